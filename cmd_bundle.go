@@ -0,0 +1,328 @@
+package main // Declare main package
+
+import ( // Import required packages
+	"archive/zip"    // For the bundle
+	"bytes"          // For rendered entries
+	"context"        // For the doctor checks
+	"encoding/json"  // For JSON entries
+	"flag"           // For parsing bundle flags
+	"fmt"            // For printing the bundle path
+	"io"             // For copying files
+	"log/slog"       // For structured logging
+	"os"             // For reading artifacts
+	"path/filepath"  // For entry names
+	"regexp"         // For spotting secrets
+	"runtime"        // For the environment summary
+	"sort"           // For stable listings
+	"strings"        // For environment variables
+	"text/tabwriter" // For the rejects listing
+	"time"           // For the bundle name
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // Consistency checks
+)
+
+// Limits keeping a bundle small enough to attach to an issue
+const (
+	bundleMaxFile     = 32 << 20 // State files larger than this are left out
+	bundleScreenshots = 10       // Most recent challenge screenshots included
+)
+
+// Config keys and environment variables whose values never leave the machine
+var secretName = regexp.MustCompile(`(?i)password|passwd|secret|token|cookie|credential|webhook|api[-_]?key|access[-_]?key|auth`)
+
+const redacted = "REDACTED" // Stands in for removed secrets
+
+// bundleIndex is bundle.json, the first entry of a support bundle
+type bundleIndex struct {
+	Schema    string               `json:"schema"`
+	Build     *sdscraper.BuildInfo `json:"build"`
+	CreatedAt time.Time            `json:"created_at"`
+	Files     []bundleFile         `json:"files"`             // Entries, in the order written
+	Skipped   []bundleSkip         `json:"skipped,omitempty"` // Artifacts asked for but left out
+}
+
+// One entry of a support bundle
+type bundleFile struct {
+	Name   string `json:"name"`             // Path inside the zip
+	Source string `json:"source,omitempty"` // File it was taken from
+	Size   int64  `json:"size"`
+}
+
+// An artifact a support bundle could not include
+type bundleSkip struct {
+	Source string `json:"source"`
+	Reason string `json:"reason"`
+}
+
+// A support bundle being written
+type bundle struct {
+	zip   *zip.Writer
+	index bundleIndex
+}
+
+// Runs "support-bundle", zipping what maintainers need to look into a problem: recent logs, the config with
+// secrets redacted, the last run report, doctor output and debug artifacts such as challenge screenshots; every
+// text entry also passes through the redaction rules
+func runSupportBundle(args []string) {
+	flags := flag.NewFlagSet("support-bundle", flag.ExitOnError) // Bundle flags
+	setupLogging := logFlags(flags)                              // -log-level, -log-format and -log-file
+	options := doctorFlags(flags)                                // What the doctor checks
+	output := flags.String("o", "gojo-support-"+time.Now().UTC().Format("20060102T150405Z")+".zip", "zip file to write")
+	configPath := flags.String("config", os.Getenv("SDS_CONFIG"), "config file included with secrets redacted (default $SDS_CONFIG)")
+	logFile := flags.String("logs", os.Getenv("SDS_LOG_FILE"), "log file whose end is included, as written with -log-file (default $SDS_LOG_FILE)")
+	logTail := flags.String("log-tail", "8MiB", "how much of the end of the log file to include")
+	reportPath := flags.String("report", "", "JSON report of the last run, as written with -report")
+	screenshots := flags.String("challenge-screenshots", "challenges", "directory of challenge screenshots to include the latest of")
+	rejectDir := flags.String("rejects", "rejects/", "quarantine directory whose listing is included")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: support-bundle [flags]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args) // Exits on invalid flags
+	setupLogging()
+	if flags.NArg() > 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	tail, err := sdscraper.ParseByteSize(*logTail)
+	if err != nil {
+		fatal("Invalid -log-tail", "err", err)
+	}
+
+	if err := sdscraper.CheckWritable("create", *output); err != nil {
+		fatal("Creating the bundle failed", "err", err)
+	}
+	file, err := os.Create(*output)
+	if err != nil {
+		fatal("Creating the bundle failed", "err", err)
+	}
+	b := &bundle{zip: zip.NewWriter(file), index: bundleIndex{Schema: bundleSchema, Build: build(), CreatedAt: time.Now().UTC()}}
+
+	checks := doctorChecks(context.Background(), *options)
+	var doctorText bytes.Buffer
+	printDoctor(&doctorText, checks)
+	b.add("doctor.txt", "", doctorText.Bytes())
+	b.addJSON("doctor.json", doctorReport{Schema: doctorSchema, Build: build(), Checks: checks})
+	b.add("environment.txt", "", environment())
+	if *configPath != "" {
+		if config, err := loadConfig(*configPath); err != nil {
+			b.skip(*configPath, err.Error())
+		} else {
+			b.addJSON("config.json", redactConfig(config)) // Re-encoded, so comments holding secrets go too
+		}
+	}
+	if *logFile != "" {
+		b.addTail("logs/"+filepath.Base(*logFile), *logFile, tail)
+	}
+	if *reportPath != "" {
+		b.addFile("report.json", *reportPath)
+	}
+	b.addFile("manifest.json", options.ManifestPath)
+	b.addFile("state.json", options.CheckpointPath)
+	if manifest, err := sdscraper.ReadManifest(options.ManifestPath); err == nil {
+		problems := sdscraper.Fsck(manifest, sdscraper.FsckOptions{OutputDir: options.OutputDir, CheckpointPath: options.CheckpointPath})
+		report := fsckReport{Schema: fsckSchema, Build: build(), Problems: []problemReport{}, Remaining: len(problems)}
+		for _, problem := range problems {
+			report.Problems = append(report.Problems, problemReport{Problem: problem, Repairable: problem.Repairable()})
+		}
+		b.addJSON("fsck.json", report)
+	}
+	for _, path := range latestFiles(*screenshots, "*.png", bundleScreenshots) {
+		b.addFile("challenges/"+filepath.Base(path), path)
+	}
+	if *rejectDir != "" {
+		b.add("rejects.txt", *rejectDir, listDir(*rejectDir))
+	}
+
+	b.addJSON("bundle.json", b.index) // Last, so it lists everything
+	if err := b.zip.Close(); err != nil {
+		fatal("Writing the bundle failed", "err", err)
+	}
+	if err := file.Close(); err != nil {
+		fatal("Writing the bundle failed", "err", err)
+	}
+	slog.Info("Wrote support bundle", "path", *output, "files", len(b.index.Files), "skipped", len(b.index.Skipped))
+	fmt.Println(*output)
+}
+
+// Writes one entry, redacted unless it is an image
+func (b *bundle) add(name, source string, data []byte) {
+	switch filepath.Ext(name) {
+	case ".png":
+	case ".json":
+		data = redactor.JSON(data) // Keeps the entry valid JSON
+	default:
+		data = []byte(redactor.String(string(data)))
+	}
+	writer, err := b.zip.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: b.index.CreatedAt})
+	if err == nil {
+		_, err = writer.Write(data)
+	}
+	if err != nil {
+		fatal("Writing the bundle failed", "entry", name, "err", err)
+	}
+	b.index.Files = append(b.index.Files, bundleFile{Name: name, Source: source, Size: int64(len(data))})
+}
+
+// Writes one entry as indented JSON
+func (b *bundle) addJSON(name string, v any) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fatal("Writing the bundle failed", "entry", name, "err", err)
+	}
+	b.add(name, "", append(data, '\n'))
+}
+
+// Copies a file in whole, noting it as skipped when it is missing or too large
+func (b *bundle) addFile(name, path string) {
+	if path == "" {
+		return
+	}
+	info, err := os.Stat(path)
+	switch {
+	case err != nil:
+		b.skip(path, err.Error())
+		return
+	case info.Size() > bundleMaxFile:
+		b.skip(path, fmt.Sprintf("%d bytes, over the %d byte limit", info.Size(), bundleMaxFile))
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		b.skip(path, err.Error())
+		return
+	}
+	b.add(name, path, data)
+}
+
+// Copies the last size bytes of a file, starting at a line boundary
+func (b *bundle) addTail(name, path string, size int64) {
+	file, err := os.Open(path)
+	if err != nil {
+		b.skip(path, err.Error())
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		b.skip(path, err.Error())
+		return
+	}
+	offset := max(0, info.Size()-size)
+	data, err := io.ReadAll(io.NewSectionReader(file, offset, info.Size()-offset))
+	if err != nil {
+		b.skip(path, err.Error())
+		return
+	}
+	if offset > 0 { // Drop the partial first line
+		if newline := bytes.IndexByte(data, '\n'); newline >= 0 {
+			data = data[newline+1:]
+		}
+	}
+	b.add(name, path, data)
+}
+
+// Notes an artifact left out
+func (b *bundle) skip(source, reason string) {
+	slog.Warn("Left out of the support bundle", "source", source, "reason", reason)
+	b.index.Skipped = append(b.index.Skipped, bundleSkip{Source: source, Reason: reason})
+}
+
+// Describes the platform and the SDS_ and proxy environment variables, secret values redacted
+func environment() []byte {
+	var text strings.Builder
+	fmt.Fprintf(&text, "os=%s arch=%s go=%s cpus=%d\n", runtime.GOOS, runtime.GOARCH, runtime.Version(), runtime.NumCPU())
+	var variables []string
+	for _, variable := range os.Environ() {
+		name, value, _ := strings.Cut(variable, "=")
+		if !strings.HasPrefix(name, "SDS_") && !strings.HasSuffix(strings.ToUpper(name), "_PROXY") {
+			continue
+		}
+		if secretName.MatchString(name) || strings.Contains(value, "@") { // Proxy URLs may carry credentials
+			value = redacted
+		}
+		variables = append(variables, name+"="+value)
+	}
+	sort.Strings(variables)
+	for _, variable := range variables {
+		text.WriteString(variable + "\n")
+	}
+	return []byte(text.String())
+}
+
+// Returns a copy of the config with the values of secret-looking keys replaced
+func redactConfig(config *configFile) *configFile {
+	copied := &configFile{Profile: config.Profile, Defaults: redactValues(config.Defaults), Profiles: make(map[string]map[string]any)}
+	for name, values := range config.Profiles {
+		copied.Profiles[name] = redactValues(values)
+	}
+	for _, site := range config.Sites {
+		copied.Sites = append(copied.Sites, redactValues(site))
+	}
+	return copied
+}
+
+// Returns values with secret-looking keys redacted, recursing into nested maps such as headers
+func redactValues(values map[string]any) map[string]any {
+	if values == nil {
+		return nil
+	}
+	copied := make(map[string]any, len(values))
+	for key, value := range values {
+		switch typed := value.(type) {
+		case map[string]any:
+			value = redactValues(typed)
+		case []any: // Repeated "Name: value" headers
+			items := make([]any, len(typed))
+			for i, item := range typed {
+				if text, ok := item.(string); ok {
+					if name, _, isHeader := strings.Cut(text, ":"); isHeader && secretName.MatchString(name) {
+						item = name + ": " + redacted
+					}
+				}
+				items[i] = item
+			}
+			value = items
+			if secretName.MatchString(key) {
+				value = redacted
+			}
+		default:
+			if secretName.MatchString(key) {
+				value = redacted
+			}
+		}
+		copied[key] = value
+	}
+	return copied
+}
+
+// Returns the newest count files in dir matching pattern
+func latestFiles(dir, pattern string, count int) []string {
+	paths, _ := filepath.Glob(filepath.Join(dir, pattern)) // A missing directory simply has none
+	modified := make(map[string]time.Time, len(paths))
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			modified[path] = info.ModTime()
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool { return modified[paths[i]].After(modified[paths[j]]) })
+	return paths[:min(count, len(paths))]
+}
+
+// Lists a directory's files with their sizes and times, without their contents
+func listDir(dir string) []byte {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return []byte(err.Error() + "\n")
+	}
+	var text bytes.Buffer
+	table := tabwriter.NewWriter(&text, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "MODIFIED\tSIZE\tNAME")
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && !entry.IsDir() {
+			fmt.Fprintf(table, "%s\t%d\t%s\n", info.ModTime().UTC().Format(time.RFC3339), info.Size(), entry.Name())
+		}
+	}
+	table.Flush()
+	return text.Bytes()
+}
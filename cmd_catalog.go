@@ -0,0 +1,317 @@
+package main // Declare main package
+
+import ( // Import required packages
+	"context"        // For running queries
+	"flag"           // For parsing catalog flags
+	"fmt"            // For printing results
+	"os"             // For output and exit codes
+	"slices"         // For sorting JSON output
+	"strings"        // For comparing file names
+	"text/tabwriter" // For aligned tables
+	"time"           // For formatting timestamps
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // Manifest access
+)
+
+// Runs "catalog deleted|delete|restore", which list, soft-delete and restore catalog entries, "catalog tag|untag|tagged",
+// which label entries and list them by label, "catalog correct", which fixes extracted metadata by hand, "catalog
+// review", which lists metadata extracted with little confidence, "catalog print-index", which writes the printable
+// binder index, and "catalog list|query", which read the SQLite catalog
+func runCatalog(args []string) {
+	flags := flag.NewFlagSet("catalog", flag.ExitOnError) // Catalog flags
+	setupLogging := logFlags(flags)                       // -log-level and -log-format
+	jsonOutput := formatFlags(flags)                      // -format
+	manifestPath := flags.String("manifest", "manifest.json", "path of the manifest file")
+	reason := flags.String("reason", "", "why the document is being deleted (delete only)")
+	dbPath := flags.String("db", "catalog.db", "SQLite catalog written by crawls with -catalog-db (list and query only)")
+	product := flags.String("product", "", "only documents whose product contains this text, ignoring case (list only)")
+	indexURL := flags.String("index-url", "", "serve-mode address the QR codes open; empty links to the source URLs (print-index only)")
+	indexTitle := flags.String("index-title", "", "heading of every index page (print-index only)")
+	indexTags := flags.String("index-tags", "", "only list documents with these comma-separated tags; -tag excludes one (print-index only)")
+	stampTemplates := flags.String("stamp-templates", "stamps.json", "JSON file of per-facility stamp templates (print-index only)")
+	facility := flags.String("facility", "", "head every index page with this facility's logo, name and contact from -stamp-templates (print-index only)")
+	snapshot := flags.String("snapshot", "", "index this snapshot, by name or ID, instead of the live catalog, with QR codes opening its copies under -index-url (print-index only)")
+	snapshotDir := flags.String("snapshots", "snapshots/", "directory the snapshots are kept in (print-index only)")
+	note := flags.String("note", "", "why the metadata is being corrected (correct only)")
+	batch := flags.String("batch", "", "YAML, TOML or JSON file of corrections, a list of {filename, fields, note} (correct only)")
+	minConfidence := flags.Float64("min-confidence", sdscraper.LowConfidence, "list fields found with less confidence than this, from 0 to 1 (review only)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: catalog [flags] deleted | delete <filename> | restore <filename> | tag <filename> <tag>... | untag <filename> <tag>... | tagged [filter] | correct <filename> <field>=<value>... | review | print-index <out.pdf> | list | query <sql>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args) // Exits on invalid flags
+	setupLogging()
+	asJSON := jsonOutput()
+
+	switch action := flags.Arg(0); action {
+	case "list":
+		queryCatalogDB(*dbPath, asJSON, `SELECT filename, product, revision, downloaded_at, last_verified FROM documents
+			WHERE deleted_at = '' AND product LIKE '%' || ? || '%' ORDER BY product, filename`, *product)
+		return
+	case "query":
+		if flags.NArg() != 2 {
+			flags.Usage()
+			os.Exit(2)
+		}
+		queryCatalogDB(*dbPath, asJSON, flags.Arg(1))
+		return
+	}
+
+	manifest, err := sdscraper.LoadManifest(*manifestPath)
+	if err != nil {
+		fatal("Reading manifest failed", "err", err)
+	}
+	switch action := flags.Arg(0); action {
+	case "deleted":
+		if asJSON {
+			report := catalogReport{Schema: catalogSchema, Build: build(), Action: action, Documents: []catalogDocument{}}
+			for documentURL, entry := range manifest.Documents {
+				if !entry.DeletedAt.IsZero() {
+					report.Documents = append(report.Documents, catalogDocument{URL: documentURL, Filename: entry.Filename, DeletedAt: entry.DeletedAt, Reason: entry.DeletedReason})
+				}
+			}
+			slices.SortFunc(report.Documents, func(a, b catalogDocument) int { return strings.Compare(a.Filename, b.Filename) })
+			printJSON(report)
+			return
+		}
+		table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(table, "FILENAME\tDELETED\tREASON")
+		for _, entry := range manifest.Documents {
+			if !entry.DeletedAt.IsZero() {
+				fmt.Fprintf(table, "%s\t%s\t%s\n", entry.Filename, entry.DeletedAt.Format(time.RFC3339), entry.DeletedReason)
+			}
+		}
+		table.Flush()
+		return
+	case "delete", "restore":
+		sourceURL, _, ok := manifest.FindByFilename(flags.Arg(1))
+		if !ok {
+			fatal("Not in the catalog", "filename", flags.Arg(1))
+		}
+		var changed bool
+		if action == "delete" {
+			changed = manifest.SoftDelete(sourceURL, *reason)
+		} else {
+			changed = manifest.Restore(sourceURL)
+		}
+		if !changed {
+			fatal("Nothing to "+action, "filename", flags.Arg(1))
+		}
+		if err := sdscraper.SaveManifest(*manifestPath, manifest); err != nil {
+			fatal("Saving manifest failed", "err", err)
+		}
+		if asJSON {
+			entry := manifest.Documents[sourceURL]
+			printJSON(catalogReport{Schema: catalogSchema, Build: build(), Action: action, Documents: []catalogDocument{{
+				URL: sourceURL, Filename: entry.Filename, DeletedAt: entry.DeletedAt, Reason: entry.DeletedReason}}})
+			return
+		}
+		fmt.Printf("%sd %s\n", action, flags.Arg(1))
+	case "tag", "untag":
+		if flags.NArg() < 3 {
+			flags.Usage()
+			os.Exit(2)
+		}
+		sourceURL, entry, ok := manifest.FindByFilename(flags.Arg(1))
+		if !ok {
+			fatal("Not in the catalog", "filename", flags.Arg(1))
+		}
+		var tags []string
+		for _, tag := range flags.Args()[2:] {
+			normalized, err := sdscraper.NormalizeTag(tag)
+			if err != nil {
+				fatal("Invalid tag", "err", err)
+			}
+			tags = append(tags, normalized)
+		}
+		var changed bool
+		if action == "tag" {
+			changed = entry.AddTags(tags...)
+		} else {
+			changed = entry.RemoveTags(tags...)
+		}
+		if changed {
+			manifest.RecordChange(sdscraper.ChangeUpdated, entry)
+			if err := sdscraper.SaveManifest(*manifestPath, manifest); err != nil {
+				fatal("Saving manifest failed", "err", err)
+			}
+		}
+		if asJSON {
+			printJSON(catalogReport{Schema: catalogSchema, Build: build(), Action: action, Documents: []catalogDocument{{
+				URL: sourceURL, Filename: entry.Filename, Tags: entry.Tags}}})
+			return
+		}
+		fmt.Printf("%s: %s\n", entry.Filename, strings.Join(entry.Tags, ", "))
+	case "correct":
+		var patches []sdscraper.MetadataPatch
+		switch {
+		case *batch != "" && flags.NArg() == 1:
+			if err := decodeFile(*batch, &patches); err != nil {
+				fatal("Reading the corrections failed", "path", *batch, "err", err)
+			}
+		case *batch == "" && flags.NArg() >= 3:
+			patch := sdscraper.MetadataPatch{Filename: flags.Arg(1), Fields: make(map[string]string), Note: *note}
+			for _, assignment := range flags.Args()[2:] {
+				field, value, ok := strings.Cut(assignment, "=")
+				if !ok {
+					fatal("Corrections are field=value, an empty value lifting the correction", "got", assignment)
+				}
+				patch.Fields[field] = value
+			}
+			patches = append(patches, patch)
+		default:
+			flags.Usage()
+			os.Exit(2)
+		}
+		changed, err := manifest.ApplyPatches(patches)
+		if err != nil {
+			fatal("Invalid corrections; nothing was changed", "err", err)
+		}
+		if err := sdscraper.SaveManifest(*manifestPath, manifest); err != nil {
+			fatal("Saving manifest failed", "err", err)
+		}
+		report := catalogReport{Schema: catalogSchema, Build: build(), Action: action, Documents: []catalogDocument{}}
+		for _, entry := range changed {
+			report.Documents = append(report.Documents, catalogDocument{URL: entry.URL, Filename: entry.Filename, Corrections: entry.Corrections})
+		}
+		if asJSON {
+			printJSON(report)
+			return
+		}
+		for _, entry := range changed {
+			fmt.Printf("%s: product %q, sku %q, language %q, revision %q; %d fields corrected\n", entry.Filename, entry.Product, entry.SKU, entry.Language, entry.Revision, len(entry.Corrections))
+		}
+	case "review":
+		if flags.NArg() != 1 {
+			flags.Usage()
+			os.Exit(2)
+		}
+		report := catalogReport{Schema: catalogSchema, Build: build(), Action: action, Documents: []catalogDocument{}}
+		for documentURL, entry := range manifest.Documents {
+			if fields := entry.LowConfidenceFields(*minConfidence); entry.DeletedAt.IsZero() && len(fields) > 0 {
+				report.Documents = append(report.Documents, catalogDocument{URL: documentURL, Filename: entry.Filename, Sources: entry.Sources, Review: fields})
+			}
+		}
+		slices.SortFunc(report.Documents, func(a, b catalogDocument) int { return strings.Compare(a.Filename, b.Filename) })
+		if asJSON {
+			printJSON(report)
+			return
+		}
+		table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(table, "FILENAME\tFIELD\tVALUE\tMETHOD\tCONFIDENCE")
+		for _, document := range report.Documents {
+			entry := manifest.Documents[document.URL]
+			for _, field := range document.Review {
+				source, _ := entry.MetadataSource(field)
+				fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%.2f\n", document.Filename, field, entry.MetadataField(field), source.Method, source.Confidence)
+			}
+		}
+		table.Flush()
+	case "print-index":
+		if flags.NArg() != 2 {
+			flags.Usage()
+			os.Exit(2)
+		}
+		options := sdscraper.PrintIndexOptions{Title: *indexTitle, LinkBase: *indexURL, Tags: tagFilter("-index-tags", *indexTags), Stamp: stampTemplate(*stampTemplates, *facility)}
+		if *snapshot != "" { // A binder for an audit date rather than for today
+			frozen, err := (sdscraper.SnapshotStore{Dir: *snapshotDir}).Open(*snapshot)
+			if err == nil {
+				manifest, err = frozen.Manifest()
+			}
+			if err != nil {
+				fatal("Opening the snapshot failed", "err", err)
+			}
+			options.Snapshot, options.Generated = frozen.Name, frozen.CreatedAt
+			if options.LinkBase != "" {
+				options.LinkBase = strings.TrimSuffix(options.LinkBase, "/") + "/snapshots/" + frozen.ID
+			}
+		}
+		if err := sdscraper.SavePrintIndex(flags.Arg(1), manifest, options); err != nil {
+			fatal("Writing the print index failed", "path", flags.Arg(1), "err", err)
+		}
+		if !asJSON {
+			fmt.Println("Wrote", flags.Arg(1))
+		}
+	case "tagged":
+		if flags.NArg() > 2 {
+			flags.Usage()
+			os.Exit(2)
+		}
+		filter := tagFilter("filter", flags.Arg(1))
+		report := catalogReport{Schema: catalogSchema, Build: build(), Action: action, Documents: []catalogDocument{}}
+		for documentURL, entry := range manifest.Documents {
+			if entry.DeletedAt.IsZero() && len(entry.Tags) > 0 && filter.Match(entry.Tags) {
+				report.Documents = append(report.Documents, catalogDocument{URL: documentURL, Filename: entry.Filename, Tags: entry.Tags})
+			}
+		}
+		slices.SortFunc(report.Documents, func(a, b catalogDocument) int { return strings.Compare(a.Filename, b.Filename) })
+		if asJSON {
+			printJSON(report)
+			return
+		}
+		table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(table, "FILENAME\tTAGS")
+		for _, document := range report.Documents {
+			fmt.Fprintf(table, "%s\t%s\n", document.Filename, strings.Join(document.Tags, ", "))
+		}
+		table.Flush()
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+}
+
+// catalogReport is the JSON form of a catalog command: the deleted or tagged documents, or the one just changed
+type catalogReport struct {
+	Schema    string               `json:"schema"`
+	Build     *sdscraper.BuildInfo `json:"build"`
+	Action    string               `json:"action"` // deleted, delete, restore, tag, untag, tagged, correct or review
+	Documents []catalogDocument    `json:"documents"`
+}
+
+// catalogDocument is one catalog entry as catalog commands report it
+type catalogDocument struct {
+	URL       string    `json:"url"`
+	Filename  string    `json:"filename"`
+	DeletedAt time.Time `json:"deleted_at,omitzero"` // Zero once restored
+	Reason    string    `json:"reason,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
+
+	Corrections map[string]*sdscraper.MetadataCorrection `json:"corrections,omitempty"` // Fields corrected by hand
+	Sources     map[string]sdscraper.FieldSource         `json:"sources,omitempty"`     // How each field was extracted
+	Review      []string                                 `json:"review,omitempty"`      // Fields below -min-confidence
+}
+
+// Runs a read-only query against the SQLite catalog and prints the rows as a table or as JSON
+func queryCatalogDB(path string, asJSON bool, query string, args ...any) {
+	if _, err := os.Stat(path); err != nil {
+		fatal("No SQLite catalog; crawl with -catalog-db first", "path", path, "err", err)
+	}
+	catalog, err := sdscraper.OpenCatalogDB(path, true) // Statements that write fail
+	if err != nil {
+		fatal("Opening the SQLite catalog failed", "path", path, "err", err)
+	}
+	defer catalog.Close()
+	columns, rows, err := catalog.Query(context.Background(), query, args...)
+	if err != nil {
+		fatal("Query failed", "err", err)
+	}
+	if asJSON {
+		printJSON(queryReport{Schema: catalogQuerySchema, Build: build(), Columns: columns, Rows: append([][]string{}, rows...)})
+		return
+	}
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, strings.ToUpper(strings.Join(columns, "\t")))
+	for _, row := range rows {
+		fmt.Fprintln(table, strings.Join(row, "\t"))
+	}
+	table.Flush()
+}
+
+// queryReport is the JSON form of catalog list and query
+type queryReport struct {
+	Schema  string               `json:"schema"`
+	Build   *sdscraper.BuildInfo `json:"build"`
+	Columns []string             `json:"columns"`
+	Rows    [][]string           `json:"rows"` // Values as text, NULL as ""
+}
@@ -0,0 +1,185 @@
+package main // Declare main package
+
+import ( // Import required packages
+	"flag"    // For walking the commands' flags
+	"fmt"     // For printing the reference
+	"io"      // For output writers
+	"os"      // For stdout and the environment
+	"reflect" // For the config file's own keys
+	"regexp"  // For environment variable names in usage strings
+	"slices"  // For sorting settings
+	"strings" // For escaping table cells
+	"time"    // For duration defaults
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // Build information
+)
+
+// Runs "config-reference", printing every config file key with its type, default and environment variable, generated
+// from the flags the crawl and serve commands register, so the reference cannot drift from the binary
+func runConfigReference(args []string) {
+	flags := flag.NewFlagSet("config-reference", flag.ExitOnError) // Reference flags
+	jsonOutput := formatFlags(flags)                               // -format
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: config-reference [flags]\n\nPrints the config file reference as Markdown, or as JSON with -format json.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args) // Exits on invalid flags
+	if flags.NArg() > 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	reference := configReference()
+	if jsonOutput() {
+		printJSON(reference)
+		return
+	}
+	printConfigReference(os.Stdout, reference)
+}
+
+// configReferenceReport is the JSON form of the config reference
+type configReferenceReport struct {
+	Schema string               `json:"schema"`
+	Build  *sdscraper.BuildInfo `json:"build"`
+	File   []configKey          `json:"file"`  // Top-level keys of a config file
+	Crawl  []configSetting      `json:"crawl"` // Settings of the crawl command, in defaults and profiles
+	Serve  []configSetting      `json:"serve"` // Settings of the serve command, in defaults and profiles
+	Site   []configSetting      `json:"site"`  // Settings of one sites entry besides its name
+}
+
+// configKey is a top-level key of a config file
+type configKey struct {
+	Key         string `json:"key"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
+
+// configSetting is one setting, the name of the flag it sets
+type configSetting struct {
+	Key         string `json:"key"`
+	Type        string `json:"type"`              // bool, integer, number, duration, string, list or repeatable
+	Default     string `json:"default,omitempty"` // As the flag prints it, empty for none
+	Env         string `json:"env,omitempty"`     // Environment variable supplying the default
+	Description string `json:"description"`
+}
+
+var envReference = regexp.MustCompile(`\$(SDS_[A-Z0-9_]+)`) // Environment defaults as usage strings name them
+
+// Builds the reference from the commands' flag sets and the config file struct
+func configReference() configReferenceReport {
+	for _, variable := range os.Environ() { // Defaults read from the environment would leak this shell's values
+		if name, _, _ := strings.Cut(variable, "="); strings.HasPrefix(name, "SDS_") {
+			os.Unsetenv(name)
+		}
+	}
+	reference := configReferenceReport{Schema: configReferenceSchema, Build: build()}
+	fileType := reflect.TypeFor[configFile]()
+	for i := range fileType.NumField() {
+		field := fileType.Field(i)
+		kind := "mapping"
+		switch field.Type.Kind() {
+		case reflect.String:
+			kind = "string"
+		case reflect.Slice:
+			kind = "list of mappings"
+		}
+		reference.File = append(reference.File, configKey{Key: field.Tag.Get("yaml"), Type: kind, Description: field.Tag.Get("doc")})
+	}
+
+	crawl := flag.NewFlagSet("crawl", flag.ContinueOnError)
+	crawlCommand(crawl)
+	reference.Crawl = configSettings(crawl)
+	serve := flag.NewFlagSet("serve", flag.ContinueOnError)
+	serveCommand(serve)
+	reference.Serve = configSettings(serve)
+
+	site := flag.NewFlagSet("site", flag.ContinueOnError) // As configuredSites registers them
+	registerCrawlFlags(site)
+	site.String("output", "", "directory this site's PDFs are written to (default: the crawl's -output with the site name appended)")
+	site.String("manifest", "", "path of this site's manifest file (default: the crawl's -manifest with the site name as suffix)")
+	site.String("template", "", "built-in vendor site template whose settings the entry starts from, e.g. ecolab; the entry's name defaults to it, and the vendors command lists them")
+	reference.Site = configSettings(site)
+	return reference
+}
+
+// Returns the settings of a flag set, sorted by key; -config and -profile cannot be set from the file itself
+func configSettings(flags *flag.FlagSet) []configSetting {
+	var settings []configSetting
+	flags.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" || f.Name == "profile" {
+			return
+		}
+		setting := configSetting{Key: f.Name, Type: flagType(f), Default: f.DefValue, Description: f.Usage}
+		if match := envReference.FindStringSubmatch(f.Usage); match != nil {
+			setting.Env = match[1]
+		}
+		if setting.Type == "bool" && setting.Default == "false" || setting.Type == "repeatable" && setting.Default == "[]" {
+			setting.Default = ""
+		}
+		settings = append(settings, setting)
+	})
+	slices.SortFunc(settings, func(a, b configSetting) int { return strings.Compare(a.Key, b.Key) })
+	return settings
+}
+
+// Returns the type of value a flag takes in a config file
+func flagType(f *flag.Flag) string {
+	if repeatable(f) {
+		return "repeatable" // A list, or a mapping for name: value flags
+	}
+	getter, ok := f.Value.(flag.Getter)
+	if !ok {
+		return "string"
+	}
+	switch getter.Get().(type) {
+	case bool:
+		return "bool"
+	case int, int64, uint, uint64:
+		return "integer"
+	case float64:
+		return "number"
+	case time.Duration:
+		return "duration"
+	}
+	if strings.Contains(f.Usage, "comma-separated") {
+		return "list" // A list, or the same comma-separated string the flag takes
+	}
+	return "string"
+}
+
+// Prints the reference as Markdown
+func printConfigReference(w io.Writer, reference configReferenceReport) {
+	fmt.Fprintln(w, "# Configuration reference")
+	fmt.Fprintf(w, "\nGenerated by `gojo config-reference` from gojo %s. A config file (YAML, TOML or JSON, chosen by extension)\n", reference.Build.Version)
+	fmt.Fprintln(w, "sets flags by name: a setting is the flag without its dash, and flags given on the command line win.")
+	fmt.Fprintln(w, "\n## File layout\n\n| Key | Type | Description |\n| --- | --- | --- |")
+	for _, key := range reference.File {
+		fmt.Fprintf(w, "| `%s` | %s | %s |\n", key.Key, key.Type, markdownCell(key.Description))
+	}
+	sections := []struct {
+		title, intro string
+		settings     []configSetting
+	}{
+		{"Crawl settings", "Read by the crawl, from `defaults` and the chosen profile.", reference.Crawl},
+		{"Serve settings", "Read by `gojo serve`, from `defaults` and the chosen profile.", reference.Serve},
+		{"Site settings", "Accepted in each `sites` entry besides its `name`; they start from the crawl's values.", reference.Site},
+	}
+	for _, section := range sections {
+		fmt.Fprintf(w, "\n## %s\n\n%s\n\n| Key | Type | Default | Environment | Description |\n| --- | --- | --- | --- | --- |\n", section.title, section.intro)
+		for _, setting := range section.settings {
+			defaultValue, env := "", ""
+			if setting.Default != "" {
+				defaultValue = "`" + strings.ReplaceAll(setting.Default, "|", `\|`) + "`"
+			}
+			if setting.Env != "" {
+				env = "`" + setting.Env + "`"
+			}
+			fmt.Fprintf(w, "| `%s` | %s | %s | %s | %s |\n", setting.Key, setting.Type, defaultValue, env, markdownCell(setting.Description))
+		}
+	}
+	fmt.Fprintln(w, "\nTypes: list settings take a list or a comma-separated string; repeatable settings take a list, or a mapping for `name: value` flags.")
+}
+
+// Escapes text for a Markdown table cell
+func markdownCell(text string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(text)
+}
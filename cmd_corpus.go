@@ -0,0 +1,60 @@
+package main // Declare main package
+
+import ( // Import required packages
+	"flag" // For parsing corpus flags
+	"fmt"  // For printing the summary
+	"os"   // For exit codes
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper"         // Byte sizes
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper/sdstest" // The generator
+)
+
+// Runs "test-corpus", writing synthetic SDS PDFs of every kind, valid and broken, for validating a deployment
+// without gojo.com; the same seed always writes the same files
+func runTestCorpus(args []string) {
+	flags := flag.NewFlagSet("test-corpus", flag.ExitOnError) // Corpus flags
+	setupLogging := logFlags(flags)                           // -log-level and -log-format
+	jsonOutput := formatFlags(flags)                          // -format
+	dir := flags.String("dir", "corpus", "directory the documents and corpus.json are written to")
+	seed := flags.Int64("seed", 1, "varies products, values and page counts")
+	documents := flags.Int("documents", 5, "number of ordinary text documents")
+	largeSizes := flags.String("large", "1MiB,8MiB", "comma-separated sizes of the padded large documents (empty for none)")
+	flags.Parse(args) // Exits on invalid flags
+	setupLogging()
+	asJSON := jsonOutput()
+	if flags.NArg() > 0 {
+		fmt.Fprintln(flags.Output(), "usage: test-corpus [flags]")
+		os.Exit(2)
+	}
+
+	sizes := []int64{} // Empty rather than nil, which means the defaults
+	for _, value := range splitList(*largeSizes) {
+		size, err := sdscraper.ParseByteSize(value)
+		if err != nil {
+			fatal("Invalid -large", "err", err)
+		}
+		sizes = append(sizes, size)
+	}
+	corpus := sdstest.Corpus(sdstest.CorpusOptions{Seed: *seed, Documents: *documents, LargeSizes: sizes})
+	if err := sdstest.WriteCorpus(*dir, corpus); err != nil {
+		fatal("Writing the corpus failed", "err", err)
+	}
+	if asJSON {
+		printJSON(corpusReport{Schema: corpusSchema, Build: build(), Dir: *dir, Seed: *seed, Documents: corpus})
+		return
+	}
+	var total int64
+	for _, document := range corpus {
+		total += document.Size
+	}
+	fmt.Printf("Wrote %d documents (%s) to %s\n", len(corpus), formatBytes(total), *dir)
+}
+
+// corpusReport is the JSON form of the test-corpus command
+type corpusReport struct {
+	Schema    string                   `json:"schema"`
+	Build     *sdscraper.BuildInfo     `json:"build"`
+	Dir       string                   `json:"dir"`
+	Seed      int64                    `json:"seed"`
+	Documents []sdstest.CorpusDocument `json:"documents"`
+}
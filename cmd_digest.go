@@ -0,0 +1,89 @@
+package main // Declare main package
+
+import ( // Import required packages
+	"context"        // For stopping between files
+	"errors"         // For matching cancellation
+	"flag"           // For parsing digest flags
+	"fmt"            // For printing results
+	"os"             // For output and exit codes
+	"os/signal"      // For stopping on Ctrl-C
+	"strings"        // For listing algorithms
+	"text/tabwriter" // For aligned tables
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // Digests
+)
+
+// digestReport is the JSON form of a digest run
+type digestReport struct {
+	Schema     string               `json:"schema"`
+	Build      *sdscraper.BuildInfo `json:"build"`
+	Algorithms []string             `json:"algorithms"`
+	*sdscraper.DigestResult
+}
+
+// Runs "digest", which records further digests of the stored documents from the files on disk, e.g. when a
+// compliance policy starts requiring SHA-512, without downloading anything again
+func runDigest(args []string) {
+	flags := flag.NewFlagSet("digest", flag.ExitOnError) // Digest flags
+	setupLogging := logFlags(flags)                      // -log-level and -log-format
+	jsonOutput := formatFlags(flags)                     // -format
+	manifestPath := flags.String("manifest", "manifest.json", "path of the manifest file")
+	outputDir := flags.String("output", "PDFs/", "directory holding downloaded documents")
+	algorithms := flags.String("algorithms", "", "comma-separated digests to add where missing: "+strings.Join(sdscraper.HasherNames(), ", "))
+	blockSize := flags.Int("block-size", 1<<20, "read size in bytes when hashing")
+	mmap := flags.Bool("mmap", false, "memory-map files while hashing instead of reading them in blocks")
+	flags.Parse(args) // Exits on invalid flags
+	setupLogging()
+	asJSON := jsonOutput()
+	list := hashers("-algorithms", *algorithms)
+	if len(list) == 0 {
+		fatal("give the digests to add with -algorithms, e.g. -algorithms sha512")
+	}
+	if sdscraper.ReadOnly() {
+		fatal("digest records digests in the manifest, which -read-only forbids")
+	}
+
+	manifest, err := sdscraper.ReadManifest(*manifestPath)
+	if err != nil {
+		fatal("Reading the manifest failed", "err", err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt) // Ctrl-C keeps what was digested so far
+	defer stop()
+	result, err := sdscraper.AddDigests(ctx, manifest, *outputDir, list, sdscraper.HashOptions{BlockSize: *blockSize, MMap: *mmap})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		fatal("Adding digests failed", "err", err)
+	}
+	if result.Added > 0 {
+		if err := sdscraper.SaveManifest(*manifestPath, manifest); err != nil {
+			fatal("Saving the manifest failed", "err", err)
+		}
+	}
+
+	report := digestReport{Schema: digestSchema, Build: build(), DigestResult: result}
+	for _, hasher := range list {
+		report.Algorithms = append(report.Algorithms, hasher.Name())
+	}
+	if report.Problems == nil {
+		report.Problems = []sdscraper.Problem{}
+	}
+	if asJSON {
+		printJSON(report)
+	} else {
+		fmt.Printf("Added digests to %d documents, %d had them already\n", result.Added, result.Current)
+		if len(result.Problems) > 0 {
+			table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(table, "KIND\tFILE\tDETAIL\tFIX")
+			for _, problem := range result.Problems {
+				fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", problem.Kind, problem.Filename, problem.Detail, problem.Fix)
+			}
+			table.Flush()
+		}
+	}
+	switch {
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "Interrupted; run the same command again to digest the rest")
+		os.Exit(130)
+	case len(result.Problems) > 0:
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,198 @@
+package main // Declare main package
+
+import ( // Import required packages
+	"context"        // For the network checks' timeout
+	"errors"         // For telling a missing file apart
+	"flag"           // For parsing doctor flags
+	"fmt"            // For check details
+	"io"             // For printing the checks
+	"net/http"       // For reachability checks
+	"os"             // For files and exit codes
+	"path/filepath"  // For probe files
+	"runtime"        // For the platform
+	"strings"        // For the listing URL template
+	"text/tabwriter" // For aligned tables
+	"time"           // For timeouts
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // Manifest and consistency checks
+)
+
+// Results of a doctor check
+const (
+	checkOK   = "ok"
+	checkWarn = "warn" // Works, but something is likely to bite
+	checkFail = "fail" // Runs will not work until it is fixed
+)
+
+// One doctor finding
+type doctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"` // checkOK, checkWarn or checkFail
+	Detail string `json:"detail"`
+}
+
+// What the doctor looks at
+type doctorOptions struct {
+	ManifestPath   string
+	OutputDir      string
+	CheckpointPath string
+	RemoteChrome   string
+	ChromePath     string
+	PageURL        string // Listing probed over the network, empty to stay offline
+	Timeout        time.Duration
+}
+
+// doctorReport is the JSON form of the doctor command
+type doctorReport struct {
+	Schema string               `json:"schema"`
+	Build  *sdscraper.BuildInfo `json:"build"`
+	Checks []doctorCheck        `json:"checks"`
+}
+
+// Runs "doctor", which checks the environment a crawl needs and prints what is wrong with it
+func runDoctor(args []string) {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError) // Doctor flags
+	setupLogging := logFlags(flags)                      // -log-level and -log-format
+	jsonOutput := formatFlags(flags)                     // -format
+	options := doctorFlags(flags)
+	flags.Parse(args) // Exits on invalid flags
+	setupLogging()
+
+	checks := doctorChecks(context.Background(), *options)
+	if jsonOutput() {
+		printJSON(doctorReport{Schema: doctorSchema, Build: build(), Checks: checks})
+	} else {
+		printDoctor(os.Stdout, checks)
+	}
+	for _, check := range checks {
+		if check.Status == checkFail {
+			os.Exit(1)
+		}
+	}
+}
+
+// Registers the flags naming what the doctor checks, shared with support-bundle
+func doctorFlags(flags *flag.FlagSet) *doctorOptions {
+	options := &doctorOptions{}
+	flags.StringVar(&options.ManifestPath, "manifest", "manifest.json", "path of the manifest file")
+	flags.StringVar(&options.OutputDir, "output", "PDFs/", "directory holding downloaded documents")
+	flags.StringVar(&options.CheckpointPath, "checkpoint", "state.json", "progress file of interrupted runs")
+	flags.StringVar(&options.RemoteChrome, "remote-chrome", "", "DevTools endpoint checked instead of a local Chrome binary")
+	flags.StringVar(&options.ChromePath, "chrome-path", os.Getenv("SDS_CHROME_PATH"), "Chrome binary checked instead of the one a crawl would find ($SDS_CHROME_PATH)")
+	flags.StringVar(&options.PageURL, "page-url", "https://www.gojo.com/{locale}/SDS", "listing probed over the network; {locale} becomes en (empty to stay offline)")
+	flags.DurationVar(&options.Timeout, "timeout", 10*time.Second, "upper bound for each network check")
+	return options
+}
+
+// Prints the checks as an aligned table
+func printDoctor(w io.Writer, checks []doctorCheck) {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "STATUS\tCHECK\tDETAIL")
+	for _, check := range checks {
+		fmt.Fprintf(table, "%s\t%s\t%s\n", check.Status, check.Name, check.Detail)
+	}
+	table.Flush()
+}
+
+// Runs every check in a fixed order
+func doctorChecks(ctx context.Context, options doctorOptions) []doctorCheck {
+	info := sdscraper.Build()
+	checks := []doctorCheck{
+		{"build", checkOK, fmt.Sprintf("%s (commit %s) %s %s/%s", info.Version, info.Commit, runtime.Version(), runtime.GOOS, runtime.GOARCH)},
+		checkChrome(ctx, options),
+		checkWritable("output", options.OutputDir),
+		checkWritable("temp", os.TempDir()),
+		checkManifest(options),
+		checkCheckpoint(options.CheckpointPath),
+	}
+	if options.PageURL != "" {
+		checks = append(checks, checkReachable(ctx, options))
+	}
+	return checks
+}
+
+// Finds the browser a crawl would use
+func checkChrome(ctx context.Context, options doctorOptions) doctorCheck {
+	if options.RemoteChrome != "" {
+		endpoint := strings.Replace(strings.Replace(options.RemoteChrome, "ws://", "http://", 1), "wss://", "https://", 1)
+		if err := probe(ctx, strings.TrimSuffix(endpoint, "/")+"/json/version", options.Timeout); err != nil {
+			return doctorCheck{"chrome", checkFail, fmt.Sprintf("remote Chrome %s: %v", options.RemoteChrome, err)}
+		}
+		return doctorCheck{"chrome", checkOK, "remote Chrome " + options.RemoteChrome + " answers"}
+	}
+	path, err := sdscraper.FindChrome(options.ChromePath)
+	if err != nil {
+		return doctorCheck{"chrome", checkWarn, fmt.Sprintf("%v; only -renderer http works, or pass -chrome-path or -remote-chrome", err)}
+	}
+	return doctorCheck{"chrome", checkOK, path}
+}
+
+// Confirms a directory exists, or can be created, and takes files
+func checkWritable(name, dir string) doctorCheck {
+	if sdscraper.ReadOnly() { // The probe would write
+		return doctorCheck{name, checkWarn, "not probed in read-only mode"}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return doctorCheck{name, checkFail, err.Error()}
+	}
+	probe, err := os.CreateTemp(dir, ".gojo-doctor-*")
+	if err != nil {
+		return doctorCheck{name, checkFail, err.Error()}
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	return doctorCheck{name, checkOK, filepath.Clean(dir) + " is writable"}
+}
+
+// Reads the manifest and cross-checks it with the files by size
+func checkManifest(options doctorOptions) doctorCheck {
+	manifest, err := sdscraper.ReadManifest(options.ManifestPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return doctorCheck{"manifest", checkOK, "none yet; the first run creates " + options.ManifestPath}
+	case err != nil:
+		return doctorCheck{"manifest", checkFail, err.Error()}
+	}
+	problems := sdscraper.Fsck(manifest, sdscraper.FsckOptions{OutputDir: options.OutputDir})
+	if len(problems) > 0 {
+		return doctorCheck{"manifest", checkWarn, fmt.Sprintf("%d documents, %d fsck problems; run fsck for details", len(manifest.Documents), len(problems))}
+	}
+	return doctorCheck{"manifest", checkOK, fmt.Sprintf("%d documents, consistent with %s", len(manifest.Documents), options.OutputDir)}
+}
+
+// Reports an interrupted run waiting to be resumed
+func checkCheckpoint(path string) doctorCheck {
+	info, err := os.Stat(path)
+	if err != nil {
+		return doctorCheck{"checkpoint", checkOK, "no interrupted run"}
+	}
+	return doctorCheck{"checkpoint", checkWarn, fmt.Sprintf("an interrupted run from %s resumes next time", info.ModTime().UTC().Format(time.RFC3339))}
+}
+
+// Fetches the listing page once
+func checkReachable(ctx context.Context, options doctorOptions) doctorCheck {
+	pageURL := strings.ReplaceAll(options.PageURL, "{locale}", "en")
+	if err := probe(ctx, pageURL, options.Timeout); err != nil {
+		return doctorCheck{"network", checkFail, fmt.Sprintf("%s: %v", pageURL, err)}
+	}
+	return doctorCheck{"network", checkOK, pageURL + " answers"}
+}
+
+// GETs rawURL, failing on transport errors and error statuses
+func probe(ctx context.Context, rawURL string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode >= http.StatusBadRequest {
+		return errors.New(response.Status)
+	}
+	return nil
+}
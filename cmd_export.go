@@ -0,0 +1,85 @@
+package main // Declare main package
+
+import ( // Import required packages
+	"flag" // For parsing export flags
+	"fmt"  // For printing the archive path
+	"os"   // For exit codes
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // Archive export
+)
+
+// Runs "export", packaging the mirror and its manifest into one dated archive for audits
+func runExport(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError) // Export flags
+	setupLogging := logFlags(flags)                      // -log-level and -log-format
+	jsonOutput := formatFlags(flags)                     // -format
+	outputDir := flags.String("output", "PDFs/", "directory holding the downloaded documents")
+	manifestPath := flags.String("manifest", "manifest.json", "path of the manifest file")
+	dir := flags.String("dir", "exports/", "directory the archive is written to")
+	archive := flags.String("archive", "zip", "archive format: zip or tar.gz")
+	statePath := flags.String("state", "exports/last-export.json", "record of what the last export held, for -since-last (empty keeps none)")
+	sinceLast := flags.Bool("since-last", false, "only include documents added or changed since the last export")
+	snapshot := flags.String("snapshot", "", "export this snapshot, by name or ID, instead of the live archive; -output, -manifest and -state are ignored")
+	snapshotDir := flags.String("snapshots", "snapshots/", "directory the snapshots are kept in")
+	vendors := flags.String("vendors", "", "only include these comma-separated vendors' documents, from a -unified-manifest catalog; content several vendors carry is exported once")
+	tags := flags.String("tags", "", "only include documents with these comma-separated tags; -tag excludes one")
+	stampTemplates := flags.String("stamp-templates", "stamps.json", "JSON file of per-facility stamp templates: facility name, contact and logo")
+	facility := flags.String("facility", "", "stamp every PDF page, and the -cover-page, with this facility's template from -stamp-templates; stored files are unchanged")
+	workers := flags.Int("workers", 0, "documents stamped at once for -cover-page and -facility, 0 for one per CPU")
+	maxMemory := flags.String("max-memory", "256MiB", "rough bound on the memory stamped documents take while they wait for the archive writer, e.g. 64MiB on small machines")
+	coverPage := flags.Bool("cover-page", false, "put a generated cover page with the source URL, SHA-256, retrieval date and an uncontrolled-copy notice in front of each PDF; stored files are unchanged")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: export [flags]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args) // Exits on invalid flags
+	setupLogging()
+	asJSON := jsonOutput()
+	if flags.NArg() > 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	memory, err := sdscraper.ParseByteSize(*maxMemory)
+	if err != nil {
+		fatal("Invalid -max-memory", "err", err)
+	}
+	var frozen *sdscraper.Snapshot
+	if *snapshot != "" {
+		if frozen, err = (sdscraper.SnapshotStore{Dir: *snapshotDir}).Open(*snapshot); err != nil {
+			fatal("Opening the snapshot failed", "err", err)
+		}
+	}
+	result, err := sdscraper.Export(sdscraper.ExportOptions{
+		OutputDir:    *outputDir,
+		ManifestPath: *manifestPath,
+		Dir:          *dir,
+		Format:       *archive,
+		StatePath:    *statePath,
+		Incremental:  *sinceLast,
+		Tags:         tagFilter("-tags", *tags),
+		Vendors:      splitList(*vendors),
+		CoverPages:   *coverPage,
+		Stamp:        stampTemplate(*stampTemplates, *facility),
+		Snapshot:     frozen,
+		Workers:      *workers,
+		MaxMemory:    memory,
+	})
+	if result == nil && err != nil {
+		fatal("Export failed", "err", err)
+	}
+	if asJSON {
+		printJSON(exportReport{Schema: exportSchema, ExportResult: result})
+	} else {
+		fmt.Println(result.Path)
+	}
+	if err != nil { // The archive exists, but the next -since-last would repeat it
+		fatal("Export incomplete", "err", err)
+	}
+}
+
+// exportReport is the JSON form of the export command, the same document as the archive's export.json
+type exportReport struct {
+	Schema string `json:"schema"`
+	*sdscraper.ExportResult
+}
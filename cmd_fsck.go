@@ -0,0 +1,121 @@
+package main // Declare main package
+
+import ( // Import required packages
+	"flag"           // For parsing fsck flags
+	"fmt"            // For printing results
+	"log/slog"       // For structured logging
+	"os"             // For output and exit codes
+	"text/tabwriter" // For aligned tables
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // Consistency checks
+)
+
+// Runs "fsck", which cross-checks the manifest, checkpoint and downloaded files and optionally repairs them
+func runFsck(args []string) {
+	flags := flag.NewFlagSet("fsck", flag.ExitOnError) // Fsck flags
+	setupLogging := logFlags(flags)                    // -log-level and -log-format
+	jsonOutput := formatFlags(flags)                   // -format
+	manifestPath := flags.String("manifest", "manifest.json", "path of the manifest file")
+	outputDir := flags.String("output", "PDFs/", "directory holding downloaded documents")
+	checkpointPath := flags.String("checkpoint", "state.json", "progress file of interrupted runs (empty to skip)")
+	verifyHashes := flags.Bool("hashes", false, "re-hash every file instead of only comparing sizes")
+	blockSize := flags.Int("block-size", 1<<20, "read size in bytes when hashing")
+	mmap := flags.Bool("mmap", false, "memory-map files while hashing instead of reading them in blocks")
+	trashDir := flags.String("trash", "trash/", "directory repairs move removed files to (empty to delete them outright)")
+	repair := flags.Bool("repair", false, "apply the safe repairs and save the manifest")
+	flags.Parse(args) // Exits on invalid flags
+	setupLogging()
+	asJSON := jsonOutput()
+
+	var trash *sdscraper.Trash // Where repaired-away files go
+	if *trashDir != "" {
+		trash = &sdscraper.Trash{Dir: *trashDir}
+	}
+	manifest, err := sdscraper.LoadManifest(*manifestPath)
+	if err != nil {
+		fatal("Reading manifest failed", "err", err)
+	}
+	problems := sdscraper.Fsck(manifest, sdscraper.FsckOptions{
+		OutputDir:      *outputDir,
+		CheckpointPath: *checkpointPath,
+		VerifyHashes:   *verifyHashes,
+		Hashing:        sdscraper.HashOptions{BlockSize: *blockSize, MMap: *mmap},
+		Trash:          trash,
+	})
+	if asJSON {
+		fsckJSON(manifest, problems, *repair, *manifestPath)
+		return
+	}
+	if len(problems) == 0 {
+		fmt.Println("No problems found")
+		return
+	}
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "KIND\tFILE\tDETAIL\tFIX")
+	for _, problem := range problems {
+		fix := problem.Fix
+		if !problem.Repairable() {
+			fix += " (manual)"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", problem.Kind, problem.Filename, problem.Detail, fix)
+	}
+	table.Flush()
+
+	if !*repair {
+		os.Exit(1) // Problems found; -repair fixes the safe ones
+	}
+	remaining := 0 // Problems still needing attention after repairs
+	for _, problem := range problems {
+		if err := problem.Repair(); err != nil {
+			remaining++
+			continue
+		}
+		slog.Info("Repaired", "kind", problem.Kind, "filename", problem.Filename)
+	}
+	if err := sdscraper.SaveManifest(*manifestPath, manifest); err != nil {
+		fatal("Saving manifest failed", "err", err)
+	}
+	if remaining > 0 {
+		fmt.Printf("%d problems need manual repair\n", remaining)
+		os.Exit(1)
+	}
+}
+
+// fsckReport is the JSON form of an fsck run
+type fsckReport struct {
+	Schema    string               `json:"schema"`
+	Build     *sdscraper.BuildInfo `json:"build"`
+	Problems  []problemReport      `json:"problems"` // Sorted by file
+	Repaired  int                  `json:"repaired"` // Zero unless -repair was given
+	Remaining int                  `json:"remaining"`
+}
+
+// problemReport is one problem with whether fsck can repair it
+type problemReport struct {
+	sdscraper.Problem
+	Repairable bool `json:"repairable"`
+}
+
+// Prints the problems as JSON, repairing them first if asked, and exits 1 while problems remain
+func fsckJSON(manifest *sdscraper.Manifest, problems []sdscraper.Problem, repair bool, manifestPath string) {
+	report := fsckReport{Schema: fsckSchema, Build: build(), Problems: []problemReport{}, Remaining: len(problems)}
+	for _, problem := range problems {
+		report.Problems = append(report.Problems, problemReport{Problem: problem, Repairable: problem.Repairable()})
+	}
+	if repair && len(problems) > 0 {
+		for _, problem := range problems {
+			if problem.Repair() == nil {
+				report.Repaired++
+			}
+		}
+		report.Remaining -= report.Repaired
+		if err := sdscraper.SaveManifest(manifestPath, manifest); err != nil {
+			fatal("Saving manifest failed", "err", err)
+		}
+	}
+	printJSON(report)
+	if report.Remaining > 0 {
+		os.Exit(1)
+	}
+}
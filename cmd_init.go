@@ -0,0 +1,236 @@
+package main // Declare main package
+
+import ( // Import required packages
+	"bufio"         // For reading answers
+	"bytes"         // For encoding TOML
+	"cmp"           // For defaults
+	"encoding/json" // For JSON config files
+	"errors"        // For answer errors
+	"flag"          // For parsing init flags and validating the settings
+	"fmt"           // For the questions
+	"io"            // For the wizard's input and output
+	"net/url"       // For checking webhook URLs
+	"os"            // For stdin and writing the files
+	"path/filepath" // For absolute paths in the service unit
+	"regexp"        // For checking locales
+	"runtime"       // For offering a systemd unit on Linux only
+	"strings"       // For normalising answers
+	"time"          // For schedule intervals
+
+	"github.com/BurntSushi/toml"                                        // For TOML config files
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // Cron schedules and read-only mode
+	"gopkg.in/yaml.v3"                                                  // For YAML config files
+)
+
+var localePattern = regexp.MustCompile(`^[a-z]{2}(-[A-Za-z]{2})?$`) // GOJO site locales such as en or fr-CA
+
+// Runs "init", which asks a few questions and writes a config file, plus optionally a systemd unit, for a scheduled
+// mirror; meant for deployments by people who would rather not learn the flags first
+func runInit(args []string) {
+	flags := flag.NewFlagSet("init", flag.ExitOnError) // Init flags
+	setupLogging := logFlags(flags)                    // -log-level and -log-format
+	configPath := flags.String("config", "gojo.yaml", "config file to write; .yaml, .yml, .toml or .json picks the format")
+	servicePath := flags.String("service", "gojo.service", "systemd unit written when asked for one")
+	force := flags.Bool("force", false, "overwrite existing files")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s init [flags]\n\nAsks where to put documents, which locales to crawl, how often and whom to tell, then writes a\nvalidated config file. Answers are read from stdin, so they can be piped in as one per line.\n\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args) // Exits on invalid flags
+	setupLogging()
+	if sdscraper.ReadOnly() {
+		fatal("init writes a config file, which -read-only forbids")
+	}
+	refuseOverwrite(*configPath, *force)
+
+	w := &wizard{in: bufio.NewScanner(os.Stdin), out: os.Stdout}
+	fmt.Fprint(w.out, "Setting up a GOJO SDS mirror. Press Enter to take the default in brackets.\n\n")
+	settings := map[string]any{
+		"output":  w.ask("Folder for the downloaded safety data sheets", "PDFs/", func(string) error { return nil }),
+		"locales": w.ask("GOJO site locales to crawl, comma-separated (e.g. en,en-CA,fr-CA)", "en", checkLocales),
+	}
+	schedule := w.ask("How often to check for new or revised sheets: an interval such as 24h, a cron expression such as \"0 3 * * *\", or \"never\" to run it from your own scheduler", "24h", checkSchedule)
+	switch {
+	case schedule == "never":
+	case strings.Contains(schedule, " "):
+		settings["watch"], settings["watch-cron"] = true, schedule
+	default:
+		settings["watch"], settings["watch-interval"] = true, schedule
+	}
+	if slack := w.ask("Slack incoming webhook URL told about new or revised sheets (empty for none)", "", checkWebhook); slack != "" {
+		settings["slack-webhook"] = slack
+	}
+	if webhook := w.ask("Other webhook URL receiving JSON about new or revised sheets (empty for none)", "", checkWebhook); webhook != "" {
+		settings["webhook"] = webhook
+	}
+	withService := schedule != "never" && runtime.GOOS == "linux" &&
+		w.ask("Also write a systemd service that keeps the mirror running? (yes/no)", "yes", checkYesNo)[0]|0x20 == 'y'
+	if withService {
+		refuseOverwrite(*servicePath, *force)
+	}
+
+	if err := checkSettings(settings); err != nil { // The crawl command must accept the file as written
+		fatal("The answers do not make a valid config", "err", err)
+	}
+	data, err := encodeConfig(*configPath, map[string]any{"defaults": settings})
+	if err == nil {
+		err = os.WriteFile(*configPath, data, 0o600) // Webhook URLs are secrets
+	}
+	if err != nil {
+		fatal("Writing the config file failed", "path", *configPath, "err", err)
+	}
+	fmt.Fprintf(w.out, "\nWrote %s. Preview a run with:\n  %s -config %s -dry-run\n", *configPath, os.Args[0], *configPath)
+	if !withService {
+		return
+	}
+	unit, err := serviceUnit(*configPath)
+	if err == nil {
+		err = os.WriteFile(*servicePath, unit, 0o644)
+	}
+	if err != nil {
+		fatal("Writing the service unit failed", "path", *servicePath, "err", err)
+	}
+	fmt.Fprintf(w.out, "Wrote %s. Install and start it with:\n  sudo cp %s /etc/systemd/system/ && sudo systemctl enable --now %s\n",
+		*servicePath, *servicePath, filepath.Base(*servicePath))
+}
+
+// Exits when path exists, unless force allows replacing it
+func refuseOverwrite(path string, force bool) {
+	if _, err := os.Stat(path); err == nil && !force {
+		fatal("Not overwriting an existing file; pass -force to replace it", "path", path)
+	}
+}
+
+// Asks questions on a terminal or a pipe of answers
+type wizard struct {
+	in  *bufio.Scanner
+	out io.Writer
+}
+
+// Asks question until check accepts the answer, the default standing in for an empty one; at the end of the input
+// the default is taken, and must pass
+func (w *wizard) ask(question, fallback string, check func(string) error) string {
+	for {
+		fmt.Fprint(w.out, question)
+		if fallback != "" {
+			fmt.Fprintf(w.out, " [%s]", fallback)
+		}
+		fmt.Fprint(w.out, ": ")
+		answer, more := fallback, w.in.Scan()
+		if more {
+			answer = cmp.Or(strings.TrimSpace(w.in.Text()), fallback)
+		} else {
+			fmt.Fprintln(w.out)
+		}
+		err := check(answer)
+		if err == nil {
+			return answer
+		}
+		if !more {
+			fatal("No valid answer before the end of the input", "question", question, "err", err)
+		}
+		fmt.Fprintf(w.out, "  %v\n", err)
+	}
+}
+
+// Accepts comma-separated GOJO locales
+func checkLocales(answer string) error {
+	locales := splitList(answer)
+	if len(locales) == 0 {
+		return errors.New("give at least one locale, e.g. en")
+	}
+	for _, locale := range locales {
+		if !localePattern.MatchString(locale) {
+			return fmt.Errorf("%q is not a locale such as en or fr-CA", locale)
+		}
+	}
+	return nil
+}
+
+// Accepts "never", an interval of at least a minute or a cron expression
+func checkSchedule(answer string) error {
+	if answer == "never" {
+		return nil
+	}
+	if strings.Contains(answer, " ") {
+		_, err := sdscraper.ParseCron(answer)
+		return err
+	}
+	interval, err := time.ParseDuration(answer)
+	if err != nil || interval < time.Minute {
+		return errors.New("want an interval such as 6h or 24h of at least 1m, a cron expression, or never")
+	}
+	return nil
+}
+
+// Accepts nothing or an http(s) URL
+func checkWebhook(answer string) error {
+	if answer == "" {
+		return nil
+	}
+	parsed, err := url.Parse(answer)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return errors.New("want a URL starting with https://, or nothing")
+	}
+	return nil
+}
+
+// Accepts yes or no, or their first letter
+func checkYesNo(answer string) error {
+	switch strings.ToLower(answer) {
+	case "y", "yes", "n", "no":
+		return nil
+	}
+	return errors.New("answer yes or no")
+}
+
+// Applies settings to a fresh crawl command's flags, the check a crawl makes when it loads the file
+func checkSettings(settings map[string]any) error {
+	flags := flag.NewFlagSet("crawl", flag.ContinueOnError)
+	crawlCommand(flags)
+	return setFlags(flags, settings, nil)
+}
+
+// Encodes a config file in the format path's extension names
+func encodeConfig(path string, config any) ([]byte, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return yaml.Marshal(config)
+	case ".toml":
+		var buffer bytes.Buffer
+		err := toml.NewEncoder(&buffer).Encode(config)
+		return buffer.Bytes(), err
+	case ".json":
+		data, err := json.MarshalIndent(config, "", "  ")
+		return append(data, '\n'), err
+	}
+	return nil, fmt.Errorf("unknown format %q (want .yaml, .yml, .toml or .json)", filepath.Ext(path))
+}
+
+// Returns a systemd unit running this binary with the config file, in the config file's directory so relative
+// paths in it resolve as they do here
+func serviceUnit(configPath string) ([]byte, error) {
+	binary, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	config, err := filepath.Abs(configPath)
+	if err != nil {
+		return nil, err
+	}
+	return fmt.Appendf(nil, `[Unit]
+Description=GOJO SDS mirror
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+Type=simple
+WorkingDirectory=%s
+ExecStart=%q -config %q
+Restart=on-failure
+RestartSec=1min
+
+[Install]
+WantedBy=multi-user.target
+`, filepath.Dir(config), binary, config), nil
+}
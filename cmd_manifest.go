@@ -0,0 +1,63 @@
+package main // Declare main package
+
+import ( // Import required packages
+	"flag"     // For parsing manifest flags
+	"fmt"      // For printing differences
+	"log/slog" // For structured logging
+	"os"       // For exit codes
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // Manifest comparison
+)
+
+// Runs "manifest diff a.json b.json", which exits 0 when the manifests match, 1 when they differ and 2 on errors
+func runManifest(args []string) {
+	flags := flag.NewFlagSet("manifest", flag.ExitOnError) // Manifest flags
+	setupLogging := logFlags(flags)                        // -log-level and -log-format
+	jsonOutput := formatFlags(flags)                       // -format
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: manifest [flags] diff <old.json> <new.json>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args) // Exits on invalid flags
+	setupLogging()
+	asJSON := jsonOutput()
+
+	if flags.Arg(0) != "diff" || flags.NArg() != 3 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	var manifests [2]*sdscraper.Manifest
+	for i, path := range flags.Args()[1:] {
+		manifest, err := sdscraper.ReadManifest(path)
+		if err != nil {
+			slog.Error("Reading manifest failed", "err", err)
+			os.Exit(2) // Trouble, as with diff(1)
+		}
+		manifests[i] = manifest
+	}
+
+	diff := sdscraper.DiffManifests(manifests[0], manifests[1])
+	if asJSON {
+		printJSON(struct {
+			Schema string               `json:"schema"`
+			Build  *sdscraper.BuildInfo `json:"build"`
+			sdscraper.ManifestDiff
+		}{diffSchema, build(), diff})
+	} else {
+		for _, documentURL := range diff.Added {
+			fmt.Printf("+ %s\n", documentURL)
+		}
+		for _, documentURL := range diff.Removed {
+			fmt.Printf("- %s\n", documentURL)
+		}
+		for _, change := range diff.Changed {
+			fmt.Printf("~ %s\n", change.URL)
+			for _, field := range change.Fields {
+				fmt.Printf("    %s: %q -> %q\n", field.Field, field.Old, field.New)
+			}
+		}
+	}
+	if !diff.Empty() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,115 @@
+package main // Declare main package
+
+import ( // Import required packages
+	"context"        // For stopping between files
+	"errors"         // For matching cancellation
+	"flag"           // For parsing rekey flags
+	"fmt"            // For printing results
+	"os"             // For output and exit codes
+	"os/signal"      // For stopping on Ctrl-C
+	"strings"        // For splitting the key command
+	"syscall"        // For SIGTERM
+	"text/tabwriter" // For aligned tables
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // Re-encryption
+)
+
+// rekeyReport is the JSON form of a rekey run
+type rekeyReport struct {
+	Schema      string               `json:"schema"`
+	Build       *sdscraper.BuildInfo `json:"build"`
+	Decrypt     bool                 `json:"decrypt"`     // Written back as plaintext
+	Interrupted bool                 `json:"interrupted"` // Stopped early; run again to resume
+	*sdscraper.RekeyResult
+}
+
+// Runs "rekey", which re-encrypts the documents and manifest written under -encryption-key with -new-key, verifying
+// each file before it replaces the old one; rerunning with the same keys resumes an interrupted run
+func runRekey(args []string) {
+	flags := flag.NewFlagSet("rekey", flag.ExitOnError) // Rekey flags
+	setupLogging := logFlags(flags)                     // -log-level, -log-format and the current -encryption-key
+	jsonOutput := formatFlags(flags)                    // -format
+	manifestPath := flags.String("manifest", "manifest.json", "path of the manifest file")
+	outputDir := flags.String("output", "PDFs/", "directory holding downloaded documents")
+	storage := storageFlags(flags) // -storage s3 rekeys the bucket's documents instead
+	spoolDir := flags.String("spool-dir", "", "directory documents from -storage s3 are rekeyed in, one at a time (empty for the system temp directory)")
+	backupDir := flags.String("backup-dir", "backups/", "directory of manifest backups to rekey too (empty to leave them)")
+	trashDir := flags.String("trash", "trash/", "directory of trashed files to rekey too (empty to leave them)")
+	snapshotDir := flags.String("snapshots", "snapshots/", "directory of catalog snapshots to rekey too (empty to leave them)")
+	newKeyFile := flags.String("new-key", "", "file holding the new 32-byte key, as hex or base64")
+	newKeyCommand := flags.String("new-key-command", "", "command printing the new key instead, e.g. a KMS client")
+	decrypt := flags.Bool("decrypt", false, "write the archive back as plaintext instead of under a new key")
+	reportPath := flags.String("report", "", "also write the JSON report to this file")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: rekey -encryption-key OLD (-new-key NEW | -decrypt) [flags]")
+		fmt.Fprintln(flags.Output(), "Without -encryption-key a plaintext archive is encrypted for the first time. Stop serve and scheduled crawls first.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args) // Exits on invalid flags
+	setupLogging()
+	asJSON := jsonOutput()
+	if sdscraper.ReadOnly() {
+		fatal("rekey rewrites every stored file, which -read-only forbids")
+	}
+
+	var newKey []byte
+	switch hasNewKey := *newKeyFile != "" || *newKeyCommand != ""; {
+	case hasNewKey && *decrypt:
+		fatal("give either -new-key or -decrypt, not both")
+	case hasNewKey:
+		var err error
+		if newKey, err = sdscraper.LoadEncryptionKey(*newKeyFile, strings.Fields(*newKeyCommand)); err != nil {
+			fatal("Invalid -new-key", "err", err)
+		}
+	case !*decrypt:
+		fatal("give the key to re-encrypt under with -new-key or -new-key-command, or -decrypt")
+	case !sdscraper.EncryptionEnabled():
+		fatal("-decrypt needs the archive's current key in -encryption-key")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM) // Ctrl-C stops between files
+	defer stop()
+	result, err := sdscraper.Rekey(ctx, sdscraper.RekeyOptions{
+		OutputDir:    *outputDir,
+		Storage:      storage(),
+		SpoolDir:     *spoolDir,
+		ManifestPath: *manifestPath,
+		BackupDir:    *backupDir,
+		TrashDir:     *trashDir,
+		SnapshotDir:  *snapshotDir,
+		NewKey:       newKey,
+	})
+	interrupted := errors.Is(err, context.Canceled)
+	if err != nil && !interrupted {
+		fatal("Rekey failed", "err", err)
+	}
+
+	report := rekeyReport{Schema: rekeySchema, Build: build(), Decrypt: *decrypt, Interrupted: interrupted, RekeyResult: result}
+	if report.Problems == nil {
+		report.Problems = []sdscraper.Problem{}
+	}
+	if *reportPath != "" {
+		writeJSONReport(*reportPath, report)
+	}
+	if asJSON {
+		printJSON(report)
+	} else {
+		fmt.Printf("Rekeyed %d files (%d bytes), %d already done\n", result.Rekeyed, result.Bytes, result.Already)
+		if len(result.Problems) > 0 {
+			table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(table, "KIND\tFILE\tDETAIL\tFIX")
+			for _, problem := range result.Problems {
+				fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", problem.Kind, problem.Filename, problem.Detail, problem.Fix)
+			}
+			table.Flush()
+			fmt.Println("The manifest keeps the old key until these are fixed and rekey is run again")
+		}
+	}
+	switch {
+	case interrupted:
+		fmt.Fprintln(os.Stderr, "Interrupted; run the same command again to resume")
+		os.Exit(130)
+	case len(result.Problems) > 0:
+		os.Exit(1)
+	}
+}
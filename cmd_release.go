@@ -0,0 +1,179 @@
+package main // Declare main package
+
+import ( // Import required packages
+	"bytes"           // For the checksum list
+	"crypto/ed25519"  // For signing the checksums
+	"crypto/sha256"   // For checksums
+	"encoding/base64" // For keys and signatures
+	"flag"            // For parsing build-release flags
+	"fmt"             // For error messages and the summary
+	"log/slog"        // For structured logging
+	"os"              // For the dist directory and the environment
+	"os/exec"         // For running go build and git
+	"path/filepath"   // For artifact paths
+	"strconv"         // For SOURCE_DATE_EPOCH
+	"strings"         // For splitting targets
+	"text/tabwriter"  // For the summary table
+	"time"            // For the build date
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // Build information
+)
+
+// Platforms release builds are produced for, as GOOS/GOARCH
+var releaseTargets = []string{"linux/amd64", "linux/arm64", "windows/amd64", "darwin/arm64"}
+
+// Package the build stamps its version, commit and date into
+const buildInfoPackage = "github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper"
+
+// Runs "build-release", a developer command cross-compiling the release binaries from a checkout: pure-Go, CGO
+// disabled and paths trimmed so every platform's binary behaves the same, with SHA256SUMS and, given the release
+// key, the SHA256SUMS.sig self-update verifies
+func runBuildRelease(args []string) {
+	flags := flag.NewFlagSet("build-release", flag.ExitOnError) // Build-release flags
+	setupLogging := logFlags(flags)                             // -log-level and -log-format
+	jsonOutput := formatFlags(flags)                            // -format
+	dist := flags.String("dist", "dist", "directory the binaries and checksums are written to")
+	version := flags.String("version", "", "version stamped into the binaries (default: git describe, then dev)")
+	targets := flags.String("targets", strings.Join(releaseTargets, ","), "comma-separated GOOS/GOARCH platforms to build")
+	signingKey := flags.String("signing-key", os.Getenv("SDS_RELEASE_SIGNING_KEY"), "file holding the base64 Ed25519 private key or seed SHA256SUMS is signed with; its public key is built in for self-update ($SDS_RELEASE_SIGNING_KEY)")
+	goTool := flags.String("go", "go", "go command used to build")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: build-release [flags]\n\nRun from the repository root; builds one binary per target into -dist.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args) // Exits on invalid flags
+	setupLogging()
+
+	if _, err := os.Stat("go.mod"); err != nil {
+		fatal("build-release runs from the repository root, next to go.mod", "err", err)
+	}
+	var key ed25519.PrivateKey
+	if *signingKey != "" {
+		var err error
+		if key, err = readSigningKey(*signingKey); err != nil {
+			fatal("Reading the signing key failed", "path", *signingKey, "err", err)
+		}
+	}
+	if err := os.MkdirAll(*dist, 0o755); err != nil {
+		fatal("Creating the dist directory failed", "dir", *dist, "err", err)
+	}
+
+	report := releaseReport{Schema: releaseSchema, Build: build(), Version: *version, Artifacts: []releaseArtifact{}}
+	if report.Version == "" {
+		report.Version = gitOutput("describe", "--tags", "--always", "--dirty")
+	}
+	if report.Version == "" {
+		report.Version = "dev"
+	}
+	commit, date := gitOutput("rev-parse", "HEAD"), releaseDate()
+	ldflags := []string{"-s", "-w",
+		"-X " + buildInfoPackage + ".version=" + report.Version,
+		"-X " + buildInfoPackage + ".commit=" + commit,
+		"-X " + buildInfoPackage + ".buildDate=" + date,
+	}
+	if key != nil {
+		ldflags = append(ldflags, "-X main.updatePublicKey="+base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)))
+	}
+
+	var sums bytes.Buffer
+	for _, target := range strings.Split(*targets, ",") {
+		goos, goarch, ok := strings.Cut(strings.TrimSpace(target), "/")
+		if !ok || goos == "" || goarch == "" {
+			fatal("Invalid -targets entry; want GOOS/GOARCH", "target", target)
+		}
+		artifact := releaseArtifact{Name: releaseAsset(goos, goarch), GOOS: goos, GOARCH: goarch}
+		output := filepath.Join(*dist, artifact.Name)
+		command := exec.Command(*goTool, "build", "-trimpath", "-ldflags", strings.Join(ldflags, " "), "-o", output, ".")
+		command.Env = append(os.Environ(), "CGO_ENABLED=0", "GOOS="+goos, "GOARCH="+goarch) // No C toolchain needed, for any target
+		command.Stdout, command.Stderr = os.Stderr, os.Stderr
+		slog.Info("Building", "target", goos+"/"+goarch, "output", output)
+		if err := command.Run(); err != nil {
+			fatal("Building failed", "target", goos+"/"+goarch, "err", err)
+		}
+		binary, err := os.ReadFile(output)
+		if err != nil {
+			fatal("Reading the built binary failed", "path", output, "err", err)
+		}
+		artifact.Size, artifact.SHA256 = int64(len(binary)), fmt.Sprintf("%x", sha256.Sum256(binary))
+		fmt.Fprintf(&sums, "%s  %s\n", artifact.SHA256, artifact.Name) // As sha256sum writes it
+		report.Artifacts = append(report.Artifacts, artifact)
+	}
+	if err := os.WriteFile(filepath.Join(*dist, "SHA256SUMS"), sums.Bytes(), 0o644); err != nil {
+		fatal("Writing SHA256SUMS failed", "err", err)
+	}
+	if key != nil {
+		signature := base64.StdEncoding.EncodeToString(ed25519.Sign(key, sums.Bytes())) + "\n"
+		if err := os.WriteFile(filepath.Join(*dist, "SHA256SUMS.sig"), []byte(signature), 0o644); err != nil {
+			fatal("Writing SHA256SUMS.sig failed", "err", err)
+		}
+		report.Signed = true
+	} else {
+		slog.Warn("No -signing-key: SHA256SUMS is unsigned and the binaries carry no release key, so self-update refuses them")
+	}
+
+	if jsonOutput() {
+		printJSON(report)
+		return
+	}
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "ARTIFACT\tSIZE\tSHA256")
+	for _, artifact := range report.Artifacts {
+		fmt.Fprintf(table, "%s\t%d\t%s\n", artifact.Name, artifact.Size, artifact.SHA256)
+	}
+	table.Flush()
+	fmt.Printf("gojo %s in %s, signed: %t\n", report.Version, *dist, report.Signed)
+}
+
+// releaseReport is the JSON form of the build-release command
+type releaseReport struct {
+	Schema    string               `json:"schema"`
+	Build     *sdscraper.BuildInfo `json:"build"`   // The binary that ran the build
+	Version   string               `json:"version"` // Stamped into the artifacts
+	Artifacts []releaseArtifact    `json:"artifacts"`
+	Signed    bool                 `json:"signed"` // Whether SHA256SUMS.sig was written
+}
+
+// releaseArtifact is one built binary
+type releaseArtifact struct {
+	Name   string `json:"name"`
+	GOOS   string `json:"goos"`
+	GOARCH string `json:"goarch"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Reads a base64 Ed25519 private key, or the 32-byte seed it derives from
+func readSigningKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("not base64: %w", err)
+	case len(decoded) == ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(decoded), nil
+	case len(decoded) == ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(decoded), nil
+	}
+	return nil, fmt.Errorf("want a %d-byte seed or %d-byte private key, got %d bytes", ed25519.SeedSize, ed25519.PrivateKeySize, len(decoded))
+}
+
+// Returns the trimmed output of a git command, empty outside a checkout or without git
+func gitOutput(args ...string) string {
+	output, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// Returns the build date to stamp: SOURCE_DATE_EPOCH when set, so rebuilds of a commit are reproducible, else now
+func releaseDate() string {
+	date := time.Now().UTC()
+	if epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+		date = time.Unix(epoch, 0).UTC()
+	}
+	return date.Format(time.RFC3339)
+}
@@ -0,0 +1,57 @@
+package main // Declare main package
+
+import ( // Import required packages
+	"flag" // For parsing restore flags
+	"fmt"  // For printing backups
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // Backup policy
+)
+
+// Runs "restore [id|latest]", listing backups when no ID is given
+func runRestore(args []string) {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError) // Restore flags
+	setupLogging := logFlags(flags)                       // -log-level and -log-format
+	jsonOutput := formatFlags(flags)                      // -format
+	backupDir := flags.String("backup-dir", "backups/", "directory holding the backups")
+	manifestPath := flags.String("manifest", "manifest.json", "path of the manifest file")
+	checkpointPath := flags.String("checkpoint", "state.json", "progress file of interrupted runs, as given to crawl (empty to leave it)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: restore [flags] [backup-id | latest]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args) // Exits on invalid flags
+	setupLogging()
+	asJSON := jsonOutput()
+
+	policy := sdscraper.BackupPolicy{Dir: *backupDir}
+	state := (&sdscraper.Scraper{ManifestPath: *manifestPath, CheckpointPath: *checkpointPath}).StateFiles() // Same files the crawl backs up
+
+	if flags.NArg() == 0 {
+		ids, err := policy.ListBackups()
+		if err != nil {
+			fatal("Listing backups failed", "err", err)
+		}
+		if asJSON {
+			printJSON(backupsReport{Schema: backupsSchema, Build: build(), Backups: append([]string{}, ids...)})
+			return
+		}
+		for _, id := range ids {
+			fmt.Println(id)
+		}
+		return
+	}
+	if err := policy.Restore(flags.Arg(0), state); err != nil {
+		fatal("Restore failed", "err", err)
+	}
+	if asJSON {
+		printJSON(backupsReport{Schema: backupsSchema, Build: build(), Backups: []string{}, Restored: flags.Arg(0)})
+	}
+}
+
+// backupsReport is the JSON form of the restore command: the available backups, or the one restored
+type backupsReport struct {
+	Schema   string               `json:"schema"`
+	Build    *sdscraper.BuildInfo `json:"build"`
+	Backups  []string             `json:"backups"`            // Oldest first
+	Restored string               `json:"restored,omitempty"` // Backup ID or "latest" as given
+}
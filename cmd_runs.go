@@ -0,0 +1,115 @@
+package main // Declare main package
+
+import ( // Import required packages
+	"flag"           // For parsing runs flags
+	"fmt"            // For printing runs
+	"log/slog"       // For structured logging
+	"math"           // For whole counts
+	"os"             // For exit codes
+	"strconv"        // For formatting metrics
+	"text/tabwriter" // For aligned tables
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // Run history
+)
+
+// Runs "runs list" and "runs compare <id1> <id2>" over the run history kept in the manifest; compare exits 0
+// without regressions, 1 with some and 2 on errors
+func runRuns(args []string) {
+	flags := flag.NewFlagSet("runs", flag.ExitOnError) // Runs flags
+	setupLogging := logFlags(flags)                    // -log-level and -log-format
+	jsonOutput := formatFlags(flags)                   // -format
+	manifestPath := flags.String("manifest", "manifest.json", "path of the manifest file holding the run history")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: runs [flags] list\n       runs [flags] compare <id1> <id2>\n\nRun IDs are listed by \"runs list\"; latest and previous name the last two runs.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args) // Exits on invalid flags
+	setupLogging()
+	asJSON := jsonOutput()
+
+	switch {
+	case flags.Arg(0) == "list" && flags.NArg() == 1:
+	case flags.Arg(0) == "compare" && flags.NArg() == 3:
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+	manifest, err := sdscraper.ReadManifest(*manifestPath)
+	if err != nil {
+		slog.Error("Reading manifest failed", "err", err)
+		os.Exit(2)
+	}
+
+	if flags.Arg(0) == "list" {
+		if asJSON {
+			printJSON(runsReport{Schema: runsSchema, Build: build(), Runs: append([]sdscraper.RunStats{}, manifest.Runs...)}) // Empty, never null
+			return
+		}
+		table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(table, "ID\tVERSION\tELAPSED\tDISCOVERED\tDOWNLOADED\tFAILED\tBYTES\tREASON")
+		for _, run := range manifest.Runs {
+			fmt.Fprintf(table, "%s\t%s\t%.1fs\t%d\t%d\t%d\t%s\t%s\n", run.RunID(), run.Version, run.Elapsed, run.Discovered, run.Downloaded, run.Failed, formatBytes(run.Bytes), run.Reason)
+		}
+		table.Flush()
+		return
+	}
+
+	var runs [2]sdscraper.RunStats
+	for i, id := range flags.Args()[1:] {
+		run, ok := manifest.FindRun(id)
+		if !ok {
+			slog.Error("No such run in the history; see runs list", "id", id, "manifest", *manifestPath)
+			os.Exit(2)
+		}
+		runs[i] = run
+	}
+	comparison := sdscraper.CompareRuns(runs[0], runs[1])
+	if asJSON {
+		printJSON(struct {
+			Schema string               `json:"schema"`
+			Build  *sdscraper.BuildInfo `json:"build"`
+			sdscraper.RunComparison
+		}{runsCompareSchema, build(), comparison})
+	} else {
+		fmt.Printf("%s (%s) -> %s (%s)\n", runs[0].RunID(), runs[0].Version, runs[1].RunID(), runs[1].Version)
+		for _, run := range runs {
+			if run.Reason != "" {
+				fmt.Printf("%s started by hand: %s\n", run.RunID(), run.Reason)
+			}
+		}
+		fmt.Println()
+		table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(table, "METRIC\tBEFORE\tAFTER\tCHANGE\t")
+		for _, delta := range comparison.Deltas {
+			change := ""
+			if delta.Change != 0 {
+				change = fmt.Sprintf("%+.0f%%", delta.Change*100)
+			}
+			marker := ""
+			if delta.Regression {
+				marker = "REGRESSION"
+			}
+			fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", delta.Metric, formatMetric(delta.Before), formatMetric(delta.After), change, marker)
+		}
+		table.Flush()
+		fmt.Printf("\n%d regressions beyond %.0f%%\n", comparison.Regressions, sdscraper.RegressionThreshold*100)
+	}
+	if comparison.Regressions > 0 {
+		os.Exit(1)
+	}
+}
+
+// runsReport is the JSON form of "runs list"
+type runsReport struct {
+	Schema string               `json:"schema"`
+	Build  *sdscraper.BuildInfo `json:"build"`
+	Runs   []sdscraper.RunStats `json:"runs"` // Oldest first
+}
+
+// Formats a metric, counts in full and rates to four significant digits
+func formatMetric(value float64) string {
+	if value == math.Trunc(value) {
+		return strconv.FormatFloat(value, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(value, 'g', 4, 64)
+}
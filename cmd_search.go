@@ -0,0 +1,67 @@
+package main // Declare main package
+
+import ( // Import required packages
+	"context"        // For running the search
+	"flag"           // For parsing search flags
+	"fmt"            // For printing hits
+	"os"             // For output and exit codes
+	"strings"        // For joining the query
+	"text/tabwriter" // For aligned tables
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // Search index
+)
+
+// Runs "search <terms>", listing the PDF pages that mention every term; exits 1 when nothing matches
+func runSearch(args []string) {
+	flags := flag.NewFlagSet("search", flag.ExitOnError) // Search flags
+	setupLogging := logFlags(flags)                      // -log-level and -log-format
+	jsonOutput := formatFlags(flags)                     // -format
+	indexPath := flags.String("index", "search.db", "full-text index written by crawls with -search-index")
+	limit := flags.Int("limit", 20, "most pages listed")
+	raw := flags.Bool("raw", false, "pass the query to SQLite FTS5 as written, e.g. 'ethanol OR \"64-17-5\"'")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: search [flags] <terms>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args) // Exits on invalid flags
+	setupLogging()
+	asJSON := jsonOutput()
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if _, err := os.Stat(*indexPath); err != nil {
+		fatal("No search index; crawl with -search-index first", "path", *indexPath, "err", err)
+	}
+	index, err := sdscraper.OpenSearchIndex(*indexPath, true)
+	if err != nil {
+		fatal("Opening the search index failed", "path", *indexPath, "err", err)
+	}
+	defer index.Close()
+	hits, err := index.Search(context.Background(), strings.Join(flags.Args(), " "), *limit, *raw)
+	if err != nil {
+		fatal("Search failed", "err", err)
+	}
+
+	if asJSON {
+		printJSON(searchReport{Schema: searchSchema, Build: build(), Hits: append([]sdscraper.SearchHit{}, hits...)})
+	} else {
+		table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(table, "FILENAME\tPAGE\tSNIPPET")
+		for _, hit := range hits {
+			fmt.Fprintf(table, "%s\t%d\t%s\n", hit.Filename, hit.Page, hit.Snippet)
+		}
+		table.Flush()
+	}
+	if len(hits) == 0 {
+		os.Exit(1) // Like grep, so scripts can test for a mention
+	}
+}
+
+// searchReport is the JSON form of the search command
+type searchReport struct {
+	Schema string                `json:"schema"`
+	Build  *sdscraper.BuildInfo  `json:"build"`
+	Hits   []sdscraper.SearchHit `json:"hits"` // Best match first
+}
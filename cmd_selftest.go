@@ -0,0 +1,166 @@
+package main // Declare main package
+
+import ( // Import required packages
+	"context"       // For the run's timeout
+	"flag"          // For parsing selftest flags
+	"fmt"           // For check details
+	"maps"          // For the failed URLs
+	"os"            // For the work directory and exit codes
+	"path/filepath" // For state file paths
+	"regexp"        // For the deep crawl allowlist
+	"slices"        // For comparing URL sets
+	"strings"       // For listing differences
+	"time"          // For the timeout
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper"         // The pipeline under test
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper/sdstest" // The mock site
+)
+
+// selftestReport is the JSON form of the selftest command
+type selftestReport struct {
+	Schema string               `json:"schema"`
+	Build  *sdscraper.BuildInfo `json:"build"`
+	Dir    string               `json:"dir,omitempty"` // Work directory, when kept
+	Checks []doctorCheck        `json:"checks"`
+}
+
+// Runs "selftest", crawling a bundled mock site with a paginated listing, valid, duplicate, broken and throttled
+// documents and a synthetic corpus through the whole pipeline, then checking what it made of them; it needs
+// neither the network nor Chrome, so it shows an installation works before it is pointed at the real site
+func runSelftest(args []string) {
+	flags := flag.NewFlagSet("selftest", flag.ExitOnError) // Selftest flags
+	setupLogging := logFlags(flags)                        // -log-level and -log-format
+	jsonOutput := formatFlags(flags)                       // -format
+	dir := flags.String("dir", "", "work directory for the mirror (default a temporary one)")
+	keep := flags.Bool("keep", false, "keep the work directory afterwards for inspection")
+	structural := flags.Bool("structural", true, "validate documents with pdfcpu, as -structural-check does")
+	perPage := flags.Int("per-page", 4, "listing rows per mock page")
+	timeout := flags.Duration("timeout", 2*time.Minute, "upper bound for the whole test")
+	flags.Parse(args) // Exits on invalid flags
+	setupLogging()
+	asJSON := jsonOutput()
+	if flags.NArg() > 0 {
+		fmt.Fprintln(flags.Output(), "usage: selftest [flags]")
+		os.Exit(2)
+	}
+
+	if sdscraper.ReadOnly() {
+		fatal("selftest crawls into a work directory, which -read-only forbids")
+	}
+	workDir := *dir
+	if workDir == "" {
+		temp, err := os.MkdirTemp("", "gojo-selftest-*")
+		if err != nil {
+			fatal("Creating the work directory failed", "err", err)
+		}
+		workDir = temp
+	}
+	if !*keep {
+		defer os.RemoveAll(workDir)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	checks := selftest(ctx, workDir, *perPage, *structural)
+	cancel()
+
+	report := selftestReport{Schema: selftestSchema, Build: build(), Checks: checks}
+	if *keep {
+		report.Dir = workDir
+	}
+	if asJSON {
+		printJSON(report)
+	} else {
+		printDoctor(os.Stdout, checks)
+		if *keep {
+			fmt.Println("Work directory:", workDir)
+		}
+	}
+	for _, check := range checks {
+		if check.Status == checkFail {
+			if !*keep {
+				os.RemoveAll(workDir) // os.Exit skips the deferred removal
+			}
+			os.Exit(1)
+		}
+	}
+}
+
+// Crawls the mock site twice into dir and checks both runs, stopping at the first run that errors
+func selftest(ctx context.Context, dir string, perPage int, structural bool) []doctorCheck {
+	corpus := sdstest.Corpus(sdstest.CorpusOptions{Seed: 1, Documents: 3, LargeSizes: []int64{256 << 10}})
+	site := sdstest.NewSiteWith(sdstest.SiteOptions{PerPage: perPage, Corpus: corpus})
+	defer site.Close()
+	expected := site.Expect(structural)
+
+	scraper := func() *sdscraper.Scraper {
+		s := site.Scraper(dir)
+		s.DeepCrawl = sdscraper.DeepCrawl{Depth: site.Pages() - 1, Allow: []*regexp.Regexp{sdstest.PageLinks}}
+		s.Downloader.StructuralCheck = structural
+		return s
+	}
+	first, err := scraper().Run(ctx)
+	if err != nil {
+		return []doctorCheck{{"first run", checkFail, err.Error()}}
+	}
+	listed := len(expected.Downloaded) + len(expected.Aliased) + len(expected.Failed)
+	checks := []doctorCheck{
+		countCheck("listing", len(first.Discovered), listed, fmt.Sprintf("%d documents over %d pages", listed, site.Pages())),
+		setCheck("downloaded", first.Downloaded, expected.Downloaded),
+		setCheck("aliased", first.Aliased, expected.Aliased),
+		setCheck("rejected", slices.Collect(maps.Keys(first.Failed)), expected.Failed),
+	}
+
+	second, err := scraper().Run(ctx)
+	if err != nil {
+		return append(checks, doctorCheck{"second run", checkFail, err.Error()})
+	}
+	checks = append(checks,
+		setCheck("retried", second.Downloaded, expected.Retried),
+		countCheck("revalidated", len(second.NotModified), len(expected.Downloaded)+len(expected.Aliased), "unchanged documents answered 304"),
+		fsckCheck(second.Manifest, dir),
+	)
+	return checks
+}
+
+// Compares a count with the expected one
+func countCheck(name string, got, want int, detail string) doctorCheck {
+	if got != want {
+		return doctorCheck{name, checkFail, fmt.Sprintf("%d, expected %d", got, want)}
+	}
+	return doctorCheck{name, checkOK, detail}
+}
+
+// Compares URL sets, naming what is missing or unexpected
+func setCheck(name string, got, want []string) doctorCheck {
+	var missing, unexpected []string
+	for _, documentURL := range want {
+		if !slices.Contains(got, documentURL) {
+			missing = append(missing, filepath.Base(documentURL))
+		}
+	}
+	for _, documentURL := range got {
+		if !slices.Contains(want, documentURL) {
+			unexpected = append(unexpected, filepath.Base(documentURL))
+		}
+	}
+	var problems []string
+	if len(missing) > 0 {
+		problems = append(problems, "missing "+strings.Join(missing, ", "))
+	}
+	if len(unexpected) > 0 {
+		problems = append(problems, "unexpected "+strings.Join(unexpected, ", "))
+	}
+	if len(problems) > 0 {
+		return doctorCheck{name, checkFail, strings.Join(problems, "; ")}
+	}
+	return doctorCheck{name, checkOK, fmt.Sprintf("%d documents as expected", len(want))}
+}
+
+// Cross-checks the saved manifest with the files on disk, hashes included
+func fsckCheck(manifest *sdscraper.Manifest, dir string) doctorCheck {
+	problems := sdscraper.Fsck(manifest, sdscraper.FsckOptions{OutputDir: filepath.Join(dir, "PDFs"), VerifyHashes: true})
+	if len(problems) > 0 {
+		return doctorCheck{"fsck", checkFail, fmt.Sprintf("%d problems, the first: %s", len(problems), problems[0].Detail)}
+	}
+	return doctorCheck{"fsck", checkOK, fmt.Sprintf("%d documents consistent with their files", len(manifest.Documents))}
+}
@@ -0,0 +1,303 @@
+package main // Declare main package
+
+import ( // Import required packages
+	"bufio"           // For reading checksum lists
+	"bytes"           // For buffering downloads
+	"cmp"             // For the key fallback
+	"crypto/ed25519"  // For verifying release signatures
+	"crypto/sha256"   // For checksums
+	"encoding/base64" // For keys and signatures
+	"encoding/hex"    // For checksum lists
+	"errors"          // For the no-key error
+	"flag"            // For parsing self-update flags
+	"fmt"             // For error messages
+	"io"              // For reading responses
+	"log/slog"        // For structured logging
+	"net/http"        // For fetching releases
+	"net/url"         // For resolving asset URLs
+	"os"              // For replacing the executable
+	"path/filepath"   // For the executable's directory
+	"runtime"         // For picking this platform's binary
+	"strconv"         // For version numbers
+	"strings"         // For parsing checksum lines
+	"time"            // For the download timeout
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // For read-only mode
+)
+
+// Base64 Ed25519 key release checksums are signed with, set at build time via -ldflags "-X main.updatePublicKey=..."
+var updatePublicKey = ""
+
+// Where releases are published: SHA256SUMS, its signature SHA256SUMS.sig, a VERSION file listed in SHA256SUMS and
+// one binary per platform
+const defaultReleaseURL = "https://github.com/Tech-Trailblazers/gojo-com-documentation/releases/latest/download/"
+
+// Runs "self-update", replacing the running binary with the latest signed release; with -check it only reports,
+// exiting 1 when an update is available
+func runSelfUpdate(args []string) {
+	flags := flag.NewFlagSet("self-update", flag.ExitOnError) // Self-update flags
+	setupLogging := logFlags(flags)                           // -log-level and -log-format
+	releaseURL := flags.String("release-url", defaultReleaseURL, "directory URL holding SHA256SUMS, SHA256SUMS.sig and the per-platform binaries")
+	publicKey := flags.String("public-key", cmp.Or(os.Getenv("SDS_UPDATE_PUBLIC_KEY"), updatePublicKey), "base64 Ed25519 key the checksums must be signed with (default $SDS_UPDATE_PUBLIC_KEY, then the built-in key)")
+	check := flags.Bool("check", false, "only report whether an update is available; exit 1 if one is")
+	allowDowngrade := flags.Bool("allow-downgrade", false, "install the release even when it is older than the running build, or carries no version")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: self-update [flags]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args) // Exits on invalid flags
+	setupLogging()
+
+	key, err := parsePublicKey(*publicKey)
+	if err != nil {
+		fatal("Refusing to update", "err", err)
+	}
+	executable, err := os.Executable()
+	if err == nil {
+		executable, err = filepath.EvalSymlinks(executable) // Replace the file, not a link to it
+	}
+	if err != nil {
+		fatal("Locating the running binary failed", "err", err)
+	}
+
+	client := &http.Client{Timeout: 5 * time.Minute} // Binaries are tens of megabytes
+	base, err := url.Parse(*releaseURL)
+	if err != nil {
+		fatal("Invalid -release-url", "err", err)
+	}
+	sums, err := fetchRelease(client, base, "SHA256SUMS")
+	if err != nil {
+		fatal("Fetching checksums failed", "err", err)
+	}
+	signature, err := fetchRelease(client, base, "SHA256SUMS.sig")
+	if err != nil {
+		fatal("Fetching the checksum signature failed", "err", err)
+	}
+	if !ed25519.Verify(key, sums, decodeSignature(signature)) {
+		fatal("Refusing to update: SHA256SUMS is not signed by the release key")
+	}
+
+	asset := releaseAsset(runtime.GOOS, runtime.GOARCH)
+	want, ok := checksumFor(sums, asset)
+	if !ok {
+		fatal("The release has no binary for this platform", "asset", asset)
+	}
+	if current, err := fileSHA256(executable); err == nil && current == want {
+		slog.Info("Already up to date", "binary", executable)
+		return
+	}
+	running := sdscraper.Build().Version
+	release, err := releaseVersion(client, base, sums)
+	if err == nil && compareVersions(release, running) < 0 {
+		err = fmt.Errorf("release %s is older than the running %s", release, running)
+	}
+	if err != nil && !*allowDowngrade { // A replayed old release must not roll back fixes
+		if *check {
+			slog.Info("No update available", "reason", err)
+			return
+		}
+		fatal("Refusing to update; pass -allow-downgrade to install it anyway", "err", err)
+	}
+	if *check {
+		slog.Info("Update available", "asset", asset, "version", release, "running", running)
+		os.Exit(1)
+	}
+
+	binary, err := fetchRelease(client, base, asset)
+	if err != nil {
+		fatal("Downloading the release failed", "asset", asset, "err", err)
+	}
+	if got := fmt.Sprintf("%x", sha256.Sum256(binary)); got != want {
+		fatal("Refusing to update: the downloaded binary does not match its signed checksum", "asset", asset, "sha256", got)
+	}
+	if err := replaceExecutable(executable, binary); err != nil {
+		fatal("Replacing the binary failed", "binary", executable, "err", err)
+	}
+	slog.Info("Updated; the next start runs the new release", "binary", executable, "version", release, "sha256", want)
+}
+
+// Returns the release's version from its VERSION file, trusted only when it matches its signed checksum
+func releaseVersion(client *http.Client, base *url.URL, sums []byte) (string, error) {
+	want, ok := checksumFor(sums, "VERSION")
+	if !ok {
+		return "", errors.New("the release lists no signed VERSION")
+	}
+	data, err := fetchRelease(client, base, "VERSION")
+	if err != nil {
+		return "", err
+	}
+	if got := fmt.Sprintf("%x", sha256.Sum256(data)); got != want {
+		return "", errors.New("the release's VERSION does not match its signed checksum")
+	}
+	release := strings.TrimSpace(string(data))
+	if _, ok := parseVersion(release); !ok {
+		return "", fmt.Errorf("the release's VERSION %q is not of the form v1.2.3", release)
+	}
+	return release, nil
+}
+
+// Orders two versions of the form v1.2.3[-pre], a pre-release before its release; builds without such a version,
+// e.g. "dev", come before every release
+func compareVersions(a, b string) int {
+	parsedA, okA := parseVersion(a)
+	parsedB, okB := parseVersion(b)
+	if !okA || !okB {
+		switch {
+		case okA == okB:
+			return 0
+		case okA:
+			return 1
+		}
+		return -1
+	}
+	for i := range 3 {
+		if c := cmp.Compare(parsedA.numbers[i], parsedB.numbers[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case parsedA.pre == parsedB.pre:
+		return 0
+	case parsedA.pre == "":
+		return 1
+	case parsedB.pre == "":
+		return -1
+	}
+	return cmp.Compare(parsedA.pre, parsedB.pre)
+}
+
+// A release version split into its numbers and pre-release suffix
+type releaseNumber struct {
+	numbers [3]int
+	pre     string
+}
+
+// Parses v1.2.3 or v1.2.3-rc1, the leading v optional
+func parseVersion(version string) (releaseNumber, bool) {
+	var parsed releaseNumber
+	version, _, _ = strings.Cut(version, "+") // Build metadata does not order
+	core, pre, _ := strings.Cut(strings.TrimPrefix(version, "v"), "-")
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return parsed, false
+	}
+	for i, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return parsed, false
+		}
+		parsed.numbers[i] = number
+	}
+	parsed.pre = pre
+	return parsed, true
+}
+
+// Decodes a base64 Ed25519 public key
+func parsePublicKey(encoded string) (ed25519.PublicKey, error) {
+	if encoded == "" {
+		return nil, errors.New("no release key: this build carries none, so pass -public-key or set SDS_UPDATE_PUBLIC_KEY")
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid release key: want %d base64-encoded bytes", ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// Accepts a signature as raw bytes or base64 text, as signing tools produce either
+func decodeSignature(data []byte) []byte {
+	if len(data) == ed25519.SignatureSize {
+		return data
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil // Fails verification
+	}
+	return decoded
+}
+
+// Names the release binary for a platform, e.g. gojo-linux-amd64 or gojo-windows-amd64.exe
+func releaseAsset(goos, goarch string) string {
+	name := "gojo-" + goos + "-" + goarch
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// Finds asset in a sha256sum-style list ("<hex>  <name>", binary-mode "*name" too)
+func checksumFor(sums []byte, asset string) (string, bool) {
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || strings.TrimPrefix(fields[1], "*") != asset {
+			continue
+		}
+		if _, err := hex.DecodeString(fields[0]); err == nil && len(fields[0]) == sha256.Size*2 {
+			return strings.ToLower(fields[0]), true
+		}
+	}
+	return "", false
+}
+
+// Downloads one file of the release
+func fetchRelease(client *http.Client, base *url.URL, name string) ([]byte, error) {
+	resp, err := client.Get(base.ResolveReference(&url.URL{Path: name}).String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", name, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// Returns the hex SHA-256 of a file
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// Swaps in the new binary with a rename inside the executable's directory, so a crash leaves either the old or the new one
+func replaceExecutable(executable string, binary []byte) error {
+	info, err := os.Stat(executable)
+	if err != nil {
+		return err
+	}
+	if err := sdscraper.CheckWritable("replace", executable); err != nil {
+		return err
+	}
+	next := executable + ".new"
+	if err := os.WriteFile(next, binary, info.Mode().Perm()|0o100); err != nil { // Same directory, so the rename stays atomic
+		return err
+	}
+	if runtime.GOOS != "windows" {
+		if err := os.Rename(next, executable); err != nil { // Running processes keep the old inode
+			os.Remove(next)
+			return err
+		}
+		return nil
+	}
+	previous := executable + ".old" // Windows can rename a running binary but not replace it
+	os.Remove(previous)             // Left over by an earlier update
+	if err := os.Rename(executable, previous); err != nil {
+		os.Remove(next)
+		return err
+	}
+	if err := os.Rename(next, executable); err != nil {
+		os.Rename(previous, executable) // Put the working binary back
+		os.Remove(next)
+		return err
+	}
+	os.Remove(previous) // Fails while the old binary still runs; the next update retries
+	return nil
+}
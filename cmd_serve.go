@@ -0,0 +1,194 @@
+package main // Declare main package
+
+import ( // Import required packages
+	"context"   // For shutdown deadlines
+	"errors"    // For matching server errors
+	"flag"      // For parsing serve-mode flags
+	"log/slog"  // For structured logging
+	"net/http"  // For the serve-mode HTTP server
+	"net/netip" // For network allowlists
+	"os"        // For environment variables
+	"os/signal" // For graceful shutdown
+	"strings"   // For splitting prefix lists
+	"syscall"   // For SIGTERM
+	"time"      // For the shutdown timeout
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // Scraping, downloading and serving
+)
+
+// Parses serve flags and runs the HTTP server until it fails
+func runServe(args []string) {
+	serveCommand(flag.NewFlagSet("serve", flag.ExitOnError))(args)
+}
+
+// Registers the serve command's flags on flags and returns the command, which parses args and serves
+func serveCommand(flags *flag.FlagSet) func(args []string) {
+	applyConfig := configFlags(flags)                                                 // -config and -profile
+	setupLogging := logFlags(flags)                                                   // -log-level and -log-format
+	addr := flags.String("addr", ":8080", "address to listen on")                     // Listen address
+	outputDir := flags.String("output", "PDFs/", "directory holding downloaded PDFs") // Mirror directory
+	manifestPath := flags.String("manifest", "manifest.json", "path of the manifest file")
+	cacheMaxBytes := flags.Int64("cache-max-bytes", 0, "evict least-recently-used documents beyond this many bytes (0 = unlimited)")
+	deleteRetention := flags.Duration("delete-retention", sdscraper.DefaultDeleteRetention, "how long soft-deleted documents stay restorable before they are purged")
+	syncEvery := flags.Duration("sync-every", 0, "crawl in the background at this interval, starting at launch (0 disables)")
+	serveTags := flags.String("serve-tags", "", "only list and serve documents with these comma-separated tags; -tag excludes one")
+	sharesPath := flags.String("shares", "shares.json", "file keeping expiring share links, their access logs and signing secret (empty disables share links)")
+	shareMaxTTL := flags.Duration("share-max-ttl", sdscraper.DefaultShareMaxTTL, "longest lifetime an admin may give a share link")
+	public := flags.Bool("public", false, "serve beyond the LAN: apply the public defaults to the -client-* and -max-* limits not set explicitly")
+	clientRate := flags.String("client-rate", "", "requests each client address may make, e.g. 10/s or 600/m (empty for unlimited; -public: 10/s)")
+	clientBurst := flags.Int("client-burst", 0, "requests a client may make back to back before -client-rate applies (-public: 30)")
+	clientConcurrency := flags.Int("client-concurrency", 0, "requests a client may have in flight, 0 for unlimited (-public: 6)")
+	maxConcurrency := flags.Int("max-concurrency", 0, "requests in flight over all clients before new ones get 503, 0 for unlimited (-public: 128)")
+	maxBody := flags.String("max-body", "", "largest request body accepted from non-admin clients, e.g. 1MiB (empty for the endpoints' own limits; -public: 1MiB)")
+	allow := flags.String("allow", "", "comma-separated CIDR prefixes requests may come from, e.g. 10.20.0.0/16 for the plant networks (empty for anywhere)")
+	shareAnywhere := flags.Bool("share-anywhere", false, "let share links through from outside -allow, e.g. for inspectors on mobile data")
+	trustedProxies := flags.String("trusted-proxies", "", "comma-separated CIDR prefixes of reverse proxies whose X-Forwarded-For is believed for -allow and the client limits")
+	coverPage := flags.Bool("cover-page", false, "serve PDFs behind a generated cover page giving the source URL, SHA-256, retrieval date and an uncontrolled-copy notice; stored files are unchanged")
+	snapshotDir := flags.String("snapshots", "snapshots/", "directory of catalog snapshots served under /snapshots, which admins may add to (empty disables them)")
+	syncOverlap := flags.String("sync-overlap", "queue-one", "when a sync is due while the previous one still runs: skip, queue-one or abort-previous")
+	crawl := registerCrawlFlags(flags) // Configure the background sync
+	return func(args []string) {
+		flags.Parse(args) // Exits on invalid flags
+		applyConfig()
+		setupLogging()
+
+		if sdscraper.ReadOnly() {
+			fatal("serve caches documents and keeps state files, which -read-only forbids; use catalog, search or verify to inspect the archive")
+		}
+		policy, err := sdscraper.ParseOverlapPolicy(*syncOverlap)
+		if err != nil {
+			fatal("Invalid -sync-overlap", "err", err)
+		}
+		limits := publicLimits(flags, *public, *clientRate, *clientBurst, *clientConcurrency, *maxConcurrency, *maxBody)
+		allowed := prefixesFlag("-allow", *allow)
+		if *shareAnywhere && allowed == nil {
+			fatal("-share-anywhere needs -allow")
+		}
+		scraper := crawl.scraper()
+		scraper.ManifestPath = *manifestPath
+		scraper.DeleteRetention = *deleteRetention
+		if *syncEvery > 0 {
+			scraper.Pause = &sdscraper.PauseControl{} // Held by /sync/pause, SIGUSR1 and SIGUSR2
+		}
+		if scraper.Downloader.Storage != nil {
+			fatal("serve keeps its cache on local disk; -storage s3 is only supported by the crawl command")
+		}
+		downloader := scraper.Downloader // On-demand fetches share the sync's client and download slots
+		downloader.OutputDir = *outputDir
+		downloader.Scheduler = sdscraper.NewScheduler(max(*crawl.workers, 1)) // User requests overtake queued sync downloads
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM) // Ctrl-C stops cleanly
+		defer stop()
+
+		var queue *sdscraper.RunQueue
+		var runNow sdscraper.RunTrigger // POST /sync/run
+		if *syncEvery > 0 {
+			queue = syncQueue(ctx, scraper, policy, false)
+			runNow = func(reason string) bool { return queue.TriggerWithReason(ctx, reason) }
+		}
+		server, err := sdscraper.NewServer(downloader, sdscraper.ServerOptions{
+			ManifestPath:    *manifestPath,
+			CacheMaxBytes:   *cacheMaxBytes,
+			AdminToken:      os.Getenv("SDS_ADMIN_TOKEN"), // Kept out of the process list
+			DeleteRetention: *deleteRetention,
+			Tags:            tagFilter("-serve-tags", *serveTags),
+			SharesPath:      *sharesPath,
+			ShareSecret:     []byte(os.Getenv("SDS_SHARE_SECRET")), // Lets several replicas honour the same links
+			ShareMaxTTL:     *shareMaxTTL,
+			Limits:          limits,
+			Allow:           allowed,
+			ShareAnywhere:   *shareAnywhere,
+			TrustedProxies:  prefixesFlag("-trusted-proxies", *trustedProxies),
+			Pause:           scraper.Pause,
+			RunNow:          runNow,
+			CoverPages:      *coverPage,
+			SnapshotDir:     *snapshotDir,
+		})
+		if err != nil {
+			fatal("Starting the server failed", "err", err)
+		}
+
+		pauseOnSignals(ctx, scraper.Pause)
+		if *crawl.metricsAddr != "" { // On its own port so it can stay private while the mirror is public
+			serveMetrics(ctx, *crawl.metricsAddr, downloader.Metrics)
+		}
+
+		syncDone := make(chan struct{}) // Closed once the background sync has stopped
+		if *syncEvery > 0 {
+			go func() {
+				defer close(syncDone)
+				watch(ctx, queue, every(*syncEvery))
+			}()
+		} else {
+			close(syncDone)
+		}
+
+		httpServer := &http.Server{
+			Addr:              *addr,
+			Handler:           server.Handler(),
+			ReadHeaderTimeout: 10 * time.Second, // Slow clients cannot hold connections open for free
+			MaxHeaderBytes:    64 << 10,
+		}
+		go func() {
+			<-ctx.Done() // Let in-flight downloads finish before exiting
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := httpServer.Shutdown(shutdownCtx); err != nil {
+				slog.Error("Shutdown incomplete", "err", err)
+			}
+		}()
+
+		slog.Info("Serving", "dir", *outputDir, "addr", *addr)
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) { // Block until the server stops
+			fatal("Server failed", "err", err)
+		}
+		<-syncDone     // An interrupted sync saves its checkpoint first
+		server.Close() // Access times still held in memory
+		slog.Info("Server stopped")
+	}
+}
+
+// Returns the serve limits from their flags, with -public filling the ones not set explicitly
+func publicLimits(flags *flag.FlagSet, public bool, rate string, burst, clientConcurrency, maxConcurrency int, maxBody string) sdscraper.PublicLimits {
+	if public {
+		explicit := explicitFlags(flags)
+		if !explicit["client-rate"] {
+			rate = "10/s"
+		}
+		if !explicit["client-burst"] {
+			burst = 30
+		}
+		if !explicit["client-concurrency"] {
+			clientConcurrency = 6
+		}
+		if !explicit["max-concurrency"] {
+			maxConcurrency = 128
+		}
+		if !explicit["max-body"] {
+			maxBody = "1MiB"
+		}
+	}
+	perSecond, err := sdscraper.ParseRate(rate)
+	if err != nil {
+		fatal("Invalid -client-rate", "err", err)
+	}
+	var bodyBytes int64
+	if maxBody != "" {
+		if bodyBytes, err = sdscraper.ParseByteSize(maxBody); err != nil {
+			fatal("Invalid -max-body", "err", err)
+		}
+	}
+	return sdscraper.PublicLimits{PerClientRate: perSecond, PerClientBurst: burst, PerClientConcurrent: clientConcurrency, MaxConcurrent: maxConcurrency, MaxBodyBytes: bodyBytes}
+}
+
+// Parses a comma-separated list of CIDR prefixes flag, nil when empty
+func prefixesFlag(name, value string) []netip.Prefix {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	prefixes, err := sdscraper.ParsePrefixes(strings.Split(value, ","))
+	if err != nil {
+		fatal("Invalid "+name, "err", err)
+	}
+	return prefixes
+}
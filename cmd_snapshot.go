@@ -0,0 +1,145 @@
+package main // Declare main package
+
+import ( // Import required packages
+	"errors"         // For matching snapshot errors
+	"flag"           // For parsing snapshot flags
+	"fmt"            // For printing snapshots
+	"os"             // For exit codes
+	"slices"         // For sorting the catalog
+	"strings"        // For comparing file names
+	"text/tabwriter" // For aligned tables
+	"time"           // For creation times
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // Catalog snapshots
+)
+
+// snapshotReport is the JSON form of "snapshot create", "show" and "delete"
+type snapshotReport struct {
+	Schema   string                     `json:"schema"`
+	Build    *sdscraper.BuildInfo       `json:"build"`
+	Action   string                     `json:"action"`
+	Snapshot *sdscraper.Snapshot        `json:"snapshot"`
+	Catalog  []*sdscraper.ManifestEntry `json:"catalog,omitempty"` // Show only, sorted by file name
+}
+
+// snapshotsReport is the JSON form of "snapshot list"
+type snapshotsReport struct {
+	Schema    string                `json:"schema"`
+	Build     *sdscraper.BuildInfo  `json:"build"`
+	Snapshots []*sdscraper.Snapshot `json:"snapshots"` // Oldest first
+}
+
+// Runs "snapshot create <name> | list | show <name> | delete <name>", which keeps named, immutable copies of the
+// catalog, e.g. "2024-Q4 audit", that export -snapshot, catalog print-index -snapshot and share links refer to later
+func runSnapshot(args []string) {
+	flags := flag.NewFlagSet("snapshot", flag.ExitOnError) // Snapshot flags
+	setupLogging := logFlags(flags)                        // -log-level and -log-format
+	jsonOutput := formatFlags(flags)                       // -format
+	outputDir := flags.String("output", "PDFs/", "directory holding the downloaded documents")
+	manifestPath := flags.String("manifest", "manifest.json", "path of the manifest file")
+	snapshotDir := flags.String("snapshots", "snapshots/", "directory the snapshots are kept in, one folder each")
+	note := flags.String("note", "", "what the snapshot is for, e.g. \"insurer audit\" (create only)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: snapshot [flags] create <name> | list | show <name> | delete <name>\n\nA snapshot freezes the catalog and its files as they are now; the live archive keeps changing.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args) // Exits on invalid flags
+	setupLogging()
+	asJSON := jsonOutput()
+
+	action := flags.Arg(0)
+	switch {
+	case action == "list" && flags.NArg() == 1:
+	case (action == "create" || action == "show" || action == "delete") && flags.NArg() == 2:
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+	if sdscraper.ReadOnly() && (action == "create" || action == "delete") {
+		fatal("snapshot " + action + " writes the snapshot directory, which -read-only forbids")
+	}
+	store := sdscraper.SnapshotStore{Dir: *snapshotDir}
+
+	switch action {
+	case "list":
+		snapshots, err := store.List()
+		if err != nil {
+			fatal("Listing snapshots failed", "dir", *snapshotDir, "err", err)
+		}
+		if asJSON {
+			printJSON(snapshotsReport{Schema: snapshotSchema, Build: build(), Snapshots: append([]*sdscraper.Snapshot{}, snapshots...)}) // Empty, never null
+			return
+		}
+		table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(table, "ID\tNAME\tCREATED\tDOCUMENTS\tSIZE\tNOTE")
+		for _, snapshot := range snapshots {
+			fmt.Fprintf(table, "%s\t%s\t%s\t%d\t%s\t%s\n", snapshot.ID, snapshot.Name, snapshot.CreatedAt.Format(time.RFC3339), snapshot.Documents, formatBytes(snapshot.Bytes), snapshot.Note)
+		}
+		table.Flush()
+	case "create":
+		manifest, err := sdscraper.ReadManifest(*manifestPath)
+		if err != nil {
+			fatal("Reading manifest failed", "err", err)
+		}
+		snapshot, err := store.Create(manifest, *outputDir, flags.Arg(1), *note)
+		if errors.Is(err, sdscraper.ErrSnapshotExists) {
+			fatal("A snapshot of that name exists; snapshots are never replaced, so pick another name or delete it first", "err", err)
+		}
+		if err != nil {
+			fatal("Creating the snapshot failed", "err", err)
+		}
+		if asJSON {
+			printJSON(snapshotReport{Schema: snapshotSchema, Build: build(), Action: action, Snapshot: snapshot})
+			return
+		}
+		fmt.Printf("Created snapshot %s (%q): %d documents, %s\n", snapshot.ID, snapshot.Name, snapshot.Documents, formatBytes(snapshot.Bytes))
+		for _, name := range snapshot.Missing {
+			fmt.Printf("  missing %s: not on disk, so the snapshot lists it without its file\n", name)
+		}
+	case "show":
+		snapshot, err := store.Open(flags.Arg(1))
+		if err != nil {
+			fatal("Opening the snapshot failed", "err", err)
+		}
+		manifest, err := snapshot.Manifest()
+		if err != nil {
+			fatal("Reading the snapshot's catalog failed", "err", err)
+		}
+		catalog := []*sdscraper.ManifestEntry{}
+		for documentURL, entry := range manifest.Documents {
+			entry.URL = documentURL
+			catalog = append(catalog, entry)
+		}
+		slices.SortFunc(catalog, func(a, b *sdscraper.ManifestEntry) int {
+			return strings.Compare(a.Filename+"\n"+a.URL, b.Filename+"\n"+b.URL)
+		})
+		if asJSON {
+			printJSON(snapshotReport{Schema: snapshotSchema, Build: build(), Action: action, Snapshot: snapshot, Catalog: catalog})
+			return
+		}
+		fmt.Printf("%s (%q), created %s", snapshot.ID, snapshot.Name, snapshot.CreatedAt.Format(time.RFC3339))
+		if snapshot.Note != "" {
+			fmt.Printf(": %s", snapshot.Note)
+		}
+		fmt.Print("\n\n")
+		table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(table, "FILENAME\tREVISION\tSHA256\tURL")
+		for _, entry := range catalog {
+			fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", entry.Filename, entry.Revision, entry.SHA256, entry.URL)
+		}
+		table.Flush()
+	case "delete":
+		snapshot, err := store.Open(flags.Arg(1))
+		if err == nil {
+			err = store.Delete(snapshot.ID)
+		}
+		if err != nil {
+			fatal("Deleting the snapshot failed", "err", err)
+		}
+		if asJSON {
+			printJSON(snapshotReport{Schema: snapshotSchema, Build: build(), Action: action, Snapshot: snapshot})
+			return
+		}
+		fmt.Println("Deleted snapshot", snapshot.ID)
+	}
+}
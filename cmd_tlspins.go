@@ -0,0 +1,62 @@
+package main // Declare main package
+
+import ( // Import required packages
+	"context"        // For the connection timeout
+	"flag"           // For parsing tls-pins flags
+	"fmt"            // For printing pins
+	"os"             // For output
+	"text/tabwriter" // For aligned tables
+	"time"           // For the timeout and expiry dates
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // Certificate pins
+)
+
+// tlsPinsReport is the JSON form of the tls-pins command
+type tlsPinsReport struct {
+	Schema       string                           `json:"schema"`
+	Build        *sdscraper.BuildInfo             `json:"build"`
+	Certificates []sdscraper.PresentedCertificate `json:"certificates"`
+}
+
+// Runs "tls-pins", which prints the public key pins of the chains hosts present now, for setting up -tls-pin and for
+// checking a rotation: a new pin is only added once the chain it comes from has been confirmed with the site
+func runTLSPins(args []string) {
+	flags := flag.NewFlagSet("tls-pins", flag.ExitOnError) // tls-pins flags
+	setupLogging := logFlags(flags)                        // -log-level and -log-format
+	jsonOutput := formatFlags(flags)                       // -format
+	timeout := flags.Duration("timeout", 10*time.Second, "upper bound for each connection")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s tls-pins [flags] host[:port]...\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args) // Exits on invalid flags
+	setupLogging()
+	asJSON := jsonOutput()
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	report := tlsPinsReport{Schema: tlsPinsSchema, Build: build(), Certificates: []sdscraper.PresentedCertificate{}}
+	for _, address := range flags.Args() {
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		presented, err := sdscraper.PresentedPins(ctx, address)
+		cancel()
+		if err != nil {
+			fatal("Reading the certificate chain failed", "host", address, "err", err)
+		}
+		report.Certificates = append(report.Certificates, presented...)
+	}
+	if asJSON {
+		printJSON(report)
+		return
+	}
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "HOST\tDEPTH\tEXPIRES\tSUBJECT\tPIN")
+	for _, certificate := range report.Certificates {
+		fmt.Fprintf(table, "%s\t%d\t%s\t%s\t%s\n", certificate.Host, certificate.Depth,
+			certificate.NotAfter.UTC().Format(time.DateOnly), certificate.Subject, certificate.Pin)
+	}
+	table.Flush()
+	fmt.Println("\npin with -tls-pin host=PIN, ideally an intermediate's key plus a backup key")
+}
@@ -0,0 +1,108 @@
+package main // Declare main package
+
+import ( // Import required packages
+	"context"        // For cancelling remote checks
+	"flag"           // For parsing verify flags
+	"fmt"            // For printing results
+	"net/http"       // For the remote checks
+	"os"             // For output and exit codes
+	"os/signal"      // For stopping on Ctrl-C
+	"slices"         // For merging problems in file order
+	"strings"        // For comparing file names
+	"text/tabwriter" // For aligned tables
+	"time"           // For the request timeout
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // Mirror audit
+)
+
+// verifyReport is the JSON form of a verify run
+type verifyReport struct {
+	Schema   string                  `json:"schema"`
+	Build    *sdscraper.BuildInfo    `json:"build"`
+	Remote   bool                    `json:"remote"`          // Source URLs were checked too
+	Drift    *sdscraper.DriftSummary `json:"drift,omitempty"` // With -against-remote, how far the archive has drifted from the site
+	Problems []sdscraper.Problem     `json:"problems"`        // Sorted by file
+}
+
+// Runs "verify", which re-hashes the mirror against the manifest, optionally confirms the sources are still live or
+// downloads them again to compare content, and exits 1 when anything is off; "fsck -repair" fixes what can be fixed
+func runVerify(args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError) // Verify flags
+	setupLogging := logFlags(flags)                      // -log-level and -log-format
+	jsonOutput := formatFlags(flags)                     // -format
+	manifestPath := flags.String("manifest", "manifest.json", "path of the manifest file")
+	outputDir := flags.String("output", "PDFs/", "directory holding downloaded documents")
+	blockSize := flags.Int("block-size", 1<<20, "read size in bytes when hashing")
+	mmap := flags.Bool("mmap", false, "memory-map files while hashing instead of reading them in blocks")
+	remote := flags.Bool("remote", false, "also send a HEAD request for every live document to confirm its source URL still answers")
+	againstRemote := flags.Bool("against-remote", false, "download live documents again and compare their content with the manifest and local copies, reporting drift and corruption rates")
+	sample := flags.Int("sample", 0, "with -against-remote, compare this many documents drawn at random (0 for all)")
+	workers := flags.Int("workers", 4, "concurrent remote checks")
+	timeout := flags.Duration("timeout", 30*time.Second, "time limit of each remote check")
+	userAgent := flags.String("user-agent", "", "User-Agent of the remote checks (empty for Go's default)")
+	reportPath := flags.String("report", "", "also write the JSON report to this file")
+	flags.Parse(args) // Exits on invalid flags
+	setupLogging()
+	asJSON := jsonOutput()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	client := &http.Client{Timeout: *timeout}
+	if *userAgent != "" {
+		client.Transport = &sdscraper.HeaderTransport{UserAgent: *userAgent}
+	}
+	manifest, err := sdscraper.LoadManifest(*manifestPath)
+	if err != nil {
+		fatal("Reading manifest failed", "err", err)
+	}
+	options := sdscraper.VerifyOptions{
+		OutputDir: *outputDir,
+		Hashing:   sdscraper.HashOptions{BlockSize: *blockSize, MMap: *mmap},
+		Remote:    *remote,
+		Client:    client,
+		Workers:   *workers,
+		Sample:    *sample,
+	}
+	problems, err := sdscraper.Verify(ctx, manifest, options)
+	if err != nil {
+		fatal("Verification was interrupted", "err", err)
+	}
+	var drift *sdscraper.DriftSummary
+	if *againstRemote {
+		summary, changed, err := sdscraper.CompareRemote(ctx, manifest, options)
+		if err != nil {
+			fatal("Comparing with the live site was interrupted", "err", err)
+		}
+		drift = &summary
+		problems = append(problems, changed...)
+		slices.SortStableFunc(problems, func(a, b sdscraper.Problem) int { return strings.Compare(a.Filename, b.Filename) })
+	}
+
+	report := verifyReport{Schema: verifySchema, Build: build(), Remote: *remote, Drift: drift, Problems: problems}
+	if report.Problems == nil {
+		report.Problems = []sdscraper.Problem{}
+	}
+	if *reportPath != "" {
+		writeJSONReport(*reportPath, report)
+	}
+	if drift != nil && !asJSON {
+		fmt.Printf("Compared %d of %d documents with the live site: %d matched, %d drifted (%.1f%%), %d corrupted locally (%.1f%%), %d unreachable\n",
+			drift.Compared, drift.Eligible, drift.Matched, drift.Drifted, 100*drift.DriftRate, drift.Corrupted, 100*drift.CorruptRate, drift.Unreachable)
+	}
+	switch {
+	case asJSON:
+		printJSON(report)
+	case len(problems) == 0:
+		fmt.Println("Mirror verified; no discrepancies")
+	default:
+		table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(table, "KIND\tFILE\tDETAIL\tFIX")
+		for _, problem := range problems {
+			fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", problem.Kind, problem.Filename, problem.Detail, problem.Fix)
+		}
+		table.Flush()
+	}
+	if len(problems) > 0 {
+		os.Exit(1) // Discrepancies found
+	}
+}
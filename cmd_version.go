@@ -0,0 +1,35 @@
+package main // Declare main package
+
+import ( // Import required packages
+	"flag" // For parsing version flags
+	"fmt"  // For printing the build
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // Build information
+)
+
+// Runs "version", printing the build that support needs to identify this binary
+func runVersion(args []string) {
+	flags := flag.NewFlagSet("version", flag.ExitOnError) // Version flags
+	jsonOutput := formatFlags(flags)                      // -format
+	flags.Parse(args)                                     // Exits on invalid flags
+	build := sdscraper.Build()
+	if jsonOutput() {
+		printJSON(versionReport{Schema: versionSchema, BuildInfo: build})
+		return
+	}
+	fmt.Printf("gojo %s", build.Version)
+	if build.Commit != "" {
+		fmt.Printf(" (commit %s", build.Commit)
+		if build.Date != "" {
+			fmt.Printf(", built %s", build.Date)
+		}
+		fmt.Print(")")
+	}
+	fmt.Println()
+}
+
+// versionReport is the JSON form of the version command
+type versionReport struct {
+	Schema string `json:"schema"`
+	sdscraper.BuildInfo
+}
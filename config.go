@@ -0,0 +1,178 @@
+package main // Declare main package
+
+import ( // Import required packages
+	"cmp"           // For the default profile
+	"encoding/json" // For JSON config files
+	"flag"          // For applying config values to flags
+	"fmt"           // For error messages
+	"os"            // For reading the config file
+	"path/filepath" // For picking the format by extension
+	"sort"          // For applying keys in a stable order
+	"strings"       // For joining list values
+
+	"github.com/BurntSushi/toml" // For TOML config files
+	"gopkg.in/yaml.v3"           // For YAML config files
+)
+
+// A config file: flag values shared by every profile, and named profiles layered on top
+type configFile struct {
+	Profile  string                    `json:"profile" yaml:"profile" toml:"profile" doc:"profile used when -profile is not given"`
+	Defaults map[string]any            `json:"defaults" yaml:"defaults" toml:"defaults" doc:"settings for every profile"`
+	Profiles map[string]map[string]any `json:"profiles" yaml:"profiles" toml:"profiles" doc:"settings per profile, e.g. gojo-canada, overriding defaults; features add to the defaults' list"`
+	Sites    []map[string]any          `json:"sites" yaml:"sites" toml:"sites" doc:"sites crawled in one run, each a name plus site settings"`
+}
+
+// Registers -config and -profile and returns a function that fills flags not given on the command line from the file
+// and returns the loaded file, nil when there is none
+func configFlags(flags *flag.FlagSet) func() *configFile {
+	path := flags.String("config", os.Getenv("SDS_CONFIG"), "YAML, TOML or JSON file of flag values with named profiles (default $SDS_CONFIG)")
+	profile := flags.String("profile", "", "profile of the config file to use (default: the file's profile key)")
+	return func() *configFile {
+		if *path == "" {
+			if *profile != "" {
+				fmt.Fprintln(flags.Output(), "-profile needs -config")
+				os.Exit(2)
+			}
+			return nil
+		}
+		config, err := applyConfig(flags, *path, *profile)
+		if err != nil {
+			fmt.Fprintf(flags.Output(), "config %s: %v\n", *path, err)
+			os.Exit(2) // Same status as other flag errors
+		}
+		return config
+	}
+}
+
+// Loads path and sets every flag the command line left alone, profile values overriding defaults
+func applyConfig(flags *flag.FlagSet, path, profile string) (*configFile, error) {
+	config, err := loadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]any, len(config.Defaults))
+	for key, value := range config.Defaults {
+		values[key] = value
+	}
+	if profile = cmp.Or(profile, config.Profile); profile != "" {
+		profileValues, ok := config.Profiles[profile]
+		if !ok {
+			return nil, fmt.Errorf("no profile %q (have %s)", profile, strings.Join(sortedKeys(config.Profiles), ", "))
+		}
+		for key, value := range profileValues {
+			if key == "features" { // Profiles add to the default features instead of replacing them, "-name" to drop one
+				value = append(listValue(values[key]), listValue(value)...)
+			}
+			values[key] = value
+		}
+	}
+
+	return config, setFlags(flags, values, explicitFlags(flags))
+}
+
+// Returns the flags given on the command line
+func explicitFlags(flags *flag.FlagSet) map[string]bool {
+	explicit := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	return explicit
+}
+
+// Sets flags from config values, leaving those in skip alone
+func setFlags(flags *flag.FlagSet, values map[string]any, skip map[string]bool) error {
+	for _, key := range sortedKeys(values) {
+		target := flags.Lookup(key)
+		switch {
+		case target == nil:
+			return fmt.Errorf("unknown setting %q; settings are flag names without the dash", key)
+		case skip[key], key == "config", key == "profile":
+			continue
+		}
+		for _, value := range flagValues(values[key], target) {
+			if err := flags.Set(key, value); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Parses a config file in the format its extension names
+func loadConfig(path string) (*configFile, error) {
+	config := &configFile{}
+	return config, decodeFile(path, config)
+}
+
+// Decodes a YAML, TOML or JSON file into target, picking the format by extension
+func decodeFile(path string, target any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return yaml.Unmarshal(data, target)
+	case ".toml":
+		return toml.Unmarshal(data, target)
+	case ".json":
+		return json.Unmarshal(data, target)
+	}
+	return fmt.Errorf("unknown format %q (want .yaml, .yml, .toml or .json)", filepath.Ext(path))
+}
+
+// Converts a config value into the strings to pass to flag.Set; lists become comma-separated,
+// except for repeatable flags, which are set once per item, and maps become "key: value" items
+func flagValues(value any, target *flag.Flag) []string {
+	var items []string
+	switch typed := value.(type) {
+	case []any:
+		for _, item := range typed {
+			items = append(items, fmt.Sprint(item))
+		}
+	case map[string]any:
+		for _, key := range sortedKeys(typed) {
+			items = append(items, key+": "+fmt.Sprint(typed[key]))
+		}
+	default:
+		return []string{fmt.Sprint(value)}
+	}
+	if repeatable(target) {
+		return items
+	}
+	return []string{strings.Join(items, ",")}
+}
+
+// Reports whether a flag takes one value per use rather than a comma-separated list
+func repeatable(target *flag.Flag) bool {
+	switch target.Value.(type) {
+	case headerFlags, *redactFlags, *tagRuleFlags, channelFlags, *bandwidthFlags, *pinFlags:
+		return true
+	}
+	return false
+}
+
+// Returns a config value as a list, splitting comma-separated strings
+func listValue(value any) []any {
+	switch typed := value.(type) {
+	case nil:
+		return nil
+	case []any:
+		return typed
+	case string:
+		var items []any
+		for _, item := range splitList(typed) {
+			items = append(items, item)
+		}
+		return items
+	}
+	return []any{value}
+}
+
+// Returns a map's keys in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,652 @@
+package main // Declare main package
+
+import ( // Import required packages
+	"cmp"                // For falling back to the environment
+	"context"            // For the object lock check
+	"flag"               // For registering crawl flags
+	"fmt"                // For error messages
+	"log/slog"           // For logging enabled features
+	"net/http"           // For the shared HTTP client
+	"net/http/cookiejar" // For portal login sessions
+	"net/url"            // For recognising Slack webhooks
+	"os"                 // For environment variables
+	"regexp"             // For document link patterns
+	"strings"            // For parsing header flags
+	"time"               // For duration defaults
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // Scraping and downloading
+)
+
+// Flags configuring a crawl, shared by the crawl command and serve's background sync
+type crawlFlags struct {
+	rendererName, listingEndpoints, remoteChrome *string
+	bandwidth                                    *bandwidthFlags
+	interaction                                  sdscraper.Interaction  // Chrome page-driving steps
+	browserLogin                                 sdscraper.BrowserLogin // Chrome sign-in for portal-gated listings
+	browserPasswordFile, browserCookies          *string
+	headless                                     *bool
+	chromePath                                   *string
+	challengeWait                                *time.Duration
+	challengeScreenshots, challengeCookies       *string
+	chromeMaxPages                               *int
+	chromeMaxAge                                 *time.Duration
+	blockResources, blockURLs                    *string
+	pageURL, locales, rejectDir                  *string
+	extraPageURLs, linkPattern, cacheFile        *string
+	crawlDepth, crawlMaxPages, crawlMaxPattern   *int
+	crawlAllow, types                            *string
+	crawlScope, crawlHosts, crawlDeny            *string
+	crawlNofollow                                *bool
+	structuralCheck                              *bool
+	digests                                      *string
+	extractors, ocrCommand                       *string
+	maxFileSize, maxRunBytes, spoolDir           *string
+	httpCache, seeds                             *string
+	portalURL, portalLoginURL, portalUsername    *string
+	portalPassword, portalToken                  *string
+	backupDir, trashDir                          *string
+	trashGrace                                   *time.Duration
+	backupKeep                                   *int
+	backupMaxAge                                 *time.Duration
+	checkpointPath                               *string
+	workers, ioWorkers                           *int
+	warmUp                                       *bool
+	networkInterval                              *time.Duration
+	networkProbe                                 *string
+	rate                                         *string
+	delay                                        *time.Duration
+	burst                                        *int
+	proxy, userAgent                             *string
+	pins                                         *pinFlags // TLS public keys pinned per host
+	pinReportOnly                                *string
+	headers                                      headerFlags // Extra request headers
+	robots                                       *bool
+	refresh                                      *time.Duration
+	forceRefresh                                 *bool
+	webhook, slackWebhook                        *string
+	notifyFirstSync                              *bool
+	filenameTemplate                             *string
+	localeManifests                              *string
+	tagRules                                     *tagRuleFlags // Tags from listing metadata
+	rulesPath                                    *string
+	channels                                     channelFlags // Notification channels the rules route to
+	tags, pruneTags, webhookTags, slackTags      *string
+	prune, allowAnomalousPrune, forcePrune       *bool
+	anomalyDrop, maxPrune                        *float64
+	storage                                      func() sdscraper.Storage // -storage and the -s3-* flags
+	metricsAddr, catalogDB, searchIndex          *string
+	printIndex, printIndexURL                    *string
+	features                                     *string
+}
+
+// Registers the crawl flags on flags
+func registerCrawlFlags(flags *flag.FlagSet) *crawlFlags {
+	c := &crawlFlags{headers: make(headerFlags), pins: &pinFlags{}, tagRules: &tagRuleFlags{}, channels: make(channelFlags), bandwidth: &bandwidthFlags{}}
+	c.rendererName = flags.String("renderer", "chrome", "how to render the listing page: chrome, http, or auto (http first, chrome if no links are found)")
+	c.listingEndpoints = flags.String("listing-endpoints", "", "comma-separated JSON/XHR listing URLs fetched by the http renderer")
+	c.remoteChrome = flags.String("remote-chrome", "", "DevTools endpoint of a running Chrome to use instead of launching one, e.g. ws://host:9222")
+	c.chromePath = flags.String("chrome-path", os.Getenv("SDS_CHROME_PATH"), "Chrome or Chromium binary to launch (default: searched on PATH and the platform's install locations; $SDS_CHROME_PATH)")
+	flags.StringVar(&c.interaction.WaitSelector, "wait-selector", "", "CSS selector that must be visible before the listing is captured")
+	flags.IntVar(&c.interaction.MaxScrolls, "max-scrolls", 10, "scroll-to-bottom attempts while the listing keeps growing")
+	flags.StringVar(&c.interaction.LoadMoreSelector, "load-more-selector", "", "CSS selector of a \"Load more\" button to click until it disappears")
+	flags.StringVar(&c.interaction.NextPageSelector, "next-page-selector", "", "CSS selector of the next-page control; every page is captured")
+	flags.IntVar(&c.interaction.MaxPages, "max-pages", 20, "upper bound on load-more clicks and pagination steps")
+	c.chromeMaxPages = flags.Int("chrome-max-pages", 50, "restart Chrome after it captured this many listing pages (0 for never)")
+	c.blockResources = flags.String("block-resources", strings.Join(sdscraper.DefaultBlockedTypes, ","), "comma-separated resource types Chrome skips while rendering, e.g. image,font,media,stylesheet (empty loads everything)")
+	c.blockURLs = flags.String("block-urls", strings.Join(sdscraper.DefaultBlockedURLs, ","), "comma-separated URL patterns with * wildcards Chrome never requests (empty blocks none)")
+	flags.StringVar(&c.browserLogin.URL, "browser-login-url", "", "login page Chrome signs in at before capturing listings that sit behind a customer portal (empty for none)")
+	flags.StringVar(&c.browserLogin.Username, "browser-username", os.Getenv("SDS_BROWSER_USERNAME"), "login name typed in at -browser-login-url (default $SDS_BROWSER_USERNAME)")
+	flags.StringVar(&c.browserLogin.Password, "browser-password", os.Getenv("SDS_BROWSER_PASSWORD"), "password typed in at -browser-login-url (default $SDS_BROWSER_PASSWORD)")
+	c.browserPasswordFile = flags.String("browser-password-file", "", "file holding the -browser-password, such as a mounted secret; wins over the flag")
+	flags.StringVar(&c.browserLogin.UsernameSelector, "browser-username-selector", "", "CSS selector of the login form's username field (empty guesses from the usual names)")
+	flags.StringVar(&c.browserLogin.PasswordSelector, "browser-password-selector", "", "CSS selector of the login form's password field (empty for its password input)")
+	flags.StringVar(&c.browserLogin.SubmitSelector, "browser-submit-selector", "", "CSS selector of the login button (empty submits the password field's form)")
+	flags.StringVar(&c.browserLogin.SuccessSelector, "browser-success-selector", "", "CSS selector only shown once signed in (empty waits for the password field to go away)")
+	c.browserCookies = flags.String("browser-cookies", "", "JSON or cookies.txt export of a signed-in session, injected into Chrome and the download client before anything is fetched")
+	c.headless = flags.Bool("headless", false, "run a launched Chrome without a window; keep it visible to solve CAPTCHAs by hand")
+	c.challengeWait = flags.Duration("challenge-wait", 0, "how long a listing served as a CAPTCHA or bot check waits for the operator to solve it (0 fails the render at once)")
+	c.challengeScreenshots = flags.String("challenge-screenshots", "challenges", "directory receiving a screenshot of every challenged listing (empty for none)")
+	c.challengeCookies = flags.String("challenge-cookies", "", "file watched during -challenge-wait; a solved session's cookies written there are injected and the listing reloaded")
+	c.chromeMaxAge = flags.Duration("chrome-max-age", 30*time.Minute, "restart Chrome once it has been running this long (0 for never)")
+	c.pageURL = flags.String("page-url", "https://www.gojo.com/{locale}/SDS", "listing page to scrape; {locale} is replaced with each locale")
+	c.extraPageURLs = flags.String("extra-page-urls", "", "comma-separated further listing pages crawled after -page-url; {locale} is replaced too")
+	c.linkPattern = flags.String("link-pattern", "", "regular expression matching document URLs, relative links included (empty for absolute .pdf links)")
+	c.seeds = flags.String("seeds", "", "comma-separated JSON or CSV seed lists, files or URLs, of document URLs with product, sku, language, revision and locale, mirrored along with the listings' links")
+	c.portalURL = flags.String("portal-url", "", "JSON listing API of an authenticated SDS portal whose documents are mirrored too, paginated with ?page=N or the responses' next links (empty disables)")
+	c.portalLoginURL = flags.String("portal-login-url", "", "form endpoint -portal-username and -portal-password are posted to before listing the portal; its cookies also authorise downloads")
+	c.portalUsername = flags.String("portal-username", os.Getenv("SDS_PORTAL_USERNAME"), "portal login name (default $SDS_PORTAL_USERNAME)")
+	c.portalPassword = flags.String("portal-password", os.Getenv("SDS_PORTAL_PASSWORD"), "portal password (default $SDS_PORTAL_PASSWORD)")
+	c.portalToken = flags.String("portal-token", os.Getenv("SDS_PORTAL_TOKEN"), "bearer token sent to the portal's host only, for API-key portals (default $SDS_PORTAL_TOKEN)")
+	c.crawlDepth = flags.Int("crawl-depth", 0, "follow same-site links this many hops from the listings to find documents only product pages link (0 to stay on the listings)")
+	c.crawlAllow = flags.String("crawl-allow", "", "regular expression the pages a deep crawl visits must match, e.g. /products/ (empty for any same-site page)")
+	c.crawlMaxPages = flags.Int("crawl-max-pages", sdscraper.DefaultDeepCrawlPages, "upper bound on pages one locale's deep crawl renders")
+	c.crawlScope = flags.String("crawl-scope", "", "comma-separated path prefixes a deep crawl stays within, e.g. /en/products/ (empty for the whole site)")
+	c.crawlHosts = flags.String("crawl-hosts", "", "comma-separated further hosts a deep crawl may enter; *.example.com admits every subdomain (empty for the listing's host only)")
+	c.crawlDeny = flags.String("crawl-deny", "", "regular expression of pages a deep crawl never visits, even when -crawl-allow matches, e.g. /(login|cart)/")
+	c.crawlNofollow = flags.Bool("crawl-nofollow", false, "skip rel=nofollow links and pages whose robots meta tag says nofollow during a deep crawl")
+	c.crawlMaxPattern = flags.Int("crawl-max-per-pattern", sdscraper.DefaultCrawlPatternPages, "upper bound on pages a deep crawl renders per URL pattern with digits masked, e.g. /events?month=N, so calendars and pagers end")
+	c.cacheFile = flags.String("listing-cache", "gojo-{locale}.html", "local copy of each rendered listing page; {locale} is replaced")
+	c.locales = flags.String("locales", "en", "comma-separated GOJO site locales to crawl, e.g. en,en-CA,fr-CA")
+	c.rejectDir = flags.String("rejects", "rejects/", "directory invalid downloads are quarantined in (empty to discard them)")
+	c.types = flags.String("types", "", "comma-separated document types to mirror, each in its own subfolder: pdf, docx, doc, xlsx, zip; extensionless links are asked for their type (empty for PDFs only, kept in the output folder itself)")
+	c.maxFileSize = flags.String("max-file-size", "512MiB", "largest document downloaded, e.g. 100MB; larger ones fail before or while streaming (0 for no limit)")
+	c.maxRunBytes = flags.String("max-run-bytes", "0", "bytes one run may transfer before the remaining downloads fail, e.g. 20GiB (0 for no quota)")
+	c.httpCache = flags.String("http-cache", "", "directory of a disk cache for pages fetched without Chrome, honouring Cache-Control and Expires, e.g. http-cache/ (empty disables)")
+	c.spoolDir = flags.String("spool-dir", "", "directory bodies are streamed to while they are validated (empty for the system temp directory)")
+	c.structuralCheck = flags.Bool("validate-structure", false, "parse every downloaded PDF with pdfcpu before accepting it")
+	c.digests = flags.String("digests", "", "comma-separated digests to record for every document besides SHA-256: "+strings.Join(sdscraper.HasherNames(), ", ")+"; the digest command adds them to documents stored before")
+	c.extractors = flags.String("extractors", "", "comma-separated metadata extractors run over new content, each field taken from the first that finds it: pdf-info, first-page, url, ocr (empty for the listings' metadata alone)")
+	c.ocrCommand = flags.String("ocr-command", "", "command the ocr extractor runs, split on spaces, reading a PDF on stdin and printing its text, e.g. ocr-sds.sh")
+	c.backupDir = flags.String("backup-dir", "backups/", "directory for pre-run backups of the manifest (empty to disable)")
+	c.trashDir = flags.String("trash", "trash/", "directory deleted and replaced files are moved to, one folder per day (empty to delete them outright)")
+	c.trashGrace = flags.Duration("trash-grace", sdscraper.DefaultTrashGrace, "how long trashed files stay recoverable before they are deleted for good")
+	c.backupKeep = flags.Int("backup-keep", 10, "number of newest backups always kept")
+	c.backupMaxAge = flags.Duration("backup-max-age", 30*24*time.Hour, "older backups beyond -backup-keep are deleted")
+	c.checkpointPath = flags.String("checkpoint", "state.json", "progress file that lets an interrupted run resume (empty to disable)")
+	c.workers = flags.Int("workers", 1, "documents transferred concurrently while discovery continues")
+	c.ioWorkers = flags.Int("io-workers", 1, "downloaded documents validated, hashed and written to disk concurrently")
+	c.warmUp = flags.Bool("warm-up", true, "resolve and connect to each document host before downloading from it")
+	c.networkInterval = flags.Duration("network-interval", sdscraper.DefaultNetworkInterval, "how often network changes and suspend are looked for, pausing downloads while offline (0 disables)")
+	c.networkProbe = flags.String("network-probe", "", "host:port dialled to confirm connectivity after a network change (default: the listing page's host)")
+	c.rate = flags.String("rate", "", "request rate limit shared by all HTTP requests, e.g. 2/s or 30/m (empty for unlimited)")
+	c.delay = flags.Duration("delay", 0, "minimum spacing between HTTP requests, e.g. 500ms")
+	flags.Var(c.bandwidth, "bandwidth-window", "limit downloads by local time of day, as \"days HH:MM-HH:MM rate [workers=N]\", e.g. \"mon-fri 08:00-18:00 1MiB/s workers=2\"; the first matching window applies, none means unlimited; repeatable")
+	c.burst = flags.Int("burst", 1, "requests allowed back to back before -rate applies")
+	c.proxy = flags.String("proxy", "", "outbound proxy for Chrome and downloads: http://, https:// or socks5:// (empty to use HTTPS_PROXY/HTTP_PROXY)")
+	c.userAgent = flags.String("user-agent", "", "User-Agent sent by Chrome and the download client (empty keeps their defaults)")
+	flags.Var(c.headers, "header", "extra request header as \"Name: value\"; repeatable")
+	flags.Var(c.pins, "tls-pin", "only trust host's TLS chain if it carries this public key, as host=sha256/BASE64 with host or *.domain; pin a backup key too so rotations do not fail; the tls-pins command prints a host's pins; Chrome rendering is not pinned; repeatable")
+	c.pinReportOnly = flags.String("tls-pin-report-only", "", "comma-separated -tls-pin hosts, or * for all, whose pin mismatches are logged instead of failing, while a legitimate key rotation is verified and rolled out")
+	c.robots = flags.Bool("robots", true, "fetch each host's robots.txt, again daily, and skip disallowed URLs; an unreachable robots.txt disallows the host until it is back")
+	c.refresh = flags.Duration("refresh", 24*time.Hour, "re-render cached listing pages older than this (0 keeps them forever)")
+	c.forceRefresh = flags.Bool("force-refresh", false, "re-render every listing page regardless of its cache age")
+	c.webhook = flags.String("webhook", "", "URL receiving a JSON POST for every new or revised document")
+	c.slackWebhook = flags.String("slack-webhook", "", "Slack incoming webhook URL told about new or revised documents")
+	c.notifyFirstSync = flags.Bool("notify-first-sync", false, "announce every document of the first sync into an empty archive too, instead of one baseline summary")
+	c.webhookTags = flags.String("webhook-tags", "", "only tell -webhook about documents with these comma-separated tags; -tag excludes one")
+	c.slackTags = flags.String("slack-tags", "", "only tell -slack-webhook about documents with these comma-separated tags; -tag excludes one")
+	flags.Var(c.tagRules, "tag-rule", "tag documents whose metadata matches, as tag=field:regexp with field url, filename, product, sku, brand, language, type or locale; repeatable")
+	c.rulesPath = flags.String("rules", "", "YAML, TOML or JSON file of ingestion rules matching URL, title, locale, hazard codes or tags to add tags, pick a folder and route notifications")
+	flags.Var(c.channels, "notify-channel", "notification channel rules can route to, as name=url; Slack incoming webhook URLs get Slack messages, others JSON; repeatable")
+	c.tags = flags.String("tags", "", "only download documents with these comma-separated tags; -tag excludes one")
+	c.localeManifests = flags.String("locale-manifests", "", "also write a manifest per locale to this path, {locale} replaced and relative paths inside -output, e.g. \"{locale}/manifest.json\"; -manifest becomes the merged one referencing them")
+	c.filenameTemplate = flags.String("filename-template", "", "name new downloads from listing metadata: {product} {sku} {lang} {revision} {brand} {locale} {name} {id}, e.g. \"{product}_{sku}_{lang}.pdf\" (empty keeps URL-derived names)")
+	c.prune = flags.Bool("prune", false, "soft-delete catalogued documents the listings no longer show")
+	c.anomalyDrop = flags.Float64("anomaly-drop", sdscraper.DefaultAnomalyDrop, "flag a run whose discovery count falls this fraction below the usual, e.g. 0.5 for a halving")
+	c.pruneTags = flags.String("prune-tags", "", "only prune documents with these comma-separated tags; -tag excludes one")
+	c.maxPrune = flags.Float64("max-prune", sdscraper.DefaultMaxPruneFraction, "largest fraction of the archive within -prune-tags one run may prune without -force, from 0 to 1 (0 holds every prune for -force)")
+	c.forcePrune = flags.Bool("force", false, "prune even more than -max-prune of the archive")
+	c.allowAnomalousPrune = flags.Bool("allow-anomalous-prune", false, "prune even when the run's discovery looks anomalous")
+	c.storage = storageFlags(flags)
+	c.catalogDB = flags.String("catalog-db", "", "SQLite database kept in sync with the manifest after each run, for \"catalog list\" and \"catalog query\" (empty disables; not allowed with -encryption-key)")
+	c.features = flags.String("features", "", "comma-separated features to enable while they are rolled out: "+strings.Join(sdscraper.KnownFeatures(), ", ")+"; a -name item switches one off again")
+	c.printIndex = flags.String("print-index", "", "printable PDF index of the archive (product, revision, file, QR link) rewritten after each run, for the front of SDS binders (empty disables)")
+	c.printIndexURL = flags.String("print-index-url", "", "serve-mode address the print index's QR codes open, e.g. https://sds.example.com (empty links to the source URLs)")
+	c.searchIndex = flags.String("search-index", "", "SQLite full-text index of the downloaded PDFs updated after each run, for the search command (empty disables; not allowed with -encryption-key)")
+	c.metricsAddr = flags.String("metrics-addr", "", "address serving Prometheus /metrics and /healthz while watching or serving, e.g. :9090 (empty disables)")
+	return c
+}
+
+// Builds the scraper the parsed flags describe, exiting on invalid values
+func (c *crawlFlags) scraper() *sdscraper.Scraper {
+	if sdscraper.EncryptionEnabled() && (*c.catalogDB != "" || *c.searchIndex != "") {
+		fatal("-catalog-db and -search-index keep plaintext copies of the archive's metadata and text, which -encryption-key forbids")
+	}
+	perSecond, err := sdscraper.ParseRate(*c.rate)
+	if err != nil {
+		fatal("Invalid -rate", "err", err)
+	}
+	base, err := sdscraper.NewProxyTransport(*c.proxy)
+	if err != nil {
+		fatal("Invalid -proxy", "err", err)
+	}
+	if len(c.pins.Hosts) > 0 {
+		c.pins.ReportOnly = splitList(*c.pinReportOnly)
+		c.pins.Apply(base)
+	} else if *c.pinReportOnly != "" {
+		fatal("-tls-pin-report-only needs -tls-pin")
+	}
+	transport := &sdscraper.PoliteTransport{ // Politeness at the HTTP layer
+		Base:    &sdscraper.HeaderTransport{Base: base, UserAgent: *c.userAgent, Headers: http.Header(c.headers)},
+		Limiter: sdscraper.NewRateLimiter(perSecond, *c.delay, *c.burst),
+	}
+	if *c.robots {
+		transport.Robots = &sdscraper.RobotsPolicy{UserAgent: *c.userAgent}
+	}
+	client := &http.Client{Timeout: 30 * time.Second, Transport: transport} // Shared by listing fetches and downloads
+	var sources []sdscraper.DiscoverySource                                 // Documents besides the listings' links
+	bandwidth := c.bandwidth.schedule()
+	if bandwidth != nil {
+		client.Timeout, base.ResponseHeaderTimeout = 0, 30*time.Second // A throttled body may take far longer than 30 seconds
+	}
+	for _, location := range splitList(*c.seeds) {
+		sources = append(sources, &sdscraper.SeedList{Location: location, Client: client})
+	}
+	if *c.portalURL != "" {
+		portal := &sdscraper.PortalSource{
+			ListURL: *c.portalURL, LoginURL: *c.portalLoginURL, Username: *c.portalUsername, Password: *c.portalPassword,
+			Token: *c.portalToken, Client: client,
+		}
+		transport.Base = portal.Authorize(transport.Base) // The token reaches the portal's host and nothing else
+		client.Jar, _ = cookiejar.New(nil)                // The login session, shared with the downloads; never fails without options
+		sources = append(sources, portal)
+	}
+
+	chrome := &sdscraper.ChromeRenderer{ // Visible local Chrome unless a remote one is given
+		Headless:    *c.headless,
+		RemoteURL:   *c.remoteChrome,
+		ExecPath:    *c.chromePath,
+		Interaction: c.interaction,
+		ProxyURL:    cmp.Or(*c.proxy, proxyFromEnvironment()),
+		UserAgent:   *c.userAgent,
+		Headers:     http.Header(c.headers),
+		MaxPages:    *c.chromeMaxPages,
+		MaxAge:      *c.chromeMaxAge,
+		Blocking:    sdscraper.ResourceBlocking{Types: splitList(*c.blockResources), URLs: splitList(*c.blockURLs)},
+	}
+	if login := c.browserLogin; login.URL != "" || *c.browserCookies != "" {
+		if *c.browserPasswordFile != "" {
+			password, err := os.ReadFile(*c.browserPasswordFile)
+			if err != nil {
+				fatal("Invalid -browser-password-file", "err", err)
+			}
+			login.Password = strings.TrimRight(string(password), "\r\n") // Secret files usually end in a newline
+		}
+		if *c.browserCookies != "" {
+			if login.Cookies, err = sdscraper.LoadCookies(*c.browserCookies); err != nil {
+				fatal("Invalid -browser-cookies", "err", err)
+			}
+		}
+		if client.Jar == nil {
+			client.Jar, _ = cookiejar.New(nil) // Downloads behind the login need the session too
+		}
+		sdscraper.AddCookies(client.Jar, login.Cookies) // For the http renderer, before Chrome has run
+		login.Jar = client.Jar
+		chrome.Login = &login
+	}
+	if err := chrome.Blocking.Validate(); err != nil {
+		fatal("Invalid -block-resources", "err", err)
+	}
+	features, err := sdscraper.ParseFeatures(splitList(*c.features))
+	if err != nil {
+		fatal("Invalid -features", "err", err)
+	}
+	if names := features.Names(); len(names) > 0 {
+		slog.Info("Features enabled", "features", names)
+	}
+	pageClient := client    // Listings, endpoints and product pages fetched without Chrome
+	if *c.httpCache != "" { // Documents bypass it; the manifest's validators already cover them
+		pageClient = &http.Client{Timeout: client.Timeout, Jar: client.Jar, Transport: &sdscraper.CacheTransport{Base: transport, Dir: *c.httpCache}}
+	}
+	renderer, err := newRenderer(*c.rendererName, splitList(*c.listingEndpoints), chrome, pageClient, features) // Pick the rendering strategy
+	if err != nil {
+		fatal("Invalid renderer", "err", err)
+	}
+
+	var linkPattern *regexp.Regexp
+	if *c.linkPattern != "" {
+		if linkPattern, err = regexp.Compile(*c.linkPattern); err != nil {
+			fatal("Invalid -link-pattern", "err", err)
+		}
+	}
+	types, err := sdscraper.ParseDocumentTypes(splitList(*c.types))
+	if err != nil {
+		fatal("Invalid -types", "err", err)
+	}
+	extractors, err := sdscraper.ParseExtractors(splitList(*c.extractors), strings.Fields(*c.ocrCommand))
+	if err != nil {
+		fatal("Invalid -extractors", "err", err)
+	}
+	maxFileSize, err := sdscraper.ParseByteSize(*c.maxFileSize)
+	if err != nil {
+		fatal("Invalid -max-file-size", "err", err)
+	}
+	if maxFileSize == 0 {
+		maxFileSize = -1 // The library reads zero as its default
+	}
+	maxPrune := *c.maxPrune
+	if !(maxPrune >= 0 && maxPrune <= 1) { // NaN fails too
+		fatal("Invalid -max-prune, want a fraction from 0 to 1", "value", maxPrune)
+	}
+	if maxPrune == 0 {
+		maxPrune = -1 // The library reads zero as its default
+	}
+	maxRunBytes, err := sdscraper.ParseByteSize(*c.maxRunBytes)
+	if err != nil {
+		fatal("Invalid -max-run-bytes", "err", err)
+	}
+	deepCrawl := sdscraper.DeepCrawl{Depth: *c.crawlDepth, MaxPages: *c.crawlMaxPages, MaxPerPattern: *c.crawlMaxPattern}
+	if *c.crawlAllow != "" {
+		allow, err := regexp.Compile(*c.crawlAllow)
+		if err != nil {
+			fatal("Invalid -crawl-allow", "err", err)
+		}
+		deepCrawl.Allow = []*regexp.Regexp{allow}
+	}
+	if *c.crawlDeny != "" {
+		deny, err := regexp.Compile(*c.crawlDeny)
+		if err != nil {
+			fatal("Invalid -crawl-deny", "err", err)
+		}
+		deepCrawl.Deny = []*regexp.Regexp{deny}
+	}
+	deepCrawl.Scope, deepCrawl.Hosts, deepCrawl.Nofollow = splitList(*c.crawlScope), splitList(*c.crawlHosts), *c.crawlNofollow
+
+	scraper := &sdscraper.Scraper{
+		PageURL:         *c.pageURL,                  // Remote web page URL to scrape
+		ExtraPageURLs:   splitList(*c.extraPageURLs), // More listing pages of the same site
+		LinkPattern:     linkPattern,                 // Which links are documents
+		DeepCrawl:       deepCrawl,                   // Product pages behind the listings
+		Sources:         sources,                     // Seed lists and portals
+		CacheFile:       *c.cacheFile,                // Local file name to save HTML
+		Locales:         splitList(*c.locales),       // One listing page per locale
+		ManifestPath:    "manifest.json",             // Local file tracking per-URL download state
+		CatalogDB:       *c.catalogDB,                // Queryable copy of the manifest
+		SearchIndex:     *c.searchIndex,              // Full-text search over the PDFs
+		PrintIndex:      *c.printIndex,               // Binder index
+		PrintIndexLinks: *c.printIndexURL,            // Where its QR codes lead
+		Renderer:        renderer,                    // Chrome unless told otherwise
+		Downloader: &sdscraper.Downloader{ // Fetches and validates each PDF
+			Client:          client,             // Rate-limited, robots-aware client
+			OutputDir:       "PDFs/",            // Directory to store downloaded PDFs
+			RejectDir:       *c.rejectDir,       // Where invalid downloads go
+			StructuralCheck: *c.structuralCheck, // Deep validation is opt-in
+			MaxFileSize:     maxFileSize,        // A runaway link cannot fill the disk
+			MaxRunBytes:     maxRunBytes,        // Nor can a whole run
+			SpoolDir:        *c.spoolDir,        // Bodies wait on disk, not in memory
+			Types:           types,              // Formats besides PDF
+			Extractors:      extractors,         // Metadata read from the documents themselves
+			Bandwidth:       bandwidth,          // Business-hours throttling
+			Digests:         hashers("-digests", *c.digests),
+		},
+		CheckpointPath:      *c.checkpointPath,   // Resume point after Ctrl-C
+		Workers:             *c.workers,          // Network pool size
+		IOWorkers:           *c.ioWorkers,        // Disk pool size
+		WarmUp:              *c.warmUp,           // Pre-resolve and pre-connect hosts
+		CacheTTL:            *c.refresh,          // Listing cache lifetime
+		ForceRefresh:        *c.forceRefresh,     // Ignore the listing cache
+		FilenameTemplate:    *c.filenameTemplate, // Human-readable names for new downloads
+		LocaleManifests:     *c.localeManifests,  // Per-locale views of the manifest
+		TagRules:            c.tagRules.rules,    // Tags from listing metadata
+		Tags:                tagFilter("-tags", *c.tags),
+		PruneTags:           tagFilter("-prune-tags", *c.pruneTags),
+		Features:            features,               // Behaviours being rolled out
+		Prune:               *c.prune,               // Soft-delete unlisted documents
+		AllowAnomalousPrune: *c.allowAnomalousPrune, // Override the anomaly guard
+		AnomalyDrop:         *c.anomalyDrop,         // Anomaly threshold
+		MaxPruneFraction:    maxPrune,               // Mass-deletion guardrail
+		ForcePrune:          *c.forcePrune,          // Override the guardrail
+	}
+	scraper.Downloader.Storage = c.storage() // Nil keeps documents in the output directory
+	if *c.networkInterval > 0 {
+		scraper.Network = &sdscraper.NetworkMonitor{Interval: *c.networkInterval, Probe: cmp.Or(*c.networkProbe, sdscraper.NetworkProbe(*c.pageURL)), Transport: base}
+	}
+	if *c.trashDir != "" {
+		scraper.Downloader.Trash = &sdscraper.Trash{Dir: *c.trashDir, Grace: *c.trashGrace}
+	}
+	if *c.backupDir != "" {
+		scraper.Backup = &sdscraper.BackupPolicy{Dir: *c.backupDir, Keep: *c.backupKeep, MaxAge: *c.backupMaxAge, Trash: scraper.Downloader.Trash}
+	}
+	if *c.metricsAddr != "" {
+		scraper.Downloader.Metrics = sdscraper.NewMetrics()
+	}
+	if *c.webhook != "" {
+		scraper.Notifiers = append(scraper.Notifiers, scopedNotifier(&sdscraper.WebhookNotifier{URL: *c.webhook}, tagFilter("-webhook-tags", *c.webhookTags)))
+	}
+	if *c.slackWebhook != "" {
+		scraper.Notifiers = append(scraper.Notifiers, scopedNotifier(&sdscraper.SlackNotifier{WebhookURL: *c.slackWebhook}, tagFilter("-slack-tags", *c.slackTags)))
+	}
+	if *c.rulesPath != "" {
+		rules, err := loadRules(*c.rulesPath)
+		if err != nil {
+			fatal("Loading -rules failed", "path", *c.rulesPath, "err", err)
+		}
+		scraper.Downloader.Rules = rules
+	}
+	scraper.NotifyFirstSync = *c.notifyFirstSync
+	for _, name := range sortedKeys(c.channels) {
+		scraper.Notifiers = append(scraper.Notifiers, &sdscraper.ChannelNotifier{Channel: name, Notifier: channelNotifier(c.channels[name])})
+	}
+	chrome.Challenges = sdscraper.ChallengePolicy{Wait: *c.challengeWait, ScreenshotDir: *c.challengeScreenshots, CookiesFile: *c.challengeCookies}
+	if chrome.Challenges.Wait > 0 {
+		if client.Jar == nil {
+			client.Jar, _ = cookiejar.New(nil) // The clearance cookie lets downloads past the same check
+		}
+		chrome.Challenges.Jar = client.Jar
+		for _, notifier := range scraper.Notifiers {
+			if notifier, ok := notifier.(sdscraper.ChallengeNotifier); ok {
+				chrome.Challenges.Notifiers = append(chrome.Challenges.Notifiers, notifier)
+			}
+		}
+	}
+	return scraper
+}
+
+// Builds the renderer selected by the -renderer flag
+func newRenderer(name string, listingEndpoints []string, chromeRenderer *sdscraper.ChromeRenderer, client *http.Client, features sdscraper.Features) (sdscraper.Renderer, error) {
+	httpRenderer := &sdscraper.HTTPRenderer{Client: client, ListingEndpoints: listingEndpoints} // No browser needed
+	switch name {
+	case "chrome":
+		return chromeRenderer, nil
+	case "http":
+		if features.Enabled(sdscraper.FeatureBrowserFallback) { // Same chain as auto, opted into per mirror
+			return &sdscraper.FallbackRenderer{Renderers: []sdscraper.Renderer{httpRenderer, chromeRenderer}}, nil
+		}
+		return httpRenderer, nil
+	case "auto":
+		return &sdscraper.FallbackRenderer{Renderers: []sdscraper.Renderer{httpRenderer, chromeRenderer}}, nil
+	}
+	return nil, fmt.Errorf("unknown renderer %q (want chrome, http, or auto)", name)
+}
+
+// Collects repeated -header "Name: value" flags
+type headerFlags http.Header
+
+// String implements flag.Value
+func (h headerFlags) String() string {
+	var pairs []string
+	for name, values := range h {
+		for _, value := range values {
+			pairs = append(pairs, name+": "+value)
+		}
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// Set implements flag.Value
+func (h headerFlags) Set(value string) error {
+	name, headerValue, ok := strings.Cut(value, ":")
+	if name = strings.TrimSpace(name); !ok || name == "" {
+		return fmt.Errorf("want \"Name: value\", got %q", value)
+	}
+	http.Header(h).Add(name, strings.TrimSpace(headerValue))
+	return nil
+}
+
+// pinFlags collects repeated -tls-pin host=pin flags
+type pinFlags struct {
+	sdscraper.CertificatePins
+}
+
+// String implements flag.Value
+func (p *pinFlags) String() string {
+	if p == nil {
+		return ""
+	}
+	var pairs []string
+	for _, host := range sortedKeys(p.Hosts) {
+		for _, pin := range p.Hosts[host] {
+			pairs = append(pairs, host+"="+pin)
+		}
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// Set implements flag.Value
+func (p *pinFlags) Set(value string) error {
+	host, pin, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("want host=sha256/BASE64, got %q", value)
+	}
+	return p.Add(host, pin)
+}
+
+// Limits a notifier to the documents passing a tag filter, if there is one
+func scopedNotifier(notifier sdscraper.Notifier, tags sdscraper.TagFilter) sdscraper.Notifier {
+	if tags.IsZero() {
+		return notifier
+	}
+	return &sdscraper.TaggedNotifier{Notifier: notifier, Tags: tags}
+}
+
+// tagRuleFlags collects repeated -tag-rule flags
+type tagRuleFlags struct {
+	rules []sdscraper.TagRule
+}
+
+// String implements flag.Value
+func (t *tagRuleFlags) String() string {
+	if t == nil {
+		return ""
+	}
+	rules := make([]string, len(t.rules))
+	for i, rule := range t.rules {
+		rules[i] = rule.String()
+	}
+	return strings.Join(rules, ", ")
+}
+
+// Set implements flag.Value
+func (t *tagRuleFlags) Set(value string) error {
+	rule, err := sdscraper.ParseTagRule(value)
+	if err != nil {
+		return err
+	}
+	t.rules = append(t.rules, rule)
+	return nil
+}
+
+// bandwidthFlags collects the repeatable -bandwidth-window flag
+type bandwidthFlags struct {
+	windows []sdscraper.BandwidthWindow
+}
+
+// String implements flag.Value
+func (b *bandwidthFlags) String() string {
+	if b == nil {
+		return ""
+	}
+	windows := make([]string, len(b.windows))
+	for i, window := range b.windows {
+		windows[i] = window.String()
+	}
+	return strings.Join(windows, ", ")
+}
+
+// Set implements flag.Value
+func (b *bandwidthFlags) Set(value string) error {
+	window, err := sdscraper.ParseBandwidthWindow(value)
+	if err != nil {
+		return err
+	}
+	b.windows = append(b.windows, window)
+	return nil
+}
+
+// Returns the schedule of the windows given, nil when there are none
+func (b *bandwidthFlags) schedule() *sdscraper.BandwidthSchedule {
+	if len(b.windows) == 0 {
+		return nil
+	}
+	return &sdscraper.BandwidthSchedule{Windows: b.windows}
+}
+
+// Reads a rules file, whose "rules" key lists the rules in order, in the format its extension names
+func loadRules(path string) (*sdscraper.RuleSet, error) {
+	var file struct {
+		Rules []sdscraper.Rule `json:"rules" yaml:"rules" toml:"rules"`
+	}
+	if err := decodeFile(path, &file); err != nil {
+		return nil, err
+	}
+	return sdscraper.NewRuleSet(file.Rules)
+}
+
+// channelFlags collects repeated -notify-channel flags by channel name
+type channelFlags map[string]string
+
+// String implements flag.Value
+func (c channelFlags) String() string {
+	var pairs []string
+	for _, name := range sortedKeys(c) {
+		pairs = append(pairs, name+"="+c[name])
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// Set implements flag.Value, also accepting "name: url" as config file maps produce
+func (c channelFlags) Set(value string) error {
+	split := strings.IndexAny(value, "=:")
+	if split <= 0 || strings.TrimSpace(value[split+1:]) == "" {
+		return fmt.Errorf("want name=url, got %q", value)
+	}
+	c[strings.TrimSpace(value[:split])] = strings.TrimSpace(value[split+1:])
+	return nil
+}
+
+// Returns the notifier for a channel URL: Slack messages for Slack incoming webhooks, JSON posts otherwise
+func channelNotifier(channelURL string) sdscraper.Notifier {
+	if parsed, err := url.Parse(channelURL); err == nil && parsed.Hostname() == "hooks.slack.com" {
+		return &sdscraper.SlackNotifier{WebhookURL: channelURL}
+	}
+	return &sdscraper.WebhookNotifier{URL: channelURL}
+}
+
+// Returns the proxy the environment configures for HTTPS, so a launched Chrome matches the Go client
+func proxyFromEnvironment() string {
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy"} {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// Registers -storage and the -s3-* flags and returns a function giving the storage they configure after parsing,
+// nil for the local output directory; it exits on an incomplete configuration
+func storageFlags(flags *flag.FlagSet) func() sdscraper.Storage {
+	kind := flags.String("storage", "local", "where downloaded documents are kept: local (PDFs/) or s3")
+	bucket := flags.String("s3-bucket", os.Getenv("SDS_S3_BUCKET"), "bucket for -storage s3 (default $SDS_S3_BUCKET)")
+	prefix := flags.String("s3-prefix", os.Getenv("SDS_S3_PREFIX"), "object key prefix for -storage s3 (default $SDS_S3_PREFIX)")
+	endpoint := flags.String("s3-endpoint", os.Getenv("SDS_S3_ENDPOINT"), "S3-compatible service URL, empty for AWS (default $SDS_S3_ENDPOINT)")
+	region := flags.String("s3-region", "", "signing region (empty to use $AWS_REGION, then us-east-1)")
+	pathStyle := flags.Bool("s3-path-style", false, "address the bucket in the URL path, as MinIO and most S3-compatible stores expect")
+	objectLock := flags.String("s3-object-lock", "", "lock every document revision written to -storage s3 against changes and deletion: governance or compliance (empty for the bucket's default); needs a versioned bucket created with object lock")
+	retention := flags.Duration("s3-retention", 0, "how long -s3-object-lock keeps each revision locked, e.g. 61320h for seven years")
+	return func() sdscraper.Storage {
+		switch *kind {
+		case "local":
+			return nil
+		case "s3":
+		default:
+			fatal("Invalid -storage", "storage", *kind, "want", "local or s3")
+		}
+		if *bucket == "" {
+			fatal("-storage s3 needs -s3-bucket or SDS_S3_BUCKET")
+		}
+		storage := sdscraper.NewS3StorageFromEnv(*bucket, *prefix) // Credentials from AWS_ACCESS_KEY_ID and friends
+		storage.Endpoint = *endpoint
+		storage.Region = cmp.Or(*region, storage.Region)
+		storage.PathStyle = *pathStyle
+		if *objectLock != "" {
+			storage.ObjectLock, storage.Retention = strings.ToUpper(*objectLock), *retention
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			err := storage.CheckObjectLock(ctx) // Refuse to start rather than store revisions unprotected
+			cancel()
+			if err != nil {
+				fatal("-s3-object-lock cannot be honoured", "bucket", *bucket, "err", err)
+			}
+		}
+		return storage
+	}
+}
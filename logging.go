@@ -0,0 +1,116 @@
+package main // Declare main package
+
+import ( // Import required packages
+	"flag"     // For the logging flags
+	"fmt"      // For flag errors
+	"io"       // For copying logs to a file
+	"log/slog" // For structured logging
+	"os"       // For stderr and exit codes
+	"strings"  // For listing rules and splitting the key command
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // Build information
+)
+
+// Redacts logs, JSON reports and support bundles; the built-in rules apply until -redact-defaults=false
+var redactor = &sdscraper.Redactor{Rules: sdscraper.DefaultRedactionRules()}
+
+// Registers -log-level, -log-format, -log-file, the redaction flags, -read-only and the encryption key flags and
+// returns a function that installs the chosen logger, redactor, mode and key after parsing
+func logFlags(flags *flag.FlagSet) func() {
+	level := flags.String("log-level", "info", "minimum level logged: debug, info, warn or error")
+	format := flags.String("log-format", "text", "log output format: text or json")
+	file := flags.String("log-file", os.Getenv("SDS_LOG_FILE"), "also append logs to this file, e.g. for support-bundle (default $SDS_LOG_FILE)")
+	defaults := flags.Bool("redact-defaults", true, "redact tokens, cookies, passwords and secret query parameters in logs, reports and support bundles")
+	rules := &redactFlags{}
+	flags.Var(rules, "redact", "regular expression whose matches (or first group) are redacted from logs, reports and support bundles; repeatable")
+	hosts := flags.String("redact-hosts", "", "comma-separated internal domains whose host names are redacted, e.g. corp.example.com")
+	readOnly := flags.Bool("read-only", os.Getenv("SDS_READ_ONLY") != "", "refuse every change to files and remote storage, printing reports to stdout only, e.g. for audits of the production archive (default $SDS_READ_ONLY)")
+	keyFile := flags.String("encryption-key", os.Getenv("SDS_ENCRYPTION_KEY_FILE"), "file holding a 32-byte AES-256 key, as hex or base64, to encrypt stored documents and the manifest with AES-GCM and read them back transparently; plaintext files are refused, so encrypt an existing archive with rekey first; -catalog-db and -search-index, which would be plaintext copies, are refused (default $SDS_ENCRYPTION_KEY_FILE)")
+	keyCommand := flags.String("encryption-key-command", os.Getenv("SDS_ENCRYPTION_KEY_COMMAND"), "command printing the encryption key instead, e.g. a KMS client decrypting a wrapped data key (default $SDS_ENCRYPTION_KEY_COMMAND)")
+	return func() {
+		sdscraper.SetReadOnly(*readOnly)         // Before anything below or after could write
+		if *keyFile != "" || *keyCommand != "" { // Before anything reads or writes stored files
+			key, err := sdscraper.LoadEncryptionKey(*keyFile, strings.Fields(*keyCommand))
+			if err == nil {
+				err = sdscraper.SetEncryptionKey(key)
+			}
+			if err != nil {
+				fmt.Fprintf(flags.Output(), "invalid -encryption-key: %v\n", err)
+				os.Exit(2)
+			}
+		}
+		redactor.Rules = nil
+		if *defaults {
+			redactor.Rules = sdscraper.DefaultRedactionRules()
+		}
+		redactor.Rules = append(redactor.Rules, rules.rules...)
+		for _, host := range splitList(*hosts) {
+			redactor.Rules = append(redactor.Rules, sdscraper.HostRedactionRule(host))
+		}
+		var minLevel slog.Level
+		if err := minLevel.UnmarshalText([]byte(*level)); err != nil {
+			fmt.Fprintf(flags.Output(), "invalid -log-level %q\n", *level)
+			os.Exit(2) // Same status as other flag errors
+		}
+		options := &slog.HandlerOptions{Level: minLevel}
+		var output io.Writer = os.Stderr
+		if *file != "" {
+			if *readOnly {
+				fmt.Fprintln(flags.Output(), "-log-file appends to a file, which -read-only forbids")
+				os.Exit(2)
+			}
+			logFile, err := os.OpenFile(*file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+			if err != nil {
+				fmt.Fprintf(flags.Output(), "invalid -log-file: %v\n", err)
+				os.Exit(2)
+			}
+			output = io.MultiWriter(os.Stderr, logFile) // Left open until the process exits
+		}
+		var handler slog.Handler
+		switch *format {
+		case "text":
+			handler = slog.NewTextHandler(output, options)
+		case "json": // One object per line for log shippers
+			handler = slog.NewJSONHandler(output, options)
+		default:
+			fmt.Fprintf(flags.Output(), "invalid -log-format %q (want text or json)\n", *format)
+			os.Exit(2)
+		}
+		slog.SetDefault(slog.New(redactor.Handler(handler))) // Also routes the standard log package
+		info := sdscraper.Build()
+		slog.Info("Starting", "version", info.Version, "commit", info.Commit, "date", info.Date) // Header line telling support which binary logged
+	}
+}
+
+// Collects repeated -redact patterns
+type redactFlags struct {
+	rules []sdscraper.RedactionRule
+}
+
+// String implements flag.Value
+func (r *redactFlags) String() string {
+	if r == nil {
+		return ""
+	}
+	names := make([]string, len(r.rules))
+	for i, rule := range r.rules {
+		names[i] = rule.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// Set implements flag.Value
+func (r *redactFlags) Set(value string) error {
+	rule, err := sdscraper.ParseRedactionRule(value)
+	if err != nil {
+		return err
+	}
+	r.rules = append(r.rules, rule)
+	return nil
+}
+
+// Logs an error and exits with status 1
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
@@ -1,252 +1,305 @@
 package main // Declare main package
 
 import ( // Import required packages
-	"bytes"         // For in-memory byte buffer
-	"context"       // For managing context (timeouts, cancellations)
-	"io"            // For input/output utilities
-	"log"           // For logging errors/info
-	"net/http"      // For HTTP client
-	"net/url"       // For URL parsing and manipulation
-	"os"            // For file and directory handling
-	"path/filepath" // For OS-independent path operations
-	"regexp"        // For regular expressions
-	"strings"       // For string manipulation
-	"time"          // For timing and delays
-
-	"github.com/chromedp/chromedp" // For headless browser automation using Chrome
+	"cmp"            // For an empty config
+	"context"        // For managing context (timeouts, cancellations)
+	"errors"         // For matching cancellation
+	"flag"           // For parsing command-line flags
+	"fmt"            // For error messages
+	"io"             // For output writers
+	"log/slog"       // For structured logging
+	"os"             // For command-line arguments
+	"os/signal"      // For graceful shutdown
+	"strings"        // For splitting list flags
+	"syscall"        // For SIGTERM
+	"text/tabwriter" // For aligned tables
+	"time"           // For watch intervals
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // Scraping, downloading and serving
 )
 
 func main() {
-	remoteURL := "https://www.gojo.com/en/SDS" // Remote web page URL to scrape
-	localFileName := "gojo.html"               // Local file name to save HTML
-	outputFolder := "PDFs/"                    // Directory to store downloaded PDFs
-
-	if !directoryExists(outputFolder) { // Check if output folder exists
-		createDirectory(outputFolder, 0o755) // If not, create it with permission
-	}
-
-	if !fileExists(localFileName) { // If local HTML file doesn't exist
-		remoteHTML := scrapePageHTMLWithChrome(remoteURL) // Scrape page using headless Chrome
-		appendAndWriteToFile(localFileName, remoteHTML)   // Save scraped HTML to file
-	}
-
-	localFileContent := readAFileAsString(localFileName)                   // Read saved HTML content
-	extractedLocalPDFURL := extractPDFLinks(localFileContent)              // Extract all PDF links
-	extractedLocalPDFURL = removeDuplicatesFromSlice(extractedLocalPDFURL) // Remove duplicates
-
-	for _, urls := range extractedLocalPDFURL { // Loop through each PDF URL
-		if isUrlValid(urls) { // Check if URL is valid
-			downloadPDF(urls, outputFolder) // Download the PDF
+	if len(os.Args) > 1 { // Subcommands; plain flags mean a crawl
+		switch os.Args[1] {
+		case "serve": // Serve the mirror over HTTP instead of crawling
+			runServe(os.Args[2:])
+			return
+		case "catalog": // Inspect and edit catalog entries
+			runCatalog(os.Args[2:])
+			return
+		case "restore": // Roll state files back to a backup
+			runRestore(os.Args[2:])
+			return
+		case "fsck": // Cross-check manifest, checkpoint and files
+			runFsck(os.Args[2:])
+			return
+		case "verify": // Audit the mirror against the manifest and its sources
+			runVerify(os.Args[2:])
+			return
+		case "rekey": // Re-encrypt the archive under a new key
+			runRekey(os.Args[2:])
+			return
+		case "digest": // Record further digests of stored documents
+			runDigest(os.Args[2:])
+			return
+		case "init": // Write a config file by answering questions
+			runInit(os.Args[2:])
+			return
+		case "vendors": // List the built-in site templates
+			runVendors(os.Args[2:])
+			return
+		case "tls-pins": // Print the public key pins hosts present
+			runTLSPins(os.Args[2:])
+			return
+		case "doctor": // Check the environment a crawl needs
+			runDoctor(os.Args[2:])
+			return
+		case "support-bundle": // Zip what maintainers need for an issue
+			runSupportBundle(os.Args[2:])
+			return
+		case "test-corpus": // Write synthetic PDFs for validating a deployment
+			runTestCorpus(os.Args[2:])
+			return
+		case "selftest": // Crawl a bundled mock site end to end
+			runSelftest(os.Args[2:])
+			return
+		case "manifest": // Compare manifests
+			runManifest(os.Args[2:])
+			return
+		case "runs": // List and compare past runs
+			runRuns(os.Args[2:])
+			return
+		case "snapshot": // Freeze the catalog under a name
+			runSnapshot(os.Args[2:])
+			return
+		case "export": // Package the mirror into one archive
+			runExport(os.Args[2:])
+			return
+		case "search": // Find the documents mentioning a term
+			runSearch(os.Args[2:])
+			return
+		case "config-reference": // Print every config file setting
+			runConfigReference(os.Args[2:])
+			return
+		case "version": // Print the build
+			runVersion(os.Args[2:])
+			return
+		case "self-update": // Replace this binary with the latest signed release
+			runSelfUpdate(os.Args[2:])
+			return
+		case "build-release": // Cross-compile the release binaries and their checksums
+			runBuildRelease(os.Args[2:])
+			return
 		}
 	}
-}
 
-// Writes the given content to file, appending if file already exists
-func appendAndWriteToFile(path string, content string) {
-	filePath, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644) // Open or create file
-	if err != nil {
-		log.Println(err) // Exit if error
-	}
-	_, err = filePath.WriteString(content + "\n") // Write content to file
-	if err != nil {
-		log.Println(err)
-	}
-	err = filePath.Close() // Close the file
-	if err != nil {
-		log.Println(err)
-	}
+	crawlCommand(flag.CommandLine)(os.Args[1:])
 }
 
-// Uses headless Chrome via chromedp to get fully rendered HTML from a page
-func scrapePageHTMLWithChrome(pageURL string) string {
-	log.Println("Scraping:", pageURL) // Log page being scraped
-
-	options := append(chromedp.DefaultExecAllocatorOptions[:], // Chrome options
-		chromedp.Flag("headless", false),              // Run visible (set to true for headless)
-		chromedp.Flag("disable-gpu", true),            // Disable GPU
-		chromedp.WindowSize(1920, 1080),               // Set window size
-		chromedp.Flag("no-sandbox", true),             // Disable sandbox
-		chromedp.Flag("disable-setuid-sandbox", true), // Fix for Linux environments
-	)
-
-	allocatorCtx, cancelAllocator := chromedp.NewExecAllocator(context.Background(), options...) // Allocator context
-	ctxTimeout, cancelTimeout := context.WithTimeout(allocatorCtx, 5*time.Minute)                // Set timeout
-	browserCtx, cancelBrowser := chromedp.NewContext(ctxTimeout)                                 // Create Chrome context
-
-	defer func() { // Ensure all contexts are cancelled
-		cancelBrowser()
-		cancelTimeout()
-		cancelAllocator()
-	}()
-
-	var pageHTML string // Placeholder for output
-	err := chromedp.Run(browserCtx,
-		chromedp.Navigate(pageURL),            // Navigate to the URL
-		chromedp.OuterHTML("html", &pageHTML), // Extract full HTML
-	)
-	if err != nil {
-		log.Println(err) // Log error
-		return ""        // Return empty string on failure
+// Registers the crawl command's flags on flags and returns the command, which parses args and runs the crawl; the
+// flags alone serve the config reference
+func crawlCommand(flags *flag.FlagSet) func(args []string) {
+	applyConfig := configFlags(flags) // -config and -profile
+	setupLogging := logFlags(flags)   // -log-level and -log-format
+	jsonOutput := formatFlags(flags)  // -format
+	crawl := registerCrawlFlags(flags)
+	outputDir := flags.String("output", "PDFs/", "directory downloaded PDFs are written to")
+	manifestPath := flags.String("manifest", "manifest.json", "path of the manifest file")
+	deleteRetention := flags.Duration("delete-retention", sdscraper.DefaultDeleteRetention, "how long soft-deleted documents stay restorable before they are purged")
+	dryRun := flags.Bool("dry-run", false, "scrape and list planned downloads without fetching any documents")
+	watchMode := flags.Bool("watch", false, "keep running, re-crawling on -watch-interval or -watch-cron and logging what changed")
+	watchInterval := flags.Duration("watch-interval", 6*time.Hour, "time between watch cycles")
+	watchCron := flags.String("watch-cron", "", "cron expression for watch cycles, e.g. \"0 3 * * *\"; overrides -watch-interval")
+	watchOverlap := flags.String("watch-overlap", "queue-one", "when a cycle is due while the previous one still runs: skip, queue-one or abort-previous")
+	soakCycles := flags.Int("soak-cycles", 0, "internal soak test: run this many sync cycles back to back and fail if goroutines, open files or the heap keep growing between them")
+	soakInterval := flags.Duration("soak-interval", 0, "pause between -soak-cycles cycles")
+	vendors := flags.String("vendors", "", "comma-separated built-in site templates to crawl as sites besides the config file's, e.g. ecolab,scjp; the vendors command lists them")
+	unifiedManifest := flags.String("unified-manifest", "", "after a crawl of the config file's sites, merge their manifests into this one catalog over -output, with each document's vendor and cross-vendor duplicates marked, for serve, export and fsck across vendors (empty skips)")
+	onlySites := flags.String("sites", "", "comma-separated names of the config file's sites to crawl (default all)")
+	reason := flags.String("reason", "", "why this run is being started, e.g. \"supplier announced revised sheets\"; kept with the run in the history runs list shows, so off-schedule syncs explain themselves")
+	reportPath := flags.String("report", "", "also write the run's JSON report to this file, whatever -format says")
+	var assertions sdscraper.Assertions // Checked for each site of a config file
+	flags.IntVar(&assertions.MinDocuments, "assert-min-documents", 0, "exit with status 4 unless the catalog holds at least this many live documents after the run, so a pipeline can hold back publishing a short archive (0 disables)")
+	flags.BoolVar(&assertions.NoFailures, "assert-no-failures", false, "exit with status 4 if any download failed, e.g. a truncated or invalid PDF")
+	flags.DurationVar(&assertions.MaxStaleness, "assert-max-staleness", 0, "exit with status 4 if a live document's source was last checked longer ago than this, e.g. 48h (0 disables)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s [flags]\n", os.Args[0])
+		flags.PrintDefaults()
+		fmt.Fprintln(flags.Output(), "\nexit status: 0 success, 1 run failed, 2 invalid flags, 3 some downloads failed, 4 an -assert-* threshold was missed, 130 interrupted")
 	}
+	return func(args []string) {
+		flags.Parse(args) // Exits on invalid flags
+		config := applyConfig()
+		setupLogging()
+		asJSON := jsonOutput()
+		if sdscraper.ReadOnly() && (!*dryRun || *watchMode) {
+			fatal("a crawl writes the mirror and its state, which -read-only forbids; add -dry-run to only list what it would do")
+		}
+		if assertions.Enabled() && (*dryRun || *watchMode || *soakCycles > 0) {
+			fatal("-assert-* judge the catalog a single run leaves behind; drop -dry-run, -watch and -soak-cycles")
+		}
 
-	return pageHTML // Return scraped HTML
-}
-
-// Extracts all PDF URLs from the given HTML content
-func extractPDFLinks(htmlContent string) []string {
-	pdfRegex := regexp.MustCompile(`https?://[^\s"'<>]+?\.pdf(?:\?[^\s"'<>]*)?`) // Regex for PDF URLs
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM) // Ctrl-C stops cleanly
+		defer stop()
 
-	seen := make(map[string]struct{}) // To keep track of seen URLs
-	var links []string                // Slice to store unique URLs
+		if *vendors != "" {
+			config = cmp.Or(config, &configFile{})
+			config.Sites = append(config.Sites, vendorSites(splitList(*vendors))...)
+		}
+		if config != nil && len(config.Sites) > 0 { // Several sites instead of the single -page-url crawl
+			if *watchMode {
+				fatal("-watch does not support config files with sites yet; schedule the command instead")
+			}
+			if *soakCycles > 0 {
+				fatal("-soak-cycles soaks a single -page-url crawl, not config file sites")
+			}
+			sites, err := configuredSites(config, flags, *outputDir, *manifestPath, splitList(*onlySites))
+			if err != nil {
+				fatal("Invalid sites in the config file", "err", err)
+			}
+			for _, site := range sites {
+				site.scraper.Reason = *reason
+			}
+			crawlSites(ctx, sites, *dryRun, *deleteRetention, asJSON, *reportPath, unifiedCatalog{manifestPath: *unifiedManifest, outputDir: *outputDir}, assertions)
+			return
+		}
+		if *onlySites != "" || *unifiedManifest != "" {
+			fatal("-sites and -unified-manifest need a config file with sites, or -vendors")
+		}
 
-	for _, line := range strings.Split(htmlContent, "\n") { // Process line by line
-		for _, match := range pdfRegex.FindAllString(line, -1) { // Find all matches
-			if _, ok := seen[match]; !ok { // If not already seen
-				seen[match] = struct{}{}     // Mark as seen
-				links = append(links, match) // Add to list
+		scraper := crawl.scraper()
+		scraper.Downloader.OutputDir = *outputDir // Output layout
+		scraper.ManifestPath = *manifestPath
+		scraper.DryRun = *dryRun                   // Preview only
+		scraper.DeleteRetention = *deleteRetention // Purge window for soft deletes
+		scraper.Reason = *reason                   // Recorded in the run history
+		scraper.Pause = &sdscraper.PauseControl{}  // SIGUSR1 holds the downloads, SIGUSR2 lets them go on
+		pauseOnSignals(ctx, scraper.Pause)
+
+		if *watchMode {
+			if *soakCycles > 0 {
+				fatal("-soak-cycles runs its own cycles; drop -watch")
+			}
+			if *reason != "" {
+				fatal("-reason annotates a run started by hand; -watch cycles follow the schedule")
+			}
+			if *crawl.metricsAddr != "" {
+				serveMetrics(ctx, *crawl.metricsAddr, scraper.Downloader.Metrics)
+			}
+			policy, err := sdscraper.ParseOverlapPolicy(*watchOverlap)
+			if err != nil {
+				fatal("Invalid -watch-overlap", "err", err)
 			}
+			next := every(*watchInterval)
+			if *watchCron != "" {
+				schedule, err := sdscraper.ParseCron(*watchCron)
+				if err != nil {
+					fatal("Invalid -watch-cron", "err", err)
+				}
+				next = schedule.Next
+			}
+			watch(ctx, syncQueue(ctx, scraper, policy, asJSON), next)
+			slog.Info("Watch stopped")
+			return
 		}
-	}
 
-	return links // Return list of PDF URLs
-}
+		if *soakCycles > 0 {
+			if *dryRun {
+				fatal("-soak-cycles runs real sync cycles; drop -dry-run")
+			}
+			soak(ctx, scraper, *soakCycles, *soakInterval, asJSON)
+			return
+		}
+		if *crawl.metricsAddr != "" {
+			fatal("-metrics-addr needs -watch or the serve command; a single run exits before it could be scraped")
+		}
+		result, err := scraper.Run(ctx) // Scrape and download
+		report := newCrawlReport(result, *dryRun, err)
+		passed := err != nil || report.assert(assertions, result) // A failed run exits for itself
+		if asJSON {
+			printJSON(report)
+		}
+		if *reportPath != "" {
+			writeJSONReport(*reportPath, report)
+		}
+		if errors.Is(err, context.Canceled) {
+			slog.Info("Stopped; progress was saved and the next run resumes")
+			os.Exit(130) // Conventional exit status for SIGINT
+		}
+		if err != nil {
+			fatal("Run failed", "err", err)
+		}
+		switch {
+		case asJSON: // The report was the output
+		case *dryRun:
+			printPlan(os.Stdout, result.Planned)
+		default:
+			printSummary(os.Stdout, result)
+		}
+		if !passed {
+			os.Exit(exitAssertionsFailed)
+		}
+		if len(result.Failed) > 0 {
+			os.Exit(exitFailedDownloads)
+		}
 
-// Converts a URL to a filesystem-safe file name
-func urlToFilename(rawURL string) string {
-	parsed, err := url.Parse(rawURL) // Parse the URL
-	if err != nil {
-		log.Println(err)
-		return ""
 	}
-	filename := parsed.Host // Start with host
-	if parsed.Path != "" {
-		filename += "_" + strings.ReplaceAll(parsed.Path, "/", "_") // Add path
-	}
-	if parsed.RawQuery != "" {
-		filename += "_" + strings.ReplaceAll(parsed.RawQuery, "&", "_") // Add query
-	}
-	invalidChars := []string{`"`, `\`, `/`, `:`, `*`, `?`, `<`, `>`, `|`, `-`} // Invalid filename characters
-	for _, char := range invalidChars {
-		filename = strings.ReplaceAll(filename, char, "_") // Replace with underscore
-	}
-	if getFileExtension(filename) != ".pdf" { // Ensure .pdf extension
-		filename += ".pdf"
-	}
-	return strings.ToLower(filename) // Return lowercased name
 }
 
-// Reads an entire file as string
-func readAFileAsString(path string) string {
-	content, err := os.ReadFile(path) // Read file
-	if err != nil {
-		log.Println(err)
+// Prints a dry run's planned actions as an aligned table
+func printPlan(w io.Writer, planned []sdscraper.PlannedDownload) {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "ACTION\tFILENAME\tURL")
+	counts := make(map[string]int) // Totals per action
+	for _, item := range planned {
+		fmt.Fprintf(table, "%s\t%s\t%s\n", item.Action, item.Filename, item.URL)
+		counts[item.Action]++
 	}
-	return string(content) // Return content as string
+	table.Flush()
+	fmt.Fprintf(w, "\n%d to download, %d to refresh, %d skipped\n", counts[sdscraper.PlanDownload], counts[sdscraper.PlanRefresh], counts[sdscraper.PlanSkip])
 }
 
-// Downloads a PDF and saves it to the output directory
-func downloadPDF(finalURL, outputDir string) bool {
-	filename := urlToFilename(finalURL)            // Create safe file name
-	filePath := filepath.Join(outputDir, filename) // Full path
-
-	if fileExists(filePath) { // Skip if file already exists
-		log.Printf("File already exists, skipping: %s", filePath)
-		return false
-	}
-
-	client := &http.Client{Timeout: 30 * time.Second} // Create HTTP client
-
-	resp, err := client.Get(finalURL) // Make GET request
-	if err != nil {
-		log.Printf("Failed to download %s: %v", finalURL, err)
-		return false
-	}
-	defer resp.Body.Close() // Ensure response body is closed
-
-	if resp.StatusCode != http.StatusOK { // Check for 200 OK
-		log.Printf("Download failed for %s: %s", finalURL, resp.Status)
-		return false
-	}
-
-	contentType := resp.Header.Get("Content-Type") // Check Content-Type
-	if !strings.Contains(contentType, "application/pdf") {
-		log.Printf("Invalid content type for %s: %s (expected application/pdf)", finalURL, contentType)
-		return false
-	}
-
-	var buf bytes.Buffer                     // Temporary buffer
-	written, err := io.Copy(&buf, resp.Body) // Read response body
-	if err != nil {
-		log.Printf("Failed to read PDF data from %s: %v", finalURL, err)
-		return false
-	}
-	if written == 0 {
-		log.Printf("Downloaded 0 bytes for %s; not creating file", finalURL)
-		return false
-	}
-
-	out, err := os.Create(filePath) // Create file on disk
-	if err != nil {
-		log.Printf("Failed to create file for %s: %v", finalURL, err)
-		return false
-	}
-	defer out.Close()
-
-	if _, err := buf.WriteTo(out); err != nil { // Write buffer to file
-		log.Printf("Failed to write PDF to file for %s: %v", finalURL, err)
-		return false
+// Splits a comma-separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
-
-	log.Printf("Successfully downloaded %d bytes: %s → %s", written, finalURL, filePath)
-	return true
+	return items
 }
 
-// Checks if a file exists
-func fileExists(filename string) bool {
-	info, err := os.Stat(filename) // Get file info
+// Parses a tag filter flag, exiting on an invalid tag
+func tagFilter(name, value string) sdscraper.TagFilter {
+	filter, err := sdscraper.ParseTagFilter(value)
 	if err != nil {
-		return false // Doesn't exist
+		fatal("Invalid "+name, "err", err)
 	}
-	return !info.IsDir() // Return true if it's a file
+	return filter
 }
 
-// Checks if a directory exists
-func directoryExists(path string) bool {
-	directory, err := os.Stat(path) // Get file info
-	if err != nil {
-		return false // Doesn't exist
+// Loads the -facility template from the -stamp-templates file, exiting on errors; nil without -facility
+func stampTemplate(path, facility string) *sdscraper.StampTemplate {
+	if facility == "" {
+		return nil
 	}
-	return directory.IsDir() // Return true if it's a directory
-}
-
-// Creates a directory with given permission
-func createDirectory(path string, permission os.FileMode) {
-	err := os.Mkdir(path, permission) // Try to create directory
+	template, err := sdscraper.LoadStampTemplate(path, facility)
 	if err != nil {
-		log.Println(err)
+		fatal("Loading the stamp template failed", "facility", facility, "err", err)
 	}
+	return template
 }
 
-// Gets file extension
-func getFileExtension(path string) string {
-	return filepath.Ext(path) // Return file extension
-}
-
-// Checks if a URL is valid
-func isUrlValid(uri string) bool {
-	_, err := url.ParseRequestURI(uri) // Try to parse URI
-	return err == nil                  // True if parsing succeeded
-}
-
-// Removes duplicate entries from a string slice
-func removeDuplicatesFromSlice(slice []string) []string {
-	check := make(map[string]bool) // Map to track seen strings
-	var newReturnSlice []string    // Slice to store unique values
-	for _, content := range slice {
-		if !check[content] {
-			check[content] = true
-			newReturnSlice = append(newReturnSlice, content)
+// Parses a comma-separated list of hash algorithms, exiting on an unknown one
+func hashers(name, value string) []sdscraper.Hasher {
+	var list []sdscraper.Hasher
+	for _, algorithm := range splitList(value) {
+		hasher, err := sdscraper.LookupHasher(algorithm)
+		if err != nil {
+			fatal("Invalid "+name, "err", err)
 		}
+		list = append(list, hasher)
 	}
-	return newReturnSlice
+	return list
 }
@@ -0,0 +1,219 @@
+package main // Declare main package
+
+import ( // Import required packages
+	"encoding/json"  // For machine-readable output
+	"flag"           // For the format flag
+	"fmt"            // For flag errors
+	"io"             // For summary writers
+	"log/slog"       // For logging missed assertions
+	"os"             // For stdout and exit codes
+	"text/tabwriter" // For aligned summaries
+	"time"           // For rounding durations and judging staleness
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // Run results
+)
+
+// Registers -format and returns a function reporting, after parsing, whether results should be printed as JSON
+func formatFlags(flags *flag.FlagSet) func() bool {
+	format := flags.String("format", "text", "result output on stdout: text, or json with a stable schema for scripts")
+	return func() bool {
+		switch *format {
+		case "text":
+			return false
+		case "json":
+			return true
+		}
+		fmt.Fprintf(flags.Output(), "invalid -format %q (want text or json)\n", *format)
+		os.Exit(2) // Same status as other flag errors
+		return false
+	}
+}
+
+// Writes v to stdout as one indented JSON document, redacted
+func printJSON(v any) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err == nil {
+		_, err = os.Stdout.Write(append(redactor.JSON(data), '\n'))
+	}
+	if err != nil {
+		fatal("Writing JSON output failed", "err", err)
+	}
+}
+
+// Writes v to stdout as a single line of JSON, redacted, for streams of documents such as watch cycles
+func printJSONLine(v any) {
+	data, err := json.Marshal(v)
+	if err == nil {
+		_, err = os.Stdout.Write(append(redactor.JSON(data), '\n'))
+	}
+	if err != nil {
+		fatal("Writing JSON output failed", "err", err)
+	}
+}
+
+// Exit status of a crawl that finished but could not download some documents, distinct from 1 for a failed run
+const exitFailedDownloads = 3
+
+// Exit status of a crawl that finished but missed an -assert-* threshold, so pipelines can hold back publishing
+const exitAssertionsFailed = 4
+
+// Returns the running build for a report's build field
+func build() *sdscraper.BuildInfo {
+	info := sdscraper.Build()
+	return &info
+}
+
+// Schema versions of the JSON documents; a field is only ever added, a breaking change bumps the version
+const (
+	crawlSchema           = "gojo.crawl/v1"
+	sitesSchema           = "gojo.sites/v1"
+	fsckSchema            = "gojo.fsck/v1"
+	catalogSchema         = "gojo.catalog/v1"
+	backupsSchema         = "gojo.backups/v1"
+	diffSchema            = "gojo.manifest-diff/v1"
+	catalogQuerySchema    = "gojo.catalog-query/v1"
+	versionSchema         = "gojo.version/v1"
+	searchSchema          = "gojo.search/v1"
+	exportSchema          = "gojo.export/v1"
+	verifySchema          = "gojo.verify/v1"
+	doctorSchema          = "gojo.doctor/v1"
+	bundleSchema          = "gojo.support-bundle/v1"
+	corpusSchema          = "gojo.test-corpus/v1"
+	selftestSchema        = "gojo.selftest/v1"
+	configReferenceSchema = "gojo.config-reference/v1"
+	runsSchema            = "gojo.runs/v1"
+	runsCompareSchema     = "gojo.runs-compare/v1"
+	releaseSchema         = "gojo.build-release/v1"
+	rekeySchema           = "gojo.rekey/v1"
+	digestSchema          = "gojo.digest/v1"
+	soakSchema            = "gojo.soak/v1"
+	tlsPinsSchema         = "gojo.tls-pins/v1"
+	vendorsSchema         = "gojo.vendors/v1"
+	snapshotSchema        = "gojo.snapshot/v1"
+)
+
+// crawlReport is the JSON form of one crawl's result
+type crawlReport struct {
+	Schema        string               `json:"schema"`
+	Build         *sdscraper.BuildInfo `json:"build,omitempty"` // Absent inside a sites report
+	Site          string               `json:"site,omitempty"`  // Config file site, empty for a single crawl
+	DryRun        bool                 `json:"dry_run"`
+	Error         string               `json:"error,omitempty"` // Why the run stopped early
+	Discovered    []string             `json:"discovered"`
+	Downloaded    []string             `json:"downloaded"`
+	NotModified   []string             `json:"not_modified"`
+	Aliased       []string             `json:"aliased"`
+	Skipped       []string             `json:"skipped"` // Disallowed by robots.txt or soft-deleted
+	Added         []string             `json:"added"`
+	Updated       []string             `json:"updated"`
+	Removed       []string             `json:"removed"`
+	Pruned        []string             `json:"pruned"`
+	PruneHeld     string               `json:"prune_held,omitempty"`
+	Failed        map[string]string    `json:"failed"` // URL to error message
+	Confidence    float64              `json:"confidence"`
+	Anomalies     []string             `json:"anomalies"`
+	LowConfidence []string             `json:"low_confidence"`     // Documents with metadata worth reviewing
+	Planned       []plannedReport      `json:"planned,omitempty"`  // Dry runs only
+	Bytes         int64                `json:"bytes"`              // Size of the documents received
+	Baseline      bool                 `json:"baseline,omitempty"` // First sync into an empty archive, summarised to notifiers
+	Reason        string               `json:"reason,omitempty"`   // Why an operator started the run
+	Elapsed       float64              `json:"elapsed_seconds"`
+	Assertions    []string             `json:"assertions_failed,omitempty"` // -assert-* thresholds the run missed
+}
+
+// plannedReport is one dry-run action
+type plannedReport struct {
+	Action   string `json:"action"`
+	Filename string `json:"filename"`
+	URL      string `json:"url"`
+}
+
+// Builds the report of a finished run; result may be nil when the run failed before producing one
+func newCrawlReport(result *sdscraper.Result, dryRun bool, err error) crawlReport {
+	report := crawlReport{Schema: crawlSchema, Build: build(), DryRun: dryRun, Failed: map[string]string{}}
+	if err != nil {
+		report.Error = err.Error()
+	}
+	if result == nil {
+		return report
+	}
+	report.Discovered, report.Downloaded, report.NotModified = result.Discovered, result.Downloaded, result.NotModified
+	report.Aliased, report.Skipped, report.Added, report.Updated = result.Aliased, result.Skipped, result.Added, result.Updated()
+	report.Bytes, report.Elapsed, report.Baseline, report.Reason = result.Bytes, result.Elapsed.Seconds(), result.Baseline, result.Reason
+	report.Removed, report.Pruned, report.PruneHeld = result.Removed, result.Pruned, result.PruneHeld
+	report.Confidence, report.Anomalies, report.LowConfidence = result.Confidence, result.Anomalies, result.LowConfidence
+	for documentURL, err := range result.Failed {
+		report.Failed[documentURL] = err.Error()
+	}
+	for _, item := range result.Planned {
+		report.Planned = append(report.Planned, plannedReport{Action: item.Action, Filename: item.Filename, URL: item.URL})
+	}
+	for _, list := range []*[]string{&report.Discovered, &report.Downloaded, &report.NotModified, &report.Aliased, &report.Skipped, // Empty lists, never null
+		&report.Added, &report.Updated, &report.Removed, &report.Pruned, &report.Anomalies, &report.LowConfidence} {
+		if *list == nil {
+			*list = []string{}
+		}
+	}
+	return report
+}
+
+// Checks a finished run against the -assert-* thresholds, recording and logging each one it missed; reports whether
+// the run passed
+func (r *crawlReport) assert(assertions sdscraper.Assertions, result *sdscraper.Result) bool {
+	r.Assertions = assertions.Check(result, time.Now())
+	logger := slog.Default()
+	if r.Site != "" {
+		logger = logger.With("site", r.Site)
+	}
+	for _, violation := range r.Assertions {
+		logger.Error("Assertion failed", "assertion", violation)
+	}
+	return len(r.Assertions) == 0
+}
+
+// Writes v as indented, redacted JSON to path, replacing the file
+func writeJSONReport(path string, v any) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err == nil {
+		err = sdscraper.CheckWritable("write", path)
+	}
+	if err == nil {
+		err = os.WriteFile(path, append(redactor.JSON(data), '\n'), 0o644)
+	}
+	if err != nil {
+		fatal("Writing the report failed", "path", path, "err", err)
+	}
+}
+
+// Prints the end-of-run summary of a crawl
+func printSummary(w io.Writer, result *sdscraper.Result) {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if result.Reason != "" {
+		fmt.Fprintf(table, "Reason\t%s\n", result.Reason)
+	}
+	fmt.Fprintf(table, "Discovered\t%d\n", len(result.Discovered))
+	fmt.Fprintf(table, "Downloaded\t%d\t%s\n", len(result.Downloaded)+len(result.Aliased), formatBytes(result.Bytes))
+	fmt.Fprintf(table, "Not modified\t%d\n", len(result.NotModified))
+	fmt.Fprintf(table, "Skipped\t%d\n", len(result.Skipped))
+	fmt.Fprintf(table, "Failed\t%d\n", len(result.Failed))
+	if len(result.LowConfidence) > 0 {
+		fmt.Fprintf(table, "Low-confidence metadata\t%d\tcatalog review lists them\n", len(result.LowConfidence))
+	}
+	fmt.Fprintf(table, "Elapsed\t%s\n", result.Elapsed.Round(time.Millisecond))
+	table.Flush()
+	for _, documentURL := range sortedKeys(result.Failed) {
+		fmt.Fprintf(w, "  failed %s: %v\n", documentURL, result.Failed[documentURL])
+	}
+}
+
+// Formats a byte count with a binary unit, e.g. "1.5 MiB"
+func formatBytes(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, unit := float64(n)/1024, 0
+	for value >= 1024 && unit < 3 {
+		value, unit = value/1024, unit+1
+	}
+	return fmt.Sprintf("%.1f %s", value, []string{"KiB", "MiB", "GiB", "TiB"}[unit])
+}
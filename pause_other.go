@@ -0,0 +1,12 @@
+//go:build !unix
+
+package main // Declare main package
+
+import ( // Import required packages
+	"context" // For the shared signature
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // The pause control
+)
+
+// Does nothing: this platform has no SIGUSR1 or SIGUSR2, so serve's /sync/pause endpoints are the only control
+func pauseOnSignals(context.Context, *sdscraper.PauseControl) {}
@@ -0,0 +1,37 @@
+//go:build unix
+
+package main // Declare main package
+
+import ( // Import required packages
+	"context"   // For stopping the handler
+	"os"        // For signal values
+	"os/signal" // For SIGUSR1 and SIGUSR2
+	"syscall"   // For the signal numbers
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // The pause control
+)
+
+// Pauses the sync on SIGUSR1 and resumes it on SIGUSR2 until ctx is done, e.g. from a business-hours cron job; a nil
+// control leaves the signals alone
+func pauseOnSignals(ctx context.Context, pause *sdscraper.PauseControl) {
+	if pause == nil {
+		return
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case received := <-signals:
+				if received == syscall.SIGUSR1 {
+					pause.Pause("SIGUSR1")
+				} else {
+					pause.Resume()
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,89 @@
+package sdscraper
+
+import ( // Import required packages
+	"context"  // For giving up on a slot
+	"errors"   // For recognising throttling
+	"log/slog" // For structured logging
+	"net/http" // For throttling status codes
+	"sync"     // For guarding the limit
+)
+
+// Caps concurrent downloads below the worker count, adding a slot after each limit's worth of clean transfers and
+// halving on throttling responses, as TCP congestion control does
+type adaptiveLimiter struct {
+	mu        sync.Mutex
+	changed   *sync.Cond // Signalled when a slot frees up or the limit grows
+	limit     int        // Transfers currently allowed at once
+	max       int        // The worker count, never exceeded
+	inUse     int        // Transfers under way
+	successes int        // Clean transfers since the limit last changed
+}
+
+// Returns a limiter for the adaptive-concurrency feature, nil when it is off or there is nothing to adapt
+func (s *Scraper) concurrencyLimiter() *adaptiveLimiter {
+	if !s.Features.Enabled(FeatureAdaptiveConcurrency) || s.Workers <= 1 {
+		return nil
+	}
+	limiter := &adaptiveLimiter{limit: 1, max: s.Workers}
+	limiter.changed = sync.NewCond(&limiter.mu)
+	return limiter
+}
+
+// Waits for a slot; a nil limiter never waits
+func (l *adaptiveLimiter) acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	stop := context.AfterFunc(ctx, func() { // Wake waiters so they notice the cancellation
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.changed.Broadcast()
+	})
+	defer stop()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.inUse >= l.limit {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		l.changed.Wait()
+	}
+	l.inUse++
+	return nil
+}
+
+// Gives a slot back
+func (l *adaptiveLimiter) release() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inUse--
+	l.changed.Broadcast()
+}
+
+// Adjusts the limit to how a transfer went
+func (l *adaptiveLimiter) observe(err error) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var status *StatusError
+	switch {
+	case errors.As(err, &status) && (status.StatusCode == http.StatusTooManyRequests || status.StatusCode == http.StatusServiceUnavailable):
+		if l.limit > 1 {
+			l.limit /= 2
+			slog.Info("Server is throttling; lowering download concurrency", "workers", l.limit, "status", status.StatusCode)
+		}
+		l.successes = 0
+	case err == nil:
+		if l.successes++; l.successes >= l.limit && l.limit < l.max {
+			l.limit++
+			l.successes = 0
+			slog.Debug("Raising download concurrency", "workers", l.limit)
+			l.changed.Broadcast()
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package sdscraper
+
+import ( // Import required packages
+	"crypto/subtle" // For constant-time token comparison
+	"encoding/json" // For tag requests
+	"log/slog"      // For structured logging
+	"net/http"      // For the HTTP server
+	"strings"       // For parsing the Authorization header
+	"time"          // For change timestamps
+)
+
+// Wraps an admin handler so it only runs for requests carrying the admin bearer token
+func (s *Server) requireAdmin(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.adminToken == "" { // No token configured means no admin access at all
+			http.Error(w, "admin endpoints are disabled; set an admin token to enable them", http.StatusForbidden)
+			return
+		}
+		if !s.isAdmin(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="sds-admin"`)
+			http.Error(w, "admin token required", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	})
+}
+
+// Reports whether a request carries the admin bearer token
+func (s *Server) isAdmin(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && s.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) == 1
+}
+
+// Soft-deletes a document, recording the optional ?reason= with it
+func (s *Server) handleSoftDelete(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh()
+	sourceURL, _, ok := s.catalog.FindByFilename(r.PathValue("name"))
+	if !ok || !s.catalog.SoftDelete(sourceURL, r.URL.Query().Get("reason")) {
+		http.NotFound(w, r) // Unknown or already deleted
+		return
+	}
+	s.changedAt = time.Now()
+	s.save()
+	slog.Info("Soft-deleted document", "filename", r.PathValue("name"), "retention", s.retention)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Restores a soft-deleted document
+func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request) {
+	name, ok := strings.CutSuffix(r.PathValue("path"), "/restore") // Names may contain a type subfolder
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh()
+	sourceURL, _, ok := s.catalog.FindByFilename(name)
+	if !ok || !s.catalog.Restore(sourceURL) {
+		http.NotFound(w, r) // Unknown, purged, or not deleted
+		return
+	}
+	s.changedAt = time.Now()
+	s.save()
+	slog.Info("Restored document", "filename", name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Body of PUT /documents/{name}/tags, and its response
+type tagsRequest struct {
+	Tags []string `json:"tags"` // The complete new set; empty clears it
+}
+
+// Replaces a document's tags; the document need not be within the server's tag filter, so one can be brought into it
+func (s *Server) handleSetTags(w http.ResponseWriter, r *http.Request) {
+	name, ok := strings.CutSuffix(r.PathValue("path"), "/tags")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	var request tagsRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&request); err != nil {
+		http.Error(w, "body must be JSON like {\"tags\": [\"hazardous\"]}", http.StatusBadRequest)
+		return
+	}
+	tags := make([]string, 0, len(request.Tags))
+	for _, tag := range request.Tags {
+		normalized, err := NormalizeTag(tag)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		tags = append(tags, normalized)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh()
+	_, entry, ok := s.catalog.FindByFilename(name)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	entry.Tags = nil
+	entry.AddTags(tags...)
+	s.catalog.RecordChange(ChangeUpdated, entry) // Replicas pick up the new tags
+	s.changedAt = time.Now()
+	s.save()
+	slog.Info("Tagged document", "filename", name, "tags", entry.Tags)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(tagsRequest{Tags: append([]string{}, entry.Tags...)}); err != nil {
+		slog.Error("Writing tags response failed", "err", err)
+	}
+}
+
+// Item of the PATCH /catalog response
+type patchedDocument struct {
+	Filename    string                         `json:"filename"`
+	Product     string                         `json:"product,omitempty"`
+	SKU         string                         `json:"sku,omitempty"`
+	Language    string                         `json:"language,omitempty"`
+	Revision    string                         `json:"revision,omitempty"`
+	Corrections map[string]*MetadataCorrection `json:"corrections,omitempty"`
+}
+
+// Corrects extracted metadata of several documents at once; nothing changes unless every patch is valid
+func (s *Server) handlePatchCatalog(w http.ResponseWriter, r *http.Request) {
+	var patches []MetadataPatch
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&patches); err != nil || len(patches) == 0 {
+		http.Error(w, "body must be a JSON array like [{\"filename\": \"sheet.pdf\", \"fields\": {\"revision\": \"2024-05-31\"}}]", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh()
+	changed, err := s.catalog.ApplyPatches(patches)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.changedAt = time.Now()
+	s.save()
+	response := make([]patchedDocument, 0, len(changed))
+	for _, entry := range changed {
+		slog.Info("Corrected document metadata", "filename", entry.Filename, "corrections", len(entry.Corrections))
+		response = append(response, patchedDocument{Filename: entry.Filename, Product: entry.Product, SKU: entry.SKU,
+			Language: entry.Language, Revision: entry.Revision, Corrections: entry.Corrections})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil { // Encoded under the lock, as extraction updates corrections
+		slog.Error("Writing patch response failed", "err", err)
+	}
+}
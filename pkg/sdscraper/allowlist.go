@@ -0,0 +1,92 @@
+package sdscraper
+
+import ( // Import required packages
+	"fmt"       // For parse errors
+	"log/slog"  // For structured logging
+	"net"       // For splitting peer addresses
+	"net/http"  // For the middleware
+	"net/netip" // For address prefixes
+	"slices"    // For prefix matching
+	"strings"   // For X-Forwarded-For
+)
+
+// ParsePrefixes reads CIDR prefixes such as "10.20.0.0/16", a bare address standing for itself alone
+func ParsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if !strings.Contains(value, "/") {
+			address, err := netip.ParseAddr(value)
+			if err != nil {
+				return nil, fmt.Errorf("%q is neither an address nor a CIDR prefix", value)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(address.Unmap(), address.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is neither an address nor a CIDR prefix", value)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// Reports whether any prefix contains address
+func containsAddr(prefixes []netip.Prefix, address netip.Addr) bool {
+	return slices.ContainsFunc(prefixes, func(prefix netip.Prefix) bool { return prefix.Contains(address) })
+}
+
+// Finds the address a request came from; X-Forwarded-For is only believed when the peer is a trusted proxy, and then
+// only as far back as the hops added by trusted proxies
+type clientResolver struct {
+	trusted []netip.Prefix
+}
+
+// Returns the client address of a request, invalid when the peer address cannot be parsed
+func (c clientResolver) address(r *http.Request) netip.Addr {
+	host := r.RemoteAddr
+	if split, _, err := net.SplitHostPort(host); err == nil {
+		host = split
+	}
+	address, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+	address = address.Unmap()
+	if !containsAddr(c.trusted, address) {
+		return address // Anyone else could claim any forwarded address
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- { // Each proxy appends the address it received the request from
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break // Garbage beyond this point was not written by a trusted proxy
+		}
+		address = hop.Unmap()
+		if !containsAddr(c.trusted, address) {
+			break
+		}
+	}
+	return address
+}
+
+// Refuses requests from outside the allowed networks
+type allowHandler struct {
+	allow   []netip.Prefix
+	resolve clientResolver
+	exempt  func(*http.Request) bool // Requests let through from anywhere, nil for none
+	metrics *Metrics
+	next    http.Handler
+}
+
+// ServeHTTP implements http.Handler
+func (h *allowHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if address := h.resolve.address(r); !containsAddr(h.allow, address) && (h.exempt == nil || !h.exempt(r)) {
+		h.metrics.observeLimited("allowlist")
+		slog.Warn("Refused request from outside the allowed networks", "client", address, "path", r.URL.Path)
+		http.Error(w, "access is restricted to the allowed networks", http.StatusForbidden)
+		return
+	}
+	h.next.ServeHTTP(w, r)
+}
@@ -0,0 +1,106 @@
+package sdscraper
+
+import ( // Import required packages
+	"fmt"      // For anomaly descriptions
+	"log/slog" // For structured logging
+	"slices"   // For medians
+	"time"     // For run timestamps
+)
+
+// DefaultAnomalyDrop is the fall below the usual discovery count that marks a run anomalous
+const DefaultAnomalyDrop = 0.5
+
+const (
+	runHistoryLimit = 30 // Complete runs remembered in the manifest
+	minHistoryRuns  = 3  // Fewer runs than this are no norm to judge by
+	minLocaleNorm   = 5  // Locales usually listing fewer documents are too noisy to judge
+)
+
+// RunStats summarises one complete run, so later runs can be judged by and compared with it
+type RunStats struct {
+	ID          string         `json:"id,omitempty"`              // The start time in RunIDFormat, naming the run for "runs compare"
+	At          time.Time      `json:"at"`                        // When the run started
+	Version     string         `json:"version,omitempty"`         // Build that ran it
+	Elapsed     float64        `json:"elapsed_seconds,omitempty"` // From start to the end of the downloads
+	Discovered  int            `json:"discovered"`                // Valid document links found
+	ByLocale    map[string]int `json:"by_locale,omitempty"`       // Links found per locale listing
+	Downloaded  int            `json:"downloaded,omitempty"`      // Documents written, aliased ones included
+	NotModified int            `json:"not_modified,omitempty"`
+	Skipped     int            `json:"skipped,omitempty"`
+	Failed      int            `json:"failed,omitempty"`
+	Failures    map[string]int `json:"failures,omitempty"`  // Failed downloads by FailureCategory
+	Bytes       int64          `json:"bytes,omitempty"`     // Size of the documents received
+	Anomalous   bool           `json:"anomalous,omitempty"` // Left out of the norms later runs are judged by
+	Reason      string         `json:"reason,omitempty"`    // Why an operator started the run, empty for scheduled ones
+}
+
+// Compares a run's discovery with the norms of earlier complete runs, returning a confidence between 0 and 1 and
+// a description of every metric that fell by more than drop; the run is then appended to the manifest's history
+func (m *Manifest) assessRun(stats RunStats, drop float64) (float64, []string) {
+	var totals []int
+	byLocale := make(map[string][]int)
+	for _, past := range m.Runs {
+		if past.Anomalous {
+			continue
+		}
+		totals = append(totals, past.Discovered)
+		for locale, count := range past.ByLocale {
+			byLocale[locale] = append(byLocale[locale], count)
+		}
+	}
+
+	confidence := 1.0
+	var anomalies []string
+	judge := func(what string, current int, history []int, minimum int) {
+		if len(history) < minHistoryRuns {
+			return
+		}
+		norm := median(history)
+		if norm < minimum {
+			return
+		}
+		ratio := float64(current) / float64(norm)
+		confidence = min(confidence, ratio)
+		if ratio < 1-drop {
+			anomalies = append(anomalies, fmt.Sprintf("%s: %d documents, %.0f%% below the usual %d", what, current, (1-ratio)*100, norm))
+		}
+	}
+	judge("all listings", stats.Discovered, totals, 1)
+	for _, locale := range sortedKeys(byLocale) {
+		judge("locale "+locale, stats.ByLocale[locale], byLocale[locale], minLocaleNorm)
+	}
+
+	stats.Anomalous = len(anomalies) > 0
+	if stats.ID != "" {
+		stats.ID = m.uniqueRunID(stats.ID)
+	}
+	m.Runs = append(m.Runs, stats)
+	if len(m.Runs) > runHistoryLimit {
+		m.Runs = slices.Delete(m.Runs, 0, len(m.Runs)-runHistoryLimit)
+	}
+	for _, anomaly := range anomalies {
+		slog.Warn("Anomalous discovery", "detail", anomaly)
+	}
+	return confidence, anomalies
+}
+
+// Returns the median of values, rounding down between the middle two
+func median(values []int) int {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+// Returns a map's keys in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,237 @@
+package sdscraper
+
+import ( // Import required packages
+	"archive/tar"   // For tar.gz archives
+	"archive/zip"   // For zip archives
+	"bytes"         // For in-memory entries
+	"cmp"           // For defaults
+	"compress/gzip" // For tar.gz archives
+	"context"       // For stopping the stamping workers
+	"io"            // For streaming file contents
+	"io/fs"         // For file metadata
+	"iter"          // For the files handed to the writers
+	"log/slog"      // For cover page failures
+	"os"            // For opening documents
+	"slices"        // For copying the file list
+	"sync"          // For the stamping workers
+	"time"          // For entry timestamps
+)
+
+// DefaultExportMemory bounds the stamped documents an export holds in memory at once
+const DefaultExportMemory = 256 << 20
+
+// Stamping a PDF holds the original, pdfcpu's parse of it and the stamped copy, roughly this many times its size
+const stampMemoryFactor = 4
+
+// One file to place in an archive
+type archiveFile struct {
+	name    string    // Path inside the archive
+	path    string    // Location on disk, unless data is set
+	data    []byte    // Content generated for the archive, e.g. an index or a stamped copy
+	modTime time.Time // Timestamp of data, zero for now
+	stamp   *pdfStamp // Applied to the file at path, nil to copy it as it is
+	release func()    // Called once the file is in the archive, nil when there is nothing to free
+}
+
+// Opens an archive file's content with its size and modification time
+func (f archiveFile) open() (io.ReadCloser, int64, time.Time, error) {
+	if f.data != nil {
+		return io.NopCloser(bytes.NewReader(f.data)), int64(len(f.data)), cmp.Or(f.modTime, time.Now()), nil
+	}
+	source, err := openStored(f.path) // Decrypted when sealed at rest; archives hold plaintext
+	if err != nil {
+		return nil, 0, time.Time{}, err
+	}
+	info, err := os.Stat(f.path) // Keep the original timestamps
+	if err != nil {
+		source.Close()
+		return nil, 0, time.Time{}, err
+	}
+	if f.stamp != nil { // Not rendered ahead
+		source.Close()
+		return f.render().open()
+	}
+	return source, plainSize(f.path, info.Size()), info.ModTime(), nil
+}
+
+// Stamps the files that need it on workers goroutines ahead of the archive writer and yields all of them in order,
+// so the writer never waits for one document's rendering while the CPUs idle. Stamped copies wait in memory until
+// the writer has archived them, and files are only taken up while their estimated footprint fits within budget bytes,
+// one at a time when a single document exceeds it; unstamped files are yielded as they are, to be streamed from disk
+func renderAhead(files []archiveFile, workers int, budget int64) iter.Seq[archiveFile] {
+	return func(yield func(archiveFile) bool) {
+		files = slices.Clone(files) // Reservations are recorded on the files
+		ctx, cancel := context.WithCancel(context.Background())
+		var wg sync.WaitGroup
+		defer func() {
+			cancel() // Stops admitting files when the writer gives up early
+			wg.Wait()
+		}()
+
+		rendered := make([]chan archiveFile, len(files)) // One slot per file, filled in any order, read in order
+		for i := range rendered {
+			rendered[i] = make(chan archiveFile, 1)
+		}
+		freed := make(chan int64, len(files)) // Never blocks the writer: each file frees its reservation once
+		jobs := make(chan int)
+		for range max(workers, 1) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range jobs {
+					rendered[i] <- files[i].render()
+				}
+			}()
+		}
+		go func() { // Admits files in order, so the writer's next file is never waiting behind later ones
+			defer close(jobs)
+			var held int64
+			for i, file := range files {
+				if file.stamp == nil {
+					rendered[i] <- file
+					continue
+				}
+				reserve := stampMemoryFactor * plainSize(file.path, fileSize(file.path))
+				for held > 0 && held+reserve > budget {
+					select {
+					case n := <-freed:
+						held -= n
+					case <-ctx.Done():
+						return
+					}
+				}
+				held += reserve
+				files[i].release = func() { freed <- reserve }
+				select {
+				case jobs <- i:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		for i := range files {
+			var file archiveFile
+			select {
+			case file = <-rendered[i]:
+			case <-ctx.Done():
+				return
+			}
+			if !yield(file) {
+				return
+			}
+		}
+	}
+}
+
+// Returns the file with its stamp applied, or without the stamp when stamping fails
+func (f archiveFile) render() archiveFile {
+	if f.stamp == nil {
+		return f
+	}
+	info, err := os.Stat(f.path) // Keep the original's timestamp
+	if err == nil {
+		var stamped []byte
+		if stamped, err = f.stamp.apply(f.path); err == nil {
+			f.data, f.modTime, f.stamp = stamped, info.ModTime(), nil
+			return f
+		}
+	}
+	slog.Warn("Stamping failed; archiving the original", "file", f.name, "err", err) // E.g. an encrypted PDF
+	f.stamp = nil
+	return f
+}
+
+// Returns the size of the file at path, zero when it cannot be read
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// Marks a file as archived, freeing what it held
+func (f archiveFile) done() {
+	if f.release != nil {
+		f.release()
+	}
+}
+
+// Streams the given files into a zip written to w, storing PDFs without recompression
+func writeZip(w io.Writer, files iter.Seq[archiveFile]) error {
+	archive := zip.NewWriter(w)
+	for file := range files {
+		if err := addZipFile(archive, file); err != nil {
+			archive.Close()
+			return err
+		}
+	}
+	return archive.Close() // Writes the central directory
+}
+
+// Copies one file into the zip
+func addZipFile(archive *zip.Writer, file archiveFile) error {
+	defer file.done()
+	source, size, modTime, err := file.open()
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	header := &zip.FileHeader{
+		Name:               file.name,
+		Method:             zip.Store, // PDFs are already compressed internally
+		Modified:           modTime.UTC().Truncate(time.Second),
+		UncompressedSize64: uint64(size),
+	}
+	header.SetMode(0o644)
+
+	target, err := archive.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(target, source) // Stream, never holding whole documents in memory
+	return err
+}
+
+// Streams the given files into a gzip-compressed tar written to w
+func writeTarGz(w io.Writer, files iter.Seq[archiveFile]) error {
+	compressed := gzip.NewWriter(w)
+	archive := tar.NewWriter(compressed)
+	for file := range files {
+		if err := addTarFile(archive, file); err != nil {
+			archive.Close()
+			compressed.Close()
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		compressed.Close()
+		return err
+	}
+	return compressed.Close()
+}
+
+// Copies one file into the tar
+func addTarFile(archive *tar.Writer, file archiveFile) error {
+	defer file.done()
+	source, size, modTime, err := file.open()
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     file.name,
+		Size:     size,
+		Mode:     int64(fs.FileMode(0o644)),
+		ModTime:  modTime.UTC().Truncate(time.Second),
+		Format:   tar.FormatPAX, // Long names and UTF-8
+	}
+	if err := archive.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(archive, source)
+	return err
+}
@@ -0,0 +1,69 @@
+package sdscraper
+
+import ( // Import required packages
+	"fmt"  // For describing violations
+	"time" // For staleness
+)
+
+// Assertions are quality thresholds a finished run must meet, so a scheduled pipeline can hold back publishing an
+// archive that came out short, failed or stale
+type Assertions struct {
+	MinDocuments int           // Fewest live, stored documents fetched from the source the catalog must hold, zero for no minimum
+	NoFailures   bool          // Every download of the run must have succeeded
+	MaxStaleness time.Duration // Longest a live document may go without its source being checked, zero for no limit
+}
+
+// Enabled reports whether any assertion is set
+func (a Assertions) Enabled() bool {
+	return a.MinDocuments > 0 || a.NoFailures || a.MaxStaleness > 0
+}
+
+// Check describes every assertion the run's result violates, judging staleness at now; nil means the run passes
+func (a Assertions) Check(result *Result, now time.Time) []string {
+	var violations []string
+	if a.NoFailures && len(result.Failed) > 0 {
+		violations = append(violations, fmt.Sprintf("%d downloads failed, want none", len(result.Failed)))
+	}
+	if result.Manifest == nil { // Nothing was catalogued to judge
+		if a.MinDocuments > 0 || a.MaxStaleness > 0 {
+			violations = append(violations, "the run saved no catalog")
+		}
+		return violations
+	}
+
+	live, stale := 0, 0
+	var oldest *ManifestEntry // Stale document checked longest ago
+	for _, documentURL := range sortedKeys(result.Manifest.Documents) {
+		entry := result.Manifest.Documents[documentURL]
+		if !entry.DeletedAt.IsZero() || entry.SHA256 == "" || entry.Source == SourceUpload { // Uploads say nothing about the source
+			continue
+		}
+		live++
+		if a.MaxStaleness > 0 && now.Sub(entry.lastChecked()) > a.MaxStaleness {
+			stale++
+			if oldest == nil || entry.lastChecked().Before(oldest.lastChecked()) {
+				oldest = entry
+			}
+		}
+	}
+	if live < a.MinDocuments {
+		violations = append(violations, fmt.Sprintf("the catalog holds %d documents, want at least %d", live, a.MinDocuments))
+	}
+	if stale > 0 {
+		since := "never"
+		if checked := oldest.lastChecked(); !checked.IsZero() {
+			since = "last " + checked.UTC().Format(time.RFC3339)
+		}
+		violations = append(violations, fmt.Sprintf("%d documents were not checked against their source within %s, the oldest %s (%s)",
+			stale, a.MaxStaleness, oldest.Filename, since))
+	}
+	return violations
+}
+
+// Returns when the entry's source was last confirmed: the last check, or the download before any check was made
+func (e *ManifestEntry) lastChecked() time.Time {
+	if e.CheckedAt.IsZero() {
+		return e.DownloadedAt
+	}
+	return e.CheckedAt
+}
@@ -0,0 +1,170 @@
+package sdscraper
+
+import ( // Import required packages
+	"fmt"           // For error messages
+	"io"            // For copying files
+	"log/slog"      // For structured logging
+	"os"            // For file handling
+	"path/filepath" // For OS-independent path operations
+	"slices"        // For ordering backups
+	"time"          // For timestamps and retention
+)
+
+const backupTimeFormat = "20060102T150405.000Z" // Backup directory names sort chronologically
+
+// BackupPolicy says where pre-run backups of state files go and how many are kept
+type BackupPolicy struct {
+	Dir    string        // Directory holding one subdirectory per backup
+	Keep   int           // Newest backups always kept, zero for 10
+	MaxAge time.Duration // Backups beyond Keep are deleted once older than this, zero to delete them right away
+	Trash  *Trash        // Receives rotated backups, nil to delete them outright
+}
+
+// Backup copies the existing files among paths, keyed by role, into a new timestamped backup and rotates old ones,
+// returning its ID; each file is kept under its role, so two state files sharing a base name never collide
+func (p BackupPolicy) Backup(paths map[string]string) (string, error) {
+	if err := makeDirAll(p.Dir, 0o755); err != nil {
+		return "", err
+	}
+	var id, target string
+	for { // Mkdir fails on an existing name, so two backups never share a directory
+		id = time.Now().UTC().Format(backupTimeFormat)
+		target = filepath.Join(p.Dir, id)
+		err := makeDir(target, 0o755)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	copied := 0
+	for _, role := range sortedKeys(paths) {
+		path := paths[role]
+		if !fileExists(path) { // Nothing written yet, e.g. on the first run
+			continue
+		}
+		if err := copyFile(path, filepath.Join(target, backupName(role, path))); err != nil {
+			return "", fmt.Errorf("back up %s: %w", path, err)
+		}
+		copied++
+	}
+	if copied == 0 { // Don't leave empty backups behind
+		removeFile(target)
+		return "", nil
+	}
+
+	p.rotate()
+	return id, nil
+}
+
+// ListBackups returns the backup IDs in the policy directory, oldest first
+func (p BackupPolicy) ListBackups() ([]string, error) {
+	entries, err := os.ReadDir(p.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var ids []string
+	for _, entry := range entries {
+		if _, err := time.Parse(backupTimeFormat, entry.Name()); entry.IsDir() && err == nil {
+			ids = append(ids, entry.Name())
+		}
+	}
+	slices.Sort(ids) // Names sort chronologically
+	return ids, nil
+}
+
+// Restore copies the files of backup id back over paths, matched by role, first backing up the current state
+func (p BackupPolicy) Restore(id string, paths map[string]string) error {
+	if id == "latest" {
+		ids, err := p.ListBackups()
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return fmt.Errorf("no backups in %s", p.Dir)
+		}
+		id = ids[len(ids)-1]
+	}
+	source := filepath.Join(p.Dir, id)
+	if !directoryExists(source) {
+		return fmt.Errorf("backup %s not found in %s", id, p.Dir)
+	}
+
+	if safety, err := p.Backup(paths); err != nil { // The restore itself must be undoable
+		return fmt.Errorf("back up current state: %w", err)
+	} else if safety != "" {
+		slog.Info("Current state saved as backup", "backup", safety)
+	}
+
+	for _, role := range sortedKeys(paths) {
+		path := paths[role]
+		backedUp := filepath.Join(source, backupName(role, path))
+		if !fileExists(backedUp) {
+			backedUp = filepath.Join(source, filepath.Base(path)) // Backups from before roles were named by the file
+		}
+		if !fileExists(backedUp) {
+			continue
+		}
+		temp := path + ".restore"
+		if err := copyFile(backedUp, temp); err != nil {
+			return err
+		}
+		if err := renameFile(temp, path); err != nil { // Atomic swap, like SaveManifest
+			return err
+		}
+		slog.Info("Restored state file from backup", "path", path, "backup", id)
+	}
+	return nil
+}
+
+// Returns the name the file at path takes in a backup: its role, with the file's extension
+func backupName(role, path string) string {
+	return role + filepath.Ext(path)
+}
+
+// Deletes backups beyond the newest Keep that are older than MaxAge
+func (p BackupPolicy) rotate() {
+	ids, err := p.ListBackups()
+	if err != nil {
+		slog.Error("Listing backups failed", "dir", p.Dir, "err", err)
+		return
+	}
+	keep := p.Keep
+	if keep <= 0 {
+		keep = 10
+	}
+	cutoff := time.Now().Add(-p.MaxAge)
+	for _, id := range ids[:max(0, len(ids)-keep)] {
+		created, _ := time.Parse(backupTimeFormat, id)
+		if created.After(cutoff) {
+			continue // Inside the age window
+		}
+		if err := trashOrRemove(p.Trash, filepath.Join(p.Dir, id), "backups/"+id); err != nil {
+			slog.Error("Removing old backup failed", "backup", id, "err", err)
+		}
+	}
+}
+
+// Copies a file's contents to target, replacing it
+func copyFile(source, target string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := createFile(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,36 @@
+package sdscraper
+
+import ( // Import required packages
+	"os"            // For the state files
+	"path/filepath" // For file paths
+	"testing"       // For the test harness
+)
+
+func TestBackupRestoreByRole(t *testing.T) {
+	dir := t.TempDir()
+	state := map[string]string{ // Same base name, different roles
+		StateManifest:   filepath.Join(dir, "site", "state.json"),
+		StateCheckpoint: filepath.Join(dir, "run", "state.json"),
+	}
+	for role, path := range state {
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		os.WriteFile(path, []byte(role+" v1"), 0o644)
+	}
+	policy := BackupPolicy{Dir: filepath.Join(dir, "backups")}
+	id, err := policy.Backup(state)
+	if err != nil || id == "" {
+		t.Fatalf("Backup = %q, %v", id, err)
+	}
+	for role, path := range state {
+		os.WriteFile(path, []byte(role+" v2"), 0o644)
+	}
+
+	if err := policy.Restore(id, state); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	for role, path := range state {
+		if data, _ := os.ReadFile(path); string(data) != role+" v1" {
+			t.Errorf("%s restored as %q, want %q", role, data, role+" v1")
+		}
+	}
+}
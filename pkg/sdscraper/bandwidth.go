@@ -0,0 +1,255 @@
+package sdscraper
+
+import ( // Import required packages
+	"context" // For cancelling waits
+	"fmt"     // For parse errors and window names
+	"io"      // For throttled bodies
+	"strconv" // For worker counts
+	"strings" // For parsing windows
+	"sync"    // For guarding the bucket and slots
+	"time"    // For the time of day and refill timing
+)
+
+// BandwidthWindow limits downloads during part of the week, e.g. 1MiB/s on weekdays from 08:00 to 18:00
+type BandwidthWindow struct {
+	Days           [7]bool // Weekdays the window starts on, indexed by time.Weekday
+	Start, End     int     // Minutes after midnight; an End at or before Start runs past midnight
+	BytesPerSecond int64   // Combined transfer rate of all downloads, zero for unlimited
+	Workers        int     // Downloads at once, zero for as many as there are workers
+}
+
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// ParseBandwidthWindow reads a window such as "mon-fri 08:00-18:00 1MiB/s", "sat,sun 00:00-24:00 unlimited workers=1"
+// or "* 22:00-06:00 4MiB/s"; the days are those it starts on
+func ParseBandwidthWindow(value string) (BandwidthWindow, error) {
+	var window BandwidthWindow
+	fields := strings.Fields(value)
+	if len(fields) < 3 || len(fields) > 4 {
+		return window, fmt.Errorf("bandwidth window %q: want \"days HH:MM-HH:MM rate [workers=N]\"", value)
+	}
+	if err := window.parseDays(strings.ToLower(fields[0])); err != nil {
+		return window, fmt.Errorf("bandwidth window %q: %w", value, err)
+	}
+	start, end, ok := strings.Cut(fields[1], "-")
+	var err error
+	if window.Start, err = parseClock(start); ok && err == nil {
+		window.End, err = parseClock(end)
+	}
+	if !ok || err != nil {
+		return window, fmt.Errorf("bandwidth window %q: want hours as HH:MM-HH:MM", value)
+	}
+	if rate := strings.ToLower(fields[2]); rate != "unlimited" {
+		size, perSecond := strings.CutSuffix(rate, "/s")
+		if window.BytesPerSecond, err = ParseByteSize(size); !perSecond || err != nil || window.BytesPerSecond == 0 {
+			return window, fmt.Errorf("bandwidth window %q: want a rate such as 1MiB/s, or unlimited", value)
+		}
+	}
+	if len(fields) == 4 {
+		count, ok := strings.CutPrefix(fields[3], "workers=")
+		if window.Workers, err = strconv.Atoi(count); !ok || err != nil || window.Workers < 1 {
+			return window, fmt.Errorf("bandwidth window %q: want workers=N with N at least 1", value)
+		}
+	}
+	return window, nil
+}
+
+// Reads the days field: "*", or comma-separated days and ranges such as mon-fri
+func (w *BandwidthWindow) parseDays(field string) error {
+	if field == "*" || field == "daily" {
+		w.Days = [7]bool{true, true, true, true, true, true, true}
+		return nil
+	}
+	for _, part := range strings.Split(field, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		first, last := dayIndex(from), dayIndex(to)
+		if !isRange {
+			last = first
+		}
+		if first < 0 || last < 0 {
+			return fmt.Errorf("unknown day in %q (want e.g. mon-fri, sat,sun or *)", part)
+		}
+		for day := first; ; day = (day + 1) % 7 { // sat-mon wraps over the weekend
+			w.Days[day] = true
+			if day == last {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the time.Weekday of a three-letter day name, -1 for none
+func dayIndex(name string) int {
+	for i, day := range weekdays {
+		if name == day {
+			return i
+		}
+	}
+	return -1
+}
+
+// Reads HH:MM as minutes after midnight, 24:00 included
+func parseClock(value string) (int, error) {
+	hours, minutes, ok := strings.Cut(value, ":")
+	h, err := strconv.Atoi(hours)
+	m, err2 := strconv.Atoi(minutes)
+	if !ok || err != nil || err2 != nil || h < 0 || m < 0 || m > 59 || h*60+m > 24*60 {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	return h*60 + m, nil
+}
+
+// String formats the window as ParseBandwidthWindow reads it
+func (w BandwidthWindow) String() string {
+	var days []string
+	for i, on := range w.Days {
+		if on {
+			days = append(days, weekdays[i])
+		}
+	}
+	rate := "unlimited"
+	if w.BytesPerSecond > 0 {
+		rate = strconv.FormatInt(w.BytesPerSecond, 10) + "/s"
+	}
+	text := fmt.Sprintf("%s %02d:%02d-%02d:%02d %s", strings.Join(days, ","), w.Start/60, w.Start%60, w.End/60, w.End%60, rate)
+	if w.Workers > 0 {
+		text += " workers=" + strconv.Itoa(w.Workers)
+	}
+	return text
+}
+
+// Reports whether the window covers t, in t's location
+func (w BandwidthWindow) covers(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if w.End > w.Start {
+		return w.Days[t.Weekday()] && minute >= w.Start && minute < w.End
+	}
+	yesterday := (t.Weekday() + 6) % 7 // Overnight windows belong to the day they start on
+	return w.Days[t.Weekday()] && minute >= w.Start || w.Days[yesterday] && minute < w.End
+}
+
+// BandwidthSchedule throttles downloads by time of day so a mirror coexists with business traffic: the first window
+// covering the local time sets the rate and concurrency, and outside every window downloads are unlimited
+type BandwidthSchedule struct {
+	Windows []BandwidthWindow
+	mu      sync.Mutex
+	changed chan struct{} // Closed when a slot is released, waking waiters
+	inUse   int           // Downloads holding a slot
+	tokens  float64       // Bytes that may be read now, negative when in debt
+	last    time.Time     // When tokens was last brought up to date
+}
+
+// Returns the window in force at t, the zero window of no limits outside every window
+func (s *BandwidthSchedule) at(t time.Time) BandwidthWindow {
+	for _, window := range s.Windows {
+		if window.covers(t) {
+			return window
+		}
+	}
+	return BandwidthWindow{}
+}
+
+// Waits until the window in force lets another download start; priority requests, e.g. serve's users, never wait
+func (s *BandwidthSchedule) acquire(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	if priority, _ := ctx.Value(priorityKey{}).(bool); priority {
+		return nil
+	}
+	for {
+		s.mu.Lock()
+		if s.changed == nil {
+			s.changed = make(chan struct{})
+		}
+		window := s.at(time.Now())
+		if window.Workers == 0 || s.inUse < window.Workers {
+			s.inUse++
+			s.mu.Unlock()
+			return nil
+		}
+		changed := s.changed
+		s.mu.Unlock()
+		select {
+		case <-changed:
+		case <-time.After(time.Minute): // The window may have ended
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Gives back a slot taken by acquire
+func (s *BandwidthSchedule) release(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	if priority, _ := ctx.Value(priorityKey{}).(bool); priority {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inUse--
+	if s.changed != nil {
+		close(s.changed)
+		s.changed = make(chan struct{})
+	}
+}
+
+// Blocks until n more bytes fit the rate in force, which is looked up afresh so a window starting mid-transfer applies
+func (s *BandwidthSchedule) waitBytes(ctx context.Context, n int) error {
+	s.mu.Lock()
+	now := time.Now()
+	window := s.at(now)
+	if window.BytesPerSecond == 0 {
+		s.tokens, s.last = 0, now
+		s.mu.Unlock()
+		return nil
+	}
+	rate := float64(window.BytesPerSecond)
+	if !s.last.IsZero() {
+		s.tokens = min(rate, s.tokens+now.Sub(s.last).Seconds()*rate) // At most a second's worth of burst
+	}
+	s.last = now
+	s.tokens -= float64(n) // Reserve, possibly going into debt
+	wait := time.Duration(-s.tokens / rate * float64(time.Second))
+	s.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Returns a reader pacing r to the schedule; a nil schedule returns r itself
+func (s *BandwidthSchedule) reader(ctx context.Context, r io.Reader) io.Reader {
+	if s == nil || len(s.Windows) == 0 {
+		return r
+	}
+	return &throttledReader{ctx: ctx, reader: r, schedule: s}
+}
+
+// Reads no faster than the schedule allows
+type throttledReader struct {
+	ctx      context.Context
+	reader   io.Reader
+	schedule *BandwidthSchedule
+}
+
+// Read implements io.Reader, in chunks small enough to keep the pace smooth
+func (r *throttledReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p[:min(len(p), 32<<10)])
+	if n > 0 {
+		if waitErr := r.schedule.waitBytes(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,87 @@
+package sdscraper
+
+import ( // Import required packages
+	"context"     // For the browser target
+	"fmt"         // For error messages
+	"log/slog"    // For structured logging
+	"strings"     // For matching type names
+	"sync/atomic" // For counting blocked requests
+
+	"github.com/chromedp/cdproto/cdp"     // For answering paused requests on the page's target
+	"github.com/chromedp/cdproto/fetch"   // For intercepting requests by resource type
+	"github.com/chromedp/cdproto/network" // For blocking requests by URL
+	"github.com/chromedp/chromedp"        // For browser actions
+)
+
+// DefaultBlockedTypes are resource types a listing never needs, since only its DOM links are read
+var DefaultBlockedTypes = []string{"image", "font", "media"}
+
+// DefaultBlockedURLs match common analytics and advertising hosts
+var DefaultBlockedURLs = []string{
+	"*google-analytics.com*", "*googletagmanager.com*", "*doubleclick.net*", "*facebook.net*",
+	"*hotjar.com*", "*clarity.ms*", "*bat.bing.com*", "*px.ads.linkedin.com*",
+}
+
+// ResourceBlocking says which requests Chrome refuses while rendering a listing
+type ResourceBlocking struct {
+	Types []string // Resource types to refuse: image, font, media, stylesheet, script, xhr, fetch, ...
+	URLs  []string // URL patterns to refuse, with * wildcards
+}
+
+// Chrome's names for the resource types, keyed by their lowercase spelling
+var resourceTypes = map[string]network.ResourceType{
+	"document": network.ResourceTypeDocument, "stylesheet": network.ResourceTypeStylesheet, "image": network.ResourceTypeImage,
+	"media": network.ResourceTypeMedia, "font": network.ResourceTypeFont, "script": network.ResourceTypeScript,
+	"texttrack": network.ResourceTypeTextTrack, "xhr": network.ResourceTypeXHR, "fetch": network.ResourceTypeFetch,
+	"eventsource": network.ResourceTypeEventSource, "websocket": network.ResourceTypeWebSocket,
+	"manifest": network.ResourceTypeManifest, "ping": network.ResourceTypePing, "other": network.ResourceTypeOther,
+}
+
+// Validate reports resource types Chrome does not know
+func (b ResourceBlocking) Validate() error {
+	for _, name := range b.Types {
+		if _, ok := resourceTypes[strings.ToLower(name)]; !ok {
+			return fmt.Errorf("unknown resource type %q", name)
+		}
+	}
+	return nil
+}
+
+// Returns an action installing the blocking rules on the page, counting refused requests in blocked
+func (b ResourceBlocking) action(blocked *atomic.Int64) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if len(b.URLs) > 0 {
+			if err := network.Enable().Do(ctx); err != nil {
+				return err
+			}
+			if err := network.SetBlockedURLs(b.URLs).Do(ctx); err != nil {
+				return err
+			}
+		}
+		if len(b.Types) == 0 {
+			return nil
+		}
+		var patterns []*fetch.RequestPattern // Only these types are paused, so every paused request is refused
+		for _, name := range b.Types {
+			resourceType, ok := resourceTypes[strings.ToLower(name)]
+			if !ok {
+				return fmt.Errorf("unknown resource type %q", name)
+			}
+			patterns = append(patterns, &fetch.RequestPattern{URLPattern: "*", ResourceType: resourceType})
+		}
+		target := chromedp.FromContext(ctx).Target
+		chromedp.ListenTarget(ctx, func(event any) {
+			paused, ok := event.(*fetch.EventRequestPaused)
+			if !ok {
+				return
+			}
+			blocked.Add(1)
+			go func() { // Listeners must not block the event loop
+				if err := fetch.FailRequest(paused.RequestID, network.ErrorReasonBlockedByClient).Do(cdp.WithExecutor(ctx, target)); err != nil && ctx.Err() == nil {
+					slog.Debug("Refusing browser request failed", "url", paused.Request.URL, "err", err)
+				}
+			}()
+		})
+		return fetch.Enable().WithPatterns(patterns).Do(ctx)
+	})
+}
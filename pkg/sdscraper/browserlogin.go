@@ -0,0 +1,258 @@
+package sdscraper
+
+import ( // Import required packages
+	"bufio"         // For cookies.txt files
+	"bytes"         // For sniffing the cookie file format
+	"cmp"           // For default selectors
+	"context"       // For chromedp actions
+	"encoding/json" // For JSON cookie exports
+	"errors"        // For login failures
+	"fmt"           // For error messages
+	"log/slog"      // For structured logging
+	"net/http"      // For cookies and the jar
+	"net/url"       // For jar URLs
+	"os"            // For reading cookie files
+	"strconv"       // For expiry times and quoting selectors
+	"strings"       // For parsing cookies.txt
+	"time"          // For polling and expiry
+
+	"github.com/chromedp/cdproto/cdp"     // For cookie expiry times
+	"github.com/chromedp/cdproto/network" // For injecting cookies
+	"github.com/chromedp/cdproto/storage" // For reading the session back
+	"github.com/chromedp/chromedp"        // For driving the login form
+)
+
+// Selectors tried when BrowserLogin leaves the form fields unset
+const (
+	defaultUsernameSelector = `input[type=email], input[name*=user i], input[name*=login i], input[name*=email i], input[type=text]`
+	defaultPasswordSelector = `input[type=password]`
+)
+
+// BrowserLogin signs Chrome in before the first listing is captured, for sites whose listings sit behind a customer
+// portal login: Cookies are injected first, then, when URL is set, the form there is filled in and submitted. It runs
+// again whenever the browser is restarted
+type BrowserLogin struct {
+	URL              string         // Login page, empty to rely on Cookies alone
+	UsernameSelector string         // Username field, empty to guess from the usual names
+	PasswordSelector string         // Password field, empty for the form's password input
+	SubmitSelector   string         // Button to click, empty to submit the password field's form
+	SuccessSelector  string         // Element only shown once signed in, empty to wait for the password field to go away
+	Username         string         // Typed into the username field
+	Password         string         // Typed into the password field
+	Cookies          []*http.Cookie // Injected before anything is loaded, e.g. a session exported with LoadCookies
+	Jar              http.CookieJar // Receives the browser's cookies once signed in, so downloads share the session; may be nil
+	Timeout          time.Duration  // Upper bound for signing in, zero for a minute
+}
+
+// Signs the browser behind browserCtx in, in a tab of its own
+func (l *BrowserLogin) run(ctx, browserCtx context.Context, identify chromedp.Action) error {
+	tabCtx, cancelTab := chromedp.NewContext(browserCtx)
+	defer cancelTab()
+	timeoutCtx, cancelTimeout := context.WithTimeout(tabCtx, cmp.Or(l.Timeout, time.Minute))
+	defer cancelTimeout()
+	stop := context.AfterFunc(ctx, cancelTab)
+	defer stop()
+
+	actions := []chromedp.Action{identify, l.injectCookies()}
+	if l.URL != "" {
+		actions = append(actions, chromedp.Navigate(l.URL), l.submitForm(), l.awaitSignedIn())
+	}
+	actions = append(actions, l.exportCookies())
+	if err := chromedp.Run(timeoutCtx, actions...); err != nil {
+		return fmt.Errorf("browser login: %w", err)
+	}
+	slog.Info("Browser signed in", "login_url", l.URL, "injected_cookies", len(l.Cookies))
+	return nil
+}
+
+// Returns an action setting the configured cookies in the browser
+func (l *BrowserLogin) injectCookies() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if len(l.Cookies) == 0 {
+			return nil
+		}
+		return storage.SetCookies(cookieParams(l.Cookies)).Do(ctx)
+	})
+}
+
+// Returns an action filling in and submitting the login form
+func (l *BrowserLogin) submitForm() chromedp.Action {
+	username := cmp.Or(l.UsernameSelector, defaultUsernameSelector)
+	password := cmp.Or(l.PasswordSelector, defaultPasswordSelector)
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if err := chromedp.WaitVisible(password, chromedp.ByQuery).Do(ctx); err != nil {
+			return fmt.Errorf("wait for the password field: %w", err)
+		}
+		if l.Username != "" {
+			if err := chromedp.SendKeys(username, l.Username, chromedp.ByQuery).Do(ctx); err != nil {
+				return fmt.Errorf("type the username: %w", err)
+			}
+		}
+		if err := chromedp.SendKeys(password, l.Password, chromedp.ByQuery).Do(ctx); err != nil {
+			return fmt.Errorf("type the password: %w", err)
+		}
+		if l.SubmitSelector != "" {
+			return chromedp.Click(l.SubmitSelector, chromedp.ByQuery).Do(ctx)
+		}
+		return chromedp.Submit(password, chromedp.ByQuery).Do(ctx)
+	})
+}
+
+// Returns an action waiting until the page shows the user is signed in
+func (l *BrowserLogin) awaitSignedIn() chromedp.Action {
+	script := `!document.querySelector(` + strconv.Quote(cmp.Or(l.PasswordSelector, defaultPasswordSelector)) + `)` // Form gone
+	if l.SuccessSelector != "" {
+		script = `!!document.querySelector(` + strconv.Quote(l.SuccessSelector) + `)`
+	}
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		ticker := time.NewTicker(250 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			var signedIn bool
+			if err := chromedp.Evaluate(script, &signedIn).Do(ctx); err == nil && signedIn { // Errors while the next page loads
+				return nil
+			}
+			select {
+			case <-ctx.Done():
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					return errors.New("still not signed in; check the credentials and selectors, or watch the login in a visible browser")
+				}
+				return ctx.Err()
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+// Returns an action copying the browser's cookies into Jar
+func (l *BrowserLogin) exportCookies() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if l.Jar == nil {
+			return nil
+		}
+		cookies, err := storage.GetCookies().Do(ctx)
+		if err != nil {
+			return err
+		}
+		AddCookies(l.Jar, httpCookies(cookies))
+		return nil
+	})
+}
+
+// Converts cookies to the parameters Chrome sets them from
+func cookieParams(cookies []*http.Cookie) []*network.CookieParam {
+	params := make([]*network.CookieParam, 0, len(cookies))
+	for _, cookie := range cookies {
+		param := &network.CookieParam{
+			Name: cookie.Name, Value: cookie.Value, Domain: cookie.Domain, Path: cmp.Or(cookie.Path, "/"),
+			Secure: cookie.Secure, HTTPOnly: cookie.HttpOnly,
+		}
+		if !cookie.Expires.IsZero() {
+			expires := cdp.TimeSinceEpoch(cookie.Expires)
+			param.Expires = &expires
+		}
+		params = append(params, param)
+	}
+	return params
+}
+
+// Converts the browser's cookies to net/http ones
+func httpCookies(cookies []*network.Cookie) []*http.Cookie {
+	converted := make([]*http.Cookie, 0, len(cookies))
+	for _, cookie := range cookies {
+		exported := &http.Cookie{
+			Name: cookie.Name, Value: cookie.Value, Domain: cookie.Domain, Path: cookie.Path,
+			Secure: cookie.Secure, HttpOnly: cookie.HTTPOnly,
+		}
+		if !cookie.Session && cookie.Expires > 0 {
+			exported.Expires = time.Unix(int64(cookie.Expires), 0)
+		}
+		converted = append(converted, exported)
+	}
+	return converted
+}
+
+// AddCookies stores cookies carrying their own Domain in jar, as if each domain had set them
+func AddCookies(jar http.CookieJar, cookies []*http.Cookie) {
+	for _, cookie := range cookies {
+		host := strings.TrimPrefix(cookie.Domain, ".")
+		if host == "" {
+			continue
+		}
+		stored := *cookie
+		if !strings.HasPrefix(cookie.Domain, ".") {
+			stored.Domain = "" // Host-only, as the browser had it
+		}
+		jar.SetCookies(&url.URL{Scheme: "https", Host: host, Path: "/"}, []*http.Cookie{&stored})
+	}
+}
+
+// A cookie as browser extensions and DevTools export it
+type exportedCookie struct {
+	Name           string  `json:"name"`
+	Value          string  `json:"value"`
+	Domain         string  `json:"domain"`
+	Path           string  `json:"path"`
+	Expires        float64 `json:"expires"`
+	ExpirationDate float64 `json:"expirationDate"` // Name used by cookie editor extensions
+	Secure         bool    `json:"secure"`
+	HTTPOnly       bool    `json:"httpOnly"`
+}
+
+// LoadCookies reads a session exported from a browser: a JSON array of cookies with name, value, domain, path and
+// expires (or expirationDate) as DevTools and cookie extensions write them, or a Netscape cookies.txt file
+func LoadCookies(path string) ([]*http.Cookie, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cookies []*http.Cookie
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		var exported []exportedCookie
+		if err := json.Unmarshal(trimmed, &exported); err != nil {
+			return nil, fmt.Errorf("parse cookies %s: %w", path, err)
+		}
+		for _, cookie := range exported {
+			parsed := &http.Cookie{Name: cookie.Name, Value: cookie.Value, Domain: cookie.Domain, Path: cookie.Path, Secure: cookie.Secure, HttpOnly: cookie.HTTPOnly}
+			if expires := cmp.Or(cookie.Expires, cookie.ExpirationDate); expires > 0 {
+				parsed.Expires = time.Unix(int64(expires), 0)
+			}
+			cookies = append(cookies, parsed)
+		}
+	} else if cookies, err = parseCookiesTxt(data); err != nil {
+		return nil, fmt.Errorf("parse cookies %s: %w", path, err)
+	}
+	for _, cookie := range cookies {
+		if cookie.Name == "" || cookie.Domain == "" {
+			return nil, fmt.Errorf("parse cookies %s: every cookie needs a name and a domain", path)
+		}
+	}
+	return cookies, nil
+}
+
+// Parses the tab-separated Netscape format curl and browser add-ons write
+func parseCookiesTxt(data []byte) ([]*http.Cookie, error) {
+	var cookies []*http.Cookie
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		httpOnly := strings.HasPrefix(text, "#HttpOnly_")
+		text = strings.TrimPrefix(text, "#HttpOnly_")
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("line %d: want 7 tab-separated fields, got %d", line, len(fields))
+		}
+		cookie := &http.Cookie{Domain: fields[0], Path: fields[2], Secure: strings.EqualFold(fields[3], "TRUE"), Name: fields[5], Value: fields[6], HttpOnly: httpOnly}
+		if strings.EqualFold(fields[1], "TRUE") && !strings.HasPrefix(cookie.Domain, ".") {
+			cookie.Domain = "." + cookie.Domain // Sent to subdomains too
+		}
+		if expires, err := strconv.ParseInt(fields[4], 10, 64); err == nil && expires > 0 {
+			cookie.Expires = time.Unix(expires, 0)
+		}
+		cookies = append(cookies, cookie)
+	}
+	return cookies, scanner.Err()
+}
@@ -0,0 +1,51 @@
+package sdscraper
+
+import ( // Import required packages
+	"net/http"      // For the version endpoint
+	"runtime/debug" // For VCS stamps of go build
+	"sync"          // For computing the build once
+	"time"          // For the response's zero modification time
+)
+
+// Stamped by release builds, e.g. -ldflags "-X github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper.version=v1.4.0
+// -X ...sdscraper.commit=abc1234 -X ...sdscraper.buildDate=2025-06-01T12:00:00Z"
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// BuildInfo identifies the build that produced a manifest, report or response
+type BuildInfo struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit,omitempty"`
+	Date    string `json:"date,omitempty"` // RFC 3339 build or commit time
+}
+
+// Build returns the running binary's version, falling back to what go build recorded when the ldflags were not set
+var Build = sync.OnceValue(func() BuildInfo {
+	build := BuildInfo{Version: version, Commit: commit, Date: buildDate}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return build
+	}
+	if build.Version == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" { // go install module@version
+		build.Version = info.Main.Version
+	}
+	for _, setting := range info.Settings {
+		switch {
+		case setting.Key == "vcs.revision" && build.Commit == "":
+			build.Commit = setting.Value
+		case setting.Key == "vcs.time" && build.Date == "":
+			build.Date = setting.Value
+		case setting.Key == "vcs.modified" && setting.Value == "true" && build.Commit != "" && commit == "":
+			build.Commit += "-dirty"
+		}
+	}
+	return build
+})
+
+// Serves the build as JSON, so support can tell which binary a mirror runs
+func handleVersion(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, Build(), time.Time{})
+}
@@ -0,0 +1,78 @@
+package sdscraper
+
+import ( // Import required packages
+	"encoding/json" // For decoding the request body
+	"errors"        // For matching sentinel errors
+	"fmt"           // For error messages
+	"log/slog"      // For structured logging
+	"net/http"      // For the HTTP server
+	"slices"        // For streaming the file list
+	"sort"          // For a stable archive order
+	"time"          // For the archive name
+)
+
+const maxBulkDocuments = 1000 // Largest selection accepted by POST /downloads
+
+// Body of POST /downloads
+type bulkDownloadRequest struct {
+	Documents []string `json:"documents"`      // File names as listed in the catalog
+	Tags      string   `json:"tags,omitempty"` // Tag filter selecting documents besides those named, e.g. "hazardous,-internal"
+}
+
+// Streams a zip of the requested documents, fetching any that are catalogued but not yet local
+func (s *Server) handleBulkDownload(w http.ResponseWriter, r *http.Request) {
+	var request bulkDownloadRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&request); err != nil {
+		http.Error(w, "body must be JSON like {\"documents\": [\"name.pdf\"]} or {\"tags\": \"hazardous\"}", http.StatusBadRequest)
+		return
+	}
+	if request.Tags != "" {
+		tags, err := ParseTagFilter(request.Tags)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		request.Documents = append(request.Documents, s.tagged(tags)...)
+	}
+	names := removeDuplicatesFromSlice(request.Documents) // The same file only once per archive
+	if len(names) == 0 || len(names) > maxBulkDocuments {
+		http.Error(w, fmt.Sprintf("request between 1 and %d documents", maxBulkDocuments), http.StatusBadRequest)
+		return
+	}
+
+	files := make([]archiveFile, 0, len(names)) // Resolve everything before the first byte is sent
+	for _, name := range names {
+		_, filePath, err := s.ensureLocal(name)
+		switch {
+		case errors.Is(err, errNotCatalogued):
+			http.Error(w, fmt.Sprintf("%s: %v", name, err), http.StatusNotFound)
+			return
+		case err != nil:
+			http.Error(w, fmt.Sprintf("%s: %v", name, err), http.StatusBadGateway)
+			return
+		}
+		files = append(files, archiveFile{name: name, path: filePath})
+	}
+
+	archiveName := fmt.Sprintf("sds-%s.zip", time.Now().UTC().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", archiveName))
+	if err := writeZip(w, slices.Values(files)); err != nil { // Headers are gone, so the client sees a truncated zip
+		slog.Error("Streaming archive failed", "archive", archiveName, "err", err)
+	}
+}
+
+// Returns the file names of the live, servable documents passing a tag filter that were ever stored
+func (s *Server) tagged(tags TagFilter) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh()
+	var names []string
+	for _, entry := range s.catalog.Documents {
+		if entry.DeletedAt.IsZero() && entry.SHA256 != "" && s.tags.Match(entry.Tags) && tags.Match(entry.Tags) {
+			names = append(names, entry.Filename)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,69 @@
+package sdscraper
+
+import ( // Import required packages
+	"log/slog"      // For structured logging
+	"os"            // For removing evicted files
+	"path/filepath" // For OS-independent path operations
+	"sort"          // For least-recently-used ordering
+	"time"          // For access timestamps
+)
+
+// Describes a locally cached document considered for eviction
+type cachedDocument struct {
+	sourceURL string    // Catalog key of the document
+	path      string    // Location on disk
+	size      int64     // Bytes used on disk
+	lastUsed  time.Time // Most recent access or download
+}
+
+// Removes least-recently-used documents until the cache fits within maxBytes, skipping pinned, legal-hold and uploaded
+// entries, and returns how many went; evicted entries keep their catalog metadata and hashes but no longer count as downloaded
+func evictToBudget(catalog *Manifest, outputDir string, maxBytes int64, keepURL string) (evicted int) {
+	if maxBytes <= 0 { // Zero means unlimited
+		return 0
+	}
+
+	var candidates []cachedDocument // Documents that may be removed
+	var totalBytes int64            // Current local footprint
+	for sourceURL, entry := range catalog.Documents {
+		if entry.AliasOf != "" { // The file is accounted for under the original
+			continue
+		}
+		path := filepath.Join(outputDir, entry.Filename)
+		info, err := os.Stat(path) // Only local files use space
+		if err != nil || info.IsDir() {
+			continue
+		}
+		totalBytes += info.Size()
+		if entry.Pinned || entry.LegalHold || entry.Source == SourceUpload || sourceURL == keepURL { // Protected, or impossible to fetch again
+			continue
+		}
+		lastUsed := entry.LastAccessed // Prefer access time, fall back to download time
+		if lastUsed.IsZero() {
+			lastUsed = entry.DownloadedAt
+		}
+		candidates = append(candidates, cachedDocument{sourceURL: sourceURL, path: path, size: info.Size(), lastUsed: lastUsed})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].lastUsed.Before(candidates[j].lastUsed) }) // Oldest first
+
+	for _, candidate := range candidates {
+		if totalBytes <= maxBytes { // Budget satisfied
+			return evicted
+		}
+		if err := removeFile(candidate.path); err != nil { // Catalog entry stays, so it can be fetched again
+			slog.Error("Evicting cached file failed", "path", candidate.path, "err", err)
+			continue
+		}
+		totalBytes -= candidate.size
+		evicted++
+		entry := catalog.Documents[candidate.sourceURL]
+		entry.DownloadedAt, entry.ETag, entry.LastModified = time.Time{}, "", "" // No local copy for fsck to expect or validators to describe
+		slog.Info("Evicted to stay within cache budget", "path", candidate.path, "bytes", candidate.size)
+	}
+
+	if totalBytes > maxBytes { // Only protected documents are left
+		slog.Warn("Cache over budget, remaining documents are protected", "bytes", totalBytes, "budget", maxBytes)
+	}
+	return evicted
+}
@@ -0,0 +1,36 @@
+package sdscraper
+
+import ( // Import required packages
+	"os"            // For the cached files
+	"path/filepath" // For file paths
+	"testing"       // For the test harness
+	"time"          // For access times
+)
+
+func TestEvictToBudget(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	catalog := NewManifest()
+	for name, accessed := range map[string]time.Duration{"old.pdf": 3 * time.Hour, "recent.pdf": time.Hour} {
+		os.WriteFile(filepath.Join(dir, name), make([]byte, 100), 0o644)
+		catalog.Documents["https://www.gojo.com/"+name] = &ManifestEntry{Filename: name, SHA256: "ab", ETag: `"1"`,
+			DownloadedAt: now.Add(-4 * time.Hour), LastAccessed: now.Add(-accessed)}
+	}
+
+	if evicted := evictToBudget(catalog, dir, 150, ""); evicted != 1 {
+		t.Fatalf("evicted %d documents, want 1", evicted)
+	}
+	old, recent := catalog.Documents["https://www.gojo.com/old.pdf"], catalog.Documents["https://www.gojo.com/recent.pdf"]
+	if _, err := os.Stat(filepath.Join(dir, "old.pdf")); !os.IsNotExist(err) {
+		t.Errorf("the least recently used file was kept: %v", err)
+	}
+	if !old.DownloadedAt.IsZero() || old.ETag != "" || old.SHA256 != "ab" {
+		t.Errorf("evicted entry: DownloadedAt %v, ETag %q, SHA256 %q; want no local state and the hash kept", old.DownloadedAt, old.ETag, old.SHA256)
+	}
+	if recent.DownloadedAt.IsZero() {
+		t.Error("the kept entry lost its download time")
+	}
+	if problems := Fsck(catalog, FsckOptions{OutputDir: dir}); len(problems) > 0 {
+		t.Errorf("fsck after eviction: %+v", problems)
+	}
+}
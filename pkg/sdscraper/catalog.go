@@ -0,0 +1,195 @@
+package sdscraper
+
+import ( // Import required packages
+	"errors"  // For sentinel errors
+	"fmt"     // For error messages
+	"net/url" // For query parameters
+	"slices"  // For locale matching
+	"sort"    // For ordering results
+	"strconv" // For numeric parameters
+	"strings" // For case-insensitive matching
+	"time"    // For updated-since filtering
+)
+
+const ( // Catalog paging limits
+	defaultCatalogLimit = 100  // Page size when the client doesn't ask
+	maxCatalogLimit     = 1000 // Largest page a client may request
+)
+
+// One page of the catalog as returned by GET /catalog
+type catalogPage struct {
+	Documents  []catalogItem `json:"documents"`             // Entries on this page
+	Total      int           `json:"total"`                 // Entries matching the filters
+	Offset     int           `json:"offset"`                // Index of the first entry on this page
+	Limit      int           `json:"limit"`                 // Requested page size
+	NextOffset *int          `json:"next_offset,omitempty"` // Offset of the next page, absent on the last one
+}
+
+// Parsed catalog query string
+type catalogQuery struct {
+	limit        int       // Page size
+	offset       int       // Entries to skip
+	locale       string    // Only entries listed under this locale
+	brand        string    // Only entries with this brand
+	vendor       string    // Only entries listed by this vendor
+	distinct     bool      // Leave out duplicates of other vendors' entries on the page
+	tags         TagFilter // Only entries passing this tag filter
+	updatedSince time.Time // Only entries downloaded after this time
+	sortKey      string    // Field to sort on
+	descending   bool      // Reverse the sort order
+}
+
+// Reads limit, offset, locale, brand, vendor, distinct, tags, updated-since and sort from the query string
+func parseCatalogQuery(values url.Values) (catalogQuery, error) {
+	query := catalogQuery{limit: defaultCatalogLimit, sortKey: "filename"}
+
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxCatalogLimit {
+			return query, fmt.Errorf("limit must be between 1 and %d", maxCatalogLimit)
+		}
+		query.limit = limit
+	}
+	if raw := values.Get("offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			return query, fmt.Errorf("offset must be a non-negative integer")
+		}
+		query.offset = offset
+	}
+	if raw := values.Get("updated-since"); raw != "" {
+		since, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return query, fmt.Errorf("updated-since must be an RFC 3339 timestamp")
+		}
+		query.updatedSince = since
+	}
+	if raw := values.Get("sort"); raw != "" {
+		query.sortKey, query.descending = strings.TrimPrefix(raw, "-"), strings.HasPrefix(raw, "-") // "-size" sorts descending
+		switch query.sortKey {
+		case "filename", "url", "size", "updated":
+		default:
+			return query, fmt.Errorf("sort must be one of filename, url, size, updated (prefix with - to reverse)")
+		}
+	}
+	tags, err := ParseTagFilter(values.Get("tags"))
+	if err != nil {
+		return query, err
+	}
+	query.tags = tags
+	query.locale = values.Get("locale")
+	query.brand = values.Get("brand")
+	query.vendor = values.Get("vendor")
+	if raw := values.Get("distinct"); raw != "" {
+		distinct, err := strconv.ParseBool(raw)
+		if err != nil {
+			return query, fmt.Errorf("distinct must be true or false")
+		}
+		query.distinct = distinct
+	}
+	return query, nil
+}
+
+// Filters, sorts and slices items according to the query
+func (q catalogQuery) apply(items []catalogItem) catalogPage {
+	matched := items[:0:0] // Fresh slice, leaving items untouched
+	byURL := make(map[string]*ManifestEntry, len(items))
+	for _, item := range items {
+		byURL[item.URL] = item.ManifestEntry
+	}
+	for _, item := range items {
+		if !q.matches(item.ManifestEntry) || q.distinct && item.coveredDuplicate(byURL, q.matches) {
+			continue
+		}
+		matched = append(matched, item)
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if q.descending {
+			a, b = b, a
+		}
+		switch q.sortKey {
+		case "url":
+			return a.URL < b.URL
+		case "size":
+			return a.Size < b.Size
+		case "updated":
+			return a.DownloadedAt.Before(b.DownloadedAt)
+		}
+		return a.Filename < b.Filename
+	})
+
+	page := catalogPage{Total: len(matched), Offset: q.offset, Limit: q.limit, Documents: []catalogItem{}}
+	if q.offset < len(matched) {
+		end := min(q.offset+q.limit, len(matched))
+		page.Documents = matched[q.offset:end]
+		if end < len(matched) {
+			page.NextOffset = &end
+		}
+	}
+	return page
+}
+
+// Reports whether an entry passes the query's filters
+func (q catalogQuery) matches(entry *ManifestEntry) bool {
+	switch {
+	case q.locale != "" && !slices.ContainsFunc(entry.Locales, func(l string) bool { return strings.EqualFold(l, q.locale) }):
+		return false
+	case q.brand != "" && !strings.EqualFold(entry.Brand, q.brand):
+		return false
+	case q.vendor != "" && !strings.EqualFold(entry.Vendor, q.vendor):
+		return false
+	case !q.updatedSince.IsZero() && !entry.DownloadedAt.After(q.updatedSince):
+		return false
+	}
+	return q.tags.Match(entry.Tags)
+}
+
+// Response of GET /catalog/changes
+type changesPage struct {
+	Changes      []Change `json:"changes"`       // Changes after the requested point, oldest first
+	NextCursor   int64    `json:"next_cursor"`   // Pass as since= to continue from here
+	OldestCursor int64    `json:"oldest_cursor"` // Earliest retained change; older cursors must resync
+	HasMore      bool     `json:"has_more"`      // More changes exist beyond this page
+}
+
+// Returns the changes after since, which is either a cursor from a previous response or an RFC 3339 timestamp
+func changesSince(m *Manifest, since string, limit int) (changesPage, error) {
+	page := changesPage{Changes: []Change{}, NextCursor: m.Sequence}
+	if len(m.Changes) > 0 {
+		page.OldestCursor = m.Changes[0].Sequence
+	}
+
+	include := func(Change) bool { return true } // Empty since returns the whole retained log
+	if since != "" {
+		if cursor, err := strconv.ParseInt(since, 10, 64); err == nil {
+			if cursor < page.OldestCursor-1 { // Changes between cursor and the log start were trimmed
+				return page, errCursorExpired
+			}
+			include = func(c Change) bool { return c.Sequence > cursor }
+		} else if at, err := time.Parse(time.RFC3339, since); err == nil {
+			include = func(c Change) bool { return c.Time.After(at) }
+		} else {
+			return page, fmt.Errorf("since must be a cursor or an RFC 3339 timestamp")
+		}
+	}
+
+	for _, change := range m.Changes {
+		if !include(change) {
+			continue
+		}
+		if len(page.Changes) == limit {
+			page.HasMore = true
+			break
+		}
+		page.Changes = append(page.Changes, change)
+		page.NextCursor = change.Sequence
+	}
+	if !page.HasMore {
+		page.NextCursor = m.Sequence // Nothing left, so the client is fully caught up
+	}
+	return page, nil
+}
+
+var errCursorExpired = errors.New("cursor is older than the retained change log; resync from GET /catalog")
@@ -0,0 +1,172 @@
+package sdscraper
+
+import ( // Import required packages
+	"context"      // For query cancellation
+	"database/sql" // For the SQLite catalog
+	"fmt"          // For error wrapping
+	"strings"      // For joining locales
+	"time"         // For sync timestamps
+
+	_ "modernc.org/sqlite" // Pure-Go SQLite driver, so builds need no C toolchain
+)
+
+// Schema of the SQLite catalog; first_seen is kept by the database itself, as the manifest has no such field, and
+// tags live in their own table so existing catalogs gain it without a migration
+const catalogSchemaSQL = `
+CREATE TABLE IF NOT EXISTS documents (
+	url           TEXT PRIMARY KEY,
+	filename      TEXT NOT NULL,
+	sha256        TEXT NOT NULL DEFAULT '',
+	size          INTEGER NOT NULL DEFAULT 0,
+	type          TEXT NOT NULL DEFAULT '',
+	product       TEXT NOT NULL DEFAULT '',
+	sku           TEXT NOT NULL DEFAULT '',
+	language      TEXT NOT NULL DEFAULT '',
+	locales       TEXT NOT NULL DEFAULT '',
+	revision      TEXT NOT NULL DEFAULT '',
+	first_seen    TEXT NOT NULL,
+	downloaded_at TEXT NOT NULL DEFAULT '',
+	last_verified TEXT NOT NULL DEFAULT '',
+	deleted_at    TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS documents_product ON documents (product);
+CREATE INDEX IF NOT EXISTS documents_sha256 ON documents (sha256);
+CREATE TABLE IF NOT EXISTS document_tags (
+	url TEXT NOT NULL,
+	tag TEXT NOT NULL,
+	PRIMARY KEY (url, tag)
+);
+CREATE INDEX IF NOT EXISTS document_tags_tag ON document_tags (tag);
+`
+
+// CatalogDB is a SQLite copy of the manifest for ad-hoc questions, e.g. when the SDS for a product was last fetched
+type CatalogDB struct {
+	db *sql.DB
+}
+
+// OpenCatalogDB opens or creates the SQLite catalog at path; readOnly opens an existing one without taking write locks
+func OpenCatalogDB(path string, readOnly bool) (*CatalogDB, error) {
+	if !readOnly {
+		if err := CheckWritable("open", path); err != nil {
+			return nil, err
+		}
+	}
+	dsn := "file:" + path + "?_pragma=busy_timeout(5000)" // Wait out a crawl that is syncing
+	if readOnly {
+		dsn += "&mode=ro"
+	}
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1) // SQLite serialises writers anyway
+	if !readOnly {
+		if _, err := db.Exec(catalogSchemaSQL); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("create catalog schema: %w", err)
+		}
+	} else if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &CatalogDB{db: db}, nil
+}
+
+// Close releases the database
+func (c *CatalogDB) Close() error {
+	return c.db.Close()
+}
+
+// Sync makes the documents table match the manifest in one transaction, keeping each row's first_seen
+func (c *CatalogDB) Sync(ctx context.Context, m *Manifest) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // No-op after Commit
+
+	now := formatTime(time.Now())
+	upsert, err := tx.PrepareContext(ctx, `
+		INSERT INTO documents (url, filename, sha256, size, type, product, sku, language, locales, revision, first_seen, downloaded_at, last_verified, deleted_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (url) DO UPDATE SET
+			filename = excluded.filename, sha256 = excluded.sha256, size = excluded.size, type = excluded.type,
+			product = excluded.product, sku = excluded.sku, language = excluded.language, locales = excluded.locales,
+			revision = excluded.revision, downloaded_at = excluded.downloaded_at, last_verified = excluded.last_verified,
+			deleted_at = excluded.deleted_at`)
+	if err != nil {
+		return err
+	}
+	defer upsert.Close()
+	if _, err := tx.ExecContext(ctx, `CREATE TEMP TABLE IF NOT EXISTS synced (url TEXT PRIMARY KEY)`); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM synced`); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM document_tags`); err != nil { // Rewritten whole; tags are few
+		return err
+	}
+	for documentURL, entry := range m.Documents {
+		if _, err := upsert.ExecContext(ctx, documentURL, entry.Filename, entry.SHA256, entry.Size, entry.Type, entry.Product, entry.SKU,
+			entry.Language, strings.Join(entry.Locales, ","), entry.Revision, now, formatTime(entry.DownloadedAt),
+			formatTime(entry.CheckedAt), formatTime(entry.DeletedAt)); err != nil {
+			return fmt.Errorf("sync %s: %w", documentURL, err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO synced (url) VALUES (?)`, documentURL); err != nil {
+			return err
+		}
+		for _, tag := range entry.Tags {
+			if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO document_tags (url, tag) VALUES (?, ?)`, documentURL, tag); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM documents WHERE url NOT IN (SELECT url FROM synced)`); err != nil { // Purged from the manifest
+		return err
+	}
+	return tx.Commit()
+}
+
+// Query runs a SELECT and returns its column names and rows, every value rendered as text
+func (c *CatalogDB) Query(ctx context.Context, query string, args ...any) ([]string, [][]string, error) {
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+	var table [][]string
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		targets := make([]any, len(columns))
+		for i := range values {
+			targets[i] = &values[i]
+		}
+		if err := rows.Scan(targets...); err != nil {
+			return nil, nil, err
+		}
+		row := make([]string, len(columns))
+		for i, value := range values {
+			row[i] = value.String // NULL as empty
+		}
+		table = append(table, row)
+	}
+	return columns, table, rows.Err()
+}
+
+// Syncs the manifest into the SQLite catalog at path, opening and closing it around the sync
+func syncCatalogDB(ctx context.Context, path string, m *Manifest) error {
+	catalog, err := OpenCatalogDB(path, false)
+	if err != nil {
+		return err
+	}
+	if err := catalog.Sync(ctx, m); err != nil {
+		catalog.Close()
+		return err
+	}
+	return catalog.Close()
+}
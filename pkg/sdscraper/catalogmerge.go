@@ -0,0 +1,64 @@
+package sdscraper
+
+import ( // Import required packages
+	"cmp"  // For vendor defaults
+	"path" // For slash-separated file names
+)
+
+// CatalogPart is one vendor's catalog to merge, kept in Dir below the merged catalog's output directory
+type CatalogPart struct {
+	Vendor   string    // Recorded on entries that carry no vendor yet
+	Manifest *Manifest // The vendor site's own manifest
+	Dir      string    // Slash-separated folder of the vendor's files, relative to the merged output directory
+}
+
+// MergeResult counts what MergeCatalogs combined
+type MergeResult struct {
+	Documents  int            `json:"documents"`  // Entries in the merged catalog
+	Vendors    map[string]int `json:"vendors"`    // Entries per vendor
+	Duplicates int            `json:"duplicates"` // Entries marked as another vendor's content, or listed twice
+}
+
+// MergeCatalogs combines the manifests of several vendor sites into one catalog over the common output directory,
+// so serve, export and fsck work across vendors: file names gain the vendor's folder, every entry names its vendor,
+// and a document whose content another vendor already carries, e.g. a private-label product, is marked as a
+// duplicate of the first vendor's in parts order. The vendor manifests are left untouched
+func MergeCatalogs(parts []CatalogPart) (*Manifest, MergeResult) {
+	merged := NewManifest()
+	result := MergeResult{Vendors: make(map[string]int)}
+	originals := make(map[string]*ManifestEntry) // Content hash to the first vendor's document holding it
+	for _, part := range parts {
+		for _, documentURL := range sortedKeys(part.Manifest.Documents) {
+			if _, taken := merged.Documents[documentURL]; taken { // Both vendors list the same URL
+				result.Duplicates++
+				continue
+			}
+			entry := *part.Manifest.Documents[documentURL] // The vendor's manifest keeps its own names
+			entry.URL = documentURL
+			entry.Vendor = cmp.Or(entry.Vendor, part.Vendor)
+			if entry.Filename != "" {
+				entry.Filename = path.Join(part.Dir, entry.Filename)
+			}
+			entry.DuplicateOf = ""
+			if entry.SHA256 != "" && entry.AliasOf == "" && entry.DeletedAt.IsZero() {
+				if original, ok := originals[entry.SHA256]; ok && original.Vendor != entry.Vendor {
+					entry.DuplicateOf = original.URL
+					result.Duplicates++
+				} else if !ok {
+					originals[entry.SHA256] = &entry
+				}
+			}
+			merged.Documents[documentURL] = &entry
+			result.Vendors[entry.Vendor]++
+		}
+	}
+	result.Documents = len(merged.Documents)
+	return merged, result
+}
+
+// Reports whether the entry is a duplicate that selected, which applies the same filters, already covers through
+// its original; a duplicate whose original is filtered out, e.g. by vendor, stands for that content itself
+func (e *ManifestEntry) coveredDuplicate(documents map[string]*ManifestEntry, selected func(*ManifestEntry) bool) bool {
+	original, ok := documents[e.DuplicateOf]
+	return e.DuplicateOf != "" && ok && selected(original)
+}
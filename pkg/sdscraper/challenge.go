@@ -0,0 +1,202 @@
+package sdscraper
+
+import ( // Import required packages
+	"context"       // For chromedp actions and notifications
+	"fmt"           // For error messages
+	"log/slog"      // For structured logging
+	"net/http"      // For the session jar
+	"os"            // For screenshots and the cookie file
+	"path/filepath" // For screenshot paths
+	"regexp"        // For naming screenshots
+	"strings"       // For matching challenge markers
+	"time"          // For pausing
+
+	"github.com/chromedp/cdproto/storage" // For injecting a solved session
+	"github.com/chromedp/chromedp"        // For inspecting and reloading the page
+)
+
+// Challenge describes a CAPTCHA or bot-check interstitial served instead of a listing
+type Challenge struct {
+	URL        string    `json:"url"`                  // Page that was challenged
+	Kind       string    `json:"kind"`                 // recaptcha, hcaptcha, turnstile, cloudflare, perimeterx, datadome or captcha
+	Screenshot string    `json:"screenshot,omitempty"` // PNG of the page as the browser showed it
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// ChallengeError reports a challenge that was not solved in time
+type ChallengeError struct {
+	Challenge
+}
+
+// Error implements error
+func (e *ChallengeError) Error() string {
+	message := fmt.Sprintf("%s blocked by a %s challenge", e.URL, e.Kind)
+	if e.Screenshot != "" {
+		message += " (screenshot " + e.Screenshot + ")"
+	}
+	return message + "; solve it in a visible browser while the run waits, or supply the solved session's cookies"
+}
+
+// ChallengeNotifier is implemented by notifiers that can also tell the operator a run is waiting at a challenge
+type ChallengeNotifier interface {
+	NotifyChallenge(ctx context.Context, challenge Challenge) error
+}
+
+// ChallengePolicy decides what Chrome does when a listing comes back as a challenge: with Wait set the render
+// pauses, the operator is notified with a screenshot, and the run resumes once the page no longer shows the
+// challenge, whether it was solved in the visible window or a solved session was written to CookiesFile
+type ChallengePolicy struct {
+	Wait          time.Duration       // How long a render waits for the challenge to be solved, zero fails at once
+	ScreenshotDir string              // Where screenshots of challenged pages go, empty for none
+	CookiesFile   string              // Watched while paused; cookies written to it are injected and the page reloaded
+	Notifiers     []ChallengeNotifier // Told when a render pauses
+	Jar           http.CookieJar      // Receives the browser's cookies once solved, so downloads keep the clearance; may be nil
+}
+
+// Marker substrings of challenge pages, checked in order on the lowercased HTML; interstitials come first so a
+// Cloudflare page embedding Turnstile reports the vendor. Widgets are only a challenge on a page with few links,
+// since ordinary pages embed reCAPTCHA in contact and newsletter forms
+var challengeMarkers = []struct {
+	kind         string
+	marker       string
+	interstitial bool
+}{
+	{"cloudflare", "/cdn-cgi/challenge-platform/", true},
+	{"cloudflare", "<title>just a moment...</title>", true},
+	{"cloudflare", "cf-chl-", true},
+	{"perimeterx", "px-captcha", true},
+	{"datadome", "captcha-delivery.com", true},
+	{"recaptcha", "www.google.com/recaptcha/", false},
+	{"recaptcha", "g-recaptcha", false},
+	{"hcaptcha", "hcaptcha.com/", false},
+	{"turnstile", "challenges.cloudflare.com/turnstile", false},
+	{"captcha", "captcha", false},
+}
+
+var unsafeFilename = regexp.MustCompile(`[^A-Za-z0-9]+`) // Runs of characters replaced in screenshot names
+
+// Returns the kind of challenge content is, or "" for an ordinary page
+func detectChallenge(content string) string {
+	lower := strings.ToLower(content)
+	fewLinks := strings.Count(lower, "<a ") < 5
+	for _, candidate := range challengeMarkers {
+		if (candidate.interstitial || fewLinks) && strings.Contains(lower, candidate.marker) {
+			return candidate.kind
+		}
+	}
+	return ""
+}
+
+// Returns an action checking the loaded page for a challenge and, per the policy, pausing until it is gone
+func (p ChallengePolicy) action(pageURL string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		kind, err := pageChallenge(ctx)
+		if err != nil || kind == "" {
+			return err
+		}
+		challenge := Challenge{URL: pageURL, Kind: kind, DetectedAt: time.Now().UTC()}
+		challenge.Screenshot = p.screenshot(ctx, pageURL)
+		if p.Wait <= 0 {
+			return &ChallengeError{challenge}
+		}
+		slog.Warn("Paused at a challenge; solve it in the browser window or write the solved session's cookies to the cookie file",
+			"url", pageURL, "kind", kind, "screenshot", challenge.Screenshot, "cookies_file", p.CookiesFile, "wait", p.Wait)
+		for _, notifier := range p.Notifiers {
+			if err := notifier.NotifyChallenge(ctx, challenge); err != nil {
+				slog.Error("Challenge notification failed", "notifier", fmt.Sprintf("%T", notifier), "err", err)
+			}
+		}
+
+		cookiesSeen := modTime(p.CookiesFile) // Only a file written after the pause counts as a solution
+		deadline := time.Now().Add(p.Wait)
+		for time.Now().Before(deadline) {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(2 * time.Second):
+			}
+			if written := modTime(p.CookiesFile); written.After(cookiesSeen) {
+				cookiesSeen = written
+				if err := p.injectCookies(ctx); err != nil {
+					slog.Warn("Injecting the solved session failed", "cookies_file", p.CookiesFile, "err", err)
+					continue
+				}
+			}
+			if kind, err := pageChallenge(ctx); err == nil && kind == "" { // Errors while the page navigates
+				slog.Info("Challenge solved; resuming", "url", pageURL, "waited", time.Since(challenge.DetectedAt).Round(time.Second))
+				return p.exportCookies(ctx)
+			}
+		}
+		return &ChallengeError{challenge}
+	})
+}
+
+// Returns the kind of challenge the current page shows
+func pageChallenge(ctx context.Context) (string, error) {
+	var content string
+	if err := chromedp.OuterHTML("html", &content).Do(ctx); err != nil {
+		return "", err
+	}
+	return detectChallenge(content), nil
+}
+
+// Saves a screenshot of the current page, returning its path or "" when none was taken
+func (p ChallengePolicy) screenshot(ctx context.Context, pageURL string) string {
+	if p.ScreenshotDir == "" {
+		return ""
+	}
+	var image []byte
+	if err := chromedp.CaptureScreenshot(&image).Do(ctx); err != nil {
+		slog.Warn("Screenshot of the challenge failed", "url", pageURL, "err", err)
+		return ""
+	}
+	name := strings.Trim(unsafeFilename.ReplaceAllString(pageURL, "-"), "-")
+	path := filepath.Join(p.ScreenshotDir, fmt.Sprintf("challenge-%s-%s.png", time.Now().UTC().Format("20060102T150405Z"), name))
+	if err := makeDirAll(p.ScreenshotDir, 0o755); err != nil {
+		slog.Warn("Screenshot of the challenge failed", "url", pageURL, "err", err)
+		return ""
+	}
+	if err := writeFile(path, image, 0o644); err != nil {
+		slog.Warn("Screenshot of the challenge failed", "url", pageURL, "err", err)
+		return ""
+	}
+	return path
+}
+
+// Sets the cookies in CookiesFile in the browser and reloads the page with them
+func (p ChallengePolicy) injectCookies(ctx context.Context) error {
+	cookies, err := LoadCookies(p.CookiesFile)
+	if err != nil {
+		return err
+	}
+	if err := storage.SetCookies(cookieParams(cookies)).Do(ctx); err != nil {
+		return err
+	}
+	slog.Info("Injected the solved session; reloading", "cookies", len(cookies))
+	return chromedp.Reload().Do(ctx)
+}
+
+// Copies the browser's cookies into Jar
+func (p ChallengePolicy) exportCookies(ctx context.Context) error {
+	if p.Jar == nil {
+		return nil
+	}
+	cookies, err := storage.GetCookies().Do(ctx)
+	if err != nil {
+		return err
+	}
+	AddCookies(p.Jar, httpCookies(cookies))
+	return nil
+}
+
+// Returns when a file was last written, zero when it does not exist
+func modTime(path string) time.Time {
+	if path == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
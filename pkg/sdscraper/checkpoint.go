@@ -0,0 +1,152 @@
+package sdscraper
+
+import ( // Import required packages
+	"encoding/json" // For the checkpoint file
+	"log/slog"      // For structured logging
+	"os"            // For file handling
+	"sync"          // For sharing run state between workers
+	"time"          // For timestamps
+)
+
+const checkpointEvery = 25 // Documents processed between checkpoint and manifest saves
+
+// Checkpoint records the progress of a run so an interrupted one can resume
+type Checkpoint struct {
+	StartedAt time.Time `json:"started_at"` // When the interrupted run began
+	Pending   []string  `json:"pending"`    // Discovered documents not processed yet, in discovery order
+}
+
+// State shared by the discovery stage and the download workers of one run
+type runState struct {
+	mu        sync.Mutex
+	result    *Result
+	done      map[string]bool  // Documents processed this run
+	startedAt time.Time        // When the run, or the run it resumes, began
+	resumed   bool             // Documents came from a checkpoint instead of discovery
+	listed    bool             // Discovery finished, so Discovered is complete
+	byLocale  map[string]int   // Valid links found per locale listing
+	limiter   *adaptiveLimiter // Download concurrency below Workers, nil for all of them
+}
+
+// Records a discovered link with its locale and listing metadata, reporting whether it is new to this run;
+// documents never downloaded are named from the scraper's FilenameTemplate when one is set, and its tag rules and
+// the rules decided without a document's text tag every document
+func (r *runState) discovered(s *Scraper, documentURL, locale string, meta DocumentMetadata) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry := r.result.Manifest.EntryFor(documentURL)
+	if locale != "" {
+		entry.Locales = mergeLocales(entry.Locales, []string{locale}) // Tag with every listing locale
+	}
+	if locale != "" {
+		r.byLocale[locale]++
+	}
+	entry.applyMetadata(meta)
+	if s.Vendor != "" {
+		entry.Vendor = s.Vendor
+	}
+	ApplyTagRules(entry, s.TagRules)
+	s.Downloader.Rules.tagListed(entry)
+	if s.FilenameTemplate != "" && entry.DownloadedAt.IsZero() && entry.AliasOf == "" { // Existing files keep their names
+		if name := RenderFilename(s.FilenameTemplate, entry); name != "" {
+			entry.Filename = r.result.Manifest.uniqueFilename(name, documentURL)
+		}
+	}
+	for _, known := range r.result.Discovered { // Listings are small enough for a scan
+		if known == documentURL {
+			return false
+		}
+	}
+	r.result.Discovered = append(r.result.Discovered, documentURL)
+	return true
+}
+
+// Marks a document processed and periodically persists progress; callers hold r.mu
+func (r *runState) finish(s *Scraper, documentURL string) {
+	r.done[documentURL] = true
+	if len(r.done)%checkpointEvery == 0 { // Bound the work lost to a crash
+		s.persist(r.result.Manifest, r.checkpoint())
+	}
+}
+
+// Returns the resumable progress, or nil while discovery is incomplete; callers hold r.mu
+func (r *runState) checkpoint() *Checkpoint {
+	if !r.resumed && !r.listed { // A partial list would make the resumed run miss documents
+		return nil
+	}
+	checkpoint := &Checkpoint{StartedAt: r.startedAt}
+	for _, documentURL := range r.result.Discovered {
+		if !r.done[documentURL] {
+			checkpoint.Pending = append(checkpoint.Pending, documentURL)
+		}
+	}
+	return checkpoint
+}
+
+// LookupHash implements HashIndex under the run's lock
+func (r *runState) LookupHash(sha256, excludeURL string) (*ManifestEntry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.result.Manifest.LookupHash(sha256, excludeURL)
+	if !ok {
+		return nil, false
+	}
+	copied := *entry // Callers read it without the lock
+	return &copied, true
+}
+
+// ClaimFilename implements HashIndex under the run's lock
+func (r *runState) ClaimFilename(name, documentURL string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.result.Manifest.ClaimFilename(name, documentURL)
+}
+
+// Loads the checkpoint of an interrupted run, if there is one
+func (s *Scraper) loadCheckpoint() (*Checkpoint, bool) {
+	if s.CheckpointPath == "" || !fileExists(s.CheckpointPath) {
+		return nil, false
+	}
+	var checkpoint Checkpoint
+	if err := json.Unmarshal([]byte(readAFileAsString(s.CheckpointPath)), &checkpoint); err != nil {
+		slog.Warn("Ignoring unreadable checkpoint", "path", s.CheckpointPath, "err", err)
+		return nil, false
+	}
+	if len(checkpoint.Pending) == 0 { // Nothing left to resume
+		return nil, false
+	}
+	return &checkpoint, true
+}
+
+// Saves the manifest and, when enabled and available, the checkpoint
+func (s *Scraper) persist(m *Manifest, checkpoint *Checkpoint) {
+	if err := SaveManifest(s.ManifestPath, m); err != nil {
+		slog.Error("Saving manifest failed", "path", s.ManifestPath, "err", err)
+	}
+	if s.CheckpointPath == "" || checkpoint == nil {
+		return
+	}
+	data, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		slog.Error("Encoding checkpoint failed", "err", err)
+		return
+	}
+	temp := s.CheckpointPath + ".tmp" // Same atomic swap as the manifest
+	if err := writeFile(temp, append(data, '\n'), 0644); err != nil {
+		slog.Error("Writing checkpoint failed", "path", temp, "err", err)
+		return
+	}
+	if err := renameFile(temp, s.CheckpointPath); err != nil {
+		slog.Error("Replacing checkpoint failed", "path", s.CheckpointPath, "err", err)
+	}
+}
+
+// Removes the checkpoint after a completed run
+func (s *Scraper) clearCheckpoint() {
+	if s.CheckpointPath == "" {
+		return
+	}
+	if err := removeFile(s.CheckpointPath); err != nil && !os.IsNotExist(err) {
+		slog.Error("Removing checkpoint failed", "path", s.CheckpointPath, "err", err)
+	}
+}
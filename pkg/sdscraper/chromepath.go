@@ -0,0 +1,60 @@
+package sdscraper
+
+import ( // Import required packages
+	"errors"        // For the not-found error
+	"os"            // For install locations under the user's profile
+	"os/exec"       // For searching PATH
+	"path/filepath" // For joining install locations
+	"runtime"       // For the platform's locations
+)
+
+// ErrNoChrome is returned when no Chrome or Chromium binary is installed where FindChrome looks
+var ErrNoChrome = errors.New("no Chrome or Chromium found on PATH or in the usual install locations")
+
+// Returns the names and paths Chrome and Chromium are installed under on goos, in the order they are tried; Google
+// ships no Chrome for Linux on ARM, so the distributions' Chromium packages and their real binaries come first
+func chromeLocations(goos string) []string {
+	switch goos {
+	case "darwin": // Apple silicon and Intel builds install to the same place
+		home, _ := os.UserHomeDir()
+		return []string{
+			"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+			"/Applications/Chromium.app/Contents/MacOS/Chromium",
+			filepath.Join(home, "Applications/Google Chrome.app/Contents/MacOS/Google Chrome"),
+			filepath.Join(home, "Applications/Chromium.app/Contents/MacOS/Chromium"),
+			"chromium", "google-chrome", // Homebrew and hand-made links
+		}
+	case "windows":
+		profile := os.Getenv("LOCALAPPDATA")
+		return []string{
+			"chrome", "chrome.exe",
+			filepath.Join(os.Getenv("ProgramFiles"), `Google\Chrome\Application\chrome.exe`),
+			filepath.Join(os.Getenv("ProgramFiles(x86)"), `Google\Chrome\Application\chrome.exe`),
+			filepath.Join(profile, `Google\Chrome\Application\chrome.exe`),
+			filepath.Join(profile, `Chromium\Application\chrome.exe`),
+			filepath.Join(os.Getenv("ProgramFiles(x86)"), `Microsoft\Edge\Application\msedge.exe`), // Chromium too, and often all an ARM device has
+		}
+	}
+	return []string{
+		"headless_shell", "headless-shell", // chromedp/headless-shell images
+		"chromium", "chromium-browser", // Debian, Ubuntu, Alpine and Raspberry Pi OS packages, amd64 and arm64 alike
+		"/usr/lib/chromium/chromium", "/usr/lib/chromium-browser/chromium-browser", "/usr/lib64/chromium-browser/chromium-browser",
+		"/snap/bin/chromium",
+		"google-chrome", "google-chrome-stable", "/opt/google/chrome/chrome",
+		"chrome",
+	}
+}
+
+// FindChrome returns the Chrome binary a crawl launches: path itself when given, otherwise the first of the
+// platform's usual names on PATH and install locations that exists
+func FindChrome(path string) (string, error) {
+	if path != "" {
+		return exec.LookPath(path) // A bare name or an absolute path
+	}
+	for _, location := range chromeLocations(runtime.GOOS) {
+		if found, err := exec.LookPath(location); err == nil {
+			return found, nil
+		}
+	}
+	return "", ErrNoChrome
+}
@@ -0,0 +1,92 @@
+package sdscraper
+
+import ( // Import required packages
+	"maps"   // For copying field sources
+	"slices" // For collecting fields
+)
+
+// Extraction methods a metadata field's value may come from
+const (
+	MethodRegex       = "regex"        // Text patterns around the document's link on a listing
+	MethodSource      = "source"       // A structured field of a seed list or vendor portal
+	MethodPDFMetadata = "pdf-metadata" // The PDF's document information or XMP metadata
+	MethodOCR         = "ocr"          // Text recognised in a scanned page
+	MethodManual      = "manual"       // Entered by hand: a correction or an upload's form
+)
+
+// LowConfidence is the confidence below which reports flag a field for review
+const LowConfidence = 0.6
+
+// FieldSource records how a metadata field's value was found and how far it can be trusted
+type FieldSource struct {
+	Method     string  `json:"method"`              // One of the Method constants
+	Extractor  string  `json:"extractor,omitempty"` // What applied it, e.g. "listing", "first-page" or a discovery source's name
+	Confidence float64 `json:"confidence"`          // From 0 for a guess to 1 for certain
+}
+
+// Returns the metadata with every non-empty field attributed to extractor's method at confidence, keeping sources
+// already set
+func (m DocumentMetadata) attributed(method, extractor string, confidence float64) DocumentMetadata {
+	sources := maps.Clone(m.Sources)
+	for field, value := range map[string]string{"product": m.Product, "sku": m.SKU, "language": m.Language, "revision": m.Revision} {
+		if _, known := sources[field]; value != "" && !known {
+			if sources == nil {
+				sources = make(map[string]FieldSource)
+			}
+			sources[field] = FieldSource{Method: method, Extractor: extractor, Confidence: confidence}
+		}
+	}
+	m.Sources = sources
+	return m
+}
+
+// Reports whether a newly extracted value should replace the one found by known: a value from the same extractor
+// supersedes itself, and otherwise only an at least as confident source wins the conflict
+func (source FieldSource) replaces(known FieldSource) bool {
+	return source.Method == known.Method && source.Extractor == known.Extractor || source.Confidence >= known.Confidence
+}
+
+// MetadataSource returns how a field's current value was found: a hand correction when one holds, otherwise what
+// extraction recorded; false when nothing is known, e.g. for entries catalogued before sources were recorded
+func (e *ManifestEntry) MetadataSource(field string) (FieldSource, bool) {
+	if _, corrected := e.Corrections[field]; corrected {
+		return FieldSource{Method: MethodManual, Extractor: "correction", Confidence: 1}, true
+	}
+	source, ok := e.Sources[field]
+	return source, ok
+}
+
+// LowConfidenceFields returns the set fields whose value was found with less than threshold confidence, sorted
+func (e *ManifestEntry) LowConfidenceFields(threshold float64) []string {
+	var fields []string
+	for _, field := range CorrectableFields {
+		if source, ok := e.MetadataSource(field); ok && *e.metadataField(field) != "" && source.Confidence < threshold {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// Records the source of a field's extracted value, replacing the map rather than editing it as working copies of
+// the entry may share it
+func (e *ManifestEntry) setSource(field string, source FieldSource) {
+	if known, ok := e.Sources[field]; ok && known == source {
+		return
+	}
+	e.Sources = maps.Clone(e.Sources)
+	if e.Sources == nil {
+		e.Sources = make(map[string]FieldSource)
+	}
+	e.Sources[field] = source
+}
+
+// Returns those of urls whose catalogued metadata has a field below threshold, in the order given
+func (m *Manifest) lowConfidence(urls []string, threshold float64) []string {
+	var flagged []string
+	for _, documentURL := range urls {
+		if entry, ok := m.Documents[documentURL]; ok && len(entry.LowConfidenceFields(threshold)) > 0 && !slices.Contains(flagged, documentURL) {
+			flagged = append(flagged, documentURL)
+		}
+	}
+	return flagged
+}
@@ -0,0 +1,173 @@
+package sdscraper
+
+import ( // Import required packages
+	"errors"   // For joining validation errors
+	"fmt"      // For error messages
+	"log/slog" // For structured logging
+	"maps"     // For copying corrections
+	"slices"   // For field names
+	"time"     // For correction times
+)
+
+// CorrectableFields are the metadata fields an operator may correct by hand
+var CorrectableFields = []string{"language", "product", "revision", "sku"}
+
+// MetadataCorrection is a hand correction of one metadata field; automatic extraction leaves the field alone until
+// the document's content changes, which lifts the correction
+type MetadataCorrection struct {
+	Value     string    `json:"value"`               // The corrected value, also set on the entry
+	Extracted string    `json:"extracted,omitempty"` // What extraction last found, restored when the correction is lifted
+	SHA256    string    `json:"sha256,omitempty"`    // Content the correction was made against
+	At        time.Time `json:"at"`
+	Note      string    `json:"note,omitempty"` // Why, e.g. "listing shows the old product name"
+}
+
+// MetadataPatch corrects fields of one document, the body item of PATCH /catalog and of "catalog correct" batches
+type MetadataPatch struct {
+	Filename string            `json:"filename" yaml:"filename" toml:"filename"`
+	Fields   map[string]string `json:"fields" yaml:"fields" toml:"fields"` // Field to corrected value; empty lifts the field's correction
+	Note     string            `json:"note,omitempty" yaml:"note" toml:"note"`
+}
+
+// Returns the entry's value of a correctable field, nil for other names
+func (e *ManifestEntry) metadataField(field string) *string {
+	switch field {
+	case "language":
+		return &e.Language
+	case "product":
+		return &e.Product
+	case "revision":
+		return &e.Revision
+	case "sku":
+		return &e.SKU
+	}
+	return nil
+}
+
+// MetadataField returns the value of one of the CorrectableFields, empty for other names
+func (e *ManifestEntry) MetadataField(field string) string {
+	if value := e.metadataField(field); value != nil {
+		return *value
+	}
+	return ""
+}
+
+// Correct sets a metadata field by hand, protecting it from extraction until the content changes; an empty value
+// lifts the field's correction instead, restoring what extraction found
+func (e *ManifestEntry) Correct(field, value, note string) error {
+	current := e.metadataField(field)
+	if current == nil {
+		return fmt.Errorf("%q cannot be corrected (want one of %v)", field, CorrectableFields)
+	}
+	if field == "revision" && value != "" {
+		if _, err := time.Parse("2006-01-02", normaliseDate(value)); err != nil {
+			return fmt.Errorf("revision %q is not a date such as 2024-05-31", value)
+		}
+		value = normaliseDate(value)
+	}
+	correction, corrected := e.Corrections[field]
+	if value == "" {
+		if corrected {
+			*current = correction.Extracted
+			e.Corrections = maps.Clone(e.Corrections) // Working copies of the entry may share the map
+			delete(e.Corrections, field)
+			if len(e.Corrections) == 0 {
+				e.Corrections = nil
+			}
+		}
+		return nil
+	}
+	extracted := *current
+	if corrected {
+		extracted = correction.Extracted // Not the earlier correction
+	}
+	e.Corrections = maps.Clone(e.Corrections)
+	if e.Corrections == nil {
+		e.Corrections = make(map[string]*MetadataCorrection)
+	}
+	e.Corrections[field] = &MetadataCorrection{Value: value, Extracted: extracted, SHA256: e.SHA256, At: time.Now().UTC(), Note: note}
+	*current = value
+	return nil
+}
+
+// Sets a field from extraction unless a more trustworthy source found the current value, or a correction still holds,
+// in which case only the correction learns the value
+func (e *ManifestEntry) extracted(field, value string, source FieldSource) {
+	if value == "" {
+		return
+	}
+	if known, ok := e.Sources[field]; ok && !source.replaces(known) {
+		return
+	}
+	e.setSource(field, source)
+	if correction, ok := e.Corrections[field]; ok && correction.SHA256 == e.SHA256 {
+		if correction.Extracted != value { // Copied, as working copies of the entry may share the correction
+			updated := *correction
+			updated.Extracted = value
+			e.Corrections = maps.Clone(e.Corrections)
+			e.Corrections[field] = &updated
+		}
+		return
+	}
+	*e.metadataField(field) = value
+}
+
+// Lifts the corrections made against other content than the entry's, once a new version has been stored; the map is
+// replaced rather than edited, as the entry may be a working copy sharing it with the catalogued one
+func (e *ManifestEntry) liftStaleCorrections() {
+	var kept map[string]*MetadataCorrection
+	for _, field := range sortedKeys(e.Corrections) {
+		correction := e.Corrections[field]
+		if correction.SHA256 == e.SHA256 {
+			if kept == nil {
+				kept = make(map[string]*MetadataCorrection)
+			}
+			kept[field] = correction
+			continue
+		}
+		*e.metadataField(field) = correction.Extracted
+		slog.Info("Lifted metadata correction; the content changed", "filename", e.Filename, "field", field, "corrected", correction.Value, "extracted", correction.Extracted)
+	}
+	e.Corrections = kept
+}
+
+// ApplyPatches validates every patch and, only if all are valid, applies them, recording each changed document in
+// the change log; it returns the changed entries
+func (m *Manifest) ApplyPatches(patches []MetadataPatch) ([]*ManifestEntry, error) {
+	var problems []error
+	entries := make([]*ManifestEntry, len(patches))
+	for i, patch := range patches {
+		_, entry, ok := m.FindByFilename(patch.Filename)
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Errorf("%s: not in the catalog", patch.Filename))
+			continue
+		case len(patch.Fields) == 0:
+			problems = append(problems, fmt.Errorf("%s: no fields to correct", patch.Filename))
+			continue
+		}
+		trial := *entry // Validated on a copy so nothing changes unless every patch is valid
+		trial.Corrections = nil
+		for _, field := range sortedKeys(patch.Fields) {
+			if err := trial.Correct(field, patch.Fields[field], patch.Note); err != nil {
+				problems = append(problems, fmt.Errorf("%s: %w", patch.Filename, err))
+			}
+		}
+		entries[i] = entry
+	}
+	if len(problems) > 0 {
+		return nil, errors.Join(problems...)
+	}
+	var changed []*ManifestEntry
+	for i, patch := range patches {
+		entry := entries[i]
+		for _, field := range sortedKeys(patch.Fields) {
+			entry.Correct(field, patch.Fields[field], patch.Note) // Validated above
+		}
+		m.RecordChange(ChangeUpdated, entry)
+		if !slices.Contains(changed, entry) {
+			changed = append(changed, entry)
+		}
+	}
+	return changed, nil
+}
@@ -0,0 +1,138 @@
+package sdscraper
+
+import ( // Import required packages
+	"cmp"           // For the heading fallback
+	"path/filepath" // For the file extension
+	"strings"       // For comparing extensions
+	"sync"          // For guarding the cache
+	"time"          // For the dates printed
+)
+
+// Layout of the cover page on US Letter, in points
+const (
+	coverMargin = 54.0
+	coverLabelX = coverMargin
+	coverValueX = 160.0
+)
+
+// Notice printed on every cover page
+const coverNotice = "UNCONTROLLED COPY WHEN PRINTED"
+
+// Budget of a server's covered documents kept in memory
+const coverCacheBytes = 64 << 20
+
+// Returns a one-page PDF stamping where and when the copy of entry came from; it only describes the stored file, so
+// the cover stays the same until the document itself is rewritten
+func coverPage(documentURL string, entry *ManifestEntry, template *StampTemplate) []byte {
+	title := cmp.Or(entry.Product, entry.Filename)
+	document := newPDF(letterWidth, letterHeight, title+" (cover page)", cmp.Or(entry.Language, "en"))
+	page := document.page()
+	top := letterHeight - coverMargin
+	if template != nil { // The facility heads its copies, above the document's own details
+		template.drawLogo(document, page, letterWidth-coverMargin, top)
+		page.mark(document.element(nil, "P"), func() {
+			page.text(coverMargin, top-14, 12, true, fitText(template.Facility, 12, true, letterWidth-2*coverMargin-130))
+			if template.Contact != "" {
+				page.text(coverMargin, top-30, 9, false, fitText(template.Contact, 9, false, letterWidth-2*coverMargin-130))
+			}
+		})
+		top -= 56
+	}
+	page.mark(document.element(nil, "H1"), func() {
+		page.text(coverMargin, top-18, 18, true, fitText(title, 18, true, letterWidth-2*coverMargin))
+	})
+	page.mark(document.element(nil, "P"), func() {
+		page.text(coverMargin, top-38, 10, false, "Cover page added by the archive; the document as retrieved follows on the next page.")
+	})
+
+	valueWidth := letterWidth - coverMargin - coverValueX
+	fields := []struct{ label, value string }{
+		{"Product", entry.Product},
+		{"Brand", entry.Brand},
+		{"SKU", entry.SKU},
+		{"Revision", entry.Revision},
+		{"File", entry.Filename},
+		{"Source", documentURL},
+		{"SHA-256", entry.SHA256},
+		{"Retrieved", coverDate(entry.DownloadedAt)},
+	}
+	table := document.element(nil, "Table")
+	y := top - 80
+	for _, field := range fields {
+		if field.value == "" {
+			continue
+		}
+		lines := wrapText(field.value, 10, valueWidth, 3) // URLs and hashes rarely fit one line
+		row := document.element(table, "TR")
+		page.mark(document.element(row, "TH"), func() { page.text(coverLabelX, y, 10, true, field.label) })
+		page.mark(document.element(row, "TD"), func() {
+			for i, line := range lines {
+				page.text(coverValueX, y-float64(i)*13, 10, false, line)
+			}
+		})
+		y -= float64(len(lines))*13 + 9
+	}
+
+	boxTop := y - 20
+	page.artifact(func() {
+		page.line(coverMargin, boxTop, letterWidth-coverMargin, boxTop, 2)
+		page.line(coverMargin, boxTop-78, letterWidth-coverMargin, boxTop-78, 2)
+	})
+	page.mark(document.element(nil, "P"), func() {
+		page.text((letterWidth-textWidth(coverNotice, 20, true))/2, boxTop-32, 20, true, coverNotice)
+		check := "Check the source for the current revision before relying on a printed copy."
+		page.text((letterWidth-textWidth(check, 10, false))/2, boxTop-56, 10, false, check)
+	})
+	return document.bytes()
+}
+
+// Formats a manifest time for the cover, empty for none
+func coverDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02 15:04 UTC")
+}
+
+// Reports whether a stored file gets a cover page: PDFs only, other documents being served as they are
+func coverable(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".pdf")
+}
+
+// Keeps a server's recently covered documents: merging numbers objects differently each time, and the range requests
+// PDF viewers make must get the bytes of the response they continue
+type coverCache struct {
+	mu      sync.Mutex
+	entries map[string][]byte // By the original's ETag and name
+	order   []string          // Keys, oldest first
+	size    int64             // Bytes held
+}
+
+// Returns the covered document for key, building and keeping it when it isn't held
+func (c *coverCache) get(key string, build func() ([]byte, error)) ([]byte, error) {
+	c.mu.Lock()
+	covered, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok {
+		return covered, nil
+	}
+	covered, err := build() // Outside the lock; merging takes a while
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if held, ok := c.entries[key]; ok { // Built concurrently; serve what others got
+		return held, nil
+	}
+	if c.entries == nil {
+		c.entries = make(map[string][]byte)
+	}
+	c.entries[key], c.order, c.size = covered, append(c.order, key), c.size+int64(len(covered))
+	for c.size > coverCacheBytes && len(c.order) > 1 {
+		c.size -= int64(len(c.entries[c.order[0]]))
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+	return covered, nil
+}
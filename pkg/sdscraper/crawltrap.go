@@ -0,0 +1,166 @@
+package sdscraper
+
+import ( // Import required packages
+	"crypto/sha256" // For page fingerprints
+	"log/slog"      // For structured logging
+	"net/url"       // For normalising links
+	"path"          // For cleaning URL paths
+	"regexp"        // For digit runs and whitespace
+	"strings"       // For path segments
+)
+
+// DefaultCrawlPatternPages bounds the pages one URL pattern yields when DeepCrawl.MaxPerPattern is zero
+const DefaultCrawlPatternPages = 20
+
+const (
+	maxCrawlURLLength   = 1024 // Longer links are almost always generated, e.g. ever-growing filter strings
+	maxCrawlSegments    = 12   // Deeper paths rarely hold product pages
+	maxSegmentRepeats   = 2    // A segment appearing more often means relative links looping, e.g. /a/b/a/b/a/
+	crawlTrapLogExample = 3    // Skipped URLs kept per reason for the summary
+)
+
+// Query parameters that only track visitors or sessions, so they never make a page different
+var trackingParameters = map[string]bool{
+	"fbclid": true, "gclid": true, "msclkid": true, "sessionid": true, "sid": true, "jsessionid": true, "phpsessid": true,
+}
+
+var (
+	digitRun   = regexp.MustCompile(`[0-9]+`)                     // Dates, page numbers and IDs in URL patterns
+	whitespace = regexp.MustCompile(`\s+`)                        // Collapsed before fingerprinting pages
+	pathParams = regexp.MustCompile(`;[^/]*`)                     // ;jsessionid=... style session IDs in paths
+	scriptTags = regexp.MustCompile(`(?is)<script\b.*?</script>`) // Nonces and timestamps differ between otherwise equal pages
+)
+
+// Keeps a deep crawl bounded: visits each normalised URL once, refuses links that look generated, such as calendar
+// pages or looping relative links, and skips pages whose content was already seen under another URL
+type crawlGuard struct {
+	maxPerPattern int                 // Pages allowed per URL pattern
+	visited       map[string]bool     // Normalised URLs already queued
+	patterns      map[string]int      // Pages queued per URL pattern
+	contents      map[[32]byte]string // Page fingerprints to the first URL that had them
+	skipped       map[string][]string // Example URLs skipped per reason, for the summary
+	counts        map[string]int      // URLs skipped per reason
+}
+
+// Returns a guard for one locale's deep crawl
+func (d *DeepCrawl) guard() *crawlGuard {
+	maxPerPattern := d.MaxPerPattern
+	if maxPerPattern <= 0 {
+		maxPerPattern = DefaultCrawlPatternPages
+	}
+	return &crawlGuard{
+		maxPerPattern: maxPerPattern,
+		visited:       make(map[string]bool),
+		patterns:      make(map[string]int),
+		contents:      make(map[[32]byte]string),
+		skipped:       make(map[string][]string),
+		counts:        make(map[string]int),
+	}
+}
+
+// Marks a URL as visited without any trap checks, e.g. the listings themselves
+func (g *crawlGuard) mark(link string) {
+	g.visited[normalizeCrawlURL(link)] = true
+}
+
+// Reports whether link should be queued, recording it as visited when it is
+func (g *crawlGuard) admit(link string) bool {
+	normalized := normalizeCrawlURL(link)
+	if g.visited[normalized] {
+		return false // A cycle back to a known page, perhaps spelled differently
+	}
+	g.visited[normalized] = true
+	parsed, err := url.Parse(normalized)
+	if err != nil {
+		return false
+	}
+	segments := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	switch {
+	case len(normalized) > maxCrawlURLLength:
+		return g.skip("too long", link)
+	case len(segments) > maxCrawlSegments:
+		return g.skip("too deep", link)
+	case repeatsSegment(segments):
+		return g.skip("repeated path segments", link)
+	}
+	pattern := digitRun.ReplaceAllString(parsed.Path, "0") + "?" + queryPattern(parsed.Query())
+	if g.patterns[pattern] >= g.maxPerPattern { // E.g. /events?month=2024-05, then every other month
+		return g.skip("parameter space", link)
+	}
+	g.patterns[pattern]++
+	return true
+}
+
+// Reports whether a rendered page repeats one seen under another URL; its links are then not followed again
+func (g *crawlGuard) duplicate(content, pageURL string) bool {
+	fingerprint := sha256.Sum256([]byte(whitespace.ReplaceAllString(scriptTags.ReplaceAllString(content, ""), " ")))
+	if first, ok := g.contents[fingerprint]; ok {
+		g.skip("duplicate content", pageURL)
+		slog.Debug("Deep crawl page repeats another", "url", pageURL, "same_as", first)
+		return true
+	}
+	g.contents[fingerprint] = pageURL
+	return false
+}
+
+// Counts a skipped link, always refusing it
+func (g *crawlGuard) skip(reason, link string) bool {
+	g.counts[reason]++
+	if len(g.skipped[reason]) < crawlTrapLogExample {
+		g.skipped[reason] = append(g.skipped[reason], link)
+	}
+	return false
+}
+
+// Logs what the guard kept the crawl away from
+func (g *crawlGuard) report(locale string) {
+	for _, reason := range sortedKeys(g.counts) {
+		slog.Info("Deep crawl skipped likely crawl traps", "locale", locale, "reason", reason, "pages", g.counts[reason], "examples", g.skipped[reason])
+	}
+}
+
+// Returns the form two links to the same page share: no fragment, session IDs or tracking parameters, a lowercase
+// host without default port, a clean path and sorted query parameters
+func normalizeCrawlURL(link string) string {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	parsed.Fragment, parsed.RawFragment = "", ""
+	parsed.Host = strings.ToLower(parsed.Host)
+	if port := parsed.Port(); (parsed.Scheme == "http" && port == "80") || (parsed.Scheme == "https" && port == "443") {
+		parsed.Host = parsed.Hostname()
+	}
+	cleaned := path.Clean("/" + pathParams.ReplaceAllString(parsed.Path, ""))
+	parsed.Path, parsed.RawPath = cleaned, ""
+	query := parsed.Query()
+	for name := range query {
+		if lower := strings.ToLower(name); trackingParameters[lower] || strings.HasPrefix(lower, "utm_") {
+			query.Del(name)
+		}
+	}
+	parsed.RawQuery = query.Encode() // Sorted by key
+	return parsed.String()
+}
+
+// Returns the query's parameter names with digit runs in their values replaced, sorted
+func queryPattern(query url.Values) string {
+	pattern := make(url.Values, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			pattern.Add(name, digitRun.ReplaceAllString(value, "0"))
+		}
+	}
+	return pattern.Encode()
+}
+
+// Reports whether any path segment occurs more than maxSegmentRepeats times
+func repeatsSegment(segments []string) bool {
+	counts := make(map[string]int, len(segments))
+	for _, segment := range segments {
+		if counts[segment]++; segment != "" && counts[segment] > maxSegmentRepeats {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,179 @@
+package sdscraper
+
+import ( // Import required packages
+	"context"  // For cancellation
+	"log/slog" // For structured logging
+	"net/url"  // For host comparison
+	"regexp"   // For allowlist patterns
+	"strings"  // For extension checks
+)
+
+// DefaultDeepCrawlPages bounds the pages one locale's deep crawl visits when DeepCrawl.MaxPages is zero
+const DefaultDeepCrawlPages = 200
+
+var anyLink = regexp.MustCompile(``) // Matches every URL; the allowlist filters page links afterwards
+
+// DeepCrawl follows links from the listing pages to pages on the same host, such as product detail pages,
+// and extracts the document links found there as well
+type DeepCrawl struct {
+	Depth    int              // Link hops followed from a listing page, zero to stay on the listings
+	Allow    []*regexp.Regexp // Page URLs worth visiting; none for every same-host page
+	MaxPages int              // Pages visited per locale besides the listings, zero for DefaultDeepCrawlPages
+
+	MaxPerPattern int // Pages visited per URL pattern (digits masked), zero for DefaultCrawlPatternPages; bounds calendars and pagers
+
+	Scope    []string         // Path prefixes the crawl stays within, e.g. /en/products/; none for the whole host
+	Hosts    []string         // Hosts besides the page's own the crawl may enter; "*.example.com" admits every subdomain
+	Deny     []*regexp.Regexp // Page URLs never visited, even when Allow matches
+	Nofollow bool             // Skip rel="nofollow" links, and every link of pages whose robots meta tag says nofollow
+}
+
+var (
+	anchorTag      = regexp.MustCompile(`(?is)<a\b[^>]*>`)                                     // Opening anchor tags
+	nofollowRel    = regexp.MustCompile(`(?i)\brel\s*=\s*["']?[^"'>]*\bnofollow\b`)            // rel="nofollow" and rel="noopener nofollow"
+	anchorHref     = regexp.MustCompile(`(?i)\bhref\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)  // The anchor's target
+	metaTag        = regexp.MustCompile(`(?is)<meta\b[^>]*>`)                                  // Meta tags
+	robotsMetaName = regexp.MustCompile(`(?i)\bname\s*=\s*["']?robots\b`)                      // <meta name="robots">
+	nofollowMeta   = regexp.MustCompile(`(?i)\bcontent\s*=\s*["'][^"']*\b(?:nofollow|none)\b`) // content="noindex, nofollow"
+)
+
+// A page queued for extraction, with the hops it took from a listing page
+type crawlPage struct {
+	url   string
+	depth int
+}
+
+// Returns the links in a page's content that a deep crawl should visit next: same-host HTML pages matching Allow
+func (d *DeepCrawl) pageLinks(content, pageURL string) []string {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+	var nofollow map[string]bool
+	if d.Nofollow {
+		if pageNofollow(content) {
+			slog.Debug("Deep crawl follows no links of a nofollow page", "url", pageURL)
+			return nil
+		}
+		nofollow = nofollowLinks(content, base)
+	}
+	var pages []string
+	for _, link := range ExtractLinks(content, pageURL, anyLink) {
+		parsed, err := url.Parse(link)
+		if err != nil || !d.inBounds(parsed, base) || !crawlablePath(parsed.Path) {
+			continue // Other sites and non-HTML resources are out of scope
+		}
+		if d.allows(link) && !nofollow[link] {
+			pages = append(pages, link)
+		}
+	}
+	return pages
+}
+
+// Reports whether a link stays within the crawl's domain boundary and path scope
+func (d *DeepCrawl) inBounds(link, page *url.URL) bool {
+	host := strings.ToLower(link.Hostname())
+	sameHost := strings.EqualFold(link.Host, page.Host)
+	for _, allowed := range d.Hosts {
+		allowed = strings.ToLower(allowed)
+		if wildcard, ok := strings.CutPrefix(allowed, "*."); ok && (host == wildcard || strings.HasSuffix(host, "."+wildcard)) || host == allowed {
+			sameHost = true
+		}
+	}
+	if !sameHost {
+		return false
+	}
+	if len(d.Scope) == 0 {
+		return true
+	}
+	for _, prefix := range d.Scope {
+		if strings.HasPrefix(link.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether a page's robots meta tag asks crawlers not to follow its links
+func pageNofollow(content string) bool {
+	for _, tag := range metaTag.FindAllString(content, -1) {
+		if robotsMetaName.MatchString(tag) && nofollowMeta.MatchString(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the resolved targets of a page's rel="nofollow" anchors
+func nofollowLinks(content string, base *url.URL) map[string]bool {
+	links := make(map[string]bool)
+	for _, tag := range anchorTag.FindAllString(content, -1) {
+		if !nofollowRel.MatchString(tag) {
+			continue
+		}
+		if match := anchorHref.FindStringSubmatch(tag); match != nil {
+			if link, ok := resolveLink(base, match[1]+match[2]+match[3], anyLink); ok {
+				links[link] = true
+			}
+		}
+	}
+	return links
+}
+
+// Appends the page links of page's content the guard admits to queue, one hop deeper than page
+func (s *Scraper) enqueueLinks(queue []crawlPage, guard *crawlGuard, content string, page crawlPage) []crawlPage {
+	for _, link := range s.DeepCrawl.pageLinks(content, page.url) {
+		if guard.admit(link) {
+			queue = append(queue, crawlPage{url: link, depth: page.depth + 1})
+		}
+	}
+	return queue
+}
+
+// Reports whether link matches the allowlist, which is open when empty, and none of the deny patterns
+func (d *DeepCrawl) allows(link string) bool {
+	for _, pattern := range d.Deny {
+		if pattern.MatchString(link) {
+			return false
+		}
+	}
+	if len(d.Allow) == 0 {
+		return true
+	}
+	for _, pattern := range d.Allow {
+		if pattern.MatchString(link) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether a path looks like a page rather than a document, script, stylesheet or image
+func crawlablePath(path string) bool {
+	switch strings.ToLower(getFileExtension(path)) {
+	case "", ".html", ".htm", ".php", ".asp", ".aspx", ".jsp":
+		return true
+	}
+	return false
+}
+
+// Renders a page found by the deep crawl, honouring robots.txt when the download client does; failures only skip the page
+func (s *Scraper) renderCrawled(ctx context.Context, pageURL string) (string, bool) {
+	if polite, ok := s.Downloader.client().Transport.(*PoliteTransport); ok && polite.Robots != nil { // Chrome bypasses the client
+		target, err := url.Parse(pageURL)
+		if err != nil {
+			return "", false
+		}
+		allowed, err := polite.Robots.Allowed(ctx, target, polite.base(), polite.Limiter)
+		if err != nil || !allowed {
+			slog.Debug("Deep crawl skipped a page robots.txt disallows", "url", pageURL)
+			return "", false
+		}
+	}
+	content, err := s.Renderer.Render(ctx, pageURL)
+	if err != nil {
+		slog.Warn("Deep crawl could not render a page", "url", pageURL, "err", err)
+		return "", false
+	}
+	return content, true
+}
@@ -0,0 +1,73 @@
+package sdscraper
+
+import ( // Import required packages
+	"context"       // For stopping between files
+	"fmt"           // For the throughput
+	"log/slog"      // For progress
+	"maps"          // For copying digests
+	"path/filepath" // For OS-independent path operations
+	"time"          // For progress intervals
+)
+
+// DigestResult counts what AddDigests recorded
+type DigestResult struct {
+	Added    int       `json:"added"`    // Entries that gained digests
+	Current  int       `json:"current"`  // Entries that had them all already
+	Problems []Problem `json:"problems"` // Files not digested, e.g. because their SHA-256 no longer matches
+}
+
+// AddDigests records the digests of hashers that stored documents lack by hashing the files already on disk, so a new
+// algorithm is adopted without downloading anything again. Each file must still match its recorded SHA-256, so new
+// digests are only taken of verified content; aliases get their original's digests
+func AddDigests(ctx context.Context, m *Manifest, outputDir string, hashers []Hasher, options HashOptions) (*DigestResult, error) {
+	result := &DigestResult{}
+	stats := &hashStats{}
+	lastLog := time.Now()
+	for _, documentURL := range sortedKeys(m.Documents) {
+		entry := m.Documents[documentURL]
+		if entry.Filename == "" || entry.DownloadedAt.IsZero() || entry.AliasOf != "" || entry.SHA256 == "" {
+			continue // Never stored, or digested with its original below
+		}
+		var missing []Hasher
+		for _, hasher := range hashers {
+			if _, ok := entry.Digests[hasher.Name()]; !ok && hasher.Name() != defaultHasher.Name() {
+				missing = append(missing, hasher)
+			}
+		}
+		if len(missing) == 0 {
+			result.Current++
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		sum, digests, err := options.hashFile(filepath.Join(outputDir, entry.Filename), stats, missing...)
+		switch {
+		case err != nil:
+			result.Problems = append(result.Problems, Problem{Kind: ProblemMissingFile, URL: documentURL, Filename: entry.Filename,
+				Detail: err.Error(), Fix: "run fsck"})
+			continue
+		case sum != entry.SHA256:
+			result.Problems = append(result.Problems, Problem{Kind: ProblemHashMismatch, URL: documentURL, Filename: entry.Filename,
+				Detail: "sha256 " + sum + ", recorded " + entry.SHA256, Fix: "run fsck -hashes and repair the file first"})
+			continue
+		}
+		if entry.Digests == nil {
+			entry.Digests = make(map[string]string, len(digests))
+		}
+		maps.Copy(entry.Digests, digests)
+		result.Added++
+		if time.Since(lastLog) >= progressLogEvery {
+			lastLog = time.Now()
+			slog.Info("Adding digests", "added", result.Added, "current", result.Current, "bytes", stats.bytes.Load())
+		}
+	}
+	for _, entry := range m.Documents { // Aliases hold the same bytes as their original
+		if original, ok := m.Documents[entry.AliasOf]; ok && entry.AliasOf != "" && original.SHA256 == entry.SHA256 && len(original.Digests) > 0 {
+			entry.Digests = maps.Clone(original.Digests)
+		}
+	}
+	slog.Info("Added digests", "added", result.Added, "current", result.Current, "files", stats.files.Load(),
+		"bytes", stats.bytes.Load(), "mib_per_second", fmt.Sprintf("%.1f", stats.throughput()))
+	return result, nil
+}
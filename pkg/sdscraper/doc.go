@@ -0,0 +1,7 @@
+// Package sdscraper discovers Safety Data Sheet PDFs on a listing page, mirrors
+// them into a local directory, and can serve that mirror over HTTP.
+//
+// A Scraper ties the pieces together: a Renderer produces the listing page
+// HTML, links are extracted from it, and a Downloader fetches each document,
+// recording validators in a Manifest so later runs only transfer changes.
+package sdscraper
@@ -0,0 +1,217 @@
+package sdscraper
+
+import ( // Import required packages
+	"bytes"    // For signature checks
+	"context"  // For probing links
+	"fmt"      // For error messages
+	"log/slog" // For probe failures
+	"mime"     // For Content-Type and Content-Disposition parsing
+	"net/http" // For response headers
+	"net/url"  // For URL paths
+	"path"     // For URL extensions
+	"regexp"   // For link patterns
+	"slices"   // For extension lookups
+	"sort"     // For listing known types
+	"strings"  // For name manipulation
+)
+
+// DocumentType is a kind of document the scraper may mirror besides PDFs
+type DocumentType struct {
+	Name         string   // Short name used by -types and as the subfolder, e.g. "docx"
+	Extensions   []string // File extensions, preferred first, e.g. ".docx"
+	ContentTypes []string // MIME types servers label the documents with
+	signature    [][]byte // Possible leading bytes, none to accept any content
+}
+
+// Formats the scraper knows how to recognise
+var documentTypes = map[string]DocumentType{
+	"pdf": {Name: "pdf", Extensions: []string{".pdf"}, ContentTypes: []string{"application/pdf", "application/x-pdf"}},
+	"docx": {Name: "docx", Extensions: []string{".docx"},
+		ContentTypes: []string{"application/vnd.openxmlformats-officedocument.wordprocessingml.document"}, signature: [][]byte{zipSignature}},
+	"xlsx": {Name: "xlsx", Extensions: []string{".xlsx"},
+		ContentTypes: []string{"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"}, signature: [][]byte{zipSignature}},
+	"doc": {Name: "doc", Extensions: []string{".doc"}, ContentTypes: []string{"application/msword"}, signature: [][]byte{oleSignature}},
+	"zip": {Name: "zip", Extensions: []string{".zip"},
+		ContentTypes: []string{"application/zip", "application/x-zip-compressed"}, signature: [][]byte{zipSignature, []byte("PK\x05\x06")}},
+}
+
+var (
+	zipSignature = []byte("PK\x03\x04")                                   // Local file header opening ZIP, DOCX and XLSX files
+	oleSignature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1} // Compound file header of legacy Office files
+)
+
+// ParseDocumentTypes looks up the named document types, e.g. from -types pdf,docx,zip
+func ParseDocumentTypes(names []string) ([]DocumentType, error) {
+	var types []DocumentType
+	for _, name := range names {
+		docType, ok := documentTypes[strings.ToLower(strings.TrimPrefix(name, "."))]
+		if !ok {
+			known := make([]string, 0, len(documentTypes))
+			for name := range documentTypes {
+				known = append(known, name)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("unknown document type %q (known: %s)", name, strings.Join(known, ", "))
+		}
+		types = append(types, docType)
+	}
+	return types, nil
+}
+
+// DocumentLinkPattern matches links whose path ends in one of the types' extensions
+func DocumentLinkPattern(types []DocumentType) *regexp.Regexp {
+	var extensions []string
+	for _, docType := range types {
+		for _, extension := range docType.Extensions {
+			extensions = append(extensions, regexp.QuoteMeta(strings.TrimPrefix(extension, ".")))
+		}
+	}
+	return regexp.MustCompile(`(?i)\.(?:` + strings.Join(extensions, "|") + `)(?:[?#]|$)`)
+}
+
+// Matches links whose last path segment has no extension, e.g. "/download?id=7", which only the response can classify
+var extensionlessLinkPattern = regexp.MustCompile(`^https?://[^/?#]+(?:/[^/?#]*)*/[^/.?#]+(?:[?#].*)?$`)
+
+// Returns the Content-Type a stored document is served with: its recorded type's, else the one its name's extension
+// suggests, else generic binary
+func contentTypeFor(typeName, name string) string {
+	if docType, ok := documentTypes[typeName]; ok {
+		return docType.ContentTypes[0]
+	}
+	extension := strings.ToLower(path.Ext(name))
+	for _, docType := range documentTypes {
+		if slices.Contains(docType.Extensions, extension) {
+			return docType.ContentTypes[0]
+		}
+	}
+	if contentType := mime.TypeByExtension(extension); contentType != "" {
+		return contentType // E.g. the manifest's JSON
+	}
+	return "application/octet-stream"
+}
+
+// Asks the server what an extensionless link serves, reporting whether its Content-Disposition or Content-Type names
+// one of the configured types; only the headers are read
+func (d *Downloader) probe(ctx context.Context, rawURL string) bool {
+	for _, method := range []string{http.MethodHead, http.MethodGet} { // GET for servers that refuse HEAD
+		request, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+		if err != nil {
+			return false
+		}
+		resp, err := d.client().Do(request)
+		if err != nil {
+			slog.Debug("Probing an extensionless link failed", "url", rawURL, "err", err)
+			return false
+		}
+		resp.Body.Close() // Only the headers matter, never the document itself
+		if resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented {
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			return false
+		}
+		if _, _, err := d.classify(resp.Header, rawURL); err != nil {
+			return false // A page, not a document
+		}
+		return true
+	}
+	return false
+}
+
+// Returns the configured types, PDF alone unless Types is set
+func (d *Downloader) types() []DocumentType {
+	if len(d.Types) == 0 {
+		return []DocumentType{documentTypes["pdf"]}
+	}
+	return d.Types
+}
+
+// Works out which configured type a response carries, from the Content-Disposition file name, the Content-Type
+// or, for generic binary labels, the URL, returning it with the extension the stored file should have
+func (d *Downloader) classify(header http.Header, rawURL string) (DocumentType, string, error) {
+	types := d.types()
+	if name := dispositionFilename(header); name != "" {
+		if docType, extension, ok := typeByExtension(types, path.Ext(name)); ok {
+			return docType, extension, nil
+		}
+	}
+	contentType := header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for _, docType := range types {
+		if slices.Contains(docType.ContentTypes, mediaType) {
+			return docType, docType.Extensions[0], nil
+		}
+	}
+	if mediaType == "application/octet-stream" || mediaType == "binary/octet-stream" { // Servers that do not know better
+		if docType, extension, ok := typeByExtension(types, urlExtension(rawURL)); ok {
+			return docType, extension, nil
+		}
+	}
+	if len(d.Types) == 0 {
+		return DocumentType{}, "", fmt.Errorf("invalid content type %q (expected application/pdf)", contentType)
+	}
+	return DocumentType{}, "", fmt.Errorf("invalid content type %q (expected one of the -types documents)", contentType)
+}
+
+// Returns the file name a Content-Disposition header suggests without any directory part, empty if there is none
+func dispositionFilename(header http.Header) string {
+	_, params, err := mime.ParseMediaType(header.Get("Content-Disposition")) // Decodes filename* too
+	if err != nil {
+		return ""
+	}
+	name := path.Base(strings.ReplaceAll(params["filename"], `\`, "/")) // Never a path of the server's choosing
+	if name == "." || name == "/" || name == ".." {
+		return ""
+	}
+	return name
+}
+
+// Returns the type among types that uses extension
+func typeByExtension(types []DocumentType, extension string) (DocumentType, string, bool) {
+	extension = strings.ToLower(extension)
+	for _, docType := range types {
+		if slices.Contains(docType.Extensions, extension) {
+			return docType, extension, true
+		}
+	}
+	return DocumentType{}, "", false
+}
+
+// Returns the extension of a URL's path, empty for extensionless links
+func urlExtension(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return path.Ext(parsed.Path)
+}
+
+// Returns where a document of docType should be stored: in the type's subfolder with the given extension when
+// Types is set, at the top of the output directory otherwise
+func (d *Downloader) typedFilename(filename string, docType DocumentType, extension string) string {
+	if len(d.Types) == 0 {
+		return filename // PDFs only, laid out as before types existed
+	}
+	name := strings.TrimSuffix(path.Base(filename), path.Ext(filename))
+	if _, _, ok := typeByExtension(d.types(), path.Ext(name)); ok { // E.g. "sheet.docx.pdf" from URLToFilename
+		name = strings.TrimSuffix(name, path.Ext(name))
+	}
+	return docType.Name + "/" + name + extension
+}
+
+// Checks that a document of size bytes is what docType says it is, validating PDFs in full and other formats by their signature
+func (d *Downloader) validate(content documentReader, size int64, docType DocumentType) error {
+	if docType.Name == "pdf" {
+		return validatePDFReader(content, size, d.StructuralCheck)
+	}
+	if len(docType.signature) == 0 {
+		return nil
+	}
+	for _, signature := range docType.signature {
+		head := make([]byte, len(signature))
+		if n, _ := content.ReadAt(head, 0); bytes.Equal(head[:n], signature) {
+			return nil
+		}
+	}
+	return fmt.Errorf("not a valid %s document: unexpected leading bytes", docType.Name)
+}
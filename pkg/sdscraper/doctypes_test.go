@@ -0,0 +1,61 @@
+package sdscraper
+
+import ( // Import required packages
+	"context"           // For probing
+	"net/http"          // For the fake server's handlers
+	"net/http/httptest" // For the fake server
+	"slices"            // For comparing link lists
+	"testing"           // For the test harness
+)
+
+func TestContentTypeFor(t *testing.T) {
+	for _, test := range []struct{ typeName, name, want string }{
+		{"", "sheet.pdf", "application/pdf"},
+		{"docx", "docx/sheet.docx", "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+		{"zip", "bundle", "application/zip"}, // The recorded type beats the name
+		{"", "manifest.json", "application/json"},
+		{"", "download_id=7", "application/octet-stream"},
+	} {
+		if got := contentTypeFor(test.typeName, test.name); got != test.want {
+			t.Errorf("contentTypeFor(%q, %q) = %q, want %q", test.typeName, test.name, got, test.want)
+		}
+	}
+}
+
+func TestExtensionlessLinks(t *testing.T) {
+	content := `<a href="/download?id=7">Sheet</a> <a href="/en/about-us">About</a> <a href="/docs/gel.pdf">Gel</a> <a href="/">Home</a>`
+	want := []string{"https://www.gojo.com/download?id=7", "https://www.gojo.com/en/about-us"}
+	if got := ExtractLinks(content, "https://www.gojo.com/en/SDS", extensionlessLinkPattern); !slices.Equal(got, want) {
+		t.Errorf("extensionless links = %q, want %q", got, want)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/download":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Header().Set("Content-Disposition", `attachment; filename="Sheet.docx"`)
+		case "/sheet":
+			w.Header().Set("Content-Type", "application/pdf")
+		case "/no-head":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.Header().Set("Content-Type", "application/zip")
+		case "/binary":
+			w.Header().Set("Content-Type", "application/octet-stream") // Nothing says what it is
+		default:
+			w.Header().Set("Content-Type", "text/html")
+		}
+	}))
+	defer server.Close()
+	types, _ := ParseDocumentTypes([]string{"pdf", "docx", "zip"})
+	d := &Downloader{Types: types}
+	for path, want := range map[string]bool{"/download?id=7": true, "/sheet": true, "/no-head": true, "/binary": false, "/about": false} {
+		if got := d.probe(context.Background(), server.URL+path); got != want {
+			t.Errorf("probe(%s) = %t, want %t", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,391 @@
+package sdscraper
+
+import ( // Import required packages
+	"cmp"           // For defaulting file names
+	"context"       // For request cancellation
+	"errors"        // For matching robots refusals
+	"fmt"           // For error messages
+	"io"            // For input/output utilities
+	"log/slog"      // For structured logging
+	"net/http"      // For HTTP client
+	"path"          // For file names inside the storage
+	"path/filepath" // For OS-independent path operations
+	"strings"       // For file names
+	"sync/atomic"   // For the run's byte count
+	"time"          // For timestamps
+)
+
+// Downloader fetches documents into a Storage, using manifest validators to skip unchanged files
+type Downloader struct {
+	Client          *http.Client        // HTTP client used for downloads, nil for a 30 second default
+	OutputDir       string              // Directory the documents are written to, unless Storage is set
+	Storage         Storage             // Where documents are kept, nil for LocalStorage in OutputDir
+	RejectDir       string              // Directory invalid downloads are quarantined in, empty to discard them
+	StructuralCheck bool                // Parse every PDF with pdfcpu in addition to the header/trailer checks
+	Rules           *RuleSet            // Tags stored documents and picks folders for new ones, nil for no rules
+	Trash           *Trash              // Receives deleted and replaced local documents, nil to discard them
+	Types           []DocumentType      // Formats to accept, each stored in its own subfolder; nil for PDFs in OutputDir itself
+	Scheduler       *Scheduler          // Download slots shared with other callers, nil for no limit
+	Metrics         *Metrics            // Counts downloads for a metrics endpoint, nil to skip
+	MaxFileSize     int64               // Largest document accepted in bytes, zero for DefaultMaxFileSize, negative for no limit
+	MaxRunBytes     int64               // Bytes one run may transfer before remaining downloads fail, zero for no quota
+	SpoolDir        string              // Where bodies wait while they are validated and hashed, empty for os.TempDir()
+	Extractors      []MetadataExtractor // Read metadata out of new content, tried in order per field; nil for the listings' alone
+	Bandwidth       *BandwidthSchedule  // Transfer rate and concurrency by time of day, nil for no limit
+	Digests         []Hasher            // Digests recorded for every document besides SHA-256, e.g. SHA-512 for compliance
+
+	runBytes atomic.Int64 // Transferred since the run started, counted against MaxRunBytes
+}
+
+// StatusError is a download the server answered with an unexpected status
+type StatusError struct {
+	StatusCode int    // E.g. 429
+	Status     string // E.g. "429 Too Many Requests"
+}
+
+// Error implements error
+func (e *StatusError) Error() string {
+	return "download failed: " + e.Status
+}
+
+// Outcome says what a successful Download did
+type Outcome int
+
+const (
+	OutcomeNotModified Outcome = iota // The local copy was already current
+	OutcomeDownloaded                 // A new copy was written to disk
+	OutcomeAliased                    // The content matched another document, so no second copy was written
+)
+
+// HashIndex finds already stored documents by content hash and hands out file names no other document uses
+type HashIndex interface {
+	LookupHash(sha256, excludeURL string) (*ManifestEntry, bool) // Another entry with this hash, if any
+	ClaimFilename(name, documentURL string) string               // Reserves name for documentURL, suffixed if it is taken
+}
+
+// Download fetches rawURL into the output directory, recording content identical to a known document as an alias
+func (d *Downloader) Download(ctx context.Context, rawURL string, entry *ManifestEntry, index HashIndex) (Outcome, error) {
+	started := time.Now()
+	body, outcome, err := d.fetch(ctx, rawURL, entry)
+	if err == nil && body != nil {
+		outcome, err = d.store(ctx, body, entry, index)
+	}
+	logDownload(rawURL, entry, started, 1, outcome, err) // A single attempt; runs retry through Scraper
+	d.Metrics.observeDownload(entry.Size, started, outcome, err)
+	return outcome, err
+}
+
+// A transferred document waiting to be validated, hashed and written
+type fetchedBody struct {
+	rawURL   string       // Source URL
+	filename string       // Target file inside the output directory
+	header   http.Header  // Response headers carrying the validators
+	docType  DocumentType // Format the response was recognised as
+	claim    bool         // The file name is new and must not clash with another document's
+	body     *spool       // The whole body, on disk
+}
+
+// Performs the network half of a download: one conditional GET whose body, if any, still needs storing
+func (d *Downloader) fetch(ctx context.Context, rawURL string, entry *ManifestEntry) (*fetchedBody, Outcome, error) {
+	if d.Scheduler != nil { // Share download slots with other callers, interactive ones first
+		if err := d.Scheduler.acquire(ctx); err != nil {
+			return nil, OutcomeNotModified, err
+		}
+		defer d.Scheduler.release()
+	}
+	if err := d.Bandwidth.acquire(ctx); err != nil { // Fewer downloads at once during business hours
+		return nil, OutcomeNotModified, err
+	}
+	defer d.Bandwidth.release(ctx)
+
+	filename := URLToFilename(rawURL) // Create safe file name
+	if entry.Filename != "" && entry.AliasOf == "" {
+		filename = entry.Filename // Keep the name it was given, e.g. from a filename template
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil) // Build GET request
+	if err != nil {
+		return nil, OutcomeNotModified, err
+	}
+
+	current := cmp.Or(entry.Filename, filename)                    // Aliases validate against the file they point at
+	if stored, err := d.storage().Stat(ctx, current); err == nil { // Ask the server whether our copy is still current
+		if entry.ETag != "" {
+			request.Header.Set("If-None-Match", entry.ETag) // Validate by ETag
+		}
+		if entry.LastModified != "" {
+			request.Header.Set("If-Modified-Since", entry.LastModified) // Validate by date
+		} else if entry.ETag == "" && !stored.ModTime.IsZero() {
+			request.Header.Set("If-Modified-Since", stored.ModTime.UTC().Format(http.TimeFormat)) // Fall back to file time
+		}
+	}
+	if err := d.admitSize(-1); err != nil { // Quota used up by earlier documents
+		return nil, OutcomeNotModified, err
+	}
+	partial := d.loadPartial(rawURL, filename) // An earlier transfer that broke off
+	if partial != nil {
+		partial.requestRange(request)
+	}
+
+	resp, err := d.client().Do(request) // Make GET request
+	if err != nil {
+		return nil, OutcomeNotModified, err
+	}
+	defer resp.Body.Close() // Ensure response body is closed
+
+	entry.CheckedAt = time.Now().UTC() // Record that the server was consulted
+
+	if resp.StatusCode == http.StatusNotModified { // Our copy is current
+		if partial != nil {
+			d.discardPartial(filename)
+		}
+		return nil, OutcomeNotModified, nil
+	}
+
+	total := resp.ContentLength // Expected size of the whole document, -1 if unknown
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && partial != nil:
+		start, rangeTotal, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil || start != partial.Received {
+			d.discardPartial(filename)
+			return nil, OutcomeNotModified, fmt.Errorf("server resumed at the wrong offset (%s); starting over next time", resp.Header.Get("Content-Range"))
+		}
+		total = rangeTotal
+	case resp.StatusCode == http.StatusOK: // Full body, because nothing was kept, ranges are unsupported or the document changed
+		if partial != nil {
+			d.discardPartial(filename)
+			partial = nil
+		}
+	default:
+		if partial != nil { // E.g. 416 when the kept prefix no longer fits the document
+			d.discardPartial(filename)
+		}
+		return nil, OutcomeNotModified, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	docType, extension, err := d.classify(resp.Header, rawURL) // Check Content-Type
+	if err != nil {
+		return nil, OutcomeNotModified, err
+	}
+
+	remaining := resp.ContentLength // Still to come in this response
+	if partial != nil && total >= 0 {
+		remaining = total - partial.Received
+	}
+	if err := d.admitSize(total); errors.Is(err, ErrTooLarge) { // Refused before a byte of the body is read
+		d.discardPartial(filename)
+		return nil, OutcomeNotModified, err
+	}
+	if err := d.admitSize(remaining); err != nil {
+		return nil, OutcomeNotModified, err // A kept prefix stays for a later run with quota left
+	}
+	received, err := d.newSpool() // Streamed to disk, never held in memory
+	if err != nil {
+		return nil, OutcomeNotModified, err
+	}
+	if partial != nil { // Kept prefix first
+		if err := d.spoolPartial(filename, received); err != nil {
+			received.discard()
+			d.discardPartial(filename)
+			return nil, OutcomeNotModified, fmt.Errorf("read kept partial download: %w", err)
+		}
+	}
+	var content io.Reader = quotaReader{reader: d.Bandwidth.reader(ctx, resp.Body), downloader: d}
+	if limit := d.fileLimit(); limit > 0 {
+		content = io.LimitReader(content, limit-received.size+1) // One byte past the limit tells an oversized body apart
+	}
+	err = received.copyFrom(content) // Read response body
+	if limit := d.fileLimit(); err == nil && limit > 0 && received.size > limit {
+		err = fmt.Errorf("%w: more than %d bytes", ErrTooLarge, limit)
+	}
+	if err != nil {
+		defer received.discard()
+		if errors.Is(err, ErrTooLarge) {
+			d.discardPartial(filename)
+			return nil, OutcomeNotModified, err
+		}
+		if acceptsRanges(resp) && d.keepPartial(rawURL, filename, resp.Header, received, total) {
+			return nil, OutcomeNotModified, fmt.Errorf("read PDF data (kept %d bytes to resume from): %w", received.size, err)
+		}
+		d.discardPartial(filename)
+		return nil, OutcomeNotModified, fmt.Errorf("read PDF data: %w", err)
+	}
+	if partial != nil {
+		d.discardPartial(filename) // The whole document is in the spool now
+	}
+	if total >= 0 && received.size != total {
+		received.discard()
+		return nil, OutcomeNotModified, fmt.Errorf("downloaded %d of %d bytes; not creating file", received.size, total)
+	}
+	if received.size == 0 {
+		received.discard()
+		return nil, OutcomeNotModified, fmt.Errorf("downloaded 0 bytes; not creating file")
+	}
+	if partial != nil {
+		slog.Info("Resumed download", "url", rawURL, "from", partial.Received, "bytes", received.size)
+	}
+	serverName := dispositionFilename(resp.Header)
+	if serverName != "" { // Record both names, whichever is used
+		entry.ServerFilename, entry.URLFilename = serverName, URLToFilename(rawURL)
+	}
+	unplaced := entry.DownloadedAt.IsZero() || entry.AliasOf != "" // Not stored yet, so the response may decide the name
+	if unplaced {
+		if serverName != "" && filename == URLToFilename(rawURL) { // The server's name beats the URL's, a template's beats both
+			filename = sanitizeFilename(strings.ReplaceAll(serverName, " ", "_"))
+		}
+		filename = d.typedFilename(filename, docType, extension)
+	}
+	return &fetchedBody{rawURL: rawURL, filename: filename, header: resp.Header, docType: docType, claim: unplaced, body: received}, OutcomeDownloaded, nil
+}
+
+// Performs the disk half of a download: validates, hashes and writes a fetched body or records it as an alias
+func (d *Downloader) store(ctx context.Context, body *fetchedBody, entry *ManifestEntry, index HashIndex) (Outcome, error) {
+	defer body.body.discard()                                                            // Stored or not, the spool file goes
+	if err := d.validate(body.body.reader(), body.body.size, body.docType); err != nil { // Content-Type alone can lie
+		d.quarantine(path.Base(body.filename), body.body.reader())
+		return OutcomeNotModified, err
+	}
+
+	if len(d.Extractors) > 0 { // Before the rules, which may match on the product
+		entry.applyMetadata(extractMetadata(ctx, d.Extractors, ExtractInput{URL: body.rawURL, Type: body.docType.Name, Content: body.body.reader(), Size: body.body.size}))
+	}
+	folder := d.applyRules(body, entry) // Before the alias check, so aliases are tagged too
+	written := body.body.size
+	sum := body.body.sum() // Identifies the content regardless of URL
+	if index != nil {
+		if original, ok := index.LookupHash(sum, body.rawURL); ok && d.stored(ctx, original.Filename) {
+			entry.AliasOf = original.URL // Same bytes under another URL
+			entry.Filename = original.Filename
+			entry.StoreVersion = original.StoreVersion
+			entry.Type = body.docType.Name
+			d.recordDownload(entry, body.header, written, sum, body.body.digests.extra())
+			return OutcomeAliased, nil
+		}
+	}
+
+	if folder != "" && body.claim { // Files already stored stay where they are
+		body.filename = folder + "/" + body.filename // Type subfolders nest inside it
+	}
+	if body.claim && index != nil { // Two documents may suggest the same name
+		body.filename = index.ClaimFilename(body.filename, body.rawURL)
+	}
+	version, err := putVersion(ctx, d.storage(), body.filename, body.body.reader()) // A crash never leaves a half-written PDF
+	if err != nil {
+		return OutcomeNotModified, fmt.Errorf("store %s: %w", body.docType.Name, err)
+	}
+	entry.StoreVersion = version // Names the revision in a versioned, possibly object-locked, bucket
+
+	entry.AliasOf = ""             // Content of its own, even if it used to be an alias
+	entry.Filename = body.filename // Remember where the file lives
+	entry.Type = body.docType.Name // And what it is
+	d.recordDownload(entry, body.header, written, sum, body.body.digests.extra())
+	return OutcomeDownloaded, nil
+}
+
+// Evaluates the rules on a validated document, finding its hazard codes first when a rule needs them, and returns
+// the folder they choose for it
+func (d *Downloader) applyRules(body *fetchedBody, entry *ManifestEntry) string {
+	if d.Rules.Len() == 0 {
+		return ""
+	}
+	entry.Type = body.docType.Name // Rules may look at it before the store records it
+	if d.Rules.needsHazards() && body.docType.Name == "pdf" {
+		codes, err := hazardCodes(body.body.reader(), body.body.size)
+		if err != nil {
+			slog.Warn("Finding hazard codes failed", "url", body.rawURL, "err", err) // Rules then see the codes found so far
+		}
+		entry.HazardCodes = codes
+	}
+	return d.Rules.ingest(entry)
+}
+
+// Returns the configured Storage, defaulting to the output directory
+func (d *Downloader) storage() Storage {
+	if d.Storage == nil {
+		return sealStorage(LocalStorage{Dir: d.OutputDir, Trash: d.Trash}, d.SpoolDir)
+	}
+	return sealStorage(d.Storage, d.SpoolDir)
+}
+
+// Reports whether a document is present in the storage
+func (d *Downloader) stored(ctx context.Context, filename string) bool {
+	_, err := d.storage().Stat(ctx, filename)
+	return err == nil
+}
+
+// Logs one structured event per finished download, attempt counting the transfers made, the first one included
+func logDownload(rawURL string, entry *ManifestEntry, started time.Time, attempt int, outcome Outcome, err error) {
+	event := slog.With( // Fields shared by every download event
+		"url", rawURL,
+		"filename", entry.Filename,
+		"bytes", entry.Size,
+		"duration", time.Since(started),
+		"attempt", attempt,
+	)
+	switch {
+	case errors.Is(err, ErrRobotsDisallowed): // The site asked not to be crawled there
+		event.Info("Skipped, disallowed by robots.txt")
+	case err != nil:
+		event.Error("Download failed", "err", err)
+	case outcome == OutcomeNotModified:
+		event.Info("Not modified, skipping")
+	case outcome == OutcomeAliased:
+		event.Info("Identical content, recorded alias", "alias_of", entry.AliasOf)
+	default:
+		event.Info("Downloaded")
+	}
+}
+
+// Writes data to path+".part" and renames it into place once complete
+func writePartThenRename(path string, data io.Reader) error {
+	partPath := path + ".part"
+	out, err := createFile(partPath) // Create (or replace) the temporary file
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, data); err != nil {
+		out.Close()
+		removeFile(partPath)
+		return err
+	}
+	if err := out.Close(); err != nil { // Flush before the rename makes it visible
+		removeFile(partPath)
+		return err
+	}
+	return renameFile(partPath, path)
+}
+
+// Stores validators, size and hash of a successful transfer
+func (d *Downloader) recordDownload(entry *ManifestEntry, header http.Header, written int64, sum string, digests map[string]string) {
+	entry.ETag = header.Get("ETag")                  // Store validators for the next run
+	entry.LastModified = header.Get("Last-Modified") // Both are optional
+	entry.Size = written                             // Record stored size
+	entry.SHA256 = sum                               // Record content hash
+	entry.Digests = digests                          // And any further digests asked for
+	entry.DownloadedAt = entry.CheckedAt             // Record write time
+	entry.liftStaleCorrections()                     // Hand corrections covered the old content only
+}
+
+// Keeps a rejected download for inspection instead of saving it as a good document
+func (d *Downloader) quarantine(filename string, data io.Reader) {
+	if d.RejectDir == "" {
+		return
+	}
+	if !directoryExists(d.RejectDir) {
+		createDirectory(d.RejectDir, 0o755)
+	}
+	rejectPath := filepath.Join(d.RejectDir, filename)
+	if err := writePartThenRename(rejectPath, data); err != nil {
+		slog.Error("Quarantining download failed", "filename", filename, "err", err)
+		return
+	}
+	slog.Warn("Quarantined invalid download", "filename", filename, "path", rejectPath)
+}
+
+// Returns the configured HTTP client or the default one
+func (d *Downloader) client() *http.Client {
+	if d.Client != nil {
+		return d.Client
+	}
+	return &http.Client{Timeout: 30 * time.Second} // Create HTTP client
+}
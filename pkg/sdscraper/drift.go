@@ -0,0 +1,192 @@
+package sdscraper
+
+import ( // Import required packages
+	"context"       // For cancelling downloads
+	"crypto/sha256" // For hashing the live content
+	"fmt"           // For problem details
+	"io"            // For streaming bodies into the hash
+	"math/rand/v2"  // For drawing the sample
+	"net/http"      // For the downloads
+	"path/filepath" // For OS-independent path operations
+	"sort"          // For a stable report order
+	"sync"          // For concurrent downloads
+)
+
+// DriftSummary quantifies how far the archive has drifted from the live site over the documents compared
+type DriftSummary struct {
+	Eligible    int     `json:"eligible"`     // Live, crawled documents that could be compared
+	Compared    int     `json:"compared"`     // Downloaded again and hashed
+	Matched     int     `json:"matched"`      // Source, manifest and local copy agree
+	Drifted     int     `json:"drifted"`      // Source serves other content than was archived
+	Corrupted   int     `json:"corrupted"`    // Local copy no longer matches the manifest
+	Unreachable int     `json:"unreachable"`  // Could not be downloaded again
+	DriftRate   float64 `json:"drift_rate"`   // Drifted share of the documents compared
+	CorruptRate float64 `json:"corrupt_rate"` // Corrupted share of the documents compared
+}
+
+// Outcome of comparing one document with its source
+type driftCheck struct {
+	problem                         Problem // Kind empty when there is nothing to report
+	drifted, corrupted, unreachable bool
+}
+
+// CompareRemote downloads live documents again, all of them or a random sample of opts.Sample, and compares the
+// content each source serves now with the manifest's hash and with the local copy, quantifying drift from the site
+// and silent corruption in storage. Bodies are hashed as they stream in and never written anywhere
+func CompareRemote(ctx context.Context, m *Manifest, opts VerifyOptions) (DriftSummary, []Problem, error) {
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var eligible []string
+	for _, documentURL := range sortedKeys(m.Documents) {
+		entry := m.Documents[documentURL]
+		if entry.Source != SourceUpload && entry.DeletedAt.IsZero() && !entry.DownloadedAt.IsZero() && entry.SHA256 != "" {
+			eligible = append(eligible, documentURL)
+		}
+	}
+	summary := DriftSummary{Eligible: len(eligible)}
+	sample := eligible
+	if opts.Sample > 0 && opts.Sample < len(eligible) {
+		sample = append([]string(nil), eligible...)
+		rand.Shuffle(len(sample), func(i, j int) { sample[i], sample[j] = sample[j], sample[i] })
+		sample = sample[:opts.Sample]
+	}
+
+	local := &localHashes{options: opts.Hashing, dir: opts.OutputDir, sums: make(map[string]string)}
+	urls := make(chan string)
+	var (
+		mu       sync.Mutex
+		problems []Problem
+		wg       sync.WaitGroup
+	)
+	for range max(opts.Workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for documentURL := range urls {
+				check, ok := compareSource(ctx, client, documentURL, m.Documents[documentURL], local)
+				if !ok {
+					continue // Interrupted
+				}
+				mu.Lock()
+				if check.unreachable {
+					summary.Unreachable++
+				} else {
+					summary.Compared++
+					if check.drifted {
+						summary.Drifted++
+					}
+					if check.corrupted {
+						summary.Corrupted++
+					}
+					if !check.drifted && !check.corrupted {
+						summary.Matched++
+					}
+				}
+				if check.problem.Kind != "" {
+					problems = append(problems, check.problem)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, documentURL := range sample {
+		select {
+		case urls <- documentURL:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(urls)
+	wg.Wait()
+	if summary.Compared > 0 {
+		summary.DriftRate = float64(summary.Drifted) / float64(summary.Compared)
+		summary.CorruptRate = float64(summary.Corrupted) / float64(summary.Compared)
+	}
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Filename < problems[j].Filename })
+	return summary, problems, ctx.Err()
+}
+
+// Downloads one document again and compares it with the manifest and the local copy
+func compareSource(ctx context.Context, client *http.Client, documentURL string, entry *ManifestEntry, local *localHashes) (driftCheck, bool) {
+	var check driftCheck
+	problem := Problem{URL: documentURL, Filename: entry.Filename}
+	remote, size, status, err := fetchHash(ctx, client, documentURL)
+	switch {
+	case err != nil:
+		if ctx.Err() != nil {
+			return check, false // Interrupted, not unreachable
+		}
+		problem.Kind, problem.Detail = ProblemSourceUnreachable, err.Error()
+		problem.Fix = "check the site; the next crawl retries the document"
+		check.unreachable = true
+	case status == http.StatusNotFound || status == http.StatusGone:
+		problem.Kind, problem.Detail = ProblemSourceGone, fmt.Sprintf("source answers %d %s", status, http.StatusText(status))
+		problem.Fix = "the next crawl soft-deletes it once the listing drops it; keep the local copy as the last known version"
+		check.unreachable = true
+	case status >= http.StatusBadRequest:
+		problem.Kind, problem.Detail = ProblemSourceUnreachable, fmt.Sprintf("source answers %d %s", status, http.StatusText(status))
+		problem.Fix = "check the site; the next crawl retries the document"
+		check.unreachable = true
+	case remote != entry.SHA256:
+		problem.Kind, problem.Detail = ProblemSourceChanged, fmt.Sprintf("source serves sha256 %s (%d bytes), archived %s", remote, size, entry.SHA256)
+		problem.Fix = "the archived copy is an older revision; the next crawl stores the new one once the server reports the change"
+		check.drifted = true
+	}
+	check.problem = problem
+	if sum, err := local.sum(entry.Filename); err != nil || sum != entry.SHA256 { // Fsck's half of Verify reports the details
+		check.corrupted = true
+	}
+	return check, true
+}
+
+// Downloads a document, returning the hex SHA-256 and size of its body along with the status
+func fetchHash(ctx context.Context, client *http.Client, documentURL string) (string, int64, int, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, documentURL, nil)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= http.StatusBadRequest {
+		return "", 0, response.StatusCode, nil
+	}
+	hash := sha256.New()
+	size, err := io.Copy(hash, io.LimitReader(response.Body, DefaultMaxFileSize))
+	if err != nil {
+		return "", size, response.StatusCode, err
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), size, response.StatusCode, nil
+}
+
+// Hashes local copies once each, as aliases share their original's file
+type localHashes struct {
+	options HashOptions
+	dir     string
+	mu      sync.Mutex
+	sums    map[string]string
+}
+
+// Returns the hex SHA-256 of the stored file name
+func (l *localHashes) sum(name string) (string, error) {
+	l.mu.Lock()
+	sum, ok := l.sums[name]
+	l.mu.Unlock()
+	if ok {
+		return sum, nil
+	}
+	sum, _, err := l.options.hashFile(filepath.Join(l.dir, name), nil)
+	if err != nil {
+		return "", err
+	}
+	l.mu.Lock()
+	l.sums[name] = sum
+	l.mu.Unlock()
+	return sum, nil
+}
@@ -0,0 +1,605 @@
+package sdscraper
+
+import ( // Import required packages
+	"bufio"           // For telling a stream's last chunk
+	"bytes"           // For sealing the manifest in memory
+	"cmp"             // For the error stopping a pipe
+	"context"         // For the key command and storage calls
+	"crypto/aes"      // For AES-256
+	"crypto/cipher"   // For GCM
+	"crypto/rand"     // For nonce prefixes
+	"encoding/base64" // For keys as text
+	"encoding/binary" // For the header and chunk counters
+	"encoding/hex"    // For keys as text
+	"errors"          // For the sentinel errors
+	"fmt"             // For error messages
+	"io"              // For sealed readers and writers
+	"math"            // For the longest name a header holds
+	"os"              // For key files and stored files
+	"os/exec"         // For key commands
+	"path"            // For names inside a Storage
+	"path/filepath"   // For names of local files
+	"slices"          // For assembling headers
+	"strings"         // For trimming key text
+	"sync"            // For sealed files read from several goroutines
+	"sync/atomic"     // For the process-wide key
+	"time"            // For the key command timeout
+)
+
+// ErrNoEncryptionKey is returned when reading a sealed file without the key it was sealed with
+var ErrNoEncryptionKey = errors.New("file is encrypted at rest; give the encryption key")
+
+// ErrNotSealed is returned when reading a plaintext file while encryption at rest is on, which would otherwise let a
+// file swapped in unencrypted pass for one of the archive's
+var ErrNotSealed = errors.New("file is not encrypted although encryption at rest is on; encrypt the archive with rekey first")
+
+// ErrPlaintextCopy is returned by Scraper.Run when a SQLite catalog or search index, which hold the archive's
+// metadata and text unencrypted, is asked for while encryption at rest is on
+var ErrPlaintextCopy = errors.New("the catalog database and search index are not encrypted; they cannot be kept while encryption at rest is on")
+
+// Returned for files sealed whole by earlier releases, which only rekey still opens
+var errLegacySealed = errors.New("file is sealed in the format of an earlier release; re-encrypt the archive with rekey")
+
+// Marks a sealed file: a header with the file's name and a nonce prefix, then the content in chunks sealed one by
+// one, each authenticated together with the header and whether it is the last, so chunks can neither be reordered,
+// dropped from the end nor moved between files
+const sealedMagic = "GOJOAES2"
+
+// Marks a file sealed whole, with nothing binding it to its name, by earlier releases
+const legacyMagic = "GOJOAES1"
+
+// Layout of sealed files
+const (
+	sealChunk       = 64 << 10 // Plaintext bytes per chunk; only the last is shorter
+	sealTag         = 16       // GCM tag ending every chunk
+	sealNoncePrefix = 8        // Random bytes starting every chunk's nonce, which a 4-byte chunk counter completes
+)
+
+var atRest atomic.Pointer[cipher.AEAD] // Set once at startup, read from every goroutine
+
+// SetEncryptionKey turns on encryption at rest with a 32-byte AES-256 key for the whole process: documents and the
+// manifest are sealed with AES-GCM when written and opened transparently when read, and plaintext files are refused,
+// so an existing archive is encrypted with Rekey first; nil turns it off
+func SetEncryptionKey(key []byte) error {
+	if key == nil {
+		atRest.Store(nil)
+		return nil
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+	atRest.Store(&aead)
+	return nil
+}
+
+// Returns the AES-256-GCM cipher of key
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key is %d bytes, want 32 for AES-256", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Returns the process key's cipher, nil while encryption is off
+func processAEAD() cipher.AEAD {
+	if aead := atRest.Load(); aead != nil {
+		return *aead
+	}
+	return nil
+}
+
+// EncryptionEnabled reports whether files are sealed as they are written
+func EncryptionEnabled() bool {
+	return atRest.Load() != nil
+}
+
+// LoadEncryptionKey reads a key from a file, or from what command prints, such as a KMS or vault client decrypting
+// a wrapped data key; either way as 64 hex digits or base64 of 32 bytes
+func LoadEncryptionKey(path string, command []string) ([]byte, error) {
+	var text []byte
+	var err error
+	switch {
+	case len(command) > 0:
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		run := exec.CommandContext(ctx, command[0], command[1:]...)
+		run.Stderr = os.Stderr // The client's own prompts and errors
+		if text, err = run.Output(); err != nil {
+			return nil, fmt.Errorf("encryption key command %s: %w", command[0], err)
+		}
+	case path != "":
+		if text, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("no encryption key file or command")
+	}
+	trimmed := strings.TrimSpace(string(text))
+	if key, err := hex.DecodeString(trimmed); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(trimmed); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, errors.New("encryption key must be 32 bytes as 64 hex digits or base64")
+}
+
+// The header starting a sealed file; every chunk is authenticated together with it
+type sealHeader struct {
+	raw  []byte // Marker, name length, name and nonce prefix as written
+	name string // Base name of the file the content was sealed for
+}
+
+// Returns a header for a file named name, with a fresh nonce prefix
+func newSealHeader(name string) (sealHeader, error) {
+	if len(name) > math.MaxUint16 {
+		return sealHeader{}, fmt.Errorf("file name of %d bytes is too long to seal", len(name))
+	}
+	raw := binary.BigEndian.AppendUint16([]byte(sealedMagic), uint16(len(name)))
+	raw = append(raw, name...)
+	prefix := make([]byte, sealNoncePrefix)
+	if _, err := rand.Read(prefix); err != nil {
+		return sealHeader{}, err
+	}
+	return sealHeader{raw: append(raw, prefix...), name: name}, nil
+}
+
+// Reads a file's marker and, when it is sealed, the rest of its header; a plaintext file yields no header and the
+// bytes read while looking, which belong to its content
+func readSealHeader(r io.Reader) (header *sealHeader, peeked []byte, err error) {
+	marker := make([]byte, len(sealedMagic))
+	n, err := io.ReadFull(r, marker)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, nil, err
+	}
+	switch string(marker[:n]) {
+	case sealedMagic:
+	case legacyMagic:
+		return nil, nil, errLegacySealed
+	default:
+		return nil, marker[:n], nil
+	}
+	length := make([]byte, 2)
+	if _, err := io.ReadFull(r, length); err != nil {
+		return nil, nil, fmt.Errorf("sealed file header is truncated: %w", err)
+	}
+	rest := make([]byte, int(binary.BigEndian.Uint16(length))+sealNoncePrefix)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return nil, nil, fmt.Errorf("sealed file header is truncated: %w", err)
+	}
+	return &sealHeader{raw: slices.Concat(marker, length, rest), name: string(rest[:len(rest)-sealNoncePrefix])}, nil, nil
+}
+
+// Fails unless the file was sealed for name; an empty name accepts any, for copies such as trashed files that
+// carry the name they were sealed under with them
+func (h *sealHeader) check(name string) error {
+	if name != "" && h.name != name {
+		return fmt.Errorf("file was sealed as %q, not %q: it was swapped for another file or renamed outside this tool", h.name, name)
+	}
+	return nil
+}
+
+// Returns chunk index's nonce and additional data
+func (h *sealHeader) chunk(index uint32, last bool) (nonce, additional []byte) {
+	nonce = binary.BigEndian.AppendUint32(bytes.Clone(h.raw[len(h.raw)-sealNoncePrefix:]), index)
+	additional = append(bytes.Clone(h.raw), 0)
+	if last {
+		additional[len(additional)-1] = 1
+	}
+	return nonce, additional
+}
+
+// Appends chunk index of plain, sealed, to dst
+func (h *sealHeader) seal(aead cipher.AEAD, dst, plain []byte, index uint32, last bool) []byte {
+	nonce, additional := h.chunk(index, last)
+	return aead.Seal(dst, nonce, plain, additional)
+}
+
+// Appends the plaintext of sealed chunk index to dst
+func (h *sealHeader) open(aead cipher.AEAD, dst, sealed []byte, index uint32, last bool) ([]byte, error) {
+	nonce, additional := h.chunk(index, last)
+	plain, err := aead.Open(dst, nonce, sealed, additional)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: wrong encryption key, or a damaged or truncated file: %w", err)
+	}
+	return plain, nil
+}
+
+// Returns the number of chunks and the plaintext size of a sealed body of size bytes after the header
+func sealedGeometry(size int64) (chunks, plain int64) {
+	chunks = max(1, (size+sealChunk+sealTag-1)/(sealChunk+sealTag)) // Empty content still has its last chunk
+	return chunks, size - chunks*sealTag
+}
+
+// Seals everything written to it for one file in chunks, the last of them on Close
+type chunkWriter struct {
+	aead   cipher.AEAD
+	header *sealHeader
+	dest   io.Writer
+	plain  []byte // Plaintext of the chunk being filled
+	sealed []byte // Reused for sealed chunks
+	index  uint32
+}
+
+// Returns a writer sealing for the file named name into dest with aead, or passing through to it for a nil aead;
+// Close seals the last chunk and leaves dest open
+func sealWriterWith(aead cipher.AEAD, dest io.Writer, name string) (io.WriteCloser, error) {
+	if aead == nil {
+		return nopWriteCloser{dest}, nil
+	}
+	header, err := newSealHeader(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dest.Write(header.raw); err != nil {
+		return nil, err
+	}
+	return &chunkWriter{aead: aead, header: &header, dest: dest, plain: make([]byte, 0, sealChunk)}, nil
+}
+
+// Write implements io.Writer; a full chunk is held until more follows, as only then is it known not to be the last
+func (c *chunkWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if len(c.plain) == sealChunk {
+			if err := c.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(c.plain[len(c.plain):sealChunk], p)
+		c.plain, p, written = c.plain[:len(c.plain)+n], p[n:], written+n
+	}
+	return written, nil
+}
+
+// Close implements io.Closer, sealing the last chunk
+func (c *chunkWriter) Close() error {
+	return c.flush(true)
+}
+
+// Seals and writes the chunk being filled
+func (c *chunkWriter) flush(last bool) error {
+	c.sealed = c.header.seal(c.aead, c.sealed[:0], c.plain, c.index, last)
+	c.plain, c.index = c.plain[:0], c.index+1
+	_, err := c.dest.Write(c.sealed)
+	return err
+}
+
+// An io.Writer with a Close doing nothing
+type nopWriteCloser struct{ io.Writer }
+
+// Close implements io.Closer
+func (nopWriteCloser) Close() error { return nil }
+
+// Opens sealed chunks one after another from a stream
+type chunkReader struct {
+	aead   cipher.AEAD
+	header *sealHeader
+	source *bufio.Reader
+	sealed []byte // Reused for sealed chunks
+	plain  []byte // Decrypted, not yet read
+	buffer []byte // Backs plain
+	index  uint32
+	last   bool // The last chunk was opened
+}
+
+// Returns the plaintext of r, a stored file sealed for the file named name with aead, or r itself when encryption
+// is off and the file plaintext; see sealHeader.check for an empty name
+func openReaderWith(aead cipher.AEAD, r io.Reader, name string) (io.Reader, error) {
+	header, peeked, err := readSealHeader(r)
+	switch {
+	case err != nil:
+		return nil, err
+	case header == nil && aead != nil:
+		return nil, ErrNotSealed
+	case header == nil:
+		return io.MultiReader(bytes.NewReader(peeked), r), nil
+	case aead == nil:
+		return nil, ErrNoEncryptionKey
+	}
+	if err := header.check(name); err != nil {
+		return nil, err
+	}
+	return &chunkReader{aead: aead, header: header, source: bufio.NewReaderSize(r, sealChunk+sealTag)}, nil
+}
+
+// Read implements io.Reader
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for len(c.plain) == 0 {
+		if c.last {
+			return 0, io.EOF
+		}
+		if err := c.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, c.plain)
+	c.plain = c.plain[n:]
+	return n, nil
+}
+
+// Opens the next chunk; a short one, or one nothing follows, is the last
+func (c *chunkReader) next() error {
+	if c.sealed == nil {
+		c.sealed = make([]byte, sealChunk+sealTag)
+	}
+	n, err := io.ReadFull(c.source, c.sealed)
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		c.last = true
+	case err != nil:
+		return err
+	default:
+		if _, err := c.source.Peek(1); err == io.EOF {
+			c.last = true
+		} else if err != nil {
+			return err
+		}
+	}
+	plain, err := c.header.open(c.aead, c.buffer[:0], c.sealed[:n], c.index, c.last)
+	if err != nil {
+		return err
+	}
+	c.buffer, c.plain, c.index = plain, plain, c.index+1
+	return nil
+}
+
+// Returns data sealed for the file named name with the process key, or data itself while encryption is off
+func seal(name string, data []byte) ([]byte, error) {
+	var sealed bytes.Buffer
+	writer, err := sealWriterWith(processAEAD(), &sealed, name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return sealed.Bytes(), nil
+}
+
+// Reads a stored file's plaintext, decrypting it if it was sealed
+func readStored(path string) ([]byte, error) {
+	file, err := openStored(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// Opens a stored file's plaintext: plaintext files stream from disk, sealed ones are decrypted a chunk at a time
+// as they are read, seeks included
+func openStored(path string) (io.ReadSeekCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	opened, err := openSealedFile(file, processAEAD(), filepath.Base(path))
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return opened, nil
+}
+
+// Returns the plaintext of file, sealed for the file named name with aead; plaintext files are returned themselves,
+// rewound, when aead is nil
+func openSealedFile(file *os.File, aead cipher.AEAD, name string) (io.ReadSeekCloser, error) {
+	header, _, err := readSealHeader(file)
+	switch {
+	case err != nil:
+		return nil, err
+	case header == nil && aead != nil:
+		return nil, ErrNotSealed
+	case header == nil:
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return file, nil
+	case aead == nil:
+		return nil, ErrNoEncryptionKey
+	}
+	if err := header.check(name); err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	chunks, size := sealedGeometry(info.Size() - int64(len(header.raw)))
+	if size < 0 {
+		return nil, errors.New("sealed file is truncated")
+	}
+	chunked := &chunkReaderAt{aead: aead, header: header, file: file, offset: int64(len(header.raw)), chunks: chunks, size: size, cached: -1}
+	return sealedFile{io.NewSectionReader(chunked, 0, size), file}, nil
+}
+
+// Opens the chunks of a sealed file in any order, keeping the last one opened for sequential reads
+type chunkReaderAt struct {
+	aead   cipher.AEAD
+	header *sealHeader
+	file   *os.File
+	offset int64 // Where the first chunk starts
+	chunks int64
+	size   int64 // Plaintext bytes
+
+	mu     sync.Mutex // Guards the fields below
+	cached int64      // Chunk held in plain, -1 for none
+	plain  []byte
+	sealed []byte
+}
+
+// ReadAt implements io.ReaderAt
+func (c *chunkReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for n < len(p) && off < c.size {
+		index := off / sealChunk
+		if err := c.load(index); err != nil {
+			return n, err
+		}
+		copied := copy(p[n:], c.plain[off-index*sealChunk:])
+		n, off = n+copied, off+int64(copied)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Opens chunk index into plain unless it is there already
+func (c *chunkReaderAt) load(index int64) error {
+	if c.cached == index {
+		return nil
+	}
+	if c.sealed == nil {
+		c.sealed = make([]byte, sealChunk+sealTag)
+	}
+	n, err := c.file.ReadAt(c.sealed, c.offset+index*(sealChunk+sealTag))
+	if err != nil && err != io.EOF {
+		return err
+	}
+	c.cached = -1
+	plain, err := c.header.open(c.aead, c.plain[:0], c.sealed[:n], uint32(index), index == c.chunks-1)
+	if err != nil {
+		return err
+	}
+	c.plain, c.cached = plain, index
+	return nil
+}
+
+// Reports whether the file at path was sealed, in either format
+func sealedOnDisk(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	marker := make([]byte, len(sealedMagic))
+	n, _ := io.ReadFull(file, marker)
+	return n == len(marker) && (string(marker) == sealedMagic || string(marker) == legacyMagic)
+}
+
+// Returns the plaintext size of a stored file of size bytes on disk
+func plainSize(path string, size int64) int64 {
+	file, err := os.Open(path)
+	if err != nil {
+		return size
+	}
+	defer file.Close()
+	header, _, err := readSealHeader(file)
+	if err != nil || header == nil {
+		return size
+	}
+	_, plain := sealedGeometry(size - int64(len(header.raw)))
+	return max(0, plain)
+}
+
+// A decrypted view of a sealed file
+type sealedFile struct {
+	*io.SectionReader
+	file *os.File
+}
+
+// Close implements io.Closer
+func (f sealedFile) Close() error { return f.file.Close() }
+
+// Seals documents on their way into a Storage and opens them on the way out, so local directories and buckets
+// alike only ever hold ciphertext; each is sealed for its base name, so it still opens after moving to another
+// folder, e.g. the trash or a snapshot
+type sealedStorage struct {
+	Storage
+	spoolDir string // Where sealed copies wait for stores that need to size them before the upload, empty for os.TempDir()
+}
+
+// Returns storage sealing what it stores while encryption is on, storage itself otherwise
+func sealStorage(storage Storage, spoolDir string) Storage {
+	if !EncryptionEnabled() {
+		return storage
+	}
+	return sealedStorage{Storage: storage, spoolDir: spoolDir}
+}
+
+// Put implements Storage
+func (s sealedStorage) Put(ctx context.Context, name string, content io.Reader) error {
+	_, err := s.PutVersion(ctx, name, content)
+	return err
+}
+
+// PutVersion implements VersionedStorage, returning no version when the wrapped storage keeps none; local
+// directories take the sealed stream as it is produced, other stores a sealed copy spooled to disk, which they
+// can size and hash before uploading it
+func (s sealedStorage) PutVersion(ctx context.Context, name string, content io.Reader) (string, error) {
+	if _, local := s.Storage.(LocalStorage); local {
+		reader, writer := io.Pipe()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			writer.CloseWithError(sealTo(writer, name, content))
+		}()
+		version, err := putVersion(ctx, s.Storage, name, reader)
+		reader.CloseWithError(cmp.Or(err, io.ErrClosedPipe)) // Stops the sealing should the store give up early
+		<-done
+		return version, err
+	}
+
+	dir := s.spoolDir
+	if dir == "" {
+		dir = os.TempDir()
+	} else if err := makeDirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	spooled, err := createTemp(dir, "gojo-sealed-*")
+	if err != nil {
+		return "", fmt.Errorf("create sealed spool file: %w", err)
+	}
+	defer func() {
+		spooled.Close()
+		removeFile(spooled.Name())
+	}()
+	if err := sealTo(spooled, name, content); err != nil {
+		return "", err
+	}
+	if _, err := spooled.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return putVersion(ctx, s.Storage, name, spooled)
+}
+
+// Seals content for the document stored as name into dest
+func sealTo(dest io.Writer, name string, content io.Reader) error {
+	writer, err := sealWriterWith(processAEAD(), dest, path.Base(name))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(writer, content); err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+// Open implements Storage, decrypting as the content streams
+func (s sealedStorage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	content, err := s.Storage.Open(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := openReaderWith(processAEAD(), content, path.Base(name))
+	if err != nil {
+		content.Close()
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{plain, content}, nil
+}
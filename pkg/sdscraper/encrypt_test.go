@@ -0,0 +1,152 @@
+package sdscraper
+
+import ( // Import required packages
+	"bytes"         // For comparing plaintext
+	"context"       // For running the scraper
+	"errors"        // For matching sentinel errors
+	"io"            // For reading sealed files
+	"os"            // For writing test files
+	"path/filepath" // For test file paths
+	"testing"       // For the test harness
+)
+
+// Turns encryption at rest on with key for the rest of the test
+func useKey(t *testing.T, key byte) {
+	t.Helper()
+	if err := SetEncryptionKey(bytes.Repeat([]byte{key}, 32)); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetEncryptionKey(nil) })
+}
+
+// Returns size bytes of varying content
+func content(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i*7 + i/sealChunk)
+	}
+	return data
+}
+
+func TestSealRoundTrip(t *testing.T) {
+	useKey(t, 1)
+	dir := t.TempDir()
+	for _, size := range []int{0, 1, sealChunk - 1, sealChunk, sealChunk + 1, 3*sealChunk + 100} {
+		plain := content(size)
+		path := filepath.Join(dir, "sheet.pdf")
+		sealed, err := seal("sheet.pdf", plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, sealed, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if got := plainSize(path, int64(len(sealed))); got != int64(size) {
+			t.Errorf("plainSize of %d bytes = %d", size, got)
+		}
+		read, err := readStored(path) // Random access, chunk by chunk
+		if err != nil || !bytes.Equal(read, plain) {
+			t.Errorf("readStored of %d bytes: %v, equal %t", size, err, bytes.Equal(read, plain))
+		}
+		streamed, err := openReaderWith(processAEAD(), bytes.NewReader(sealed), "sheet.pdf") // Sequential, as from a bucket
+		if err == nil {
+			read, err = io.ReadAll(streamed)
+		}
+		if err != nil || !bytes.Equal(read, plain) {
+			t.Errorf("streaming %d bytes: %v, equal %t", size, err, bytes.Equal(read, plain))
+		}
+	}
+}
+
+func TestSealedFileSeeks(t *testing.T) {
+	useKey(t, 1)
+	plain := content(2*sealChunk + 500)
+	path := filepath.Join(t.TempDir(), "sheet.pdf")
+	sealed, _ := seal("sheet.pdf", plain)
+	os.WriteFile(path, sealed, 0o644)
+	file, err := openStored(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	part := make([]byte, 1000)
+	offset := int64(sealChunk - 300) // Spans two chunks
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(file, part); err != nil || !bytes.Equal(part, plain[offset:offset+1000]) {
+		t.Errorf("read after seek: %v", err)
+	}
+}
+
+func TestSealRejectsTampering(t *testing.T) {
+	useKey(t, 1)
+	plain := content(2*sealChunk + 10)
+	sealed, _ := seal("gel.pdf", plain)
+	chunk := sealChunk + sealTag
+	headerLength := len(sealed) - 2*chunk - 10 - sealTag
+	for name, test := range map[string]struct {
+		data []byte
+		as   string
+	}{
+		"other name":      {sealed, "foam.pdf"},
+		"last chunk gone": {sealed[:headerLength+2*chunk], "gel.pdf"},
+		"bit flipped":     {append(bytes.Clone(sealed[:len(sealed)-1]), sealed[len(sealed)-1]^1), "gel.pdf"},
+		"chunks swapped": {bytes.Join([][]byte{sealed[:headerLength], sealed[headerLength+chunk : headerLength+2*chunk],
+			sealed[headerLength : headerLength+chunk], sealed[headerLength+2*chunk:]}, nil), "gel.pdf"},
+	} {
+		path := filepath.Join(t.TempDir(), test.as)
+		os.WriteFile(path, test.data, 0o644)
+		if _, err := readStored(path); err == nil {
+			t.Errorf("%s: opened", name)
+		}
+	}
+}
+
+func TestEncryptionRefusesPlaintext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	if err := SaveManifest(path, NewManifest()); err != nil { // Plaintext
+		t.Fatal(err)
+	}
+	useKey(t, 1)
+	if _, err := LoadManifest(path); !errors.Is(err, ErrNotSealed) {
+		t.Errorf("LoadManifest of a plaintext manifest with a key = %v, want ErrNotSealed", err)
+	}
+}
+
+func TestLoadManifestWrongKey(t *testing.T) {
+	useKey(t, 1)
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	manifest := NewManifest()
+	manifest.Documents["https://www.gojo.com/gel.pdf"] = &ManifestEntry{Filename: "gel.pdf"}
+	if err := SaveManifest(path, manifest); err != nil {
+		t.Fatal(err)
+	}
+	useKey(t, 2)
+	if loaded, err := LoadManifest(path); err == nil {
+		t.Errorf("LoadManifest under another key returned %d documents and no error", len(loaded.Documents))
+	}
+	SetEncryptionKey(nil)
+	if _, err := LoadManifest(path); !errors.Is(err, ErrNoEncryptionKey) {
+		t.Errorf("LoadManifest without a key = %v, want ErrNoEncryptionKey", err)
+	}
+	if err := SaveManifest(path, NewManifest()); !errors.Is(err, ErrNoEncryptionKey) {
+		t.Errorf("SaveManifest over a sealed manifest without a key = %v, want ErrNoEncryptionKey", err)
+	}
+}
+
+func TestRunRefusesPlaintextCopies(t *testing.T) {
+	useKey(t, 1)
+	dir := t.TempDir()
+	for option, s := range map[string]*Scraper{
+		"CatalogDB":   {CatalogDB: filepath.Join(dir, "catalog.db")},
+		"SearchIndex": {SearchIndex: filepath.Join(dir, "search.db")},
+	} {
+		if _, err := s.Run(context.Background()); !errors.Is(err, ErrPlaintextCopy) {
+			t.Errorf("Run with %s under encryption = %v, want ErrPlaintextCopy", option, err)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) > 0 {
+		t.Errorf("Run wrote %d files before refusing", len(entries))
+	}
+}
@@ -0,0 +1,187 @@
+package sdscraper
+
+import ( // Import required packages
+	"bytes"         // For writing the state file
+	"cmp"           // For defaults
+	"encoding/json" // For the export state and index
+	"errors"        // For matching missing files
+	"fmt"           // For error messages
+	"io"            // For archive writers
+	"io/fs"         // For missing-file errors
+	"iter"          // For the archive writers
+	"log/slog"      // For structured logging
+	"os"            // For writing the archive
+	"path/filepath" // For OS-independent path operations
+	"runtime"       // For the default worker count
+	"slices"        // For vendor filters
+	"sort"          // For a stable archive order
+	"time"          // For dated archive names
+)
+
+// ExportOptions configures Export
+type ExportOptions struct {
+	OutputDir    string         // Directory holding the documents
+	ManifestPath string         // Manifest embedded in every archive
+	Dir          string         // Where the archive is written
+	Format       string         // "zip" or "tar.gz"
+	StatePath    string         // Remembers what earlier exports contained, empty to keep no record
+	Incremental  bool           // Only documents added or changed since the last export recorded in StatePath
+	Tags         TagFilter      // Only documents passing this filter; the zero filter exports everything
+	Vendors      []string       // Only documents of these vendors, none for all
+	CoverPages   bool           // Put a cover page stamping source, hash and retrieval date in front of each PDF
+	Stamp        *StampTemplate // Brand every PDF page, and the cover pages, for one facility; nil for none
+	Snapshot     *Snapshot      // Export this snapshot instead of OutputDir and ManifestPath, never incrementally; nil for the live archive
+	Workers      int            // Documents stamped at once ahead of the archive writer, zero for one per CPU
+	MaxMemory    int64          // Rough bound on the memory stamped documents take while they wait, zero for DefaultExportMemory
+}
+
+// ExportResult describes a written archive
+type ExportResult struct {
+	Path        string    `json:"path"`
+	Format      string    `json:"format"`
+	Created     time.Time `json:"created"`
+	Incremental bool      `json:"incremental"`
+	Tags        string    `json:"tags,omitempty"`        // Tag filter the documents were selected by
+	Vendors     []string  `json:"vendors,omitempty"`     // Vendors the documents were selected from
+	Since       time.Time `json:"since,omitzero"`        // The export an incremental one continues from
+	Documents   []string  `json:"documents"`             // File names inside documents/, sorted
+	Skipped     []string  `json:"skipped"`               // Catalogued files missing from disk
+	CoverPages  bool      `json:"cover_pages,omitempty"` // PDFs carry a cover page, so their hashes differ from the manifest's
+	Facility    string    `json:"facility,omitempty"`    // Facility the PDFs were stamped for, likewise
+	Snapshot    string    `json:"snapshot,omitempty"`    // Snapshot exported, whose catalog the archive's manifest.json is
+	Build       BuildInfo `json:"build"`                 // Binary that wrote the archive
+}
+
+// What the last export contained, so the next incremental one knows what changed
+type exportState struct {
+	At        time.Time         `json:"at"`
+	Documents map[string]string `json:"documents"` // URL to SHA-256 at export time
+}
+
+// Export packages the catalogued documents and the manifest into a dated zip or tar.gz in options.Dir; the archive
+// holds documents/<filename>, manifest.json and export.json describing what was included
+func Export(options ExportOptions) (*ExportResult, error) {
+	writeArchive := map[string]func(io.Writer, iter.Seq[archiveFile]) error{"zip": writeZip, "tar.gz": writeTarGz}[options.Format]
+	if writeArchive == nil {
+		return nil, fmt.Errorf("unknown archive format %q (want zip or tar.gz)", options.Format)
+	}
+	if options.Snapshot != nil { // Frozen, so there is nothing to continue from or record
+		if options.Incremental {
+			return nil, errors.New("a snapshot is exported in full")
+		}
+		options.OutputDir, options.ManifestPath, options.StatePath = options.Snapshot.DocumentsDir(), options.Snapshot.ManifestPath(), ""
+	}
+	manifest, err := ReadManifest(options.ManifestPath)
+	if err != nil {
+		return nil, err
+	}
+	state := exportState{Documents: make(map[string]string)}
+	if options.StatePath != "" {
+		if data, err := os.ReadFile(options.StatePath); err == nil {
+			if err := json.Unmarshal(data, &state); err != nil {
+				return nil, fmt.Errorf("read export state: %w", err)
+			}
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	if options.Incremental && options.StatePath == "" {
+		return nil, errors.New("an incremental export needs an export state file")
+	}
+
+	now := time.Now().UTC()
+	result := &ExportResult{Documents: []string{}, Skipped: []string{}, Build: Build(), Created: now, Format: options.Format, Incremental: options.Incremental, Tags: options.Tags.String(), Vendors: options.Vendors, CoverPages: options.CoverPages}
+	if options.Stamp != nil {
+		result.Facility = options.Stamp.Facility
+	}
+	if options.Snapshot != nil {
+		result.Snapshot = options.Snapshot.Name
+	}
+	if options.Incremental {
+		result.Since = state.At
+	}
+	next := exportState{At: now, Documents: make(map[string]string)}
+	var files []archiveFile
+	included := make(map[string]bool) // Aliases share a file
+	selected := func(entry *ManifestEntry) bool {
+		return entry.SHA256 != "" && entry.DeletedAt.IsZero() && options.Tags.Match(entry.Tags) &&
+			(len(options.Vendors) == 0 || slices.Contains(options.Vendors, entry.Vendor))
+	}
+	for _, documentURL := range sortedKeys(manifest.Documents) {
+		entry := manifest.Documents[documentURL]
+		if !selected(entry) || entry.coveredDuplicate(manifest.Documents, selected) {
+			continue // Never downloaded, withdrawn, outside the filters, or another vendor's copy is in already
+		}
+		next.Documents[documentURL] = entry.SHA256
+		if options.Incremental && state.Documents[documentURL] == entry.SHA256 {
+			continue
+		}
+		if included[entry.Filename] {
+			continue
+		}
+		path := filepath.Join(options.OutputDir, filepath.FromSlash(entry.Filename))
+		if _, err := os.Stat(path); err != nil {
+			result.Skipped = append(result.Skipped, entry.Filename) // E.g. evicted from a serve cache
+			continue
+		}
+		included[entry.Filename] = true
+		file := archiveFile{name: "documents/" + entry.Filename, path: path}
+		if (options.CoverPages || options.Stamp != nil) && coverable(entry.Filename) {
+			file.stamp = &pdfStamp{documentURL: documentURL, entry: entry, cover: options.CoverPages, template: options.Stamp}
+		}
+		files = append(files, file)
+		result.Documents = append(result.Documents, entry.Filename)
+	}
+	sort.Strings(result.Documents)
+
+	suffix := "full"
+	switch {
+	case options.Incremental:
+		suffix = "incremental"
+	case options.Snapshot != nil:
+		suffix = "snapshot-" + options.Snapshot.ID
+	}
+	result.Path = filepath.Join(options.Dir, fmt.Sprintf("gojo-%s-%s.%s", now.Format("20060102T150405Z"), suffix, options.Format))
+	index, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	files = append(files,
+		archiveFile{name: "manifest.json", path: options.ManifestPath},
+		archiveFile{name: "export.json", data: append(index, '\n')},
+	)
+
+	if err := makeDirAll(options.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	partPath := result.Path + ".part" // Auditors never see half an archive
+	out, err := createFile(partPath)
+	if err != nil {
+		return nil, err
+	}
+	workers, budget := cmp.Or(options.Workers, runtime.GOMAXPROCS(0)), cmp.Or(options.MaxMemory, DefaultExportMemory)
+	if err := writeArchive(out, renderAhead(files, workers, budget)); err != nil {
+		out.Close()
+		removeFile(partPath)
+		return nil, err
+	}
+	if err := out.Close(); err != nil {
+		removeFile(partPath)
+		return nil, err
+	}
+	if err := renameFile(partPath, result.Path); err != nil {
+		return nil, err
+	}
+
+	if options.StatePath != "" { // Only after the archive exists, so a failed export is repeated in full
+		data, err := json.MarshalIndent(next, "", "  ")
+		if err == nil {
+			err = writePartThenRename(options.StatePath, bytes.NewReader(data))
+		}
+		if err != nil {
+			return result, fmt.Errorf("archive written but export state not saved: %w", err)
+		}
+	}
+	slog.Info("Exported archive", "path", result.Path, "documents", len(result.Documents), "incremental", options.Incremental)
+	return result, nil
+}
@@ -0,0 +1,278 @@
+package sdscraper
+
+import ( // Import required packages
+	"bytes"    // For capturing OCR output
+	"cmp"      // For the default timeout
+	"context"  // For cancelling extractors
+	"fmt"      // For error messages
+	"io"       // For document content
+	"log/slog" // For structured logging
+	"net/url"  // For URL heuristics
+	"os/exec"  // For the OCR command
+	"path"     // For the URL's file name
+	"regexp"   // For text patterns
+	"strings"  // For text handling
+	"time"     // For the OCR timeout
+
+	"github.com/ledongthuc/pdf" // For document information and page text
+)
+
+// MetadataExtractor reads metadata fields out of a stored document; extractors form a chain tried in order, each
+// field taken from the first extractor that finds it
+type MetadataExtractor interface {
+	Name() string                                                                 // Identifies the extractor in -extractors, logs and FieldSource
+	Extract(ctx context.Context, document ExtractInput) (DocumentMetadata, error) // Fields it found, with their Sources
+}
+
+// ExtractInput is the document a MetadataExtractor reads
+type ExtractInput struct {
+	URL     string      // Source URL
+	Type    string      // Document type name, e.g. "pdf"
+	Content io.ReaderAt // The validated content
+	Size    int64       // Its length in bytes
+}
+
+// DefaultOCRTimeout bounds one run of the OCR command
+const DefaultOCRTimeout = 2 * time.Minute
+
+// ParseExtractors builds the chain named by -extractors, e.g. pdf-info,first-page,url,ocr; ocr runs ocrCommand
+func ParseExtractors(names []string, ocrCommand []string) ([]MetadataExtractor, error) {
+	var chain []MetadataExtractor
+	known := []string{"first-page", "ocr", "pdf-info", "url"}
+	for _, name := range names {
+		switch strings.ToLower(name) {
+		case "pdf-info":
+			chain = append(chain, PDFInfoExtractor{})
+		case "first-page":
+			chain = append(chain, FirstPageExtractor{})
+		case "url":
+			chain = append(chain, URLExtractor{})
+		case "ocr":
+			if len(ocrCommand) == 0 {
+				return nil, fmt.Errorf("the ocr extractor needs an OCR command")
+			}
+			chain = append(chain, OCRExtractor{Command: ocrCommand})
+		default:
+			return nil, fmt.Errorf("unknown extractor %q (known: %s)", name, strings.Join(known, ", "))
+		}
+	}
+	return chain, nil
+}
+
+// Runs the chain over a document, taking each field from the first extractor that finds it and stopping once every
+// field is known; a failing extractor is logged and passed over
+func extractMetadata(ctx context.Context, chain []MetadataExtractor, document ExtractInput) DocumentMetadata {
+	combined := DocumentMetadata{Sources: make(map[string]FieldSource)}
+	for _, extractor := range chain {
+		found, err := runExtractor(ctx, extractor, document)
+		if err != nil {
+			slog.Warn("Metadata extractor failed", "extractor", extractor.Name(), "url", document.URL, "err", err)
+		}
+		found = found.attributed(MethodRegex, extractor.Name(), LowConfidence) // For extractors that rate nothing
+		complete := true
+		for _, field := range CorrectableFields {
+			target, value := metadataValue(&combined, field), *metadataValue(&found, field)
+			if *target == "" && value != "" {
+				*target = value
+				combined.Sources[field] = found.Sources[field]
+			}
+			complete = complete && *target != ""
+		}
+		if complete {
+			break
+		}
+	}
+	return combined
+}
+
+// Calls one extractor, turning a panic on a malformed document into an error
+func runExtractor(ctx context.Context, extractor MetadataExtractor, document ExtractInput) (found DocumentMetadata, err error) {
+	defer recoverPanic("extract "+extractor.Name(), document.URL, func(recovered *PanicError) { err = recovered })
+	return extractor.Extract(ctx, document)
+}
+
+// Returns a pointer to one of the CorrectableFields of m
+func metadataValue(m *DocumentMetadata, field string) *string {
+	switch field {
+	case "language":
+		return &m.Language
+	case "product":
+		return &m.Product
+	case "revision":
+		return &m.Revision
+	}
+	return &m.SKU
+}
+
+// PDFInfoExtractor reads the title and language a PDF declares in its document information, XMP metadata and catalog
+type PDFInfoExtractor struct{}
+
+// Name implements MetadataExtractor
+func (PDFInfoExtractor) Name() string { return "pdf-info" }
+
+var (
+	xmpTitle       = regexp.MustCompile(`(?s)<dc:title>.*?<rdf:li[^>]*>([^<]+)</rdf:li>`) // Dublin Core title in an XMP packet
+	genericTitle   = regexp.MustCompile(`(?i)^(untitled|document\d*|microsoft word.*|safety data sheet|sds|msds|.*\.(pdf|docx?))$`)
+	languagePrefix = regexp.MustCompile(`^([a-zA-Z]{2})(?:[-_][a-zA-Z]{2,4})?$`) // BCP 47 tags such as en-US
+)
+
+// Extract implements MetadataExtractor
+func (PDFInfoExtractor) Extract(ctx context.Context, document ExtractInput) (DocumentMetadata, error) {
+	var meta DocumentMetadata
+	if document.Type != "pdf" {
+		return meta, nil
+	}
+	reader, err := pdf.NewReader(document.Content, document.Size)
+	if err != nil {
+		return meta, err
+	}
+	meta.Sources = make(map[string]FieldSource)
+	title := cleanText(reader.Trailer().Key("Info").Key("Title").Text())
+	if metadata := reader.Trailer().Key("Root").Key("Metadata"); title == "" && metadata.Kind() == pdf.Stream {
+		packet, _ := io.ReadAll(io.LimitReader(metadata.Reader(), 1<<20))
+		if match := xmpTitle.FindSubmatch(packet); match != nil {
+			title = cleanText(string(match[1]))
+		}
+	}
+	if title != "" && !genericTitle.MatchString(title) { // Authoring tools fill in file names and boilerplate
+		meta.Product = title
+		meta.Sources["product"] = FieldSource{Method: MethodPDFMetadata, Extractor: "pdf-info", Confidence: 0.6}
+	}
+	if match := languagePrefix.FindStringSubmatch(reader.Trailer().Key("Root").Key("Lang").Text()); match != nil {
+		meta.Language = strings.ToLower(match[1])
+		meta.Sources["language"] = FieldSource{Method: MethodPDFMetadata, Extractor: "pdf-info", Confidence: 0.85}
+	}
+	return meta, nil
+}
+
+// FirstPageExtractor matches the labelled product name, code and revision date an SDS prints in its first section
+type FirstPageExtractor struct{}
+
+// Name implements MetadataExtractor
+func (FirstPageExtractor) Name() string { return "first-page" }
+
+// Extract implements MetadataExtractor
+func (FirstPageExtractor) Extract(ctx context.Context, document ExtractInput) (DocumentMetadata, error) {
+	if document.Type != "pdf" {
+		return DocumentMetadata{}, nil
+	}
+	reader, err := pdf.NewReader(document.Content, document.Size)
+	if err != nil || reader.NumPage() == 0 {
+		return DocumentMetadata{}, err
+	}
+	page := reader.Page(1)
+	if page.V.IsNull() {
+		return DocumentMetadata{}, nil
+	}
+	text, err := page.GetPlainText(nil)
+	if err != nil {
+		return DocumentMetadata{}, err
+	}
+	return textMetadata(text, MethodRegex, "first-page", 1), nil
+}
+
+var (
+	labelledProduct = regexp.MustCompile(`(?i)(?:product\s+(?:name|identifier)|trade\s+name)\s*[:.]?\s*([^\n|]{3,120})`) // Section 1 of an SDS
+	nextLabel       = regexp.MustCompile(`(?i)(?:recommended use|synonyms|other means of identification|product (?:code|type)|supplier|manufacturer|company|emergency)\b`)
+)
+
+// Matches an SDS's labelled fields in text, scaling the confidences by weight for text that may be misread
+func textMetadata(text, method, extractor string, weight float64) DocumentMetadata {
+	meta := DocumentMetadata{Sources: make(map[string]FieldSource)}
+	found := func(field string, confidence float64) {
+		meta.Sources[field] = FieldSource{Method: method, Extractor: extractor, Confidence: confidence * weight}
+	}
+	if match := labelledProduct.FindStringSubmatch(text); match != nil {
+		product := cleanText(match[1])
+		for _, label := range []*regexp.Regexp{nextLabel, skuRegex, revisionRegex} { // Text runs often join the next field on
+			if index := label.FindStringIndex(product); index != nil {
+				product = strings.Trim(product[:index[0]], " -–:;,")
+			}
+		}
+		if len(product) >= 3 {
+			meta.Product = product
+			found("product", 0.85)
+		}
+	}
+	if match := skuRegex.FindStringSubmatch(text); match != nil {
+		meta.SKU = match[1]
+		found("sku", 0.8)
+	}
+	if match := revisionRegex.FindStringSubmatch(text); match != nil {
+		meta.Revision = normaliseDate(match[1])
+		found("revision", 0.85)
+		if _, err := time.Parse("2006-01-02", meta.Revision); err != nil {
+			found("revision", 0.3)
+		}
+	}
+	return meta
+}
+
+// URLExtractor guesses the language, item number and product from the document's URL, as a last resort
+type URLExtractor struct{}
+
+// Name implements MetadataExtractor
+func (URLExtractor) Name() string { return "url" }
+
+var (
+	urlLanguage = regexp.MustCompile(`(?i)(?:^|[/_.-])(en|fr|es|pt|de|it|nl|ja|zh|ko|pl)(?:[-_][a-z]{2})?(?:$|[/_.-])`) // e.g. /fr-CA/ or _EN.pdf
+	slugWords   = regexp.MustCompile(`^[A-Za-z]+(?:[-_ ][A-Za-z]+)+$`)                                                  // e.g. purell-advanced-gel
+)
+
+// Extract implements MetadataExtractor
+func (URLExtractor) Extract(ctx context.Context, document ExtractInput) (DocumentMetadata, error) {
+	parsed, err := url.Parse(document.URL)
+	if err != nil {
+		return DocumentMetadata{}, err
+	}
+	meta := DocumentMetadata{Sources: make(map[string]FieldSource)}
+	found := func(field string, confidence float64) {
+		meta.Sources[field] = FieldSource{Method: MethodRegex, Extractor: "url", Confidence: confidence}
+	}
+	name := strings.ReplaceAll(strings.TrimSuffix(path.Base(parsed.Path), path.Ext(parsed.Path)), "_", " ")
+	if match := bareSKURegex.FindString(name); match != "" {
+		meta.SKU = match
+		found("sku", 0.5)
+		name = strings.Trim(strings.ReplaceAll(name, match, ""), " -")
+	}
+	if match := urlLanguage.FindStringSubmatch(parsed.Path); match != nil {
+		meta.Language = strings.ToLower(match[1])
+		found("language", 0.4)
+	}
+	if slugWords.MatchString(name) && !boilerplate.MatchString(name) {
+		words := strings.FieldsFunc(name, func(r rune) bool { return r == '-' || r == '_' || r == ' ' })
+		for i, word := range words {
+			words[i] = strings.ToUpper(word[:1]) + word[1:]
+		}
+		meta.Product = strings.Join(words, " ")
+		found("product", 0.3)
+	}
+	return meta, nil
+}
+
+// OCRExtractor runs an OCR command over documents that carry no text layer, e.g. scanned sheets: the command reads
+// the document on stdin and prints the recognised text, which is matched like a first page's
+type OCRExtractor struct {
+	Command []string      // Program and arguments, e.g. a script running pdftoppm and tesseract
+	Timeout time.Duration // Longest one document may take, zero for DefaultOCRTimeout
+}
+
+// Name implements MetadataExtractor
+func (OCRExtractor) Name() string { return "ocr" }
+
+// Extract implements MetadataExtractor
+func (o OCRExtractor) Extract(ctx context.Context, document ExtractInput) (DocumentMetadata, error) {
+	if document.Type != "pdf" || len(o.Command) == 0 {
+		return DocumentMetadata{}, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, cmp.Or(o.Timeout, DefaultOCRTimeout))
+	defer cancel()
+	var text, stderr bytes.Buffer
+	command := exec.CommandContext(ctx, o.Command[0], o.Command[1:]...)
+	command.Stdin = io.NewSectionReader(document.Content, 0, document.Size)
+	command.Stdout, command.Stderr = &text, &stderr
+	if err := command.Run(); err != nil {
+		return DocumentMetadata{}, fmt.Errorf("%s: %w: %s", o.Command[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return textMetadata(text.String(), MethodOCR, "ocr", 0.75), nil // Recognition misreads characters
+}
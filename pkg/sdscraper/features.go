@@ -0,0 +1,71 @@
+package sdscraper
+
+import ( // Import required packages
+	"fmt"     // For error messages
+	"sort"    // For listing known features
+	"strings" // For parsing names
+)
+
+// Named features gating behaviours still being rolled out; each mirror opts in until a feature becomes the default
+const (
+	FeatureAdaptiveConcurrency = "adaptive-concurrency" // Start downloads at one worker, grow while the server copes and halve on throttling
+	FeatureBrowserFallback     = "browser-fallback"     // Render a listing in Chrome when plain HTTP finds no documents there
+	FeaturePopularFirst        = "popular-first"        // Revalidate the documents serve mode hands out most before the rest
+)
+
+// Descriptions of the known features, for help output
+var knownFeatures = map[string]string{
+	FeatureAdaptiveConcurrency: "grow download concurrency up to -workers while the server copes, halve it on 429 and 503 responses",
+	FeatureBrowserFallback:     "with -renderer http, render listings in Chrome when plain HTTP finds no documents",
+	FeaturePopularFirst:        "revalidate and download the most-accessed documents of each listing first, by serve mode's access counts",
+}
+
+// Features is the set of enabled feature names; the nil set enables none
+type Features map[string]bool
+
+// ParseFeatures reads feature names, e.g. from -features; a "-" prefix disables a feature an earlier item enabled,
+// so profiles can switch one off by appending to the defaults
+func ParseFeatures(names []string) (Features, error) {
+	features := make(Features)
+	for _, name := range names {
+		disable := strings.HasPrefix(name, "-")
+		name = strings.TrimPrefix(strings.TrimPrefix(name, "-"), "+")
+		if _, ok := knownFeatures[name]; !ok {
+			return nil, fmt.Errorf("unknown feature %q (known: %s)", name, strings.Join(KnownFeatures(), ", "))
+		}
+		features[name] = !disable
+	}
+	return features, nil
+}
+
+// KnownFeatures returns the names ParseFeatures accepts, sorted
+func KnownFeatures() []string {
+	names := make([]string, 0, len(knownFeatures))
+	for name := range knownFeatures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DescribeFeature returns what a known feature does
+func DescribeFeature(name string) string {
+	return knownFeatures[name]
+}
+
+// Enabled reports whether the named feature is switched on
+func (f Features) Enabled(name string) bool {
+	return f[name]
+}
+
+// Names returns the enabled features, sorted
+func (f Features) Names() []string {
+	var names []string
+	for name, enabled := range f {
+		if enabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,59 @@
+package sdscraper
+
+import ( // Import required packages
+	"log/slog"      // For structured logging
+	"os"            // For file and directory handling
+	"path/filepath" // For OS-independent path operations
+)
+
+// Replaces the file's content, writing a temporary file first so readers never see a partial one
+func writeToFile(path string, content string) {
+	temp := path + ".tmp"                                          // Renamed over the target once complete
+	if err := writeFile(temp, []byte(content), 0644); err != nil { // Create or truncate
+		slog.Error("Writing file failed", "path", temp, "err", err)
+		return
+	}
+	if err := renameFile(temp, path); err != nil {
+		slog.Error("Replacing file failed", "path", path, "err", err)
+	}
+}
+
+// Reads an entire file as string
+func readAFileAsString(path string) string {
+	content, err := os.ReadFile(path) // Read file
+	if err != nil {
+		slog.Error("Reading file failed", "path", path, "err", err)
+	}
+	return string(content) // Return content as string
+}
+
+// Checks if a file exists
+func fileExists(filename string) bool {
+	info, err := os.Stat(filename) // Get file info
+	if err != nil {
+		return false // Doesn't exist
+	}
+	return !info.IsDir() // Return true if it's a file
+}
+
+// Checks if a directory exists
+func directoryExists(path string) bool {
+	directory, err := os.Stat(path) // Get file info
+	if err != nil {
+		return false // Doesn't exist
+	}
+	return directory.IsDir() // Return true if it's a directory
+}
+
+// Creates a directory with given permission
+func createDirectory(path string, permission os.FileMode) {
+	err := makeDirAll(path, permission) // Try to create directory and its parents
+	if err != nil {
+		slog.Error("Creating directory failed", "path", path, "err", err)
+	}
+}
+
+// Gets file extension
+func getFileExtension(path string) string {
+	return filepath.Ext(path) // Return file extension
+}
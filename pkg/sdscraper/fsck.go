@@ -0,0 +1,246 @@
+package sdscraper
+
+import ( // Import required packages
+	"cmp"           // For ordering problems
+	"encoding/json" // For reading the checkpoint
+	"fmt"           // For problem details
+	"io/fs"         // For walking the output directory
+	"log/slog"      // For the throughput report
+	"os"            // For inspecting the output directory
+	"path/filepath" // For OS-independent path operations
+	"slices"        // For stable report order
+	"strings"       // For suffix checks
+	"time"          // For file ages
+)
+
+// Kinds of inconsistency reported by Fsck
+const (
+	ProblemMissingFile      = "missing-file"      // Catalogued document has no file on disk
+	ProblemSizeMismatch     = "size-mismatch"     // File size differs from the recorded size
+	ProblemHashMismatch     = "hash-mismatch"     // File content differs from the recorded hash
+	ProblemDanglingAlias    = "dangling-alias"    // Alias points at a document that no longer exists
+	ProblemSharedFile       = "shared-file"       // Two originals claim the same file
+	ProblemOrphanFile       = "orphan-file"       // File on disk that no entry refers to
+	ProblemStalePart        = "stale-part"        // Leftover temporary download
+	ProblemBadCheckpoint    = "bad-checkpoint"    // Checkpoint file cannot be read
+	ProblemCheckpointOrphan = "checkpoint-orphan" // Checkpoint left behind without pending work
+)
+
+const (
+	stalePartAge     = time.Hour          // Younger .part files may belong to a running download
+	resumablePartAge = 7 * 24 * time.Hour // Kept transfers are worth resuming for this long
+)
+
+// Problem is one inconsistency between the manifest, the checkpoint and the output directory
+type Problem struct {
+	Kind     string `json:"kind"`          // One of the Problem* constants
+	URL      string `json:"url,omitempty"` // Catalog key involved, if any
+	Filename string `json:"filename"`      // File involved
+	Detail   string `json:"detail"`        // What is wrong
+	Fix      string `json:"fix"`           // What Repair does, or what to do by hand
+	repair   func() error
+}
+
+// Repairable reports whether Repair can fix the problem without losing data
+func (p Problem) Repairable() bool {
+	return p.repair != nil
+}
+
+// Repair applies the safe fix; manifest changes take effect once the manifest is saved
+func (p Problem) Repair() error {
+	if p.repair == nil {
+		return fmt.Errorf("%s %s: no automatic repair", p.Kind, p.Filename)
+	}
+	return p.repair()
+}
+
+// FsckOptions selects what Fsck inspects
+type FsckOptions struct {
+	OutputDir      string      // Directory holding downloaded documents
+	CheckpointPath string      // Progress file of interrupted runs, empty to skip
+	VerifyHashes   bool        // Re-hash every file instead of only comparing sizes
+	Hashing        HashOptions // Read strategy used when VerifyHashes is set
+	Trash          *Trash      // Receives the files repairs remove, nil to delete them
+}
+
+// Fsck cross-checks the manifest against the output directory and checkpoint, returning problems sorted by file
+func Fsck(m *Manifest, opts FsckOptions) []Problem {
+	var problems []Problem
+	owners := make(map[string]string) // Filename to the original that owns it
+	stats := &hashStats{}             // Hashing throughput for the audit log
+
+	for documentURL, entry := range m.Documents {
+		if entry.Filename == "" || entry.DownloadedAt.IsZero() { // Never downloaded yet
+			continue
+		}
+		if entry.AliasOf != "" {
+			if target, ok := m.Documents[entry.AliasOf]; !ok || target.Filename != entry.Filename {
+				problems = append(problems, Problem{
+					Kind: ProblemDanglingAlias, URL: documentURL, Filename: entry.Filename,
+					Detail: "alias of " + entry.AliasOf + ", which no longer owns the file",
+					Fix:    "forget the alias so the next run downloads the document itself",
+					repair: forgetDownload(entry),
+				})
+			}
+			continue
+		}
+		if other, taken := owners[entry.Filename]; taken {
+			problems = append(problems, Problem{
+				Kind: ProblemSharedFile, URL: documentURL, Filename: entry.Filename,
+				Detail: "also owned by " + other,
+				Fix:    "delete one of the entries with \"catalog delete\"",
+			})
+			continue
+		}
+		owners[entry.Filename] = documentURL
+		problems = append(problems, checkFile(documentURL, entry, opts, stats)...)
+	}
+
+	if opts.VerifyHashes {
+		slog.Info("Verified hashes", "files", stats.files.Load(), "bytes", stats.bytes.Load(),
+			"duration", time.Duration(stats.elapsed.Load()), "mib_per_second", fmt.Sprintf("%.1f", stats.throughput()))
+	}
+	problems = append(problems, checkOutputDir(m, opts.OutputDir, opts.Trash)...)
+	if opts.CheckpointPath != "" {
+		problems = append(problems, checkCheckpoint(opts.CheckpointPath, opts.Trash)...)
+	}
+
+	slices.SortFunc(problems, func(a, b Problem) int {
+		return cmp.Or(strings.Compare(a.Filename, b.Filename), strings.Compare(a.Kind, b.Kind))
+	})
+	return problems
+}
+
+// Compares one original document's file with what the manifest recorded
+func checkFile(documentURL string, entry *ManifestEntry, opts FsckOptions, stats *hashStats) []Problem {
+	path := filepath.Join(opts.OutputDir, entry.Filename)
+	redownload := "forget the recorded download so the next run fetches it again"
+	repair := forgetDownload(entry)
+	if entry.Source == SourceUpload { // Nothing upstream to fetch it from
+		redownload = "restore the file from a backup or upload it again"
+		repair = nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return []Problem{{Kind: ProblemMissingFile, URL: documentURL, Filename: entry.Filename,
+			Detail: err.Error(), Fix: redownload, repair: repair}}
+	}
+	if repair != nil { // A damaged copy must go, or its modification time would validate it again
+		redownload = "remove the damaged file so the next run fetches it again"
+		repair = discardFile(path, entry, opts.Trash)
+	}
+	if size := plainSize(path, info.Size()); entry.Size != 0 && size != entry.Size {
+		return []Problem{{Kind: ProblemSizeMismatch, URL: documentURL, Filename: entry.Filename,
+			Detail: fmt.Sprintf("%d bytes on disk, %d recorded", size, entry.Size), Fix: redownload, repair: repair}}
+	}
+	if opts.VerifyHashes && entry.SHA256 != "" {
+		sum, digests, err := opts.Hashing.hashFile(path, stats, entry.recordedHashers()...)
+		if err != nil {
+			return []Problem{{Kind: ProblemMissingFile, URL: documentURL, Filename: entry.Filename,
+				Detail: err.Error(), Fix: redownload, repair: repair}}
+		}
+		if sum != entry.SHA256 {
+			return []Problem{{Kind: ProblemHashMismatch, URL: documentURL, Filename: entry.Filename,
+				Detail: "sha256 " + sum + ", recorded " + entry.SHA256, Fix: redownload, repair: repair}}
+		}
+		for _, name := range sortedKeys(digests) { // Every recorded digest must agree, not only SHA-256
+			if digests[name] != entry.Digests[name] {
+				return []Problem{{Kind: ProblemHashMismatch, URL: documentURL, Filename: entry.Filename,
+					Detail: name + " " + digests[name] + ", recorded " + entry.Digests[name], Fix: redownload, repair: repair}}
+			}
+		}
+	}
+	return nil
+}
+
+// Finds files no entry refers to and abandoned temporary downloads
+func checkOutputDir(m *Manifest, outputDir string, trash *Trash) []Problem {
+	var files []fs.DirEntry
+	var names []string
+	filepath.WalkDir(outputDir, func(path string, file fs.DirEntry, err error) error { // Type subfolders included
+		if err == nil && !file.IsDir() {
+			relative, _ := filepath.Rel(outputDir, path)
+			files, names = append(files, file), append(names, filepath.ToSlash(relative))
+		}
+		return nil // Nothing downloaded yet is fine; missing files are reported per entry
+	})
+	var problems []Problem
+	for i, file := range files {
+		name, path := names[i], filepath.Join(outputDir, names[i])
+		if strings.HasSuffix(name, ".part.json") { // Described together with its .part file
+			continue
+		}
+		if strings.HasSuffix(name, ".part") {
+			info, err := file.Info()
+			maxAge := stalePartAge
+			if fileExists(path + ".json") { // Kept so the next run can resume it
+				maxAge = resumablePartAge
+			}
+			if err != nil || time.Since(info.ModTime()) < maxAge {
+				continue
+			}
+			problems = append(problems, Problem{
+				Kind: ProblemStalePart, Filename: name,
+				Detail: "interrupted download from " + info.ModTime().Format(time.RFC3339),
+				Fix:    "remove the temporary file",
+				repair: func() error {
+					trashOrRemove(trash, path+".json", name+".json")
+					return trashOrRemove(trash, path, name)
+				},
+			})
+			continue
+		}
+		if !m.fileInUse(name) && !m.isLocaleManifest(name) {
+			problems = append(problems, Problem{
+				Kind: ProblemOrphanFile, Filename: name,
+				Detail: "not referenced by the manifest",
+				Fix:    "remove it by hand, or restore the manifest from a backup if it should be catalogued",
+			})
+		}
+	}
+	return problems
+}
+
+// Checks that the checkpoint is readable and still describes pending work
+func checkCheckpoint(path string, trash *Trash) []Problem {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	remove := func() error { return trashOrRemove(trash, path, filepath.Base(path)) }
+	var checkpoint Checkpoint
+	if err == nil {
+		err = json.Unmarshal(data, &checkpoint)
+	}
+	if err != nil {
+		return []Problem{{Kind: ProblemBadCheckpoint, Filename: path, Detail: err.Error(),
+			Fix: "remove it; the next run starts over", repair: remove}}
+	}
+	if len(checkpoint.Pending) == 0 {
+		return []Problem{{Kind: ProblemCheckpointOrphan, Filename: path, Detail: "no pending documents",
+			Fix: "remove it", repair: remove}}
+	}
+	return nil
+}
+
+// Returns a repair that clears an entry's download state so the next run fetches it again
+func forgetDownload(entry *ManifestEntry) func() error {
+	return func() error {
+		entry.AliasOf, entry.Filename = "", "" // The next run derives the file name from the URL again
+		entry.ETag, entry.LastModified = "", ""
+		entry.Size, entry.SHA256, entry.Digests = 0, "", nil
+		entry.DownloadedAt = time.Time{}
+		return nil
+	}
+}
+
+// Returns a repair that removes a damaged file and forgets its download
+func discardFile(path string, entry *ManifestEntry, trash *Trash) func() error {
+	return func() error {
+		if err := trashOrRemove(trash, path, entry.Filename); err != nil {
+			return err
+		}
+		return forgetDownload(entry)()
+	}
+}
@@ -0,0 +1,178 @@
+package sdscraper
+
+import ( // Import required packages
+	"bufio"         // For block-sized buffered reads
+	"crypto/sha256" // For content hashes
+	"crypto/sha512" // For optional SHA-512 digests
+	"fmt"           // For hex encoding
+	"hash"          // For the hasher interface
+	"io"            // For streaming
+	"os"            // For opening files
+	"slices"        // For listing hashers
+	"strings"       // For hasher names
+	"sync/atomic"   // For throughput counters shared by callers
+	"time"          // For throughput
+
+	"lukechampine.com/blake3" // For optional BLAKE3 digests
+)
+
+const defaultHashBlockSize = 1 << 20 // 1 MiB reads keep fast disks busy without a large footprint
+
+// HashOptions tunes how files are read while hashing
+type HashOptions struct {
+	BlockSize int  // Read size in bytes, zero for 1 MiB
+	MMap      bool // Map files into memory instead of reading them, where the platform supports it
+}
+
+// Counts bytes and time spent hashing so audits can report throughput
+type hashStats struct {
+	files, bytes atomic.Int64
+	elapsed      atomic.Int64 // Nanoseconds
+}
+
+// Throughput in MiB per second over everything hashed so far
+func (h *hashStats) throughput() float64 {
+	seconds := time.Duration(h.elapsed.Load()).Seconds()
+	if seconds == 0 {
+		return 0
+	}
+	return float64(h.bytes.Load()) / (1 << 20) / seconds
+}
+
+// Hasher is a content digest algorithm; every document gets a SHA-256, the one aliases and verification go by, and
+// the manifest records any further digests by Name
+type Hasher interface {
+	Name() string   // Key in a manifest entry's digests, e.g. "sha512"
+	New() hash.Hash // A fresh hash
+}
+
+// A Hasher from a name and constructor
+type namedHasher struct {
+	name string
+	new  func() hash.Hash
+}
+
+// Name implements Hasher
+func (h namedHasher) Name() string { return h.name }
+
+// New implements Hasher
+func (h namedHasher) New() hash.Hash { return h.new() }
+
+// Built-in hashers by name
+var hashers = map[string]Hasher{
+	"sha256": namedHasher{"sha256", sha256.New},
+	"sha512": namedHasher{"sha512", sha512.New},
+	"blake3": namedHasher{"blake3", func() hash.Hash { return blake3.New(32, nil) }},
+}
+
+// The digest every document gets
+var defaultHasher = hashers["sha256"]
+
+// LookupHasher returns the hasher named name, e.g. "sha512" or "blake3"
+func LookupHasher(name string) (Hasher, error) {
+	if hasher, ok := hashers[strings.ToLower(name)]; ok {
+		return hasher, nil
+	}
+	return nil, fmt.Errorf("unknown hash algorithm %q (want one of %s)", name, strings.Join(HasherNames(), ", "))
+}
+
+// HasherNames lists the built-in hashers
+func HasherNames() []string {
+	names := make([]string, 0, len(hashers))
+	for name := range hashers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Returns the registered hashers of the entry's recorded digests, so they can be checked; unknown names are skipped
+func (e *ManifestEntry) recordedHashers() []Hasher {
+	var recorded []Hasher
+	for _, name := range sortedKeys(e.Digests) {
+		if hasher, ok := hashers[name]; ok {
+			recorded = append(recorded, hasher)
+		}
+	}
+	return recorded
+}
+
+// Feeds one stream to several hashes at once
+type digester struct {
+	hashers []Hasher
+	hashes  []hash.Hash
+	io.Writer
+}
+
+// Returns a digester for SHA-256 and every hasher in extra
+func newDigester(extra []Hasher) *digester {
+	d := &digester{hashers: []Hasher{defaultHasher}}
+	for _, hasher := range extra {
+		if hasher.Name() != defaultHasher.Name() {
+			d.hashers = append(d.hashers, hasher)
+		}
+	}
+	writers := make([]io.Writer, len(d.hashers))
+	for i, hasher := range d.hashers {
+		d.hashes = append(d.hashes, hasher.New())
+		writers[i] = d.hashes[i]
+	}
+	d.Writer = io.MultiWriter(writers...)
+	return d
+}
+
+// Returns the hex SHA-256 written so far
+func (d *digester) sha256() string {
+	return fmt.Sprintf("%x", d.hashes[0].Sum(nil))
+}
+
+// Returns the hex digests besides SHA-256 written so far by hasher name, nil for none
+func (d *digester) extra() map[string]string {
+	if len(d.hashers) == 1 {
+		return nil
+	}
+	sums := make(map[string]string, len(d.hashers)-1)
+	for i, hasher := range d.hashers[1:] {
+		sums[hasher.Name()] = fmt.Sprintf("%x", d.hashes[i+1].Sum(nil))
+	}
+	return sums
+}
+
+// Returns the hex SHA-256 of a file using the configured read strategy, and the digests of the extra hashers
+func (o HashOptions) hashFile(path string, stats *hashStats, extra ...Hasher) (string, map[string]string, error) {
+	started := time.Now()
+	file, err := os.Open(path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer file.Close()
+
+	digests := newDigester(extra)
+	var size int64
+	sealed := sealedOnDisk(path) || EncryptionEnabled() // Hashes are of the plaintext; openStored refuses plaintext files while encryption is on
+	if o.MMap && !sealed {
+		size, err = hashMapped(file, digests)
+	}
+	if sealed {
+		var plain io.ReadSeekCloser
+		if plain, err = openStored(path); err == nil {
+			size, err = io.Copy(digests, plain)
+			plain.Close()
+		}
+	} else if !o.MMap || err == errMMapUnsupported { // Fall back to buffered reads
+		blockSize := o.BlockSize
+		if blockSize <= 0 {
+			blockSize = defaultHashBlockSize
+		}
+		size, err = io.Copy(digests, bufio.NewReaderSize(file, blockSize))
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if stats != nil {
+		stats.files.Add(1)
+		stats.bytes.Add(size)
+		stats.elapsed.Add(int64(time.Since(started)))
+	}
+	return digests.sha256(), digests.extra(), nil
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package sdscraper
+
+import ( // Import required packages
+	"errors" // For the unsupported sentinel
+	"io"     // For the hash being fed
+	"os"     // For the file
+)
+
+var errMMapUnsupported = errors.New("mmap unsupported") // Buffered reads are used instead
+
+// Reports that memory mapping is unavailable on this platform
+func hashMapped(*os.File, io.Writer) (int64, error) {
+	return 0, errMMapUnsupported
+}
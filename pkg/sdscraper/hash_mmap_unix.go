@@ -0,0 +1,31 @@
+//go:build unix
+
+package sdscraper
+
+import ( // Import required packages
+	"errors"  // For the unsupported sentinel
+	"io"      // For the hash being fed
+	"os"      // For file info
+	"syscall" // For mmap
+)
+
+var errMMapUnsupported = errors.New("mmap unsupported") // Never returned on unix except for empty files
+
+// Hashes a file by mapping it read-only into memory
+func hashMapped(file *os.File, h io.Writer) (int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := info.Size()
+	if size == 0 { // Zero-length mappings are rejected
+		return 0, errMMapUnsupported
+	}
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return 0, errMMapUnsupported // e.g. files on filesystems that cannot be mapped
+	}
+	defer syscall.Munmap(data)
+	h.Write(data)
+	return size, nil
+}
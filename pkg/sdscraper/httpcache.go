@@ -0,0 +1,243 @@
+package sdscraper
+
+import ( // Import required packages
+	"bytes"         // For replaying cached bodies
+	"crypto/sha256" // For cache file names
+	"encoding/json" // For cache entries on disk
+	"fmt"           // For hex digests
+	"io"            // For reading response bodies
+	"log/slog"      // For structured logging
+	"net/http"      // For the transport
+	"os"            // For the cache directory
+	"path/filepath" // For OS-independent path operations
+	"strconv"       // For max-age and Age values
+	"strings"       // For parsing Cache-Control
+	"time"          // For freshness
+)
+
+const maxCachedBody = 16 << 20 // Larger responses are passed through uncached; pages and endpoint payloads are far smaller
+
+// CacheTransport is a private HTTP cache on disk for page fetches, such as listings, listing endpoints and product
+// pages: GET responses are kept for as long as Cache-Control max-age or Expires allows and answered from disk while
+// fresh; stale ones are revalidated with their ETag or Last-Modified, so an unchanged page costs a 304
+type CacheTransport struct {
+	Base http.RoundTripper // Underlying transport, nil for http.DefaultTransport
+	Dir  string            // Directory holding one file per cached URL
+}
+
+// One cached response as stored on disk
+type cacheEntry struct {
+	URL          string            `json:"url"`
+	StatusCode   int               `json:"status_code"`
+	Status       string            `json:"status"`
+	Header       http.Header       `json:"header"`
+	Vary         map[string]string `json:"vary,omitempty"` // Request header values the response was chosen by
+	RequestTime  time.Time         `json:"request_time"`   // When the request that produced it was sent
+	ResponseTime time.Time         `json:"response_time"`  // When it arrived or was last revalidated
+	Body         []byte            `json:"body"`
+}
+
+// RoundTrip answers from the cache when it can and stores what it may
+func (t *CacheTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.Method != http.MethodGet && request.Method != http.MethodHead {
+		response, err := t.base().RoundTrip(request)
+		if err == nil && response.StatusCode < 400 { // Unsafe methods invalidate what the cache holds for the URL
+			removeFile(t.path(request.URL.String()))
+		}
+		return response, err
+	}
+	requestDirectives := cacheControl(request.Header)
+	_, noStore := requestDirectives["no-store"]
+	if request.Method != http.MethodGet || noStore || request.Header.Get("Range") != "" ||
+		request.Header.Get("If-None-Match") != "" || request.Header.Get("If-Modified-Since") != "" { // The caller validates itself
+		return t.base().RoundTrip(request)
+	}
+
+	entry := t.load(request)
+	_, noCache := requestDirectives["no-cache"]
+	if entry != nil && !noCache && entry.age(time.Now()) < entry.freshness() {
+		slog.Debug("HTTP cache hit", "url", entry.URL)
+		return entry.response(request), nil
+	}
+
+	outgoing := request
+	if entry != nil { // Stale, or the caller insists on revalidation
+		outgoing = request.Clone(request.Context())
+		if etag := entry.Header.Get("ETag"); etag != "" {
+			outgoing.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := entry.Header.Get("Last-Modified"); lastModified != "" {
+			outgoing.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+	requestTime := time.Now()
+	response, err := t.base().RoundTrip(outgoing)
+	if err != nil {
+		return nil, err
+	}
+	if entry != nil && response.StatusCode == http.StatusNotModified {
+		response.Body.Close()
+		for name, values := range response.Header { // The 304 carries the updated freshness
+			entry.Header[name] = values
+		}
+		entry.RequestTime, entry.ResponseTime = requestTime, time.Now()
+		t.save(entry)
+		slog.Debug("HTTP cache revalidated", "url", entry.URL)
+		return entry.response(request), nil
+	}
+	if !storable(response) {
+		return response, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxCachedBody+1))
+	if err != nil {
+		response.Body.Close()
+		return nil, err
+	}
+	if len(body) > maxCachedBody { // Hand back the whole body without caching it
+		response.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), response.Body), response.Body}
+		return response, nil
+	}
+	response.Body.Close()
+	response.Body = io.NopCloser(bytes.NewReader(body))
+	t.save(&cacheEntry{
+		URL: request.URL.String(), StatusCode: response.StatusCode, Status: response.Status, Header: response.Header.Clone(),
+		Vary: varyValues(response.Header, request.Header), RequestTime: requestTime, ResponseTime: time.Now(), Body: body,
+	})
+	return response, nil
+}
+
+// Returns the configured base transport or the default one
+func (t *CacheTransport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// Returns the cache file of a URL
+func (t *CacheTransport) path(rawURL string) string {
+	return filepath.Join(t.Dir, fmt.Sprintf("%x.json", sha256.Sum256([]byte(rawURL))))
+}
+
+// Loads the cached response to request, nil when there is none or it was chosen by other request headers
+func (t *CacheTransport) load(request *http.Request) *cacheEntry {
+	data, err := os.ReadFile(t.path(request.URL.String()))
+	if err != nil {
+		return nil
+	}
+	var entry cacheEntry
+	if json.Unmarshal(data, &entry) != nil || entry.URL != request.URL.String() {
+		return nil
+	}
+	for name, value := range entry.Vary {
+		if request.Header.Get(name) != value {
+			return nil
+		}
+	}
+	return &entry
+}
+
+// Writes an entry; failures only cost a future cache miss
+func (t *CacheTransport) save(entry *cacheEntry) {
+	data, err := json.Marshal(entry)
+	if err == nil {
+		err = makeDirAll(t.Dir, 0o755)
+	}
+	if err == nil {
+		err = writePartThenRename(t.path(entry.URL), bytes.NewReader(data))
+	}
+	if err != nil {
+		slog.Warn("Writing the HTTP cache failed", "url", entry.URL, "err", err)
+	}
+}
+
+// Reports whether a response may be kept: a complete 200 that permits storing and could be reused or revalidated
+func storable(response *http.Response) bool {
+	directives := cacheControl(response.Header)
+	if _, ok := directives["no-store"]; ok || response.StatusCode != http.StatusOK || response.Header.Get("Vary") == "*" {
+		return false
+	}
+	_, maxAge := directives["max-age"]
+	return maxAge || response.Header.Get("Expires") != "" || response.Header.Get("ETag") != "" || response.Header.Get("Last-Modified") != ""
+}
+
+// Returns how long the response stays fresh after it was generated (RFC 9111 section 4.2.1), zero when it must be revalidated
+func (e *cacheEntry) freshness() time.Duration {
+	directives := cacheControl(e.Header)
+	if _, ok := directives["no-cache"]; ok {
+		return 0
+	}
+	if value, ok := directives["max-age"]; ok {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if value := e.Header.Get("Expires"); value != "" {
+		expires, err := http.ParseTime(value)
+		if err != nil { // Invalid dates, such as "0", mean already expired
+			return 0
+		}
+		return expires.Sub(e.date())
+	}
+	return 0 // No heuristic freshness; validators still save the body transfer
+}
+
+// Returns the response's age at now (RFC 9111 section 4.2.3)
+func (e *cacheEntry) age(now time.Time) time.Duration {
+	apparent := max(0, e.ResponseTime.Sub(e.date()))
+	corrected := time.Duration(0)
+	if seconds, err := strconv.Atoi(e.Header.Get("Age")); err == nil {
+		corrected = time.Duration(seconds)*time.Second + e.ResponseTime.Sub(e.RequestTime)
+	}
+	return max(apparent, corrected) + now.Sub(e.ResponseTime)
+}
+
+// Returns the response's Date, or when it arrived if the server sent none
+func (e *cacheEntry) date() time.Time {
+	if date, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		return date
+	}
+	return e.ResponseTime
+}
+
+// Builds the response a cached entry replays
+func (e *cacheEntry) response(request *http.Request) *http.Response {
+	header := e.Header.Clone()
+	header.Set("Age", strconv.Itoa(int(e.age(time.Now()).Seconds())))
+	return &http.Response{
+		Status: e.Status, StatusCode: e.StatusCode, Proto: "HTTP/1.1", ProtoMajor: 1, ProtoMinor: 1,
+		Header: header, Body: io.NopCloser(bytes.NewReader(e.Body)), ContentLength: int64(len(e.Body)), Request: request,
+	}
+}
+
+// Parses Cache-Control directives, lowercased, with their values unquoted
+func cacheControl(header http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, line := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(line, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name != "" {
+				directives[strings.ToLower(name)] = strings.Trim(value, `"`)
+			}
+		}
+	}
+	return directives
+}
+
+// Returns the request header values named by the response's Vary header
+func varyValues(response, request http.Header) map[string]string {
+	values := make(map[string]string)
+	for _, line := range response.Values("Vary") {
+		for _, name := range strings.Split(line, ",") {
+			if name = http.CanonicalHeaderKey(strings.TrimSpace(name)); name != "" {
+				values[name] = request.Get(name)
+			}
+		}
+	}
+	return values
+}
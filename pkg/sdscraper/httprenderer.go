@@ -0,0 +1,127 @@
+package sdscraper
+
+import ( // Import required packages
+	"context"  // For request cancellation
+	"errors"   // For combining failures
+	"fmt"      // For error messages
+	"io"       // For reading response bodies
+	"log/slog" // For structured logging
+	"net/http" // For HTTP client
+	"strings"  // For string manipulation
+	"time"     // For timeouts
+)
+
+// HTTPRenderer fetches pages with a plain HTTP client, without executing JavaScript
+type HTTPRenderer struct {
+	Client           *http.Client // HTTP client, nil for a 30 second default
+	ListingEndpoints []string     // JSON/XHR endpoints behind the listing page, appended to its HTML
+}
+
+// Render returns the raw page HTML followed by the bodies of any configured listing endpoints
+func (h *HTTPRenderer) Render(ctx context.Context, pageURL string) (string, error) {
+	slog.Info("Fetching listing", "url", pageURL) // Log page being fetched
+
+	page, err := h.fetch(ctx, pageURL) // The page as served, before any scripts run
+	if err != nil {
+		return "", err
+	}
+
+	var content strings.Builder // Page followed by endpoint payloads
+	content.WriteString(page)
+	for _, endpoint := range h.ListingEndpoints {
+		payload, err := h.fetch(ctx, endpoint) // Same data the page's scripts would request
+		if err != nil {
+			slog.Warn("Fetching listing endpoint failed", "url", endpoint, "err", err)
+			continue
+		}
+		content.WriteString("\n")
+		content.WriteString(strings.ReplaceAll(payload, `\/`, `/`)) // JSON may escape slashes in URLs
+	}
+	return content.String(), nil
+}
+
+// Performs one GET and returns the body as a string
+func (h *HTTPRenderer) fetch(ctx context.Context, rawURL string) (string, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil) // Build GET request
+	if err != nil {
+		return "", err
+	}
+
+	client := h.Client // Default matches the downloader
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	resp, err := client.Do(request) // Make GET request
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close() // Ensure response body is closed
+
+	body, err := io.ReadAll(resp.Body) // Listing pages are small enough to hold in memory
+	if err != nil {
+		return "", err
+	}
+	if kind := detectChallenge(string(body)); kind != "" && !strings.Contains(resp.Header.Get("Content-Type"), "json") { // Bot checks answer 403 or 503 too
+		return "", &ChallengeError{Challenge{URL: rawURL, Kind: kind, DetectedAt: time.Now().UTC()}}
+	}
+	if resp.StatusCode != http.StatusOK { // Check for 200 OK
+		return "", fmt.Errorf("fetch %s: %s", rawURL, resp.Status)
+	}
+	return string(body), nil
+}
+
+// StaticRenderer answers with fixed HTML per page URL, e.g. a saved listing, so a Scraper runs without a network or
+// browser; unknown URLs fail like unreachable pages
+type StaticRenderer map[string]string
+
+// Render implements Renderer
+func (r StaticRenderer) Render(ctx context.Context, pageURL string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	content, ok := r[pageURL]
+	if !ok {
+		return "", fmt.Errorf("fetch %s: no static page", pageURL)
+	}
+	return content, nil
+}
+
+// FallbackRenderer tries a cheap renderer first and only uses the next one when no document links were found
+type FallbackRenderer struct {
+	Renderers []Renderer // Tried in order, typically HTTP then Chrome
+}
+
+// Render returns the first rendering that contains document links, or the last successful one
+func (f *FallbackRenderer) Render(ctx context.Context, pageURL string) (string, error) {
+	var errs []error      // Failures from every renderer tried
+	var lastResult string // Best effort output when nothing had links
+	rendered := false
+	for _, renderer := range f.Renderers {
+		content, err := renderer.Render(ctx, pageURL)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if len(ExtractPDFLinks(content)) > 0 { // Good enough, no JavaScript needed
+			return content, nil
+		}
+		slog.Info("No document links found, trying next renderer", "renderer", fmt.Sprintf("%T", renderer))
+		lastResult, rendered = content, true
+	}
+	if rendered {
+		return lastResult, nil
+	}
+	return "", errors.Join(errs...)
+}
+
+// Close releases every renderer that holds resources, such as a running browser
+func (f *FallbackRenderer) Close() error {
+	var errs []error
+	for _, renderer := range f.Renderers {
+		if closer, ok := renderer.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,133 @@
+package sdscraper
+
+import ( // Import required packages
+	"context"  // For chromedp actions
+	"log/slog" // For structured logging
+	"strconv"  // For quoting selectors into scripts
+	"time"     // For settle delays
+
+	"github.com/chromedp/chromedp" // For driving the page
+)
+
+// Interaction scripts how a listing page is driven before its HTML is captured
+type Interaction struct {
+	WaitSelector     string        // Element that must be visible before anything else happens
+	MaxScrolls       int           // Scroll-to-bottom attempts while the page keeps growing
+	LoadMoreSelector string        // "Load more" button clicked until it disappears
+	NextPageSelector string        // Pagination control; the HTML of every page is captured
+	MaxPages         int           // Upper bound on load-more clicks and pagination steps, zero for 20
+	Settle           time.Duration // Pause after each step for new content to arrive, zero for 1.5 seconds
+}
+
+// Returns a chromedp action that runs the interaction and appends each captured page to pages
+func (in Interaction) actions(pages *[]string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if in.WaitSelector != "" { // Wait for the listing to be rendered at all
+			if err := chromedp.WaitVisible(in.WaitSelector, chromedp.ByQuery).Do(ctx); err != nil {
+				return err
+			}
+		}
+
+		for page := 1; ; page++ {
+			if err := in.scrollToEnd(ctx); err != nil { // Trigger lazy loading
+				return err
+			}
+			if err := in.clickLoadMore(ctx); err != nil { // Expand in-place listings
+				return err
+			}
+
+			var pageHTML string
+			if err := chromedp.OuterHTML("html", &pageHTML).Do(ctx); err != nil { // Capture this page
+				return err
+			}
+			*pages = append(*pages, pageHTML)
+
+			if in.NextPageSelector == "" || page >= in.maxPages() {
+				return nil
+			}
+			clicked, err := in.click(ctx, in.NextPageSelector) // Move to the next page
+			if err != nil || !clicked {
+				return err // Last page reached when nothing was clicked
+			}
+			slog.Debug("Moved to next listing page", "page", page+1)
+		}
+	})
+}
+
+// Scrolls to the bottom until the page stops growing or MaxScrolls is reached
+func (in Interaction) scrollToEnd(ctx context.Context) error {
+	var previousHeight int
+	for scroll := 0; scroll < in.MaxScrolls; scroll++ {
+		var height int
+		if err := chromedp.Evaluate(`window.scrollTo(0, document.body.scrollHeight); document.body.scrollHeight`, &height).Do(ctx); err != nil {
+			return err
+		}
+		if height == previousHeight { // Nothing new was loaded
+			return nil
+		}
+		previousHeight = height
+		if err := in.wait(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Clicks the "Load more" button until it disappears or MaxPages is reached
+func (in Interaction) clickLoadMore(ctx context.Context) error {
+	if in.LoadMoreSelector == "" {
+		return nil
+	}
+	for clicks := 0; clicks < in.maxPages(); clicks++ {
+		clicked, err := in.click(ctx, in.LoadMoreSelector)
+		if err != nil || !clicked {
+			return err
+		}
+		if err := in.scrollToEnd(ctx); err != nil { // New rows may be lazily loaded too
+			return err
+		}
+	}
+	slog.Warn("Stopped clicking load more at the page limit", "selector", in.LoadMoreSelector, "clicks", in.maxPages())
+	return nil
+}
+
+// Clicks the first enabled element matching selector, reporting whether one was found
+func (in Interaction) click(ctx context.Context, selector string) (bool, error) {
+	script := `(() => {
+		const el = document.querySelector(` + strconv.Quote(selector) + `);
+		if (!el || el.disabled || el.getAttribute("aria-disabled") === "true") return false;
+		el.scrollIntoView();
+		el.click();
+		return true;
+	})()`
+	var clicked bool
+	if err := chromedp.Evaluate(script, &clicked).Do(ctx); err != nil {
+		return false, err
+	}
+	if clicked {
+		return true, in.wait(ctx)
+	}
+	return false, nil
+}
+
+// Pauses for the settle delay, respecting cancellation
+func (in Interaction) wait(ctx context.Context) error {
+	settle := in.Settle
+	if settle <= 0 {
+		settle = 1500 * time.Millisecond
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(settle):
+		return nil
+	}
+}
+
+// Returns the page limit, defaulting to 20
+func (in Interaction) maxPages() int {
+	if in.MaxPages <= 0 {
+		return 20
+	}
+	return in.MaxPages
+}
@@ -0,0 +1,133 @@
+package sdscraper
+
+import ( // Import required packages
+	"errors"  // For limit errors
+	"fmt"     // For error messages
+	"io"      // For streaming bodies
+	"os"      // For spool files
+	"strconv" // For parsing sizes
+	"strings" // For parsing units
+)
+
+// DefaultMaxFileSize bounds a single download unless Downloader.MaxFileSize says otherwise; SDS PDFs are a few MiB
+const DefaultMaxFileSize = 512 << 20
+
+var (
+	ErrTooLarge      = errors.New("document exceeds the size limit")     // Content-Length or the streamed body is over MaxFileSize
+	ErrQuotaExceeded = errors.New("the run's download quota is used up") // MaxRunBytes were transferred already
+)
+
+// Multipliers of the units ParseByteSize accepts
+var byteUnits = map[string]int64{
+	"": 1, "b": 1,
+	"kb": 1e3, "mb": 1e6, "gb": 1e9, "tb": 1e12,
+	"kib": 1 << 10, "mib": 1 << 20, "gib": 1 << 30, "tib": 1 << 40,
+	"k": 1 << 10, "m": 1 << 20, "g": 1 << 30, "t": 1 << 40,
+}
+
+// ParseByteSize reads a size such as "512MiB", "2GB" or "1048576"; bare K, M, G and T are binary units
+func ParseByteSize(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	split := strings.IndexFunc(value, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if split < 0 {
+		split = len(value)
+	}
+	number, err := strconv.ParseFloat(value[:split], 64)
+	unit, ok := byteUnits[strings.ToLower(strings.TrimSpace(value[split:]))]
+	if err != nil || !ok || number < 0 {
+		return 0, fmt.Errorf("invalid size %q (want e.g. 512MiB, 2GB or a byte count)", value)
+	}
+	return int64(number * float64(unit)), nil
+}
+
+// Returns the per-file limit in bytes, or zero for none
+func (d *Downloader) fileLimit() int64 {
+	switch {
+	case d.MaxFileSize < 0:
+		return 0
+	case d.MaxFileSize == 0:
+		return DefaultMaxFileSize
+	}
+	return d.MaxFileSize
+}
+
+// Starts counting a new run against MaxRunBytes
+func (d *Downloader) resetQuota() {
+	d.runBytes.Store(0)
+}
+
+// Reports whether a transfer of size bytes (negative when unknown) still fits the file limit and the run's quota
+func (d *Downloader) admitSize(size int64) error {
+	if limit := d.fileLimit(); limit > 0 && size > limit {
+		return fmt.Errorf("%w: %d bytes, limit %d", ErrTooLarge, size, limit)
+	}
+	if d.MaxRunBytes <= 0 {
+		return nil
+	}
+	used := d.runBytes.Load()
+	if used >= d.MaxRunBytes || (size > 0 && used+size > d.MaxRunBytes) {
+		return fmt.Errorf("%w (%d of %d bytes)", ErrQuotaExceeded, used, d.MaxRunBytes)
+	}
+	return nil
+}
+
+// Counts transferred bytes against the run's quota, failing the read once it is used up
+type quotaReader struct {
+	reader     io.Reader
+	downloader *Downloader
+}
+
+// Read implements io.Reader
+func (r quotaReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if used := r.downloader.runBytes.Add(int64(n)); r.downloader.MaxRunBytes > 0 && used > r.downloader.MaxRunBytes {
+		return n, fmt.Errorf("%w (%d bytes)", ErrQuotaExceeded, r.downloader.MaxRunBytes)
+	}
+	return n, err
+}
+
+// A transferred body kept in a temporary file, hashed as it is written, until it is validated and stored
+type spool struct {
+	file    *os.File
+	digests *digester
+	size    int64
+}
+
+// Creates an empty spool file in SpoolDir
+func (d *Downloader) newSpool() (*spool, error) {
+	dir := d.SpoolDir
+	if dir == "" {
+		dir = os.TempDir()
+	} else if err := makeDirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	file, err := createTemp(dir, "gojo-download-*")
+	if err != nil {
+		return nil, fmt.Errorf("create spool file: %w", err)
+	}
+	return &spool{file: file, digests: newDigester(d.Digests)}, nil
+}
+
+// Appends everything r yields
+func (s *spool) copyFrom(r io.Reader) error {
+	n, err := io.Copy(io.MultiWriter(s.file, s.digests), r)
+	s.size += n
+	return err
+}
+
+// Returns the hex SHA-256 of what was written
+func (s *spool) sum() string {
+	return s.digests.sha256()
+}
+
+// Returns the content from its start; the spool must not be written afterwards
+func (s *spool) reader() *os.File {
+	s.file.Seek(0, io.SeekStart)
+	return s.file
+}
+
+// Closes and deletes the spool file
+func (s *spool) discard() {
+	s.file.Close()
+	removeFile(s.file.Name())
+}
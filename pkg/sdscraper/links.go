@@ -0,0 +1,117 @@
+package sdscraper
+
+import ( // Import required packages
+	"html"     // For decoding attribute values
+	"log/slog" // For structured logging
+	"net/url"  // For URL parsing and manipulation
+	"regexp"   // For regular expressions
+	"strings"  // For string manipulation
+)
+
+var pdfRegex = regexp.MustCompile(`https?://[^\s"'<>]+?\.pdf(?:\?[^\s"'<>]*)?`) // Regex for PDF URLs
+
+// ExtractPDFLinks returns every distinct PDF URL in the given HTML content, in order of appearance
+func ExtractPDFLinks(htmlContent string) []string {
+	seen := make(map[string]struct{}) // To keep track of seen URLs
+	var links []string                // Slice to store unique URLs
+
+	for _, line := range strings.Split(htmlContent, "\n") { // Process line by line
+		for _, match := range pdfRegex.FindAllString(line, -1) { // Find all matches
+			if _, ok := seen[match]; !ok { // If not already seen
+				seen[match] = struct{}{}     // Mark as seen
+				links = append(links, match) // Add to list
+			}
+		}
+	}
+
+	return links // Return list of PDF URLs
+}
+
+var (
+	attributeURLRegex = regexp.MustCompile(`(?i)\b(?:href|src|data-href|data-url)\s*=\s*["']([^"']+)["']`) // Link-bearing attributes
+	absoluteURLRegex  = regexp.MustCompile(`https?://[^\s"'<>\\]+`)                                        // URLs in scripts and JSON
+)
+
+// ExtractLinks returns every distinct URL in content that matches pattern, resolving relative links against pageURL;
+// unlike ExtractPDFLinks it finds documents whose links are relative or do not end in .pdf
+func ExtractLinks(content, pageURL string, pattern *regexp.Regexp) []string {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+	var candidates []string
+	for _, match := range attributeURLRegex.FindAllStringSubmatch(content, -1) {
+		candidates = append(candidates, match[1])
+	}
+	candidates = append(candidates, absoluteURLRegex.FindAllString(content, -1)...)
+
+	seen := make(map[string]bool)
+	var links []string
+	for _, candidate := range candidates {
+		if link, ok := resolveLink(base, candidate, pattern); ok && !seen[link] {
+			seen[link] = true
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// Resolves a link as written in markup against the page's URL, reporting whether it is an HTTP(S) URL matching pattern
+func resolveLink(base *url.URL, raw string, pattern *regexp.Regexp) (string, bool) {
+	reference, err := url.Parse(html.UnescapeString(strings.TrimSpace(raw)))
+	if err != nil {
+		return "", false
+	}
+	resolved := base.ResolveReference(reference)
+	resolved.Fragment = "" // Same document
+	link := resolved.String()
+	return link, (resolved.Scheme == "http" || resolved.Scheme == "https") && pattern.MatchString(link)
+}
+
+// URLToFilename converts a URL to a filesystem-safe file name
+func URLToFilename(rawURL string) string {
+	parsed, err := url.Parse(rawURL) // Parse the URL
+	if err != nil {
+		slog.Warn("Unparseable document URL", "url", rawURL, "err", err)
+		return ""
+	}
+	filename := parsed.Host // Start with host
+	if parsed.Path != "" {
+		filename += "_" + strings.ReplaceAll(parsed.Path, "/", "_") // Add path
+	}
+	if parsed.RawQuery != "" {
+		filename += "_" + strings.ReplaceAll(parsed.RawQuery, "&", "_") // Add query
+	}
+	return sanitizeFilename(filename)
+}
+
+// Replaces characters that are unsafe in file names, forces a .pdf extension and lowercases the result
+func sanitizeFilename(filename string) string {
+	invalidChars := []string{`"`, `\`, `/`, `:`, `*`, `?`, `<`, `>`, `|`, `-`} // Invalid filename characters
+	for _, char := range invalidChars {
+		filename = strings.ReplaceAll(filename, char, "_") // Replace with underscore
+	}
+	if getFileExtension(filename) != ".pdf" { // Ensure .pdf extension
+		filename += ".pdf"
+	}
+	return strings.ToLower(filename) // Return lowercased name
+}
+
+// Checks if a URL is valid
+func isUrlValid(uri string) bool {
+	_, err := url.ParseRequestURI(uri) // Try to parse URI
+	return err == nil                  // True if parsing succeeded
+}
+
+// Removes duplicate entries from a string slice
+func removeDuplicatesFromSlice(slice []string) []string {
+	check := make(map[string]bool) // Map to track seen strings
+	var newReturnSlice []string    // Slice to store unique values
+	for _, content := range slice {
+		if !check[content] {
+			check[content] = true
+			newReturnSlice = append(newReturnSlice, content)
+		}
+	}
+	return newReturnSlice
+}
@@ -0,0 +1,43 @@
+package sdscraper
+
+import ( // Import required packages
+	"regexp"  // For link patterns
+	"slices"  // For comparing link lists
+	"testing" // For the test harness
+)
+
+func TestExtractPDFLinks(t *testing.T) {
+	content := `<a href="https://www.gojo.com/sds/gel.pdf">Gel</a> <a href="https://www.gojo.com/sds/foam.pdf?v=2">Foam</a>
+<a href="https://www.gojo.com/sds/gel.pdf">Gel again</a> <a href="/sds/relative.pdf">Relative</a> <img src="https://www.gojo.com/logo.png">`
+	want := []string{"https://www.gojo.com/sds/gel.pdf", "https://www.gojo.com/sds/foam.pdf?v=2"} // Distinct, in order, absolute only
+	if got := ExtractPDFLinks(content); !slices.Equal(got, want) {
+		t.Errorf("ExtractPDFLinks = %q, want %q", got, want)
+	}
+}
+
+func TestExtractLinks(t *testing.T) {
+	content := `<a href="/sds/gel.pdf">Gel</a> <a href='../docs/foam.pdf#page=2'>Foam</a> <a data-url="download?id=7">Hand Medic</a>
+<a href="mailto:sds@gojo.com">Mail</a> <script>load("https://cdn.gojo.com/sds/gel.pdf")</script> <a href="/sds/gel.pdf">Gel again</a>`
+	pattern := regexp.MustCompile(`\.pdf$|/download\?id=\d+$`)
+	want := []string{
+		"https://www.gojo.com/sds/gel.pdf",      // Root-relative
+		"https://www.gojo.com/docs/foam.pdf",    // Parent-relative, fragment dropped
+		"https://www.gojo.com/en/download?id=7", // No extension, matched by the pattern
+		"https://cdn.gojo.com/sds/gel.pdf",      // Absolute in a script
+	}
+	if got := ExtractLinks(content, "https://www.gojo.com/en/SDS", pattern); !slices.Equal(got, want) {
+		t.Errorf("ExtractLinks = %q, want %q", got, want)
+	}
+}
+
+func TestURLToFilename(t *testing.T) {
+	for _, test := range []struct{ url, want string }{
+		{"https://www.gojo.com/SDS/Gel-12.pdf", "www.gojo.com__sds_gel_12.pdf"},
+		{"https://www.gojo.com/download?id=7&lang=en", "www.gojo.com__download_id=7_lang=en.pdf"}, // Query kept, .pdf forced
+		{"https://example.com", "example.com.pdf"},
+	} {
+		if got := URLToFilename(test.url); got != test.want {
+			t.Errorf("URLToFilename(%q) = %q, want %q", test.url, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package sdscraper
+
+import ( // Import required packages
+	"bufio"    // For reading the header line
+	"log/slog" // For structured logging
+	"os"       // For file info
+	"strings"  // For parsing the header
+	"time"     // For fetch timestamps
+)
+
+const listingHeaderPrefix = "<!-- sdscraper fetched=" // First line of every cached listing
+
+// Writes a rendered listing to its cache file, prefixed with a comment recording when and where it was fetched
+func writeListingCache(path, pageURL, html string) {
+	header := listingHeaderPrefix + time.Now().UTC().Format(time.RFC3339) + " url=" + pageURL + " -->\n"
+	writeToFile(path, header+html+"\n")
+}
+
+// Returns when a cached listing was fetched, from its header or, for caches written before it existed, the file time
+func listingFetchedAt(path string) (time.Time, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer file.Close()
+
+	line, _ := bufio.NewReader(file).ReadString('\n')
+	if rest, ok := strings.CutPrefix(line, listingHeaderPrefix); ok {
+		stamp, _, _ := strings.Cut(rest, " ")
+		if fetched, err := time.Parse(time.RFC3339, stamp); err == nil {
+			return fetched, true
+		}
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return info.ModTime(), true
+}
+
+// Reports whether a cached listing can be used instead of rendering the page again
+func (s *Scraper) listingFresh(cacheFile string) bool {
+	fetched, ok := listingFetchedAt(cacheFile)
+	if !ok || s.ForceRefresh {
+		return false
+	}
+	if s.CacheTTL > 0 && time.Since(fetched) > s.CacheTTL {
+		slog.Info("Cached listing expired", "path", cacheFile, "fetched_at", fetched, "ttl", s.CacheTTL)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+package sdscraper
+
+import ( // Import required packages
+	"fmt"           // For error wrapping
+	"maps"          // For collecting locales
+	"path/filepath" // For cross-reference paths
+	"slices"        // For sorted locales
+	"strings"       // For the path template
+)
+
+// LocaleManifest returns the part of a merged manifest listed under one locale: its documents, the documents they
+// alias and the changes to them. Entries keep every locale they are listed under, so a document shared between
+// locales points at the other locales' manifests through the merged one.
+func LocaleManifest(global *Manifest, locale string) *Manifest {
+	local := &Manifest{Documents: make(map[string]*ManifestEntry), Sequence: global.Sequence, Locale: locale}
+	for documentURL, entry := range global.Documents {
+		if slices.Contains(entry.Locales, locale) {
+			local.Documents[documentURL] = entry
+		}
+	}
+	for _, entry := range local.Documents { // Alias targets hold the file, even when listed under another locale only
+		if target, ok := global.Documents[entry.AliasOf]; ok {
+			local.Documents[entry.AliasOf] = target
+		}
+	}
+	for _, change := range global.Changes {
+		if _, ok := local.Documents[change.URL]; ok {
+			local.Changes = append(local.Changes, change)
+		}
+	}
+	for _, run := range global.Runs {
+		if count, ok := run.ByLocale[locale]; ok {
+			local.Runs = append(local.Runs, RunStats{At: run.At, Discovered: count, ByLocale: map[string]int{locale: count}, Anomalous: run.Anomalous})
+		}
+	}
+	return local
+}
+
+// SaveLocaleManifests writes a manifest per locale to template, in which {locale} is replaced, and records them in
+// the merged manifest saved at globalPath, which the caller saves afterwards; locales are those configured plus
+// every locale a document is listed under, so a locale whose listing emptied still gets an authoritative file
+func SaveLocaleManifests(global *Manifest, globalPath, template string, locales []string) error {
+	if !strings.Contains(template, "{locale}") {
+		return fmt.Errorf("locale manifest path %q has no {locale}", template)
+	}
+	seen := make(map[string]bool)
+	for _, locale := range locales {
+		seen[locale] = true
+	}
+	for _, entry := range global.Documents {
+		for _, locale := range entry.Locales {
+			seen[locale] = true
+		}
+	}
+	global.LocaleManifests = make(map[string]string, len(seen))
+	for _, locale := range slices.Sorted(maps.Keys(seen)) {
+		path := strings.ReplaceAll(template, "{locale}", locale)
+		if err := makeDirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		local := LocaleManifest(global, locale)
+		local.Global = relativePath(path, globalPath)
+		if err := SaveManifest(path, local); err != nil {
+			return fmt.Errorf("save %s manifest: %w", locale, err)
+		}
+		global.LocaleManifests[locale] = relativePath(globalPath, path)
+	}
+	return nil
+}
+
+// Returns target relative to the directory of from, or target as given when there is no relative path
+func relativePath(from, target string) string {
+	fromDir, err := filepath.Abs(filepath.Dir(from))
+	if err != nil {
+		return target
+	}
+	absolute, err := filepath.Abs(target)
+	if err != nil {
+		return target
+	}
+	relative, err := filepath.Rel(fromDir, absolute)
+	if err != nil {
+		return target
+	}
+	return filepath.ToSlash(relative)
+}
+
+// Reports whether a file in the output directory is one of the per-locale manifests the merged manifest references
+func (m *Manifest) isLocaleManifest(name string) bool {
+	for _, path := range m.LocaleManifests {
+		if path == name || strings.HasSuffix(path, "/"+name) {
+			return true
+		}
+	}
+	return false
+}
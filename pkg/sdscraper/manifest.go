@@ -0,0 +1,220 @@
+package sdscraper
+
+import ( // Import required packages
+	"encoding/json" // For reading and writing the manifest
+	"fmt"           // For error wrapping
+	"log/slog"      // For structured logging
+	"path/filepath" // For the name the manifest is sealed under
+	"time"          // For timestamps
+)
+
+// ManifestEntry describes the download state of a single document URL
+type ManifestEntry struct {
+	URL            string                         `json:"url"`                       // Source URL of the document
+	Filename       string                         `json:"filename"`                  // File name inside the output directory
+	Locales        []string                       `json:"locales,omitempty"`         // Site locales the document was listed under
+	Brand          string                         `json:"brand,omitempty"`           // Brand the document belongs to
+	Vendor         string                         `json:"vendor,omitempty"`          // Supplier whose site lists the document, set by vendor site crawls
+	DuplicateOf    string                         `json:"duplicate_of,omitempty"`    // In a merged catalog, URL of another vendor's document with identical content
+	ETag           string                         `json:"etag,omitempty"`            // ETag returned by the server
+	LastModified   string                         `json:"last_modified,omitempty"`   // Last-Modified returned by the server
+	Size           int64                          `json:"size,omitempty"`            // Size of the stored file in bytes
+	SHA256         string                         `json:"sha256,omitempty"`          // Hex SHA-256 of the stored content
+	Digests        map[string]string              `json:"digests,omitempty"`         // Further hex digests of the content by algorithm, e.g. "sha512"
+	AliasOf        string                         `json:"alias_of,omitempty"`        // URL of the document whose file holds identical content
+	Source         string                         `json:"source,omitempty"`          // SourceUpload for supplemental documents, empty when fetched
+	Product        string                         `json:"product,omitempty"`         // Product the document belongs to
+	SKU            string                         `json:"sku,omitempty"`             // Product code shown on the listing
+	Language       string                         `json:"language,omitempty"`        // Language code of the document, from the listing
+	Revision       string                         `json:"revision,omitempty"`        // Revision date shown on the listing, YYYY-MM-DD
+	Type           string                         `json:"type,omitempty"`            // Document type the download was recognised as, e.g. "docx"
+	ServerFilename string                         `json:"server_filename,omitempty"` // Name the server suggested in Content-Disposition
+	StoreVersion   string                         `json:"store_version,omitempty"`   // Version a VersionedStorage gave the stored revision, e.g. an S3 version ID
+	URLFilename    string                         `json:"url_filename,omitempty"`    // Name derived from the URL, recorded alongside ServerFilename
+	AttachedTo     string                         `json:"attached_to,omitempty"`     // File name of the catalogued document this one supplements
+	Description    string                         `json:"description,omitempty"`     // Free-text label, e.g. "Internal risk assessment"
+	Tags           []string                       `json:"tags,omitempty"`            // Labels from tag rules, the API or the catalog command, sorted
+	HazardCodes    []string                       `json:"hazard_codes,omitempty"`    // GHS hazard statements in the text, e.g. H225; found when rules match on them
+	Sources        map[string]FieldSource         `json:"sources,omitempty"`         // How each metadata field's extracted value was found, by field name
+	Corrections    map[string]*MetadataCorrection `json:"corrections,omitempty"`     // Metadata fields corrected by hand, by field name
+	DownloadedAt   time.Time                      `json:"downloaded_at,omitzero"`    // When the file was last written
+	CheckedAt      time.Time                      `json:"checked_at,omitzero"`       // When the server was last asked about the file
+	LastAccessed   time.Time                      `json:"last_accessed,omitzero"`    // When serve mode last handed the file out
+	AccessCount    int64                          `json:"access_count,omitempty"`    // Times serve mode handed the file out
+	DeletedAt      time.Time                      `json:"deleted_at,omitzero"`       // When the document was soft-deleted, zero if live
+	DeletedReason  string                         `json:"deleted_reason,omitempty"`  // Why it was deleted
+	Pinned         bool                           `json:"pinned,omitempty"`          // Never evicted from the local cache
+	LegalHold      bool                           `json:"legal_hold,omitempty"`      // Never evicted while the hold is in place
+}
+
+// Scheme of the manifest keys given to uploaded documents, which have no source URL
+const uploadScheme = "upload://"
+
+// SourceUpload marks documents added through the upload endpoint rather than fetched
+const SourceUpload = "upload"
+
+// Kinds of catalog change recorded in the manifest's change log
+const (
+	ChangeAdded   = "added"   // A document was catalogued for the first time
+	ChangeUpdated = "updated" // New content was downloaded for a document
+	ChangeRemoved = "removed" // A document was dropped from the catalog
+)
+
+const maxChangeLog = 10000 // Oldest changes are forgotten beyond this many
+
+// Change is one entry in the catalog change log
+type Change struct {
+	Sequence int64     `json:"seq"`      // Monotonic cursor, unique within the manifest
+	Time     time.Time `json:"time"`     // When the change was recorded
+	Kind     string    `json:"kind"`     // ChangeAdded, ChangeUpdated or ChangeRemoved
+	URL      string    `json:"url"`      // Source URL of the document
+	Filename string    `json:"filename"` // File name at the time of the change
+}
+
+// Manifest holds the download state of every known document, keyed by URL
+type Manifest struct {
+	Documents map[string]*ManifestEntry `json:"documents"`         // Entries keyed by source URL
+	Sequence  int64                     `json:"sequence"`          // Cursor of the most recent change
+	Changes   []Change                  `json:"changes,omitempty"` // Recent changes, oldest first
+	Runs      []RunStats                `json:"runs,omitempty"`    // Discovery counts of recent complete runs, oldest first
+	Build     *BuildInfo                `json:"build,omitempty"`   // Binary that last wrote the manifest
+
+	PendingPrune *PendingPrune `json:"pending_prune,omitempty"` // A held prune waiting for an admin's decision
+
+	LocaleManifests map[string]string `json:"locale_manifests,omitempty"` // Per-locale manifests by locale, paths relative to this file
+	Locale          string            `json:"locale,omitempty"`           // Set in a per-locale manifest: the locale its documents are listed under
+	Global          string            `json:"global,omitempty"`           // Set in a per-locale manifest: the merged manifest, relative to this file
+}
+
+// NewManifest returns an empty manifest
+func NewManifest() *Manifest {
+	return &Manifest{Documents: make(map[string]*ManifestEntry)}
+}
+
+// Reports whether no document had been stored before t, as when a mirror is first populated
+func (m *Manifest) emptyBefore(t time.Time) bool {
+	for _, entry := range m.Documents {
+		if !entry.DownloadedAt.IsZero() && entry.DownloadedAt.Before(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// EntryFor returns the entry for a URL, creating it if it doesn't exist yet
+func (m *Manifest) EntryFor(rawURL string) *ManifestEntry {
+	entry, ok := m.Documents[rawURL] // Look up existing entry
+	if !ok {
+		entry = &ManifestEntry{URL: rawURL, Filename: URLToFilename(rawURL)} // Create a new one
+		m.Documents[rawURL] = entry
+		m.RecordChange(ChangeAdded, entry)
+	}
+	return entry
+}
+
+// Returns the live documents that came from a listing rather than an upload
+func (m *Manifest) listedURLs() map[string]bool {
+	urls := make(map[string]bool, len(m.Documents))
+	for documentURL, entry := range m.Documents {
+		if entry.DeletedAt.IsZero() && entry.Source != SourceUpload {
+			urls[documentURL] = true
+		}
+	}
+	return urls
+}
+
+// ClaimFilename implements HashIndex, giving documentURL's entry name or, if another entry uses it, a hash-suffixed variant
+func (m *Manifest) ClaimFilename(name, documentURL string) string {
+	name = m.uniqueFilename(name, documentURL)
+	if entry, ok := m.Documents[documentURL]; ok {
+		entry.Filename = name // Visible to later claims before the download is published
+	}
+	return name
+}
+
+// LookupHash returns a stored, live, non-alias entry other than excludeURL with the given content hash
+func (m *Manifest) LookupHash(sha256, excludeURL string) (*ManifestEntry, bool) {
+	for documentURL, entry := range m.Documents {
+		if documentURL != excludeURL && entry.AliasOf == "" && entry.DeletedAt.IsZero() && entry.SHA256 == sha256 {
+			return entry, true
+		}
+	}
+	return nil, false
+}
+
+// Remove drops a document from the manifest and records the removal
+func (m *Manifest) Remove(rawURL string) {
+	entry, ok := m.Documents[rawURL]
+	if !ok {
+		return
+	}
+	delete(m.Documents, rawURL)
+	m.RecordChange(ChangeRemoved, entry)
+}
+
+// RecordChange appends a change to the log, trimming the oldest entries beyond the retention limit
+func (m *Manifest) RecordChange(kind string, entry *ManifestEntry) {
+	m.Sequence++ // Cursors never repeat, even after trimming
+	m.Changes = append(m.Changes, Change{Sequence: m.Sequence, Time: time.Now().UTC(), Kind: kind, URL: entry.URL, Filename: entry.Filename})
+	if excess := len(m.Changes) - maxChangeLog; excess > 0 {
+		m.Changes = append(m.Changes[:0:0], m.Changes[excess:]...) // Copy so the old backing array can be freed
+	}
+}
+
+// LoadManifest loads the manifest from disk, returning an empty one if it doesn't exist or does not decode; a file
+// that cannot be read, e.g. one sealed under another key, is an error, as starting fresh would overwrite the catalog
+func LoadManifest(path string) (*Manifest, error) {
+	loaded := NewManifest() // Empty manifest
+	if !fileExists(path) {
+		return loaded, nil // Nothing saved yet
+	}
+	data, err := readStored(path) // Decrypted when sealed at rest
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, loaded); err != nil { // Decode JSON
+		slog.Error("Decoding manifest failed", "path", path, "err", err)
+		return NewManifest(), nil // Start fresh on corrupt manifest
+	}
+	if loaded.Documents == nil {
+		loaded.Documents = make(map[string]*ManifestEntry) // Guard against "documents": null
+	}
+	return loaded, nil
+}
+
+// ReadManifest reads the manifest at path, reporting a missing or undecodable file instead of starting fresh
+func ReadManifest(path string) (*Manifest, error) {
+	data, err := readStored(path)
+	if err != nil {
+		return nil, err
+	}
+	loaded := NewManifest()
+	if err := json.Unmarshal(data, loaded); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+	if loaded.Documents == nil {
+		loaded.Documents = make(map[string]*ManifestEntry)
+	}
+	return loaded, nil
+}
+
+// SaveManifest writes the manifest to disk, replacing the previous copy atomically
+func SaveManifest(path string, m *Manifest) error {
+	build := Build()
+	m.Build = &build                             // Stamp the writer
+	data, err := json.MarshalIndent(m, "", "  ") // Encode as readable JSON
+	if err != nil {
+		return err
+	}
+	if !EncryptionEnabled() && sealedOnDisk(path) { // Never replace an encrypted manifest with a fresh plaintext one
+		return fmt.Errorf("save %s: %w", path, ErrNoEncryptionKey)
+	}
+	if data, err = seal(filepath.Base(path), append(data, '\n')); err != nil {
+		return err
+	}
+	tempPath := path + ".tmp" // Write next to the target so rename is atomic
+	if err := writeFile(tempPath, data, 0644); err != nil {
+		return err
+	}
+	return renameFile(tempPath, path) // Swap in the new manifest
+}
@@ -0,0 +1,99 @@
+package sdscraper
+
+import ( // Import required packages
+	"slices"  // For sorting URLs
+	"strconv" // For formatting numbers
+	"strings" // For joining locales
+	"time"    // For formatting timestamps
+)
+
+// ManifestDiff lists how a later manifest differs from an earlier one, every list sorted by URL
+type ManifestDiff struct {
+	Added   []string         `json:"added"`   // URLs only the later manifest has
+	Removed []string         `json:"removed"` // URLs only the earlier manifest has
+	Changed []DocumentChange `json:"changed"` // URLs both have whose compared fields differ
+}
+
+// DocumentChange is one document whose entry differs between two manifests
+type DocumentChange struct {
+	URL    string        `json:"url"`
+	Fields []FieldChange `json:"fields"` // In the order of the manifest entry
+}
+
+// FieldChange is one differing manifest field, named as in the manifest's JSON
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Empty reports whether the manifests hold the same documents with the same compared fields
+func (d ManifestDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Fields compared by DiffManifests; timestamps of checks and accesses change on every run and are left out
+var diffFields = []struct {
+	name  string
+	value func(*ManifestEntry) string
+}{
+	{"filename", func(e *ManifestEntry) string { return e.Filename }},
+	{"locales", func(e *ManifestEntry) string { return strings.Join(e.Locales, ",") }},
+	{"brand", func(e *ManifestEntry) string { return e.Brand }},
+	{"vendor", func(e *ManifestEntry) string { return e.Vendor }},
+	{"duplicate_of", func(e *ManifestEntry) string { return e.DuplicateOf }},
+	{"etag", func(e *ManifestEntry) string { return e.ETag }},
+	{"last_modified", func(e *ManifestEntry) string { return e.LastModified }},
+	{"size", func(e *ManifestEntry) string { return strconv.FormatInt(e.Size, 10) }},
+	{"sha256", func(e *ManifestEntry) string { return e.SHA256 }},
+	{"alias_of", func(e *ManifestEntry) string { return e.AliasOf }},
+	{"source", func(e *ManifestEntry) string { return e.Source }},
+	{"product", func(e *ManifestEntry) string { return e.Product }},
+	{"sku", func(e *ManifestEntry) string { return e.SKU }},
+	{"language", func(e *ManifestEntry) string { return e.Language }},
+	{"revision", func(e *ManifestEntry) string { return e.Revision }},
+	{"type", func(e *ManifestEntry) string { return e.Type }},
+	{"attached_to", func(e *ManifestEntry) string { return e.AttachedTo }},
+	{"description", func(e *ManifestEntry) string { return e.Description }},
+	{"deleted_at", func(e *ManifestEntry) string { return formatTime(e.DeletedAt) }},
+	{"pinned", func(e *ManifestEntry) string { return strconv.FormatBool(e.Pinned) }},
+	{"legal_hold", func(e *ManifestEntry) string { return strconv.FormatBool(e.LegalHold) }},
+}
+
+// DiffManifests compares the documents of manifest a with those of the later manifest b
+func DiffManifests(a, b *Manifest) ManifestDiff {
+	diff := ManifestDiff{Added: []string{}, Removed: []string{}, Changed: []DocumentChange{}} // Empty lists, never null
+	for documentURL, old := range a.Documents {
+		current, ok := b.Documents[documentURL]
+		if !ok {
+			diff.Removed = append(diff.Removed, documentURL)
+			continue
+		}
+		var fields []FieldChange
+		for _, field := range diffFields {
+			if before, after := field.value(old), field.value(current); before != after {
+				fields = append(fields, FieldChange{Field: field.name, Old: before, New: after})
+			}
+		}
+		if len(fields) > 0 {
+			diff.Changed = append(diff.Changed, DocumentChange{URL: documentURL, Fields: fields})
+		}
+	}
+	for documentURL := range b.Documents {
+		if _, ok := a.Documents[documentURL]; !ok {
+			diff.Added = append(diff.Added, documentURL)
+		}
+	}
+	slices.Sort(diff.Added)
+	slices.Sort(diff.Removed)
+	slices.SortFunc(diff.Changed, func(x, y DocumentChange) int { return strings.Compare(x.URL, y.URL) })
+	return diff
+}
+
+// Formats t as RFC 3339, empty when zero
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
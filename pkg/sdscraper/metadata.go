@@ -0,0 +1,297 @@
+package sdscraper
+
+import ( // Import required packages
+	"cmp"           // For keeping earlier metadata
+	"crypto/sha256" // For stable per-URL suffixes
+	"fmt"           // For hex digests
+	"html"          // For decoding entities in listing text
+	"net/url"       // For resolving relative links
+	"path"          // For file extensions
+	"regexp"        // For recognising SKUs, dates and tags
+	"slices"        // For product name candidates
+	"strings"       // For string manipulation
+	"time"          // For normalising revision dates
+	"unicode"       // For keeping letters in file names
+)
+
+// DocumentMetadata is what a listing says about one document next to its link
+type DocumentMetadata struct {
+	Product  string // Product name, e.g. "PURELL Advanced Hand Sanitizer Gel"
+	SKU      string // Product or item code, e.g. "9652-12"
+	Language string // Language code of the document, e.g. "en" or "fr"
+	Revision string // Revision date as YYYY-MM-DD
+
+	Sources map[string]FieldSource // How each field was found, by field name; unattributed fields count as listing patterns
+}
+
+var (
+	tagRegex      = regexp.MustCompile(`<[^>]*>`)                                                                                                           // Any HTML tag
+	skuRegex      = regexp.MustCompile(`(?i)\b(?:sku|item|code|product\s*(?:code|number|no\.?)|part\s*(?:number|no\.?))\s*[#:]?\s*([A-Z0-9][A-Z0-9-]{2,})`) // Labelled codes
+	bareSKURegex  = regexp.MustCompile(`\b\d{4}-\d{2,3}\b`)                                                                                                 // GOJO item numbers such as 2156-08
+	revisionRegex = regexp.MustCompile(`(?i)(?:revision|revised|rev\.?|issued?|date)\s*(?:date)?\s*:?\s*(\d{4}-\d{2}-\d{2}|\d{1,2}/\d{1,2}/\d{4}|\d{1,2}\.\d{1,2}\.\d{4})`)
+	boilerplate   = regexp.MustCompile(`(?i)^(download|view|open|pdf|sds|safety data sheet|click here|here)$`) // Link texts that name no product
+	rowStarts     = []string{"<tr", "<li", "<article"}                                                         // Elements that usually hold one listing row
+	rowEnds       = []string{"</tr>", "</li>", "</article>"}
+)
+
+// Language names as listings spell them, with their codes
+var languageNames = map[string]string{
+	"english": "en", "french": "fr", "français": "fr", "francais": "fr", "spanish": "es", "español": "es", "espanol": "es",
+	"portuguese": "pt", "português": "pt", "german": "de", "deutsch": "de", "italian": "it", "italiano": "it",
+	"dutch": "nl", "nederlands": "nl", "japanese": "ja", "chinese": "zh", "korean": "ko", "polish": "pl", "polski": "pl",
+}
+
+// Date layouts seen in listings, tried in order
+var revisionLayouts = []string{"2006-01-02", "1/2/2006", "2.1.2006"}
+
+// ExtractDocumentMetadata reads the product, SKU, language and revision date printed around each PDF link in a listing
+func ExtractDocumentMetadata(htmlContent string) map[string]DocumentMetadata {
+	var links []foundLink
+	for _, match := range pdfRegex.FindAllStringIndex(htmlContent, -1) {
+		links = append(links, foundLink{url: htmlContent[match[0]:match[1]], start: match[0], end: match[1]})
+	}
+	return metadataAround(htmlContent, links)
+}
+
+// ExtractLinkMetadata is ExtractDocumentMetadata for the links ExtractLinks finds with pattern
+func ExtractLinkMetadata(htmlContent, pageURL string, pattern *regexp.Regexp) map[string]DocumentMetadata {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+	var links []foundLink
+	for _, match := range attributeURLRegex.FindAllStringSubmatchIndex(htmlContent, -1) {
+		if link, ok := resolveLink(base, htmlContent[match[2]:match[3]], pattern); ok {
+			links = append(links, foundLink{url: link, start: match[2], end: match[3]})
+		}
+	}
+	return metadataAround(htmlContent, links)
+}
+
+// A document link and where it appears in a listing
+type foundLink struct {
+	url        string
+	start, end int
+}
+
+// Parses the listing row around each link, in order of appearance
+func metadataAround(htmlContent string, links []foundLink) map[string]DocumentMetadata {
+	metadata := make(map[string]DocumentMetadata)
+	for i, link := range links {
+		if _, ok := metadata[link.url]; ok { // The first row naming a document wins
+			continue
+		}
+		previousEnd, nextStart := 0, len(htmlContent) // Neighbouring links bound the row when no element does
+		if i > 0 {
+			previousEnd = links[i-1].end
+		}
+		if i+1 < len(links) {
+			nextStart = links[i+1].start
+		}
+		row := listingRow(htmlContent, link.start, link.end, previousEnd, nextStart)
+		metadata[link.url] = parseRow(row, anchorText(htmlContent, link.start, link.end))
+	}
+	return metadata
+}
+
+// Returns the markup of the table row, list item or article holding the link at start:end
+func listingRow(content string, start, end, previousEnd, nextStart int) string {
+	rowStart := -1 // The innermost row element before the link
+	for _, tag := range rowStarts {
+		if index := strings.LastIndex(content[previousEnd:start], tag); index >= 0 {
+			rowStart = max(rowStart, previousEnd+index)
+		}
+	}
+	if rowStart < 0 { // At least the link itself
+		rowStart = max(previousEnd, strings.LastIndex(content[:start], "<a"))
+	}
+	rowEnd := nextStart
+	if index := strings.Index(content[end:nextStart], "</a>"); index >= 0 {
+		rowEnd = end + index + len("</a>")
+	}
+	for _, tag := range rowEnds {
+		if index := strings.Index(content[end:nextStart], tag); index >= 0 {
+			rowEnd = min(rowEnd, end+index)
+		}
+	}
+	return content[rowStart:rowEnd]
+}
+
+// Returns the text of the anchor whose href is the link at start:end
+func anchorText(content string, start, end int) string {
+	open := strings.LastIndex(content[:start], "<a")
+	closeTag := strings.Index(content[end:], ">")
+	if open < 0 || closeTag < 0 {
+		return ""
+	}
+	inner := content[end+closeTag+1:]
+	if stop := strings.Index(inner, "</a>"); stop >= 0 {
+		return cleanText(inner[:stop])
+	}
+	return ""
+}
+
+// Pulls metadata out of one row's markup, preferring the link text as the product name
+func parseRow(row, linkText string) DocumentMetadata {
+	var chunks []string // Text nodes in document order
+	for _, chunk := range tagRegex.Split(row, -1) {
+		if text := cleanText(chunk); text != "" {
+			chunks = append(chunks, text)
+		}
+	}
+	text := strings.Join(chunks, " | ")
+
+	meta := DocumentMetadata{Sources: make(map[string]FieldSource)}
+	found := func(field string, confidence float64) {
+		meta.Sources[field] = FieldSource{Method: MethodRegex, Extractor: "listing", Confidence: confidence}
+	}
+	if match := skuRegex.FindStringSubmatch(text); match != nil {
+		meta.SKU = match[1]
+		found("sku", 0.9) // Labelled as a code
+	} else if match := bareSKURegex.FindString(text); match != "" {
+		meta.SKU = match
+		found("sku", 0.6) // Shaped like one, wherever it stands
+	}
+	if match := revisionRegex.FindStringSubmatch(text); match != nil {
+		meta.Revision = normaliseDate(match[1])
+		found("revision", 0.8)
+		if _, err := time.Parse("2006-01-02", meta.Revision); err != nil { // Kept verbatim, so maybe not a date at all
+			found("revision", 0.3)
+		}
+	}
+	for _, chunk := range append([]string{linkText}, chunks...) {
+		if code, ok := languageNames[strings.ToLower(chunk)]; ok {
+			meta.Language = code
+			found("language", 0.8) // A whole cell or link naming the language
+			break
+		}
+	}
+	var candidates []string // Plausible product names, the link text first
+	for _, candidate := range append([]string{linkText}, chunks...) {
+		if productName(candidate, meta) && !slices.Contains(candidates, candidate) {
+			candidates = append(candidates, candidate)
+		}
+	}
+	if len(candidates) > 0 {
+		meta.Product = candidates[0]
+		if meta.SKU != "" { // Rows often print the code inside the name cell
+			meta.Product = strings.Trim(cleanText(strings.ReplaceAll(meta.Product, meta.SKU, "")), " -–|,")
+		}
+		switch {
+		case candidates[0] == linkText:
+			found("product", 0.8) // The link names the product
+		case len(candidates) == 1:
+			found("product", 0.7) // The only cell that could
+		default:
+			found("product", 0.5) // The first of several cells that could
+		}
+	}
+	return meta
+}
+
+// Reports whether a text chunk looks like a product name rather than a label, code, date or language
+func productName(candidate string, meta DocumentMetadata) bool {
+	lower := strings.ToLower(candidate)
+	switch {
+	case len(candidate) < 3, boilerplate.MatchString(candidate), languageNames[lower] != "":
+		return false
+	case candidate == meta.SKU, skuRegex.MatchString(candidate), bareSKURegex.MatchString(candidate) && len(candidate) < 12:
+		return false
+	case revisionRegex.MatchString(candidate), pdfRegex.MatchString(candidate):
+		return false
+	}
+	return true
+}
+
+// Decodes entities and collapses whitespace
+func cleanText(markup string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(markup)), " ")
+}
+
+// Converts a listing date to YYYY-MM-DD, keeping it verbatim when no layout fits
+func normaliseDate(value string) string {
+	for _, layout := range revisionLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed.Format("2006-01-02")
+		}
+	}
+	return value
+}
+
+// Copies the non-empty metadata fields onto the entry, except those corrected by hand or found by a more confident
+// source
+func (e *ManifestEntry) applyMetadata(meta DocumentMetadata) {
+	meta = meta.attributed(MethodRegex, "listing", LowConfidence)
+	e.extracted("product", meta.Product, meta.Sources["product"])
+	e.extracted("sku", meta.SKU, meta.Sources["sku"])
+	e.extracted("language", meta.Language, meta.Sources["language"])
+	e.extracted("revision", meta.Revision, meta.Sources["revision"])
+}
+
+var (
+	unsafeFilenameRegex = regexp.MustCompile(`[^\p{L}\p{N}._-]+`) // Characters templates must not put in a file name
+	separatorRunRegex   = regexp.MustCompile(`[_-]{2,}`)          // Runs left by empty values
+)
+
+// RenderFilename fills a template such as "{product}_{sku}_{lang}.pdf" from an entry's metadata; placeholders are
+// {product}, {sku}, {lang}, {revision}, {brand}, {locale}, {name} (the URL-derived name) and {id} (a short URL hash).
+// It returns "" when nothing the template uses tells this document apart, e.g. the listing showed no product or SKU.
+func RenderFilename(template string, entry *ManifestEntry) string {
+	identified := strings.Contains(template, "{name}") || strings.Contains(template, "{id}") ||
+		(strings.Contains(template, "{product}") && entry.Product != "") || (strings.Contains(template, "{sku}") && entry.SKU != "")
+	if !identified {
+		return ""
+	}
+	locale := ""
+	if len(entry.Locales) > 0 {
+		locale = entry.Locales[0]
+	}
+	sum := sha256.Sum256([]byte(entry.URL))
+	replacer := strings.NewReplacer(
+		"{product}", slugify(entry.Product),
+		"{sku}", slugify(entry.SKU),
+		"{lang}", slugify(cmp.Or(entry.Language, locale)),
+		"{revision}", slugify(entry.Revision),
+		"{brand}", slugify(entry.Brand),
+		"{locale}", slugify(locale),
+		"{name}", strings.TrimSuffix(URLToFilename(entry.URL), ".pdf"),
+		"{id}", fmt.Sprintf("%x", sum[:4]),
+	)
+	name := strings.TrimSuffix(replacer.Replace(template), ".pdf")
+	name = unsafeFilenameRegex.ReplaceAllString(name, "_")
+	name = separatorRunRegex.ReplaceAllStringFunc(name, func(run string) string { return run[:1] })
+	name = strings.Trim(name, "_-.")
+	if name == "" {
+		return ""
+	}
+	return name + ".pdf"
+}
+
+// Turns a metadata value into a file name fragment, keeping letters, digits and dots and dashing the rest
+func slugify(value string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range value {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.':
+			b.WriteRune(r)
+			dash = false
+		case !dash && b.Len() > 0:
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimRight(b.String(), "-")
+}
+
+// Returns name, or name with the URL's short hash appended when another document already uses it
+func (m *Manifest) uniqueFilename(name, documentURL string) string {
+	for otherURL, entry := range m.Documents {
+		if otherURL != documentURL && entry.Filename == name {
+			sum := sha256.Sum256([]byte(documentURL))
+			extension := path.Ext(name)
+			return strings.TrimSuffix(name, extension) + fmt.Sprintf("_%x", sum[:4]) + extension
+		}
+	}
+	return name
+}
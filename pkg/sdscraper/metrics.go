@@ -0,0 +1,190 @@
+package sdscraper
+
+import ( // Import required packages
+	"context"  // For telling aborted cycles apart
+	"errors"   // For matching cancellations
+	"fmt"      // For the exposition format
+	"net/http" // For the metrics endpoint
+	"sort"     // For finding histogram buckets
+	"strconv"  // For formatting bucket bounds
+	"sync"     // For guarding counters
+	"time"     // For latencies and timestamps
+)
+
+// Upper bounds in seconds of the download latency histogram buckets
+var downloadBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// Metrics counts crawl cycles and downloads for a Prometheus scrape; the zero value is not usable, see NewMetrics
+type Metrics struct {
+	mu          sync.Mutex
+	started     time.Time        // Process start, for the uptime gauge
+	cycles      map[string]int64 // Finished crawl cycles by status: ok, failed or aborted
+	discovered  int64            // Documents found on the listings, summed over cycles
+	downloads   map[string]int64 // Finished downloads by outcome
+	bytes       int64            // Bytes of documents written to storage
+	buckets     []int64          // Download latency counts per bucket, not cumulative
+	latencySum  float64          // Sum of download latencies in seconds
+	latencyN    int64            // Number of observed latencies
+	lastCycle   time.Time        // When the last cycle finished
+	lastSuccess time.Time        // When the last successful cycle finished
+	limited     map[string]int64 // Serve-mode requests refused by the public limits, by reason
+}
+
+// NewMetrics returns empty counters
+func NewMetrics() *Metrics {
+	return &Metrics{
+		started:   time.Now(),
+		cycles:    make(map[string]int64),
+		downloads: make(map[string]int64),
+		limited:   make(map[string]int64),
+		buckets:   make([]int64, len(downloadBuckets)+1), // The last one is +Inf
+	}
+}
+
+// ObserveRun counts one finished crawl cycle; a nil Metrics ignores it
+func (m *Metrics) ObserveRun(result *Result, err error) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	status := "ok"
+	switch {
+	case errors.Is(err, context.Canceled):
+		status = "aborted"
+	case err != nil:
+		status = "failed"
+	}
+	m.cycles[status]++
+	m.lastCycle = time.Now()
+	if err == nil {
+		m.lastSuccess = m.lastCycle
+	}
+	if result != nil {
+		m.discovered += int64(len(result.Discovered))
+	}
+}
+
+// Counts one request refused by the public limits; a nil Metrics ignores it
+func (m *Metrics) observeLimited(reason string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.limited[reason]++
+}
+
+// Counts one finished download; a nil Metrics ignores it
+func (m *Metrics) observeDownload(size int64, started time.Time, outcome Outcome, err error) {
+	if m == nil {
+		return
+	}
+	elapsed := time.Since(started).Seconds()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	label := "not_modified"
+	switch {
+	case errors.Is(err, ErrRobotsDisallowed):
+		label = "skipped"
+	case err != nil:
+		label = "failed"
+	case outcome == OutcomeDownloaded:
+		label = "downloaded"
+		m.bytes += size
+	case outcome == OutcomeAliased:
+		label = "aliased"
+	}
+	m.downloads[label]++
+	if label == "failed" || label == "skipped" { // Latency of transfers only
+		return
+	}
+	bucket := sort.SearchFloat64s(downloadBuckets, elapsed) // First bound not below the latency
+	m.buckets[bucket]++
+	m.latencySum += elapsed
+	m.latencyN++
+}
+
+// Handler serves the counters at /metrics in the Prometheus text format, a liveness check at /healthz and the build at /version
+func (m *Metrics) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", m.handleMetrics)
+	mux.HandleFunc("GET /version", handleVersion)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		m.mu.Lock()
+		lastCycle, lastSuccess := m.lastCycle, m.lastSuccess
+		m.mu.Unlock()
+		writeJSON(w, r, map[string]any{"status": "ok", "last_cycle": optionalTime(lastCycle), "last_success": optionalTime(lastSuccess)}, time.Time{})
+	})
+	return mux
+}
+
+// Writes every metric in the Prometheus text exposition format
+func (m *Metrics) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	fmt.Fprintln(w, "# HELP gojo_crawl_cycles_total Finished crawl cycles by status.")
+	fmt.Fprintln(w, "# TYPE gojo_crawl_cycles_total counter")
+	for _, status := range []string{"ok", "failed", "aborted"} {
+		fmt.Fprintf(w, "gojo_crawl_cycles_total{status=%q} %d\n", status, m.cycles[status])
+	}
+	fmt.Fprintln(w, "# HELP gojo_documents_discovered_total Documents found on the listings, summed over cycles.")
+	fmt.Fprintln(w, "# TYPE gojo_documents_discovered_total counter")
+	fmt.Fprintf(w, "gojo_documents_discovered_total %d\n", m.discovered)
+	fmt.Fprintln(w, "# HELP gojo_downloads_total Finished downloads by outcome.")
+	fmt.Fprintln(w, "# TYPE gojo_downloads_total counter")
+	for _, outcome := range []string{"downloaded", "not_modified", "aliased", "skipped", "failed"} {
+		fmt.Fprintf(w, "gojo_downloads_total{outcome=%q} %d\n", outcome, m.downloads[outcome])
+	}
+	fmt.Fprintln(w, "# HELP gojo_requests_limited_total Serve-mode requests refused by the public limits, by reason.")
+	fmt.Fprintln(w, "# TYPE gojo_requests_limited_total counter")
+	for _, reason := range limitReasons {
+		fmt.Fprintf(w, "gojo_requests_limited_total{reason=%q} %d\n", reason, m.limited[reason])
+	}
+	fmt.Fprintln(w, "# HELP gojo_bytes_written_total Bytes of documents written to storage.")
+	fmt.Fprintln(w, "# TYPE gojo_bytes_written_total counter")
+	fmt.Fprintf(w, "gojo_bytes_written_total %d\n", m.bytes)
+
+	fmt.Fprintln(w, "# HELP gojo_download_duration_seconds Time from request to stored document.")
+	fmt.Fprintln(w, "# TYPE gojo_download_duration_seconds histogram")
+	var cumulative int64
+	for i, bound := range downloadBuckets {
+		cumulative += m.buckets[i]
+		fmt.Fprintf(w, "gojo_download_duration_seconds_bucket{le=%q} %d\n", strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+	}
+	fmt.Fprintf(w, "gojo_download_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.latencyN)
+	fmt.Fprintf(w, "gojo_download_duration_seconds_sum %g\n", m.latencySum)
+	fmt.Fprintf(w, "gojo_download_duration_seconds_count %d\n", m.latencyN)
+
+	fmt.Fprintln(w, "# HELP gojo_last_cycle_timestamp_seconds When the last crawl cycle finished, 0 before the first.")
+	fmt.Fprintln(w, "# TYPE gojo_last_cycle_timestamp_seconds gauge")
+	fmt.Fprintf(w, "gojo_last_cycle_timestamp_seconds %d\n", unixOrZero(m.lastCycle))
+	fmt.Fprintln(w, "# HELP gojo_last_success_timestamp_seconds When the last successful crawl cycle finished, 0 before the first.")
+	fmt.Fprintln(w, "# TYPE gojo_last_success_timestamp_seconds gauge")
+	fmt.Fprintf(w, "gojo_last_success_timestamp_seconds %d\n", unixOrZero(m.lastSuccess))
+	build := Build()
+	fmt.Fprintln(w, "# HELP gojo_build_info Version and commit of the running binary.")
+	fmt.Fprintln(w, "# TYPE gojo_build_info gauge")
+	fmt.Fprintf(w, "gojo_build_info{version=%q,commit=%q} 1\n", build.Version, build.Commit)
+	fmt.Fprintln(w, "# HELP gojo_uptime_seconds Time since the process started.")
+	fmt.Fprintln(w, "# TYPE gojo_uptime_seconds gauge")
+	fmt.Fprintf(w, "gojo_uptime_seconds %g\n", time.Since(m.started).Seconds())
+}
+
+// Returns t as Unix seconds, zero for the zero time
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// Returns t for JSON, nil for the zero time
+func optionalTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC()
+}
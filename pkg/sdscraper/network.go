@@ -0,0 +1,221 @@
+package sdscraper
+
+import ( // Import required packages
+	"context"  // For stopping the monitor and giving up on waits
+	"errors"   // For recognising network failures
+	"log/slog" // For structured logging
+	"net"      // For interfaces and probes
+	"net/http" // For dropping stale connections
+	"net/url"  // For the default probe address
+	"slices"   // For ordering addresses
+	"strings"  // For the address fingerprint
+	"sync"     // For guarding the state
+	"time"     // For polling and suspend detection
+)
+
+// DefaultNetworkInterval is how often a NetworkMonitor looks at the interfaces and the clock
+const DefaultNetworkInterval = 5 * time.Second
+
+// Times a document whose transfer the network broke is retried within one run
+const maxNetworkRetries = 3
+
+// NetworkMonitor notices the machine losing its network, switching networks or waking from suspend, as laptops and
+// edge devices do during long daemon runs: work pauses while it is offline, connections bound to the old network are
+// dropped and transfers the change broke are retried, rather than each failing with a timeout
+type NetworkMonitor struct {
+	Interval  time.Duration   // Between checks, zero for DefaultNetworkInterval
+	Probe     string          // host:port dialled to confirm connectivity after a change, empty to trust the interfaces
+	Transport *http.Transport // Its idle connections are closed on a change, nil to skip
+	mu        sync.Mutex
+	online    chan struct{} // Closed while online; an open one while work waits for the network
+	changedAt time.Time     // Last change of address or resume from suspend
+	suspect   bool          // Connectivity is to be confirmed by the probe
+	addresses string        // Fingerprint of the usable interface addresses
+}
+
+// Starts watching for the length of a run, returning the function that stops it; a nil monitor does nothing
+func (m *NetworkMonitor) start(ctx context.Context) (stop func()) {
+	if m == nil {
+		return func() {}
+	}
+	m.mu.Lock()
+	m.online, m.suspect, m.addresses = make(chan struct{}), false, interfaceAddresses()
+	close(m.online) // Assume the network works until a check says otherwise
+	if m.addresses == "" {
+		m.setOnlineLocked(false)
+	}
+	m.mu.Unlock()
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(m.interval())
+		defer ticker.Stop()
+		last := time.Now()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				m.check(ctx, last, now)
+				last = now
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// Returns the interval between checks
+func (m *NetworkMonitor) interval() time.Duration {
+	if m.Interval > 0 {
+		return m.Interval
+	}
+	return DefaultNetworkInterval
+}
+
+// Compares the interfaces and the clock with the previous check at last, and probes while connectivity is in doubt
+func (m *NetworkMonitor) check(ctx context.Context, last, now time.Time) {
+	wall := now.Round(0).Sub(last.Round(0)) // Wall clocks keep running through suspend; monotonic ones stop
+	resumed := wall-now.Sub(last) > m.interval() || now.Sub(last) > 4*m.interval()
+	addresses := interfaceAddresses()
+
+	m.mu.Lock()
+	changed := addresses != m.addresses
+	if resumed || changed {
+		slog.Info("Network may have changed; dropping open connections", "resumed_from_suspend", resumed, "addresses_changed", changed)
+		m.addresses, m.changedAt, m.suspect = addresses, now, true
+		if m.Transport != nil {
+			m.Transport.CloseIdleConnections() // Bound to an address or route that may be gone
+		}
+	}
+	probe := addresses != "" && (m.suspect || !m.isOnlineLocked())
+	if addresses == "" {
+		m.setOnlineLocked(false)
+	}
+	m.mu.Unlock()
+	if !probe {
+		return
+	}
+	reachable := m.reachable(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.suspect = !reachable
+	m.setOnlineLocked(reachable)
+}
+
+// Dials the probe address, true without one
+func (m *NetworkMonitor) reachable(ctx context.Context) bool {
+	if m.Probe == "" {
+		return true
+	}
+	ctx, cancel := context.WithTimeout(ctx, m.interval())
+	defer cancel()
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", m.Probe)
+	if err != nil {
+		slog.Debug("Network probe failed", "probe", m.Probe, "err", err)
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// Reports whether the network is up; callers hold m.mu
+func (m *NetworkMonitor) isOnlineLocked() bool {
+	select {
+	case <-m.online:
+		return true
+	default:
+		return false
+	}
+}
+
+// Marks the network up, releasing waiting work, or down; callers hold m.mu
+func (m *NetworkMonitor) setOnlineLocked(online bool) {
+	switch was := m.isOnlineLocked(); {
+	case online && !was:
+		slog.Info("Network is back; resuming")
+		close(m.online)
+	case !online && was:
+		slog.Warn("Network is unavailable; pausing network work until it returns", "probe", m.Probe)
+		m.online = make(chan struct{})
+	}
+}
+
+// Blocks while the network is unavailable; a nil monitor never waits
+func (m *NetworkMonitor) wait(ctx context.Context) error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	online := m.online
+	m.mu.Unlock()
+	select {
+	case <-online:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Reports whether a transfer started at since and failed with err was broken by the network rather than the server:
+// it changed meanwhile, or a network error comes with the probe failing too, which pauses work until it recovers
+func (m *NetworkMonitor) disrupted(ctx context.Context, since time.Time, err error) bool {
+	var netErr net.Error
+	if m == nil || err == nil || ctx.Err() != nil || !errors.As(err, &netErr) {
+		return false
+	}
+	m.mu.Lock()
+	changed := m.changedAt.After(since) || !m.isOnlineLocked()
+	m.mu.Unlock()
+	if changed {
+		return true
+	}
+	if m.reachable(ctx) {
+		return false // The network works; the failure was the server's
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.suspect = true
+	m.setOnlineLocked(false)
+	return true
+}
+
+// Returns the up, non-loopback interfaces' routable addresses as one comparable string, empty when there are none
+func interfaceAddresses() string {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return "unknown" // Cannot tell; treat as a stable, working network
+	}
+	var addresses []string
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, _ := iface.Addrs()
+		for _, addr := range addrs {
+			if prefix, ok := addr.(*net.IPNet); ok && prefix.IP.IsGlobalUnicast() {
+				addresses = append(addresses, iface.Name+"="+prefix.IP.String())
+			}
+		}
+	}
+	slices.Sort(addresses)
+	return strings.Join(addresses, ",")
+}
+
+// NetworkProbe returns the host:port of a listing URL, the default address a NetworkMonitor dials
+func NetworkProbe(pageURL string) string {
+	parsed, err := url.Parse(strings.ReplaceAll(pageURL, "{locale}", "en"))
+	if err != nil || parsed.Hostname() == "" {
+		return ""
+	}
+	if port := parsed.Port(); port != "" {
+		return parsed.Host
+	}
+	if parsed.Scheme == "http" {
+		return net.JoinHostPort(parsed.Hostname(), "80")
+	}
+	return net.JoinHostPort(parsed.Hostname(), "443")
+}
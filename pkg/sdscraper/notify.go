@@ -0,0 +1,270 @@
+package sdscraper
+
+import ( // Import required packages
+	"bytes"         // For request bodies
+	"context"       // For cancelling deliveries
+	"encoding/json" // For payloads
+	"fmt"           // For error messages
+	"log/slog"      // For structured logging
+	"net/http"      // For posting to webhooks
+	"slices"        // For channel routing
+	"strings"       // For building Slack messages
+	"time"          // For detection timestamps
+)
+
+// Kinds of DocumentEvent
+const (
+	EventAdded   = "added"   // Not in the manifest before this run
+	EventUpdated = "updated" // Catalogued before, new content this run
+)
+
+// DocumentEvent describes a new or revised document found by a run
+type DocumentEvent struct {
+	Kind       string    `json:"kind"`        // EventAdded or EventUpdated
+	URL        string    `json:"url"`         // Source URL
+	Filename   string    `json:"filename"`    // File inside the output directory
+	SHA256     string    `json:"sha256"`      // Content hash
+	DetectedAt time.Time `json:"detected_at"` // When the run stored it
+	Tags       []string  `json:"tags,omitempty"`
+	Channels   []string  `json:"channels,omitempty"` // Notification channels the rules route the document to
+}
+
+// Notifier delivers document events, e.g. to a webhook
+type Notifier interface {
+	Notify(ctx context.Context, events []DocumentEvent) error
+}
+
+// Baseline summarises the first sync into an empty archive, which notifiers receive instead of an event per document
+// so that populating a new mirror does not announce thousands of "new" documents
+type Baseline struct {
+	PageURL    string    `json:"page_url"`         // Listing the archive mirrors
+	Vendor     string    `json:"vendor,omitempty"` // Site of a multi-vendor crawl
+	Documents  int       `json:"documents"`        // Documents stored by the run
+	Bytes      int64     `json:"bytes"`            // Size of the documents received
+	Failed     int       `json:"failed"`           // Documents that could not be downloaded; later runs retry them
+	DetectedAt time.Time `json:"detected_at"`      // When the run finished
+}
+
+// BaselineNotifier is implemented by notifiers that can send the summary of a first sync
+type BaselineNotifier interface {
+	NotifyBaseline(ctx context.Context, baseline Baseline) error
+}
+
+// WebhookNotifier POSTs each event as a JSON object to URL
+type WebhookNotifier struct {
+	URL    string       // Endpoint receiving the events
+	Client *http.Client // HTTP client, nil for a 10 second default
+}
+
+// Notify implements Notifier, posting one request per event
+func (w *WebhookNotifier) Notify(ctx context.Context, events []DocumentEvent) error {
+	for _, event := range events {
+		if err := postJSON(ctx, w.Client, w.URL, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NotifyChallenge implements ChallengeNotifier, posting {"kind": "challenge", "challenge": {...}}
+func (w *WebhookNotifier) NotifyChallenge(ctx context.Context, challenge Challenge) error {
+	return postJSON(ctx, w.Client, w.URL, struct {
+		Kind      string    `json:"kind"`
+		Challenge Challenge `json:"challenge"`
+	}{"challenge", challenge})
+}
+
+// NotifyBaseline implements BaselineNotifier, posting {"kind": "baseline", "baseline": {...}}
+func (w *WebhookNotifier) NotifyBaseline(ctx context.Context, baseline Baseline) error {
+	return postJSON(ctx, w.Client, w.URL, struct {
+		Kind     string   `json:"kind"`
+		Baseline Baseline `json:"baseline"`
+	}{"baseline", baseline})
+}
+
+// TaggedNotifier passes on only the events of documents matching Tags, so one notifier can cover e.g. the
+// hazardous documents of a plant
+type TaggedNotifier struct {
+	Notifier Notifier
+	Tags     TagFilter
+}
+
+// Notify implements Notifier, staying silent when no event matches
+func (t *TaggedNotifier) Notify(ctx context.Context, events []DocumentEvent) error {
+	var matched []DocumentEvent
+	for _, event := range events {
+		if t.Tags.Match(event.Tags) {
+			matched = append(matched, event)
+		}
+	}
+	if len(matched) == 0 {
+		return nil
+	}
+	return t.Notifier.Notify(ctx, matched)
+}
+
+// NotifyChallenge implements ChallengeNotifier when the wrapped notifier does; challenges concern the whole run
+func (t *TaggedNotifier) NotifyChallenge(ctx context.Context, challenge Challenge) error {
+	if notifier, ok := t.Notifier.(ChallengeNotifier); ok {
+		return notifier.NotifyChallenge(ctx, challenge)
+	}
+	return nil
+}
+
+// NotifyBaseline implements BaselineNotifier when the wrapped notifier does; the baseline covers the whole archive
+func (t *TaggedNotifier) NotifyBaseline(ctx context.Context, baseline Baseline) error {
+	if notifier, ok := t.Notifier.(BaselineNotifier); ok {
+		return notifier.NotifyBaseline(ctx, baseline)
+	}
+	return nil
+}
+
+// ChannelNotifier passes on only the events the rules route to Channel, e.g. flammables to an EHS webhook;
+// notifiers without a channel receive every event
+type ChannelNotifier struct {
+	Channel  string
+	Notifier Notifier
+}
+
+// Notify implements Notifier, staying silent when no event is routed to the channel
+func (c *ChannelNotifier) Notify(ctx context.Context, events []DocumentEvent) error {
+	var routed []DocumentEvent
+	for _, event := range events {
+		if slices.Contains(event.Channels, c.Channel) {
+			routed = append(routed, event)
+		}
+	}
+	if len(routed) == 0 {
+		return nil
+	}
+	return c.Notifier.Notify(ctx, routed)
+}
+
+// NotifyBaseline implements BaselineNotifier when the wrapped notifier does, so every channel learns the mirror exists
+func (c *ChannelNotifier) NotifyBaseline(ctx context.Context, baseline Baseline) error {
+	if notifier, ok := c.Notifier.(BaselineNotifier); ok {
+		return notifier.NotifyBaseline(ctx, baseline)
+	}
+	return nil
+}
+
+// SlackNotifier posts a summary of each run's events to a Slack incoming webhook
+type SlackNotifier struct {
+	WebhookURL string       // https://hooks.slack.com/services/...
+	Client     *http.Client // HTTP client, nil for a 10 second default
+}
+
+// Notify implements Notifier with a single message listing every event
+func (s *SlackNotifier) Notify(ctx context.Context, events []DocumentEvent) error {
+	var text strings.Builder
+	fmt.Fprintf(&text, "%d new or revised GOJO SDS documents:\n", len(events))
+	for _, event := range events {
+		fmt.Fprintf(&text, "• %s: <%s|%s>\n", event.Kind, event.URL, event.Filename)
+	}
+	return postJSON(ctx, s.Client, s.WebhookURL, map[string]string{"text": text.String()})
+}
+
+// NotifyChallenge implements ChallengeNotifier with a message asking for the challenge to be solved
+func (s *SlackNotifier) NotifyChallenge(ctx context.Context, challenge Challenge) error {
+	text := fmt.Sprintf(":warning: GOJO SDS sync paused at a %s challenge on <%s>; solve it in the browser window or supply the solved session's cookies.", challenge.Kind, challenge.URL)
+	if challenge.Screenshot != "" {
+		text += "\nScreenshot: " + challenge.Screenshot
+	}
+	return postJSON(ctx, s.Client, s.WebhookURL, map[string]string{"text": text})
+}
+
+// NotifyBaseline implements BaselineNotifier with one message instead of a list of every document
+func (s *SlackNotifier) NotifyBaseline(ctx context.Context, baseline Baseline) error {
+	text := fmt.Sprintf("GOJO SDS mirror of <%s> populated: %d documents stored", baseline.PageURL, baseline.Documents)
+	if baseline.Vendor != "" {
+		text = fmt.Sprintf("SDS mirror of %s (<%s>) populated: %d documents stored", baseline.Vendor, baseline.PageURL, baseline.Documents)
+	}
+	if baseline.Failed > 0 {
+		text += fmt.Sprintf(", %d to retry", baseline.Failed)
+	}
+	return postJSON(ctx, s.Client, s.WebhookURL, map[string]string{"text": text + ". New and revised documents are reported from the next sync on."})
+}
+
+// Posts value as JSON and treats any non-2xx status as failure
+func postJSON(ctx context.Context, client *http.Client, endpoint string, value any) error {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("notify %s: %s", endpoint, resp.Status)
+	}
+	return nil
+}
+
+// Builds the events for a finished run's new and revised documents
+func (r *Result) events() []DocumentEvent {
+	var events []DocumentEvent
+	add := func(kind, documentURL string) {
+		entry, ok := r.Manifest.Documents[documentURL]
+		if !ok || entry.SHA256 == "" { // Listed but never stored, e.g. a failed download
+			return
+		}
+		events = append(events, DocumentEvent{Kind: kind, URL: documentURL, Filename: entry.Filename, SHA256: entry.SHA256, DetectedAt: entry.DownloadedAt, Tags: entry.Tags})
+	}
+	for _, documentURL := range r.Added {
+		add(EventAdded, documentURL)
+	}
+	for _, documentURL := range r.Updated() {
+		add(EventUpdated, documentURL)
+	}
+	return events
+}
+
+// Sends the run's events to every notifier, or the baseline summary after a first sync, logging failures
+func (s *Scraper) notify(ctx context.Context, result *Result) {
+	events := result.events()
+	if len(events) == 0 {
+		return
+	}
+	if result.Baseline {
+		s.notifyBaseline(ctx, result, len(events))
+		return
+	}
+	for i := range events {
+		events[i].Channels = s.Downloader.Rules.channels(result.Manifest.Documents[events[i].URL])
+	}
+	for _, notifier := range s.Notifiers {
+		if err := notifier.Notify(ctx, events); err != nil {
+			slog.Error("Notification failed", "notifier", fmt.Sprintf("%T", notifier), "events", len(events), "err", err)
+			continue
+		}
+		slog.Info("Sent notification", "notifier", fmt.Sprintf("%T", notifier), "events", len(events))
+	}
+}
+
+// Sends the summary of a first sync that stored documents to every notifier that takes one
+func (s *Scraper) notifyBaseline(ctx context.Context, result *Result, documents int) {
+	baseline := Baseline{PageURL: s.PageURL, Vendor: s.Vendor, Documents: documents, Bytes: result.Bytes,
+		Failed: len(result.Failed), DetectedAt: time.Now().UTC()}
+	for _, notifier := range s.Notifiers {
+		baseliner, ok := notifier.(BaselineNotifier)
+		if !ok {
+			slog.Info("First sync; no per-document notifications", "notifier", fmt.Sprintf("%T", notifier), "documents", documents)
+			continue
+		}
+		if err := baseliner.NotifyBaseline(ctx, baseline); err != nil {
+			slog.Error("Baseline notification failed", "notifier", fmt.Sprintf("%T", notifier), "err", err)
+			continue
+		}
+		slog.Info("Sent baseline notification instead of per-document ones", "notifier", fmt.Sprintf("%T", notifier), "documents", documents)
+	}
+}
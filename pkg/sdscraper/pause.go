@@ -0,0 +1,136 @@
+package sdscraper
+
+import ( // Import required packages
+	"context"       // For giving up on a wait
+	"encoding/json" // For the pause responses
+	"log/slog"      // For structured logging
+	"net/http"      // For the pause endpoints
+	"strings"       // For trimming reasons
+	"sync"          // For guarding the state
+	"time"          // For the pause time
+)
+
+// PauseControl holds a sync's download queue while operators want the bandwidth back, e.g. during business hours:
+// transfers under way finish, no new ones start and the run keeps its progress until it is resumed
+type PauseControl struct {
+	mu      sync.Mutex
+	resumed chan struct{} // Open while paused, nil while running
+	since   time.Time
+	reason  string
+}
+
+// PauseStatus is the state of a PauseControl
+type PauseStatus struct {
+	Paused bool      `json:"paused"`
+	Since  time.Time `json:"since,omitzero"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// Pause holds new downloads from now on, false when already paused
+func (p *PauseControl) Pause(reason string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.resumed != nil {
+		return false
+	}
+	p.resumed, p.since, p.reason = make(chan struct{}), time.Now().UTC(), reason
+	slog.Info("Sync paused; downloads under way finish, queued ones wait", "reason", reason)
+	return true
+}
+
+// Resume lets held downloads start again, false when not paused
+func (p *PauseControl) Resume() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.resumed == nil {
+		return false
+	}
+	close(p.resumed)
+	slog.Info("Sync resumed", "paused_for", time.Since(p.since).Round(time.Second))
+	p.resumed, p.since, p.reason = nil, time.Time{}, ""
+	return true
+}
+
+// Status reports whether downloads are held, since when and why
+func (p *PauseControl) Status() PauseStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return PauseStatus{Paused: p.resumed != nil, Since: p.since, Reason: p.reason}
+}
+
+// RunTrigger starts a sync run an operator asked for, recording their reason with it, and reports whether it will happen
+type RunTrigger func(reason string) bool
+
+// Blocks while paused; a nil control never waits
+func (p *PauseControl) wait(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	resumed := p.resumed
+	p.mu.Unlock()
+	if resumed == nil {
+		return nil
+	}
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Reports the background sync's pause state; 404 when the server runs no sync
+func (s *Server) handlePauseStatus(w http.ResponseWriter, r *http.Request) {
+	if s.pause == nil {
+		http.Error(w, "this server runs no background sync", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, r, s.pause.Status(), time.Time{})
+}
+
+// Pauses or resumes the background sync via POST /sync/pause or /sync/resume, with an optional reason for a pause
+func (s *Server) handlePause(w http.ResponseWriter, r *http.Request) {
+	action := r.PathValue("action")
+	if action == "run" {
+		s.handleRunNow(w, r)
+		return
+	}
+	if action != "pause" && action != "resume" {
+		http.NotFound(w, r)
+		return
+	}
+	if s.pause == nil {
+		http.Error(w, "this server runs no background sync", http.StatusNotFound)
+		return
+	}
+	if action == "pause" {
+		s.pause.Pause(r.URL.Query().Get("reason"))
+	} else {
+		s.pause.Resume()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.pause.Status()); err != nil {
+		slog.Error("Writing pause status failed", "err", err)
+	}
+}
+
+// Starts an off-schedule background sync via POST /sync/run, recording the optional ?reason= in the run history so
+// the audit trail says why it happened; 409 when a run is under way and the overlap policy skips the trigger
+func (s *Server) handleRunNow(w http.ResponseWriter, r *http.Request) {
+	if s.runNow == nil {
+		http.Error(w, "this server runs no background sync", http.StatusNotFound)
+		return
+	}
+	reason := strings.TrimSpace(r.URL.Query().Get("reason"))
+	if !s.runNow(reason) {
+		http.Error(w, "a sync is already running and -sync-overlap skips further triggers", http.StatusConflict)
+		return
+	}
+	slog.Info("Sync run requested", "reason", reason)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(map[string]string{"reason": reason}); err != nil {
+		slog.Error("Writing run response failed", "err", err)
+	}
+}
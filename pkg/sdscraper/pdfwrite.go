@@ -0,0 +1,335 @@
+package sdscraper
+
+import ( // Import required packages
+	"bytes"   // For assembling the file
+	"cmp"     // For the default parent element
+	"fmt"     // For PDF operators
+	"slices"  // For finding drawn images
+	"strings" // For escaping text
+)
+
+// A generated PDF: pages of text in Helvetica and filled rectangles, which is all reports and index sheets need.
+// Content drawn through mark is tagged with its structure, so screen readers read headings and tables as such.
+type pdfBuilder struct {
+	width, height float64         // Page size in points
+	title         string          // Document title shown by viewers
+	lang          string          // Language of the text, e.g. "en-US"
+	pages         []*bytes.Buffer // Content stream of each page
+	root          *pdfElement     // Document structure, nil until an element is added
+	marked        [][]*pdfElement // Per page, the element owning each marked-content ID
+	images        []*pdfImage     // Pictures drawn on any page, e.g. a facility logo
+}
+
+// A picture as an uncompressed-RGB image XObject, deflated
+type pdfImage struct {
+	width, height int    // Pixels
+	data          []byte // zlib-compressed RGB samples, row by row
+}
+
+// A structure element of a tagged PDF, such as a heading, table row or figure
+type pdfElement struct {
+	tag      string      // Standard structure type: Document, H1, P, Table, TR, TH, TD or Figure
+	alt      string      // Alternate text, for figures
+	parent   *pdfElement // nil for the document
+	children []any       // *pdfElement or pdfMarkedContent, in reading order
+	number   int         // Object number, assigned while writing
+}
+
+// A run of marked content on a page belonging to a structure element
+type pdfMarkedContent struct {
+	page, id int
+}
+
+// Page sizes in points
+const (
+	letterWidth, letterHeight = 612.0, 792.0
+)
+
+// Returns an empty document with pages of the given size
+func newPDF(width, height float64, title, lang string) *pdfBuilder {
+	return &pdfBuilder{width: width, height: height, title: title, lang: lang}
+}
+
+// Starts a new page and returns its content stream
+func (b *pdfBuilder) page() *pdfPage {
+	content := &bytes.Buffer{}
+	b.pages = append(b.pages, content)
+	b.marked = append(b.marked, nil)
+	return &pdfPage{content: content, builder: b, index: len(b.pages) - 1}
+}
+
+// Adds a structure element under parent, or under the document when parent is nil
+func (b *pdfBuilder) element(parent *pdfElement, tag string) *pdfElement {
+	if b.root == nil {
+		b.root = &pdfElement{tag: "Document"}
+	}
+	parent = cmp.Or(parent, b.root)
+	element := &pdfElement{tag: tag, parent: parent}
+	parent.children = append(parent.children, element)
+	return element
+}
+
+// A page's content, drawn with the origin at the bottom left
+type pdfPage struct {
+	content *bytes.Buffer
+	builder *pdfBuilder
+	index   int // Position in the document
+}
+
+// Runs draw with what it draws tagged as content of element
+func (p *pdfPage) mark(element *pdfElement, draw func()) {
+	id := len(p.builder.marked[p.index])
+	p.builder.marked[p.index] = append(p.builder.marked[p.index], element)
+	element.children = append(element.children, pdfMarkedContent{page: p.index, id: id})
+	fmt.Fprintf(p.content, "/%s <</MCID %d>> BDC\n", element.tag, id)
+	draw()
+	p.content.WriteString("EMC\n")
+}
+
+// Runs draw with what it draws marked as decoration or pagination, which screen readers skip
+func (p *pdfPage) artifact(draw func()) {
+	p.content.WriteString("/Artifact BMC\n")
+	draw()
+	p.content.WriteString("EMC\n")
+}
+
+// Draws text with its baseline starting at x, y
+func (p *pdfPage) text(x, y, size float64, bold bool, text string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(p.content, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, pdfString(text))
+}
+
+// Draws a filled rectangle from its bottom left corner
+func (p *pdfPage) rect(x, y, width, height float64) {
+	fmt.Fprintf(p.content, "%.2f %.2f %.2f %.2f re f\n", x, y, width, height)
+}
+
+// Draws a line of the given width
+func (p *pdfPage) line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(p.content, "%.2f w %.2f %.2f m %.2f %.2f l S\n", width, x1, y1, x2, y2)
+}
+
+// Sets the grey level of what is drawn next, 0 for black and 1 for white
+func (p *pdfPage) gray(level float64) {
+	fmt.Fprintf(p.content, "%.2f g %.2f G\n", level, level)
+}
+
+// Draws a QR code's modules as a square of side size with its bottom left corner at x, y, quiet zone included
+func (p *pdfPage) qr(x, y, size float64, modules [][]bool) {
+	module := size / float64(len(modules)+8)
+	top := y + size - 4*module
+	for row, line := range modules {
+		for column := 0; column < len(line); column++ {
+			if !line[column] {
+				continue
+			}
+			start := column
+			for column+1 < len(line) && line[column+1] { // One rectangle per run keeps the stream small
+				column++
+			}
+			p.rect(x+float64(4+start)*module, top-float64(row+1)*module, float64(column-start+1)*module, module)
+		}
+	}
+}
+
+// Draws img into the width by height box with its bottom left corner at x, y
+func (p *pdfPage) image(img *pdfImage, x, y, width, height float64) {
+	index := slices.Index(p.builder.images, img)
+	if index < 0 {
+		p.builder.images = append(p.builder.images, img)
+		index = len(p.builder.images) - 1
+	}
+	fmt.Fprintf(p.content, "q %.2f 0 0 %.2f %.2f %.2f cm /Im%d Do Q\n", width, height, x, y, index+1)
+}
+
+// Returns the document as a PDF file
+func (b *pdfBuilder) bytes() []byte {
+	objects := []string{
+		"", // Catalog, once the structure tree is numbered
+		"", // Pages, once the kids are known
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Title (%s) /Producer (gojo-com-documentation) >>", pdfString(b.title)),
+	}
+	var xobjects []string // Shared by every page
+	for i, img := range b.images {
+		objects = append(objects, fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream",
+			img.width, img.height, len(img.data), img.data))
+		xobjects = append(xobjects, fmt.Sprintf("/Im%d %d 0 R", i+1, len(objects)))
+	}
+	resources := "/Font << /F1 3 0 R /F2 4 0 R >>"
+	if len(xobjects) > 0 {
+		resources += " /XObject << " + strings.Join(xobjects, " ") + " >>"
+	}
+	var kids []string
+	pageNumbers := make([]int, len(b.pages))
+	for i, content := range b.pages {
+		objects = append(objects, fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+		structParents := ""
+		if b.root != nil {
+			structParents = fmt.Sprintf(" /StructParents %d", i)
+		}
+		objects = append(objects, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << %s >> /Contents %d 0 R /Tabs /S%s >>",
+			b.width, b.height, resources, len(objects), structParents))
+		pageNumbers[i] = len(objects)
+		kids = append(kids, fmt.Sprintf("%d 0 R", len(objects)))
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))
+
+	catalog := fmt.Sprintf("<< /Type /Catalog /Pages 2 0 R /Lang (%s) /ViewerPreferences << /DisplayDocTitle true >>", pdfString(b.lang))
+	if b.root != nil {
+		objects = append(objects, "") // Structure tree root, once the elements are numbered
+		treeRoot := len(objects)
+		var elements []*pdfElement
+		var number func(*pdfElement)
+		number = func(element *pdfElement) {
+			elements = append(elements, element)
+			element.number = treeRoot + len(elements)
+			for _, child := range element.children {
+				if child, ok := child.(*pdfElement); ok {
+					number(child)
+				}
+			}
+		}
+		number(b.root)
+		for _, element := range elements {
+			parent := treeRoot
+			if element.parent != nil {
+				parent = element.parent.number
+			}
+			var children []string
+			for _, child := range element.children {
+				switch child := child.(type) {
+				case *pdfElement:
+					children = append(children, fmt.Sprintf("%d 0 R", child.number))
+				case pdfMarkedContent:
+					children = append(children, fmt.Sprintf("<< /Type /MCR /Pg %d 0 R /MCID %d >>", pageNumbers[child.page], child.id))
+				}
+			}
+			alt := ""
+			if element.alt != "" {
+				alt = fmt.Sprintf(" /Alt (%s)", pdfString(element.alt))
+			}
+			objects = append(objects, fmt.Sprintf("<< /Type /StructElem /S /%s /P %d 0 R /K [%s]%s >>", element.tag, parent, strings.Join(children, " "), alt))
+		}
+		var parentTree []string // Lets readers find the element of each marked run
+		for page, owners := range b.marked {
+			refs := make([]string, len(owners))
+			for id, owner := range owners {
+				refs[id] = fmt.Sprintf("%d 0 R", owner.number)
+			}
+			parentTree = append(parentTree, fmt.Sprintf("%d [%s]", page, strings.Join(refs, " ")))
+		}
+		objects[treeRoot-1] = fmt.Sprintf("<< /Type /StructTreeRoot /K %d 0 R /ParentTree << /Nums [%s] >> /ParentTreeNextKey %d >>",
+			b.root.number, strings.Join(parentTree, " "), len(b.pages))
+		catalog += fmt.Sprintf(" /MarkInfo << /Marked true >> /StructTreeRoot %d 0 R", treeRoot)
+	}
+	objects[0] = catalog + " >>"
+
+	var file bytes.Buffer
+	file.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n") // Binary marker, so transfers keep it intact
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = file.Len()
+		fmt.Fprintf(&file, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := file.Len()
+	fmt.Fprintf(&file, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&file, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&file, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return file.Bytes()
+}
+
+// Characters outside Latin-1 that WinAnsiEncoding still has, as product names use them
+var winAnsiExtras = map[rune]byte{
+	'€': 0x80, '…': 0x85, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+// Encodes text for a PDF string in WinAnsiEncoding, escaping delimiters and replacing what the encoding lacks with ?
+func pdfString(text string) string {
+	var encoded strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			encoded.WriteByte('\\')
+			encoded.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			encoded.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&encoded, "\\%03o", r)
+		case winAnsiExtras[r] != 0:
+			fmt.Fprintf(&encoded, "\\%03o", winAnsiExtras[r])
+		default:
+			encoded.WriteByte('?')
+		}
+	}
+	return encoded.String()
+}
+
+// Helvetica advance widths of the printable ASCII characters, in thousandths of the font size
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // space to /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556, // 0 to ?
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778, // @ to O
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556, // P to _
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556, // ` to o
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584, // p to ~
+}
+
+// Returns the width of text set in Helvetica at size; bold is estimated, being about a tenth wider
+func textWidth(text string, size float64, bold bool) float64 {
+	total := 0
+	for _, r := range text {
+		if r >= 0x20 && r < 0x7f {
+			total += helveticaWidths[r-0x20]
+		} else if r == '…' || r == '—' || r == '™' {
+			total += 1000
+		} else {
+			total += 556
+		}
+	}
+	width := float64(total) * size / 1000
+	if bold {
+		width *= 1.1
+	}
+	return width
+}
+
+// Shortens text with an ellipsis until it fits width
+func fitText(text string, size float64, bold bool, width float64) string {
+	if textWidth(text, size, bold) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && textWidth(string(runes)+"…", size, bold) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return strings.TrimSpace(string(runes)) + "…"
+}
+
+// Breaks text into at most lines lines fitting width, breaking anywhere as file names have few spaces, the last one
+// shortened with an ellipsis
+func wrapText(text string, size float64, width float64, lines int) []string {
+	var wrapped []string
+	runes := []rune(text)
+	for len(runes) > 0 && len(wrapped) < lines-1 {
+		end := len(runes)
+		for end > 1 && textWidth(string(runes[:end]), size, false) > width {
+			end--
+		}
+		if end == len(runes) {
+			break
+		}
+		wrapped = append(wrapped, string(runes[:end]))
+		runes = runes[end:]
+	}
+	if len(runes) > 0 {
+		wrapped = append(wrapped, fitText(string(runes), size, false, width))
+	}
+	return wrapped
+}
@@ -0,0 +1,173 @@
+package sdscraper
+
+import ( // Import required packages
+	"encoding/json" // For the review and decision responses
+	"errors"        // For sentinel errors
+	"log/slog"      // For structured logging
+	"net/http"      // For the review endpoints
+	"slices"        // For ordering the review
+	"strings"       // For ordering the review
+	"time"          // For hold and decision times
+)
+
+// PendingPrune is a prune a run held back because its discovery looked anomalous or it would remove too much; it
+// waits in the manifest until an admin approves or rejects it, or a later run replaces it
+type PendingPrune struct {
+	ID     string    `json:"id"`      // Run ID of the run that held it
+	HeldAt time.Time `json:"held_at"` // When it was held
+	Reason string    `json:"reason"`  // Why, as Result.PruneHeld says it
+	URLs   []string  `json:"urls"`    // Documents it would soft-delete, in the run's prune scope
+}
+
+// PruneReview is a pending prune as a reviewable diff: each document it would remove, and whether it still would
+type PruneReview struct {
+	PendingPrune
+	Documents []PruneReviewItem `json:"documents"`
+}
+
+// PruneReviewItem is one document of a PruneReview
+type PruneReviewItem struct {
+	URL      string `json:"url"`
+	Filename string `json:"filename"`
+	Product  string `json:"product,omitempty"`
+	Revision string `json:"revision,omitempty"`
+	Skipped  string `json:"skipped,omitempty"` // Why approving would leave it alone, e.g. listed again since
+}
+
+// ErrNoPendingPrune refuses a decision on a prune that is not the one pending, e.g. as a later run replaced it
+var ErrNoPendingPrune = errors.New("no pending prune with that ID")
+
+// Holds back a prune of urls for review, replacing whatever prune was pending before
+func (m *Manifest) holdPrune(reason string, urls []string) {
+	if len(urls) == 0 {
+		m.PendingPrune = nil
+		return
+	}
+	now := time.Now().UTC()
+	id := now.Format(RunIDFormat)
+	if len(m.Runs) > 0 {
+		id = m.Runs[len(m.Runs)-1].RunID()
+	}
+	m.PendingPrune = &PendingPrune{ID: id, HeldAt: now, Reason: reason, URLs: slices.Clone(urls)}
+	slog.Warn("Prune held for review; approve or reject it through serve's /prunes/pending", "id", id, "documents", len(urls), "reason", reason)
+}
+
+// Returns why approving the pending prune would leave a document alone, empty if it would soft-delete it
+func (m *Manifest) pruneSkip(p *PendingPrune, documentURL string) string {
+	entry, ok := m.Documents[documentURL]
+	switch {
+	case !ok:
+		return "no longer catalogued"
+	case !entry.DeletedAt.IsZero():
+		return "already deleted"
+	case entry.CheckedAt.After(p.HeldAt): // Only listed documents are checked
+		return "listed again since"
+	}
+	return ""
+}
+
+// ReviewPrune returns the pending prune as a diff, false when none is pending
+func (m *Manifest) ReviewPrune() (PruneReview, bool) {
+	if m.PendingPrune == nil {
+		return PruneReview{}, false
+	}
+	review := PruneReview{PendingPrune: *m.PendingPrune, Documents: []PruneReviewItem{}}
+	for _, documentURL := range m.PendingPrune.URLs {
+		item := PruneReviewItem{URL: documentURL, Skipped: m.pruneSkip(m.PendingPrune, documentURL)}
+		if entry, ok := m.Documents[documentURL]; ok {
+			item.Filename, item.Product, item.Revision = entry.Filename, entry.Product, entry.Revision
+		}
+		review.Documents = append(review.Documents, item)
+	}
+	slices.SortFunc(review.Documents, func(a, b PruneReviewItem) int { return strings.Compare(a.Filename, b.Filename) })
+	return review, true
+}
+
+// ApprovePrune soft-deletes the documents of the pending prune with that ID, except any listed again since it was
+// held, and returns their URLs; an anomalous run it came from becomes the new norm, as with AllowAnomalousPrune
+func (m *Manifest) ApprovePrune(id string) ([]string, error) {
+	pending := m.PendingPrune
+	if pending == nil || pending.ID != id {
+		return nil, ErrNoPendingPrune
+	}
+	var pruned []string
+	for _, documentURL := range pending.URLs {
+		if m.pruneSkip(pending, documentURL) == "" && m.SoftDelete(documentURL, "no longer listed; prune approved") {
+			pruned = append(pruned, documentURL)
+		}
+	}
+	for i := range m.Runs {
+		if m.Runs[i].RunID() == id {
+			m.Runs[i].Anomalous = false
+		}
+	}
+	m.PendingPrune = nil
+	return pruned, nil
+}
+
+// RejectPrune drops the pending prune with that ID, leaving its documents catalogued
+func (m *Manifest) RejectPrune(id string) error {
+	if m.PendingPrune == nil || m.PendingPrune.ID != id {
+		return ErrNoPendingPrune
+	}
+	m.PendingPrune = nil
+	return nil
+}
+
+// Returns the pending prune as a diff, or 404 when nothing waits for review
+func (s *Server) handlePendingPrune(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.refresh()
+	review, ok := s.catalog.ReviewPrune()
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "no prune is pending", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(review); err != nil {
+		slog.Error("Writing prune review failed", "err", err)
+	}
+}
+
+// Response of POST /prunes/pending/{id}/approve and /reject
+type pruneDecision struct {
+	ID       string   `json:"id"`
+	Decision string   `json:"decision"`         // approved or rejected
+	Pruned   []string `json:"pruned,omitempty"` // Filenames soft-deleted, restorable for the retention window
+}
+
+// Approves or rejects the pending prune; 409 when it is no longer the one pending, e.g. a later run replaced it
+func (s *Server) handleDecidePrune(w http.ResponseWriter, r *http.Request) {
+	id, decision := r.PathValue("id"), r.PathValue("decision")
+	if decision != "approve" && decision != "reject" {
+		http.NotFound(w, r)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh()
+	response := pruneDecision{ID: id, Decision: "rejected"}
+	var err error
+	if decision == "approve" {
+		response.Decision = "approved"
+		var pruned []string
+		pruned, err = s.catalog.ApprovePrune(id)
+		for _, documentURL := range pruned {
+			response.Pruned = append(response.Pruned, s.catalog.Documents[documentURL].Filename)
+		}
+	} else {
+		err = s.catalog.RejectPrune(id)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	s.changedAt = time.Now()
+	s.save()
+	slog.Info("Decided pending prune", "id", id, "decision", response.Decision, "pruned", len(response.Pruned))
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.Error("Writing prune decision failed", "err", err)
+	}
+}
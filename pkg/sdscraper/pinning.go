@@ -0,0 +1,177 @@
+package sdscraper
+
+import ( // Import required packages
+	"context"         // For dialling hosts to read their pins
+	"crypto/sha256"   // For SPKI fingerprints
+	"crypto/tls"      // For the connection check
+	"crypto/x509"     // For certificates
+	"encoding/base64" // For the pin encoding
+	"errors"          // For ErrPinMismatch
+	"fmt"             // For error messages
+	"log/slog"        // For report-only mismatches
+	"net"             // For splitting host and port
+	"net/http"        // For the transport hook
+	"slices"          // For matching pins
+	"strings"         // For parsing pins
+	"time"            // For certificate expiry
+)
+
+// ErrPinMismatch is wrapped by PinError
+var ErrPinMismatch = errors.New("certificate pin mismatch")
+
+// Prefix of the only pin encoding accepted, the base64 SHA-256 of a certificate's SubjectPublicKeyInfo as HPKP used
+const pinPrefix = "sha256/"
+
+// PinError reports a TLS connection whose certificate chain carries none of the public keys pinned for its host
+type PinError struct {
+	Host      string   // Server name dialled
+	Pinned    []string // Pins configured for it
+	Presented []string // Pins of the chain it presented, leaf first
+}
+
+// Error names the host and both sets of pins, so a legitimate rotation can be told from an interception
+func (e *PinError) Error() string {
+	return fmt.Sprintf("tls: %v for %s: presented %s, pinned %s", ErrPinMismatch, e.Host,
+		strings.Join(e.Presented, " "), strings.Join(e.Pinned, " "))
+}
+
+// Unwrap makes errors.Is(err, ErrPinMismatch) work
+func (e *PinError) Unwrap() error {
+	return ErrPinMismatch
+}
+
+// CertificatePins restricts TLS connections to hosts, such as gojo.com and its CDN, to certificate chains carrying a
+// pinned public key. Pins are checked after the usual chain verification and match any certificate of the chain, so
+// pinning an intermediate survives leaf renewals; listing a backup key next to the current one lets a rotation go
+// through without an outage. Only the Go HTTP client is pinned, not Chrome rendering
+type CertificatePins struct {
+	Hosts      map[string][]string // Host, or "*.domain" for its subdomains, to its pins
+	ReportOnly []string            // Hosts, same patterns or "*" for all, whose mismatches are logged instead of failing
+}
+
+// SPKIPin returns a certificate's pin, "sha256/" and the base64 SHA-256 of its SubjectPublicKeyInfo
+func SPKIPin(certificate *x509.Certificate) string {
+	sum := sha256.Sum256(certificate.RawSubjectPublicKeyInfo)
+	return pinPrefix + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Add pins a public key, given as "sha256/BASE64", for host; repeat it to accept backup keys
+func (p *CertificatePins) Add(host, pin string) error {
+	host = strings.ToLower(strings.TrimSpace(host))
+	pin = strings.TrimSpace(pin)
+	if host == "" || strings.ContainsAny(host, "/:") {
+		return fmt.Errorf("invalid pin host %q", host)
+	}
+	sum, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(pin, pinPrefix))
+	if !strings.HasPrefix(pin, pinPrefix) || err != nil || len(sum) != sha256.Size {
+		return fmt.Errorf("invalid pin %q: want sha256/ and the base64 SHA-256 of the public key", pin)
+	}
+	if p.Hosts == nil {
+		p.Hosts = make(map[string][]string)
+	}
+	if !slices.Contains(p.Hosts[host], pin) {
+		p.Hosts[host] = append(p.Hosts[host], pin)
+	}
+	return nil
+}
+
+// Returns the pins for host, an exact entry winning over a wildcard one; none means the host is not pinned
+func (p *CertificatePins) pinsFor(host string) []string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if pins, ok := p.Hosts[host]; ok {
+		return pins
+	}
+	for pattern, pins := range p.Hosts {
+		if matchesHost(pattern, host) {
+			return pins
+		}
+	}
+	return nil
+}
+
+// Reports whether host is pattern or, for "*.domain", one of its subdomains
+func matchesHost(pattern, host string) bool {
+	if domain, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(host, "."+domain)
+	}
+	return pattern == host
+}
+
+// Reports whether host's mismatches are only logged
+func (p *CertificatePins) reportOnly(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	return slices.ContainsFunc(p.ReportOnly, func(pattern string) bool { return pattern == "*" || matchesHost(pattern, host) })
+}
+
+// VerifyConnection fails a TLS handshake, as tls.Config.VerifyConnection, when its host is pinned and the chain
+// carries none of the pins
+func (p *CertificatePins) VerifyConnection(state tls.ConnectionState) error {
+	pinned := p.pinsFor(state.ServerName)
+	if len(pinned) == 0 {
+		return nil
+	}
+	certificates := state.PeerCertificates
+	for _, chain := range state.VerifiedChains { // Roots too, which the server need not send
+		certificates = append(certificates, chain...)
+	}
+	var presented []string
+	for _, certificate := range certificates {
+		pin := SPKIPin(certificate)
+		if slices.Contains(pinned, pin) {
+			return nil
+		}
+		if !slices.Contains(presented, pin) {
+			presented = append(presented, pin)
+		}
+	}
+	err := &PinError{Host: state.ServerName, Pinned: pinned, Presented: presented}
+	if p.reportOnly(state.ServerName) {
+		slog.Warn("Certificate pin mismatch; connecting anyway as the host is report-only", "host", state.ServerName,
+			"presented", strings.Join(presented, " "), "pinned", strings.Join(pinned, " "))
+		return nil
+	}
+	return err
+}
+
+// Apply makes transport check the pins on every TLS connection it opens
+func (p *CertificatePins) Apply(transport *http.Transport) {
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.VerifyConnection = p.VerifyConnection
+}
+
+// PresentedCertificate describes one certificate of the chain a host presents
+type PresentedCertificate struct {
+	Host     string    `json:"host"`
+	Depth    int       `json:"depth"` // 0 for the leaf
+	Subject  string    `json:"subject"`
+	NotAfter time.Time `json:"not_after"`
+	Pin      string    `json:"pin"`
+}
+
+// PresentedPins connects to address, "host" or "host:port" with 443 as the default port, and returns the pins of the
+// verified chain it presents, leaf first, for reviewing a rotation before the new pin is added
+func PresentedPins(ctx context.Context, address string) ([]PresentedCertificate, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		host, port = address, "443"
+	}
+	dialer := &tls.Dialer{Config: &tls.Config{ServerName: host}}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	state := conn.(*tls.Conn).ConnectionState()
+	chain := state.PeerCertificates
+	if len(state.VerifiedChains) > 0 {
+		chain = state.VerifiedChains[0]
+	}
+	presented := make([]PresentedCertificate, len(chain))
+	for depth, certificate := range chain {
+		presented[depth] = PresentedCertificate{Host: host, Depth: depth, Subject: certificate.Subject.String(),
+			NotAfter: certificate.NotAfter, Pin: SPKIPin(certificate)}
+	}
+	return presented, nil
+}
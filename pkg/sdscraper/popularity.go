@@ -0,0 +1,30 @@
+package sdscraper
+
+import ( // Import required packages
+	"cmp"    // For comparing access statistics
+	"slices" // For sorting
+)
+
+// Returns the order documents are downloaded in: as found, or with the popular-first feature the ones serve mode handed
+// out most often first, so what workers actually open is revalidated before the long tail
+func (s *Scraper) downloadOrder(manifest *Manifest, urls []string) []string {
+	if !s.Features.Enabled(FeaturePopularFirst) {
+		return urls
+	}
+	ordered := slices.Clone(urls)
+	slices.SortStableFunc(ordered, func(a, b string) int { // Ties, e.g. never accessed, keep the listing's order
+		first, second := manifest.Documents[a], manifest.Documents[b]
+		var firstCount, secondCount int64
+		if first != nil {
+			firstCount = first.AccessCount
+		}
+		if second != nil {
+			secondCount = second.AccessCount
+		}
+		if order := cmp.Compare(secondCount, firstCount); order != 0 || first == nil || second == nil {
+			return order
+		}
+		return second.LastAccessed.Compare(first.LastAccessed) // Then the most recently used
+	})
+	return ordered
+}
@@ -0,0 +1,210 @@
+package sdscraper
+
+import ( // Import required packages
+	"cmp"           // For defaults
+	"context"       // For cancellation
+	"encoding/json" // For the portal's API responses
+	"errors"        // For configuration errors
+	"fmt"           // For error messages
+	"io"            // For reading responses
+	"net/http"      // For the portal's API
+	"net/url"       // For pagination and resolving links
+	"strconv"       // For page numbers
+	"strings"       // For the login form
+	"sync"          // For guarding the session
+)
+
+// DefaultPortalPages bounds the pages one portal listing is paginated through when PortalSource.MaxPages is zero
+const DefaultPortalPages = 100
+
+// PortalSource is an example DiscoverySource for an authenticated SDS portal with a JSON API: it logs in with a
+// form post or sends a bearer token, then pages through ListURL until a page comes back empty. Each page is either
+// an array of documents or an object with a "documents" (or "items", "data") array and an optional "next" URL;
+// documents carry the same fields as seed lists, with relative URLs resolved against the page
+type PortalSource struct {
+	ListURL       string       // First page of the document listing API
+	PageParameter string       // Query parameter holding the 1-based page number when responses give no "next", empty for "page"
+	MaxPages      int          // Pages fetched at most, zero for DefaultPortalPages
+	LoginURL      string       // Form endpoint the credentials are posted to, empty when Token is used or no login is needed
+	Username      string       // Posted as "username"
+	Password      string       // Posted as "password"
+	Token         string       // Sent as a bearer token to the portal's host, for API-key portals
+	Client        *http.Client // Shared with the downloader so the session cookies in its jar authorise document downloads too
+
+	mu       sync.Mutex // Guards loggedIn
+	loggedIn bool       // The session in the jar is believed valid
+}
+
+// One page of the portal's listing API
+type portalPage struct {
+	Documents []Seed `json:"documents"`
+	Items     []Seed `json:"items"`
+	Data      []Seed `json:"data"`
+	Next      string `json:"next"`
+}
+
+// Name implements DiscoverySource
+func (p *PortalSource) Name() string {
+	return "portal " + p.ListURL
+}
+
+// Discover implements DiscoverySource
+func (p *PortalSource) Discover(ctx context.Context, found func(DiscoveredDocument) bool) error {
+	if p.LoginURL != "" && (p.Client == nil || p.Client.Jar == nil) {
+		return errors.New("portal login needs a client with a cookie jar to keep the session in")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.loggedIn { // One session, reused by later runs until the portal rejects it
+		if err := p.logIn(ctx); err != nil {
+			return err
+		}
+		p.loggedIn = true
+	}
+	pageURL := p.ListURL
+	for page := 1; page <= cmp.Or(p.MaxPages, DefaultPortalPages); page++ {
+		documents, next, err := p.fetchPage(ctx, pageURL)
+		var status *StatusError
+		if errors.As(err, &status) && (status.StatusCode == http.StatusUnauthorized || status.StatusCode == http.StatusForbidden) {
+			p.loggedIn = false // Expired; the next run logs in again
+		}
+		if err != nil {
+			return fmt.Errorf("page %d: %w", page, err)
+		}
+		if len(documents) == 0 {
+			return nil
+		}
+		for _, document := range documents {
+			if !found(DiscoveredDocument{URL: document.URL, Locale: document.Locale, Metadata: document.metadata()}) {
+				return ctx.Err()
+			}
+		}
+		if next == "" {
+			next, err = withQuery(p.ListURL, cmp.Or(p.PageParameter, "page"), strconv.Itoa(page+1))
+			if err != nil {
+				return err
+			}
+		}
+		pageURL = next
+	}
+	return fmt.Errorf("still more documents after %d pages; raise MaxPages", cmp.Or(p.MaxPages, DefaultPortalPages))
+}
+
+// Posts the credentials to LoginURL, leaving the session cookies in the client's jar
+func (p *PortalSource) logIn(ctx context.Context) error {
+	if p.LoginURL == "" {
+		return nil
+	}
+	form := url.Values{"username": {p.Username}, "password": {p.Password}}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, p.LoginURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response, err := p.client().Do(request)
+	if err != nil {
+		return fmt.Errorf("log in: %w", err)
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+	if response.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("log in: %w", &StatusError{StatusCode: response.StatusCode, Status: response.Status})
+	}
+	return nil
+}
+
+// Fetches one listing page, returning its documents with absolute URLs and the next page's URL if it names one
+func (p *PortalSource) fetchPage(ctx context.Context, pageURL string) ([]Seed, string, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	request.Header.Set("Accept", "application/json")
+	response, err := p.client().Do(request)
+	if err != nil {
+		return nil, "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK { // A 401 here usually means the session expired or the token is wrong
+		return nil, "", &StatusError{StatusCode: response.StatusCode, Status: response.Status}
+	}
+	data, err := io.ReadAll(io.LimitReader(response.Body, 64<<20))
+	if err != nil {
+		return nil, "", err
+	}
+	var page portalPage
+	if err := json.Unmarshal(data, &page.Documents); err != nil { // A bare array, or else an object
+		if err := json.Unmarshal(data, &page); err != nil {
+			return nil, "", fmt.Errorf("parse listing page: %w", err)
+		}
+	}
+	documents := append(append(page.Documents, page.Items...), page.Data...)
+	base := response.Request.URL // After redirects
+	for i := range documents {
+		if reference, err := url.Parse(documents[i].URL); err == nil {
+			documents[i].URL = base.ResolveReference(reference).String()
+		}
+	}
+	next := ""
+	if page.Next != "" {
+		if reference, err := url.Parse(page.Next); err == nil {
+			next = base.ResolveReference(reference).String()
+		}
+	}
+	return documents, next, nil
+}
+
+// Returns the configured client or the default one
+func (p *PortalSource) client() *http.Client {
+	if p.Client != nil {
+		return p.Client
+	}
+	return http.DefaultClient
+}
+
+// Authorize wraps a transport so requests to the portal's host carry the bearer token, and no others do
+func (p *PortalSource) Authorize(base http.RoundTripper) http.RoundTripper {
+	if p.Token == "" {
+		return base
+	}
+	listURL, err := url.Parse(p.ListURL)
+	if err != nil {
+		return base
+	}
+	return &hostHeaderTransport{Base: base, Host: listURL.Host, Header: http.Header{"Authorization": {"Bearer " + p.Token}}}
+}
+
+// Adds headers to the requests for one host only, so credentials never leak to other sites
+type hostHeaderTransport struct {
+	Base   http.RoundTripper
+	Host   string
+	Header http.Header
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *hostHeaderTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if !strings.EqualFold(request.URL.Host, t.Host) {
+		return base.RoundTrip(request)
+	}
+	request = request.Clone(request.Context()) // RoundTrippers must not modify the caller's request
+	for name, values := range t.Header {
+		request.Header[name] = values
+	}
+	return base.RoundTrip(request)
+}
+
+// Returns rawURL with one query parameter set
+func withQuery(rawURL, name, value string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	query := parsed.Query()
+	query.Set(name, value)
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
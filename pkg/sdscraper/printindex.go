@@ -0,0 +1,183 @@
+package sdscraper
+
+import ( // Import required packages
+	"bytes"   // For buffering the file
+	"cmp"     // For the default title and sort order
+	"fmt"     // For page numbers
+	"io"      // For writing the file
+	"net/url" // For document links
+	"slices"  // For sorting entries
+	"strings" // For comparing products
+	"time"    // For the index date
+)
+
+// PrintIndexOptions configures WritePrintIndex
+type PrintIndexOptions struct {
+	Title     string         // Heading of every page, empty for "Safety Data Sheet Index"
+	LinkBase  string         // Serve-mode address the QR codes link into, e.g. "https://sds.example.com"; empty for source URLs
+	Tags      TagFilter      // Only list documents passing this filter
+	Generated time.Time      // Date printed on the index, zero for now
+	Stamp     *StampTemplate // Facility whose logo heads every page and whose name and contact sign the footer, nil for none
+	Snapshot  string         // Name of the snapshot the manifest is, for binders fixed at an audit date; empty for the live archive
+}
+
+// Layout of the index on US Letter, in points
+const (
+	indexMargin    = 40.0
+	indexRowHeight = 72.0
+	indexQRSize    = 64.0
+	indexTop       = letterHeight - indexMargin - 62 // Below the heading and column titles
+	indexPerPage   = 8                               // Rows fitting between indexTop and the footer
+)
+
+// Columns of the index: number, product with SKU and locales, revision date, file reference, QR code
+var indexColumns = [...]struct {
+	title string
+	x     float64
+}{{"No.", indexMargin}, {"Product", 72}, {"Revision", 318}, {"File reference", 382}, {"Link", letterWidth - indexMargin - indexQRSize}}
+
+// Returns the catalogued documents an index lists: stored or aliased, not deleted, in product order
+func indexEntries(m *Manifest, tags TagFilter) []*ManifestEntry {
+	var entries []*ManifestEntry
+	for _, entry := range m.Documents {
+		if entry.Filename != "" && entry.DeletedAt.IsZero() && (!entry.DownloadedAt.IsZero() || entry.AliasOf != "") && tags.Match(entry.Tags) {
+			entries = append(entries, entry)
+		}
+	}
+	slices.SortFunc(entries, func(a, b *ManifestEntry) int {
+		return cmp.Or(
+			strings.Compare(strings.ToLower(cmp.Or(a.Product, a.Filename)), strings.ToLower(cmp.Or(b.Product, b.Filename))),
+			strings.Compare(a.SKU, b.SKU), strings.Compare(a.Filename, b.Filename), strings.Compare(a.URL, b.URL))
+	})
+	return entries
+}
+
+// WritePrintIndex writes a printable PDF index of the archive's documents, for the front of a binder of printed
+// sheets: each row numbers a document and gives its product, revision date, file name and a QR code opening it
+func WritePrintIndex(w io.Writer, m *Manifest, options PrintIndexOptions) error {
+	title := cmp.Or(options.Title, "Safety Data Sheet Index")
+	generated := options.Generated
+	if generated.IsZero() {
+		generated = time.Now()
+	}
+	entries := indexEntries(m, options.Tags)
+	perPage := indexPerPage
+	pageCount := max(1, (len(entries)+perPage-1)/perPage)
+
+	document := newPDF(letterWidth, letterHeight, title, "en-US")
+	for pageIndex := range pageCount {
+		page := document.page()
+		page.mark(document.element(nil, "H1"), func() { page.text(indexMargin, letterHeight-indexMargin-16, 16, true, title) })
+		page.mark(document.element(nil, "P"), func() {
+			caption := fmt.Sprintf("%d documents, as of %s. Scan a code to open the current sheet.", len(entries), generated.Format("January 2, 2006"))
+			if options.Snapshot != "" {
+				caption = fmt.Sprintf("%d documents in snapshot %q of %s. Scan a code to open the sheet as it was then.", len(entries), options.Snapshot, generated.Format("January 2, 2006"))
+			}
+			page.text(indexMargin, letterHeight-indexMargin-32, 9, false, caption)
+		})
+		footer := generated.Format("2006-01-02")
+		if options.Stamp != nil {
+			options.Stamp.drawLogo(document, page, letterWidth-indexMargin, letterHeight-indexMargin+10)
+			footer += "  " + options.Stamp.line()
+		}
+		table := document.element(nil, "Table") // One per page, each with its own header row, as printed
+		header := document.element(table, "TR")
+		for _, column := range indexColumns {
+			page.mark(document.element(header, "TH"), func() { page.text(column.x, indexTop+8, 8, true, column.title) })
+		}
+		page.artifact(func() { page.line(indexMargin, indexTop+4, letterWidth-indexMargin, indexTop+4, 0.8) })
+
+		rows := entries[min(pageIndex*perPage, len(entries)):min((pageIndex+1)*perPage, len(entries))]
+		for i, entry := range rows {
+			top := indexTop - float64(i)*indexRowHeight
+			indexRow(document, page, document.element(table, "TR"), top, pageIndex*perPage+i+1, entry, indexLink(entry, options.LinkBase))
+			page.artifact(func() {
+				page.gray(0.75)
+				page.line(indexMargin, top-indexRowHeight, letterWidth-indexMargin, top-indexRowHeight, 0.4)
+				page.gray(0)
+			})
+		}
+		page.artifact(func() { // Repeated on every page
+			page.text(indexMargin, indexMargin-12, 8, false, fitText(footer, 8, false, letterWidth-2*indexMargin-80))
+			pageNumber := fmt.Sprintf("Page %d of %d", pageIndex+1, pageCount)
+			page.text(letterWidth-indexMargin-textWidth(pageNumber, 8, false), indexMargin-12, 8, false, pageNumber)
+		})
+	}
+	_, err := w.Write(document.bytes())
+	return err
+}
+
+// Draws one entry's cells into row below top
+func indexRow(document *pdfBuilder, page *pdfPage, row *pdfElement, top float64, number int, entry *ManifestEntry, link string) {
+	baseline := top - 16
+	page.mark(document.element(row, "TD"), func() { page.text(indexColumns[0].x, baseline, 10, true, fmt.Sprint(number)) })
+	productWidth := indexColumns[2].x - indexColumns[1].x - 10
+	var details []string
+	if entry.Brand != "" {
+		details = append(details, entry.Brand)
+	}
+	if entry.SKU != "" {
+		details = append(details, "SKU "+entry.SKU)
+	}
+	if len(entry.Locales) > 0 {
+		details = append(details, strings.Join(entry.Locales, ", "))
+	}
+	page.mark(document.element(row, "TD"), func() {
+		page.text(indexColumns[1].x, baseline, 10, true, fitText(cmp.Or(entry.Product, entry.Filename), 10, true, productWidth))
+		page.text(indexColumns[1].x, baseline-13, 8, false, fitText(strings.Join(details, " · "), 8, false, productWidth))
+	})
+
+	page.mark(document.element(row, "TD"), func() {
+		switch {
+		case entry.Revision != "":
+			page.text(indexColumns[2].x, baseline, 9, false, entry.Revision)
+		case !entry.DownloadedAt.IsZero(): // No revision on the listing, so the date of the copy
+			page.text(indexColumns[2].x, baseline, 9, false, entry.DownloadedAt.Format("2006-01-02"))
+			page.text(indexColumns[2].x, baseline-11, 6, false, "(downloaded)")
+		default:
+			page.text(indexColumns[2].x, baseline, 9, false, "—")
+		}
+	})
+
+	fileWidth := indexColumns[4].x - indexColumns[3].x - 8
+	page.mark(document.element(row, "TD"), func() {
+		for i, line := range wrapText(entry.Filename, 7, fileWidth, 4) {
+			page.text(indexColumns[3].x, baseline-float64(i)*9, 7, false, line)
+		}
+	})
+
+	cell := document.element(row, "TD")
+	modules, err := qrEncode([]byte(link))
+	if err != nil { // Too long to encode; the file reference still finds it
+		page.mark(cell, func() { page.text(indexColumns[4].x, baseline, 7, false, "(link too long)") })
+		return
+	}
+	figure := document.element(cell, "Figure")
+	figure.alt = "QR code linking to " + link // What a screen reader says in place of the code
+	page.mark(figure, func() { page.qr(indexColumns[4].x, top-indexQRSize-4, indexQRSize, modules) })
+}
+
+// Returns the address a document's QR code opens: the mirror's copy when it is served, otherwise the source URL
+func indexLink(entry *ManifestEntry, linkBase string) string {
+	if linkBase == "" {
+		return entry.URL
+	}
+	segments := strings.Split(entry.Filename, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.TrimSuffix(linkBase, "/") + "/documents/" + strings.Join(segments, "/")
+}
+
+// SavePrintIndex writes the index to path, replacing the previous one only once the new one is complete
+func SavePrintIndex(path string, m *Manifest, options PrintIndexOptions) error {
+	var index bytes.Buffer
+	if err := WritePrintIndex(&index, m, options); err != nil {
+		return err
+	}
+	temp := path + ".tmp" // Write next to the target so rename is atomic
+	if err := writeFile(temp, index.Bytes(), 0o644); err != nil {
+		return err
+	}
+	return renameFile(temp, path)
+}
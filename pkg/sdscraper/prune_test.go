@@ -0,0 +1,66 @@
+package sdscraper
+
+import ( // Import required packages
+	"fmt"     // For document URLs
+	"testing" // For the test harness
+)
+
+// Returns a result whose manifest holds inScope documents tagged "tds" and outScope tagged "sds", the first
+// unlistedIn and unlistedOut of each no longer listed, with the URLs listed before the run
+func pruneResult(inScope, outScope, unlistedIn, unlistedOut int) (*Result, map[string]bool) {
+	result := &Result{Manifest: NewManifest()}
+	known := make(map[string]bool)
+	add := func(tag string, count, unlisted int) {
+		for i := range count {
+			documentURL := fmt.Sprintf("https://www.gojo.com/%s/%d.pdf", tag, i)
+			result.Manifest.Documents[documentURL] = &ManifestEntry{URL: documentURL, Filename: fmt.Sprintf("%s_%d.pdf", tag, i), Tags: []string{tag}}
+			known[documentURL] = true
+			if i < unlisted {
+				result.Removed = append(result.Removed, documentURL)
+			}
+		}
+	}
+	add("tds", inScope, unlistedIn)
+	add("sds", outScope, unlistedOut)
+	return result, known
+}
+
+func TestPruneFractionOfScope(t *testing.T) {
+	tags, _ := ParseTagFilter("tds")
+	s := &Scraper{Prune: true, PruneTags: tags}
+
+	result, known := pruneResult(10, 90, 1, 20) // A fifth of the catalog, a tenth of the scope
+	s.prune(result, known)
+	if result.PruneHeld != "" || len(result.Pruned) != 1 {
+		t.Errorf("held %q, pruned %q; want the one unlisted document in scope pruned", result.PruneHeld, result.Pruned)
+	}
+
+	result, known = pruneResult(2, 90, 2, 0) // Every document in scope, a fiftieth of the catalog
+	s.prune(result, known)
+	if result.PruneHeld == "" || len(result.Pruned) != 0 {
+		t.Errorf("held %q, pruned %q; want the prune of the whole scope held", result.PruneHeld, result.Pruned)
+	}
+}
+
+func TestPruneLimitHoldsEverything(t *testing.T) {
+	s := &Scraper{Prune: true, MaxPruneFraction: -1}
+	result, known := pruneResult(100, 0, 1, 0) // One document in a hundred
+	s.prune(result, known)
+	if result.PruneHeld == "" || len(result.Pruned) != 0 {
+		t.Errorf("held %q, pruned %q; want a negative limit to hold even the smallest prune", result.PruneHeld, result.Pruned)
+	}
+
+	s.ForcePrune = true
+	result, known = pruneResult(100, 0, 1, 0)
+	s.prune(result, known)
+	if len(result.Pruned) != 1 {
+		t.Errorf("pruned %q with force, want the unlisted document", result.Pruned)
+	}
+
+	s = &Scraper{Prune: true} // Zero is the default, not "no limit"
+	result, known = pruneResult(100, 0, 20, 0)
+	s.prune(result, known)
+	if result.PruneHeld == "" {
+		t.Errorf("pruned %d of 100 with the default limit, want the prune held", len(result.Pruned))
+	}
+}
@@ -0,0 +1,360 @@
+package sdscraper
+
+import ( // Import required packages
+	"errors" // For oversized input
+)
+
+// Codeword layout of QR versions 1 to 10 at error correction level M, which survives a torn or smudged corner
+var qrVersionsM = [...]struct {
+	ecPerBlock       int    // Error correction codewords per block
+	blocks, dataLens [2]int // Blocks in each group and their data codewords
+	alignment        []int  // Alignment pattern centres
+}{
+	{10, [2]int{1, 0}, [2]int{16, 0}, nil},
+	{16, [2]int{1, 0}, [2]int{28, 0}, []int{6, 18}},
+	{26, [2]int{1, 0}, [2]int{44, 0}, []int{6, 22}},
+	{18, [2]int{2, 0}, [2]int{32, 0}, []int{6, 26}},
+	{24, [2]int{2, 0}, [2]int{43, 0}, []int{6, 30}},
+	{16, [2]int{4, 0}, [2]int{27, 0}, []int{6, 34}},
+	{18, [2]int{4, 0}, [2]int{31, 0}, []int{6, 22, 38}},
+	{22, [2]int{2, 2}, [2]int{38, 39}, []int{6, 24, 42}},
+	{22, [2]int{3, 2}, [2]int{36, 37}, []int{6, 26, 46}},
+	{26, [2]int{4, 1}, [2]int{43, 44}, []int{6, 28, 50}},
+}
+
+var errQRTooLong = errors.New("too long for a QR code")
+
+// A QR symbol being built: dark modules, and which modules belong to fixed patterns rather than data
+type qrSymbol struct {
+	size           int
+	dark, function [][]bool
+	version        int
+}
+
+// Encodes data in byte mode as the smallest QR code of versions 1 to 10 that holds it, returning its modules by row
+// with true for dark; a quiet zone of four modules must be left around it
+func qrEncode(data []byte) ([][]bool, error) {
+	version := 0
+	for v := range qrVersionsM {
+		layout := qrVersionsM[v]
+		capacity := layout.blocks[0]*layout.dataLens[0] + layout.blocks[1]*layout.dataLens[1]
+		countBits := 8
+		if v+1 >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) <= 8*capacity {
+			version = v + 1
+			break
+		}
+	}
+	if version == 0 {
+		return nil, errQRTooLong
+	}
+	symbol := newQRSymbol(version)
+	symbol.place(symbol.codewords(data))
+	best, bestPenalty := -1, 0
+	for mask := 0; mask < 8; mask++ { // Pick the mask leaving the fewest patterns that confuse readers
+		symbol.applyMask(mask)
+		symbol.drawFormat(mask)
+		if penalty := symbol.penalty(); best < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		symbol.applyMask(mask) // XOR again to undo it
+	}
+	symbol.applyMask(best)
+	symbol.drawFormat(best)
+	return symbol.dark, nil
+}
+
+// Returns a symbol of version with its function patterns drawn
+func newQRSymbol(version int) *qrSymbol {
+	size := 17 + 4*version
+	s := &qrSymbol{size: size, version: version, dark: make([][]bool, size), function: make([][]bool, size)}
+	for y := range size {
+		s.dark[y], s.function[y] = make([]bool, size), make([]bool, size)
+	}
+	for i := range size { // Timing patterns
+		s.set(6, i, i%2 == 0)
+		s.set(i, 6, i%2 == 0)
+	}
+	for _, corner := range [][2]int{{3, 3}, {size - 4, 3}, {3, size - 4}} { // Finder patterns with their separators
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := corner[0]+dx, corner[1]+dy
+				if x >= 0 && x < size && y >= 0 && y < size {
+					distance := max(abs(dx), abs(dy))
+					s.set(x, y, distance != 2 && distance != 4)
+				}
+			}
+		}
+	}
+	centres := qrVersionsM[version-1].alignment
+	for i, cx := range centres {
+		for j, cy := range centres {
+			if i == 0 && j == 0 || i == 0 && j == len(centres)-1 || i == len(centres)-1 && j == 0 {
+				continue // Overlaps a finder pattern
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					s.set(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+	s.drawFormat(0) // Reserves the format modules; the real mask is drawn later
+	if version >= 7 {
+		remainder := version
+		for range 12 {
+			remainder = remainder<<1 ^ (remainder>>11)*0x1F25
+		}
+		bits := version<<12 | remainder
+		for i := range 18 {
+			a, b := size-11+i%3, i/3
+			s.set(a, b, bits>>i&1 != 0)
+			s.set(b, a, bits>>i&1 != 0)
+		}
+	}
+	return s
+}
+
+// Sets a function module at column x, row y
+func (s *qrSymbol) set(x, y int, dark bool) {
+	s.dark[y][x] = dark
+	s.function[y][x] = true
+}
+
+// Draws both copies of the format information for level M and mask, and the dark module
+func (s *qrSymbol) drawFormat(mask int) {
+	data := 0<<3 | mask // Level M is 00
+	remainder := data
+	for range 10 {
+		remainder = remainder<<1 ^ (remainder>>9)*0x537
+	}
+	bits := (data<<10 | remainder) ^ 0x5412
+	bit := func(i int) bool { return bits>>i&1 != 0 }
+	for i := 0; i <= 5; i++ {
+		s.set(8, i, bit(i))
+	}
+	s.set(8, 7, bit(6))
+	s.set(8, 8, bit(7))
+	s.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		s.set(14-i, 8, bit(i))
+	}
+	for i := range 8 {
+		s.set(s.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		s.set(8, s.size-15+i, bit(i))
+	}
+	s.set(8, s.size-8, true)
+}
+
+// Returns the data and error correction codewords of data, interleaved across blocks
+func (s *qrSymbol) codewords(data []byte) []byte {
+	layout := qrVersionsM[s.version-1]
+	capacity := layout.blocks[0]*layout.dataLens[0] + layout.blocks[1]*layout.dataLens[1]
+	countBits := 8
+	if s.version >= 10 {
+		countBits = 16
+	}
+	var bits []bool
+	appendBits := func(value, count int) {
+		for i := count - 1; i >= 0; i-- {
+			bits = append(bits, value>>i&1 != 0)
+		}
+	}
+	appendBits(0b0100, 4) // Byte mode
+	appendBits(len(data), countBits)
+	for _, b := range data {
+		appendBits(int(b), 8)
+	}
+	appendBits(0, min(4, 8*capacity-len(bits))) // Terminator
+	appendBits(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < 8*capacity; pad ^= 0xEC ^ 0x11 {
+		appendBits(pad, 8)
+	}
+	encoded := make([]byte, capacity)
+	for i := range encoded {
+		for j := range 8 {
+			if bits[8*i+j] {
+				encoded[i] |= 0x80 >> j
+			}
+		}
+	}
+
+	divisor := reedSolomonDivisor(layout.ecPerBlock)
+	var blocks, ecc [][]byte
+	for group := range 2 {
+		for range layout.blocks[group] {
+			block := encoded[:layout.dataLens[group]]
+			encoded = encoded[layout.dataLens[group]:]
+			blocks, ecc = append(blocks, block), append(ecc, reedSolomonRemainder(block, divisor))
+		}
+	}
+	var result []byte
+	for i := range max(layout.dataLens[0], layout.dataLens[1]) {
+		for _, block := range blocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := range layout.ecPerBlock {
+		for _, block := range ecc {
+			result = append(result, block[i])
+		}
+	}
+	return result
+}
+
+// Places codewords in the zigzag order over the modules left free by the function patterns
+func (s *qrSymbol) place(codewords []byte) {
+	i := 0
+	for right := s.size - 1; right >= 1; right -= 2 {
+		if right == 6 { // Skips the vertical timing pattern
+			right = 5
+		}
+		for vertical := range s.size {
+			for j := range 2 {
+				x, y := right-j, vertical
+				if (right+1)&2 == 0 { // Upward column
+					y = s.size - 1 - vertical
+				}
+				if !s.function[y][x] && i < len(codewords)*8 {
+					s.dark[y][x] = codewords[i>>3]>>(7-i&7)&1 != 0
+					i++
+				}
+			}
+		}
+	}
+}
+
+// Inverts the data modules selected by mask
+func (s *qrSymbol) applyMask(mask int) {
+	for y := range s.size {
+		for x := range s.size {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !s.function[y][x] {
+				s.dark[y][x] = !s.dark[y][x]
+			}
+		}
+	}
+}
+
+// Scores runs, blocks, finder-like patterns and dark imbalance, lower being easier to read
+func (s *qrSymbol) penalty() int {
+	penalty, darkCount := 0, 0
+	module := func(x, y int, vertical bool) bool {
+		if vertical {
+			return s.dark[x][y]
+		}
+		return s.dark[y][x]
+	}
+	for _, vertical := range []bool{false, true} {
+		for y := range s.size {
+			run := 0
+			for x := range s.size {
+				if x > 0 && module(x, y, vertical) == module(x-1, y, vertical) {
+					run++
+				} else {
+					run = 1
+				}
+				if run == 5 {
+					penalty += 3
+				} else if run > 5 {
+					penalty++
+				}
+				if x >= 10 { // 1011101 with four light modules on either side
+					pattern := [11]bool{}
+					for k := range 11 {
+						pattern[k] = module(x-10+k, y, vertical)
+					}
+					if pattern == [11]bool{true, false, true, true, true, false, true, false, false, false, false} ||
+						pattern == [11]bool{false, false, false, false, true, false, true, true, true, false, true} {
+						penalty += 40
+					}
+				}
+			}
+		}
+	}
+	for y := range s.size {
+		for x := range s.size {
+			if s.dark[y][x] {
+				darkCount++
+			}
+			if x > 0 && y > 0 && s.dark[y][x] == s.dark[y-1][x] && s.dark[y][x] == s.dark[y][x-1] && s.dark[y][x] == s.dark[y-1][x-1] {
+				penalty += 3
+			}
+		}
+	}
+	percent := darkCount * 100 / (s.size * s.size)
+	return penalty + abs(percent-50)/5*10
+}
+
+// Returns the generator polynomial of degree codewords, highest coefficient first and the leading 1 omitted
+func reedSolomonDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for range degree {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+// Returns the error correction codewords of data
+func reedSolomonRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i := range result {
+			result[i] ^= gfMultiply(divisor[i], factor)
+		}
+	}
+	return result
+}
+
+// Multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1
+func gfMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ (z>>7)*0x11D
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
+
+// Returns the absolute value of n
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
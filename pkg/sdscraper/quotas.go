@@ -0,0 +1,145 @@
+package sdscraper
+
+import ( // Import required packages
+	"fmt"       // For refusal messages
+	"log/slog"  // For structured logging
+	"net/http"  // For the middleware
+	"net/netip" // For client addresses
+	"strconv"   // For Retry-After
+	"sync"      // For guarding the per-client state
+	"time"      // For idle expiry
+)
+
+// PublicLimits protects a mirror exposed beyond the LAN; the zero value limits nothing. Requests carrying the admin
+// token are exempt from the per-client limits and the body size limit, but still count towards MaxConcurrent.
+type PublicLimits struct {
+	PerClientRate       float64 // Requests per second each client address may make on average, zero for unlimited
+	PerClientBurst      int     // Requests a client may make back to back before PerClientRate applies
+	PerClientConcurrent int     // Requests a client may have in flight, zero for unlimited
+	MaxConcurrent       int     // Requests in flight over all clients, zero for unlimited
+	MaxBodyBytes        int64   // Largest request body accepted, zero for the handlers' own limits only
+}
+
+// IsZero reports whether the limits limit nothing
+func (l PublicLimits) IsZero() bool {
+	return l == PublicLimits{}
+}
+
+// Reasons a request is refused, as the metrics label them
+var limitReasons = []string{"rate", "client_concurrency", "concurrency", "body_size", "allowlist"}
+
+const clientIdleExpiry = 10 * time.Minute // Per-client state kept this long after a client's last request
+
+// State of one client address
+type clientQuota struct {
+	bucket   *RateLimiter
+	inFlight int
+	lastSeen time.Time
+}
+
+// Applies PublicLimits in front of a handler
+type quotaHandler struct {
+	limits  PublicLimits
+	next    http.Handler
+	isAdmin func(*http.Request) bool
+	resolve clientResolver
+	metrics *Metrics
+	slots   chan struct{} // One per request in flight, nil for no cap
+	mu      sync.Mutex    // Guards clients and swept
+	clients map[string]*clientQuota
+	swept   time.Time // When idle clients were last dropped
+}
+
+// Wraps next in the limits
+func newQuotaHandler(limits PublicLimits, next http.Handler, isAdmin func(*http.Request) bool, resolve clientResolver, metrics *Metrics) *quotaHandler {
+	h := &quotaHandler{limits: limits, next: next, isAdmin: isAdmin, resolve: resolve, metrics: metrics, clients: make(map[string]*clientQuota), swept: time.Now()}
+	if limits.MaxConcurrent > 0 {
+		h.slots = make(chan struct{}, limits.MaxConcurrent)
+	}
+	return h
+}
+
+// ServeHTTP implements http.Handler
+func (h *quotaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.slots != nil {
+		select {
+		case h.slots <- struct{}{}:
+			defer func() { <-h.slots }()
+		default: // Busy for everyone; shedding load keeps the mirror answering
+			h.refuse(w, r, "concurrency", http.StatusServiceUnavailable, time.Second, "server busy, retry shortly")
+			return
+		}
+	}
+	if h.isAdmin(r) {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	if h.limits.MaxBodyBytes > 0 {
+		if r.ContentLength > h.limits.MaxBodyBytes {
+			h.refuse(w, r, "body_size", http.StatusRequestEntityTooLarge, 0, fmt.Sprintf("request body must be at most %d bytes", h.limits.MaxBodyBytes))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, h.limits.MaxBodyBytes) // Bodies sent without a length
+	}
+
+	client := h.clientAddress(r)
+	h.mu.Lock()
+	h.sweep()
+	quota, ok := h.clients[client]
+	if !ok {
+		quota = &clientQuota{bucket: NewRateLimiter(h.limits.PerClientRate, 0, max(h.limits.PerClientBurst, 1))}
+		h.clients[client] = quota
+	}
+	quota.lastSeen = time.Now()
+	if h.limits.PerClientConcurrent > 0 && quota.inFlight >= h.limits.PerClientConcurrent {
+		h.mu.Unlock()
+		h.refuse(w, r, "client_concurrency", http.StatusTooManyRequests, time.Second, "too many concurrent requests from this address")
+		return
+	}
+	allowed, retryAfter := quota.bucket.take()
+	if !allowed {
+		h.mu.Unlock()
+		h.refuse(w, r, "rate", http.StatusTooManyRequests, retryAfter, "request rate limit exceeded")
+		return
+	}
+	quota.inFlight++
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		quota.inFlight--
+		h.mu.Unlock()
+	}()
+	h.next.ServeHTTP(w, r)
+}
+
+// Answers a refused request, telling the client when to retry
+func (h *quotaHandler) refuse(w http.ResponseWriter, r *http.Request, reason string, status int, retryAfter time.Duration, message string) {
+	h.metrics.observeLimited(reason)
+	slog.Debug("Refused request", "reason", reason, "client", h.clientAddress(r), "path", r.URL.Path)
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(max(1, retryAfter.Round(time.Second)/time.Second))))
+	}
+	http.Error(w, message, status)
+}
+
+// Drops clients idle for clientIdleExpiry, at most once a minute; callers hold h.mu
+func (h *quotaHandler) sweep() {
+	if time.Since(h.swept) < time.Minute {
+		return
+	}
+	h.swept = time.Now()
+	for client, quota := range h.clients {
+		if quota.inFlight == 0 && time.Since(quota.lastSeen) > clientIdleExpiry {
+			delete(h.clients, client)
+		}
+	}
+}
+
+// Returns the address a request is limited by; IPv6 addresses count per /64, as one host commonly holds a whole prefix
+func (h *quotaHandler) clientAddress(r *http.Request) string {
+	address := h.resolve.address(r)
+	if address.Is6() {
+		return netip.PrefixFrom(address, 64).Masked().String()
+	}
+	return address.String()
+}
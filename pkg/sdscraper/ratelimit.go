@@ -0,0 +1,105 @@
+package sdscraper
+
+import ( // Import required packages
+	"context" // For cancelling waits
+	"fmt"     // For parse errors
+	"strconv" // For parsing rates
+	"strings" // For splitting rates
+	"sync"    // For sharing the bucket between workers
+	"time"    // For refill timing
+)
+
+// RateLimiter is a token bucket shared by every request that goes through it
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration // Time to earn one token
+	burst    float64       // Bucket capacity
+	tokens   float64       // Tokens available at last
+	last     time.Time     // When tokens was last brought up to date
+}
+
+// NewRateLimiter allows perSecond requests per second on average with bursts of up to burst requests;
+// delay raises the spacing to at least one request per delay
+func NewRateLimiter(perSecond float64, delay time.Duration, burst int) *RateLimiter {
+	var interval time.Duration
+	if perSecond > 0 {
+		interval = time.Duration(float64(time.Second) / perSecond)
+	}
+	interval = max(interval, delay)
+	return &RateLimiter{interval: interval, burst: float64(max(burst, 1)), tokens: float64(max(burst, 1))}
+}
+
+// Wait blocks until a request may be sent or ctx is done
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil || l.interval <= 0 { // Unlimited
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if !l.last.IsZero() { // Refill for the time since the last request
+		l.tokens = min(l.burst, l.tokens+float64(now.Sub(l.last))/float64(l.interval))
+	}
+	l.last = now
+	l.tokens-- // Reserve a token, possibly going into debt
+	wait := time.Duration(-l.tokens * float64(l.interval))
+	l.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens++ // Give the reservation back
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Takes a token if one is available now, otherwise reporting how long until one is
+func (l *RateLimiter) take() (bool, time.Duration) {
+	if l == nil || l.interval <= 0 {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if !l.last.IsZero() {
+		l.tokens = min(l.burst, l.tokens+float64(now.Sub(l.last))/float64(l.interval))
+	}
+	l.last = now
+	if l.tokens < 1 { // Refused requests never go into debt, so a client backing off recovers
+		return false, time.Duration((1 - l.tokens) * float64(l.interval))
+	}
+	l.tokens--
+	return true, 0
+}
+
+// ParseRate reads a rate such as "2/s", "30/m" or "1000/h"; a bare number is per second and "" or "0" is unlimited
+func ParseRate(value string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	count, unit, hasUnit := strings.Cut(value, "/")
+	perUnit := time.Second
+	if hasUnit {
+		switch unit {
+		case "s":
+		case "m":
+			perUnit = time.Minute
+		case "h":
+			perUnit = time.Hour
+		default:
+			return 0, fmt.Errorf("rate %q: unit must be s, m or h", value)
+		}
+	}
+	n, err := strconv.ParseFloat(count, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("rate %q: want a non-negative number of requests", value)
+	}
+	return n / perUnit.Seconds(), nil
+}
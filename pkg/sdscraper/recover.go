@@ -0,0 +1,41 @@
+package sdscraper
+
+import ( // Import required packages
+	"fmt"           // For error messages
+	"log/slog"      // For structured logging
+	"runtime/debug" // For capturing stacks
+)
+
+// PanicError records a panic recovered in one stage of a run, so the rest of the run can proceed
+type PanicError struct {
+	Stage string // Where it happened, e.g. "fetch" or "store"
+	URL   string // Document being processed, empty for stages not tied to one
+	Value any    // What was passed to panic
+	Stack string // Goroutine stack at the panic
+}
+
+// Error implements error
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic in %s: %v", e.Stage, e.Value)
+}
+
+// Recovers a panic in the calling goroutine, logging it and handing it to report; use as `defer recoverPanic(...)`
+func recoverPanic(stage, documentURL string, report func(*PanicError)) {
+	value := recover()
+	if value == nil {
+		return
+	}
+	recovered := &PanicError{Stage: stage, URL: documentURL, Value: value, Stack: string(debug.Stack())}
+	slog.Error("Recovered from panic", "stage", stage, "url", documentURL, "panic", value, "stack", recovered.Stack)
+	if report != nil {
+		report(recovered)
+	}
+}
+
+// Files a document whose processing panicked as failed
+func (r *runState) failed(s *Scraper, recovered *PanicError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.result.Failed[recovered.URL] = recovered
+	r.finish(s, recovered.URL)
+}
@@ -0,0 +1,186 @@
+package sdscraper
+
+import ( // Import required packages
+	"context"       // For the logging handler
+	"encoding/json" // For quoting redacted JSON strings
+	"fmt"           // For formatting values before redaction
+	"log/slog"      // For the logging handler
+	"regexp"        // For the rules
+	"strings"       // For building redacted text
+)
+
+// Redacted stands in for whatever a redaction rule removed
+const Redacted = "REDACTED"
+
+// RedactionRule removes what Pattern matches; a pattern with a capture group only removes the group's text, so
+// `session=([^&]+)` keeps the parameter name
+type RedactionRule struct {
+	Name    string         // Shown when listing rules, e.g. "bearer-token"
+	Pattern *regexp.Regexp // What to remove
+}
+
+// DefaultRedactionRules returns the built-in rules: bearer and basic credentials, passwords in URLs, secret-looking
+// query parameters and assignments, cookie headers, JWTs, AWS access key IDs and Slack webhook paths
+func DefaultRedactionRules() []RedactionRule {
+	return []RedactionRule{
+		{"bearer-token", regexp.MustCompile(`(?i)\b(?:bearer|basic)\s+([A-Za-z0-9._~+/=-]{8,})`)},
+		{"url-credentials", regexp.MustCompile(`(?i)\b[a-z][a-z0-9+.-]*://([^\s/:@"]+:[^\s/@"]+)@`)},
+		{"query-secret", regexp.MustCompile(`(?i)[?&;](?:[a-z0-9]+[_-])*(?:token|key|apikey|secret|password|passwd|signature|sig|session|sessionid|sid|auth|credential)(?:[_-][a-z0-9]+)*=([^&\s"#]+)`)},
+		{"secret-assignment", regexp.MustCompile(`(?i)\b(?:[a-z0-9]+[_-])*(?:password|passwd|secret|token|api[_-]?key)\s*[=:]\s*([^\s",;&]+)`)},
+		{"cookie-header", regexp.MustCompile(`(?i)\b(?:set-)?cookie:\s*([^\r\n"]+)`)},
+		{"jwt", regexp.MustCompile(`\b(eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,})`)},
+		{"aws-access-key", regexp.MustCompile(`\b((?:AKIA|ASIA)[A-Z0-9]{16})\b`)},
+		{"slack-webhook", regexp.MustCompile(`https://hooks\.slack\.com/services/([A-Za-z0-9/]+)`)},
+	}
+}
+
+// HostRedactionRule returns a rule removing suffix and every host name under it, e.g. internal.example.com
+func HostRedactionRule(suffix string) RedactionRule {
+	suffix = strings.Trim(strings.TrimSpace(suffix), ".")
+	return RedactionRule{Name: "host " + suffix, Pattern: regexp.MustCompile(`(?i)\b((?:[a-z0-9-]+\.)*` + regexp.QuoteMeta(suffix) + `)\b`)}
+}
+
+// ParseRedactionRule compiles a rule given as a regular expression
+func ParseRedactionRule(pattern string) (RedactionRule, error) {
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return RedactionRule{}, fmt.Errorf("redaction rule %q: %w", pattern, err)
+	}
+	return RedactionRule{Name: pattern, Pattern: compiled}, nil
+}
+
+// Returns text with the rule's matches removed
+func (r RedactionRule) apply(text string) string {
+	if r.Pattern.NumSubexp() == 0 {
+		return r.Pattern.ReplaceAllLiteralString(text, Redacted)
+	}
+	matches := r.Pattern.FindAllStringSubmatchIndex(text, -1)
+	if matches == nil {
+		return text
+	}
+	var out strings.Builder
+	last := 0
+	for _, match := range matches {
+		if match[2] < 0 || match[2] < last { // Group did not take part
+			continue
+		}
+		out.WriteString(text[last:match[2]])
+		out.WriteString(Redacted)
+		last = match[3]
+	}
+	out.WriteString(text[last:])
+	return out.String()
+}
+
+// Redactor applies redaction rules to logs, reports and support bundles so they can be shared outside the
+// security boundary; a nil Redactor leaves everything as it is
+type Redactor struct {
+	Rules []RedactionRule
+}
+
+// String returns text with every rule applied in order
+func (r *Redactor) String(text string) string {
+	if r == nil {
+		return text
+	}
+	for _, rule := range r.Rules {
+		text = rule.apply(text)
+	}
+	return text
+}
+
+var jsonString = regexp.MustCompile(`"(?:[^"\\]|\\.)*"`) // One JSON string literal
+
+// JSON returns a JSON document with the rules applied inside its strings only, so the result stays valid JSON
+// whatever the rules match
+func (r *Redactor) JSON(data []byte) []byte {
+	if r == nil || len(r.Rules) == 0 {
+		return data
+	}
+	return jsonString.ReplaceAllFunc(data, func(literal []byte) []byte {
+		var text string
+		if json.Unmarshal(literal, &text) != nil { // Not a string after all, e.g. inside a key's escapes
+			return literal
+		}
+		redacted := r.String(text)
+		if redacted == text {
+			return literal
+		}
+		quoted, _ := json.Marshal(redacted) // Never fails for a string
+		return quoted
+	})
+}
+
+// Handler wraps a logging handler so messages and attribute values are redacted before they are written
+func (r *Redactor) Handler(handler slog.Handler) slog.Handler {
+	if r == nil || len(r.Rules) == 0 {
+		return handler
+	}
+	return &redactingHandler{handler: handler, redactor: r}
+}
+
+// A slog.Handler applying a Redactor
+type redactingHandler struct {
+	handler  slog.Handler
+	redactor *Redactor
+}
+
+// Enabled implements slog.Handler
+func (h *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler
+func (h *redactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, h.redactor.String(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(h.attr(attr))
+		return true
+	})
+	return h.handler.Handle(ctx, redacted)
+}
+
+// WithAttrs implements slog.Handler
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = h.attr(attr)
+	}
+	return &redactingHandler{handler: h.handler.WithAttrs(redacted), redactor: h.redactor}
+}
+
+// WithGroup implements slog.Handler
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{handler: h.handler.WithGroup(name), redactor: h.redactor}
+}
+
+// Returns attr with its value redacted; values that are not text keep their type unless redaction changed them
+func (h *redactingHandler) attr(attr slog.Attr) slog.Attr {
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, h.text(attr.Key, value.String()))
+	case slog.KindGroup:
+		group := value.Group()
+		redacted := make([]any, len(group))
+		for i, member := range group {
+			redacted[i] = h.attr(member)
+		}
+		return slog.Group(attr.Key, redacted...)
+	case slog.KindAny:
+		text := fmt.Sprint(value.Any()) // Errors, URLs and slices
+		if redacted := h.text(attr.Key, text); redacted != text {
+			return slog.String(attr.Key, redacted)
+		}
+	}
+	return slog.Attr{Key: attr.Key, Value: value}
+}
+
+// Redacts an attribute's text, seen together with its key so rules for "password=..." apply to a password attribute
+func (h *redactingHandler) text(key, text string) string {
+	assignment := key + "=" + text
+	if redacted := h.redactor.String(assignment); redacted != assignment && strings.HasPrefix(redacted, key+"=") {
+		return strings.TrimPrefix(redacted, key+"=")
+	}
+	return h.redactor.String(text)
+}
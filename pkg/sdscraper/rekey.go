@@ -0,0 +1,419 @@
+package sdscraper
+
+import ( // Import required packages
+	"bytes"         // For comparing read-back digests
+	"cmp"           // For names of plaintext files
+	"context"       // For stopping between files
+	"crypto/cipher" // For the old and new ciphers
+	"crypto/sha256" // For verifying plaintext against the manifest
+	"encoding/hex"  // For comparing with recorded hashes
+	"encoding/json" // For reading the manifest
+	"errors"        // For missing files
+	"fmt"           // For problem details
+	"io"            // For streaming files through the ciphers
+	"io/fs"         // For walking backups and trash
+	"log/slog"      // For progress
+	"os"            // For reading files
+	"path"          // For names of stored documents
+	"path/filepath" // For OS-independent path operations
+	"strings"       // For re-reading a legacy marker
+	"time"          // For progress intervals
+)
+
+// Kinds of problem only Rekey reports, on top of Fsck's
+const (
+	ProblemUndecryptable = "undecryptable" // File neither the old nor the new key opens
+	ProblemRekeyFailed   = "rekey-failed"  // Rekeyed copy could not be written or verified
+)
+
+// How often long file-by-file jobs such as Rekey log their progress
+const progressLogEvery = 5 * time.Second
+
+// RekeyOptions says what Rekey re-encrypts; the process key from SetEncryptionKey is the old key, and without one
+// the archive is taken to be plaintext and is encrypted for the first time
+type RekeyOptions struct {
+	OutputDir    string  // Directory holding downloaded documents, when Storage is nil
+	Storage      Storage // Where the documents are kept instead, e.g. an S3 bucket
+	SpoolDir     string  // Where documents from Storage are rekeyed one at a time, empty for os.TempDir()
+	ManifestPath string  // Manifest, rekeyed last
+	BackupDir    string  // Pre-run backups of the manifest, empty to leave them
+	TrashDir     string  // Trashed files, empty to leave them
+	SnapshotDir  string  // Catalog snapshots, empty to leave them
+	NewKey       []byte  // Key to re-encrypt under, nil to decrypt the archive back to plaintext
+}
+
+// RekeyResult counts what Rekey did
+type RekeyResult struct {
+	Rekeyed  int       `json:"rekeyed"`  // Files rewritten under the new key
+	Already  int       `json:"already"`  // Files under the new key already, e.g. from an interrupted run
+	Bytes    int64     `json:"bytes"`    // Plaintext bytes rewritten
+	Problems []Problem `json:"problems"` // Files left as they were
+}
+
+// Rekey re-encrypts the archive under a new key one file at a time: each document is decrypted, checked against its
+// manifest hash, sealed under the new key, read back and verified, and only then swapped in place of the old file,
+// in the output directory or the configured storage. Files already under the new key are skipped, so an interrupted
+// run is resumed by running it again with the same keys. The manifest goes last and is read under either key, but
+// a rewritten document opens only under the new one: until the run completes the archive is readable by rekey
+// alone. Sealed files in the backup, trash and snapshot directories are rekeyed too; earlier versions kept by a
+// versioned bucket stay under the old key. Stop serving and syncing first, as a sync meanwhile writes with the old key
+func Rekey(ctx context.Context, options RekeyOptions) (*RekeyResult, error) {
+	var next cipher.AEAD
+	if options.NewKey != nil {
+		var err error
+		if next, err = newAEAD(options.NewKey); err != nil {
+			return nil, err
+		}
+	}
+	rk := &rekeyer{old: processAEAD(), new: next, result: &RekeyResult{}, lastLog: time.Now()}
+	manifestData, err := rk.read(options.ManifestPath, filepath.Base(options.ManifestPath)) // Under either key, depending on how far an earlier run got
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", options.ManifestPath, err)
+	}
+	manifest := NewManifest()
+	if err := json.Unmarshal(manifestData, manifest); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", options.ManifestPath, err)
+	}
+
+	var documents []string // One per file; aliases share their original's
+	seen := make(map[string]bool)
+	for _, documentURL := range sortedKeys(manifest.Documents) {
+		entry := manifest.Documents[documentURL]
+		if entry.Filename != "" && !entry.DownloadedAt.IsZero() && !seen[entry.Filename] { // As Fsck, skipping failed downloads
+			seen[entry.Filename] = true
+			documents = append(documents, documentURL)
+		}
+	}
+	rk.total = len(documents)
+	for _, documentURL := range documents {
+		if err := ctx.Err(); err != nil {
+			return rk.result, err
+		}
+		entry := manifest.Documents[documentURL]
+		if options.Storage != nil {
+			rk.stored(ctx, options.Storage, options.SpoolDir, documentURL, entry.Filename, entry.SHA256)
+			continue
+		}
+		path := filepath.Join(options.OutputDir, entry.Filename)
+		rk.file(path, documentURL, entry.Filename, filepath.Base(path), entry.SHA256, true)
+	}
+	for _, dir := range []string{options.BackupDir, options.TrashDir, options.SnapshotDir} {
+		if dir == "" {
+			continue
+		}
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			rk.file(path, "", path, "", "", false) // Only sealed copies, under the names they carry; plaintext backups stay as they are
+			return nil
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return rk.result, err
+		}
+	}
+	if len(rk.result.Problems) > 0 { // Keep the old manifest key until every document is across
+		return rk.result, nil
+	}
+	rk.file(options.ManifestPath, "", filepath.Base(options.ManifestPath), filepath.Base(options.ManifestPath), "", true)
+	slog.Info("Rekey finished", "rekeyed", rk.result.Rekeyed, "already", rk.result.Already, "bytes", rk.result.Bytes)
+	return rk.result, nil
+}
+
+// Rekeys files one at a time, counting and logging as it goes
+type rekeyer struct {
+	old, new cipher.AEAD // Nil for plaintext
+	result   *RekeyResult
+	total    int // Catalogued files, for progress
+	lastLog  time.Time
+}
+
+// Reads the whole plaintext of the file at path, sealed for name, under the new key or, failing that, the old one
+func (rk *rekeyer) read(path, name string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	source, already, sealName, err := rk.open(file, name, true)
+	if err == nil && source == nil && already { // Under the new key; open it again with that
+		if _, err = file.Seek(0, io.SeekStart); err == nil {
+			source, err = openReaderWith(rk.new, file, sealName)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(source)
+}
+
+// Opens file, sealed for name (empty for the name it carries), returning its plaintext, whether it is as the new
+// key would write it already, and the name to seal it for; plaintext files are only taken when catalogued, as a
+// nil source says. Files sealed whole by earlier releases are opened in memory under the old key
+func (rk *rekeyer) open(file *os.File, name string, catalogued bool) (source io.Reader, already bool, sealName string, err error) {
+	header, peeked, err := readSealHeader(file)
+	switch {
+	case errors.Is(err, errLegacySealed):
+		data, err := io.ReadAll(io.MultiReader(strings.NewReader(legacyMagic), file))
+		if err != nil {
+			return nil, false, "", err
+		}
+		plain, err := openLegacy(rk.old, data)
+		return bytes.NewReader(plain), false, cmp.Or(name, filepath.Base(file.Name())), err
+	case err != nil:
+		return nil, false, "", err
+	case header == nil && !catalogued:
+		return nil, false, "", nil
+	case header == nil:
+		return io.MultiReader(bytes.NewReader(peeked), file), rk.new == nil, cmp.Or(name, filepath.Base(file.Name())), nil
+	}
+	if err := header.check(name); err != nil {
+		return nil, false, "", err
+	}
+	if rk.new != nil && rk.opens(file, rk.new, header.name) {
+		return nil, true, header.name, nil
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, false, "", err
+	}
+	source, err = openReaderWith(rk.old, file, header.name)
+	return source, false, header.name, err
+}
+
+// Reports whether aead opens every chunk of file, sealed for name, rewinding it first
+func (rk *rekeyer) opens(file *os.File, aead cipher.AEAD, name string) bool {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return false
+	}
+	source, err := openReaderWith(aead, file, name)
+	if err == nil {
+		_, err = io.Copy(io.Discard, source)
+	}
+	return err == nil
+}
+
+// Returns the plaintext of data sealed whole with aead by an earlier release
+func openLegacy(aead cipher.AEAD, data []byte) ([]byte, error) {
+	if aead == nil {
+		return nil, ErrNoEncryptionKey
+	}
+	data = data[len(legacyMagic):]
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("sealed file is truncated")
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, []byte(legacyMagic))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: wrong encryption key or damaged file: %w", err)
+	}
+	return plain, nil
+}
+
+// Records a file left as it was
+func (rk *rekeyer) problem(problem Problem) {
+	slog.Warn("Rekey skipped a file", "file", problem.Filename, "kind", problem.Kind, "detail", problem.Detail)
+	rk.result.Problems = append(rk.result.Problems, problem)
+}
+
+// Rewrites the file at path, shown as label and sealed for name (empty for the name it carries), under the new
+// key; plaintext files are only taken when catalogued, and a recorded hash must match the plaintext
+func (rk *rekeyer) file(path, documentURL, label, name, sum string, catalogued bool) {
+	rk.rewrite(path, documentURL, label, name, sum, catalogued, func(tempPath string) error {
+		info, err := os.Stat(path)
+		if err == nil {
+			err = changeTimes(tempPath, info.ModTime(), info.ModTime()) // Keeps the file's times
+		}
+		if err == nil {
+			err = renameFile(tempPath, path)
+		}
+		return err
+	})
+}
+
+// Rewrites the document stored as name in storage under the new key, through a copy in spoolDir: it is checked
+// there like a local file and only then stored over the original
+func (rk *rekeyer) stored(ctx context.Context, storage Storage, spoolDir, documentURL, name, sum string) {
+	local, err := rk.spool(ctx, storage, spoolDir, name)
+	if err != nil {
+		rk.problem(Problem{Kind: ProblemMissingFile, URL: documentURL, Filename: name, Detail: err.Error(), Fix: "run fsck"})
+		return
+	}
+	defer removeFile(local)
+	rk.rewrite(local, documentURL, name, path.Base(name), sum, true, func(tempPath string) error {
+		defer removeFile(tempPath)
+		sealed, err := os.Open(tempPath)
+		if err != nil {
+			return err
+		}
+		defer sealed.Close()
+		return storage.Put(ctx, name, sealed) // As stored, so the storage must not seal it again
+	})
+}
+
+// Copies the document stored as name, as stored, into a new file in spoolDir, returning its path
+func (rk *rekeyer) spool(ctx context.Context, storage Storage, spoolDir, name string) (string, error) {
+	dir := spoolDir
+	if dir == "" {
+		dir = os.TempDir()
+	} else if err := makeDirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	source, err := storage.Open(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	defer source.Close()
+	spooled, err := createTemp(dir, "gojo-rekey-*")
+	if err != nil {
+		return "", fmt.Errorf("create rekey spool file: %w", err)
+	}
+	_, err = io.Copy(spooled, source)
+	if closeErr := spooled.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		removeFile(spooled.Name())
+		return "", err
+	}
+	return spooled.Name(), nil
+}
+
+// Rewrites the file at path as file does, handing the verified copy to install to put in its place
+func (rk *rekeyer) rewrite(path, documentURL, label, name, sum string, catalogued bool, install func(tempPath string) error) {
+	file, err := os.Open(path)
+	if err != nil {
+		rk.problem(Problem{Kind: ProblemMissingFile, URL: documentURL, Filename: label, Detail: err.Error(), Fix: "run fsck"})
+		return
+	}
+	defer file.Close()
+	source, already, sealName, err := rk.open(file, name, catalogued)
+	switch {
+	case err != nil:
+		rk.problem(Problem{Kind: ProblemUndecryptable, URL: documentURL, Filename: label, Detail: err.Error(),
+			Fix: "rerun with the key the file was written with, or restore it from a backup"})
+		return
+	case already:
+		rk.result.Already++
+		return
+	case source == nil:
+		return
+	}
+	size, err := rk.replace(path, sealName, sum, &readErrors{Reader: source}, install)
+	var unreadable *readErrors
+	switch {
+	case errors.As(err, &unreadable):
+		rk.problem(Problem{Kind: ProblemUndecryptable, URL: documentURL, Filename: label, Detail: unreadable.err.Error(),
+			Fix: "rerun with the key the file was written with, or restore it from a backup"})
+		return
+	case errors.Is(err, errRekeyHash):
+		rk.problem(Problem{Kind: ProblemHashMismatch, URL: documentURL, Filename: label,
+			Detail: "plaintext differs from the recorded hash; not rewritten", Fix: "run fsck -hashes and repair the file first"})
+		return
+	case err != nil:
+		rk.problem(Problem{Kind: ProblemRekeyFailed, URL: documentURL, Filename: label, Detail: err.Error(),
+			Fix: "check the file can be rewritten and rerun"})
+		return
+	}
+	rk.result.Rekeyed++
+	rk.result.Bytes += size
+	if time.Since(rk.lastLog) >= progressLogEvery {
+		rk.lastLog = time.Now()
+		slog.Info("Rekeying", "rekeyed", rk.result.Rekeyed, "already", rk.result.Already, "documents", rk.total, "bytes", rk.result.Bytes, "file", label)
+	}
+}
+
+var errRekeyHash = errors.New("plaintext differs from the recorded hash")
+
+// Wraps a reader, remembering why it failed, so a file that cannot be decrypted is told apart from one that cannot
+// be written
+type readErrors struct {
+	io.Reader
+	err error
+}
+
+// Read implements io.Reader
+func (r *readErrors) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		r.err = err
+		return n, r
+	}
+	return n, err
+}
+
+// Error implements error
+func (r *readErrors) Error() string { return r.err.Error() }
+
+// Streams plain, sealed for name under the new key, next to path, checks it against sum and what landed on disk
+// against plain, and has install swap it in; returns the plaintext size
+func (rk *rekeyer) replace(path, name, sum string, plain io.Reader, install func(tempPath string) error) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	tempPath := path + ".rekey"
+	size, digest, err := rk.write(tempPath, name, info.Mode().Perm(), plain)
+	if err == nil && sum != "" && hex.EncodeToString(digest) != sum {
+		err = errRekeyHash
+	}
+	if err == nil {
+		var check []byte
+		if check, err = rk.digest(tempPath, name); err == nil && !bytes.Equal(check, digest) {
+			err = errors.New("verify rekeyed copy: read-back differs from the plaintext")
+		}
+	}
+	if err == nil {
+		err = install(tempPath)
+	}
+	if err != nil {
+		removeFile(tempPath)
+		return 0, err
+	}
+	return size, nil
+}
+
+// Writes plain to path sealed for name under the new key, returning its size and SHA-256
+func (rk *rekeyer) write(path, name string, mode os.FileMode, plain io.Reader) (int64, []byte, error) {
+	if err := CheckWritable("write", path); err != nil {
+		return 0, nil, err
+	}
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer out.Close()
+	writer, err := sealWriterWith(rk.new, out, name)
+	if err != nil {
+		return 0, nil, err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(writer, io.TeeReader(plain, hash))
+	if err == nil {
+		err = writer.Close()
+	}
+	if err == nil {
+		err = out.Close()
+	}
+	return size, hash.Sum(nil), err
+}
+
+// Returns the SHA-256 of the plaintext of the file at path, sealed for name under the new key
+func (rk *rekeyer) digest(path, name string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	opened, err := openSealedFile(file, rk.new, name)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, opened); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
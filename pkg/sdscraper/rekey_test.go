@@ -0,0 +1,101 @@
+package sdscraper
+
+import ( // Import required packages
+	"bytes"         // For keys and stored content
+	"context"       // For the storage calls
+	"crypto/sha256" // For the recorded hash
+	"encoding/hex"  // For hex digests
+	"io"            // For reading stored documents
+	"io/fs"         // For absent objects
+	"path/filepath" // For the manifest path
+	"sync"          // For guarding the objects
+	"testing"       // For the test harness
+	"time"          // For download times
+)
+
+// Keeps objects in memory, standing in for a bucket
+type memoryStorage struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+// Stat implements Storage
+func (m *memoryStorage) Stat(_ context.Context, name string) (StoredFile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[name]
+	if !ok {
+		return StoredFile{}, fs.ErrNotExist
+	}
+	return StoredFile{Size: int64(len(data))}, nil
+}
+
+// Put implements Storage
+func (m *memoryStorage) Put(_ context.Context, name string, content io.Reader) error {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[name] = data
+	return nil
+}
+
+// Open implements Storage
+func (m *memoryStorage) Open(_ context.Context, name string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[name]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// Delete implements Storage
+func (m *memoryStorage) Delete(_ context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, name)
+	return nil
+}
+
+func TestRekeyStorage(t *testing.T) {
+	useKey(t, 1)
+	ctx := context.Background()
+	storage := &memoryStorage{objects: make(map[string][]byte)}
+	plain := content(sealChunk + 10)
+	if err := sealStorage(storage, "").Put(ctx, "docx/sheet.docx", bytes.NewReader(plain)); err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(plain)
+	manifest := NewManifest()
+	manifest.Documents["https://www.gojo.com/sheet"] = &ManifestEntry{Filename: "docx/sheet.docx", SHA256: hex.EncodeToString(digest[:]), DownloadedAt: time.Now()}
+	manifestPath := filepath.Join(t.TempDir(), "manifest.json")
+	if err := SaveManifest(manifestPath, manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	options := RekeyOptions{ManifestPath: manifestPath, Storage: storage, SpoolDir: t.TempDir(), NewKey: bytes.Repeat([]byte{2}, 32)}
+	result, err := Rekey(ctx, options)
+	if err != nil || result.Rekeyed != 2 || len(result.Problems) > 0 {
+		t.Fatalf("Rekey = %+v, %v; want the document and the manifest rekeyed", result, err)
+	}
+	if again, err := Rekey(ctx, options); err != nil || again.Rekeyed != 0 || again.Already != 2 {
+		t.Errorf("second Rekey = %+v, %v; want both already done", again, err)
+	}
+
+	useKey(t, 2)
+	stored, err := sealStorage(storage, "").Open(ctx, "docx/sheet.docx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stored.Close()
+	if read, err := io.ReadAll(stored); err != nil || !bytes.Equal(read, plain) {
+		t.Errorf("reading the rekeyed object under the new key: %v, equal %t", err, bytes.Equal(read, plain))
+	}
+	if _, err := LoadManifest(manifestPath); err != nil {
+		t.Errorf("LoadManifest under the new key: %v", err)
+	}
+}
@@ -0,0 +1,243 @@
+package sdscraper
+
+import ( // Import required packages
+	"context"     // For managing context (timeouts, cancellations)
+	"errors"      // For recognising timeouts
+	"fmt"         // For error messages
+	"log/slog"    // For structured logging
+	"net/http"    // For remote endpoint health checks
+	"net/url"     // For deriving the health check URL
+	"strings"     // For string manipulation
+	"sync"        // For sharing the browser
+	"sync/atomic" // For counting blocked requests
+	"time"        // For timeouts
+
+	"github.com/chromedp/cdproto/emulation" // For User-Agent overrides
+	"github.com/chromedp/cdproto/network"   // For extra request headers
+	"github.com/chromedp/chromedp"          // For headless browser automation using Chrome
+)
+
+// Renderer produces the fully rendered HTML of a page
+type Renderer interface {
+	Render(ctx context.Context, pageURL string) (string, error) // Returns the page's outer HTML
+}
+
+// ChromeRenderer renders pages with Chrome driven by chromedp, launching a local binary unless RemoteURL is set;
+// one browser serves consecutive renders and is restarted when it reaches MaxPages or MaxAge
+type ChromeRenderer struct {
+	Headless    bool             // Run Chrome without a visible window
+	Timeout     time.Duration    // Upper bound for one render, zero for five minutes
+	RemoteURL   string           // DevTools endpoint of an already running Chrome, e.g. ws://host:9222
+	ExecPath    string           // Chrome binary to launch, empty for the first FindChrome finds
+	Interaction Interaction      // Scrolling, "Load more" and pagination steps run before capturing
+	ProxyURL    string           // Proxy for a launched Chrome, e.g. http://proxy:3128 or socks5://proxy:1080
+	UserAgent   string           // Overrides Chrome's User-Agent when set
+	Headers     http.Header      // Extra headers sent with every browser request
+	MaxPages    int              // Restart the browser after it captured this many listing pages, zero for never
+	MaxAge      time.Duration    // Restart the browser once it has been running this long, zero for never
+	Blocking    ResourceBlocking // Images, fonts, trackers and the like the page loads for nothing
+	Login       *BrowserLogin    // Signs the browser in after each start, nil for anonymous browsing
+	Challenges  ChallengePolicy  // What to do when a CAPTCHA or bot check is served instead of the listing
+	mu          sync.Mutex       // Guards session
+	session     *chromeSession
+}
+
+// One running browser shared by consecutive renders
+type chromeSession struct {
+	ctx     context.Context    // Browser context new tabs are opened in
+	cancel  context.CancelFunc // Closes the browser
+	started time.Time          // When it was launched
+	pages   int                // Listing pages captured so far
+}
+
+// Render navigates to pageURL, runs the interaction steps and returns the rendered HTML of every page visited;
+// a render that times out is retried once in a freshly started browser
+func (c *ChromeRenderer) Render(ctx context.Context, pageURL string) (string, error) {
+	slog.Info("Scraping listing", "url", pageURL) // Log page being scraped
+
+	html, err := c.render(ctx, pageURL)
+	if err != nil && ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) { // Wedged browsers stop answering
+		slog.Warn("Chrome stopped responding; restarting it and retrying", "url", pageURL, "err", err)
+		c.Close()
+		html, err = c.render(ctx, pageURL)
+	}
+	return html, err
+}
+
+// Renders pageURL in a new tab of the shared browser
+func (c *ChromeRenderer) render(ctx context.Context, pageURL string) (string, error) {
+	timeout := c.Timeout // Default matches the original five-minute budget
+	if timeout <= 0 {
+		timeout = 5 * time.Minute
+	}
+	timeout += max(0, c.Challenges.Wait) // Waiting for the operator is not a wedged browser
+
+	session, err := c.browser(ctx) // Local or remote browser, launched or restarted as needed
+	if err != nil {
+		return "", err
+	}
+	tabCtx, cancelTab := chromedp.NewContext(session.ctx)             // New tab in the running browser
+	ctxTimeout, cancelTimeout := context.WithTimeout(tabCtx, timeout) // Set timeout
+	stop := context.AfterFunc(ctx, cancelTab)                         // The browser outlives ctx; the tab does not
+
+	defer func() { // Ensure the tab is closed
+		stop()
+		cancelTimeout()
+		cancelTab()
+	}()
+
+	var pages []string       // One HTML snapshot per listing page
+	var blocked atomic.Int64 // Requests refused by the blocking rules
+	started := time.Now()
+	err = chromedp.Run(ctxTimeout,
+		c.identify(),                  // User-Agent and extra headers
+		c.Blocking.action(&blocked),   // Refuse what the listing does not need
+		chromedp.Navigate(pageURL),    // Navigate to the URL
+		c.Challenges.action(pageURL),  // Pause for the operator at a CAPTCHA
+		c.Interaction.actions(&pages), // Scroll, expand and paginate, capturing HTML
+	)
+	c.mu.Lock()
+	session.pages += len(pages)
+	c.mu.Unlock()
+	if err != nil {
+		return "", err // Let the caller decide how to report it
+	}
+	slog.Debug("Rendered listing", "url", pageURL, "pages", len(pages), "blocked_requests", blocked.Load(), "duration", time.Since(started))
+
+	return strings.Join(pages, "\n"), nil // Return scraped HTML
+}
+
+// Returns the running browser, launching it first or replacing it once it outlived MaxPages or MaxAge
+func (c *ChromeRenderer) browser(ctx context.Context) (*chromeSession, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if session := c.session; session != nil {
+		expired := (c.MaxPages > 0 && session.pages >= c.MaxPages) || (c.MaxAge > 0 && time.Since(session.started) >= c.MaxAge)
+		if !expired {
+			return session, nil
+		}
+		slog.Info("Restarting Chrome to keep memory in check", "pages", session.pages, "age", time.Since(session.started).Round(time.Second))
+		session.cancel()
+		c.session = nil
+	}
+
+	allocatorCtx, cancelAllocator, err := c.allocator(ctx) // Local or remote browser
+	if err != nil {
+		return nil, err
+	}
+	browserCtx, cancelBrowser := chromedp.NewContext(allocatorCtx) // Create Chrome context
+	cancel := func() {
+		cancelBrowser()
+		cancelAllocator()
+	}
+	if err := chromedp.Run(browserCtx); err != nil { // Start the browser so later tabs share it
+		cancel()
+		return nil, fmt.Errorf("start Chrome: %w", err)
+	}
+	if c.Login != nil { // Cookies live in the browser, so a restarted one signs in again
+		if err := c.Login.run(ctx, browserCtx, c.identify()); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+	c.session = &chromeSession{ctx: browserCtx, cancel: cancel, started: time.Now()}
+	return c.session, nil
+}
+
+// Close shuts the browser down; the next Render starts a new one
+func (c *ChromeRenderer) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.session != nil {
+		c.session.cancel()
+		c.session = nil
+	}
+	return nil
+}
+
+// Returns an action applying the configured User-Agent and headers to every request the page makes
+func (c *ChromeRenderer) identify() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if c.UserAgent != "" { // Works for launched and remote browsers alike
+			if err := emulation.SetUserAgentOverride(c.UserAgent).Do(ctx); err != nil {
+				return err
+			}
+		}
+		if len(c.Headers) == 0 {
+			return nil
+		}
+		headers := make(network.Headers, len(c.Headers))
+		for name, values := range c.Headers {
+			headers[name] = strings.Join(values, ", ")
+		}
+		if err := network.Enable().Do(ctx); err != nil {
+			return err
+		}
+		return network.SetExtraHTTPHeaders(headers).Do(ctx)
+	})
+}
+
+// Returns an allocator context for the configured browser
+func (c *ChromeRenderer) allocator(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	if c.RemoteURL != "" { // Attach to an existing browser instead of launching one
+		if c.ProxyURL != "" { // Proxy is a launch flag, so the remote browser keeps its own
+			slog.Warn("Proxy is not applied to a remote Chrome; configure it where Chrome runs", "remote", c.RemoteURL)
+		}
+		if err := checkRemoteChrome(ctx, c.RemoteURL); err != nil {
+			return nil, nil, err
+		}
+		allocatorCtx, cancel := chromedp.NewRemoteAllocator(context.WithoutCancel(ctx), c.RemoteURL) // Shared by later renders
+		return allocatorCtx, cancel, nil
+	}
+
+	execPath, err := FindChrome(c.ExecPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	options := append(chromedp.DefaultExecAllocatorOptions[:], // Chrome options
+		chromedp.ExecPath(execPath),                   // Found here rather than by chromedp, which misses ARM Linux installs
+		chromedp.Flag("headless", c.Headless),         // Run visible unless headless was requested
+		chromedp.Flag("disable-gpu", true),            // Disable GPU
+		chromedp.WindowSize(1920, 1080),               // Set window size
+		chromedp.Flag("no-sandbox", true),             // Disable sandbox
+		chromedp.Flag("disable-setuid-sandbox", true), // Fix for Linux environments
+	)
+	if c.ProxyURL != "" {
+		options = append(options, chromedp.ProxyServer(c.ProxyURL)) // Chrome accepts http, https and socks5 proxies
+	}
+	allocatorCtx, cancel := chromedp.NewExecAllocator(context.WithoutCancel(ctx), options...) // Shared by later renders
+	return allocatorCtx, cancel, nil
+}
+
+// Confirms a remote DevTools endpoint answers before handing it to chromedp
+func checkRemoteChrome(ctx context.Context, remoteURL string) error {
+	parsed, err := url.Parse(remoteURL) // Validate the endpoint
+	if err != nil || parsed.Host == "" {
+		return fmt.Errorf("invalid remote Chrome URL %q", remoteURL)
+	}
+	if strings.HasPrefix(parsed.Path, "/devtools/") { // Full debugger URL, nothing to probe
+		return nil
+	}
+
+	scheme := "http" // DevTools serves its metadata over plain HTTP(S)
+	if parsed.Scheme == "wss" || parsed.Scheme == "https" {
+		scheme = "https"
+	}
+	versionURL := scheme + "://" + parsed.Host + "/json/version" // Answered by every DevTools server
+
+	probeCtx, cancel := context.WithTimeout(ctx, 5*time.Second) // Fail fast when the sidecar is down
+	defer cancel()
+	request, err := http.NewRequestWithContext(probeCtx, http.MethodGet, versionURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("remote Chrome at %s is unreachable: %w", parsed.Host, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK { // Reachable, but not obviously DevTools
+		return fmt.Errorf("remote Chrome at %s answered %s on /json/version; is it a DevTools endpoint?", parsed.Host, resp.Status)
+	}
+	return nil
+}
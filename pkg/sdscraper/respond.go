@@ -0,0 +1,97 @@
+package sdscraper
+
+import ( // Import required packages
+	"bytes"         // For serving encoded bodies
+	"compress/gzip" // For gzip responses
+	"crypto/sha256" // For response ETags
+	"encoding/json" // For encoding responses
+	"fmt"           // For formatting ETags
+	"log/slog"      // For structured logging
+	"net/http"      // For the HTTP server
+	"strconv"       // For parsing q-values
+	"strings"       // For parsing Accept-Encoding
+	"time"          // For Last-Modified
+
+	"github.com/klauspost/compress/zstd" // For zstd responses
+)
+
+const minCompressBytes = 1024 // Smaller bodies aren't worth the CPU or the header overhead
+
+// Encodes value as JSON and serves it with an ETag, conditional request handling and negotiated compression
+func writeJSON(w http.ResponseWriter, r *http.Request, value any, modTime time.Time) {
+	body, err := json.Marshal(value) // Encode up front so the ETag covers the exact bytes
+	if err != nil {
+		slog.Error("Encoding response failed", "err", err)
+		http.Error(w, "response unavailable", http.StatusInternalServerError)
+		return
+	}
+
+	etag := fmt.Sprintf("%x", sha256.Sum256(body)) // Identifies this exact document
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Add("Vary", "Accept-Encoding") // Caches must key on the encoding too
+
+	if encoding := negotiateEncoding(r.Header.Get("Accept-Encoding")); encoding != "" && len(body) >= minCompressBytes {
+		compressed, err := compressBody(body, encoding)
+		if err != nil {
+			slog.Warn("Compressing response failed", "encoding", encoding, "err", err) // Fall back to the identity encoding
+		} else {
+			body = compressed
+			etag += "-" + encoding // Each representation needs its own validator
+			w.Header().Set("Content-Encoding", encoding)
+		}
+	}
+
+	w.Header().Set("ETag", `"`+etag+`"`)
+	http.ServeContent(w, r, "", modTime, bytes.NewReader(body)) // Answers 304 when the client is current
+}
+
+// Picks the preferred supported encoding from an Accept-Encoding header, or "" for identity
+func negotiateEncoding(header string) string {
+	best, bestQuality := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		quality := 1.0 // Default when no q-value is given
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				quality = parsed
+			}
+		}
+		if name != "zstd" && name != "gzip" || quality <= 0 {
+			continue // Unsupported or explicitly refused
+		}
+		if quality > bestQuality || quality == bestQuality && name == "zstd" { // zstd wins ties
+			best, bestQuality = name, quality
+		}
+	}
+	return best
+}
+
+// Compresses body with the named encoding
+func compressBody(body []byte, encoding string) ([]byte, error) {
+	var buf bytes.Buffer
+	switch encoding {
+	case "zstd":
+		encoder, err := zstd.NewWriter(&buf)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := encoder.Write(body); err != nil {
+			return nil, err
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, err
+		}
+	case "gzip":
+		encoder := gzip.NewWriter(&buf)
+		if _, err := encoder.Write(body); err != nil {
+			return nil, err
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+	return buf.Bytes(), nil
+}
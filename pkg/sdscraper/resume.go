@@ -0,0 +1,135 @@
+package sdscraper
+
+import ( // Import required packages
+	"cmp"           // For picking the If-Range validator
+	"crypto/sha256" // For verifying kept prefixes
+	"encoding/json" // For the partial transfer sidecar
+	"fmt"           // For error messages and hex digests
+	"io"            // For hashing kept prefixes
+	"log/slog"      // For structured logging
+	"net/http"      // For range headers
+	"os"            // For partial files
+	"path/filepath" // For OS-independent path operations
+	"strconv"       // For parsing Content-Range
+	"strings"       // For parsing Content-Range
+)
+
+const resumeMinBytes = 256 << 10 // Smaller interrupted transfers are cheaper to restart than to track
+
+// What is known about a kept .part file, stored beside it as .part.json
+type partialTransfer struct {
+	URL          string `json:"url"`                     // Document being downloaded
+	ETag         string `json:"etag,omitempty"`          // Version the prefix belongs to
+	LastModified string `json:"last_modified,omitempty"` // Version date when there is no ETag
+	Received     int64  `json:"received"`                // Bytes in the .part file
+	Total        int64  `json:"total"`                   // Full document size, -1 if the server did not say
+	SHA256       string `json:"sha256"`                  // Hash of the kept prefix
+}
+
+// Returns the .part path a kept transfer of filename lives at
+func (d *Downloader) partialPath(filename string) string {
+	return filepath.Join(d.OutputDir, filename) + ".part"
+}
+
+// Loads what is known about a kept prefix of rawURL, discarding it if it is unusable
+func (d *Downloader) loadPartial(rawURL, filename string) *partialTransfer {
+	path := d.partialPath(filename)
+	sidecar, err := os.ReadFile(path + ".json")
+	if err != nil {
+		return nil // Nothing kept
+	}
+	var partial partialTransfer
+	switch {
+	case json.Unmarshal(sidecar, &partial) != nil, partial.URL != rawURL:
+	case !partialIntact(path, &partial):
+		slog.Warn("Kept partial download is damaged; starting over", "url", rawURL, "filename", filename)
+	default:
+		return &partial
+	}
+	d.discardPartial(filename)
+	return nil
+}
+
+// Reports whether the .part file at path still has the size and hash its sidecar recorded
+func partialIntact(path string, partial *partialTransfer) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	hasher := sha256.New()
+	n, err := io.Copy(hasher, file)
+	return err == nil && n == partial.Received && fmt.Sprintf("%x", hasher.Sum(nil)) == partial.SHA256
+}
+
+// Appends a kept prefix to an empty spool
+func (d *Downloader) spoolPartial(filename string, into *spool) error {
+	file, err := os.Open(d.partialPath(filename))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return into.copyFrom(file)
+}
+
+// Keeps the spooled first bytes of an interrupted transfer when the server can send the rest
+func (d *Downloader) keepPartial(rawURL, filename string, header http.Header, received *spool, total int64) bool {
+	etag, lastModified := header.Get("ETag"), header.Get("Last-Modified")
+	if received.size < resumeMinBytes || (etag == "" && lastModified == "") { // Without a validator a resumed body could mix versions
+		return false
+	}
+	if EncryptionEnabled() { // Partial transfers would sit on shared storage in plaintext
+		return false
+	}
+	path := d.partialPath(filename)
+	if err := makeDirAll(filepath.Dir(path), 0o755); err != nil {
+		return false
+	}
+	sidecar, _ := json.Marshal(partialTransfer{
+		URL: rawURL, ETag: etag, LastModified: lastModified,
+		Received: received.size, Total: total, SHA256: received.sum(),
+	})
+	if writePartThenRename(path, received.reader()) != nil || writeFile(path+".json", sidecar, 0o644) != nil {
+		d.discardPartial(filename)
+		return false
+	}
+	return true
+}
+
+// Removes a kept transfer and its sidecar
+func (d *Downloader) discardPartial(filename string) {
+	path := d.partialPath(filename)
+	removeFile(path + ".json")
+	removeFile(path)
+}
+
+// Asks for the rest of a kept transfer, provided the document is still the version it belongs to
+func (p *partialTransfer) requestRange(request *http.Request) {
+	request.Header.Set("Range", "bytes="+strconv.FormatInt(p.Received, 10)+"-")
+	request.Header.Set("If-Range", cmp.Or(p.ETag, p.LastModified)) // The server sends everything if it changed
+}
+
+// Parses "bytes start-end/total" from a 206 response, with total -1 when unknown
+func parseContentRange(value string) (start, total int64, err error) {
+	spec, ok := strings.CutPrefix(value, "bytes ")
+	rangePart, totalPart, ok2 := strings.Cut(spec, "/")
+	startPart, _, ok3 := strings.Cut(rangePart, "-")
+	if !ok || !ok2 || !ok3 {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", value)
+	}
+	if start, err = strconv.ParseInt(startPart, 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", value)
+	}
+	total = -1
+	if totalPart != "*" {
+		if total, err = strconv.ParseInt(totalPart, 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("invalid Content-Range %q", value)
+		}
+	}
+	return start, total, nil
+}
+
+// Reports whether the server advertised byte-range support for the document
+func acceptsRanges(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusPartialContent || strings.EqualFold(resp.Header.Get("Accept-Ranges"), "bytes")
+}
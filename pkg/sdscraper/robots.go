@@ -0,0 +1,261 @@
+package sdscraper
+
+import ( // Import required packages
+	"bufio"    // For reading robots.txt line by line
+	"context"  // For cancelling fetches
+	"errors"   // For the sentinel error
+	"io"       // For bounding the body
+	"log/slog" // For structured logging
+	"net/http" // For fetching robots.txt
+	"net/url"  // For request URLs
+	"strings"  // For parsing directives
+	"sync"     // For the per-host cache
+	"time"     // For expiring the rules
+)
+
+// ErrRobotsDisallowed is returned for requests the site's robots.txt forbids
+var ErrRobotsDisallowed = errors.New("disallowed by robots.txt")
+
+const maxRobotsBytes = 512 << 10 // Google's limit; anything longer is truncated
+
+const (
+	robotsTTL        = 24 * time.Hour  // How long fetched rules are kept, the most RFC 9309 allows
+	robotsRetryAfter = 5 * time.Minute // How long an unreachable robots.txt disallows everything before it is tried again
+)
+
+// RobotsPolicy fetches robots.txt per host, again once a day, and answers whether a URL may be crawled
+type RobotsPolicy struct {
+	UserAgent string // Product token matched against User-agent lines; "*" rules apply otherwise
+
+	mu    sync.Mutex
+	hosts map[string]*robotsRules // Keyed by scheme://host
+	now   func() time.Time        // Clock, nil for time.Now
+}
+
+// Allow and Disallow path prefixes that apply to us
+type robotsRules struct {
+	ready       chan struct{} // Closed once the rules are fetched
+	rules       []robotsRule
+	disallowAll bool      // robots.txt was unreachable, which RFC 9309 takes to forbid everything
+	expires     time.Time // When the rules are fetched again
+	err         error     // Why no answer was had, e.g. a cancelled context; such rules are never kept
+}
+
+// One Allow or Disallow line
+type robotsRule struct {
+	prefix string
+	allow  bool
+}
+
+// Allowed reports whether target may be fetched, fetching the host's robots.txt through transport on first use and
+// once the rules expire; a fetch that could not be made is retried by the next request instead of being remembered
+func (p *RobotsPolicy) Allowed(ctx context.Context, target *url.URL, transport http.RoundTripper, limiter *RateLimiter) (bool, error) {
+	if target.Path == "/robots.txt" {
+		return true, nil
+	}
+	origin := target.Scheme + "://" + target.Host
+	for {
+		host, fetch := p.rulesFor(origin)
+		if fetch { // This request fetches the rules for everyone
+			p.fetch(ctx, origin, transport, limiter, host)
+			if host.err != nil {
+				p.forget(origin, host)
+			}
+			close(host.ready)
+		}
+		select {
+		case <-host.ready:
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+		if host.err == nil {
+			return host.allows(robotsPath(target)), nil
+		}
+		if fetch {
+			return false, host.err
+		}
+		// Another request's fetch was cut short; fetch again under this one's context
+	}
+}
+
+// Returns the origin's rules and whether the caller must fetch them, because they are missing or expired
+func (p *RobotsPolicy) rulesFor(origin string) (*robotsRules, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.hosts == nil {
+		p.hosts = make(map[string]*robotsRules)
+	}
+	if host, ok := p.hosts[origin]; ok {
+		select {
+		case <-host.ready:
+			if p.clock().Before(host.expires) {
+				return host, false
+			}
+		default:
+			return host, false // Being fetched; wait for it
+		}
+	}
+	host := &robotsRules{ready: make(chan struct{})}
+	p.hosts[origin] = host
+	return host, true
+}
+
+// Drops rules that failed to fetch, unless newer ones replaced them already
+func (p *RobotsPolicy) forget(origin string, host *robotsRules) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.hosts[origin] == host {
+		delete(p.hosts, origin)
+	}
+}
+
+// Returns the current time
+func (p *RobotsPolicy) clock() time.Time {
+	if p.now != nil {
+		return p.now()
+	}
+	return time.Now()
+}
+
+// Downloads and parses robots.txt into host: a missing file (4xx) allows everything, an unreachable one (5xx or
+// a network error) disallows everything for a while, and a fetch that could not be made records its error
+func (p *RobotsPolicy) fetch(ctx context.Context, origin string, transport http.RoundTripper, limiter *RateLimiter, host *robotsRules) {
+	host.expires = p.clock().Add(robotsTTL)
+	if err := limiter.Wait(ctx); err != nil {
+		host.err = err
+		return
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, origin+"/robots.txt", nil)
+	if err != nil {
+		host.err = err
+		return
+	}
+	if p.UserAgent != "" {
+		request.Header.Set("User-Agent", p.UserAgent)
+	}
+	resp, err := (&http.Client{Transport: transport}).Do(request)
+	if err != nil {
+		if ctx.Err() != nil { // Cut short, which says nothing about the site
+			host.err = ctx.Err()
+			return
+		}
+		slog.Warn("Fetching robots.txt failed; crawling nothing there until it is back", "origin", origin, "err", err)
+		host.disallowAll, host.expires = true, p.clock().Add(robotsRetryAfter)
+		return
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode >= http.StatusInternalServerError:
+		slog.Warn("robots.txt unreachable; crawling nothing there until it is back", "origin", origin, "status", resp.StatusCode)
+		host.disallowAll, host.expires = true, p.clock().Add(robotsRetryAfter)
+		return
+	case resp.StatusCode != http.StatusOK:
+		slog.Debug("No robots.txt", "origin", origin, "status", resp.StatusCode)
+		return
+	}
+	host.rules = parseRobots(io.LimitReader(resp.Body, maxRobotsBytes), p.UserAgent)
+	slog.Info("Loaded robots.txt", "origin", origin, "rules", len(host.rules))
+}
+
+// Returns what robots.txt rules are matched against: the escaped path with the query string
+func robotsPath(target *url.URL) string {
+	path := target.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if target.RawQuery != "" {
+		path += "?" + target.RawQuery
+	}
+	return path
+}
+
+// Extracts the rules of the group naming userAgent, or of the "*" group when none does
+func parseRobots(body io.Reader, userAgent string) []robotsRule {
+	var specific, wildcard []robotsRule
+	var matchesUs, matchesAll, inAgents bool // State of the current group
+	var foundSpecific bool
+	token := strings.ToLower(strings.SplitN(userAgent, "/", 2)[0]) // "Name/1.0" matches "name"
+
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#") // Drop comments
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			if !inAgents { // A new group starts
+				matchesUs, matchesAll = false, false
+			}
+			inAgents = true
+			agent := strings.ToLower(value)
+			if agent == "*" {
+				matchesAll = true
+			} else if token != "" && strings.HasPrefix(token, agent) {
+				matchesUs, foundSpecific = true, true
+			}
+		case "allow", "disallow":
+			inAgents = false
+			if value == "" && key == "disallow" { // Empty Disallow allows everything
+				continue
+			}
+			rule := robotsRule{prefix: value, allow: key == "allow"}
+			if matchesUs {
+				specific = append(specific, rule)
+			}
+			if matchesAll {
+				wildcard = append(wildcard, rule)
+			}
+		default:
+			inAgents = false
+		}
+	}
+	if foundSpecific {
+		return specific
+	}
+	return wildcard
+}
+
+// Applies the longest matching rule, with Allow winning ties
+func (h *robotsRules) allows(path string) bool {
+	if h.disallowAll {
+		return false
+	}
+	best, allowed := -1, true
+	for _, rule := range h.rules {
+		if !robotsMatch(rule.prefix, path) {
+			continue
+		}
+		if len(rule.prefix) > best || (len(rule.prefix) == best && rule.allow) {
+			best, allowed = len(rule.prefix), rule.allow
+		}
+	}
+	return allowed
+}
+
+// Matches a robots.txt path pattern, supporting the * wildcard and the $ end anchor
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	parts := strings.Split(strings.TrimSuffix(pattern, "$"), "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	if len(parts) == 1 {
+		return !anchored || rest == ""
+	}
+	for _, part := range parts[1 : len(parts)-1] { // Earliest match leaves the most room for the rest
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
+	}
+	last := parts[len(parts)-1]
+	if anchored {
+		return strings.HasSuffix(rest, last)
+	}
+	return strings.Contains(rest, last)
+}
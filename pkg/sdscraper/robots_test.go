@@ -0,0 +1,147 @@
+package sdscraper
+
+import ( // Import required packages
+	"context"           // For the checks
+	"net/http"          // For the fake server's handler
+	"net/http/httptest" // For the fake server
+	"net/url"           // For checked URLs
+	"strings"           // For robots.txt bodies
+	"sync"              // For guarding the fake server's state
+	"testing"           // For the test harness
+	"time"              // For the fake clock
+)
+
+func TestParseRobots(t *testing.T) {
+	body := `# Comments are dropped
+User-agent: *
+Disallow: /private
+Allow: /private/sds
+
+User-agent: gojo-sds
+User-agent: other
+Disallow: /tmp
+Disallow:
+
+User-agent: unrelated
+Disallow: /`
+	for _, test := range []struct {
+		userAgent string
+		want      []robotsRule
+	}{
+		{"gojo-sds/1.0", []robotsRule{{"/tmp", false}}}, // Its own group, the empty Disallow skipped
+		{"Mozilla/5.0", []robotsRule{{"/private", false}, {"/private/sds", true}}},
+		{"", []robotsRule{{"/private", false}, {"/private/sds", true}}},
+	} {
+		got := parseRobots(strings.NewReader(body), test.userAgent)
+		if len(got) != len(test.want) {
+			t.Errorf("parseRobots for %q = %+v, want %+v", test.userAgent, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("parseRobots for %q = %+v, want %+v", test.userAgent, got, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestRobotsMatch(t *testing.T) {
+	for _, test := range []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"/sds", "/sds/gel.pdf", true},
+		{"/sds", "/docs/gel.pdf", false},
+		{"/*.pdf$", "/sds/gel.pdf", true},
+		{"/*.pdf$", "/sds/gel.pdf?v=2", false},
+		{"/*?download=", "/file?download=7", true},
+		{"/*?download=", "/file", false},
+		{"/a*b*c", "/axxbyyc", true},
+		{"/a*b*c", "/axxcyyb", false},
+		{"/exact$", "/exact", true},
+		{"/exact$", "/exactly", false},
+	} {
+		if got := robotsMatch(test.pattern, test.path); got != test.want {
+			t.Errorf("robotsMatch(%q, %q) = %t, want %t", test.pattern, test.path, got, test.want)
+		}
+	}
+}
+
+// Serves robots.txt with a changeable status and body, counting fetches
+type robotsServer struct {
+	mu      sync.Mutex
+	status  int
+	body    string
+	fetches int
+}
+
+// ServeHTTP implements http.Handler
+func (r *robotsServer) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fetches++
+	w.WriteHeader(r.status)
+	w.Write([]byte(r.body))
+}
+
+// Changes what the server answers
+func (r *robotsServer) set(status int, body string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status, r.body = status, body
+}
+
+func TestRobotsPolicyCache(t *testing.T) {
+	robots := &robotsServer{status: http.StatusOK, body: "User-agent: *\nDisallow: /*?download="}
+	server := httptest.NewServer(robots)
+	defer server.Close()
+	now := time.Now()
+	policy := &RobotsPolicy{now: func() time.Time { return now }}
+	allowed := func(ctx context.Context, path string) (bool, error) {
+		target, _ := url.Parse(server.URL + path)
+		return policy.Allowed(ctx, target, http.DefaultTransport, nil)
+	}
+	check := func(path string, want bool) {
+		t.Helper()
+		if got, err := allowed(context.Background(), path); err != nil || got != want {
+			t.Errorf("Allowed(%s) = %t, %v; want %t", path, got, err, want)
+		}
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := allowed(cancelled, "/sds"); err == nil {
+		t.Error("a cancelled check answered")
+	}
+	check("/file?download=7", false) // Fetched afresh, not "no rules" from the cancelled attempt
+	check("/file", true)
+	if robots.fetches != 1 {
+		t.Errorf("%d fetches, want 1 while the rules are fresh", robots.fetches)
+	}
+
+	robots.set(http.StatusServiceUnavailable, "")
+	now = now.Add(robotsTTL + time.Minute)
+	check("/file", false) // Unreachable disallows everything
+	robots.set(http.StatusOK, "User-agent: *\nDisallow: /file")
+	check("/file", false) // Still within the retry delay
+	now = now.Add(robotsRetryAfter + time.Second)
+	check("/file", false) // The changed rules
+	check("/other", true)
+
+	robots.set(http.StatusNotFound, "")
+	now = now.Add(robotsTTL + time.Minute)
+	check("/file", true) // A missing robots.txt allows everything
+	if robots.fetches != 4 {
+		t.Errorf("%d fetches, want 4", robots.fetches)
+	}
+}
+
+func TestRobotsNetworkFailureDisallows(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close() // Nothing listens there any more
+	target, _ := url.Parse(server.URL + "/sds/gel.pdf")
+	if allowed, err := (&RobotsPolicy{}).Allowed(context.Background(), target, http.DefaultTransport, nil); err != nil || allowed {
+		t.Errorf("Allowed with robots.txt unreachable = %t, %v; want disallowed", allowed, err)
+	}
+}
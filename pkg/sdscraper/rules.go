@@ -0,0 +1,232 @@
+package sdscraper
+
+import ( // Import required packages
+	"cmp"     // For defaults
+	"fmt"     // For error messages
+	"io"      // For reading stored documents
+	"path"    // For storage folders
+	"regexp"  // For URL, title and hazard code matching
+	"slices"  // For code and channel sets
+	"strings" // For folder templates
+
+	"github.com/ledongthuc/pdf" // For finding hazard codes in the text
+)
+
+// Rule is one ingestion policy: when every condition of Match holds for a document, Tags are added to it, new
+// downloads are stored under Path and events about it go to the Notify channels
+type Rule struct {
+	Name   string    `json:"name"`             // Shown in logs, e.g. "flammables to EHS"
+	Match  RuleMatch `json:"match"`            // Conditions, all of which must hold; none matches every document
+	Tags   []string  `json:"tags,omitempty"`   // Added to matching documents
+	Path   string    `json:"path,omitempty"`   // Folder for new downloads, e.g. "hazardous/{brand}"; the first matching rule's wins
+	Notify []string  `json:"notify,omitempty"` // Notification channels told about matching documents
+	Stop   bool      `json:"stop,omitempty"`   // Evaluate no further rules for matching documents
+}
+
+// RuleMatch holds a rule's conditions
+type RuleMatch struct {
+	URL     string   `json:"url,omitempty"`     // Regular expression the source URL must match
+	Title   string   `json:"title,omitempty"`   // Regular expression the product title must match
+	Locales []string `json:"locales,omitempty"` // Listed under any of these locales
+	Hazards []string `json:"hazards,omitempty"` // Any of these GHS hazard codes in the text, e.g. H225; "H3" covers every H3xx
+	Tags    []string `json:"tags,omitempty"`    // Already carrying every one of these tags
+}
+
+// A rule with its patterns compiled
+type compiledRule struct {
+	Rule
+	url, title *regexp.Regexp
+}
+
+// RuleSet evaluates rules in order; rules matching on hazard codes are only decided once the document's text is
+// known, the others as soon as the document is listed
+type RuleSet struct {
+	rules []compiledRule
+}
+
+// NewRuleSet compiles rules, reporting the first invalid pattern, tag or folder
+func NewRuleSet(rules []Rule) (*RuleSet, error) {
+	set := &RuleSet{}
+	for i, rule := range rules {
+		name := cmp.Or(rule.Name, fmt.Sprintf("rule %d", i+1))
+		compiled := compiledRule{Rule: rule}
+		compiled.Name = name
+		compiled.Tags, compiled.Match.Tags = slices.Clone(rule.Tags), slices.Clone(rule.Match.Tags) // Normalised below
+		compiled.Match.Hazards = slices.Clone(rule.Match.Hazards)
+		var err error
+		if rule.Match.URL != "" {
+			if compiled.url, err = regexp.Compile(rule.Match.URL); err != nil {
+				return nil, fmt.Errorf("%s: url: %w", name, err)
+			}
+		}
+		if rule.Match.Title != "" {
+			if compiled.title, err = regexp.Compile(rule.Match.Title); err != nil {
+				return nil, fmt.Errorf("%s: title: %w", name, err)
+			}
+		}
+		for j, tag := range rule.Tags {
+			if compiled.Tags[j], err = NormalizeTag(tag); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		for j, tag := range rule.Match.Tags {
+			if compiled.Match.Tags[j], err = NormalizeTag(tag); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		for j, code := range rule.Match.Hazards {
+			compiled.Match.Hazards[j] = strings.ToUpper(strings.TrimSpace(code))
+		}
+		if cleaned := path.Clean("/" + rule.Path); rule.Path != "" && (cleaned == "/" || strings.Contains(rule.Path, "..")) {
+			return nil, fmt.Errorf("%s: path %q must name a folder inside the output directory", name, rule.Path)
+		}
+		set.rules = append(set.rules, compiled)
+	}
+	return set, nil
+}
+
+// Len returns how many rules the set holds
+func (r *RuleSet) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.rules)
+}
+
+// Reports whether the rule holds for an entry; with known false, hazard conditions are undecided and never hold
+func (c compiledRule) matches(entry *ManifestEntry, known bool) bool {
+	if c.url != nil && !c.url.MatchString(entry.URL) {
+		return false
+	}
+	if c.title != nil && !c.title.MatchString(entry.Product) {
+		return false
+	}
+	if len(c.Match.Locales) > 0 && !slices.ContainsFunc(entry.Locales, func(locale string) bool {
+		return slices.ContainsFunc(c.Match.Locales, func(want string) bool { return strings.EqualFold(want, locale) })
+	}) {
+		return false
+	}
+	for _, tag := range c.Match.Tags {
+		if !entry.HasTag(tag) {
+			return false
+		}
+	}
+	if len(c.Match.Hazards) > 0 {
+		if !known {
+			return false
+		}
+		return slices.ContainsFunc(entry.HazardCodes, func(code string) bool {
+			return slices.ContainsFunc(c.Match.Hazards, func(want string) bool { return strings.HasPrefix(code, want) })
+		})
+	}
+	return true
+}
+
+// Returns the rules holding for an entry, in order, up to the first matching rule that stops evaluation
+func (r *RuleSet) matching(entry *ManifestEntry, known bool) []compiledRule {
+	if r == nil {
+		return nil
+	}
+	var matched []compiledRule
+	for _, rule := range r.rules {
+		if !rule.matches(entry, known) {
+			continue
+		}
+		matched = append(matched, rule)
+		if rule.Stop {
+			break
+		}
+	}
+	return matched
+}
+
+// Applies the tags of the rules decided without the document's text, as soon as it is listed
+func (r *RuleSet) tagListed(entry *ManifestEntry) {
+	for _, rule := range r.matching(entry, false) {
+		entry.AddTags(rule.Tags...)
+	}
+}
+
+// Applies every matching rule's tags to a stored document, whose hazard codes are known, and returns the folder the
+// first rule with a Path gives it, empty for none
+func (r *RuleSet) ingest(entry *ManifestEntry) string {
+	folder := ""
+	for _, rule := range r.matching(entry, true) {
+		entry.AddTags(rule.Tags...)
+		if folder == "" && rule.Path != "" {
+			folder = renderFolder(rule.Path, entry)
+		}
+	}
+	return folder
+}
+
+// Returns the notification channels of the rules holding for a stored document
+func (r *RuleSet) channels(entry *ManifestEntry) []string {
+	var channels []string
+	for _, rule := range r.matching(entry, true) {
+		for _, channel := range rule.Notify {
+			if !slices.Contains(channels, channel) {
+				channels = append(channels, channel)
+			}
+		}
+	}
+	return channels
+}
+
+// Reports whether any rule needs hazard codes, which cost a text extraction per stored document
+func (r *RuleSet) needsHazards() bool {
+	return r != nil && slices.ContainsFunc(r.rules, func(rule compiledRule) bool { return len(rule.Match.Hazards) > 0 })
+}
+
+// Fills a folder template's {brand}, {product}, {sku}, {lang}, {locale} and {type} placeholders, dropping the
+// segments left empty
+func renderFolder(template string, entry *ManifestEntry) string {
+	locale := ""
+	if len(entry.Locales) > 0 {
+		locale = entry.Locales[0]
+	}
+	replacer := strings.NewReplacer(
+		"{brand}", slugify(entry.Brand),
+		"{product}", slugify(entry.Product),
+		"{sku}", slugify(entry.SKU),
+		"{lang}", slugify(cmp.Or(entry.Language, locale)),
+		"{locale}", slugify(locale),
+		"{type}", slugify(entry.Type),
+	)
+	var segments []string
+	for _, segment := range strings.Split(replacer.Replace(template), "/") {
+		segment = strings.Trim(unsafeFilenameRegex.ReplaceAllString(segment, "_"), "_-.")
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+var hazardCode = regexp.MustCompile(`\b(?:EUH\d{3}|H[2-4]\d{2}[A-Fdf]{0,2})\b`) // GHS and EU hazard statement codes
+
+// Returns the hazard statement codes in a PDF's text, sorted and without repeats
+func hazardCodes(content io.ReaderAt, size int64) (codes []string, err error) {
+	defer func() { // The extractor panics on some malformed files
+		if recovered := recover(); recovered != nil {
+			codes, err = nil, fmt.Errorf("extract text: %v", recovered)
+		}
+	}()
+	reader, err := pdf.NewReader(content, size)
+	if err != nil {
+		return nil, err
+	}
+	for i := 1; i <= reader.NumPage(); i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		text, err := page.GetPlainText(nil)
+		if err != nil {
+			return codes, fmt.Errorf("page %d: %w", i, err)
+		}
+		codes = append(codes, hazardCode.FindAllString(text, -1)...)
+	}
+	slices.Sort(codes)
+	return slices.Compact(codes), nil
+}
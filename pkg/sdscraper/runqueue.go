@@ -0,0 +1,132 @@
+package sdscraper
+
+import ( // Import required packages
+	"context"  // For cancelling superseded runs
+	"fmt"      // For error messages
+	"log/slog" // For structured logging
+	"slices"   // For joining reasons
+	"strings"  // For joining reasons
+	"sync"     // For serializing runs
+)
+
+// OverlapPolicy says what a RunQueue does with a trigger that fires while a run is in progress
+type OverlapPolicy int
+
+const (
+	OverlapQueueOne      OverlapPolicy = iota // Run once more after the current run; further triggers fold into that one
+	OverlapSkip                               // Drop the trigger
+	OverlapAbortPrevious                      // Cancel the current run, which checkpoints, and start over
+)
+
+// ParseOverlapPolicy parses "queue-one", "skip" or "abort-previous"
+func ParseOverlapPolicy(value string) (OverlapPolicy, error) {
+	switch value {
+	case "queue-one":
+		return OverlapQueueOne, nil
+	case "skip":
+		return OverlapSkip, nil
+	case "abort-previous":
+		return OverlapAbortPrevious, nil
+	}
+	return 0, fmt.Errorf("unknown overlap policy %q (want skip, queue-one or abort-previous)", value)
+}
+
+// String returns the policy's flag spelling
+func (p OverlapPolicy) String() string {
+	switch p {
+	case OverlapSkip:
+		return "skip"
+	case OverlapAbortPrevious:
+		return "abort-previous"
+	}
+	return "queue-one"
+}
+
+// RunQueue starts Scraper runs on demand, never more than one at a time, resolving overlapping triggers by Policy
+type RunQueue struct {
+	Scraper  *Scraper             // The scraper whose state the runs share
+	Policy   OverlapPolicy        // What to do with triggers during a run
+	OnResult func(*Result, error) // Called after every run, including aborted ones
+	mu       sync.Mutex           // Guards the fields below
+	running  bool                 // A run goroutine is active
+	queued   bool                 // Another run follows the current one
+	reason   string               // Operator's note for the next run, from TriggerWithReason
+	cancel   context.CancelFunc   // Cancels the current run
+	wg       sync.WaitGroup       // Tracks the run goroutine
+}
+
+// Trigger starts a run in the background, or applies the overlap policy if one is in progress; it reports whether a run will happen
+func (q *RunQueue) Trigger(ctx context.Context) bool {
+	return q.TriggerWithReason(ctx, "")
+}
+
+// TriggerWithReason is Trigger for a run an operator asked for, recording reason as the run's Scraper.Reason; a
+// manual trigger merged into a queued run takes that run over, and the reasons of merged manual triggers are joined
+func (q *RunQueue) TriggerWithReason(ctx context.Context, reason string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.running && q.Policy == OverlapSkip {
+		slog.Warn("Previous sync still running; skipping trigger", "policy", q.Policy, "reason", reason)
+		return false
+	}
+	if reason != "" {
+		q.reason = strings.Join(slices.DeleteFunc([]string{q.reason, reason}, func(r string) bool { return r == "" }), "; ")
+	}
+	if !q.running {
+		q.running = true
+		q.wg.Add(1)
+		go q.loop(ctx)
+		return true
+	}
+	switch q.Policy {
+	case OverlapAbortPrevious:
+		slog.Warn("Previous sync still running; aborting it", "policy", q.Policy)
+		q.cancel()
+	default:
+		if q.queued {
+			slog.Info("Sync already queued; merging trigger", "policy", q.Policy)
+		} else {
+			slog.Info("Previous sync still running; queueing another", "policy", q.Policy)
+		}
+	}
+	q.queued = true
+	return true
+}
+
+// Wait blocks until no run is in progress
+func (q *RunQueue) Wait() {
+	q.wg.Wait()
+}
+
+// Runs the scraper once, turning a panic outside the per-document stages into an error so the daemon keeps going
+func (q *RunQueue) run(ctx context.Context) (result *Result, err error) {
+	defer recoverPanic("run", "", func(recovered *PanicError) { err = recovered })
+	return q.Scraper.Run(ctx)
+}
+
+// Runs the scraper until no further run is queued or ctx is done
+func (q *RunQueue) loop(ctx context.Context) {
+	defer q.wg.Done()
+	for {
+		runCtx, cancel := context.WithCancel(ctx)
+		q.mu.Lock()
+		q.cancel = cancel
+		q.queued = false
+		q.Scraper.Reason, q.reason = q.reason, "" // Scheduled runs carry none
+		q.mu.Unlock()
+
+		result, err := q.run(runCtx)
+		cancel()
+		if q.OnResult != nil {
+			q.OnResult(result, err)
+		}
+
+		q.mu.Lock()
+		if !q.queued || ctx.Err() != nil {
+			q.running = false
+			q.mu.Unlock()
+			return
+		}
+		q.mu.Unlock()
+	}
+}
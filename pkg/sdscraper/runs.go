@@ -0,0 +1,209 @@
+package sdscraper
+
+import ( // Import required packages
+	"context"     // For deadline errors
+	"crypto/tls"  // For handshake errors
+	"crypto/x509" // For certificate errors
+	"errors"      // For classifying failures
+	"net"         // For network errors
+	"os"          // For I/O deadline errors
+	"strconv"     // For status classes
+	"strings"     // For TLS errors
+	"time"        // For elapsed times
+)
+
+// RunIDFormat formats a run's start time as its ID
+const RunIDFormat = "20060102T150405Z"
+
+// RegressionThreshold is the relative worsening of a run metric that "runs compare" reports as a regression
+const RegressionThreshold = 0.2
+
+const (
+	minElapsedRegression = 5.0     // Seconds a run must slow down by before it counts, so short runs are not noise
+	minThroughputBytes   = 8 << 20 // Bytes both runs must transfer for their download rates to say anything
+)
+
+// Summarises a finished run's result for the manifest's history
+func newRunStats(result *Result, started time.Time, byLocale map[string]int) RunStats {
+	stats := RunStats{
+		ID:          started.UTC().Format(RunIDFormat),
+		At:          started,
+		Version:     Build().Version,
+		Elapsed:     time.Since(started).Seconds(),
+		Discovered:  len(result.Discovered),
+		ByLocale:    byLocale,
+		Downloaded:  len(result.Downloaded) + len(result.Aliased),
+		NotModified: len(result.NotModified),
+		Skipped:     len(result.Skipped),
+		Failed:      len(result.Failed),
+		Bytes:       result.Bytes,
+		Reason:      result.Reason,
+	}
+	for _, err := range result.Failed {
+		if stats.Failures == nil {
+			stats.Failures = make(map[string]int)
+		}
+		stats.Failures[FailureCategory(err)]++
+	}
+	return stats
+}
+
+// RunID returns the ID of a run, derived from its start time for runs recorded before runs carried one
+func (r RunStats) RunID() string {
+	if r.ID != "" {
+		return r.ID
+	}
+	return r.At.UTC().Format(RunIDFormat)
+}
+
+// FailureCategory names the kind of a download failure, e.g. "http_5xx", "timeout" or "invalid_pdf"
+func FailureCategory(err error) string {
+	var status *StatusError
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var challenge *ChallengeError
+	var panicked *PanicError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &status):
+		if status.StatusCode == 429 {
+			return "rate_limited"
+		}
+		return "http_" + strconv.Itoa(status.StatusCode/100) + "xx"
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.Is(err, ErrPinMismatch):
+		return "tls_pin"
+	case errors.As(err, &certErr), errors.As(err, &unknownAuthority), strings.Contains(err.Error(), "tls:"):
+		return "tls"
+	case errors.Is(err, errInvalidPDF):
+		return "invalid_pdf"
+	case errors.Is(err, ErrTooLarge):
+		return "too_large"
+	case errors.Is(err, ErrQuotaExceeded):
+		return "quota"
+	case errors.As(err, &challenge):
+		return "challenge"
+	case errors.As(err, &panicked):
+		return "panic"
+	case errors.As(err, &netErr):
+		return "connection"
+	}
+	return "other"
+}
+
+// RunDelta compares one metric of two runs
+type RunDelta struct {
+	Metric     string  `json:"metric"` // E.g. "elapsed_seconds", "failures.timeout" or "discovered.en-US"
+	Before     float64 `json:"before"`
+	After      float64 `json:"after"`
+	Change     float64 `json:"change,omitempty"` // Relative change from Before, zero when Before is
+	Regression bool    `json:"regression,omitempty"`
+}
+
+// RunComparison contrasts two runs, metric by metric
+type RunComparison struct {
+	Before      RunStats   `json:"before"`
+	After       RunStats   `json:"after"`
+	Deltas      []RunDelta `json:"deltas"`
+	Regressions int        `json:"regressions"` // Deltas marked as regressions
+}
+
+// How a metric worsens, for marking regressions
+type runDirection int
+
+const (
+	neutral      runDirection = iota // Depends on what changed at the source, e.g. downloads
+	lowerBetter                      // E.g. time taken and failures
+	higherBetter                     // E.g. discovery and throughput
+)
+
+// CompareRuns contrasts before and after, marking as a regression every metric that worsened by more than
+// RegressionThreshold and every new or more frequent failure category
+func CompareRuns(before, after RunStats) RunComparison {
+	comparison := RunComparison{Before: before, After: after}
+	add := func(metric string, old, new float64, direction runDirection, floor float64) {
+		delta := RunDelta{Metric: metric, Before: old, After: new}
+		if old != 0 {
+			delta.Change = (new - old) / old
+		}
+		switch direction {
+		case lowerBetter:
+			delta.Regression = new-old > floor && (old == 0 || delta.Change > RegressionThreshold)
+		case higherBetter:
+			delta.Regression = old-new > floor && -delta.Change > RegressionThreshold
+		}
+		if delta.Regression {
+			comparison.Regressions++
+		}
+		comparison.Deltas = append(comparison.Deltas, delta)
+	}
+	rate := neutral
+	if before.Bytes >= minThroughputBytes && after.Bytes >= minThroughputBytes {
+		rate = higherBetter
+	}
+	add("elapsed_seconds", before.Elapsed, after.Elapsed, lowerBetter, minElapsedRegression)
+	add("mib_per_second", throughput(before), throughput(after), rate, 0)
+	add("discovered", float64(before.Discovered), float64(after.Discovered), higherBetter, 0)
+	add("downloaded", float64(before.Downloaded), float64(after.Downloaded), neutral, 0)
+	add("not_modified", float64(before.NotModified), float64(after.NotModified), neutral, 0)
+	add("skipped", float64(before.Skipped), float64(after.Skipped), neutral, 0)
+	add("bytes", float64(before.Bytes), float64(after.Bytes), neutral, 0)
+	add("failed", float64(before.Failed), float64(after.Failed), lowerBetter, 0)
+	for _, category := range unionKeys(before.Failures, after.Failures) {
+		add("failures."+category, float64(before.Failures[category]), float64(after.Failures[category]), lowerBetter, 0)
+	}
+	for _, locale := range unionKeys(before.ByLocale, after.ByLocale) {
+		add("discovered."+locale, float64(before.ByLocale[locale]), float64(after.ByLocale[locale]), higherBetter, 0)
+	}
+	return comparison
+}
+
+// Returns a run's download rate in MiB per second, zero when unknown
+func throughput(stats RunStats) float64 {
+	if stats.Elapsed <= 0 {
+		return 0
+	}
+	return float64(stats.Bytes) / (1 << 20) / stats.Elapsed
+}
+
+// Returns the keys of either map in order
+func unionKeys(a, b map[string]int) []string {
+	merged := make(map[string]int, len(a)+len(b))
+	for key := range a {
+		merged[key] = 0
+	}
+	for key := range b {
+		merged[key] = 0
+	}
+	return sortedKeys(merged)
+}
+
+// Returns id, numbered when a run of the history already has it, as happens for runs started within one second
+func (m *Manifest) uniqueRunID(id string) string {
+	for n, candidate := 2, id; ; n++ {
+		if _, taken := m.FindRun(candidate); !taken {
+			return candidate
+		}
+		candidate = id + "-" + strconv.Itoa(n)
+	}
+}
+
+// FindRun returns the run of the manifest's history with an ID, or "latest" and "previous" for the last two
+func (m *Manifest) FindRun(id string) (RunStats, bool) {
+	switch {
+	case id == "latest" && len(m.Runs) > 0:
+		return m.Runs[len(m.Runs)-1], true
+	case id == "previous" && len(m.Runs) > 1:
+		return m.Runs[len(m.Runs)-2], true
+	}
+	for _, run := range m.Runs {
+		if run.RunID() == id {
+			return run, true
+		}
+	}
+	return RunStats{}, false
+}
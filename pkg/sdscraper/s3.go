@@ -0,0 +1,389 @@
+package sdscraper
+
+import ( // Import required packages
+	"bytes"           // For buffering unseekable uploads
+	"cmp"             // For the versioning status
+	"context"         // For cancelling requests
+	"crypto/hmac"     // For SigV4 signing keys
+	"crypto/md5"      // For Content-MD5
+	"crypto/sha256"   // For SigV4 hashes
+	"encoding/base64" // For Content-MD5
+	"encoding/hex"    // For SigV4 hex digests
+	"encoding/xml"    // For bucket configuration
+	"errors"          // For matching missing objects
+	"fmt"             // For error messages
+	"io"              // For streaming content
+	"io/fs"           // For the not-exist sentinel
+	"net/http"        // For S3 requests
+	"net/url"         // For object URLs
+	"os"              // For credentials from the environment
+	"path"            // For object keys
+	"slices"          // For sorting signed headers
+	"strconv"         // For Content-Length
+	"strings"         // For canonical requests
+	"time"            // For signing dates
+)
+
+// S3Storage keeps documents as objects in an S3-compatible bucket, signing requests with AWS Signature Version 4
+type S3Storage struct {
+	Bucket       string        // Bucket name
+	Prefix       string        // Key prefix, e.g. "gojo/sds/"
+	Region       string        // Signing region, empty for us-east-1
+	Endpoint     string        // Service URL for S3-compatible stores, empty for AWS
+	PathStyle    bool          // Address the bucket in the path instead of the host name, as most S3-compatible stores expect
+	AccessKey    string        // Access key ID
+	SecretKey    string        // Secret access key
+	SessionToken string        // Temporary credentials token, if any
+	Client       *http.Client  // HTTP client, nil for http.DefaultClient
+	ObjectLock   string        // Retention mode each write is locked under, ObjectLockGovernance or ObjectLockCompliance; empty for the bucket's default
+	Retention    time.Duration // How long each write stays locked with ObjectLock
+}
+
+// Object lock retention modes: governance locks can be lifted by principals allowed to bypass them, compliance
+// locks by no one, the root account included, until they expire
+const (
+	ObjectLockGovernance = "GOVERNANCE"
+	ObjectLockCompliance = "COMPLIANCE"
+)
+
+// ErrObjectLocked is returned when a bucket's object lock forbids a change, such as deleting a retained revision
+var ErrObjectLocked = errors.New("object lock forbids the change")
+
+// NewS3StorageFromEnv fills credentials and region from the standard AWS environment variables
+func NewS3StorageFromEnv(bucket, prefix string) *S3Storage {
+	return &S3Storage{
+		Bucket:       bucket,
+		Prefix:       prefix,
+		Region:       os.Getenv("AWS_REGION"),
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// Stat implements Storage with a HEAD request, reporting the current version and its lock
+func (s *S3Storage) Stat(ctx context.Context, name string) (StoredFile, error) {
+	resp, err := s.do(ctx, http.MethodHead, name, nil, -1, nil)
+	if err != nil {
+		return StoredFile{}, err
+	}
+	resp.Body.Close()
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	retainUntil, _ := time.Parse(time.RFC3339, resp.Header.Get("X-Amz-Object-Lock-Retain-Until-Date"))
+	return StoredFile{
+		Size:        resp.ContentLength,
+		ModTime:     modTime,
+		Version:     resp.Header.Get("X-Amz-Version-Id"),
+		RetainUntil: retainUntil,
+		LegalHold:   resp.Header.Get("X-Amz-Object-Lock-Legal-Hold") == "ON",
+	}, nil
+}
+
+// Put implements Storage; S3 replaces objects atomically
+func (s *S3Storage) Put(ctx context.Context, name string, content io.Reader) error {
+	_, err := s.PutVersion(ctx, name, content)
+	return err
+}
+
+// PutVersion implements VersionedStorage: on a versioned bucket every write is a version of its own, which
+// ObjectLock keeps unaltered and undeletable for Retention
+func (s *S3Storage) PutVersion(ctx context.Context, name string, content io.Reader) (string, error) {
+	seeker, ok := content.(io.ReadSeeker)
+	if !ok { // Sizing and hashing need a second pass
+		data, err := io.ReadAll(content)
+		if err != nil {
+			return "", err
+		}
+		seeker = bytes.NewReader(data)
+	}
+	sum := md5.New() // S3 requires Content-MD5 on writes to object-locked buckets, and checks it on every write
+	size, err := io.Copy(sum, seeker)
+	if err != nil {
+		return "", err
+	}
+	if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	header := http.Header{"Content-Md5": {base64.StdEncoding.EncodeToString(sum.Sum(nil))}}
+	if s.ObjectLock != "" {
+		header.Set("X-Amz-Object-Lock-Mode", s.ObjectLock)
+		header.Set("X-Amz-Object-Lock-Retain-Until-Date", time.Now().Add(s.Retention).UTC().Format(time.RFC3339))
+	}
+	resp, err := s.do(ctx, http.MethodPut, name, seeker, size, header)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.Header.Get("X-Amz-Version-Id"), nil
+}
+
+// Open implements Storage with a GET request
+func (s *S3Storage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, name, nil, -1, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Delete implements Storage; S3 reports success for missing keys too. A current version still under retention or
+// legal hold is refused with ErrObjectLocked, as S3 would hide it behind a delete marker rather than delete it
+func (s *S3Storage) Delete(ctx context.Context, name string) error {
+	stored, err := s.Stat(ctx, name)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil
+	case err != nil:
+		return err
+	case stored.LegalHold:
+		return fmt.Errorf("s3://%s/%s is under legal hold: %w", s.Bucket, s.key(name), ErrObjectLocked)
+	case stored.RetainUntil.After(time.Now()):
+		return fmt.Errorf("s3://%s/%s is retained until %s: %w", s.Bucket, s.key(name), stored.RetainUntil.Format(time.DateOnly), ErrObjectLocked)
+	}
+	resp, err := s.do(ctx, http.MethodDelete, name, nil, -1, nil)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// CheckObjectLock confirms the bucket can hold ObjectLock writes: versioning enabled and object lock turned on,
+// which S3 only allows when the bucket is created, returning an error saying what to change otherwise
+func (s *S3Storage) CheckObjectLock(ctx context.Context) error {
+	if s.ObjectLock != ObjectLockGovernance && s.ObjectLock != ObjectLockCompliance {
+		return fmt.Errorf("object lock mode %q: want %s or %s", s.ObjectLock, ObjectLockGovernance, ObjectLockCompliance)
+	}
+	if s.Retention <= 0 {
+		return errors.New("object lock needs a retention period")
+	}
+	var versioning struct {
+		Status string `xml:"Status"`
+	}
+	if err := s.bucketConfig(ctx, "versioning", &versioning); err != nil {
+		return err
+	}
+	if versioning.Status != "Enabled" {
+		return fmt.Errorf("bucket %s does not have versioning enabled (status %q); object lock keeps revisions as versions", s.Bucket, cmp.Or(versioning.Status, "never enabled"))
+	}
+	var lock struct {
+		Enabled string `xml:"ObjectLockEnabled"`
+		Mode    string `xml:"Rule>DefaultRetention>Mode"`
+		Days    int    `xml:"Rule>DefaultRetention>Days"`
+		Years   int    `xml:"Rule>DefaultRetention>Years"`
+	}
+	if err := s.bucketConfig(ctx, "object-lock", &lock); errors.Is(err, fs.ErrNotExist) || err == nil && lock.Enabled != "Enabled" {
+		return fmt.Errorf("bucket %s has no object lock; it can only be enabled when a bucket is created, so create one with object lock and copy the documents over", s.Bucket)
+	} else if err != nil {
+		return err
+	}
+	if lock.Mode == ObjectLockCompliance && s.ObjectLock == ObjectLockGovernance {
+		return fmt.Errorf("bucket %s retains new objects in %s mode by default; writing them in %s mode would weaken the lock, so use %s", s.Bucket, lock.Mode, s.ObjectLock, lock.Mode)
+	}
+	if minimum := time.Duration(lock.Days)*24*time.Hour + time.Duration(lock.Years)*365*24*time.Hour; s.Retention < minimum {
+		return fmt.Errorf("retention %s is shorter than the default retention of bucket %s, %s; writes would be locked for less than its policy", s.Retention, s.Bucket, minimum)
+	}
+	return nil
+}
+
+// Reads a bucket subresource such as versioning into v from its XML, mapping 404 to fs.ErrNotExist
+func (s *S3Storage) bucketConfig(ctx context.Context, subresource string, v any) error {
+	bucketURL, err := s.keyURL("") // The bucket itself, not the prefix
+	if err != nil {
+		return err
+	}
+	bucketURL.RawQuery = subresource + "="
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, bucketURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.send(request, "s3://"+s.Bucket+"?"+subresource)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return xml.NewDecoder(resp.Body).Decode(v)
+}
+
+// Sends one signed request for the object holding name, mapping 404 to fs.ErrNotExist
+func (s *S3Storage) do(ctx context.Context, method, name string, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	if method != http.MethodGet && method != http.MethodHead {
+		if err := CheckWritable(strings.ToLower(method), s.key(name)); err != nil {
+			return nil, err
+		}
+	}
+	objectURL, err := s.objectURL(name)
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequestWithContext(ctx, method, objectURL.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if size >= 0 {
+		request.ContentLength = size
+		request.Header.Set("Content-Length", strconv.FormatInt(size, 10))
+	}
+	if method == http.MethodPut {
+		request.Header.Set("Content-Type", contentTypeFor("", name)) // The catalog's type is not known here; the name's extension is
+	}
+	for key, values := range header {
+		request.Header[key] = values
+	}
+	return s.send(request, "s3://"+s.Bucket+"/"+s.key(name))
+}
+
+// Signs and sends a request about target, mapping 404 to fs.ErrNotExist and object lock refusals to ErrObjectLocked
+func (s *S3Storage) send(request *http.Request, target string) (*http.Response, error) {
+	s.sign(request, time.Now().UTC())
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %w", target, fs.ErrNotExist)
+	case resp.StatusCode/100 != 2:
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024)) // S3 explains failures in an XML body
+		resp.Body.Close()
+		err := fmt.Errorf("s3 %s %s: %s %s", request.Method, target, resp.Status, strings.TrimSpace(string(detail)))
+		if strings.Contains(strings.ToLower(string(detail)), "object lock") { // E.g. a bucket without a lock configuration
+			err = fmt.Errorf("%w: %w", ErrObjectLocked, err)
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Returns the object key for a file name
+func (s *S3Storage) key(name string) string {
+	return path.Join(s.Prefix, name)
+}
+
+// Builds the object URL in virtual-hosted or path style
+func (s *S3Storage) objectURL(name string) (*url.URL, error) {
+	return s.keyURL(s.key(name))
+}
+
+// Returns the URL of the object with the full key, its path sent exactly as it is signed
+func (s *S3Storage) keyURL(key string) (*url.URL, error) {
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + s.region() + ".amazonaws.com"
+	}
+	base, err := url.Parse(endpoint)
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", endpoint)
+	}
+	if s.PathStyle {
+		base.Path = "/" + s.Bucket + "/" + key
+	} else {
+		base.Host = s.Bucket + "." + base.Host
+		base.Path = "/" + key
+	}
+	base.RawPath = awsURIEncode(base.Path, false) // Go would leave characters such as = and ! bare
+	return base, nil
+}
+
+// Percent-encodes s the way SigV4 expects: everything but A-Za-z0-9-._~ in uppercase hex, and / too if slash is set
+func awsURIEncode(s string, slash bool) string {
+	var encoded strings.Builder
+	for _, b := range []byte(s) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', strings.IndexByte("-._~", b) >= 0, b == '/' && !slash:
+			encoded.WriteByte(b)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return encoded.String()
+}
+
+// Returns the SigV4 canonical query string: encoded name=value pairs sorted by name, then value
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for _, name := range sortedKeys(query) {
+		values := slices.Clone(query[name])
+		slices.Sort(values)
+		for _, value := range values {
+			pairs = append(pairs, awsURIEncode(name, true)+"="+awsURIEncode(value, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// Returns the signing region
+func (s *S3Storage) region() string {
+	if s.Region == "" {
+		return "us-east-1"
+	}
+	return s.Region
+}
+
+// Adds AWS Signature Version 4 headers to request; the payload is left unsigned so bodies can stream
+func (s *S3Storage) sign(request *http.Request, now time.Time) {
+	const payloadHash = "UNSIGNED-PAYLOAD"
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.SessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	signed := []string{"host"} // S3 requires every x-amz- header to be signed
+	for name := range request.Header {
+		if name := strings.ToLower(name); strings.HasPrefix(name, "x-amz-") || name == "content-md5" {
+			signed = append(signed, name)
+		}
+	}
+	slices.Sort(signed)
+	var headers strings.Builder
+	for _, name := range signed {
+		value := request.Header.Get(name)
+		if name == "host" {
+			value = request.URL.Host
+		}
+		headers.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	canonical := strings.Join([]string{
+		request.Method,
+		awsURIEncode(request.URL.Path, false),
+		canonicalQuery(request.URL.Query()),
+		headers.String(),
+		strings.Join(signed, ";"),
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + s.region() + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256(canonical)
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), day)
+	key = hmacSHA256(key, s.region())
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	request.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKey+"/"+scope+
+		", SignedHeaders="+strings.Join(signed, ";")+", Signature="+signature)
+}
+
+// HMAC-SHA256 of data under key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// Hex SHA-256 of a string
+func hexSHA256(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
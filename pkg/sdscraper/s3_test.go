@@ -0,0 +1,71 @@
+package sdscraper
+
+import ( // Import required packages
+	"net/http" // For the signed request
+	"net/url"  // For query strings
+	"strings"  // For reading the Authorization header
+	"testing"  // For the test harness
+	"time"     // For the signing date
+)
+
+func TestAWSURIEncode(t *testing.T) {
+	for _, test := range []struct {
+		in, want string
+		slash    bool
+	}{
+		{"/gojo/sds/gel.pdf", "/gojo/sds/gel.pdf", false},
+		{"/www.gojo.com__download_id=7_lang=en.pdf", "/www.gojo.com__download_id%3D7_lang%3Den.pdf", false},
+		{"/a b+c!(1)*'", "/a%20b%2Bc%21%281%29%2A%27", false},
+		{"/fiche-sécurité.pdf", "/fiche-s%C3%A9curit%C3%A9.pdf", false},
+		{"a/b", "a%2Fb", true},
+		{"A-Z_a.z~0", "A-Z_a.z~0", true},
+	} {
+		if got := awsURIEncode(test.in, test.slash); got != test.want {
+			t.Errorf("awsURIEncode(%q, %t) = %q, want %q", test.in, test.slash, got, test.want)
+		}
+	}
+}
+
+func TestCanonicalQuery(t *testing.T) {
+	for raw, want := range map[string]string{
+		"":                       "",
+		"object-lock=":           "object-lock=",
+		"versioning":             "versioning=",
+		"prefix=a b&list-type=2": "list-type=2&prefix=a%20b",
+		"b=2&a=y&a=x":            "a=x&a=y&b=2",
+		"marker=k%3D1%2F2":       "marker=k%3D1%2F2",
+	} {
+		query, _ := url.ParseQuery(raw)
+		if got := canonicalQuery(query); got != want {
+			t.Errorf("canonicalQuery(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestS3SignsTheSentPath(t *testing.T) {
+	for _, pathStyle := range []bool{false, true} {
+		s := &S3Storage{Bucket: "sds", Prefix: "gojo/", PathStyle: pathStyle, AccessKey: "AKID", SecretKey: "secret"}
+		objectURL, err := s.objectURL("www.gojo.com__download_id=7_lang=en (1).pdf")
+		if err != nil {
+			t.Fatal(err)
+		}
+		request, err := http.NewRequest(http.MethodGet, objectURL.String(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "/gojo/www.gojo.com__download_id%3D7_lang%3Den%20%281%29.pdf"
+		if pathStyle {
+			want = "/sds" + want
+		}
+		if got := request.URL.EscapedPath(); got != want {
+			t.Errorf("path style %t: sent path %q, want %q", pathStyle, got, want)
+		}
+		if got := awsURIEncode(request.URL.Path, false); got != request.URL.EscapedPath() {
+			t.Errorf("path style %t: signed path %q differs from the sent %q", pathStyle, got, request.URL.EscapedPath())
+		}
+		s.sign(request, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+		if auth := request.Header.Get("Authorization"); !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/20260102/us-east-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=") {
+			t.Errorf("path style %t: Authorization %q", pathStyle, auth)
+		}
+	}
+}
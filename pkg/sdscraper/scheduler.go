@@ -0,0 +1,86 @@
+package sdscraper
+
+import ( // Import required packages
+	"context" // For cancelling waits and marking priority
+	"slices"  // For removing abandoned waiters
+	"sync"    // For guarding the queues
+)
+
+// Scheduler bounds concurrent downloads and hands free slots to interactive requests before bulk ones
+type Scheduler struct {
+	mu       sync.Mutex
+	free     int             // Slots not held by any download
+	priority []chan struct{} // Waiting interactive requests, oldest first
+	bulk     []chan struct{} // Waiting sync downloads, oldest first
+}
+
+// NewScheduler returns a scheduler allowing slots downloads at once
+func NewScheduler(slots int) *Scheduler {
+	return &Scheduler{free: max(slots, 1)}
+}
+
+type priorityKey struct{} // Context key marking interactive requests
+
+// WithPriority marks downloads made with ctx as interactive, so they overtake queued bulk downloads
+func WithPriority(ctx context.Context) context.Context {
+	return context.WithValue(ctx, priorityKey{}, true)
+}
+
+// Waits for a slot, queueing in the lane ctx selects
+func (s *Scheduler) acquire(ctx context.Context) error {
+	priority, _ := ctx.Value(priorityKey{}).(bool)
+	s.mu.Lock()
+	ahead := len(s.priority) // Interactive requests only wait behind each other
+	if !priority {
+		ahead += len(s.bulk)
+	}
+	if s.free > 0 && ahead == 0 {
+		s.free--
+		s.mu.Unlock()
+		return nil
+	}
+	ready := make(chan struct{})
+	if priority {
+		s.priority = append(s.priority, ready)
+	} else {
+		s.bulk = append(s.bulk, ready)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		select {
+		case <-ready: // Granted just as we gave up; pass the slot on
+			s.releaseLocked()
+		default:
+			s.priority = slices.DeleteFunc(s.priority, func(c chan struct{}) bool { return c == ready })
+			s.bulk = slices.DeleteFunc(s.bulk, func(c chan struct{}) bool { return c == ready })
+		}
+		return ctx.Err()
+	}
+}
+
+// Returns a slot, waking the oldest interactive waiter first
+func (s *Scheduler) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.releaseLocked()
+}
+
+// Hands the slot to the next waiter or frees it; callers hold s.mu
+func (s *Scheduler) releaseLocked() {
+	switch {
+	case len(s.priority) > 0:
+		close(s.priority[0])
+		s.priority = s.priority[1:]
+	case len(s.bulk) > 0:
+		close(s.bulk[0])
+		s.bulk = s.bulk[1:]
+	default:
+		s.free++
+	}
+}
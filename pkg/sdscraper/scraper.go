@@ -0,0 +1,702 @@
+package sdscraper
+
+import ( // Import required packages
+	"cmp"           // For option defaults
+	"context"       // For cancellation
+	"errors"        // For matching robots refusals
+	"fmt"           // For error wrapping
+	"io"            // For releasing the renderer
+	"log/slog"      // For structured logging
+	"path/filepath" // For numbering cache files and locale manifest paths
+	"regexp"        // For document link patterns
+	"slices"        // For merging locales and copying tags
+	"strconv"       // For numbering cache files
+	"strings"       // For locale placeholders
+	"sync"          // For download workers
+	"time"          // For retention windows
+)
+
+const localePlaceholder = "{locale}" // Replaced with each configured locale in PageURL and CacheFile
+
+// Scraper discovers documents on a listing page and mirrors them with a Downloader
+type Scraper struct {
+	PageURL             string            // Listing page to scrape, may contain {locale}
+	Vendor              string            // Recorded on every discovered document, e.g. the site name in a multi-vendor crawl; empty to leave it
+	Reason              string            // Operator's note on why the run was started, e.g. an off-schedule sync, kept in the run history
+	ExtraPageURLs       []string          // Further listing pages of the same site, may contain {locale}
+	LinkPattern         *regexp.Regexp    // Matches document URLs, relative links resolved; nil for absolute .pdf links
+	CacheFile           string            // Local copy of the rendered listing page, may contain {locale}
+	Locales             []string          // Locales substituted into PageURL and CacheFile, none to use them verbatim
+	ManifestPath        string            // Where download state is kept between runs
+	LocaleManifests     string            // Per-locale manifest path with {locale}, relative ones inside the output directory, e.g. "{locale}/manifest.json"; empty to skip
+	CatalogDB           string            // SQLite catalog kept in sync with the manifest after each run, empty to skip; refused under encryption at rest
+	SearchIndex         string            // Full-text index of the PDFs updated after each run, empty to skip; refused under encryption at rest
+	PrintIndex          string            // Printable PDF index of the archive rewritten after each run, empty to skip
+	PrintIndexLinks     string            // Serve-mode address the print index's QR codes open, empty for source URLs
+	Renderer            Renderer          // Produces the listing page HTML
+	Downloader          *Downloader       // Fetches the discovered documents
+	DryRun              bool              // Plan the downloads without fetching documents or saving the manifest
+	DeleteRetention     time.Duration     // How long soft-deleted documents stay restorable, zero for DefaultDeleteRetention
+	Backup              *BackupPolicy     // Back up state files before each run, nil to skip
+	CheckpointPath      string            // Progress file letting an interrupted run resume, empty to disable
+	Workers             int               // Concurrent network transfers, zero for one
+	IOWorkers           int               // Concurrent validate, hash and write steps, zero for one
+	WarmUp              bool              // Resolve and connect to document hosts before downloading from them
+	CacheTTL            time.Duration     // Age after which a cached listing is rendered again, zero to keep it forever
+	ForceRefresh        bool              // Render every listing even if its cache is fresh
+	Notifiers           []Notifier        // Told about new and revised documents after each run
+	NotifyFirstSync     bool              // Send per-document events for the first sync into an empty archive too, instead of one baseline summary
+	Prune               bool              // Soft-delete catalogued documents the listings no longer show
+	AllowAnomalousPrune bool              // Prune even when the run's discovery looks anomalous
+	MaxPruneFraction    float64           // Largest share of the archive one run may prune, zero for DefaultMaxPruneFraction, negative to hold every prune
+	ForcePrune          bool              // Prune beyond MaxPruneFraction
+	AnomalyDrop         float64           // Fall below the usual discovery counts that marks a run anomalous, zero for DefaultAnomalyDrop
+	DeepCrawl           DeepCrawl         // Follows links from the listings to product pages; zero Depth to stay on the listings
+	Sources             []DiscoverySource // Seed lists, portals and other origins of documents, merged with the listings
+	FilenameTemplate    string            // Names new downloads from listing metadata, e.g. "{product}_{sku}_{lang}.pdf"; empty for URL-derived names
+	TagRules            []TagRule         // Tag discovered documents by their metadata
+	Tags                TagFilter         // Only download documents passing this filter; the zero filter downloads everything
+	PruneTags           TagFilter         // Only prune documents passing this filter
+	Features            Features          // Behaviours being rolled out, enabled per mirror
+	Network             *NetworkMonitor   // Pauses work while offline and retries transfers a network change broke, nil to ignore
+	Pause               *PauseControl     // Holds new downloads while an operator has the sync paused, nil for never
+}
+
+// Roles of the state files a scraper persists, naming them in backups
+const (
+	StateManifest   = "manifest"   // The manifest
+	StateCheckpoint = "checkpoint" // Progress of an interrupted run
+)
+
+// StateFiles returns the files the scraper persists between runs, which backups cover, by role
+func (s *Scraper) StateFiles() map[string]string {
+	files := map[string]string{StateManifest: s.ManifestPath}
+	if s.CheckpointPath != "" {
+		files[StateCheckpoint] = s.CheckpointPath
+	}
+	return files
+}
+
+// Actions a dry run can plan for a document
+const (
+	PlanDownload = "download" // No local copy yet
+	PlanRefresh  = "refresh"  // Local copy exists and will be revalidated with a conditional GET
+	PlanSkip     = "skip"     // Link is unusable or soft-deleted and will not be requested
+)
+
+// PlannedDownload is what a dry run would do with one discovered link
+type PlannedDownload struct {
+	URL      string // Source URL as found on the listing
+	Filename string // Target file inside the output directory
+	Action   string // PlanDownload, PlanRefresh or PlanSkip
+}
+
+// Result summarises one scraper run
+type Result struct {
+	Discovered  []string          // Every valid document URL found on the page
+	Downloaded  []string          // URLs written to disk during this run
+	NotModified []string          // URLs whose local copy was already current
+	Aliased     []string          // URLs whose content duplicates another document's file
+	Skipped     []string          // URLs not requested because robots.txt disallows them, they are soft-deleted or outside Tags
+	Planned     []PlannedDownload // What a dry run would have done, in discovery order
+	Failed      map[string]error  // URLs that could not be downloaded, with the reason
+	Added       []string          // Discovered URLs the manifest did not know before this run
+	Removed     []string          // Catalogued URLs no longer listed; empty unless discovery completed
+	Pruned      []string          // Removed URLs soft-deleted because Prune was set
+	PruneHeld   string            // Why Prune left Removed alone, empty if it did not
+	Confidence  float64           // How normal the discovery looks against earlier runs, 1 when there is no norm yet
+	Anomalies   []string          // Discovery counts far below the norm; they block pruning
+	Manifest    *Manifest         // Manifest as saved at the end of the run
+	Bytes       int64             // Size of the documents received, aliased ones included
+	Elapsed     time.Duration     // How long the run took
+
+	LowConfidence []string // Discovered URLs with a metadata field below LowConfidence, worth reviewing
+	Baseline      bool     // First sync into an empty archive, summarised to notifiers instead of announced per document
+	Reason        string   // The Scraper's Reason when the run started
+}
+
+// Updated returns the URLs already catalogued before this run whose content changed during it
+func (r *Result) Updated() []string {
+	var updated []string
+	for _, documentURL := range slices.Concat(r.Downloaded, r.Aliased) {
+		if !slices.Contains(r.Added, documentURL) {
+			updated = append(updated, documentURL)
+		}
+	}
+	return updated
+}
+
+// Fills Added and Removed by comparing the discovered documents with those catalogued before the run
+func (r *Result) diff(known map[string]bool, complete bool) {
+	listed := make(map[string]bool, len(r.Discovered))
+	for _, documentURL := range r.Discovered {
+		listed[documentURL] = true
+		if !known[documentURL] {
+			r.Added = append(r.Added, documentURL)
+		}
+	}
+	if !complete { // A partial listing would report everything else as removed
+		return
+	}
+	for documentURL := range known {
+		if !listed[documentURL] {
+			r.Removed = append(r.Removed, documentURL)
+		}
+	}
+	slices.Sort(r.Removed)
+}
+
+// Run renders each locale's listing page (unless cached), extracts document links and downloads each document once
+func (s *Scraper) Run(ctx context.Context) (*Result, error) {
+	if EncryptionEnabled() && (s.CatalogDB != "" || s.SearchIndex != "") { // Both would leak what the archive seals
+		return nil, ErrPlaintextCopy
+	}
+	if closer, ok := s.Renderer.(io.Closer); ok { // A browser kept for this run's listings
+		defer closer.Close()
+	}
+	if s.Backup != nil && !s.DryRun { // Protect state before this run touches it
+		if id, err := s.Backup.Backup(s.StateFiles()); err != nil {
+			return nil, fmt.Errorf("pre-run backup: %w", err)
+		} else if id != "" {
+			slog.Info("Backed up state", "backup", id)
+		}
+	}
+
+	if err := s.Pause.wait(ctx); err != nil { // A paused sync does not start new runs either
+		return nil, err
+	}
+	defer s.Network.start(ctx)()
+	if err := s.Network.wait(ctx); err != nil { // Started offline, e.g. before a laptop rejoined its network
+		return nil, err
+	}
+
+	started := time.Now()
+	s.Downloader.resetQuota()                     // MaxRunBytes counts per run
+	manifest, err := LoadManifest(s.ManifestPath) // Validators from previous runs
+	if err != nil {
+		return nil, fmt.Errorf("load manifest: %w", err)
+	}
+	result := &Result{Failed: make(map[string]error), Manifest: manifest, Confidence: 1}
+	defer func() { result.Elapsed = time.Since(started) }()
+	if result.Reason = s.Reason; s.Reason != "" {
+		slog.Info("Run started by an operator", "reason", s.Reason)
+	}
+
+	if s.DryRun { // Report the plan and leave network and manifest untouched
+		seen := make(map[string]bool)
+		byLocale := make(map[string]int)
+		err := s.discoverAll(ctx, result.Manifest, func(documentURL, locale string, valid bool, meta DocumentMetadata) bool {
+			if valid && locale != "" {
+				byLocale[locale]++
+			}
+			if !valid {
+				result.Planned = append(result.Planned, PlannedDownload{URL: documentURL, Action: PlanSkip})
+			} else if !seen[documentURL] {
+				seen[documentURL] = true
+				result.Discovered = append(result.Discovered, documentURL)
+				result.Planned = append(result.Planned, s.plan(ctx, result.Manifest, documentURL, locale, meta))
+			}
+			return true
+		})
+		if err == nil {
+			result.Confidence, result.Anomalies = result.Manifest.assessRun(RunStats{At: time.Now().UTC(), Discovered: len(result.Discovered), ByLocale: byLocale}, s.anomalyDrop())
+		}
+		return result, err
+	}
+
+	if s.Downloader.Storage == nil && !directoryExists(s.Downloader.OutputDir) { // Check if output folder exists
+		createDirectory(s.Downloader.OutputDir, 0o755) // If not, create it with permission
+	}
+
+	result.Manifest.PurgeDeleted(ctx, s.Downloader.storage(), cmp.Or(s.DeleteRetention, DefaultDeleteRetention)) // Expired soft deletes
+	if s.Downloader.Trash != nil {
+		s.Downloader.Trash.Empty() // Files past their grace period
+	}
+
+	known := result.Manifest.listedURLs() // Compared with this run's discoveries
+	state := &runState{result: result, done: make(map[string]bool), startedAt: time.Now().UTC(), byLocale: make(map[string]int), limiter: s.concurrencyLimiter()}
+	if checkpoint, ok := s.loadCheckpoint(); ok { // Left behind by an interrupted run
+		slog.Info("Resuming interrupted run", "started_at", checkpoint.StartedAt, "pending", len(checkpoint.Pending))
+		state.startedAt = checkpoint.StartedAt
+		result.Discovered = s.downloadOrder(result.Manifest, checkpoint.Pending)
+		state.resumed = true
+	}
+	result.Baseline = !s.NotifyFirstSync && result.Manifest.emptyBefore(state.startedAt) // Resumed first syncs still count
+
+	work := make(chan string) // Discovery feeds downloads as soon as each link is extracted
+	var discoverErr error
+	go func() {
+		defer close(work)
+		defer recoverPanic("discovery", "", func(recovered *PanicError) { discoverErr = recovered }) // Documents already queued still download
+		if state.resumed {                                                                           // Discovery already finished in the interrupted run
+			if s.WarmUp {
+				s.warmUp(ctx, result.Discovered, make(map[string]bool))
+			}
+			for _, documentURL := range result.Discovered {
+				select {
+				case work <- documentURL:
+				case <-ctx.Done():
+					return
+				}
+			}
+			return
+		}
+		discoverErr = s.discoverAll(ctx, result.Manifest, func(documentURL, locale string, valid bool, meta DocumentMetadata) bool {
+			if !valid || !state.discovered(s, documentURL, locale, meta) { // Shared documents are downloaded once
+				return true
+			}
+			select {
+			case work <- documentURL:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+		if discoverErr == nil {
+			state.mu.Lock()
+			state.listed = true // Pending documents can now be checkpointed completely
+			state.mu.Unlock()
+		}
+	}()
+
+	stores := make(chan storeJob, max(s.IOWorkers, 1)) // Network workers hand bodies to the disk pool
+	var network, disk sync.WaitGroup
+	for range max(s.Workers, 1) {
+		network.Add(1)
+		go func() {
+			defer network.Done()
+			for documentURL := range work {
+				if ctx.Err() == nil && s.Pause.wait(ctx) == nil && s.Network.wait(ctx) == nil && state.limiter.acquire(ctx) == nil { // After an interrupt, drain without downloading
+					func() {
+						defer state.limiter.release()
+						defer recoverPanic("fetch", documentURL, func(recovered *PanicError) { state.failed(s, recovered) })
+						s.fetch(ctx, state, documentURL, stores)
+					}()
+				}
+			}
+		}()
+	}
+	for range max(s.IOWorkers, 1) {
+		disk.Add(1)
+		go func() {
+			defer disk.Done()
+			for job := range stores { // Fetched bodies are stored even after an interrupt
+				func() {
+					defer recoverPanic("store", job.documentURL, func(recovered *PanicError) { state.failed(s, recovered) })
+					outcome, err := s.Downloader.store(context.WithoutCancel(ctx), job.body, &job.working, state)
+					s.finish(ctx, state, job, outcome, err)
+				}()
+			}
+		}()
+	}
+	network.Wait()
+	close(stores)
+	disk.Wait()
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	result.diff(known, state.listed)
+	result.LowConfidence = result.Manifest.lowConfidence(result.Discovered, LowConfidence)
+	if state.listed && ctx.Err() == nil { // Only complete discoveries say something about the site
+		result.Confidence, result.Anomalies = result.Manifest.assessRun(newRunStats(result, state.startedAt, state.byLocale), s.anomalyDrop())
+		s.prune(result, known)
+	}
+	if err := ctx.Err(); err != nil {
+		checkpoint := state.checkpoint()
+		s.persist(result.Manifest, checkpoint)
+		if checkpoint != nil {
+			slog.Warn("Interrupted; the next run resumes from here", "pending", len(checkpoint.Pending))
+		}
+		return result, err
+	}
+	result.Manifest.LocaleManifests = nil // Only the files written below are referenced
+	if s.LocaleManifests != "" {          // Written first, so the merged manifest only references files that exist
+		template := s.LocaleManifests
+		if !filepath.IsAbs(template) {
+			template = filepath.Join(s.Downloader.OutputDir, template)
+		}
+		if err := SaveLocaleManifests(result.Manifest, s.ManifestPath, template, s.Locales); err != nil {
+			slog.Error("Writing the per-locale manifests failed", "template", template, "err", err)
+		}
+	}
+	if err := SaveManifest(s.ManifestPath, result.Manifest); err != nil { // Persist validators for the next run
+		return result, fmt.Errorf("save manifest: %w", err)
+	}
+	if s.CatalogDB != "" { // A convenience copy; the manifest stays authoritative
+		if err := syncCatalogDB(context.WithoutCancel(ctx), s.CatalogDB, result.Manifest); err != nil {
+			slog.Error("Syncing the SQLite catalog failed", "path", s.CatalogDB, "err", err)
+		}
+	}
+	if s.SearchIndex != "" {
+		if err := updateSearchIndex(context.WithoutCancel(ctx), s.SearchIndex, result.Manifest, s.Downloader.storage()); err != nil {
+			slog.Error("Updating the search index failed", "path", s.SearchIndex, "err", err)
+		}
+	}
+	if s.PrintIndex != "" {
+		if err := SavePrintIndex(s.PrintIndex, result.Manifest, PrintIndexOptions{LinkBase: s.PrintIndexLinks}); err != nil {
+			slog.Error("Writing the print index failed", "path", s.PrintIndex, "err", err)
+		}
+	}
+	if discoverErr != nil { // Documents found before the failure were still mirrored
+		s.notify(ctx, result)
+		return result, discoverErr
+	}
+	s.clearCheckpoint() // Finished, so the next run starts fresh
+	s.notify(ctx, result)
+	return result, nil
+}
+
+// DefaultMaxPruneFraction is the largest share of the archive one run prunes unless forced
+const DefaultMaxPruneFraction = 0.1
+
+// Returns the configured prune limit; a negative MaxPruneFraction holds any prune for -force
+func (s *Scraper) maxPruneFraction() float64 {
+	switch {
+	case s.MaxPruneFraction == 0:
+		return DefaultMaxPruneFraction
+	case s.MaxPruneFraction < 0:
+		return 0
+	}
+	return s.MaxPruneFraction
+}
+
+// Returns the configured anomaly threshold
+func (s *Scraper) anomalyDrop() float64 {
+	if s.AnomalyDrop <= 0 {
+		return DefaultAnomalyDrop
+	}
+	return s.AnomalyDrop
+}
+
+// Soft-deletes the documents no longer listed when Prune is set, unless the run looks anomalous or would prune
+// more than MaxPruneFraction of the archived documents in the PruneTags scope, in which case the prune waits in the
+// manifest for review; known holds the documents listed before this run
+func (s *Scraper) prune(result *Result, known map[string]bool) {
+	if !s.Prune {
+		return
+	}
+	inScope := func(documentURL string) bool {
+		entry := result.Manifest.Documents[documentURL]
+		return entry != nil && s.PruneTags.Match(entry.Tags)
+	}
+	var scope []string // Unlisted documents inside the prune scope
+	for _, documentURL := range result.Removed {
+		if inScope(documentURL) {
+			scope = append(scope, documentURL)
+		}
+	}
+	archived := 0 // Listed documents inside the scope, which the fraction is of
+	for documentURL := range known {
+		if inScope(documentURL) {
+			archived++
+		}
+	}
+	if len(scope) == 0 {
+		result.Manifest.PendingPrune = nil // Nothing held earlier is still unlisted
+		return
+	}
+	if len(result.Anomalies) > 0 && !s.AllowAnomalousPrune { // A broken scrape must not empty the archive
+		result.PruneHeld = "discovery looks anomalous"
+		slog.Warn("Not pruning because this run's discovery looks anomalous", "unlisted", len(scope), "confidence", result.Confidence)
+		result.Manifest.holdPrune(result.PruneHeld, scope)
+		return
+	}
+	limit := s.maxPruneFraction()
+	if fraction := float64(len(scope)) / float64(archived); fraction > limit && !s.ForcePrune { // Likely a redesign, not a withdrawal
+		result.PruneHeld = fmt.Sprintf("would prune %.0f%% of the archive in scope, more than %.0f%%", fraction*100, limit*100)
+		slog.Warn("Not pruning so much of the archive without force", "unlisted", len(scope), "archived", archived, "limit", limit)
+		result.Manifest.holdPrune(result.PruneHeld, scope)
+		return
+	}
+	if len(result.Anomalies) > 0 { // The operator vouched for this run, so a lasting shrink becomes the new norm
+		result.Manifest.Runs[len(result.Manifest.Runs)-1].Anomalous = false
+	}
+	result.Manifest.PendingPrune = nil // This run's prune supersedes it
+	for _, documentURL := range scope {
+		if result.Manifest.SoftDelete(documentURL, "no longer listed") {
+			result.Pruned = append(result.Pruned, documentURL)
+		}
+	}
+	slog.Info("Pruned documents no longer listed", "count", len(result.Pruned))
+}
+
+// Renders and extracts every configured locale, calling found for each link in downloadOrder until it returns false
+func (s *Scraper) discoverAll(ctx context.Context, manifest *Manifest, found func(documentURL, locale string, valid bool, meta DocumentMetadata) bool) error {
+	locales := s.Locales // A single untagged pass when no locales are configured
+	if len(locales) == 0 {
+		locales = []string{""}
+	}
+	warmed := make(map[string]bool) // Hosts already warmed up this run
+	for _, locale := range locales {
+		links, invalid, metadata, err := s.discover(ctx, manifest, locale) // Links on this locale's listing
+		if err != nil {
+			return err
+		}
+		if s.WarmUp && !s.DryRun { // Before this locale's downloads start
+			s.warmUp(ctx, links, warmed)
+		}
+		for _, badURL := range invalid {
+			if !found(badURL, locale, false, DocumentMetadata{}) {
+				return ctx.Err()
+			}
+		}
+		for _, documentURL := range s.downloadOrder(manifest, links) {
+			if !found(documentURL, locale, true, metadata[documentURL]) {
+				return ctx.Err()
+			}
+		}
+	}
+	return s.discoverSources(ctx, found) // Documents no listing links, managed the same way
+}
+
+// A document moving from the network pool to the disk pool
+type storeJob struct {
+	documentURL string
+	entry       *ManifestEntry // The manifest's entry, updated once the download is done
+	working     ManifestEntry  // Copy the download fills in outside the lock
+	body        *fetchedBody   // Transferred content still to be stored, nil when there is none
+	started     time.Time
+	attempt     int // Transfers made, more than one when the network broke earlier ones
+}
+
+// Runs the network half of one document's download, queueing any body for the disk pool
+func (s *Scraper) fetch(ctx context.Context, state *runState, documentURL string, stores chan<- storeJob) {
+	state.mu.Lock()
+	entry := state.result.Manifest.EntryFor(documentURL)
+	if !entry.DeletedAt.IsZero() { // An admin or prune removed it; only a restore brings it back
+		state.finish(s, documentURL)
+		state.result.Skipped = append(state.result.Skipped, documentURL)
+		state.mu.Unlock()
+		slog.Debug("Soft-deleted, skipping", "url", documentURL)
+		return
+	}
+	if !s.Tags.Match(entry.Tags) {
+		state.finish(s, documentURL)
+		state.result.Skipped = append(state.result.Skipped, documentURL)
+		state.mu.Unlock()
+		slog.Debug("Outside the tag filter, skipping", "url", documentURL, "tags", entry.Tags)
+		return
+	}
+	job := storeJob{documentURL: documentURL, entry: entry, working: *entry, started: time.Now(), attempt: 1} // Copy so other workers can read the manifest meanwhile
+	state.mu.Unlock()
+
+	body, outcome, err := s.Downloader.fetch(ctx, documentURL, &job.working)
+	for retry := 1; retry <= maxNetworkRetries && s.Network.disrupted(ctx, job.started, err); retry++ {
+		slog.Info("Network broke the transfer; retrying once it is back", "url", documentURL, "retry", retry, "err", err)
+		if s.Network.wait(ctx) != nil {
+			break
+		}
+		state.mu.Lock()
+		job.working, job.started, job.attempt = *entry, time.Now(), retry+1 // Start over from the catalogued state
+		state.mu.Unlock()
+		body, outcome, err = s.Downloader.fetch(ctx, documentURL, &job.working)
+	}
+	state.limiter.observe(err)
+	if err == nil && body != nil {
+		job.body = body
+		stores <- job // Blocks while the disk pool is saturated
+		return
+	}
+	s.finish(ctx, state, job, outcome, err)
+}
+
+// Publishes a finished download to the manifest and files the outcome in the run's result
+func (s *Scraper) finish(ctx context.Context, state *runState, job storeJob, outcome Outcome, err error) {
+	logDownload(job.documentURL, &job.working, job.started, job.attempt, outcome, err)
+	s.Downloader.Metrics.observeDownload(job.working.Size, job.started, outcome, err)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if err != nil && ctx.Err() != nil { // The interrupted document is retried on resume
+		return
+	}
+	entry := job.entry
+	job.working.Locales = mergeLocales(entry.Locales, job.working.Locales) // Keep locales discovered while downloading
+	*entry = job.working
+	result := state.result
+	switch {
+	case errors.Is(err, ErrRobotsDisallowed): // Not a failure; the site opted out
+		result.Skipped = append(result.Skipped, job.documentURL)
+	case err != nil: // Already logged above
+		result.Failed[job.documentURL] = err
+	case outcome == OutcomeDownloaded:
+		result.Downloaded = append(result.Downloaded, job.documentURL)
+		result.Bytes += entry.Size
+		result.Manifest.RecordChange(ChangeUpdated, entry)
+	case outcome == OutcomeAliased:
+		result.Aliased = append(result.Aliased, job.documentURL)
+		result.Bytes += entry.Size
+		result.Manifest.RecordChange(ChangeUpdated, entry)
+	default:
+		result.NotModified = append(result.NotModified, job.documentURL)
+	}
+	state.finish(s, job.documentURL)
+}
+
+// Decides what a real run would do with a discovered document, tagging a copy of its entry as the run would
+func (s *Scraper) plan(ctx context.Context, m *Manifest, documentURL, locale string, meta DocumentMetadata) PlannedDownload {
+	filename := URLToFilename(documentURL) // Where a fresh download would land
+	if entry, ok := m.Documents[documentURL]; ok && entry.Filename != "" {
+		filename = entry.Filename // Aliases point at the original's file
+	}
+	tagged := ManifestEntry{URL: documentURL, Filename: filename}
+	if entry, ok := m.Documents[documentURL]; ok {
+		tagged = *entry
+		tagged.Tags = slices.Clone(entry.Tags) // The dry run leaves the manifest alone
+	}
+	if locale != "" {
+		tagged.Locales = mergeLocales(tagged.Locales, []string{locale})
+	}
+	tagged.applyMetadata(meta)
+	ApplyTagRules(&tagged, s.TagRules)
+	s.Downloader.Rules.tagListed(&tagged)
+	action := PlanDownload
+	if entry, ok := m.Documents[documentURL]; ok && !entry.DeletedAt.IsZero() || !s.Tags.Match(tagged.Tags) {
+		action = PlanSkip
+	} else if s.Downloader.stored(ctx, filename) {
+		action = PlanRefresh
+	}
+	return PlannedDownload{URL: documentURL, Filename: filename, Action: action}
+}
+
+// Returns the valid and invalid document links on one locale's listing pages and what the pages say about them, rendering them unless cached
+func (s *Scraper) discover(ctx context.Context, manifest *Manifest, locale string) ([]string, []string, map[string]DocumentMetadata, error) {
+	var links, invalid, extensionless []string
+	metadata := make(map[string]DocumentMetadata)
+	seen := make(map[string]bool) // Pages of one site often link the same documents
+	pattern := s.LinkPattern
+	probing := pattern == nil && len(s.Downloader.Types) > 0
+	if probing { // Links ending in any configured type's extension
+		pattern = DocumentLinkPattern(s.Downloader.Types)
+	}
+	extract := func(content, pageURL string) {
+		if probing { // Links without an extension may be documents too; the server's headers decide
+			for _, link := range ExtractLinks(content, pageURL, extensionlessLinkPattern) {
+				if !seen[link] {
+					seen[link] = true
+					extensionless = append(extensionless, link)
+				}
+			}
+			for documentURL, meta := range ExtractLinkMetadata(content, pageURL, extensionlessLinkPattern) {
+				if _, ok := metadata[documentURL]; !ok {
+					metadata[documentURL] = meta // Looked up only for the links that turn out to be documents
+				}
+			}
+		}
+		extracted := ExtractPDFLinks(content) // Extract all PDF links
+		pageMetadata := ExtractDocumentMetadata(content)
+		if pattern != nil {
+			extracted = ExtractLinks(content, pageURL, pattern) // Site-specific links, relative ones included
+			pageMetadata = ExtractLinkMetadata(content, pageURL, pattern)
+		}
+
+		for _, documentURL := range extracted {
+			if seen[documentURL] {
+				continue
+			}
+			seen[documentURL] = true
+			if isUrlValid(documentURL) { // Check if URL is valid
+				links = append(links, documentURL)
+			} else {
+				invalid = append(invalid, documentURL)
+			}
+		}
+		for documentURL, meta := range pageMetadata {
+			if _, ok := metadata[documentURL]; !ok {
+				metadata[documentURL] = meta
+			}
+		}
+	}
+
+	var queue []crawlPage        // Pages the deep crawl visits after the listings
+	guard := s.DeepCrawl.guard() // Pages already queued, and the traps avoided
+	listings := append([]string{s.PageURL}, s.ExtraPageURLs...)
+	for _, pageTemplate := range listings {
+		guard.mark(strings.ReplaceAll(pageTemplate, localePlaceholder, locale)) // Listings are never crawled again
+	}
+	for index, pageTemplate := range listings {
+		pageURL := strings.ReplaceAll(pageTemplate, localePlaceholder, locale)                           // This locale's listing
+		cacheFile := listingCacheFile(strings.ReplaceAll(s.CacheFile, localePlaceholder, locale), index) // This locale's cached copy
+
+		if !s.listingFresh(cacheFile) { // Missing, expired or a refresh was forced
+			remoteHTML, err := s.Renderer.Render(ctx, pageURL) // Scrape page
+			switch {
+			case err == nil:
+				writeListingCache(cacheFile, pageURL, remoteHTML) // Replace the stale copy
+			case fileExists(cacheFile) && ctx.Err() == nil: // A stale listing beats none
+				slog.Warn("Rendering failed, using the stale cached listing", "url", pageURL, "path", cacheFile, "err", err)
+			default:
+				return nil, nil, nil, fmt.Errorf("render %s: %w", pageURL, err) // Nothing cached to fall back on
+			}
+		}
+
+		localFileContent := readAFileAsString(cacheFile) // Read saved HTML content
+		extract(localFileContent, pageURL)
+		if s.DeepCrawl.Depth > 0 {
+			queue = s.enqueueLinks(queue, guard, localFileContent, crawlPage{url: pageURL})
+		}
+	}
+
+	limit := cmp.Or(s.DeepCrawl.MaxPages, DefaultDeepCrawlPages)
+	for crawled := 0; len(queue) > 0 && ctx.Err() == nil; { // Breadth first, so shallow pages survive the limit
+		page := queue[0]
+		queue = queue[1:]
+		if crawled == limit {
+			slog.Warn("Deep crawl stopped at its page limit", "locale", locale, "limit", limit, "unvisited", len(queue)+1)
+			break
+		}
+		crawled++
+		content, ok := s.renderCrawled(ctx, page.url)
+		if !ok || guard.duplicate(content, page.url) { // A repeat holds no new links either
+			continue
+		}
+		extract(content, page.url)
+		if page.depth < s.DeepCrawl.Depth {
+			queue = s.enqueueLinks(queue, guard, content, page)
+		}
+	}
+	guard.report(locale)
+	links = append(links, s.probeLinks(ctx, manifest, extensionless)...)
+	if err := ctx.Err(); err != nil {
+		return nil, nil, nil, err // A cut-short crawl is an incomplete discovery
+	}
+	return links, invalid, metadata, nil
+}
+
+// Returns the extensionless links that serve one of the configured types: those already catalogued, and those the
+// server's headers classify
+func (s *Scraper) probeLinks(ctx context.Context, manifest *Manifest, candidates []string) []string {
+	var documents []string
+	probed := 0
+	for _, link := range candidates {
+		if ctx.Err() != nil {
+			break
+		}
+		if _, known := manifest.Documents[link]; !known {
+			if !s.Downloader.probe(ctx, link) {
+				continue
+			}
+			probed++
+		}
+		documents = append(documents, link)
+	}
+	if len(candidates) > 0 {
+		slog.Debug("Probed extensionless links", "candidates", len(candidates), "documents", len(documents), "new", probed)
+	}
+	return documents
+}
+
+// Returns the cache file of the listing page at index: the configured name for the first page, numbered after it for the rest
+func listingCacheFile(cacheFile string, index int) string {
+	if index == 0 {
+		return cacheFile
+	}
+	extension := filepath.Ext(cacheFile)
+	return strings.TrimSuffix(cacheFile, extension) + "-" + strconv.Itoa(index+1) + extension
+}
+
+// Adds the locales in extra to existing, keeping the result sorted and unique
+func mergeLocales(existing, extra []string) []string {
+	merged := append(slices.Clone(existing), extra...)
+	slices.Sort(merged)
+	return slices.Compact(merged)
+}
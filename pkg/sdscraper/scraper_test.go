@@ -0,0 +1,212 @@
+package sdscraper_test
+
+import ( // Import required packages
+	"bytes"         // For keys and stored content
+	"context"       // For running the scraper
+	"crypto/sha256" // For checking recorded hashes
+	"encoding/hex"  // For hex digests
+	"maps"          // For the failed URLs
+	"os"            // For checking stored files
+	"path/filepath" // For file paths
+	"slices"        // For comparing URL sets
+	"testing"       // For the test harness
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper"         // The scraper under test
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper/sdstest" // The fixture site
+)
+
+// Runs a fixture site's scraper into dir, failing the test on a run error
+func run(t *testing.T, site *sdstest.Site, dir string, adjust func(*sdscraper.Scraper)) *sdscraper.Result {
+	t.Helper()
+	scraper := site.Scraper(dir)
+	if adjust != nil {
+		adjust(scraper)
+	}
+	result, err := scraper.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	return result
+}
+
+// Fetches one document at a time, so the gel comes before its copy and the copy is the one recorded as an alias
+func sequential(s *sdscraper.Scraper) { s.Workers = 1 }
+
+// Returns the SHA-256 of data
+func sum(data []byte) []byte {
+	digest := sha256.Sum256(data)
+	return digest[:]
+}
+
+// Fails the test unless got and want hold the same URLs in any order
+func sameURLs(t *testing.T, name string, got, want []string) {
+	t.Helper()
+	got, want = slices.Sorted(slices.Values(got)), slices.Sorted(slices.Values(want))
+	if !slices.Equal(got, want) {
+		t.Errorf("%s = %q, want %q", name, got, want)
+	}
+}
+
+func TestRunFirstSync(t *testing.T) {
+	site := sdstest.NewSite()
+	defer site.Close()
+	dir := t.TempDir()
+	result := run(t, site, dir, sequential)
+
+	expected := site.Expect(false)
+	sameURLs(t, "Discovered", result.Discovered, slices.Concat(expected.Downloaded, expected.Aliased, expected.Failed))
+	sameURLs(t, "Downloaded", result.Downloaded, expected.Downloaded)
+	sameURLs(t, "Aliased", result.Aliased, expected.Aliased)
+	sameURLs(t, "Failed", slices.Collect(maps.Keys(result.Failed)), expected.Failed)
+	sameURLs(t, "Added", result.Added, result.Discovered) // Everything is new to an empty archive
+	if !result.Baseline {
+		t.Error("first sync into an empty archive is not marked as a baseline")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "rejects", sdscraper.URLToFilename(site.DocumentURL(sdstest.BrokenPath)))); err != nil {
+		t.Errorf("the HTML error page was not quarantined: %v", err)
+	}
+}
+
+func TestRunManifest(t *testing.T) {
+	site := sdstest.NewSite()
+	defer site.Close()
+	dir := t.TempDir()
+	run(t, site, dir, sequential)
+
+	manifest, err := sdscraper.ReadManifest(filepath.Join(dir, "manifest.json")) // As saved, not as held in memory
+	if err != nil {
+		t.Fatalf("ReadManifest: %v", err)
+	}
+	gelURL, copyURL := site.DocumentURL(sdstest.GelPath), site.DocumentURL(sdstest.GelCopyPath)
+	gel, ok := manifest.Documents[gelURL]
+	if !ok {
+		t.Fatalf("no manifest entry for %s", gelURL)
+	}
+	if gel.Filename != sdscraper.URLToFilename(gelURL) {
+		t.Errorf("Filename = %q, want the URL-derived %q", gel.Filename, sdscraper.URLToFilename(gelURL))
+	}
+	if gel.Product != "PURELL Advanced Hand Sanitizer Gel" || gel.SKU != "9652-12" || gel.Revision != "2024-01-02" {
+		t.Errorf("listing metadata = %q, %q, %q", gel.Product, gel.SKU, gel.Revision)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "PDFs", gel.Filename))
+	if err != nil {
+		t.Fatalf("stored file: %v", err)
+	}
+	if gel.SHA256 != hex.EncodeToString(sum(data)) || gel.Size != int64(len(data)) {
+		t.Errorf("SHA256 %s and Size %d do not describe the stored file", gel.SHA256, gel.Size)
+	}
+	if gel.DownloadedAt.IsZero() || gel.CheckedAt.IsZero() {
+		t.Error("download and check times are not recorded")
+	}
+
+	duplicate, ok := manifest.Documents[copyURL]
+	if !ok {
+		t.Fatalf("no manifest entry for %s", copyURL)
+	}
+	if duplicate.AliasOf != gelURL || duplicate.SHA256 != gel.SHA256 {
+		t.Errorf("duplicate: AliasOf %q, SHA256 %s; want an alias of %s with the same hash", duplicate.AliasOf, duplicate.SHA256, gelURL)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "PDFs", sdscraper.URLToFilename(copyURL))); err == nil {
+		t.Error("the duplicate was stored a second time")
+	}
+	if missing := manifest.Documents[site.DocumentURL(sdstest.MissingPath)]; missing != nil && missing.SHA256 != "" {
+		t.Errorf("the missing document has a hash: %+v", missing)
+	}
+	if len(manifest.Changes) == 0 || len(manifest.Runs) != 1 {
+		t.Errorf("%d changes and %d runs recorded, want some changes and one run", len(manifest.Changes), len(manifest.Runs))
+	}
+}
+
+func TestRunRetriesAndRevalidates(t *testing.T) {
+	site := sdstest.NewSite()
+	defer site.Close()
+	dir := t.TempDir()
+	first := run(t, site, dir, nil)
+	if _, failed := first.Failed[site.DocumentURL(sdstest.ThrottledPath)]; !failed {
+		t.Fatal("the throttled document did not fail on the first run")
+	}
+
+	second := run(t, site, dir, nil)
+	expected := site.Expect(false)
+	sameURLs(t, "Downloaded", second.Downloaded, expected.Retried)
+	sameURLs(t, "NotModified", second.NotModified, slices.Concat(expected.Downloaded, expected.Aliased))
+	if len(second.Added) != 0 {
+		t.Errorf("Added = %q on an unchanged listing", second.Added)
+	}
+	if got := site.Requests(sdstest.ThrottledPath); got != 2 {
+		t.Errorf("throttled document requested %d times, want 2", got)
+	}
+	if problems := sdscraper.Fsck(second.Manifest, sdscraper.FsckOptions{OutputDir: filepath.Join(dir, "PDFs"), VerifyHashes: true}); len(problems) > 0 {
+		t.Errorf("fsck after the retry: %+v", problems)
+	}
+}
+
+func TestRunFilenameTemplate(t *testing.T) {
+	site := sdstest.NewSite()
+	defer site.Close()
+	dir := t.TempDir()
+	result := run(t, site, dir, func(s *sdscraper.Scraper) { s.FilenameTemplate = "{product}_{sku}.pdf" })
+
+	entry := result.Manifest.Documents[site.DocumentURL(sdstest.FoamPath)]
+	if entry == nil {
+		t.Fatal("no manifest entry for the foam")
+	}
+	if want := sdscraper.RenderFilename("{product}_{sku}.pdf", entry); entry.Filename != want || want == sdscraper.URLToFilename(entry.URL) {
+		t.Errorf("Filename = %q, want the templated %q", entry.Filename, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "PDFs", entry.Filename)); err != nil {
+		t.Errorf("templated file not stored: %v", err)
+	}
+}
+
+func TestRunDryRun(t *testing.T) {
+	site := sdstest.NewSite()
+	defer site.Close()
+	dir := t.TempDir()
+	result := run(t, site, dir, func(s *sdscraper.Scraper) { s.DryRun = true })
+
+	if len(result.Planned) != len(result.Discovered) {
+		t.Errorf("%d planned actions for %d discovered documents", len(result.Planned), len(result.Discovered))
+	}
+	for _, planned := range result.Planned {
+		if planned.Action == sdscraper.PlanRefresh {
+			t.Errorf("%s planned as a refresh in an empty archive", planned.URL)
+		}
+	}
+	if site.Requests(sdstest.GelPath) != 0 {
+		t.Error("a dry run fetched a document")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "manifest.json")); !os.IsNotExist(err) {
+		t.Errorf("a dry run saved the manifest: %v", err)
+	}
+}
+
+func TestRunEncrypted(t *testing.T) {
+	if err := sdscraper.SetEncryptionKey(bytes.Repeat([]byte{7}, 32)); err != nil {
+		t.Fatal(err)
+	}
+	defer sdscraper.SetEncryptionKey(nil)
+	site := sdstest.NewSite()
+	defer site.Close()
+	dir := t.TempDir()
+	run(t, site, dir, nil)
+
+	second := run(t, site, dir, nil) // Reads the sealed manifest back
+	entry := second.Manifest.Documents[site.DocumentURL(sdstest.GelPath)]
+	stored, err := os.ReadFile(filepath.Join(dir, "PDFs", entry.Filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.HasPrefix(stored, []byte("%PDF")) || entry.Size >= int64(len(stored)) {
+		t.Error("the document was stored in plaintext")
+	}
+	sameURLs(t, "NotModified", second.NotModified, slices.Concat(site.Expect(false).Downloaded, site.Expect(false).Aliased))
+	if problems := sdscraper.Fsck(second.Manifest, sdscraper.FsckOptions{OutputDir: filepath.Join(dir, "PDFs"), VerifyHashes: true}); len(problems) > 0 {
+		t.Errorf("fsck of the encrypted archive: %+v", problems)
+	}
+
+	sdscraper.SetEncryptionKey(bytes.Repeat([]byte{8}, 32))
+	if _, err := site.Scraper(dir).Run(context.Background()); err == nil {
+		t.Error("a run under another key did not fail on the manifest")
+	}
+}
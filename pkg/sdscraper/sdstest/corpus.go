@@ -0,0 +1,310 @@
+package sdstest
+
+import ( // Import required packages
+	"bytes"           // For building documents
+	"crypto/md5"      // For the standard security handler's keys
+	"crypto/rc4"      // For encrypting streams
+	"crypto/sha256"   // For the index
+	"encoding/binary" // For key derivation inputs
+	"encoding/json"   // For the index
+	"fmt"             // For names and PDF objects
+	"math/rand/v2"    // For deterministic variety
+	"os"              // For writing the corpus
+	"path/filepath"   // For file paths
+	"strings"         // For content streams
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // For read-only mode
+)
+
+// Kinds of corpus document
+const (
+	KindText      = "text"       // Multi-page SDS with the sixteen GHS sections
+	KindLarge     = "large"      // Text SDS padded to a target size
+	KindEncrypted = "encrypted"  // RC4-encrypted with an owner password only, so it opens without one like secured vendor PDFs
+	KindImageOnly = "image-only" // A scanned page: one image, no text layer
+	KindTruncated = "truncated"  // Cut off mid-transfer, without the %%EOF trailer
+	KindBroken    = "broken"     // Ends in %%EOF, so only structural validation finds its missing page tree
+	KindHTML      = "html"       // An error page served as a PDF
+)
+
+// CorpusOptions shapes a generated corpus; the same options always produce the same bytes
+type CorpusOptions struct {
+	Seed       int64   // Varies products, values and page counts
+	Documents  int     // Text documents, zero for five
+	LargeSizes []int64 // Sizes of the large documents in bytes, nil for 1 MiB and 8 MiB
+}
+
+// CorpusDocument is one generated file and what a correct pipeline should make of it
+type CorpusDocument struct {
+	Name       string `json:"name"` // File name, e.g. "text-001.pdf"
+	Kind       string `json:"kind"` // One of the Kind constants
+	Product    string `json:"product,omitempty"`
+	SKU        string `json:"sku,omitempty"`
+	Pages      int    `json:"pages,omitempty"`
+	Valid      bool   `json:"valid"`      // Passes the downloader's header and trailer checks
+	Structural bool   `json:"structural"` // Passes structural validation as well
+	Size       int64  `json:"size"`
+	SHA256     string `json:"sha256"`
+	Data       []byte `json:"-"`
+}
+
+// Products and SKU families the generated documents are named after
+var corpusProducts = []string{
+	"PURELL Advanced Hand Sanitizer Gel", "PROVON Foaming Handwash", "GOJO Hand Medic", "PURELL Surface Sanitizer",
+	"GOJO Natural Orange Pumice Hand Cleaner", "PURELL Foodservice Surface Sanitizer", "GOJO Clear & Mild Foam Handwash",
+	"PROVON Antimicrobial Lotion Soap",
+}
+
+// Headings of the sixteen sections of a GHS safety data sheet
+var sdsSections = []string{
+	"Identification", "Hazard(s) identification", "Composition/information on ingredients", "First-aid measures",
+	"Fire-fighting measures", "Accidental release measures", "Handling and storage", "Exposure controls/personal protection",
+	"Physical and chemical properties", "Stability and reactivity", "Toxicological information", "Ecological information",
+	"Disposal considerations", "Transport information", "Regulatory information", "Other information",
+}
+
+// Corpus generates SDS-like PDFs of every kind, deterministically from options
+func Corpus(options CorpusOptions) []CorpusDocument {
+	count := options.Documents
+	if count <= 0 {
+		count = 5
+	}
+	sizes := options.LargeSizes
+	if sizes == nil {
+		sizes = []int64{1 << 20, 8 << 20}
+	}
+	random := rand.New(rand.NewPCG(uint64(options.Seed), 0x5d5)) // Same stream for the same seed
+	var documents []CorpusDocument
+	for i := 1; i <= count; i++ {
+		product, sku := corpusProduct(random)
+		pages := 1 + random.IntN(4)
+		documents = append(documents, corpusDocument(fmt.Sprintf("text-%03d.pdf", i), KindText, product, sku, pages, true, true,
+			assemble(textObjects(sdsLines(random, product, sku), pages, 0), "")))
+	}
+	for i, size := range sizes {
+		product, sku := corpusProduct(random)
+		documents = append(documents, corpusDocument(fmt.Sprintf("large-%03d.pdf", i+1), KindLarge, product, sku, 1, true, true,
+			assemble(textObjects(sdsLines(random, product, sku), 1, size), "")))
+	}
+
+	product, sku := corpusProduct(random)
+	documents = append(documents, corpusDocument("encrypted-001.pdf", KindEncrypted, product, sku, 1, true, true,
+		encrypted(textObjects(sdsLines(random, product, sku), 1, 0), random)))
+
+	product, sku = corpusProduct(random)
+	documents = append(documents, corpusDocument("image-only-001.pdf", KindImageOnly, product, sku, 1, true, true,
+		assemble(imageObjects(random), "")))
+
+	product, sku = corpusProduct(random)
+	whole := assemble(textObjects(sdsLines(random, product, sku), 2, 0), "")
+	documents = append(documents, corpusDocument("truncated-001.pdf", KindTruncated, product, sku, 2, false, false, whole[:len(whole)*2/3]))
+
+	product, sku = corpusProduct(random)
+	documents = append(documents, corpusDocument("broken-001.pdf", KindBroken, product, sku, 1, true, false,
+		brokenTree(assemble(textObjects(sdsLines(random, product, sku), 1, 0), ""))))
+
+	documents = append(documents, corpusDocument("html-001.pdf", KindHTML, "", "", 0, false, false,
+		[]byte("<!DOCTYPE html>\n<html><head><title>503 Service Unavailable</title></head><body><h1>Service Unavailable</h1></body></html>\n")))
+	return documents
+}
+
+// WriteCorpus writes documents to dir along with corpus.json, the index of their names, kinds, hashes and expected
+// validity
+func WriteCorpus(dir string, documents []CorpusDocument) error {
+	if err := sdscraper.CheckWritable("write", dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, document := range documents {
+		if err := os.WriteFile(filepath.Join(dir, document.Name), document.Data, 0o644); err != nil {
+			return err
+		}
+	}
+	index, err := json.MarshalIndent(documents, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "corpus.json"), append(index, '\n'), 0o644)
+}
+
+// Fills in a document's size and hash
+func corpusDocument(name, kind, product, sku string, pages int, valid, structural bool, data []byte) CorpusDocument {
+	return CorpusDocument{
+		Name: name, Kind: kind, Product: product, SKU: sku, Pages: pages, Valid: valid, Structural: structural,
+		Size: int64(len(data)), SHA256: fmt.Sprintf("%x", sha256.Sum256(data)), Data: data,
+	}
+}
+
+// Picks a product and makes up a SKU for it
+func corpusProduct(random *rand.Rand) (string, string) {
+	return corpusProducts[random.IntN(len(corpusProducts))], fmt.Sprintf("%04d-%02d", 1000+random.IntN(9000), 1+random.IntN(24))
+}
+
+// Returns the text lines of an SDS, with values varying by random
+func sdsLines(random *rand.Rand, product, sku string) []string {
+	lines := []string{
+		"SAFETY DATA SHEET",
+		"Product name: " + product,
+		"SKU: " + sku,
+		fmt.Sprintf("Revision date: 20%02d-%02d-%02d", 18+random.IntN(8), 1+random.IntN(12), 1+random.IntN(28)),
+	}
+	for i, heading := range sdsSections {
+		lines = append(lines, "", fmt.Sprintf("SECTION %d: %s", i+1, heading))
+		switch i + 1 {
+		case 2: // Hazard statements of an ethanol-based product
+			lines = append(lines, "H225 Highly flammable liquid and vapour.", "H319 Causes serious eye irritation.")
+		case 3:
+			lines = append(lines, fmt.Sprintf("Ethyl alcohol, CAS 64-17-5: %d - %d %%", 60+random.IntN(10), 70+random.IntN(10)))
+		case 9:
+			lines = append(lines, fmt.Sprintf("Flash point: %d C", 15+random.IntN(10)), fmt.Sprintf("pH: %.1f", 5+random.Float64()*3))
+		default:
+			lines = append(lines, fmt.Sprintf("Reference %d-%04d. See the product label for further information.", i+1, random.IntN(10000)))
+		}
+	}
+	return lines
+}
+
+// Returns the objects of a text PDF spreading lines over pages, with its content padded so the file reaches size
+func textObjects(lines []string, pages int, size int64) [][]byte {
+	perPage := (len(lines) + pages - 1) / pages
+	objects := [][]byte{
+		[]byte("<< /Type /Catalog /Pages 2 0 R >>"),
+		nil, // Pages, once the kids are known
+		[]byte("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>"),
+	}
+	var kids []string
+	for page := 0; page < pages; page++ {
+		var content strings.Builder
+		content.WriteString("BT /F1 11 Tf 14 TL 72 740 Td\n")
+		for _, line := range lines[min(page*perPage, len(lines)):min((page+1)*perPage, len(lines))] {
+			fmt.Fprintf(&content, "(%s) Tj T*\n", escapeText(line))
+		}
+		content.WriteString("ET")
+		if page == 0 && size > 0 {
+			pad(&content, size)
+		}
+		objects = append(objects, stream("", []byte(content.String())))
+		kids = append(kids, fmt.Sprintf("%d 0 R", len(objects)+1))
+		objects = append(objects, fmt.Appendf(nil, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents %d 0 R /Resources << /Font << /F1 3 0 R >> >> >>", len(objects)))
+	}
+	objects[1] = fmt.Appendf(nil, "<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), pages)
+	return objects
+}
+
+// Appends comment lines to a content stream until the file will be about size bytes
+func pad(content *strings.Builder, size int64) {
+	const overhead = 1200 // The rest of a one-page document
+	filler := strings.Repeat("0123456789abcdef", 4)
+	for int64(content.Len())+overhead < size {
+		content.WriteString("\n% ")
+		content.WriteString(filler)
+	}
+}
+
+// Returns the objects of a one-page PDF holding only a grey noise image, as a scanner would produce
+func imageObjects(random *rand.Rand) [][]byte {
+	const width, height = 320, 160
+	pixels := make([]byte, width*height)
+	for i := range pixels {
+		pixels[i] = byte(180 + random.IntN(76)) // Light paper with specks
+	}
+	content := fmt.Appendf(nil, "q %d 0 0 %d 72 420 cm /Im1 Do Q", width*3/2, height*3/2)
+	return [][]byte{
+		[]byte("<< /Type /Catalog /Pages 2 0 R >>"),
+		[]byte("<< /Type /Pages /Kids [3 0 R] /Count 1 >>"),
+		[]byte("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /XObject << /Im1 5 0 R >> >> >>"),
+		stream("", content),
+		stream(fmt.Sprintf(" /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent 8", width, height), pixels),
+	}
+}
+
+// Returns a stream object with extra dictionary entries
+func stream(entries string, data []byte) []byte {
+	var object bytes.Buffer
+	fmt.Fprintf(&object, "<<%s /Length %d >>\nstream\n", entries, len(data))
+	object.Write(data)
+	object.WriteString("\nendstream")
+	return object.Bytes()
+}
+
+// Escapes text for a PDF literal string
+func escapeText(text string) string {
+	return strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`).Replace(text)
+}
+
+// Assembles a PDF from its objects, numbered from 1, with a correct cross-reference table and extra trailer entries
+func assemble(objects [][]byte, trailer string) []byte {
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n") // Binary marker line, as producers write it
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n", i+1)
+		out.Write(object)
+		out.WriteString("\nendobj\n")
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R%s >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, trailer, xref)
+	return out.Bytes()
+}
+
+// Points the catalog at a page tree that does not exist; the replacement keeps every offset and the trailer intact
+func brokenTree(data []byte) []byte {
+	return bytes.Replace(data, []byte("/Pages 2 0 R >>"), []byte("/Pages 9 0 R >>"), 1)
+}
+
+// Padding of the standard security handler (PDF 1.7, 7.6.3.3)
+var securityPadding = []byte{
+	0x28, 0xbf, 0x4e, 0x5e, 0x4e, 0x75, 0x8a, 0x41, 0x64, 0x00, 0x4e, 0x56, 0xff, 0xfa, 0x01, 0x08,
+	0x2e, 0x2e, 0x00, 0xb6, 0xd0, 0x68, 0x3e, 0x80, 0x2f, 0x0c, 0xa9, 0xfe, 0x64, 0x53, 0x69, 0x7a,
+}
+
+// Encrypts a document's streams with 40-bit RC4 (standard security handler, revision 2) under an owner password,
+// with an empty user password and printing and copying disallowed
+func encrypted(objects [][]byte, random *rand.Rand) []byte {
+	id := make([]byte, 16)
+	for i := range id {
+		id[i] = byte(random.IntN(256))
+	}
+	permissions := int32(-64)                                                             // Bits 3 to 6 clear: no printing, modifying, copying or annotating
+	ownerKey := md5Sum(append([]byte("owner"), securityPadding[:32-len("owner")]...))[:5] // From the padded owner password
+	owner := rc4Bytes(ownerKey, securityPadding)                                          // The empty user password pads to the padding itself
+	keyInput := append(append(append([]byte{}, securityPadding...), owner...), binary.LittleEndian.AppendUint32(nil, uint32(permissions))...)
+	key := md5Sum(append(keyInput, id...))[:5]
+	user := rc4Bytes(key, securityPadding)
+
+	for i, object := range objects {
+		start := bytes.Index(object, []byte(">>\nstream\n"))
+		if start < 0 {
+			continue
+		}
+		start += len(">>\nstream\n")
+		end := bytes.LastIndex(object, []byte("\nendstream"))
+		number := i + 1
+		objectKey := md5Sum(append(append([]byte{}, key...), byte(number), byte(number>>8), byte(number>>16), 0, 0))[:len(key)+5]
+		encryptedData := rc4Bytes(objectKey, object[start:end]) // RC4 keeps the length, so /Length stays right
+		objects[i] = append(append(append([]byte{}, object[:start]...), encryptedData...), object[end:]...)
+	}
+	objects = append(objects, fmt.Appendf(nil, "<< /Filter /Standard /V 1 /R 2 /O <%x> /U <%x> /P %d >>", owner, user, permissions))
+	return assemble(objects, fmt.Sprintf(" /Encrypt %d 0 R /ID [<%x> <%x>]", len(objects), id, id))
+}
+
+// Returns the MD5 digest of data as a slice
+func md5Sum(data []byte) []byte {
+	sum := md5.Sum(data)
+	return sum[:]
+}
+
+// Returns data encrypted (or decrypted) with RC4 under key
+func rc4Bytes(key, data []byte) []byte {
+	cipher, _ := rc4.NewCipher(key) // Only fails for keys outside 1 to 256 bytes
+	out := make([]byte, len(data))
+	cipher.XORKeyStream(out, data)
+	return out
+}
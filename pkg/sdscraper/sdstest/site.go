@@ -0,0 +1,230 @@
+// Package sdstest provides a hermetic stand-in for a vendor site, so a Scraper can be exercised without gojo.com
+// or Chrome: an httptest.Server with a listing page, valid PDFs, a duplicate, a corrupt download, a missing
+// document and one answering 503 until it is retried. NewSiteWith spreads the listing over pages and serves a
+// corpus as well, for end-to-end smoke tests. Corpus generates larger sets of synthetic SDS PDFs, valid,
+// encrypted, image-only and broken, for validating a deployment end to end.
+package sdstest
+
+import ( // Import required packages
+	"bytes"             // For building PDFs
+	"cmp"               // For defaults
+	"fmt"               // For PDF objects and listing rows
+	"net/http"          // For the fixture handlers
+	"net/http/httptest" // For the local server
+	"path/filepath"     // For state file paths
+	"regexp"            // For following the listing's pages
+	"strconv"           // For page numbers
+	"strings"           // For the listing page
+	"sync"              // For request counts
+	"time"              // For stable validators
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // The scraper the site feeds
+)
+
+// Paths of the fixture documents, relative to the server URL
+const (
+	GelPath       = "/docs/gel.pdf"       // Valid PDF
+	FoamPath      = "/docs/foam.pdf"      // Another valid PDF
+	GelCopyPath   = "/docs/gel-copy.pdf"  // Same bytes as the gel, recorded as an alias
+	BrokenPath    = "/docs/broken.pdf"    // An HTML error page served as a PDF, quarantined
+	MissingPath   = "/docs/missing.pdf"   // Answers 404
+	ThrottledPath = "/docs/throttled.pdf" // Answers 503 the first time, then a valid PDF
+	CorpusPrefix  = "/docs/corpus/"       // Corpus documents are served under this path by file name
+)
+
+var modified = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) // Fixed Last-Modified, so revalidation answers 304
+
+// SiteOptions shapes a fixture site beyond the fixed documents
+type SiteOptions struct {
+	PerPage int              // Listing rows per page, zero for a single page; later pages are linked with rel="next"
+	Corpus  []CorpusDocument // Served under CorpusPrefix and listed after the fixed documents
+}
+
+// Site is a running fixture site; Close it when done
+type Site struct {
+	*httptest.Server
+	mu        sync.Mutex
+	requests  map[string]int    // Requests per path
+	documents map[string][]byte // Served bodies per path
+	rows      []listingRow      // Listed documents, in order
+	perPage   int
+	corpus    []CorpusDocument
+}
+
+// One row of the listing table
+type listingRow struct{ path, product, sku string }
+
+// NewSite starts a fixture site on a loopback port
+func NewSite() *Site {
+	return NewSiteWith(SiteOptions{})
+}
+
+// NewSiteWith starts a fixture site with a paginated listing or a corpus on a loopback port
+func NewSiteWith(options SiteOptions) *Site {
+	gel := PDF("PURELL Advanced Hand Sanitizer Gel")
+	site := &Site{
+		requests: make(map[string]int),
+		documents: map[string][]byte{
+			GelPath:       gel,
+			FoamPath:      PDF("PROVON Foaming Handwash"),
+			GelCopyPath:   gel,
+			BrokenPath:    []byte("<html><body>Internal error</body></html>"),
+			ThrottledPath: PDF("GOJO Hand Medic"),
+		},
+		rows: []listingRow{
+			{GelPath, "PURELL Advanced Hand Sanitizer Gel", "9652-12"},
+			{FoamPath, "PROVON Foaming Handwash", "5385-02"},
+			{GelCopyPath, "PURELL Advanced Hand Sanitizer Gel (copy)", "9652-24"},
+			{BrokenPath, "Broken Download", "0000-01"},
+			{MissingPath, "Withdrawn Product", "0000-02"},
+			{ThrottledPath, "GOJO Hand Medic", "8145-06"},
+		},
+		perPage: options.PerPage,
+		corpus:  options.Corpus,
+	}
+	for _, document := range options.Corpus {
+		path := CorpusPrefix + document.Name
+		site.documents[path] = document.Data
+		site.rows = append(site.rows, listingRow{path, cmp.Or(document.Product, document.Name), cmp.Or(document.SKU, "0000-00")})
+	}
+	site.Server = httptest.NewServer(http.HandlerFunc(site.serve))
+	return site
+}
+
+// ListingURL returns the listing page template, with {locale} like the real site's
+func (s *Site) ListingURL() string {
+	return s.URL + "/{locale}/SDS"
+}
+
+// DocumentURL returns the absolute URL of a fixture path
+func (s *Site) DocumentURL(path string) string {
+	return s.URL + path
+}
+
+// Pages returns how many pages the listing spans
+func (s *Site) Pages() int {
+	if s.perPage <= 0 {
+		return 1
+	}
+	return max(1, (len(s.rows)+s.perPage-1)/s.perPage)
+}
+
+// PageLinks is a deep crawl allowlist matching the listing's later pages, so a Scraper follows its "next" links
+var PageLinks = regexp.MustCompile(`/SDS\?page=\d+$`)
+
+// Expectation is what a correct first run over the site should make of its documents, as absolute URLs
+type Expectation struct {
+	Downloaded []string // Written to disk
+	Aliased    []string // Recorded as duplicates of a downloaded document
+	Failed     []string // Missing, rejected or throttled
+	Retried    []string // Failed the first time, downloaded by the next run
+}
+
+// Expect returns the outcome of a first run, whose downloader does structural validation when structural is set
+func (s *Site) Expect(structural bool) Expectation {
+	expected := Expectation{
+		Downloaded: []string{s.DocumentURL(GelPath), s.DocumentURL(FoamPath)},
+		Aliased:    []string{s.DocumentURL(GelCopyPath)},
+		Failed:     []string{s.DocumentURL(BrokenPath), s.DocumentURL(MissingPath), s.DocumentURL(ThrottledPath)},
+		Retried:    []string{s.DocumentURL(ThrottledPath)},
+	}
+	for _, document := range s.corpus {
+		documentURL := s.DocumentURL(CorpusPrefix + document.Name)
+		if document.Valid && (document.Structural || !structural) {
+			expected.Downloaded = append(expected.Downloaded, documentURL)
+		} else {
+			expected.Failed = append(expected.Failed, documentURL)
+		}
+	}
+	return expected
+}
+
+// Requests returns how many requests a path received
+func (s *Site) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+// Scraper returns a scraper of the site keeping its documents, manifest and listing cache under dir, rendering over
+// plain HTTP; callers adjust fields before Run as with any Scraper
+func (s *Site) Scraper(dir string) *sdscraper.Scraper {
+	client := s.Client()
+	return &sdscraper.Scraper{
+		PageURL:      s.ListingURL(),
+		Locales:      []string{"en"},
+		CacheFile:    filepath.Join(dir, "listing-{locale}.html"),
+		ForceRefresh: true, // Every run sees the current fixture
+		ManifestPath: filepath.Join(dir, "manifest.json"),
+		Renderer:     &sdscraper.HTTPRenderer{Client: client},
+		Downloader: &sdscraper.Downloader{
+			Client:    client,
+			OutputDir: filepath.Join(dir, "PDFs") + string(filepath.Separator),
+			RejectDir: filepath.Join(dir, "rejects"),
+			SpoolDir:  filepath.Join(dir, "spool"),
+		},
+		Workers:   2,
+		IOWorkers: 1,
+	}
+}
+
+// Answers listing and document requests
+func (s *Site) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests[r.URL.Path]++
+	count := s.requests[r.URL.Path]
+	body, isDocument := s.documents[r.URL.Path]
+	s.mu.Unlock()
+
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/SDS"):
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		page, err := strconv.Atoi(cmp.Or(r.URL.Query().Get("page"), "1"))
+		if err != nil || page < 1 || page > s.Pages() {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, s.listing(r.URL.Path, page))
+	case r.URL.Path == ThrottledPath && count == 1:
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+	case isDocument:
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, len(body)))
+		http.ServeContent(w, r, r.URL.Path, modified, bytes.NewReader(body))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Returns one listing page: a table row per document with product, SKU and revision like the real one, and a
+// link to the next page when there is one
+func (s *Site) listing(path string, page int) string {
+	rows := s.rows
+	if s.perPage > 0 {
+		rows = rows[(page-1)*s.perPage : min(page*s.perPage, len(rows))]
+	}
+	var listing strings.Builder
+	listing.WriteString("<html><body><table>\n")
+	for _, row := range rows {
+		fmt.Fprintf(&listing, "<tr><td>%s</td><td>SKU: %s</td><td>Revision date: 2024-01-02</td><td><a href=\"%s\">Download</a></td></tr>\n",
+			row.product, row.sku, s.DocumentURL(row.path))
+	}
+	listing.WriteString("</table>\n")
+	if page < s.Pages() {
+		fmt.Fprintf(&listing, "<a rel=\"next\" href=\"%s?page=%d\">Next</a>\n", path, page+1)
+	}
+	listing.WriteString("</body></html>\n")
+	return listing.String()
+}
+
+// PDF returns a small valid one-page PDF showing text, with a correct cross-reference table
+func PDF(text string) []byte {
+	return assemble([][]byte{
+		[]byte("<< /Type /Catalog /Pages 2 0 R >>"),
+		[]byte("<< /Type /Pages /Kids [3 0 R] /Count 1 >>"),
+		[]byte("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>"),
+		stream("", []byte("BT /F1 18 Tf 72 720 Td ("+escapeText(text)+") Tj ET")),
+		[]byte("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>"),
+	}, "")
+}
@@ -0,0 +1,242 @@
+package sdscraper
+
+import ( // Import required packages
+	"bytes"        // For reading stored PDFs
+	"context"      // For cancelling indexing
+	"database/sql" // For the FTS5 index
+	"fmt"          // For error wrapping
+	"io"           // For reading documents
+	"log/slog"     // For structured logging
+	"strings"      // For building queries
+	"time"         // For index timestamps
+
+	"github.com/ledongthuc/pdf" // Pure-Go PDF text extraction
+)
+
+// Schema of the search index: one FTS5 row per PDF page, and the content hash each document was indexed at
+const searchSchemaSQL = `
+CREATE VIRTUAL TABLE IF NOT EXISTS pages USING fts5(url UNINDEXED, filename UNINDEXED, page UNINDEXED, text, tokenize = 'unicode61 remove_diacritics 2');
+CREATE TABLE IF NOT EXISTS indexed (
+	url        TEXT PRIMARY KEY,
+	sha256     TEXT NOT NULL,
+	indexed_at TEXT NOT NULL,
+	error      TEXT NOT NULL DEFAULT ''
+);
+`
+
+// SearchIndex is a full-text index of the downloaded PDFs, e.g. to find every SDS that mentions a CAS number
+type SearchIndex struct {
+	db *sql.DB
+}
+
+// SearchHit is one page matching a search
+type SearchHit struct {
+	URL      string `json:"url"`
+	Filename string `json:"filename"`
+	Page     int    `json:"page"`    // 1-based
+	Snippet  string `json:"snippet"` // Matching text with the terms in [brackets]
+}
+
+// OpenSearchIndex opens or creates the index at path; readOnly opens an existing one without taking write locks
+func OpenSearchIndex(path string, readOnly bool) (*SearchIndex, error) {
+	dsn := "file:" + path + "?_pragma=busy_timeout(5000)"
+	if readOnly {
+		dsn += "&mode=ro"
+	}
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	if !readOnly {
+		if _, err := db.Exec(searchSchemaSQL); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("create search schema: %w", err)
+		}
+	} else if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SearchIndex{db: db}, nil
+}
+
+// Close releases the database
+func (x *SearchIndex) Close() error {
+	return x.db.Close()
+}
+
+// Update indexes the live PDFs of the manifest whose content changed since they were last indexed and drops
+// documents that are gone, returning how many documents were (re)indexed
+func (x *SearchIndex) Update(ctx context.Context, m *Manifest, storage Storage) (int, error) {
+	known := make(map[string]string) // URL to indexed hash
+	rows, err := x.db.QueryContext(ctx, `SELECT url, sha256 FROM indexed`)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var documentURL, sum string
+		if err := rows.Scan(&documentURL, &sum); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		known[documentURL] = sum
+	}
+	rows.Close()
+
+	live := make(map[string]bool)
+	indexed := 0
+	for documentURL, entry := range m.Documents {
+		if !searchable(entry) {
+			continue
+		}
+		live[documentURL] = true
+		if known[documentURL] == entry.SHA256 {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return indexed, err
+		}
+		pages, extractErr := extractPDFText(ctx, storage, entry.Filename)
+		if extractErr != nil { // Recorded so the document is not retried until its content changes
+			slog.Warn("Extracting text failed", "filename", entry.Filename, "err", extractErr)
+		}
+		if err := x.replace(ctx, documentURL, entry, pages, extractErr); err != nil {
+			return indexed, err
+		}
+		indexed++
+	}
+	for documentURL := range known {
+		if !live[documentURL] {
+			if err := x.replace(ctx, documentURL, nil, nil, nil); err != nil {
+				return indexed, err
+			}
+		}
+	}
+	return indexed, nil
+}
+
+// Reports whether an entry is a stored PDF of its own
+func searchable(entry *ManifestEntry) bool {
+	return entry.SHA256 != "" && entry.AliasOf == "" && entry.DeletedAt.IsZero() && (entry.Type == "" || entry.Type == "pdf")
+}
+
+// Swaps a document's pages for new ones in one transaction; a nil entry removes the document
+func (x *SearchIndex) replace(ctx context.Context, documentURL string, entry *ManifestEntry, pages []string, extractErr error) error {
+	tx, err := x.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // No-op after Commit
+	if _, err := tx.ExecContext(ctx, `DELETE FROM pages WHERE url = ?`, documentURL); err != nil {
+		return err
+	}
+	if entry == nil {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM indexed WHERE url = ?`, documentURL); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+	for i, text := range pages {
+		if strings.TrimSpace(text) == "" { // Scanned pages without a text layer
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO pages (url, filename, page, text) VALUES (?, ?, ?, ?)`, documentURL, entry.Filename, i+1, text); err != nil {
+			return err
+		}
+	}
+	message := ""
+	if extractErr != nil {
+		message = extractErr.Error()
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO indexed (url, sha256, indexed_at, error) VALUES (?, ?, ?, ?)
+		ON CONFLICT (url) DO UPDATE SET sha256 = excluded.sha256, indexed_at = excluded.indexed_at, error = excluded.error`,
+		documentURL, entry.SHA256, formatTime(time.Now()), message); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Search returns up to limit pages matching query, best first; plain words must all appear, and with raw the query
+// is passed to FTS5 as written, e.g. `ethanol OR "64-17-5"`
+func (x *SearchIndex) Search(ctx context.Context, query string, limit int, raw bool) ([]SearchHit, error) {
+	if !raw {
+		query = quoteTerms(query)
+	}
+	rows, err := x.db.QueryContext(ctx, `SELECT url, filename, page, snippet(pages, 3, '[', ']', '…', 12)
+		FROM pages WHERE pages MATCH ? ORDER BY rank LIMIT ?`, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("search %q: %w", query, err)
+	}
+	defer rows.Close()
+	var hits []SearchHit
+	for rows.Next() {
+		var hit SearchHit
+		if err := rows.Scan(&hit.URL, &hit.Filename, &hit.Page, &hit.Snippet); err != nil {
+			return nil, err
+		}
+		hit.Snippet = strings.Join(strings.Fields(hit.Snippet), " ") // PDF text is full of line breaks
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
+
+// Quotes each word as an FTS5 phrase, so CAS numbers such as 64-17-5 match as written instead of failing as syntax
+func quoteTerms(query string) string {
+	var terms []string
+	for _, term := range strings.Fields(query) {
+		terms = append(terms, `"`+strings.ReplaceAll(term, `"`, `""`)+`"`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// Returns the text of each page of a stored PDF
+func extractPDFText(ctx context.Context, storage Storage, filename string) (pages []string, err error) {
+	content, err := storage.Open(ctx, filename)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(content)
+	content.Close()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { // The extractor panics on some malformed files
+		if recovered := recover(); recovered != nil {
+			pages, err = nil, fmt.Errorf("extract text: %v", recovered)
+		}
+	}()
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	for i := 1; i <= reader.NumPage(); i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			pages = append(pages, "")
+			continue
+		}
+		text, err := page.GetPlainText(nil)
+		if err != nil {
+			return pages, fmt.Errorf("page %d: %w", i, err)
+		}
+		pages = append(pages, text)
+	}
+	return pages, nil
+}
+
+// Brings the search index at path up to date with the manifest, opening and closing it around the update
+func updateSearchIndex(ctx context.Context, path string, m *Manifest, storage Storage) error {
+	index, err := OpenSearchIndex(path, false)
+	if err != nil {
+		return err
+	}
+	indexed, err := index.Update(ctx, m, storage)
+	if indexed > 0 {
+		slog.Info("Updated search index", "documents", indexed)
+	}
+	if err != nil {
+		index.Close()
+		return err
+	}
+	return index.Close()
+}
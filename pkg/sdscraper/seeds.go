@@ -0,0 +1,141 @@
+package sdscraper
+
+import ( // Import required packages
+	"bytes"         // For sniffing the format
+	"cmp"           // For column aliases
+	"context"       // For fetching remote lists
+	"encoding/csv"  // For CSV seed lists
+	"encoding/json" // For JSON seed lists
+	"fmt"           // For error messages
+	"io"            // For reading lists
+	"net/http"      // For remote lists
+	"os"            // For local lists
+	"path"          // For telling formats apart by extension
+	"strings"       // For header names
+)
+
+// Seed is one known document from a seed list, e.g. exported from a vendor portal, whether or not a listing links it
+type Seed struct {
+	URL      string `json:"url"`
+	Locale   string `json:"locale,omitempty"` // Tags the document like a listing of this locale would, empty for none
+	Product  string `json:"product,omitempty"`
+	SKU      string `json:"sku,omitempty"`
+	Language string `json:"language,omitempty"`
+	Lang     string `json:"lang,omitempty"` // Alias of Language
+	Revision string `json:"revision,omitempty"`
+}
+
+// Returns the seed's metadata in the form listings yield it
+func (s Seed) metadata() DocumentMetadata {
+	return DocumentMetadata{Product: s.Product, SKU: s.SKU, Language: cmp.Or(s.Language, s.Lang), Revision: s.Revision}
+}
+
+// LoadSeeds reads a seed list from a file or an http(s) URL: a JSON array of seeds or an object with a "documents"
+// array, or a CSV file whose header names the columns url, locale, product, sku, language (or lang) and revision
+func LoadSeeds(ctx context.Context, client *http.Client, location string) ([]Seed, error) {
+	data, err := readSeedList(ctx, client, location)
+	if err != nil {
+		return nil, fmt.Errorf("read seed list %s: %w", location, err)
+	}
+	var seeds []Seed
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case strings.EqualFold(path.Ext(strings.SplitN(location, "?", 2)[0]), ".csv"), len(trimmed) > 0 && trimmed[0] != '[' && trimmed[0] != '{':
+		seeds, err = parseCSVSeeds(data)
+	case len(trimmed) > 0 && trimmed[0] == '{':
+		var wrapped struct {
+			Documents []Seed `json:"documents"`
+		}
+		err = json.Unmarshal(data, &wrapped)
+		seeds = wrapped.Documents
+	default:
+		err = json.Unmarshal(data, &seeds)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse seed list %s: %w", location, err)
+	}
+	return seeds, nil
+}
+
+// Returns the bytes of a local or remote seed list
+func readSeedList(ctx context.Context, client *http.Client, location string) ([]byte, error) {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		return os.ReadFile(location)
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: response.StatusCode, Status: response.Status}
+	}
+	return io.ReadAll(io.LimitReader(response.Body, 64<<20)) // Even a large portal export is far smaller
+}
+
+// Parses a CSV seed list with a header row; unknown columns are ignored
+func parseCSVSeeds(data []byte) ([]Seed, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1 // Trailing empty columns are often dropped
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	columns := make(map[string]int)
+	for i, name := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i // Excel exports start with a BOM
+	}
+	if _, ok := columns["url"]; !ok {
+		return nil, fmt.Errorf("no url column in the header %q", strings.Join(rows[0], ","))
+	}
+	field := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+	var seeds []Seed
+	for _, row := range rows[1:] {
+		seeds = append(seeds, Seed{
+			URL: field(row, "url"), Locale: field(row, "locale"), Product: field(row, "product"), SKU: field(row, "sku"),
+			Language: cmp.Or(field(row, "language"), field(row, "lang")), Revision: field(row, "revision"),
+		})
+	}
+	return seeds, nil
+}
+
+// SeedList is a DiscoverySource reading one seed list with LoadSeeds on every run
+type SeedList struct {
+	Location string       // File path or http(s) URL
+	Client   *http.Client // Fetches remote lists, nil for http.DefaultClient
+}
+
+// Name implements DiscoverySource
+func (l *SeedList) Name() string {
+	return "seeds " + l.Location
+}
+
+// Discover implements DiscoverySource
+func (l *SeedList) Discover(ctx context.Context, found func(DiscoveredDocument) bool) error {
+	client := l.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	seeds, err := LoadSeeds(ctx, client, l.Location)
+	if err != nil {
+		return err
+	}
+	for _, seed := range seeds {
+		if !found(DiscoveredDocument{URL: seed.URL, Locale: seed.Locale, Metadata: seed.metadata()}) {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,496 @@
+package sdscraper
+
+import ( // Import required packages
+	"bytes"         // For covered documents
+	"cmp"           // For option defaults
+	"context"       // For download contexts
+	"errors"        // For matching sentinel errors
+	"fmt"           // For formatting ETags
+	"io"            // For the served content
+	"log/slog"      // For structured logging
+	"maps"          // For copying recorded digests
+	"net/http"      // For the HTTP server
+	"net/netip"     // For network allowlists
+	"os"            // For opening local documents
+	"path/filepath" // For OS-independent path operations
+	"sort"          // For stable catalog ordering
+	"strconv"       // For numeric query parameters
+	"strings"       // For the share link path
+	"sync"          // For guarding shared state
+	"time"          // For access timestamps
+)
+
+// Server serves a local mirror over HTTP, fetching catalogued documents on first request
+type Server struct {
+	downloader    *Downloader            // Fetches documents that are not yet local
+	manifestPath  string                 // Path of the manifest file
+	cacheMaxBytes int64                  // Disk budget for cached documents, zero for unlimited
+	adminToken    string                 // Bearer token for admin endpoints, empty to disable them
+	retention     time.Duration          // How long soft-deleted documents stay restorable
+	tags          TagFilter              // Documents served; others are as good as absent
+	shares        *shareStore            // Share links, nil when they are disabled
+	shareMaxTTL   time.Duration          // Longest lifetime of a new share link
+	snapshots     *SnapshotStore         // Catalog snapshots, nil when they are disabled
+	limits        PublicLimits           // Per-client quotas in front of every route
+	allow         []netip.Prefix         // Networks requests may come from, nil for anywhere
+	shareAnywhere bool                   // Share links work from outside allow
+	resolve       clientResolver         // Finds client addresses behind trusted proxies
+	pause         *PauseControl          // Holds the background sync, nil when there is none
+	runNow        RunTrigger             // Starts a background sync run, nil when there is none
+	coverPages    bool                   // Prepend a cover page to served PDFs
+	covers        coverCache             // Covered PDFs recently served
+	mu            sync.Mutex             // Guards catalog and fetchLocks
+	catalog       *Manifest              // Known documents, local or not
+	fetchLocks    map[string]*sync.Mutex // One lock per document being fetched
+	changedAt     time.Time              // When the catalog contents last changed
+	loadedModTime time.Time              // Manifest file time when last loaded or saved
+	failedModTime time.Time              // Manifest file time of the last rewrite that could not be loaded
+	accessed      map[string]int64       // Accesses since the last save by document, carried over reloads
+	flushTimer    *time.Timer            // Saves the accesses once they settle, nil when none are pending
+}
+
+// How long access times wait in memory before they are saved, so bursts of requests cost one manifest write
+const accessFlushDelay = 10 * time.Second
+
+// Describes one document in the catalog response
+type catalogItem struct {
+	*ManifestEntry      // Stored download state
+	Local          bool `json:"local"` // Whether the file is already on disk
+}
+
+// ServerOptions configures a Server
+type ServerOptions struct {
+	ManifestPath    string         // Path of the manifest file
+	CacheMaxBytes   int64          // Disk budget for cached documents, zero for unlimited
+	AdminToken      string         // Bearer token required by admin endpoints, empty to disable them
+	DeleteRetention time.Duration  // How long soft-deleted documents stay restorable, zero for DefaultDeleteRetention
+	Tags            TagFilter      // Only serve documents passing this filter, e.g. a public mirror of "public" ones
+	SharesPath      string         // File keeping share links and their signing secret, empty to disable share links
+	ShareSecret     []byte         // Signs share links, empty for a random secret kept in SharesPath
+	ShareMaxTTL     time.Duration  // Longest lifetime of a share link, zero for DefaultShareMaxTTL
+	Limits          PublicLimits   // Quotas for a mirror exposed beyond the LAN, zero for none
+	Allow           []netip.Prefix // Networks requests may come from, e.g. the plants' subnets; nil for anywhere
+	ShareAnywhere   bool           // Let share links through from outside Allow, e.g. for inspectors on mobile data
+	TrustedProxies  []netip.Prefix // Reverse proxies whose X-Forwarded-For is believed for Allow and Limits
+	Pause           *PauseControl  // The background sync's, for the pause endpoints; nil without a sync
+	RunNow          RunTrigger     // Starts a background sync run annotated with the operator's reason, false if the overlap policy drops it; nil without a sync
+	CoverPages      bool           // Serve PDFs behind a cover page stamping their source, hash and retrieval date
+	SnapshotDir     string         // Catalog snapshots served under /snapshots, which admins may add to; empty to disable them
+}
+
+// NewServer loads the manifest and share links and applies the cache budget
+func NewServer(downloader *Downloader, options ServerOptions) (*Server, error) {
+	if !directoryExists(downloader.OutputDir) { // Read-through fetches need somewhere to land
+		createDirectory(downloader.OutputDir, 0o755)
+	}
+
+	server := &Server{
+		downloader:    downloader,
+		manifestPath:  options.ManifestPath,
+		cacheMaxBytes: options.CacheMaxBytes,
+		adminToken:    options.AdminToken,
+		retention:     cmp.Or(options.DeleteRetention, DefaultDeleteRetention),
+		tags:          options.Tags,
+		shareMaxTTL:   cmp.Or(options.ShareMaxTTL, DefaultShareMaxTTL),
+		limits:        options.Limits,
+		allow:         options.Allow,
+		shareAnywhere: options.ShareAnywhere,
+		resolve:       clientResolver{trusted: options.TrustedProxies},
+		pause:         options.Pause,
+		runNow:        options.RunNow,
+		coverPages:    options.CoverPages,
+		fetchLocks:    make(map[string]*sync.Mutex),
+		accessed:      make(map[string]int64),
+	}
+	if options.SharesPath != "" {
+		shares, err := openShareStore(options.SharesPath, options.ShareSecret)
+		if err != nil {
+			return nil, fmt.Errorf("share links: %w", err)
+		}
+		server.shares = shares
+	}
+	if options.SnapshotDir != "" {
+		server.snapshots = &SnapshotStore{Dir: options.SnapshotDir}
+	}
+	if err := server.refresh(); err != nil { // Initial load
+		return nil, fmt.Errorf("load manifest: %w", err)
+	}
+	if purged := server.catalog.PurgeDeleted(context.Background(), downloader.storage(), server.retention); len(purged) > 0 {
+		server.save() // Retention expired while the server was down
+	}
+	if downloader.Trash != nil {
+		downloader.Trash.Empty()
+	}
+	if evictToBudget(server.catalog, downloader.OutputDir, options.CacheMaxBytes, "") > 0 { // Apply a lowered budget at startup
+		server.save()
+	}
+	return server, nil
+}
+
+// Handler returns the HTTP routes of the mirror
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux() // Request router
+	mux.HandleFunc("GET /version", handleVersion)
+	mux.HandleFunc("GET /catalog", s.handleCatalog)                                         // List every known document
+	mux.HandleFunc("GET /catalog/changes", s.handleChanges)                                 // Adds, updates and removals since a cursor
+	mux.Handle("PATCH /catalog", s.requireAdmin(s.handlePatchCatalog))                      // Correct extracted metadata in bulk
+	mux.HandleFunc("GET /documents/{name...}", s.handleDocument)                            // Serve one document, type subfolder included, or {name}/checksums
+	mux.HandleFunc("POST /downloads", s.handleBulkDownload)                                 // Zip of selected documents
+	mux.Handle("POST /uploads", s.requireAdmin(s.handleUpload))                             // Supplemental internal documents
+	mux.Handle("DELETE /documents/{name...}", s.requireAdmin(s.handleSoftDelete))           // Hide a document, restorable for a while
+	mux.Handle("POST /documents/{path...}", s.requireAdmin(s.handleRestore))                // Undo a soft delete via {name}/restore
+	mux.Handle("PUT /documents/{path...}", s.requireAdmin(s.handleSetTags))                 // Replace the tags via {name}/tags
+	mux.Handle("GET /prunes/pending", s.requireAdmin(s.handlePendingPrune))                 // A held prune as a diff to review
+	mux.Handle("POST /prunes/pending/{id}/{decision}", s.requireAdmin(s.handleDecidePrune)) // approve or reject it
+	mux.Handle("GET /sync/pause", s.requireAdmin(s.handlePauseStatus))                      // Whether the background sync is held
+	mux.Handle("POST /sync/{action}", s.requireAdmin(s.handlePause))                        // pause, resume or run it now
+	if s.shares != nil {
+		mux.Handle("POST /shares", s.requireAdmin(s.handleCreateShare))        // Time-limited link to one document
+		mux.Handle("GET /shares", s.requireAdmin(s.handleListShares))          // Links with their access logs
+		mux.Handle("DELETE /shares/{id}", s.requireAdmin(s.handleRevokeShare)) // Refuse a link from now on
+		mux.HandleFunc("GET /shared/{token}", s.handleShared)                  // Download through a link, no account needed
+	}
+	if s.snapshots != nil {
+		mux.HandleFunc("GET /snapshots", s.handleListSnapshots)                             // Named point-in-time catalogs
+		mux.Handle("POST /snapshots", s.requireAdmin(s.handleCreateSnapshot))               // Freeze the catalog as it is now
+		mux.HandleFunc("GET /snapshots/{id}", s.handleSnapshot)                             // One snapshot with its documents
+		mux.HandleFunc("GET /snapshots/{id}/documents/{name...}", s.handleSnapshotDocument) // A document as the snapshot holds it
+	}
+	var handler http.Handler = mux
+	if !s.limits.IsZero() {
+		handler = newQuotaHandler(s.limits, handler, s.isAdmin, s.resolve, s.downloader.Metrics)
+	}
+	if s.allow != nil { // Outermost, so refused networks cost nothing else
+		allow := &allowHandler{allow: s.allow, resolve: s.resolve, metrics: s.downloader.Metrics, next: handler}
+		if s.shareAnywhere {
+			allow.exempt = func(r *http.Request) bool { return strings.HasPrefix(r.URL.Path, "/shared/") }
+		}
+		handler = allow
+	}
+	return handler
+}
+
+// Persists the catalog; callers hold s.mu
+func (s *Server) save() {
+	if err := SaveManifest(s.manifestPath, s.catalog); err != nil {
+		slog.Error("Saving catalog failed", "path", s.manifestPath, "err", err)
+		return
+	}
+	if info, err := os.Stat(s.manifestPath); err == nil {
+		s.loadedModTime = info.ModTime() // Our own write is not an outside change
+	}
+	clear(s.accessed) // Saved along with everything else
+}
+
+// Saves the access times held in memory, after a reload so another process's writes are kept
+func (s *Server) flushAccesses() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushTimer = nil
+	if len(s.accessed) == 0 {
+		return
+	}
+	s.refresh()
+	s.save()
+}
+
+// Close saves the access times not saved yet; call it once the HTTP server has stopped
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.flushTimer != nil {
+		s.flushTimer.Stop()
+	}
+	s.mu.Unlock()
+	s.flushAccesses()
+	return nil
+}
+
+// Reloads the manifest if another process (such as a crawl) rewrote it, keeping the copy held when the new one
+// cannot be read; callers hold s.mu, and only the initial load heeds the error
+func (s *Server) refresh() error {
+	info, err := os.Stat(s.manifestPath)
+	if s.catalog != nil && (err != nil || info.ModTime().Equal(s.loadedModTime)) {
+		return nil // Unchanged, or missing while we still have a copy
+	}
+	catalog, loadErr := LoadManifest(s.manifestPath)
+	if loadErr != nil {
+		if err == nil && !info.ModTime().Equal(s.failedModTime) { // Once per rewrite, not per request
+			s.failedModTime = info.ModTime()
+			slog.Error("Reloading the manifest failed; serving the copy loaded before", "path", s.manifestPath, "err", loadErr)
+		}
+		return loadErr
+	}
+	for sourceURL, count := range s.accessed { // Accesses not saved yet survive the reload
+		if entry, ok := catalog.Documents[sourceURL]; ok {
+			if held, ok := s.catalog.Documents[sourceURL]; ok && held.LastAccessed.After(entry.LastAccessed) {
+				entry.LastAccessed = held.LastAccessed
+			}
+			entry.AccessCount += count
+		}
+	}
+	s.catalog = catalog
+	s.changedAt = time.Now()
+	if err == nil {
+		s.loadedModTime = info.ModTime()
+	}
+	return nil
+}
+
+// Writes one page of the catalog as JSON, filtered and sorted per the query string
+func (s *Server) handleCatalog(w http.ResponseWriter, r *http.Request) {
+	query, err := parseCatalogQuery(r.URL.Query()) // Validate before doing any work
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.refresh()                                               // Pick up crawls finished since the last request
+	items := make([]catalogItem, 0, len(s.catalog.Documents)) // Snapshot under lock
+	for _, entry := range s.catalog.Documents {
+		if !entry.DeletedAt.IsZero() || !s.tags.Match(entry.Tags) { // Hidden until restored, or out of scope
+			continue
+		}
+		copied := *entry                                         // Copy so encoding doesn't race with fetches
+		copied.LastAccessed, copied.AccessCount = time.Time{}, 0 // Access statistics would change the ETag on every read
+		items = append(items, catalogItem{ManifestEntry: &copied, Local: fileExists(filepath.Join(s.downloader.OutputDir, entry.Filename))})
+	}
+	changedAt := s.changedAt
+	s.mu.Unlock()
+
+	sort.Slice(items, func(i, j int) bool { return items[i].Filename < items[j].Filename }) // Deterministic base order for ties
+
+	writeJSON(w, r, query.apply(items), changedAt) // Conditional and compressed
+}
+
+// Writes the catalog changes after the since cursor or timestamp
+func (s *Server) handleChanges(w http.ResponseWriter, r *http.Request) {
+	limit := maxCatalogLimit // Page size, shared with the catalog listing
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxCatalogLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxCatalogLimit), http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	s.mu.Lock()
+	s.refresh()
+	page, err := changesSince(s.catalog, r.URL.Query().Get("since"), limit) // Copies out of the log
+	changedAt := s.changedAt
+	s.mu.Unlock()
+
+	switch {
+	case errors.Is(err, errCursorExpired):
+		http.Error(w, err.Error(), http.StatusGone) // Client must fall back to a full listing
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, r, page, changedAt)
+}
+
+// Serves a document from disk, downloading it first if it is catalogued but not yet local; {name}/checksums answers
+// with its hashes instead
+func (s *Server) handleDocument(w http.ResponseWriter, r *http.Request) {
+	if name, ok := strings.CutSuffix(r.PathValue("name"), "/checksums"); ok { // Document names end in an extension
+		s.serveChecksums(w, r, name)
+		return
+	}
+	s.serveDocument(w, r, r.PathValue("name"))
+}
+
+// Response of GET /documents/{name}/checksums
+type checksumsResponse struct {
+	Filename     string            `json:"filename"`
+	URL          string            `json:"url"`
+	Size         int64             `json:"size"`
+	Checksums    map[string]string `json:"checksums"` // Hex digests by algorithm, e.g. "sha256"
+	DownloadedAt time.Time         `json:"downloaded_at"`
+}
+
+// Answers with the stored size and hashes of a document, so holders of copies can verify them without downloading
+// the mirror's; documents never stored yet have none and are not fetched for this
+func (s *Server) serveChecksums(w http.ResponseWriter, r *http.Request, name string) {
+	s.mu.Lock()
+	s.refresh()
+	var response checksumsResponse
+	sourceURL, entry, ok := s.catalog.FindByFilename(name)
+	if ok && entry.DeletedAt.IsZero() && s.tags.Match(entry.Tags) && entry.SHA256 != "" {
+		checksums := maps.Clone(entry.Digests) // Every digest recorded, e.g. SHA-512 kept for compliance
+		if checksums == nil {
+			checksums = make(map[string]string, 1)
+		}
+		checksums[defaultHasher.Name()] = entry.SHA256
+		response = checksumsResponse{Filename: entry.Filename, URL: sourceURL, Size: entry.Size,
+			Checksums: checksums, DownloadedAt: entry.DownloadedAt}
+	}
+	changedAt := s.changedAt
+	s.mu.Unlock()
+	if response.Checksums == nil {
+		http.NotFound(w, r) // Unknown, hidden, or not stored yet
+		return
+	}
+	writeJSON(w, r, response, changedAt)
+}
+
+// Serves the catalogued document named name, for a direct request or a share link
+func (s *Server) serveDocument(w http.ResponseWriter, r *http.Request, name string) {
+	sourceURL, filePath, err := s.ensureLocal(name) // Read-through download when needed
+	switch {
+	case errors.Is(err, errNotCatalogued):
+		http.NotFound(w, r) // Unknown documents are never fetched
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	file, err := openStored(filePath) // Open local copy, decrypted when sealed at rest
+	if err != nil {
+		slog.Error("Opening document failed", "path", filePath, "err", err)
+		http.Error(w, "document unavailable", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	info, err := os.Stat(filePath) // Needed for modification time and size
+	if err != nil {
+		slog.Error("Reading document info failed", "path", filePath, "err", err)
+		http.Error(w, "document unavailable", http.StatusInternalServerError)
+		return
+	}
+
+	entry, touched := s.touch(sourceURL)                                   // Record the access for eviction ordering
+	etag := fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()) // Changes whenever the file is rewritten
+	var content io.ReadSeeker = file
+	if s.coverPages && touched && coverable(name) {
+		covered, err := s.covers.get(etag+name, func() ([]byte, error) {
+			return pdfStamp{documentURL: sourceURL, entry: &entry, cover: true}.apply(filePath)
+		})
+		if err == nil {
+			content, etag = bytes.NewReader(covered), strings.TrimSuffix(etag, `"`)+`-cover"`
+		} else {
+			slog.Warn("Adding the cover page failed; serving the original", "file", name, "err", err) // E.g. an encrypted PDF
+		}
+	}
+
+	w.Header().Set("Content-Type", contentTypeFor(entry.Type, name)) // What the document was recognised as
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, name, info.ModTime(), content) // Handles ranges, HEAD and conditional requests
+}
+
+var ( // Reasons a document cannot be served
+	errNotCatalogued = errors.New("document is not in the catalog")
+	errFetchFailed   = errors.New("document could not be fetched from the source")
+)
+
+// Resolves a catalogued file name to its source URL and local path, fetching it first if it isn't local yet
+func (s *Server) ensureLocal(name string) (string, string, error) {
+	sourceURL, ok := s.lookup(name) // Find it in the catalog
+	if !ok {
+		return "", "", errNotCatalogued
+	}
+
+	filePath := filepath.Join(s.downloader.OutputDir, name) // Where the document lives locally
+	if !fileExists(filePath) {
+		fetchedName, ok := s.fetch(sourceURL) // Read-through download
+		if !ok {
+			return sourceURL, "", errFetchFailed
+		}
+		filePath = filepath.Join(s.downloader.OutputDir, fetchedName) // The downloader may rename the file
+	}
+	return sourceURL, filePath, nil
+}
+
+// Finds the source URL of a catalogued document by file name
+func (s *Server) lookup(name string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh() // Documents added by a crawl become servable immediately
+	sourceURL, entry, ok := s.catalog.FindByFilename(name)
+	if !ok || !entry.DeletedAt.IsZero() || !s.tags.Match(entry.Tags) { // Soft-deleted documents are hidden until restored
+		return "", false
+	}
+	return sourceURL, true
+}
+
+// Looks up content hashes in the server's catalog under its lock
+type lockedIndex struct{ s *Server }
+
+// LookupHash implements HashIndex
+func (l lockedIndex) LookupHash(sha256, excludeURL string) (*ManifestEntry, bool) {
+	l.s.mu.Lock()
+	defer l.s.mu.Unlock()
+	entry, ok := l.s.catalog.LookupHash(sha256, excludeURL)
+	if !ok {
+		return nil, false
+	}
+	copied := *entry // Callers read it without the lock
+	return &copied, true
+}
+
+// ClaimFilename implements HashIndex
+func (l lockedIndex) ClaimFilename(name, documentURL string) string {
+	l.s.mu.Lock()
+	defer l.s.mu.Unlock()
+	return l.s.catalog.ClaimFilename(name, documentURL)
+}
+
+// Downloads a catalogued document, letting only one request fetch a given URL at a time
+func (s *Server) fetch(sourceURL string) (string, bool) {
+	s.mu.Lock()
+	lock, ok := s.fetchLocks[sourceURL] // Per-document lock
+	if !ok {
+		lock = &sync.Mutex{}
+		s.fetchLocks[sourceURL] = lock
+	}
+	entry := *s.catalog.EntryFor(sourceURL) // Work on a copy outside the catalog lock
+	s.mu.Unlock()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if fileExists(filepath.Join(s.downloader.OutputDir, entry.Filename)) { // Another request fetched it while we waited
+		return entry.Filename, true
+	}
+
+	slog.Info("Fetching on demand", "url", sourceURL)
+	if _, err := s.downloader.Download(WithPriority(context.Background()), sourceURL, &entry, lockedIndex{s}); err != nil { // Ahead of any background sync
+		return "", false
+	}
+
+	s.mu.Lock()
+	current, ok := s.catalog.Documents[sourceURL] // The manifest may have been reloaded meanwhile
+	if !ok {
+		current = &ManifestEntry{}
+		s.catalog.Documents[sourceURL] = current
+	}
+	*current = entry                                                             // Publish the new state
+	s.catalog.RecordChange(ChangeUpdated, current)                               // Replicas see on-demand fetches too
+	s.changedAt = time.Now()                                                     // Invalidate catalog validators
+	evictToBudget(s.catalog, s.downloader.OutputDir, s.cacheMaxBytes, sourceURL) // Make room, keeping the new document
+	s.save()                                                                     // Keep the cache state across restarts
+	s.mu.Unlock()
+	return entry.Filename, true
+}
+
+// Marks a document as just used, returning a copy of its entry; the access time is saved shortly after, so access
+// order survives restarts without a manifest write per request
+func (s *Server) touch(sourceURL string) (ManifestEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.catalog.Documents[sourceURL]
+	if !ok {
+		return ManifestEntry{}, false
+	}
+	entry.LastAccessed = time.Now().UTC()
+	entry.AccessCount++ // Popular documents are revalidated first with the popular-first feature
+	s.accessed[sourceURL]++
+	if s.flushTimer == nil {
+		s.flushTimer = time.AfterFunc(accessFlushDelay, s.flushAccesses)
+	}
+	return *entry, true // Callers read it without the lock
+}
@@ -0,0 +1,320 @@
+package sdscraper
+
+import ( // Import required packages
+	"crypto/hmac"     // For signing share tokens
+	"crypto/rand"     // For link IDs and the signing secret
+	"crypto/sha256"   // For the signature hash
+	"crypto/subtle"   // For constant-time signature comparison
+	"encoding/base64" // For URL-safe tokens
+	"encoding/json"   // For the shares file and API bodies
+	"errors"          // For sentinel errors
+	"fmt"             // For error messages
+	"log/slog"        // For structured logging
+	"net/http"        // For the share endpoints
+	"os"              // For the shares file
+	"slices"          // For listing links
+	"strconv"         // For the expiry in the signature
+	"strings"         // For splitting tokens
+	"sync"            // For guarding the links
+	"time"            // For expiry
+)
+
+const (
+	DefaultShareTTL    = 7 * 24 * time.Hour  // Lifetime of a share link created without a ttl
+	DefaultShareMaxTTL = 90 * 24 * time.Hour // Longest lifetime a share link may be given
+	maxShareAccesses   = 100                 // Most recent accesses kept per link; the count covers all of them
+	shareKeepExpired   = 30 * 24 * time.Hour // Expired and revoked links stay listed this long, for the access log
+)
+
+// ShareLink lets whoever holds its token download one document until it expires or is revoked, without an account
+type ShareLink struct {
+	ID          string        `json:"id"`
+	Filename    string        `json:"filename"`           // Document shared
+	Snapshot    string        `json:"snapshot,omitempty"` // ID of the snapshot it is served from, empty for the live archive
+	Note        string        `json:"note,omitempty"`     // Who it is for, e.g. "Fire inspector, 14 Oct"
+	CreatedAt   time.Time     `json:"created_at"`
+	ExpiresAt   time.Time     `json:"expires_at"`
+	RevokedAt   time.Time     `json:"revoked_at,omitzero"`
+	AccessCount int           `json:"access_count"`       // Every use, refused ones included
+	Accesses    []ShareAccess `json:"accesses,omitempty"` // The most recent uses, oldest first
+}
+
+// ShareAccess records one use of a share link
+type ShareAccess struct {
+	At         time.Time `json:"at"`
+	RemoteAddr string    `json:"remote_addr"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	Status     int       `json:"status"` // HTTP status answered, 410 once expired or revoked
+}
+
+// The shares file: the signing secret and every link
+type shareFile struct {
+	Secret string       `json:"secret"` // Base64, generated on first use unless one is configured
+	Links  []*ShareLink `json:"links"`
+}
+
+var ( // Reasons a share token is refused
+	errShareInvalid = errors.New("share link is not valid")
+	errShareExpired = errors.New("share link has expired or been revoked")
+)
+
+// Share links persisted in a file, which holds the signing secret and is therefore only readable by its owner
+type shareStore struct {
+	path   string
+	secret []byte
+	mu     sync.Mutex // Guards links and the file
+	links  map[string]*ShareLink
+}
+
+// Loads the shares file at path, creating it with a new secret unless secret is given
+func openShareStore(path string, secret []byte) (*shareStore, error) {
+	store := &shareStore{path: path, secret: secret, links: make(map[string]*ShareLink)}
+	var file shareFile
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if len(store.secret) == 0 {
+		if store.secret, err = base64.StdEncoding.DecodeString(file.Secret); err != nil || len(store.secret) < 32 {
+			store.secret = make([]byte, 32)
+			rand.Read(store.secret)
+		}
+	}
+	for _, link := range file.Links {
+		if time.Since(link.ExpiresAt) < shareKeepExpired && (link.RevokedAt.IsZero() || time.Since(link.RevokedAt) < shareKeepExpired) {
+			store.links[link.ID] = link
+		}
+	}
+	return store, store.save()
+}
+
+// Writes the links and secret; callers hold s.mu
+func (s *shareStore) save() error {
+	file := shareFile{Secret: base64.StdEncoding.EncodeToString(s.secret), Links: s.list()}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	temp := s.path + ".tmp"
+	if err := writeFile(temp, append(data, '\n'), 0o600); err != nil { // The secret mints links
+		return err
+	}
+	return renameFile(temp, s.path)
+}
+
+// Returns the links newest first; callers hold s.mu
+func (s *shareStore) list() []*ShareLink {
+	links := make([]*ShareLink, 0, len(s.links))
+	for _, link := range s.links {
+		links = append(links, link)
+	}
+	slices.SortFunc(links, func(a, b *ShareLink) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return links
+}
+
+// Signs a link's ID, document, expiry and snapshot, so a token cannot be altered to reach another document or live longer
+func (s *shareStore) sign(link *ShareLink) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(link.ID + "\n" + link.Filename + "\n" + strconv.FormatInt(link.ExpiresAt.Unix(), 10)))
+	if link.Snapshot != "" { // Links to the live archive keep the signatures they were issued with
+		mac.Write([]byte("\n" + link.Snapshot))
+	}
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Returns the token of a link, its ID and signature
+func (s *shareStore) token(link *ShareLink) string {
+	return link.ID + "." + s.sign(link)
+}
+
+// Creates a link to filename, in snapshot unless that is empty, living for ttl
+func (s *shareStore) create(filename, snapshot, note string, ttl time.Duration) (ShareLink, string, error) {
+	id := make([]byte, 12)
+	rand.Read(id)
+	now := time.Now().UTC()
+	link := &ShareLink{ID: base64.RawURLEncoding.EncodeToString(id), Filename: filename, Snapshot: snapshot, Note: note, CreatedAt: now, ExpiresAt: now.Add(ttl).Truncate(time.Second)}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.links[link.ID] = link
+	if err := s.save(); err != nil {
+		delete(s.links, link.ID)
+		return ShareLink{}, "", err
+	}
+	return *link, s.token(link), nil
+}
+
+// Revokes a link, reporting whether it existed and was still usable
+func (s *shareStore) revoke(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	link, ok := s.links[id]
+	if !ok || !link.RevokedAt.IsZero() {
+		return false, nil
+	}
+	link.RevokedAt = time.Now().UTC()
+	return true, s.save()
+}
+
+// Returns the link a token belongs to, errShareExpired when its time is up and errShareInvalid for forged or unknown
+// tokens
+func (s *shareStore) resolve(token string) (*ShareLink, error) {
+	id, signature, ok := strings.Cut(token, ".")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	link, known := s.links[id]
+	if !ok || !known || subtle.ConstantTimeCompare([]byte(signature), []byte(s.sign(link))) != 1 {
+		return nil, errShareInvalid
+	}
+	if !link.RevokedAt.IsZero() || time.Now().After(link.ExpiresAt) {
+		return link, errShareExpired
+	}
+	return link, nil
+}
+
+// Records a use of a link
+func (s *shareStore) record(link *ShareLink, r *http.Request, client string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	link.AccessCount++
+	link.Accesses = append(link.Accesses, ShareAccess{At: time.Now().UTC(), RemoteAddr: client, UserAgent: r.UserAgent(), Status: status})
+	if len(link.Accesses) > maxShareAccesses {
+		link.Accesses = slices.Delete(link.Accesses, 0, len(link.Accesses)-maxShareAccesses)
+	}
+	if err := s.save(); err != nil {
+		slog.Error("Saving share links failed", "path", s.path, "err", err)
+	}
+}
+
+// Body of POST /shares
+type shareRequest struct {
+	Filename string `json:"filename"`
+	Snapshot string `json:"snapshot,omitempty"` // Name or ID of the snapshot to share the document from, empty for the live archive
+	TTL      string `json:"ttl,omitempty"`      // Go duration, e.g. "72h"; empty for DefaultShareTTL
+	Note     string `json:"note,omitempty"`
+}
+
+// Response of POST /shares
+type shareResponse struct {
+	ShareLink
+	URL string `json:"url"` // Path of the link on this server, to be prefixed with its public address
+}
+
+// Creates a share link for a served document
+func (s *Server) handleCreateShare(w http.ResponseWriter, r *http.Request) {
+	var request shareRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&request); err != nil || request.Filename == "" {
+		http.Error(w, "body must be JSON like {\"filename\": \"sheet.pdf\", \"ttl\": \"72h\"}", http.StatusBadRequest)
+		return
+	}
+	ttl := DefaultShareTTL
+	if request.TTL != "" {
+		parsed, err := time.ParseDuration(request.TTL)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "ttl must be a positive duration such as 72h", http.StatusBadRequest)
+			return
+		}
+		ttl = parsed
+	}
+	if ttl > s.shareMaxTTL {
+		http.Error(w, fmt.Sprintf("ttl may be at most %s", s.shareMaxTTL), http.StatusBadRequest)
+		return
+	}
+	if request.Snapshot != "" {
+		if !s.inSnapshot(request.Snapshot, request.Filename) {
+			http.NotFound(w, r)
+			return
+		}
+		request.Snapshot = SnapshotID(request.Snapshot)
+	} else if _, ok := s.lookup(request.Filename); !ok { // Only documents the server would serve itself
+		http.NotFound(w, r)
+		return
+	}
+	link, token, err := s.shares.create(request.Filename, request.Snapshot, request.Note, ttl)
+	if err != nil {
+		slog.Error("Saving share links failed", "path", s.shares.path, "err", err)
+		http.Error(w, "share link could not be saved", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Created share link", "id", link.ID, "filename", link.Filename, "snapshot", link.Snapshot, "expires", link.ExpiresAt, "note", link.Note)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(shareResponse{ShareLink: link, URL: "/shared/" + token}); err != nil {
+		slog.Error("Writing share response failed", "err", err)
+	}
+}
+
+// Lists the share links with their access logs, newest first
+func (s *Server) handleListShares(w http.ResponseWriter, r *http.Request) {
+	s.shares.mu.Lock()
+	links := s.shares.list()
+	data, err := json.Marshal(links) // Encoded under the lock, as accesses append to the links
+	s.shares.mu.Unlock()
+	if err != nil {
+		slog.Error("Encoding share links failed", "err", err)
+		http.Error(w, "share links unavailable", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// Revokes a share link; later uses are refused and logged
+func (s *Server) handleRevokeShare(w http.ResponseWriter, r *http.Request) {
+	revoked, err := s.shares.revoke(r.PathValue("id"))
+	switch {
+	case err != nil:
+		slog.Error("Saving share links failed", "path", s.shares.path, "err", err)
+		http.Error(w, "share links could not be saved", http.StatusInternalServerError)
+		return
+	case !revoked:
+		http.NotFound(w, r) // Unknown or already revoked
+		return
+	}
+	slog.Info("Revoked share link", "id", r.PathValue("id"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Serves the document of a share link, logging the access
+func (s *Server) handleShared(w http.ResponseWriter, r *http.Request) {
+	link, err := s.shares.resolve(r.PathValue("token"))
+	client := r.RemoteAddr
+	if address := s.resolve.address(r); address.IsValid() { // The visitor rather than the proxy in front
+		client = address.String()
+	}
+	switch {
+	case errors.Is(err, errShareInvalid):
+		http.NotFound(w, r) // Says nothing about whether the link ever existed
+		return
+	case errors.Is(err, errShareExpired):
+		s.shares.record(link, r, client, http.StatusGone)
+		slog.Warn("Refused share link", "id", link.ID, "filename", link.Filename, "remote", client, "err", err)
+		http.Error(w, err.Error(), http.StatusGone)
+		return
+	}
+	recorder := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	if link.Snapshot != "" {
+		s.serveSnapshotDocument(recorder, r, link.Snapshot, link.Filename)
+	} else {
+		s.serveDocument(recorder, r, link.Filename)
+	}
+	s.shares.record(link, r, client, recorder.status)
+	slog.Info("Served share link", "id", link.ID, "filename", link.Filename, "remote", client, "status", recorder.status)
+}
+
+// Remembers the status a handler answered with
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,374 @@
+package sdscraper
+
+import ( // Import required packages
+	"bytes"         // For covered documents
+	"cmp"           // For ETag fallbacks
+	"encoding/json" // For the snapshot records and API bodies
+	"errors"        // For sentinel errors
+	"fmt"           // For error messages
+	"io"            // For serving documents
+	"io/fs"         // For missing snapshots
+	"log/slog"      // For structured logging
+	"net/http"      // For the snapshot endpoints
+	"os"            // For reading the snapshot directory
+	"path/filepath" // For OS-independent path operations
+	"slices"        // For sorting snapshots
+	"strings"       // For snapshot IDs
+	"time"          // For creation times
+)
+
+var ( // Snapshot errors
+	ErrSnapshotExists = errors.New("snapshot already exists") // Snapshots are never replaced; delete the old one first
+	ErrNoSnapshot     = errors.New("no such snapshot")
+)
+
+// Files inside a snapshot's directory
+const (
+	snapshotRecord    = "snapshot.json" // The Snapshot itself
+	snapshotManifest  = "manifest.json" // The catalog as it was
+	snapshotDocuments = "documents"     // The files as they were, under their catalog names
+)
+
+// Snapshot is a named, immutable copy of the catalog at one point in time, e.g. "2024-Q4 audit", that exports,
+// print indexes and share links can refer to while the live archive keeps changing. Its directory holds the catalog
+// as an ordinary manifest and the files below documents/, hard-linked to the archive's where the file system allows,
+// so an unchanged document costs no space and fsck, verify and catalog work on a snapshot as on a mirror
+type Snapshot struct {
+	Name      string    `json:"name"`
+	ID        string    `json:"id"` // Directory and URL name, from SnapshotID
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Documents int       `json:"documents"`         // Catalog entries frozen, aliases included
+	Files     int       `json:"files"`             // Files kept; aliases share their original's
+	Bytes     int64     `json:"bytes"`             // Size of the files kept
+	Missing   []string  `json:"missing,omitempty"` // Catalogued files absent from disk, e.g. evicted from a serve cache
+	dir       string    // Where it is kept
+}
+
+// ManifestPath returns the path of the snapshot's catalog
+func (s *Snapshot) ManifestPath() string {
+	return filepath.Join(s.dir, snapshotManifest)
+}
+
+// DocumentsDir returns the directory holding the snapshot's files, the counterpart of a mirror's output directory
+func (s *Snapshot) DocumentsDir() string {
+	return filepath.Join(s.dir, snapshotDocuments)
+}
+
+// Manifest reads the snapshot's catalog
+func (s *Snapshot) Manifest() (*Manifest, error) {
+	return ReadManifest(s.ManifestPath())
+}
+
+// SnapshotID turns a snapshot name into its directory and URL name, e.g. "2024-Q4 audit" into "2024-q4-audit"
+func SnapshotID(name string) string {
+	var id strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case 'a' <= r && r <= 'z' || '0' <= r && r <= '9':
+			if dash && id.Len() > 0 {
+				id.WriteByte('-')
+			}
+			id.WriteRune(r)
+			dash = false
+		default:
+			dash = true
+		}
+	}
+	return id.String()
+}
+
+// SnapshotStore keeps snapshots in Dir, one subdirectory per snapshot named by its ID
+type SnapshotStore struct {
+	Dir string
+}
+
+// Create freezes the catalog m, whose files are in outputDir, as snapshot name: live, stored documents are copied
+// into it, deleted and never-downloaded ones left out. The snapshot appears complete or not at all, and a name already
+// taken fails with ErrSnapshotExists
+func (st SnapshotStore) Create(m *Manifest, outputDir, name, note string) (*Snapshot, error) {
+	id := SnapshotID(name)
+	if id == "" {
+		return nil, fmt.Errorf("snapshot name %q has no letters or digits", name)
+	}
+	snapshot := &Snapshot{Name: strings.TrimSpace(name), ID: id, Note: note, CreatedAt: time.Now().UTC(), dir: filepath.Join(st.Dir, id)}
+	if _, err := os.Stat(snapshot.dir); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotExists, id)
+	}
+	part := filepath.Join(st.Dir, "."+id+".part") // Renamed into place once complete
+	if err := removeAll(part); err != nil {       // Left by a failed attempt
+		return nil, err
+	}
+	if err := makeDirAll(filepath.Join(part, snapshotDocuments), 0o755); err != nil {
+		return nil, err
+	}
+
+	frozen := NewManifest()
+	kept := make(map[string]bool) // Aliases share a file
+	for _, documentURL := range sortedKeys(m.Documents) {
+		entry := *m.Documents[documentURL]
+		if entry.Filename == "" || !entry.DeletedAt.IsZero() || (entry.DownloadedAt.IsZero() && entry.AliasOf == "") {
+			continue
+		}
+		entry.LastAccessed, entry.AccessCount, entry.Pinned = time.Time{}, 0, false // Serve-cache state, not content
+		frozen.Documents[documentURL] = &entry
+		if kept[entry.Filename] {
+			continue
+		}
+		kept[entry.Filename] = true
+		source := filepath.Join(outputDir, filepath.FromSlash(entry.Filename))
+		info, err := os.Stat(source)
+		if err != nil {
+			snapshot.Missing = append(snapshot.Missing, entry.Filename)
+			continue
+		}
+		target := filepath.Join(part, snapshotDocuments, filepath.FromSlash(entry.Filename))
+		if err := makeDirAll(filepath.Dir(target), 0o755); err != nil {
+			removeAll(part)
+			return nil, err
+		}
+		if err := linkFile(source, target); err != nil { // Syncs replace files rather than rewrite them, so the link keeps this revision
+			if err := copyFile(source, target); err != nil { // Probably another file system
+				removeAll(part)
+				return nil, fmt.Errorf("snapshot %s: %w", entry.Filename, err)
+			}
+		}
+		snapshot.Files++
+		snapshot.Bytes += info.Size()
+	}
+	snapshot.Documents = len(frozen.Documents)
+
+	err := SaveManifest(filepath.Join(part, snapshotManifest), frozen)
+	if err == nil {
+		err = snapshot.save(part)
+	}
+	if err == nil {
+		err = renameFile(part, snapshot.dir) // Fails on a directory created meanwhile
+	}
+	if err != nil {
+		removeAll(part)
+		if _, statErr := os.Stat(snapshot.dir); statErr == nil {
+			return nil, fmt.Errorf("%w: %s", ErrSnapshotExists, id)
+		}
+		return nil, err
+	}
+	slog.Info("Created snapshot", "name", snapshot.Name, "id", id, "documents", snapshot.Documents, "missing", len(snapshot.Missing))
+	return snapshot, nil
+}
+
+// Writes the snapshot's record into dir
+func (s *Snapshot) save(dir string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(dir, snapshotRecord), append(data, '\n'), 0o444)
+}
+
+// Open returns the snapshot with this name or ID, ErrNoSnapshot if there is none
+func (st SnapshotStore) Open(name string) (*Snapshot, error) {
+	id := SnapshotID(name)
+	if id == "" {
+		return nil, fmt.Errorf("%w: %q", ErrNoSnapshot, name)
+	}
+	dir := filepath.Join(st.Dir, id)
+	data, err := os.ReadFile(filepath.Join(dir, snapshotRecord))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNoSnapshot, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &Snapshot{dir: dir}
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", filepath.Join(dir, snapshotRecord), err)
+	}
+	return snapshot, nil
+}
+
+// List returns the snapshots, oldest first
+func (st SnapshotStore) List() ([]*Snapshot, error) {
+	entries, err := os.ReadDir(st.Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []*Snapshot
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") { // Unfinished ones
+			continue
+		}
+		snapshot, err := st.Open(entry.Name())
+		if err != nil {
+			slog.Warn("Skipping unreadable snapshot", "dir", entry.Name(), "err", err)
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	slices.SortFunc(snapshots, func(a, b *Snapshot) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return snapshots, nil
+}
+
+// Delete removes a snapshot and the files only it still held
+func (st SnapshotStore) Delete(name string) error {
+	snapshot, err := st.Open(name)
+	if err != nil {
+		return err
+	}
+	if err := removeAll(snapshot.dir); err != nil {
+		return err
+	}
+	slog.Info("Deleted snapshot", "name", snapshot.Name, "id", snapshot.ID)
+	return nil
+}
+
+// Body of POST /snapshots
+type snapshotRequest struct {
+	Name string `json:"name"`
+	Note string `json:"note,omitempty"`
+}
+
+// One snapshot with the documents it holds, as GET /snapshots/{id} returns it
+type snapshotResponse struct {
+	*Snapshot
+	Catalog []*ManifestEntry `json:"catalog"` // Sorted by file name
+}
+
+// Lists the snapshots, oldest first
+func (s *Server) handleListSnapshots(w http.ResponseWriter, r *http.Request) {
+	snapshots, err := s.snapshots.List()
+	if err != nil {
+		slog.Error("Listing snapshots failed", "dir", s.snapshots.Dir, "err", err)
+		http.Error(w, "snapshots unavailable", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, r, append([]*Snapshot{}, snapshots...), time.Time{}) // Empty, never null
+}
+
+// Freezes the catalog as it is now under the name in the body
+func (s *Server) handleCreateSnapshot(w http.ResponseWriter, r *http.Request) {
+	var request snapshotRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&request); err != nil || SnapshotID(request.Name) == "" {
+		http.Error(w, "body must be JSON like {\"name\": \"2024-Q4 audit\", \"note\": \"for the insurer\"}", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock() // Holds off evictions and edits until every file is linked
+	s.refresh()
+	snapshot, err := s.snapshots.Create(s.catalog, s.downloader.OutputDir, request.Name, request.Note)
+	s.mu.Unlock()
+	switch {
+	case errors.Is(err, ErrSnapshotExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		slog.Error("Creating snapshot failed", "name", request.Name, "err", err)
+		http.Error(w, "snapshot could not be created", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(snapshot); err != nil {
+		slog.Error("Writing snapshot response failed", "err", err)
+	}
+}
+
+// Describes one snapshot and the documents it holds that the server would serve
+func (s *Server) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	snapshot, manifest, ok := s.openSnapshot(w, r, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	response := snapshotResponse{Snapshot: snapshot, Catalog: []*ManifestEntry{}}
+	for _, documentURL := range sortedKeys(manifest.Documents) {
+		if entry := manifest.Documents[documentURL]; s.tags.Match(entry.Tags) {
+			entry.URL = documentURL
+			response.Catalog = append(response.Catalog, entry)
+		}
+	}
+	slices.SortStableFunc(response.Catalog, func(a, b *ManifestEntry) int { return strings.Compare(a.Filename, b.Filename) })
+	writeJSON(w, r, response, snapshot.CreatedAt)
+}
+
+// Serves a document as the snapshot holds it
+func (s *Server) handleSnapshotDocument(w http.ResponseWriter, r *http.Request) {
+	s.serveSnapshotDocument(w, r, r.PathValue("id"), r.PathValue("name"))
+}
+
+// Opens a snapshot and its catalog, answering 404 or 500 itself when that fails
+func (s *Server) openSnapshot(w http.ResponseWriter, r *http.Request, id string) (*Snapshot, *Manifest, bool) {
+	snapshot, err := s.snapshots.Open(id)
+	if err == nil {
+		var manifest *Manifest
+		if manifest, err = snapshot.Manifest(); err == nil {
+			return snapshot, manifest, true
+		}
+	}
+	if errors.Is(err, ErrNoSnapshot) {
+		http.NotFound(w, r)
+	} else {
+		slog.Error("Opening snapshot failed", "id", id, "err", err)
+		http.Error(w, "snapshot unavailable", http.StatusInternalServerError)
+	}
+	return nil, nil, false
+}
+
+// Reports whether the server has snapshot id and would serve file name from it
+func (s *Server) inSnapshot(id, name string) bool {
+	if s.snapshots == nil {
+		return false
+	}
+	snapshot, err := s.snapshots.Open(id)
+	if err != nil {
+		return false
+	}
+	manifest, err := snapshot.Manifest()
+	if err != nil {
+		return false
+	}
+	_, entry, ok := manifest.FindByFilename(name)
+	return ok && s.tags.Match(entry.Tags) && fileExists(filepath.Join(snapshot.DocumentsDir(), filepath.FromSlash(name)))
+}
+
+// Serves file name from snapshot id, which never changes, so the content hash is its ETag
+func (s *Server) serveSnapshotDocument(w http.ResponseWriter, r *http.Request, id, name string) {
+	snapshot, manifest, ok := s.openSnapshot(w, r, id)
+	if !ok {
+		return
+	}
+	sourceURL, entry, ok := manifest.FindByFilename(name)
+	if !ok || !s.tags.Match(entry.Tags) {
+		http.NotFound(w, r)
+		return
+	}
+	filePath := filepath.Join(snapshot.DocumentsDir(), filepath.FromSlash(name))
+	file, err := openStored(filePath) // Decrypted when sealed at rest
+	if errors.Is(err, fs.ErrNotExist) {
+		http.NotFound(w, r) // Missing when the snapshot was taken
+		return
+	}
+	if err != nil {
+		slog.Error("Opening snapshot document failed", "path", filePath, "err", err)
+		http.Error(w, "document unavailable", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	etag := `"` + cmp.Or(entry.SHA256, snapshot.ID+"-"+name) + `"`
+	var content io.ReadSeeker = file
+	if s.coverPages && coverable(name) {
+		covered, err := s.covers.get(snapshot.ID+"/"+etag+name, func() ([]byte, error) {
+			return pdfStamp{documentURL: sourceURL, entry: entry, cover: true}.apply(filePath)
+		})
+		if err == nil {
+			content, etag = bytes.NewReader(covered), strings.TrimSuffix(etag, `"`)+`-cover"`
+		} else {
+			slog.Warn("Adding the cover page failed; serving the original", "file", name, "snapshot", snapshot.ID, "err", err)
+		}
+	}
+	w.Header().Set("Content-Type", contentTypeFor(entry.Type, name)) // What the document was recognised as
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, name, entry.DownloadedAt, content) // Handles ranges, HEAD and conditional requests
+}
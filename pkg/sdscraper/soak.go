@@ -0,0 +1,66 @@
+package sdscraper
+
+import ( // Import required packages
+	"cmp"     // For defaults
+	"errors"  // For the leak sentinel
+	"fmt"     // For leak details
+	"runtime" // For goroutine counts and the heap
+	"strings" // For joining leak details
+)
+
+// ErrResourceLeak is returned by SoakMonitor.Sample when resource use keeps growing across sync cycles
+var ErrResourceLeak = errors.New("resource use keeps growing across sync cycles")
+
+// ResourceSample is the process's resource use after one sync cycle
+type ResourceSample struct {
+	Cycle      int    `json:"cycle"`
+	Goroutines int    `json:"goroutines"`
+	OpenFiles  int    `json:"open_files"` // -1 where the platform cannot count them
+	HeapBytes  uint64 `json:"heap_bytes"` // Live heap after a collection
+}
+
+// SoakMonitor checks that a long-running sync does not leak: after Warmup cycles have let caches and connection
+// pools fill, the resource use then becomes the baseline, and a later sample beyond the baseline plus the allowed slack
+// fails. A leak of any rate eventually crosses its slack, so a soak of enough cycles catches it
+type SoakMonitor struct {
+	Warmup         int     // Cycles before the baseline is taken, zero for 2
+	GoroutineSlack int     // Goroutines allowed above the baseline, zero for 20
+	FileSlack      int     // Open files allowed above the baseline, zero for 20
+	HeapGrowth     float64 // Live heap allowed as a multiple of the baseline, zero for 2; at least 32MiB above it
+	Samples        []ResourceSample
+	baseline       *ResourceSample
+}
+
+// Sample records the resource use after a cycle, returning an error wrapping ErrResourceLeak when it exceeds the
+// baseline plus the allowed slack
+func (m *SoakMonitor) Sample() (ResourceSample, error) {
+	runtime.GC() // Count what is live, not what awaits collection
+	var memory runtime.MemStats
+	runtime.ReadMemStats(&memory)
+	sample := ResourceSample{Cycle: len(m.Samples) + 1, Goroutines: runtime.NumGoroutine(), OpenFiles: openFiles(), HeapBytes: memory.HeapAlloc}
+	m.Samples = append(m.Samples, sample)
+	warmup := cmp.Or(m.Warmup, 2)
+	switch {
+	case sample.Cycle < warmup:
+		return sample, nil
+	case sample.Cycle == warmup:
+		m.baseline = &sample
+		return sample, nil
+	}
+
+	var leaks []string
+	base := m.baseline
+	if limit := base.Goroutines + cmp.Or(m.GoroutineSlack, 20); sample.Goroutines > limit {
+		leaks = append(leaks, fmt.Sprintf("%d goroutines, baseline %d", sample.Goroutines, base.Goroutines))
+	}
+	if limit := base.OpenFiles + cmp.Or(m.FileSlack, 20); base.OpenFiles >= 0 && sample.OpenFiles > limit {
+		leaks = append(leaks, fmt.Sprintf("%d open files, baseline %d", sample.OpenFiles, base.OpenFiles))
+	}
+	if limit := max(uint64(float64(base.HeapBytes)*cmp.Or(m.HeapGrowth, 2)), base.HeapBytes+32<<20); sample.HeapBytes > limit {
+		leaks = append(leaks, fmt.Sprintf("%d heap bytes, baseline %d", sample.HeapBytes, base.HeapBytes))
+	}
+	if len(leaks) > 0 {
+		return sample, fmt.Errorf("%w: cycle %d: %s", ErrResourceLeak, sample.Cycle, strings.Join(leaks, "; "))
+	}
+	return sample, nil
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package sdscraper
+
+// Reports that open files cannot be counted on this platform
+func openFiles() int {
+	return -1
+}
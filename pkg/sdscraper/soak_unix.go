@@ -0,0 +1,16 @@
+//go:build unix
+
+package sdscraper
+
+import ( // Import required packages
+	"os" // For listing descriptors
+)
+
+// Counts the process's open file descriptors, -1 when they cannot be listed
+func openFiles() int {
+	entries, err := os.ReadDir("/dev/fd")
+	if err != nil {
+		return -1
+	}
+	return len(entries) - 1 // Less the descriptor listing them
+}
@@ -0,0 +1,90 @@
+package sdscraper
+
+import ( // Import required packages
+	"context"  // For cancelling storage operations
+	"errors"   // For recognising locked files
+	"log/slog" // For structured logging
+	"time"     // For retention windows
+)
+
+// DefaultDeleteRetention is how long soft-deleted documents can be restored before they are purged
+const DefaultDeleteRetention = 30 * 24 * time.Hour
+
+// FindByFilename returns the catalog key and entry owning a file name, preferring originals over aliases
+func (m *Manifest) FindByFilename(name string) (string, *ManifestEntry, bool) {
+	aliasURL := "" // Used only when no document owns the file itself
+	for documentURL, entry := range m.Documents {
+		if entry.Filename != name {
+			continue
+		}
+		if entry.AliasOf == "" {
+			return documentURL, entry, true
+		}
+		aliasURL = documentURL
+	}
+	if aliasURL == "" {
+		return "", nil, false
+	}
+	return aliasURL, m.Documents[aliasURL], true
+}
+
+// SoftDelete hides a document from the catalog while keeping its file restorable for the retention window
+func (m *Manifest) SoftDelete(rawURL, reason string) bool {
+	entry, ok := m.Documents[rawURL]
+	if !ok || !entry.DeletedAt.IsZero() {
+		return false // Unknown or already deleted
+	}
+	entry.DeletedAt = time.Now().UTC()
+	entry.DeletedReason = reason
+	m.RecordChange(ChangeRemoved, entry)
+	return true
+}
+
+// Restore brings a soft-deleted document back into the catalog
+func (m *Manifest) Restore(rawURL string) bool {
+	entry, ok := m.Documents[rawURL]
+	if !ok || entry.DeletedAt.IsZero() {
+		return false // Unknown or not deleted
+	}
+	entry.DeletedAt = time.Time{}
+	entry.DeletedReason = ""
+	m.RecordChange(ChangeAdded, entry)
+	return true
+}
+
+// PurgeDeleted permanently removes documents soft-deleted longer than retention ago, returning their URLs
+func (m *Manifest) PurgeDeleted(ctx context.Context, storage Storage, retention time.Duration) []string {
+	cutoff := time.Now().Add(-retention)
+	var purged []string
+	for documentURL, entry := range m.Documents {
+		if entry.DeletedAt.IsZero() || entry.DeletedAt.After(cutoff) {
+			continue
+		}
+		delete(m.Documents, documentURL) // The removal was already recorded when it was soft-deleted
+		purged = append(purged, documentURL)
+		if m.fileInUse(entry.Filename) { // Aliases or originals still point at it
+			continue
+		}
+		if err := storage.Delete(ctx, entry.Filename); errors.Is(err, ErrObjectLocked) {
+			m.Documents[documentURL] = entry // Still deleted; purged once the retention ends
+			purged = purged[:len(purged)-1]
+			slog.Info("Soft-deleted document is under object lock; purging it once the retention ends", "filename", entry.Filename, "err", err)
+			continue
+		} else if err != nil {
+			slog.Error("Purging soft-deleted file failed", "filename", entry.Filename, "err", err)
+			continue
+		}
+		slog.Info("Purged soft-deleted document", "url", documentURL, "filename", entry.Filename, "deleted_at", entry.DeletedAt)
+	}
+	return purged
+}
+
+// Reports whether any remaining entry refers to the file
+func (m *Manifest) fileInUse(filename string) bool {
+	for _, entry := range m.Documents {
+		if entry.Filename == filename {
+			return true
+		}
+	}
+	return false
+}
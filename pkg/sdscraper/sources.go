@@ -0,0 +1,52 @@
+package sdscraper
+
+import ( // Import required packages
+	"context"  // For cancellation
+	"fmt"      // For error wrapping
+	"log/slog" // For structured logging
+	"net/url"  // For validating document URLs
+)
+
+// DiscoverySource yields documents to mirror besides those the listing pages link, e.g. a seed list or a
+// credentialed vendor portal; every source is consulted after the listings on each run
+type DiscoverySource interface {
+	Name() string                                                            // Identifies the source in logs and errors
+	Discover(ctx context.Context, found func(DiscoveredDocument) bool) error // Calls found per document until it returns false
+}
+
+// DiscoveredDocument is one document a DiscoverySource knows of
+type DiscoveredDocument struct {
+	URL      string           // Absolute http(s) URL the downloader fetches
+	Locale   string           // Tags the document like a listing of this locale would, empty for none
+	Metadata DocumentMetadata // Product, SKU, language and revision, where the source knows them; Sources may rate them
+}
+
+// SourceConfidence is the confidence of the metadata a DiscoverySource gives without attributing it itself
+const SourceConfidence = 0.9
+
+// Reports the sources' documents to found like listing links; a failing source fails the discovery, as an
+// unreachable listing does, so pruning never mistakes its documents for withdrawn ones
+func (s *Scraper) discoverSources(ctx context.Context, found func(documentURL, locale string, valid bool, meta DocumentMetadata) bool) error {
+	for _, source := range s.Sources {
+		count := 0
+		stopped := false
+		err := source.Discover(ctx, func(document DiscoveredDocument) bool {
+			parsed, err := url.Parse(document.URL)
+			valid := err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+			count++
+			if !found(document.URL, document.Locale, valid, document.Metadata.attributed(MethodSource, source.Name(), SourceConfidence)) {
+				stopped = true
+				return false
+			}
+			return true
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", source.Name(), err)
+		}
+		if stopped {
+			return ctx.Err()
+		}
+		slog.Info("Discovered documents from a source", "source", source.Name(), "documents", count)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package sdscraper
+
+import ( // Import required packages
+	"bytes"         // For stamping in memory
+	"compress/zlib" // For the logo's samples
+	"encoding/json" // For the templates file
+	"errors"        // For the encrypted-original error
+	"fmt"           // For error messages
+	"image"         // For decoding logos
+	"image/color"   // For the white background
+	"image/draw"    // For flattening transparency
+	_ "image/jpeg"  // Registers JPEG logos
+	_ "image/png"   // Registers PNG logos
+	"io"            // For the merge inputs
+	"os"            // For reading templates, logos and originals
+	"path/filepath" // For logos next to the templates file
+	"slices"        // For listing known facilities
+	"strings"       // For the footer text
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"          // For stamping and merging
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model" // For pdfcpu configuration
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types" // For stamp units
+)
+
+// StampTemplate brands the copies one facility exports and prints: its logo and name head cover pages and print
+// indexes, and every page of an exported PDF carries a footer with the facility and whom to contact. Only copies
+// leaving the archive are stamped; stored originals keep the bytes their SHA-256 was taken of, for verification
+type StampTemplate struct {
+	Facility string    `json:"facility"`          // Name on every stamp, e.g. "Hamilton plant"
+	Contact  string    `json:"contact,omitempty"` // Whom to ask about a sheet, e.g. "EHS office, ext. 4410"
+	Logo     string    `json:"logo,omitempty"`    // PNG or JPEG file, relative to the templates file
+	logo     *pdfImage // Logo, decoded
+}
+
+// Largest logo accepted, in pixels per side; a print logo needs far fewer
+const maxLogoPixels = 2000
+
+// LoadStampTemplate reads the template of facility from a JSON file of templates by facility key, such as
+// {"hamilton": {"facility": "Hamilton plant", "contact": "EHS office, ext. 4410", "logo": "logos/hamilton.png"}}
+func LoadStampTemplate(path, facility string) (*StampTemplate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var templates map[string]*StampTemplate
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return nil, fmt.Errorf("stamp templates %s: %w", path, err)
+	}
+	template := templates[facility]
+	if template == nil {
+		keys := make([]string, 0, len(templates))
+		for key := range templates {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		return nil, fmt.Errorf("stamp templates %s: no facility %q (have %s)", path, facility, strings.Join(keys, ", "))
+	}
+	if template.Facility == "" {
+		return nil, fmt.Errorf("stamp templates %s: facility %q needs a facility name", path, facility)
+	}
+	if template.Logo != "" {
+		logoPath := template.Logo
+		if !filepath.IsAbs(logoPath) {
+			logoPath = filepath.Join(filepath.Dir(path), logoPath)
+		}
+		if template.logo, err = readLogo(logoPath); err != nil {
+			return nil, fmt.Errorf("stamp templates %s: facility %q: %w", path, facility, err)
+		}
+	}
+	return template, nil
+}
+
+// Decodes a PNG or JPEG into an image for generated PDFs, transparency flattened onto white paper
+func readLogo(path string) (*pdfImage, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	decoded, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("logo %s: %w", path, err)
+	}
+	bounds := decoded.Bounds()
+	if bounds.Dx() > maxLogoPixels || bounds.Dy() > maxLogoPixels {
+		return nil, fmt.Errorf("logo %s: %dx%d pixels, want at most %d per side", path, bounds.Dx(), bounds.Dy(), maxLogoPixels)
+	}
+	flat := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), decoded, bounds.Min, draw.Over)
+
+	var samples bytes.Buffer
+	compressor := zlib.NewWriter(&samples)
+	for i := 0; i < len(flat.Pix); i += 4 {
+		compressor.Write(flat.Pix[i : i+3]) // RGB without alpha; writes to a buffer cannot fail
+	}
+	if err := compressor.Close(); err != nil {
+		return nil, err
+	}
+	return &pdfImage{width: bounds.Dx(), height: bounds.Dy(), data: samples.Bytes()}, nil
+}
+
+// Returns the size of the logo scaled to fit a width by height box, keeping its proportions
+func (t *StampTemplate) logoSize(width, height float64) (float64, float64) {
+	scale := min(width/float64(t.logo.width), height/float64(t.logo.height))
+	return float64(t.logo.width) * scale, float64(t.logo.height) * scale
+}
+
+// Returns the line identifying the facility, e.g. "Hamilton plant · EHS office, ext. 4410"
+func (t *StampTemplate) line() string {
+	if t.Contact == "" {
+		return t.Facility
+	}
+	return t.Facility + " · " + t.Contact
+}
+
+// Draws the facility's logo, if it has one, within 120 by 40 points with its top right corner at right, top
+func (t *StampTemplate) drawLogo(document *pdfBuilder, page *pdfPage, right, top float64) {
+	if t.logo == nil {
+		return
+	}
+	width, height := t.logoSize(120, 40)
+	figure := document.element(nil, "Figure")
+	figure.alt = t.Facility + " logo"
+	page.mark(figure, func() { page.image(t.logo, right-width, top-height, width, height) })
+}
+
+// What export adds to a stored PDF on its way into an archive or to a printer: a cover page, a facility's footer
+// on every page, or both; serve only ever adds the cover
+type pdfStamp struct {
+	documentURL string
+	entry       *ManifestEntry
+	cover       bool           // Prepend a cover page describing entry
+	template    *StampTemplate // Facility branding, nil for none
+}
+
+var errEncryptedStamp = errors.New("encrypted PDFs keep their protection and are not stamped")
+
+// Returns the stored PDF at path with the stamp applied, leaving the file itself untouched
+func (st pdfStamp) apply(path string) ([]byte, error) {
+	original, err := readStored(path)
+	if err != nil {
+		return nil, err
+	}
+	pdfcpuSetup.Do(func() { model.ConfigPath = "disable" }) // Keep pdfcpu from writing a config directory
+	if parsed, err := api.ReadContext(bytes.NewReader(original), model.NewDefaultConfiguration()); err == nil && parsed.Encrypt != nil {
+		return nil, errEncryptedStamp // Rewriting would drop the document's protection
+	}
+	if st.template != nil {
+		footer := st.template.line() + " · Uncontrolled copy when printed"
+		watermark, err := api.TextWatermark(footer, "fontname:Helvetica, points:7, position:bc, offset:0 10, scalefactor:1 abs, rotation:0, fillcolor:#404040", true, false, types.POINTS)
+		if err != nil {
+			return nil, fmt.Errorf("facility stamp: %w", err)
+		}
+		var stamped bytes.Buffer
+		if err := api.AddWatermarks(bytes.NewReader(original), &stamped, nil, watermark, model.NewDefaultConfiguration()); err != nil {
+			return nil, fmt.Errorf("facility stamp: %w", err)
+		}
+		original = stamped.Bytes()
+	}
+	if !st.cover {
+		return original, nil
+	}
+	var merged bytes.Buffer
+	inputs := []io.ReadSeeker{bytes.NewReader(coverPage(st.documentURL, st.entry, st.template)), bytes.NewReader(original)}
+	if err := api.MergeRaw(inputs, &merged, false, model.NewDefaultConfiguration()); err != nil {
+		return nil, fmt.Errorf("prepend cover page: %w", err)
+	}
+	return merged.Bytes(), nil
+}
@@ -0,0 +1,80 @@
+package sdscraper
+
+import ( // Import required packages
+	"context"       // For cancelling remote operations
+	"fmt"           // For error messages
+	"io"            // For streaming content
+	"os"            // For local files
+	"path/filepath" // For OS-independent path operations
+	"time"          // For modification times
+)
+
+// Storage holds downloaded documents by file name, on local disk or in a remote store
+type Storage interface {
+	Stat(ctx context.Context, name string) (StoredFile, error)     // Fails with an error matching fs.ErrNotExist when absent
+	Put(ctx context.Context, name string, content io.Reader) error // Creates or replaces atomically
+	Open(ctx context.Context, name string) (io.ReadCloser, error)  // Streams the content
+	Delete(ctx context.Context, name string) error                 // Succeeds when already absent
+}
+
+// VersionedStorage is a Storage keeping every write as a version of its own, such as a versioned S3 bucket
+type VersionedStorage interface {
+	Storage
+	PutVersion(ctx context.Context, name string, content io.Reader) (string, error) // Put, returning the version written
+}
+
+// StoredFile describes a document in a Storage
+type StoredFile struct {
+	Size        int64     // Bytes stored
+	ModTime     time.Time // When it was last written
+	Version     string    // Version of a VersionedStorage, empty elsewhere
+	RetainUntil time.Time // Object lock retention of the version, zero for none
+	LegalHold   bool      // The version is under a legal hold too
+}
+
+// LocalStorage keeps documents as files in Dir
+type LocalStorage struct {
+	Dir   string // Directory holding the documents
+	Trash *Trash // Receives deleted and replaced documents, nil to discard them
+}
+
+// Stat implements Storage
+func (l LocalStorage) Stat(_ context.Context, name string) (StoredFile, error) {
+	info, err := os.Stat(filepath.Join(l.Dir, name))
+	if err != nil {
+		return StoredFile{}, err
+	}
+	return StoredFile{Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// Put implements Storage, writing a .part file that is renamed into place once complete
+func (l LocalStorage) Put(_ context.Context, name string, content io.Reader) error {
+	path := filepath.Join(l.Dir, name)
+	if err := makeDirAll(filepath.Dir(path), 0o755); err != nil { // Names may carry a subfolder
+		return err
+	}
+	if l.Trash != nil { // The previous version stays recoverable
+		if err := l.Trash.Keep(path, name); err != nil {
+			return fmt.Errorf("keep replaced %s: %w", name, err)
+		}
+	}
+	return writePartThenRename(path, content)
+}
+
+// Open implements Storage
+func (l LocalStorage) Open(_ context.Context, name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(l.Dir, name))
+}
+
+// Delete implements Storage
+func (l LocalStorage) Delete(_ context.Context, name string) error {
+	return trashOrRemove(l.Trash, filepath.Join(l.Dir, name), name)
+}
+
+// Stores content under name, returning the version written when storage keeps versions
+func putVersion(ctx context.Context, storage Storage, name string, content io.Reader) (string, error) {
+	if versioned, ok := storage.(VersionedStorage); ok {
+		return versioned.PutVersion(ctx, name, content)
+	}
+	return "", storage.Put(ctx, name, content)
+}
@@ -0,0 +1,167 @@
+package sdscraper
+
+import ( // Import required packages
+	"fmt"     // For error messages
+	"regexp"  // For rule patterns and valid tags
+	"slices"  // For tag sets
+	"strings" // For parsing rules and filters
+)
+
+var validTag = regexp.MustCompile(`^[a-z0-9][a-z0-9._:/-]*$`) // Lower case, so filters never depend on spelling
+
+// NormalizeTag returns tag trimmed and lower-cased, or an error when it holds characters tags may not use
+func NormalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if !validTag.MatchString(tag) {
+		return "", fmt.Errorf("tag %q must start with a letter or digit and hold only letters, digits and . _ : / -", tag)
+	}
+	return tag, nil
+}
+
+// HasTag reports whether the entry carries tag
+func (e *ManifestEntry) HasTag(tag string) bool {
+	return slices.Contains(e.Tags, tag)
+}
+
+// AddTags adds tags the entry does not carry yet, keeping Tags sorted, and reports whether any was new
+func (e *ManifestEntry) AddTags(tags ...string) bool {
+	added := false
+	for _, tag := range tags {
+		if !e.HasTag(tag) {
+			e.Tags = append(e.Tags, tag)
+			added = true
+		}
+	}
+	slices.Sort(e.Tags)
+	return added
+}
+
+// RemoveTags drops tags from the entry and reports whether it carried any of them
+func (e *ManifestEntry) RemoveTags(tags ...string) bool {
+	before := len(e.Tags)
+	e.Tags = slices.DeleteFunc(e.Tags, func(tag string) bool { return slices.Contains(tags, tag) })
+	if len(e.Tags) == 0 {
+		e.Tags = nil // Drops the field from the manifest
+	}
+	return len(e.Tags) != before
+}
+
+// Entry fields a TagRule can match
+var tagRuleFields = map[string]func(*ManifestEntry) []string{
+	"url":      func(e *ManifestEntry) []string { return []string{e.URL} },
+	"filename": func(e *ManifestEntry) []string { return []string{e.Filename} },
+	"product":  func(e *ManifestEntry) []string { return []string{e.Product} },
+	"sku":      func(e *ManifestEntry) []string { return []string{e.SKU} },
+	"brand":    func(e *ManifestEntry) []string { return []string{e.Brand} },
+	"language": func(e *ManifestEntry) []string { return []string{e.Language} },
+	"type":     func(e *ManifestEntry) []string { return []string{e.Type} },
+	"locale":   func(e *ManifestEntry) []string { return e.Locales },
+}
+
+// TagRule tags every entry whose Field matches Pattern, e.g. "hazardous=product:(?i)sanitizer"
+type TagRule struct {
+	Tag     string         // Tag added to matching entries
+	Field   string         // url, filename, product, sku, brand, language, type or locale
+	Pattern *regexp.Regexp // Matched against the field, anywhere in it unless anchored
+}
+
+// ParseTagRule reads a rule written as tag=field:pattern
+func ParseTagRule(rule string) (TagRule, error) {
+	tag, match, ok := strings.Cut(rule, "=")
+	field, pattern, hasField := strings.Cut(match, ":")
+	if !ok || !hasField {
+		return TagRule{}, fmt.Errorf("tag rule %q: want tag=field:pattern", rule)
+	}
+	tag, err := NormalizeTag(tag)
+	if err != nil {
+		return TagRule{}, fmt.Errorf("tag rule %q: %w", rule, err)
+	}
+	field = strings.ToLower(strings.TrimSpace(field))
+	if tagRuleFields[field] == nil {
+		return TagRule{}, fmt.Errorf("tag rule %q: unknown field %q (want url, filename, product, sku, brand, language, type or locale)", rule, field)
+	}
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return TagRule{}, fmt.Errorf("tag rule %q: %w", rule, err)
+	}
+	return TagRule{Tag: tag, Field: field, Pattern: compiled}, nil
+}
+
+// String returns the rule in the form ParseTagRule reads
+func (r TagRule) String() string {
+	return r.Tag + "=" + r.Field + ":" + r.Pattern.String()
+}
+
+// Reports whether the rule applies to an entry
+func (r TagRule) matches(entry *ManifestEntry) bool {
+	return slices.ContainsFunc(tagRuleFields[r.Field](entry), func(value string) bool { return value != "" && r.Pattern.MatchString(value) })
+}
+
+// ApplyTagRules adds the tags of every rule matching the entry; tags are never removed, so ones set by hand or through
+// the API stay put, and reports whether any was new
+func ApplyTagRules(entry *ManifestEntry, rules []TagRule) bool {
+	added := false
+	for _, rule := range rules {
+		if rule.matches(entry) && entry.AddTags(rule.Tag) {
+			added = true
+		}
+	}
+	return added
+}
+
+// TagFilter selects entries by tag: an entry matches when it carries every Require tag and none of the Exclude ones.
+// The zero filter matches everything.
+type TagFilter struct {
+	Require []string
+	Exclude []string
+}
+
+// ParseTagFilter reads a comma-separated filter such as "sds,en-ca,-internal", where a leading - excludes a tag
+func ParseTagFilter(filter string) (TagFilter, error) {
+	var parsed TagFilter
+	for _, item := range strings.Split(filter, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		excluded, negated := strings.CutPrefix(strings.TrimSpace(item), "-")
+		tag, err := NormalizeTag(excluded)
+		if err != nil {
+			return TagFilter{}, err
+		}
+		if negated {
+			parsed.Exclude = append(parsed.Exclude, tag)
+		} else {
+			parsed.Require = append(parsed.Require, tag)
+		}
+	}
+	return parsed, nil
+}
+
+// IsZero reports whether the filter matches everything
+func (f TagFilter) IsZero() bool {
+	return len(f.Require) == 0 && len(f.Exclude) == 0
+}
+
+// Match reports whether a set of tags passes the filter
+func (f TagFilter) Match(tags []string) bool {
+	for _, tag := range f.Require {
+		if !slices.Contains(tags, tag) {
+			return false
+		}
+	}
+	for _, tag := range f.Exclude {
+		if slices.Contains(tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the filter in the form ParseTagFilter reads
+func (f TagFilter) String() string {
+	items := slices.Clone(f.Require)
+	for _, tag := range f.Exclude {
+		items = append(items, "-"+tag)
+	}
+	return strings.Join(items, ",")
+}
@@ -0,0 +1,84 @@
+package sdscraper
+
+import ( // Import required packages
+	"fmt"      // For error messages
+	"net/http" // For the transport wrappers
+	"net/url"  // For proxy URLs
+)
+
+// PoliteTransport rate-limits requests and, when Robots is set, refuses URLs robots.txt disallows
+type PoliteTransport struct {
+	Base    http.RoundTripper // Underlying transport, nil for http.DefaultTransport
+	Limiter *RateLimiter      // Shared bucket, nil for no limit
+	Robots  *RobotsPolicy     // robots.txt rules, nil to ignore them
+}
+
+// RoundTrip waits for a token, checks robots.txt and forwards the request
+func (t *PoliteTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if t.Robots != nil {
+		allowed, err := t.Robots.Allowed(request.Context(), request.URL, t.base(), t.Limiter)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return nil, fmt.Errorf("%w: %s", ErrRobotsDisallowed, request.URL)
+		}
+	}
+	if err := t.Limiter.Wait(request.Context()); err != nil {
+		return nil, err
+	}
+	return t.base().RoundTrip(request)
+}
+
+// Returns the configured base transport or the default one
+func (t *PoliteTransport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// HeaderTransport sets the User-Agent and extra headers on every request it forwards
+type HeaderTransport struct {
+	Base      http.RoundTripper // Underlying transport, nil for http.DefaultTransport
+	UserAgent string            // Replaces Go's default User-Agent when set
+	Headers   http.Header       // Added to each request unless it sets them itself
+}
+
+// RoundTrip applies the headers to a copy of the request and forwards it
+func (t *HeaderTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	request = request.Clone(request.Context()) // RoundTrippers must not modify the caller's request
+	for name, values := range t.Headers {
+		if request.Header.Get(name) == "" {
+			request.Header[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+	if t.UserAgent != "" && request.Header.Get("User-Agent") == "" {
+		request.Header.Set("User-Agent", t.UserAgent)
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(request)
+}
+
+// NewProxyTransport returns a copy of http.DefaultTransport using proxyURL (http, https or socks5),
+// or the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables when proxyURL is empty
+func NewProxyTransport(proxyURL string) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxyURL == "" {
+		return transport, nil // DefaultTransport already reads the environment
+	}
+	parsed, err := url.Parse(proxyURL)
+	if err != nil || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q", proxyURL)
+	}
+	switch parsed.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("proxy %q: scheme must be http, https or socks5", proxyURL)
+	}
+	transport.Proxy = http.ProxyURL(parsed)
+	return transport, nil
+}
@@ -0,0 +1,130 @@
+package sdscraper
+
+import ( // Import required packages
+	"cmp"           // For the grace default
+	"errors"        // For matching missing files
+	"io/fs"         // For walking directories
+	"log/slog"      // For structured logging
+	"os"            // For moving files
+	"path/filepath" // For OS-independent path operations
+	"strconv"       // For numbering name collisions
+	"strings"       // For splitting names
+	"time"          // For dated folders and the grace period
+)
+
+// DefaultTrashGrace is how long trashed files are kept when Trash.Grace is zero
+const DefaultTrashGrace = 7 * 24 * time.Hour
+
+const trashDateFormat = "2006-01-02" // One trash folder per day files were removed
+
+// Trash receives the files destructive operations would otherwise delete or overwrite, in one folder per day,
+// and deletes a day's folder for good once Grace has passed
+type Trash struct {
+	Dir   string        // Directory holding the dated folders
+	Grace time.Duration // How long trashed files stay recoverable, zero for DefaultTrashGrace
+}
+
+// Move puts the file or directory at path into today's trash folder as name, e.g. "docx/sheet.docx";
+// a path that does not exist is not an error
+func (t *Trash) Move(path, name string) error {
+	if _, err := os.Lstat(path); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	target, err := t.target(name)
+	if err != nil {
+		return err
+	}
+	if err := renameFile(path, target); err == nil {
+		slog.Info("Moved to trash", "path", path, "trash", target)
+		return nil
+	}
+	if err := copyTree(path, target); err != nil { // Probably another file system
+		removeAll(target)
+		return err
+	}
+	slog.Info("Moved to trash", "path", path, "trash", target)
+	return removeAll(path)
+}
+
+// Keep puts a copy of the file at path into today's trash folder as name before the caller replaces it
+func (t *Trash) Keep(path, name string) error {
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	target, err := t.target(name)
+	if err != nil {
+		return err
+	}
+	if err := linkFile(path, target); err != nil { // A hard link costs no space until the original is replaced
+		if err := copyFile(path, target); err != nil {
+			return err
+		}
+	}
+	slog.Debug("Kept replaced file in trash", "path", path, "trash", target)
+	return nil
+}
+
+// Empty deletes the dated folders older than the grace period, returning the folders removed
+func (t *Trash) Empty() []string {
+	folders, err := os.ReadDir(t.Dir)
+	if err != nil {
+		return nil // Nothing trashed yet
+	}
+	cutoff := time.Now().Add(-cmp.Or(t.Grace, DefaultTrashGrace))
+	var emptied []string
+	for _, folder := range folders {
+		day, err := time.ParseInLocation(trashDateFormat, folder.Name(), time.Local)
+		if err != nil || !folder.IsDir() || !day.AddDate(0, 0, 1).Before(cutoff) { // Whole day past the grace period
+			continue
+		}
+		if err := removeAll(filepath.Join(t.Dir, folder.Name())); err != nil {
+			slog.Error("Emptying trash failed", "folder", folder.Name(), "err", err)
+			continue
+		}
+		emptied = append(emptied, folder.Name())
+		slog.Info("Emptied trash folder", "folder", folder.Name())
+	}
+	return emptied
+}
+
+// Returns a free path for name inside today's folder, numbering it if an earlier removal took the name
+func (t *Trash) target(name string) (string, error) {
+	target := filepath.Join(t.Dir, time.Now().Format(trashDateFormat), filepath.FromSlash(name))
+	if err := makeDirAll(filepath.Dir(target), 0o755); err != nil {
+		return "", err
+	}
+	extension := filepath.Ext(target)
+	stem := strings.TrimSuffix(target, extension)
+	for n := 2; ; n++ {
+		if _, err := os.Lstat(target); errors.Is(err, fs.ErrNotExist) {
+			return target, nil
+		}
+		target = stem + "-" + strconv.Itoa(n) + extension
+	}
+}
+
+// Copies a file, or a directory with everything below it, to target
+func copyTree(source, target string) error {
+	return filepath.WalkDir(source, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, _ := filepath.Rel(source, path)
+		destination := filepath.Join(target, relative)
+		if entry.IsDir() {
+			return makeDirAll(destination, 0o755)
+		}
+		return copyFile(path, destination)
+	})
+}
+
+// Moves path into trash as name when trash is set, deleting it otherwise
+func trashOrRemove(trash *Trash, path, name string) error {
+	if trash != nil {
+		return trash.Move(path, name)
+	}
+	if err := removeAll(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package sdscraper
+
+import ( // Import required packages
+	"bytes"         // For storing the upload
+	"encoding/json" // For the response body
+	"fmt"           // For error messages
+	"io"            // For reading the upload
+	"log/slog"      // For structured logging
+	"net/http"      // For the HTTP server
+	"path/filepath" // For OS-independent path operations
+	"strings"       // For normalising client paths
+	"time"          // For timestamps
+)
+
+const maxUploadBytes = 50 << 20 // Largest supplemental document accepted
+
+// Stores an uploaded supplemental PDF next to the fetched documents and catalogs it with its source labelled
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes+1<<20) // Room for the multipart framing
+	if err := r.ParseMultipartForm(8 << 20); err != nil {
+		http.Error(w, "expected a multipart form with a \"file\" field", http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "missing \"file\" field", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxUploadBytes+1))
+	if err != nil || len(data) > maxUploadBytes {
+		http.Error(w, fmt.Sprintf("upload must be at most %d bytes", maxUploadBytes), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := validatePDF(data, s.downloader.StructuralCheck); err != nil { // Same bar as fetched documents
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	tags, err := ParseTagFilter(r.FormValue("tags")) // Comma-separated, read like a filter's required tags
+	if err != nil || len(tags.Exclude) > 0 {
+		http.Error(w, "tags must be comma-separated tag names", http.StatusBadRequest)
+		return
+	}
+
+	attachedTo := r.FormValue("attached_to") // Optional link to a fetched SDS
+	if attachedTo != "" {
+		if _, ok := s.lookup(attachedTo); !ok {
+			http.Error(w, fmt.Sprintf("attached_to %q is not in the catalog", attachedTo), http.StatusBadRequest)
+			return
+		}
+	}
+
+	filename := "supplemental_" + sanitizeFilename(strings.ReplaceAll(filepath.Base(strings.ReplaceAll(header.Filename, `\`, "/")), " ", "_")) // Prefix keeps it apart from fetched names
+	filePath := filepath.Join(s.downloader.OutputDir, filename)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh()
+
+	key := uploadScheme + filename // Manifest key standing in for a source URL
+	if existing, ok := s.catalog.Documents[key]; ok && existing.Source != SourceUpload {
+		http.Error(w, "name collides with a fetched document", http.StatusConflict)
+		return
+	}
+	if err := s.downloader.storage().Put(r.Context(), filename, bytes.NewReader(data)); err != nil { // A replaced upload goes to the trash
+		slog.Error("Storing upload failed", "path", filePath, "err", err)
+		http.Error(w, "could not store upload", http.StatusInternalServerError)
+		return
+	}
+
+	entry := s.catalog.EntryFor(key)
+	entry.Filename = filename
+	entry.Source = SourceUpload
+	entry.Product = r.FormValue("product")
+	entry.AttachedTo = attachedTo
+	entry.Description = r.FormValue("description")
+	entry.Tags = nil
+	entry.AddTags(tags.Require...)
+	entry.Size = int64(len(data))
+	digests := newDigester(s.downloader.Digests)
+	digests.Write(data)
+	entry.SHA256, entry.Digests = digests.sha256(), digests.extra()
+	entry.DownloadedAt = time.Now().UTC()
+	entry.Corrections = nil // The upload's own metadata replaces any earlier corrections
+	entry.Sources = nil
+	if entry.Product != "" {
+		entry.setSource("product", FieldSource{Method: MethodManual, Extractor: "upload", Confidence: 1})
+	}
+	s.catalog.RecordChange(ChangeUpdated, entry)
+	s.changedAt = time.Now()
+	s.save()
+
+	slog.Info("Stored supplemental document", "path", filePath, "bytes", len(data))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/documents/"+filename)
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(entry); err != nil {
+		slog.Error("Writing upload response failed", "err", err)
+	}
+}
@@ -0,0 +1,60 @@
+package sdscraper
+
+import ( // Import required packages
+	"bytes"  // For inspecting document bytes
+	"errors" // For error values
+	"fmt"    // For error messages
+	"io"     // For reading streamed documents
+	"sync"   // For one-time pdfcpu setup
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"          // For structural PDF validation
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model" // For pdfcpu configuration
+)
+
+var ( // PDF markers checked on every download
+	pdfHeader  = []byte("%PDF-") // Must open the file
+	pdfTrailer = []byte("%%EOF") // Must appear near the end of the file
+)
+
+const pdfTrailerWindow = 1024 // Bytes at the end searched for the trailer, allowing trailing whitespace
+
+var errInvalidPDF = errors.New("not a valid PDF") // Wrapped by every validation failure
+
+var pdfcpuSetup sync.Once // Guards the pdfcpu configuration switch
+
+// Checks that data looks like a complete PDF and, if structural is set, that pdfcpu can parse it
+func validatePDF(data []byte, structural bool) error {
+	return validatePDFReader(bytes.NewReader(data), int64(len(data)), structural)
+}
+
+// A document read in place, e.g. a spool file, so validation never needs all of it in memory
+type documentReader interface {
+	io.ReaderAt
+	io.ReadSeeker
+}
+
+// Checks a document of size bytes as validatePDF does, reading only its ends unless structural is set
+func validatePDFReader(content documentReader, size int64, structural bool) error {
+	head := make([]byte, len(pdfHeader))
+	if n, _ := content.ReadAt(head, 0); !bytes.Equal(head[:n], pdfHeader) { // HTML error pages are the usual culprit
+		return fmt.Errorf("%w: missing %s header", errInvalidPDF, pdfHeader)
+	}
+	tail := make([]byte, min(size, pdfTrailerWindow))
+	if n, _ := content.ReadAt(tail, size-int64(len(tail))); !bytes.Contains(tail[:n], pdfTrailer) { // Truncated transfers lose the trailer
+		return fmt.Errorf("%w: missing %s trailer (truncated?)", errInvalidPDF, pdfTrailer)
+	}
+	if !structural {
+		return nil
+	}
+
+	pdfcpuSetup.Do(func() { model.ConfigPath = "disable" }) // Keep pdfcpu from writing a config directory
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationRelaxed // Tolerate the quirks real-world producers emit
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := api.Validate(content, conf); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidPDF, err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package sdscraper
+
+import ( // Import required packages
+	"context"  // For cancelling remote checks
+	"fmt"      // For problem details
+	"net/http" // For the liveness requests
+	"sort"     // For a stable report order
+	"sync"     // For concurrent remote checks
+)
+
+// Kinds of discrepancy only Verify reports, on top of Fsck's
+const (
+	ProblemSourceGone        = "source-gone"        // Source URL answers 404 or 410
+	ProblemSourceUnreachable = "source-unreachable" // Source URL answers with another error or not at all
+	ProblemSourceChanged     = "source-changed"     // Source URL serves other content than was archived
+)
+
+// VerifyOptions selects what Verify audits
+type VerifyOptions struct {
+	OutputDir string       // Directory holding downloaded documents
+	Hashing   HashOptions  // Read strategy for re-hashing every file
+	Remote    bool         // Also confirm each live document's source URL still answers
+	Client    *http.Client // Client for the remote checks, nil for http.DefaultClient
+	Workers   int          // Concurrent remote checks, at least one
+	Sample    int          // Documents CompareRemote downloads again, drawn at random; zero for all
+}
+
+// Verify audits the mirror: every catalogued file is re-hashed against the manifest, missing, corrupted and orphaned
+// files are reported, and with Remote each live document's source is asked whether it still serves the document.
+// Problems come sorted by file; like Fsck's, the local ones can be repaired
+func Verify(ctx context.Context, m *Manifest, opts VerifyOptions) ([]Problem, error) {
+	problems := Fsck(m, FsckOptions{OutputDir: opts.OutputDir, VerifyHashes: true, Hashing: opts.Hashing})
+	if !opts.Remote {
+		return problems, nil
+	}
+	remote, err := checkSources(ctx, m, opts)
+	problems = append(problems, remote...)
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Filename < problems[j].Filename })
+	return problems, err
+}
+
+// Sends a HEAD request for every live, crawled document, falling back to GET where HEAD is not allowed
+func checkSources(ctx context.Context, m *Manifest, opts VerifyOptions) ([]Problem, error) {
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	urls := make(chan string)
+	var (
+		mu       sync.Mutex
+		problems []Problem
+		wg       sync.WaitGroup
+	)
+	for range max(opts.Workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for documentURL := range urls {
+				if problem, ok := checkSource(ctx, client, documentURL, m.Documents[documentURL]); ok {
+					mu.Lock()
+					problems = append(problems, problem)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, documentURL := range sortedKeys(m.Documents) {
+		entry := m.Documents[documentURL]
+		if entry.Source == SourceUpload || !entry.DeletedAt.IsZero() || entry.DownloadedAt.IsZero() {
+			continue // Nothing upstream, or already known to be gone
+		}
+		select {
+		case urls <- documentURL:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(urls)
+	wg.Wait()
+	return problems, ctx.Err()
+}
+
+// Asks one source URL whether it still answers, reporting a problem when it does not
+func checkSource(ctx context.Context, client *http.Client, documentURL string, entry *ManifestEntry) (Problem, bool) {
+	status, err := probe(ctx, client, http.MethodHead, documentURL)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		status, err = probe(ctx, client, http.MethodGet, documentURL)
+	}
+	problem := Problem{URL: documentURL, Filename: entry.Filename}
+	switch {
+	case err != nil:
+		if ctx.Err() != nil {
+			return Problem{}, false // Interrupted, not unreachable
+		}
+		problem.Kind, problem.Detail = ProblemSourceUnreachable, err.Error()
+		problem.Fix = "check the site; the next crawl retries the document"
+	case status == http.StatusNotFound || status == http.StatusGone:
+		problem.Kind, problem.Detail = ProblemSourceGone, fmt.Sprintf("source answers %d %s", status, http.StatusText(status))
+		problem.Fix = "the next crawl soft-deletes it once the listing drops it; keep the local copy as the last known version"
+	case status >= http.StatusBadRequest:
+		problem.Kind, problem.Detail = ProblemSourceUnreachable, fmt.Sprintf("source answers %d %s", status, http.StatusText(status))
+		problem.Fix = "check the site; the next crawl retries the document"
+	default:
+		return Problem{}, false
+	}
+	return problem, true
+}
+
+// Sends one request and returns the response status, discarding the body
+func probe(ctx context.Context, client *http.Client, method, documentURL string) (int, error) {
+	request, err := http.NewRequestWithContext(ctx, method, documentURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	response.Body.Close() // A GET fallback is cut short; only the status matters
+	return response.StatusCode, nil
+}
@@ -0,0 +1,56 @@
+package sdscraper
+
+import ( // Import required packages
+	"context"  // For cancellation
+	"io"       // For draining responses
+	"log/slog" // For structured logging
+	"net"      // For DNS resolution
+	"net/http" // For opening pooled connections
+	"net/url"  // For extracting hosts
+	"sync"     // For warming hosts in parallel
+	"time"     // For timings
+)
+
+// Resolves and connects to every host in documentURLs not already in warmed, so the first downloads
+// reuse pooled connections and unreachable hosts show up before the download burst
+func (s *Scraper) warmUp(ctx context.Context, documentURLs []string, warmed map[string]bool) {
+	origins := make(map[string]string) // scheme://host to hostname
+	for _, documentURL := range documentURLs {
+		parsed, err := url.Parse(documentURL)
+		if err != nil || warmed[parsed.Host] {
+			continue
+		}
+		warmed[parsed.Host] = true
+		origins[parsed.Scheme+"://"+parsed.Host] = parsed.Hostname()
+	}
+
+	var hosts sync.WaitGroup
+	for origin, hostname := range origins {
+		hosts.Add(1)
+		go func() {
+			defer hosts.Done()
+			defer recoverPanic("warm-up", origin, nil) // Warming is only an optimisation
+			started := time.Now()
+			addrs, err := net.DefaultResolver.LookupHost(ctx, hostname)
+			if err != nil {
+				slog.Warn("Resolving host failed", "host", hostname, "err", err)
+				return
+			}
+			resolved := time.Since(started)
+
+			request, err := http.NewRequestWithContext(ctx, http.MethodHead, origin+"/", nil)
+			if err != nil {
+				return
+			}
+			resp, err := s.Downloader.client().Do(request) // Leaves a keep-alive connection in the pool
+			if err != nil {
+				slog.Warn("Connecting to host failed", "host", hostname, "addrs", addrs, "err", err)
+				return
+			}
+			io.Copy(io.Discard, resp.Body) // Drain so the connection is reusable
+			resp.Body.Close()
+			slog.Debug("Warmed host", "host", hostname, "addrs", addrs, "dns", resolved, "connect", time.Since(started)-resolved)
+		}()
+	}
+	hosts.Wait()
+}
@@ -0,0 +1,259 @@
+package main // Declare main package
+
+import ( // Import required packages
+	"cmp"           // For template names standing in for site names
+	"context"       // For cancellation
+	"errors"        // For recognising interrupts
+	"flag"          // For per-site flag sets
+	"fmt"           // For error messages
+	"log/slog"      // For structured logging
+	"maps"          // For layering site templates
+	"os"            // For exit codes and stdout
+	"path/filepath" // For per-site paths
+	"regexp"        // For validating site names
+	"slices"        // For filtering sites
+	"strings"       // For deriving per-site file names
+	"time"          // For the retention window
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // Scraping and downloading
+)
+
+var siteNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`) // Site names become folder and file names
+
+// A configured site and the scraper crawling it
+type site struct {
+	name    string
+	scraper *sdscraper.Scraper
+}
+
+// Builds a scraper for each configured site, or those named in only: every site starts from the crawl settings on
+// the command line and in the profile, layers its own values on top (flags given on the command line still win),
+// and keeps its documents in outputDir/<name>/ with its own manifest, listing cache and checkpoint
+func configuredSites(config *configFile, base *flag.FlagSet, outputDir, manifestPath string, only []string) ([]site, error) {
+	explicit := explicitFlags(base)
+	var sites []site
+	for index, values := range config.Sites {
+		templateName, _ := values["template"].(string)
+		name, _ := values["name"].(string)
+		name = cmp.Or(name, templateName)
+		if !siteNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("site %d needs a name made of letters, digits, dots, dashes and underscores", index+1)
+		}
+		if len(only) > 0 && !slices.Contains(only, name) {
+			continue
+		}
+
+		flags := flag.NewFlagSet(name, flag.ContinueOnError)
+		crawl := registerCrawlFlags(flags)
+		siteOutput := flags.String("output", filepath.Join(outputDir, name)+string(filepath.Separator), "")
+		siteManifest := flags.String("manifest", withSuffix(manifestPath, name), "")
+		var copyErr error
+		base.VisitAll(func(f *flag.Flag) { // Start from the shared crawl settings
+			if target := flags.Lookup(f.Name); target != nil && copyErr == nil && f.Name != "output" && f.Name != "manifest" {
+				copyErr = copyFlag(target, f)
+			}
+		})
+		if copyErr != nil {
+			return nil, copyErr
+		}
+		flags.Set("listing-cache", name+"-{locale}.html") // Per-site state unless the site says otherwise
+		if *crawl.checkpointPath != "" {
+			flags.Set("checkpoint", withSuffix(*crawl.checkpointPath, name))
+		}
+
+		siteValues := make(map[string]any, len(values))
+		if templateName != "" { // The template's settings, which the entry's own override
+			template, err := vendorTemplateNamed(templateName)
+			if err != nil {
+				return nil, fmt.Errorf("site %s: %w", name, err)
+			}
+			maps.Copy(siteValues, template.Settings)
+		}
+		for key, value := range values {
+			if key != "name" && key != "template" {
+				siteValues[key] = value
+			}
+		}
+		if err := setFlags(flags, siteValues, explicit); err != nil {
+			return nil, fmt.Errorf("site %s: %w", name, err)
+		}
+
+		scraper := crawl.scraper()
+		scraper.Vendor = name // First-class in the unified catalog
+		scraper.Downloader.OutputDir = *siteOutput
+		scraper.ManifestPath = *siteManifest
+		sites = append(sites, site{name: name, scraper: scraper})
+	}
+	for _, name := range only {
+		if !slices.ContainsFunc(sites, func(s site) bool { return s.name == name }) {
+			return nil, fmt.Errorf("no site %q in the config file", name)
+		}
+	}
+	return sites, nil
+}
+
+// Copies a parsed flag's value onto the same flag of another set
+func copyFlag(target, source *flag.Flag) error {
+	if headers, ok := source.Value.(headerFlags); ok { // Its String form does not parse back
+		for name, values := range headers {
+			for _, value := range values {
+				if err := target.Value.Set(name + ": " + value); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if rules, ok := source.Value.(*tagRuleFlags); ok { // Patterns may contain commas
+		for _, rule := range rules.rules {
+			if err := target.Value.Set(rule.String()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if channels, ok := source.Value.(channelFlags); ok { // URLs may contain commas
+		for name, channelURL := range channels {
+			if err := target.Value.Set(name + "=" + channelURL); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if bandwidth, ok := source.Value.(*bandwidthFlags); ok { // Its String form lists several windows
+		for _, window := range bandwidth.windows {
+			if err := target.Value.Set(window.String()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if pins, ok := source.Value.(*pinFlags); ok { // Its String form lists several pins
+		for host, hostPins := range pins.Hosts {
+			for _, pin := range hostPins {
+				if err := target.Value.Set(host + "=" + pin); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return target.Value.Set(source.Value.String())
+}
+
+// Inserts -suffix before a path's extension, e.g. manifest.json to manifest-purell.json
+func withSuffix(path, suffix string) string {
+	extension := filepath.Ext(path)
+	return strings.TrimSuffix(path, extension) + "-" + suffix + extension
+}
+
+// Crawls the sites one after another, carrying on past failed ones, and exits non-zero if any failed
+func crawlSites(ctx context.Context, sites []site, dryRun bool, deleteRetention time.Duration, asJSON bool, reportPath string, unified unifiedCatalog, assertions sdscraper.Assertions) {
+	var failed []string
+	downloadsFailed := false  // Some site finished with failed downloads
+	assertionsFailed := false // Some site missed an -assert-* threshold
+	report := sitesReport{Schema: sitesSchema, Build: build(), Sites: []crawlReport{}}
+	for _, site := range sites {
+		site.scraper.DryRun = dryRun
+		site.scraper.DeleteRetention = deleteRetention
+		slog.Info("Crawling site", "site", site.name, "url", site.scraper.PageURL, "output", site.scraper.Downloader.OutputDir)
+		result, err := site.scraper.Run(ctx)
+		siteReport := newCrawlReport(result, dryRun, err)
+		siteReport.Site = site.name
+		siteReport.Build = nil // Stated once for all sites
+		if err == nil && !siteReport.assert(assertions, result) {
+			assertionsFailed = true
+		}
+		report.Sites = append(report.Sites, siteReport)
+		if errors.Is(err, context.Canceled) {
+			if asJSON {
+				printJSON(report)
+			}
+			if reportPath != "" {
+				writeJSONReport(reportPath, report)
+			}
+			slog.Info("Stopped; progress was saved and the next run resumes", "site", site.name)
+			os.Exit(130) // Conventional exit status for SIGINT
+		}
+		if err != nil {
+			slog.Error("Site failed", "site", site.name, "err", err)
+			failed = append(failed, site.name)
+			continue
+		}
+		if dryRun && !asJSON {
+			fmt.Printf("== %s\n", site.name)
+			printPlan(os.Stdout, result.Planned)
+			fmt.Println()
+			continue
+		}
+		downloadsFailed = downloadsFailed || len(result.Failed) > 0
+		if !asJSON {
+			fmt.Printf("== %s\n", site.name)
+			printSummary(os.Stdout, result)
+			fmt.Println()
+		}
+		slog.Info("Site finished", "site", site.name, "discovered", len(result.Discovered), "downloaded", len(result.Downloaded),
+			"not_modified", len(result.NotModified), "failed", len(result.Failed), "pruned", len(result.Pruned), "confidence", result.Confidence)
+	}
+	if unified.manifestPath != "" && !dryRun { // Also when some sites failed, from their last manifests
+		merged, err := mergeSiteCatalogs(sites, unified)
+		if err != nil {
+			slog.Error("Writing the unified catalog failed", "path", unified.manifestPath, "err", err)
+			failed = append(failed, "unified catalog")
+		} else {
+			report.Unified = &merged
+			slog.Info("Wrote the unified catalog", "path", unified.manifestPath, "documents", merged.Documents, "duplicates", merged.Duplicates)
+		}
+	}
+	if asJSON { // Also when some sites failed
+		printJSON(report)
+	}
+	if reportPath != "" {
+		writeJSONReport(reportPath, report)
+	}
+	if len(failed) > 0 {
+		fatal("Some sites failed", "sites", failed)
+	}
+	if assertionsFailed {
+		os.Exit(exitAssertionsFailed)
+	}
+	if downloadsFailed {
+		os.Exit(exitFailedDownloads)
+	}
+}
+
+// sitesReport is the JSON form of a crawl of the config file's sites
+type sitesReport struct {
+	Schema  string                 `json:"schema"`
+	Build   *sdscraper.BuildInfo   `json:"build"`
+	Sites   []crawlReport          `json:"sites"`             // In crawl order
+	Unified *sdscraper.MergeResult `json:"unified,omitempty"` // What the unified catalog holds, when one was written
+}
+
+// Where a sites crawl writes the catalog merged across its vendors
+type unifiedCatalog struct {
+	manifestPath string // Empty to write none
+	outputDir    string // Folder holding every site's folder, which the merged file names are relative to
+}
+
+// Merges the sites' manifests into the unified catalog, each site's files named by their path below the common
+// output folder
+func mergeSiteCatalogs(sites []site, unified unifiedCatalog) (sdscraper.MergeResult, error) {
+	var parts []sdscraper.CatalogPart
+	for _, site := range sites {
+		dir, err := filepath.Rel(unified.outputDir, site.scraper.Downloader.OutputDir)
+		if err != nil || dir == ".." || strings.HasPrefix(dir, ".."+string(filepath.Separator)) {
+			return sdscraper.MergeResult{}, fmt.Errorf("site %s keeps its files outside %s", site.name, unified.outputDir)
+		}
+		manifest, err := sdscraper.ReadManifest(site.scraper.ManifestPath)
+		if errors.Is(err, os.ErrNotExist) {
+			continue // Never crawled successfully yet
+		}
+		if err != nil {
+			return sdscraper.MergeResult{}, fmt.Errorf("site %s: %w", site.name, err)
+		}
+		parts = append(parts, sdscraper.CatalogPart{Vendor: site.scraper.Vendor, Manifest: manifest, Dir: filepath.ToSlash(dir)})
+	}
+	merged, result := sdscraper.MergeCatalogs(parts)
+	return result, sdscraper.SaveManifest(unified.manifestPath, merged)
+}
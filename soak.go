@@ -0,0 +1,77 @@
+package main // Declare main package
+
+import ( // Import required packages
+	"context"        // For stopping the loop
+	"errors"         // For recognising leaks and aborted cycles
+	"fmt"            // For the samples table
+	"log/slog"       // For structured logging
+	"os"             // For output and exit codes
+	"text/tabwriter" // For aligned tables
+	"time"           // For the pause between cycles
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // Scraping and the leak monitor
+)
+
+// soakReport is the JSON form of a soak run
+type soakReport struct {
+	Schema  string                     `json:"schema"`
+	Build   *sdscraper.BuildInfo       `json:"build"`
+	Passed  bool                       `json:"passed"`
+	Leak    string                     `json:"leak,omitempty"` // What grew, when the soak failed
+	Samples []sdscraper.ResourceSample `json:"samples"`
+}
+
+// Runs cycles sync cycles back to back, interval apart, sampling goroutines, open files and the heap after each and
+// stopping with exit status 1 once they grow past the baseline; an internal check that the daemon is safe to leave
+// running for months
+func soak(ctx context.Context, scraper *sdscraper.Scraper, cycles int, interval time.Duration, asJSON bool) {
+	scraper.ForceRefresh = true // Every cycle does the full work of a watch cycle
+	monitor := &sdscraper.SoakMonitor{}
+	var leak error
+	for cycle := 1; cycle <= cycles && ctx.Err() == nil; cycle++ {
+		result, err := scraper.Run(ctx)
+		switch {
+		case errors.Is(err, context.Canceled):
+			continue
+		case err != nil:
+			slog.Error("Sync cycle failed", "cycle", cycle, "err", err) // Failure paths must not leak either
+		default:
+			reportCycle(result)
+		}
+		sample, err := monitor.Sample()
+		slog.Info("Soak sample", "cycle", sample.Cycle, "goroutines", sample.Goroutines, "open_files", sample.OpenFiles, "heap_bytes", sample.HeapBytes)
+		if err != nil {
+			leak = err
+			break
+		}
+		if cycle < cycles && interval > 0 {
+			select {
+			case <-time.After(interval):
+			case <-ctx.Done():
+			}
+		}
+	}
+
+	report := soakReport{Schema: soakSchema, Build: build(), Passed: leak == nil && ctx.Err() == nil, Samples: monitor.Samples}
+	if leak != nil {
+		report.Leak = leak.Error()
+	}
+	if asJSON {
+		printJSON(report)
+	} else {
+		table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(table, "CYCLE\tGOROUTINES\tOPEN FILES\tHEAP BYTES\t")
+		for _, sample := range monitor.Samples {
+			fmt.Fprintf(table, "%d\t%d\t%d\t%d\t\n", sample.Cycle, sample.Goroutines, sample.OpenFiles, sample.HeapBytes)
+		}
+		table.Flush()
+	}
+	switch {
+	case leak != nil:
+		fatal("Soak failed", "err", leak)
+	case ctx.Err() != nil:
+		slog.Info("Soak interrupted", "cycles", len(monitor.Samples))
+		os.Exit(130)
+	}
+	slog.Info("Soak passed; resource use stayed bounded", "cycles", len(monitor.Samples))
+}
@@ -0,0 +1,82 @@
+package main // Declare main package
+
+import ( // Import required packages
+	_ "embed"        // For the bundled templates
+	"flag"           // For parsing vendors flags
+	"fmt"            // For printing templates
+	"os"             // For output
+	"strings"        // For listing names
+	"text/tabwriter" // For aligned tables
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // Build information
+	"gopkg.in/yaml.v3"                                                  // For the template data
+)
+
+//go:embed vendors.yaml
+var vendorData []byte // Site templates shipped with the binary
+
+// A built-in site template: the settings of a sites entry for one vendor's public SDS pages
+type vendorTemplate struct {
+	Name        string         `json:"name" yaml:"name"`
+	Vendor      string         `json:"vendor" yaml:"vendor"`
+	Description string         `json:"description" yaml:"description"`
+	Settings    map[string]any `json:"settings" yaml:"settings"`
+}
+
+// Returns the built-in templates in file order
+func vendorTemplates() []vendorTemplate {
+	var file struct {
+		Templates []vendorTemplate `yaml:"templates"`
+	}
+	if err := yaml.Unmarshal(vendorData, &file); err != nil {
+		panic("vendors.yaml: " + err.Error()) // Bundled at build time, so a bad file never ships
+	}
+	return file.Templates
+}
+
+// Returns the built-in template called name
+func vendorTemplateNamed(name string) (vendorTemplate, error) {
+	var names []string
+	for _, template := range vendorTemplates() {
+		if template.Name == name {
+			return template, nil
+		}
+		names = append(names, template.Name)
+	}
+	return vendorTemplate{}, fmt.Errorf("no vendor template %q (have %s)", name, strings.Join(names, ", "))
+}
+
+// Returns a sites entry crawling each named template under its own name, for -vendors
+func vendorSites(names []string) []map[string]any {
+	sites := make([]map[string]any, len(names))
+	for i, name := range names {
+		sites[i] = map[string]any{"name": name, "template": name}
+	}
+	return sites
+}
+
+// vendorsReport is the JSON form of the vendors command
+type vendorsReport struct {
+	Schema    string               `json:"schema"`
+	Build     *sdscraper.BuildInfo `json:"build"`
+	Templates []vendorTemplate     `json:"templates"`
+}
+
+// Runs "vendors", listing the built-in site templates -vendors and sites entries can start from
+func runVendors(args []string) {
+	flags := flag.NewFlagSet("vendors", flag.ExitOnError) // Vendors flags
+	jsonOutput := formatFlags(flags)                      // -format
+	flags.Parse(args)                                     // Exits on invalid flags
+	templates := vendorTemplates()
+	if jsonOutput() {
+		printJSON(vendorsReport{Schema: vendorsSchema, Build: build(), Templates: templates})
+		return
+	}
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "NAME\tVENDOR\tPAGE URL\tDESCRIPTION")
+	for _, template := range templates {
+		fmt.Fprintf(table, "%s\t%s\t%v\t%s\n", template.Name, template.Vendor, template.Settings["page-url"], template.Description)
+	}
+	table.Flush()
+	fmt.Println("\nmirror some with -vendors name,name, or start a config file sites entry from one with template: name")
+}
@@ -0,0 +1,99 @@
+package main // Declare main package
+
+import ( // Import required packages
+	"context"  // For stopping the loop
+	"errors"   // For recognising aborted cycles
+	"log/slog" // For structured logging
+	"net"      // For the metrics listener
+	"net/http" // For the metrics server
+	"time"     // For scheduling cycles
+
+	"github.com/Tech-Trailblazers/gojo-com-documentation/pkg/sdscraper" // Scraping and downloading
+)
+
+// Returns the queue running the scraper's sync cycles, which logs what changed per cycle and, with asJSON, prints each
+// cycle's report as a line of JSON; cycles that would overlap follow policy
+func syncQueue(ctx context.Context, scraper *sdscraper.Scraper, policy sdscraper.OverlapPolicy, asJSON bool) *sdscraper.RunQueue {
+	scraper.ForceRefresh = true // Every cycle must see the current listing
+	return &sdscraper.RunQueue{Scraper: scraper, Policy: policy, OnResult: func(result *sdscraper.Result, err error) {
+		scraper.Downloader.Metrics.ObserveRun(result, err)
+		if asJSON && ctx.Err() == nil {
+			printJSONLine(newCrawlReport(result, false, err))
+		}
+		switch {
+		case ctx.Err() != nil:
+		case errors.Is(err, context.Canceled):
+			slog.Info("Sync cycle aborted; the next cycle resumes it")
+		case err != nil:
+			slog.Error("Sync cycle failed", "err", err)
+		default:
+			reportCycle(result)
+		}
+	}}
+}
+
+// Triggers queue now and then at each time next returns until ctx is done
+func watch(ctx context.Context, queue *sdscraper.RunQueue, next func(time.Time) time.Time) {
+	defer queue.Wait() // Let an interrupted run save its checkpoint
+
+	for {
+		queue.Trigger(ctx)
+
+		at := next(time.Now()) // Triggers keep to the schedule however long runs take
+		if at.IsZero() {
+			slog.Warn("Schedule has no further runs; stopping")
+			return
+		}
+		slog.Info("Next sync cycle", "at", at)
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// Serves metrics on addr until ctx is done; a listener that fails to start is fatal, as the operator asked for it
+func serveMetrics(ctx context.Context, addr string, metrics *sdscraper.Metrics) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		fatal("Metrics endpoint failed", "addr", addr, "err", err)
+	}
+	server := &http.Server{Handler: metrics.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		server.Close() // Scrapes are short; nothing worth draining
+	}()
+	go server.Serve(listener)
+	slog.Info("Serving metrics", "addr", listener.Addr().String())
+}
+
+// Returns a schedule firing every interval
+func every(interval time.Duration) func(time.Time) time.Time {
+	return func(now time.Time) time.Time { return now.Add(interval) }
+}
+
+// Logs the added, updated and removed documents of one cycle
+func reportCycle(result *sdscraper.Result) {
+	updated := result.Updated()
+	for _, documentURL := range result.Added {
+		slog.Info("Document added", "url", documentURL)
+	}
+	for _, documentURL := range updated {
+		slog.Info("Document updated", "url", documentURL)
+	}
+	for _, documentURL := range result.Removed {
+		slog.Info("Document no longer listed", "url", documentURL)
+	}
+	slog.Info("Sync cycle finished",
+		"discovered", len(result.Discovered),
+		"added", len(result.Added),
+		"updated", len(updated),
+		"removed", len(result.Removed),
+		"failed", len(result.Failed),
+		"pruned", len(result.Pruned),
+		"confidence", result.Confidence,
+	)
+}